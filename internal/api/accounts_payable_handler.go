@@ -0,0 +1,243 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type AccountsPayableHandler struct {
+	service *services.AccountsPayableService
+	audit   *audit.Recorder
+	logger  *slog.Logger
+}
+
+func NewAccountsPayableHandler(s *services.AccountsPayableService, a *audit.Recorder, l *slog.Logger) *AccountsPayableHandler {
+	return &AccountsPayableHandler{service: s, audit: a, logger: l}
+}
+
+// HandleListPayables godoc
+// @Summary      List expenses left to pay
+// @Description  Responds with unpaid and partially paid expenses, soonest due first, with the total owed and how much of it is overdue.
+// @Tags         accounts_payable
+// @Produce      json
+// @Param        provider_id  query     int     false  "Only this provider"
+// @Param        until        query     string  false  "Only due on or before this day (YYYY-MM-DD)"
+// @Success      200          {object}  PayablesResponse
+// @Failure      400          {object}  utils.HTTPError
+// @Failure      500          {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/payables [get]
+func (h *AccountsPayableHandler) HandleListPayables(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var providerID *int64
+	if v := q.Get("provider_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid provider_id")
+			return
+		}
+		providerID = &id
+	}
+	var until *time.Time
+	if v := q.Get("until"); v != "" {
+		d, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "until must be a date (YYYY-MM-DD)")
+			return
+		}
+		until = &d
+	}
+
+	payables, err := h.service.Payables(providerID, until)
+	if err != nil {
+		h.logger.Error("listing payables", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"payables": payables}, "", nil)
+}
+
+// HandleListProviderBalances godoc
+// @Summary      List provider balances
+// @Description  Responds with what is owed to each provider, largest first, with the overdue part and the next due date.
+// @Tags         accounts_payable
+// @Produce      json
+// @Success      200  {object}  ProviderBalancesResponse
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/payables/balances [get]
+func (h *AccountsPayableHandler) HandleListProviderBalances(w http.ResponseWriter, r *http.Request) {
+	balances, err := h.service.Balances()
+	if err != nil {
+		h.logger.Error("listing provider balances", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"balances": balances}, "", nil)
+}
+
+// HandleCreateProviderPayment godoc
+// @Summary      Register a provider payment
+// @Description  Records a payment to a provider with one payment method and applies it to one or more of its expenses. The payment amount is the sum of the allocations, each of which may not exceed what is left to pay of its expense.
+// @Tags         accounts_payable
+// @Accept       json
+// @Produce      json
+// @Param        body  body      services.ProviderPaymentRequest  true  "Payment data"
+// @Success      201   {object}  ProviderPaymentResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError "Provider, payment method or expense not found"
+// @Failure      409   {object}  utils.HTTPError "Amount exceeds the expense balance"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/provider_payments [post]
+func (h *AccountsPayableHandler) HandleCreateProviderPayment(w http.ResponseWriter, r *http.Request) {
+	var req services.ProviderPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	req.UserID = middleware.GetUser(r).ID
+
+	payment, err := h.service.RegisterPayment(req)
+	if err != nil {
+		h.writePayablesError(w, err)
+		return
+	}
+
+	h.audit.Created(r, store.AuditProviderPayment, payment.ID, payment)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"payment": payment}, "", nil)
+}
+
+// HandleListProviderPayments godoc
+// @Summary      List provider payments
+// @Description  Responds with payments made between two days, newest first, without their allocations.
+// @Tags         accounts_payable
+// @Produce      json
+// @Param        provider_id  query     int     false  "Only this provider"
+// @Param        from         query     string  false  "First day (YYYY-MM-DD, default: 30 days before to)"
+// @Param        to           query     string  false  "Last day (YYYY-MM-DD, default: today)"
+// @Success      200          {object}  ProviderPaymentsResponse
+// @Failure      400          {object}  utils.HTTPError
+// @Failure      500          {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/provider_payments [get]
+func (h *AccountsPayableHandler) HandleListProviderPayments(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var providerID int64
+	if v := q.Get("provider_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid provider_id")
+			return
+		}
+		providerID = id
+	}
+	from, to, err := parseMovementRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	payments, err := h.service.ListPayments(providerID, from, to)
+	if err != nil {
+		h.writePayablesError(w, err)
+		return
+	}
+	if payments == nil {
+		payments = []*store.ProviderPayment{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"payments": payments}, "", nil)
+}
+
+// HandleGetProviderPayment godoc
+// @Summary      Get a provider payment
+// @Description  Responds with the payment and the expenses it was applied to.
+// @Tags         accounts_payable
+// @Produce      json
+// @Param        id   path      int  true  "Payment ID"
+// @Success      200  {object}  ProviderPaymentResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/provider_payments/{id} [get]
+func (h *AccountsPayableHandler) HandleGetProviderPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid payment id")
+		return
+	}
+
+	payment, err := h.service.GetPayment(id)
+	if err != nil {
+		h.writePayablesError(w, err)
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"payment": payment}, "", nil)
+}
+
+// HandleGetProviderStatement godoc
+// @Summary      Get a provider account statement
+// @Description  Responds with the balance owed to the provider before from, every expense (debit) and payment (credit) between from and to with the running balance, and the closing balance. Expenses recorded as paid show a settling credit on the same day.
+// @Tags         accounts_payable
+// @Produce      json
+// @Param        id    path      int     true   "Provider ID"
+// @Param        from  query     string  false  "First day (YYYY-MM-DD, default: 30 days before to)"
+// @Param        to    query     string  false  "Last day (YYYY-MM-DD, default: today)"
+// @Success      200   {object}  ProviderStatementResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/providers/{id}/statement [get]
+func (h *AccountsPayableHandler) HandleGetProviderStatement(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid provider id")
+		return
+	}
+	from, to, err := parseMovementRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	statement, err := h.service.Statement(id, from, to)
+	if err != nil {
+		h.writePayablesError(w, err)
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"statement": statement}, "", nil)
+}
+
+func (h *AccountsPayableHandler) writePayablesError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrProviderNotFound), errors.Is(err, services.ErrPaymentMethodNotFound),
+		errors.Is(err, services.ErrExpenseNotFound), errors.Is(err, services.ErrPaymentNotFound):
+		utils.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrPaymentExceedsBalance):
+		utils.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrPaymentWithoutExpenses), errors.Is(err, services.ErrInvalidPaymentAmount),
+		errors.Is(err, services.ErrDuplicatePaymentExpense), errors.Is(err, services.ErrInvalidPaymentDate),
+		errors.Is(err, services.ErrExpenseOtherProvider), errors.Is(err, services.ErrInvalidStatementRange):
+		utils.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("accounts payable", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type APIKeyHandler struct {
+	service *services.APIKeyService
+	audit   *audit.Recorder
+	logger  *slog.Logger
+}
+
+func NewAPIKeyHandler(s *services.APIKeyService, a *audit.Recorder, l *slog.Logger) *APIKeyHandler {
+	return &APIKeyHandler{service: s, audit: a, logger: l}
+}
+
+// HandleListAPIKeys godoc
+// @Summary      List API keys
+// @Description  Responds with every API key, revoked and expired ones included, newest first. The keys themselves are never shown again after creation, only their prefix.
+// @Tags         api_keys
+// @Produce      json
+// @Success      200  {object}  APIKeysResponse
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/api_keys [get]
+func (h *APIKeyHandler) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.List()
+	if err != nil {
+		h.logger.Error("listing api keys", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if keys == nil {
+		keys = []*store.APIKey{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"api_keys": keys}, "", nil)
+}
+
+// HandleCreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Issues a long-lived key for an integration, sent as "Authorization: Bearer <key>". It acts as the user creating it, limited to the given scopes, which must be permissions of that user's role other than users.manage. allowed_ips restricts it to IPs or CIDR ranges and expires_at is optional. The key is only returned here.
+// @Tags         api_keys
+// @Accept       json
+// @Produce      json
+// @Param        body  body      services.APIKeyRequest  true  "API key data"
+// @Success      201   {object}  APIKeyCreatedResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/api_keys [post]
+func (h *APIKeyHandler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req services.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	key, plaintext, err := h.service.Create(middleware.GetUser(r), req)
+	if err != nil {
+		h.writeAPIKeyError(w, err)
+		return
+	}
+
+	h.audit.Created(r, store.AuditAPIKey, key.ID, key)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"api_key": key, "key": plaintext}, "", nil)
+}
+
+// HandleRevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Disables a key for good; requests with it get 401 from then on.
+// @Tags         api_keys
+// @Param        id   path  int  true  "API key ID"
+// @Success      204
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      409  {object}  utils.HTTPError "Already revoked"
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/api_keys/{id} [delete]
+func (h *APIKeyHandler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid api key id")
+		return
+	}
+
+	if err := h.service.Revoke(id); err != nil {
+		h.writeAPIKeyError(w, err)
+		return
+	}
+	h.audit.Record(r, store.AuditAPIKey, id, "revoke", nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIKeyHandler) writeAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		utils.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrAPIKeyRevoked):
+		utils.Error(w, http.StatusConflict, err.Error())
+	case isAPIKeyValidationError(err):
+		utils.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("saving api key", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func isAPIKeyValidationError(err error) bool {
+	for _, known := range []error{
+		services.ErrAPIKeyNameEmpty,
+		services.ErrAPIKeyNoScopes,
+		services.ErrAPIKeyScopeNotGranted,
+		services.ErrAPIKeyScopeForbidden,
+		services.ErrAPIKeyInvalidIP,
+		services.ErrAPIKeyExpired,
+		services.ErrUnknownPermission,
+	} {
+		if errors.Is(err, known) {
+			return true
+		}
+	}
+	return false
+}
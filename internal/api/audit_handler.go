@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type AuditHandler struct {
+	auditStore store.AuditStore
+	logger     *slog.Logger
+}
+
+func NewAuditHandler(s store.AuditStore, l *slog.Logger) *AuditHandler {
+	return &AuditHandler{auditStore: s, logger: l}
+}
+
+// HandleListAuditLog godoc
+// @Summary      List the audit log
+// @Description  Responds with the recorded changes, newest first. Updates keep only the fields that changed.
+// @Tags         audit
+// @Produce      json
+// @Param        entity      query     string  false  "Entity (product, order, expense, user, ...)"
+// @Param        entity_id   query     int     false  "Entity ID"
+// @Param        user_id     query     int     false  "User who made the change"
+// @Param        action      query     string  false  "Action (create, update, delete, or a state transition)"
+// @Param        request_id  query     string  false  "Request ID"
+// @Param        from        query     string  false  "First day (YYYY-MM-DD)"
+// @Param        to          query     string  false  "Last day (YYYY-MM-DD)"
+// @Param        limit       query     int     false  "Limit (default 50)"
+// @Param        offset      query     int     false  "Offset"
+// @Success      200         {object}  AuditLogResponse
+// @Failure      400         {object}  utils.HTTPError
+// @Failure      500         {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/audit_log [get]
+func (h *AuditHandler) HandleListAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, err := parseAuditFilter(q)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+	filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	entries, total, err := h.auditStore.List(filter)
+	if err != nil {
+		h.logger.Error("listing audit log", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if entries == nil {
+		entries = []*store.AuditEntry{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"entries": entries}, "", &utils.Meta{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Total:  total,
+	})
+}
+
+// parseAuditFilter reads the audit log filters shared by the API and the web
+// viewer. to is inclusive: the filter ends at the start of the next day.
+func parseAuditFilter(q url.Values) (store.AuditFilter, error) {
+	f := store.AuditFilter{
+		Entity:    q.Get("entity"),
+		Action:    q.Get("action"),
+		RequestID: q.Get("request_id"),
+	}
+	if v := q.Get("entity_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, errors.New("invalid entity_id")
+		}
+		f.EntityID = &id
+	}
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, errors.New("invalid user_id")
+		}
+		f.UserID = &id
+	}
+	if v := q.Get("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("invalid from date, use YYYY-MM-DD")
+		}
+		f.From = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return f, errors.New("invalid to date, use YYYY-MM-DD")
+		}
+		t = t.AddDate(0, 0, 1)
+		f.To = &t
+	}
+	return f, nil
+}
@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
@@ -18,12 +19,14 @@ type registerCategoryRequest struct {
 
 type CategoryHandler struct {
 	categoryStore store.CategoryStore
+	audit         *audit.Recorder
 	logger        *slog.Logger
 }
 
-func NewCategoryHandler(categoryStore store.CategoryStore, logger *slog.Logger) *CategoryHandler {
+func NewCategoryHandler(categoryStore store.CategoryStore, audit *audit.Recorder, logger *slog.Logger) *CategoryHandler {
 	return &CategoryHandler{
 		categoryStore: categoryStore,
+		audit:         audit,
 		logger:        logger,
 	}
 }
@@ -76,6 +79,7 @@ func (h *CategoryHandler) HandleRegisterCategory(w http.ResponseWriter, r *http.
 		return
 	}
 
+	h.audit.Created(r, store.AuditCategory, category.ID, category)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"category": category}, "", nil)
 }
 
@@ -118,6 +122,7 @@ func (h *CategoryHandler) HandleUpdateCategory(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusBadRequest, "invalid request payload")
 		return
 	}
+	before := *category
 	if updateCategoryRequest.Name != nil {
 		category.Name = *updateCategoryRequest.Name
 	}
@@ -132,6 +137,7 @@ func (h *CategoryHandler) HandleUpdateCategory(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	h.audit.Updated(r, store.AuditCategory, category.ID, before, category)
 	utils.OK(w, http.StatusOK, utils.Envelope{"category": category}, "", nil)
 }
 
@@ -203,6 +209,13 @@ func (h *CategoryHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	category, err := h.categoryStore.GetCategoryByID(categoryID)
+	if err != nil {
+		h.logger.Error("getCategoryByID", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
 	err = h.categoryStore.DeleteCategory(categoryID)
 	if err == sql.ErrNoRows {
 		http.Error(w, "category not found", http.StatusNotFound)
@@ -214,5 +227,6 @@ func (h *CategoryHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	h.audit.Deleted(r, store.AuditCategory, categoryID, category)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
@@ -25,11 +26,12 @@ type registerClientRequest struct {
 
 type ClientHandler struct {
 	clientStore store.ClientStore
+	audit       *audit.Recorder
 	logger      *slog.Logger
 }
 
-func NewClientHandler(clientStore store.ClientStore, logger *slog.Logger) *ClientHandler {
-	return &ClientHandler{clientStore: clientStore, logger: logger}
+func NewClientHandler(clientStore store.ClientStore, audit *audit.Recorder, logger *slog.Logger) *ClientHandler {
+	return &ClientHandler{clientStore: clientStore, audit: audit, logger: logger}
 }
 
 var emailRe = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
@@ -99,6 +101,7 @@ func (h *ClientHandler) HandleRegisterClient(w http.ResponseWriter, r *http.Requ
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Created(r, store.AuditClient, c.ID, c)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"client": c}, "", nil)
 }
 
@@ -146,6 +149,7 @@ func (h *ClientHandler) HandleUpdateClient(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	before := *cl
 	if req.Name != nil {
 		cl.Name = *req.Name
 	}
@@ -177,6 +181,7 @@ func (h *ClientHandler) HandleUpdateClient(w http.ResponseWriter, r *http.Reques
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Updated(r, store.AuditClient, cl.ID, before, cl)
 	utils.OK(w, http.StatusOK, utils.Envelope{"client": cl}, "", nil)
 }
 
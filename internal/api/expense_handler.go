@@ -2,6 +2,8 @@ package api
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -9,20 +11,33 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	"github.com/google/uuid"
 )
 
 type ExpenseHandler struct {
-	expenseStore store.ExpenseStore
-	logger       *slog.Logger
-	uploadDir    string
+	expenseStore      store.ExpenseStore
+	expenseService    *services.ExpenseService
+	extractionService *services.ExpenseExtractionService
+	audit             *audit.Recorder
+	logger            *slog.Logger
+	uploadDir         string
 }
 
-func NewExpenseHandler(expenseStore store.ExpenseStore, logger *slog.Logger) *ExpenseHandler {
+func NewExpenseHandler(
+	expenseStore store.ExpenseStore,
+	expenseService *services.ExpenseService,
+	extractionService *services.ExpenseExtractionService,
+	audit *audit.Recorder,
+	logger *slog.Logger,
+) *ExpenseHandler {
 	// Ensure upload directory exists
 	// Ideally this should be configurable, but hardcoding relative path for now as per constraints
 	uploadDir := "uploads/expenses"
@@ -30,111 +45,177 @@ func NewExpenseHandler(expenseStore store.ExpenseStore, logger *slog.Logger) *Ex
 		logger.Error("failed to create upload directory", "error", err)
 	}
 	return &ExpenseHandler{
-		expenseStore: expenseStore,
-		logger:       logger,
-		uploadDir:    uploadDir,
+		expenseStore:      expenseStore,
+		expenseService:    expenseService,
+		extractionService: extractionService,
+		audit:             audit,
+		logger:            logger,
+		uploadDir:         uploadDir,
 	}
 }
 
 // HandleCreateExpense godoc
 // @Summary      Creates an expense
-// @Description  Creates a new expense with optional receipt image
+// @Description  Creates a new expense with optional receipt image, line items, provider invoice and VAT breakdown. Accepts multipart/form-data, where items and vat are JSON arrays, or a JSON body without image. The amount is derived from the VAT breakdown or the items when omitted. Responds 409 when the provider invoice number is already loaded.
 // @Tags         expenses
 // @Accept       multipart/form-data
+// @Accept       json
 // @Produce      json
-// @Param        amount       formData  string  true  "Amount"
-// @Param        category_id  formData  int     true  "Category ID"
-// @Param        type         formData  string  true  "Type (local/production)"
-// @Param        date         formData  string  true  "Date (YYYY-MM-DD)"
-// @Param        provider_id  formData  int     false "Provider ID"
-// @Param        image        formData  file    false "Receipt Image"
+// @Param        amount          formData  string  false "Amount; derived from vat or items when empty"
+// @Param        category_id     formData  int     true  "Category ID"
+// @Param        type            formData  string  true  "Type (local/production)"
+// @Param        date            formData  string  true  "Date (YYYY-MM-DD)"
+// @Param        provider_id     formData  int     false "Provider ID"
+// @Param        due_date        formData  string  false "Due date (YYYY-MM-DD); leaves the expense pending payment"
+// @Param        invoice_number  formData  string  false "Provider invoice number"
+// @Param        invoice_type    formData  string  false "Invoice type (A/B/C/M/X)"
+// @Param        items           formData  string  false "JSON array of services.ExpenseItemRequest"
+// @Param        vat             formData  string  false "JSON array of services.ExpenseVATRequest"
+// @Param        extraction_id   formData  int     false "OCR extraction the expense is loaded from; its image is used when none is uploaded"
+// @Param        image           formData  file    false "Receipt Image"
+// @Param        request         body      services.ExpenseRequest  false "Expense (JSON body)"
 // @Success      201          {object}  store.Expense
 // @Failure      400          {object}  utils.HTTPError
+// @Failure      409          {object}  utils.HTTPError
 // @Failure      500          {object}  utils.HTTPError
 // @Security     BearerAuth
 // @Router       /api/v1/expenses [post]
 func (h *ExpenseHandler) HandleCreateExpense(w http.ResponseWriter, r *http.Request) {
-	// Limit upload size (e.g. 10MB)
-	r.ParseMultipartForm(10 << 20)
+	var req services.ExpenseRequest
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	} else {
+		// Limit upload size (e.g. 10MB)
+		r.ParseMultipartForm(10 << 20)
 
-	amountStr := r.FormValue("amount")
-	categoryIDStr := r.FormValue("category_id")
-	typeStr := r.FormValue("type")
-	dateStr := r.FormValue("date")
-	providerIDStr := r.FormValue("provider_id")
+		if err := readExpenseForm(r, &req); err != nil {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
 
-	// Validation
-	if amountStr == "" || categoryIDStr == "" || typeStr == "" || dateStr == "" {
-		utils.Error(w, http.StatusBadRequest, "missing required fields")
-		return
+		imagePath, ok := h.saveImage(w, r)
+		if !ok {
+			return
+		}
+		req.ImagePath = imagePath
 	}
 
-	date, err := time.Parse("2006-01-02", dateStr)
+	expense, err := h.expenseService.Create(req)
 	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid date format (YYYY-MM-DD)")
+		switch {
+		case errors.Is(err, store.ErrDuplicateExpenseInvoice):
+			utils.Error(w, http.StatusConflict, err.Error())
+		case isExpenseValidationError(err):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			h.logger.Error("creating expense", "error", err)
+			utils.Error(w, http.StatusInternalServerError, "internal server error")
+		}
 		return
 	}
 
-	categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
+	h.audit.Created(r, store.AuditExpense, expense.ID, expense)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"expense": expense}, "", nil)
+}
+
+// saveImage stores the uploaded "image" file, if any, and returns its path.
+// It writes the error response and returns false when the upload fails.
+func (h *ExpenseHandler) saveImage(w http.ResponseWriter, r *http.Request) (string, bool) {
+	file, header, err := r.FormFile("image")
+	if err == http.ErrMissingFile {
+		return "", true
+	}
 	if err != nil {
-		utils.Error(w, http.StatusBadRequest, "invalid category id")
-		return
+		h.logger.Error("file upload error", "error", err)
+		utils.Error(w, http.StatusBadRequest, "file upload error")
+		return "", false
 	}
+	defer file.Close()
 
-	var providerID *int64
-	if providerIDStr != "" {
-		pid, err := strconv.ParseInt(providerIDStr, 10, 64)
-		if err == nil {
-			providerID = &pid
-		}
+	ext := filepath.Ext(header.Filename)
+	filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	destPath := filepath.Join(h.uploadDir, filename)
+
+	dst, err := os.Create(destPath)
+	if err != nil {
+		h.logger.Error("failed to create file", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return "", false
 	}
+	defer dst.Close()
 
-	// Handle File Upload
-	var imagePath string
-	file, header, err := r.FormFile("image")
-	if err == nil {
-		defer file.Close()
-		
-		ext := filepath.Ext(header.Filename)
-		filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-		destPath := filepath.Join(h.uploadDir, filename)
-		
-		dst, err := os.Create(destPath)
+	if _, err := io.Copy(dst, file); err != nil {
+		h.logger.Error("failed to save file", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return "", false
+	}
+	return destPath, true
+}
+
+// readExpenseForm fills req from a create expense form, where items and vat
+// are JSON arrays.
+func readExpenseForm(r *http.Request, req *services.ExpenseRequest) error {
+	req.Amount = r.FormValue("amount")
+	req.Type = store.ExpenseType(r.FormValue("type"))
+	req.Date = r.FormValue("date")
+	req.DueDate = r.FormValue("due_date")
+	req.InvoiceNumber = r.FormValue("invoice_number")
+	req.InvoiceType = store.InvoiceType(r.FormValue("invoice_type"))
+
+	if v := r.FormValue("category_id"); v != "" {
+		categoryID, err := strconv.ParseInt(v, 10, 64)
 		if err != nil {
-			h.logger.Error("failed to create file", "error", err)
-			utils.Error(w, http.StatusInternalServerError, "internal server error")
-			return
+			return errors.New("invalid category id")
 		}
-		defer dst.Close()
-
-		if _, err := io.Copy(dst, file); err != nil {
-			h.logger.Error("failed to save file", "error", err)
-			utils.Error(w, http.StatusInternalServerError, "internal server error")
-			return
+		req.CategoryID = categoryID
+	}
+	if pid, err := strconv.ParseInt(r.FormValue("provider_id"), 10, 64); err == nil && pid != 0 {
+		req.ProviderID = &pid
+	}
+	if v := r.FormValue("extraction_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return errors.New("invalid extraction id")
 		}
-		imagePath = destPath
-	} else if err != http.ErrMissingFile {
-		h.logger.Error("file upload error", "error", err)
-		utils.Error(w, http.StatusBadRequest, "file upload error")
-		return
+		req.ExtractionID = &id
 	}
-
-	expense := &store.Expense{
-		Amount:     amountStr,
-		CategoryID: categoryID,
-		Type:       store.ExpenseType(typeStr),
-		Date:       date,
-		ProviderID: providerID,
-		ImagePath:  imagePath,
+	if v := r.FormValue("items"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Items); err != nil {
+			return errors.New("invalid items")
+		}
 	}
-
-	if err := h.expenseStore.CreateExpense(expense); err != nil {
-		h.logger.Error("creating expense", "error", err)
-		utils.Error(w, http.StatusInternalServerError, "internal server error")
-		return
+	if v := r.FormValue("vat"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.VAT); err != nil {
+			return errors.New("invalid vat")
+		}
 	}
+	return nil
+}
 
-	utils.OK(w, http.StatusCreated, utils.Envelope{"expense": expense}, "", nil)
+func isExpenseValidationError(err error) bool {
+	for _, target := range []error{
+		services.ErrMissingExpenseFields,
+		services.ErrInvalidExpenseDate,
+		services.ErrInvalidExpenseAmount,
+		services.ErrInvalidInvoiceType,
+		services.ErrInvoiceWithoutProvider,
+		services.ErrInvalidExpenseItem,
+		services.ErrIngredientNotFound,
+		services.ErrInvalidVATRate,
+		services.ErrDuplicateVATRate,
+		services.ErrInvalidVATAmount,
+		services.ErrInvalidDueDate,
+		services.ErrPayableWithoutProvider,
+		services.ErrExtractionNotFound,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
 }
 
 // HandleGetExpenses godoc
@@ -144,6 +225,8 @@ func (h *ExpenseHandler) HandleCreateExpense(w http.ResponseWriter, r *http.Requ
 // @Produce      json
 // @Param        type         query     string  false "Type (local/production)"
 // @Param        category_id  query     int     false "Category ID"
+// @Param        provider_id  query     int     false "Provider ID"
+// @Param        status       query     string  false "Payment status (paid/partial/pending)"
 // @Param        start_date   query     string  false "Start Date (YYYY-MM-DD)"
 // @Param        end_date     query     string  false "End Date (YYYY-MM-DD)"
 // @Param        limit        query     int     false "Limit"
@@ -175,6 +258,13 @@ func (h *ExpenseHandler) HandleGetExpenses(w http.ResponseWriter, r *http.Reques
 			filter.CategoryID = &cid
 		}
 	}
+	if pid, err := strconv.ParseInt(r.URL.Query().Get("provider_id"), 10, 64); err == nil {
+		filter.ProviderID = &pid
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		st := store.ExpensePaymentStatus(status)
+		filter.Status = &st
+	}
 	if startDateStr != "" {
 		if t, err := time.Parse("2006-01-02", startDateStr); err == nil {
 			filter.StartDate = &t
@@ -237,6 +327,7 @@ func (h *ExpenseHandler) HandleGetExpenseByID(w http.ResponseWriter, r *http.Req
 // @Success      204
 // @Failure      400  {object}  utils.HTTPError
 // @Failure      404  {object}  utils.HTTPError
+// @Failure      409  {object}  utils.HTTPError
 // @Failure      500  {object}  utils.HTTPError
 // @Security     BearerAuth
 // @Router       /api/v1/expenses/{id} [delete]
@@ -247,16 +338,162 @@ func (h *ExpenseHandler) HandleDeleteExpense(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	expense, err := h.expenseStore.GetExpenseByID(id)
+	if err != nil {
+		h.logger.Error("getting expense", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
 	err = h.expenseStore.DeleteExpense(id)
 	if err == sql.ErrNoRows {
 		utils.Error(w, http.StatusNotFound, "expense not found")
 		return
 	}
+	if errors.Is(err, store.ErrExpenseHasPayments) {
+		utils.Error(w, http.StatusConflict, "expense has provider payments applied")
+		return
+	}
 	if err != nil {
 		h.logger.Error("deleting expense", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Deleted(r, store.AuditExpense, id, expense)
 
 	w.WriteHeader(http.StatusNoContent)
-}
\ No newline at end of file
+}
+// HandleCreateExpenseExtraction godoc
+// @Summary      Reads a receipt
+// @Description  Stores the receipt image and reads it with OCR, proposing amount, date, provider CUIT (matched with a provider), invoice number and type. The extraction stays pending until an expense is created with its extraction_id or it is discarded. If the engine fails, the extraction is stored with the error and no proposals.
+// @Tags         expenses
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        image  formData  file  true  "Receipt Image"
+// @Success      201    {object}  store.ExpenseExtraction
+// @Failure      400    {object}  utils.HTTPError
+// @Failure      500    {object}  utils.HTTPError
+// @Failure      503    {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/expenses/extractions [post]
+func (h *ExpenseHandler) HandleCreateExpenseExtraction(w http.ResponseWriter, r *http.Request) {
+	if !h.extractionService.Available() {
+		utils.Error(w, http.StatusServiceUnavailable, services.ErrOCRUnavailable.Error())
+		return
+	}
+
+	r.ParseMultipartForm(10 << 20)
+	imagePath, ok := h.saveImage(w, r)
+	if !ok {
+		return
+	}
+	if imagePath == "" {
+		utils.Error(w, http.StatusBadRequest, "missing image")
+		return
+	}
+
+	var userID *int64
+	if user := middleware.GetUser(r); user != nil && !user.IsAnonymous() {
+		userID = &user.ID
+	}
+	extraction, err := h.extractionService.Extract(r.Context(), imagePath, userID)
+	if err != nil {
+		h.logger.Error("extracting expense receipt", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusCreated, utils.Envelope{"extraction": extraction}, "", nil)
+}
+
+// HandleListExpenseExtractions godoc
+// @Summary      Lists receipt extractions
+// @Description  Responds with OCR extractions for review, newest first
+// @Tags         expenses
+// @Produce      json
+// @Param        status  query     string  false  "Status (pending/confirmed/discarded)"
+// @Success      200     {object}  []store.ExpenseExtraction
+// @Failure      500     {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/expenses/extractions [get]
+func (h *ExpenseHandler) HandleListExpenseExtractions(w http.ResponseWriter, r *http.Request) {
+	var status *store.ExtractionStatus
+	if v := r.URL.Query().Get("status"); v != "" {
+		st := store.ExtractionStatus(v)
+		status = &st
+	}
+
+	extractions, err := h.extractionService.List(status)
+	if err != nil {
+		h.logger.Error("listing expense extractions", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"extractions": extractions}, "", nil)
+}
+
+// HandleGetExpenseExtraction godoc
+// @Summary      Gets a receipt extraction
+// @Description  Responds with the OCR text and proposed fields of a receipt
+// @Tags         expenses
+// @Produce      json
+// @Param        id   path      int  true  "Extraction ID"
+// @Success      200  {object}  store.ExpenseExtraction
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/expenses/extractions/{id} [get]
+func (h *ExpenseHandler) HandleGetExpenseExtraction(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	extraction, err := h.extractionService.Get(id)
+	if errors.Is(err, services.ErrExtractionNotFound) {
+		utils.Error(w, http.StatusNotFound, "extraction not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting expense extraction", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"extraction": extraction}, "", nil)
+}
+
+// HandleDiscardExpenseExtraction godoc
+// @Summary      Discards a receipt extraction
+// @Description  Marks a pending extraction as reviewed without loading an expense
+// @Tags         expenses
+// @Param        id   path      int  true  "Extraction ID"
+// @Success      204
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/expenses/extractions/{id}/discard [post]
+func (h *ExpenseHandler) HandleDiscardExpenseExtraction(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	err = h.extractionService.Discard(id)
+	if errors.Is(err, services.ErrExtractionNotFound) {
+		utils.Error(w, http.StatusNotFound, "pending extraction not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("discarding expense extraction", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -7,22 +7,27 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
 
 type ingredientRequest struct {
 	Name string `json:"name"`
+	// Cost is per kg, liter or unit. Left unchanged on update when omitted.
+	Cost *float64 `json:"cost" example:"1500"`
 }
 
 type IngredientHandler struct {
 	ingredientStore store.IngredientStore
+	audit           *audit.Recorder
 	logger          *slog.Logger
 }
 
-func NewIngredientHandler(ingredientStore store.IngredientStore, logger *slog.Logger) *IngredientHandler {
+func NewIngredientHandler(ingredientStore store.IngredientStore, audit *audit.Recorder, logger *slog.Logger) *IngredientHandler {
 	return &IngredientHandler{
 		ingredientStore: ingredientStore,
+		audit:           audit,
 		logger:          logger,
 	}
 }
@@ -31,12 +36,15 @@ func (h *IngredientHandler) validateRequest(req *ingredientRequest) error {
 	if req.Name == "" {
 		return fmt.Errorf("name is required")
 	}
+	if req.Cost != nil && *req.Cost < 0 {
+		return fmt.Errorf("cost must be 0 or greater")
+	}
 	return nil
 }
 
 // HandleCreateIngredient godoc
 // @Summary      Creates an ingredient
-// @Description  Creates a new ingredient with a name
+// @Description  Creates a new ingredient with a name and an optional cost per kg, liter or unit
 // @Tags         ingredients
 // @Accept       json
 // @Produce      json
@@ -62,6 +70,9 @@ func (h *IngredientHandler) HandleCreateIngredient(w http.ResponseWriter, r *htt
 	ingredient := &store.Ingredient{
 		Name: req.Name,
 	}
+	if req.Cost != nil {
+		ingredient.Cost = *req.Cost
+	}
 
 	if err := h.ingredientStore.CreateIngredient(ingredient); err != nil {
 		h.logger.Error("creating ingredient", "error", err)
@@ -69,12 +80,13 @@ func (h *IngredientHandler) HandleCreateIngredient(w http.ResponseWriter, r *htt
 		return
 	}
 
+	h.audit.Created(r, store.AuditIngredient, ingredient.ID, ingredient)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"ingredient": ingredient}, "", nil)
 }
 
 // HandleUpdateIngredient godoc
 // @Summary      Updates an ingredient
-// @Description  Updates an ingredient's name
+// @Description  Updates an ingredient's name and cost
 // @Tags         ingredients
 // @Accept       json
 // @Produce      json
@@ -117,12 +129,17 @@ func (h *IngredientHandler) HandleUpdateIngredient(w http.ResponseWriter, r *htt
 		return
 	}
 
+	before := *ingredient
 	ingredient.Name = req.Name
+	if req.Cost != nil {
+		ingredient.Cost = *req.Cost
+	}
 	if err := h.ingredientStore.UpdateIngredient(ingredient); err != nil {
 		h.logger.Error("updating ingredient", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Updated(r, store.AuditIngredient, ingredient.ID, before, ingredient)
 
 	utils.OK(w, http.StatusOK, utils.Envelope{"ingredient": ingredient}, "", nil)
 }
@@ -200,6 +217,13 @@ func (h *IngredientHandler) HandleDeleteIngredient(w http.ResponseWriter, r *htt
 		return
 	}
 
+	ingredient, err := h.ingredientStore.GetIngredientByID(id)
+	if err != nil {
+		h.logger.Error("getting ingredient by id", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
 	err = h.ingredientStore.DeleteIngredient(id)
 	if err == sql.ErrNoRows {
 		utils.Error(w, http.StatusNotFound, "ingredient not found")
@@ -210,6 +234,7 @@ func (h *IngredientHandler) HandleDeleteIngredient(w http.ResponseWriter, r *htt
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Deleted(r, store.AuditIngredient, id, ingredient)
 
 	w.WriteHeader(http.StatusNoContent)
 }
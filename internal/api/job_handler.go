@@ -0,0 +1,113 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type JobHandler struct {
+	jobStore store.JobStore
+	audit    *audit.Recorder
+	logger   *slog.Logger
+}
+
+func NewJobHandler(s store.JobStore, a *audit.Recorder, l *slog.Logger) *JobHandler {
+	return &JobHandler{jobStore: s, audit: a, logger: l}
+}
+
+// HandleListJobs godoc
+// @Summary      List background jobs
+// @Description  Responds with the background jobs (invoices, emails, webhooks, scheduled tasks), newest first, and how many there are in each status. Done jobs are kept for a week.
+// @Tags         jobs
+// @Produce      json
+// @Param        kind    query     string  false  "Kind (invoice.generate, email.send, webhooks.deliver, jobs.cleanup)"
+// @Param        status  query     string  false  "Status (pending, running, done, dead)"
+// @Param        limit   query     int     false  "Limit (default 50)"
+// @Param        offset  query     int     false  "Offset"
+// @Success      200     {object}  JobsResponse
+// @Failure      500     {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/jobs [get]
+func (h *JobHandler) HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.JobFilter{Kind: q.Get("kind"), Status: q.Get("status")}
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+	filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	jobs, total, err := h.jobStore.List(filter)
+	if err != nil {
+		h.logger.Error("listing jobs", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if jobs == nil {
+		jobs = []*store.Job{}
+	}
+	counts, err := h.jobStore.CountByStatus()
+	if err != nil {
+		h.logger.Error("counting jobs", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"jobs": jobs, "counts": counts}, "", &utils.Meta{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Total:  total,
+	})
+}
+
+// HandleRetryJob godoc
+// @Summary      Retry a dead job
+// @Description  Queues a job that ran out of attempts again, with its attempts reset. 409 when the same scheduled job is already queued.
+// @Tags         jobs
+// @Produce      json
+// @Param        id   path      int  true  "Job ID"
+// @Success      200  {object}  JobResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      409  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/jobs/{id}/retry [post]
+func (h *JobHandler) HandleRetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid job id")
+		return
+	}
+
+	if err := h.jobStore.Retry(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.Error(w, http.StatusNotFound, "dead job not found")
+			return
+		}
+		if errors.Is(err, store.ErrJobAlreadyQueued) {
+			utils.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		h.logger.Error("retrying job", "id", id, "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	h.audit.Record(r, store.AuditJob, id, "retry", nil, nil)
+
+	job, err := h.jobStore.GetByID(id)
+	if err != nil {
+		h.logger.Error("getting job", "id", id, "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"job": job}, "", nil)
+}
@@ -3,27 +3,36 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/receipt"
 	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	chi "github.com/go-chi/chi/v5"
 )
 
 type CreateLocalSaleRequest struct {
 	PaymentMethodID int64                          `json:"payment_method_id"`
+	RegisterID      int64                          `json:"register_id,omitempty"`
 	Items           []services.CreateLocalSaleItem `json:"items"`
 }
 
 type LocalSaleHandler struct {
-	service *services.LocalSaleService
-	logger  *slog.Logger
+	service        *services.LocalSaleService
+	receiptService *services.ReceiptService
+	audit          *audit.Recorder
+	logger         *slog.Logger
 }
 
-func NewLocalSaleHandler(s *services.LocalSaleService, l *slog.Logger) *LocalSaleHandler {
-	return &LocalSaleHandler{service: s, logger: l}
+func NewLocalSaleHandler(s *services.LocalSaleService, rs *services.ReceiptService, a *audit.Recorder, l *slog.Logger) *LocalSaleHandler {
+	return &LocalSaleHandler{service: s, receiptService: rs, audit: a, logger: l}
 }
 
 // HandleCreateLocalSale godoc
@@ -36,6 +45,7 @@ func NewLocalSaleHandler(s *services.LocalSaleService, l *slog.Logger) *LocalSal
 // @Success      201   {object}  LocalSaleResponse
 // @Failure      400   {object}  utils.HTTPError "Invalid input, insufficient stock, etc."
 // @Failure      404   {object}  utils.HTTPError "Resource not found (e.g., product, payment method)"
+// @Failure      409   {object}  utils.HTTPError "The given cash register has no open session"
 // @Failure      500   {object}  utils.HTTPError
 // @Security     BearerAuth
 // @Router       /api/v1/local_sales [post]
@@ -46,13 +56,19 @@ func (h *LocalSaleHandler) HandleCreateLocalSale(w http.ResponseWriter, r *http.
 		return
 	}
 
+	req.UserID = middleware.GetUser(r).ID
+
 	sale, err := h.service.CreateLocalSale(req)
 	if err != nil {
 		switch {
-		case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrPaymentMethodNotFound):
+		case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrPaymentMethodNotFound),
+			errors.Is(err, services.ErrBarcodeUnknown):
 			utils.Error(w, http.StatusNotFound, err.Error())
-		case errors.Is(err, services.ErrInsufficientStock):
+		case errors.Is(err, services.ErrInsufficientStock), errors.Is(err, services.ErrFractionalQuantity),
+			errors.Is(err, services.ErrBarcodeEmpty), errors.Is(err, services.ErrBarcodeInvalid):
 			utils.Error(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrNoOpenShift):
+			utils.Error(w, http.StatusConflict, err.Error())
 		case err.Error() == "sale must have at least one item":
 			utils.Error(w, http.StatusBadRequest, err.Error())
 		default:
@@ -62,9 +78,39 @@ func (h *LocalSaleHandler) HandleCreateLocalSale(w http.ResponseWriter, r *http.
 		return
 	}
 
+	h.audit.Created(r, store.AuditLocalSale, sale.ID, sale)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"local_sale": sale}, "", nil)
 }
 
+// HandleLookupCode godoc
+// @Summary      Look up a scanned code
+// @Description  Resolves a barcode or PLU to a product. Weighed-item EAN-13 labels (prefix 20-29) also return the price of the line, embedded in the label or computed from the embedded weight.
+// @Tags         local_sales
+// @Produce      json
+// @Param        code  query     string  true  "Scanned barcode or PLU"
+// @Success      200   {object}  ScanResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/local_sales/lookup [get]
+func (h *LocalSaleHandler) HandleLookupCode(w http.ResponseWriter, r *http.Request) {
+	scan, err := h.service.LookupCode(r.URL.Query().Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBarcodeUnknown):
+			utils.Error(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrBarcodeEmpty), errors.Is(err, services.ErrBarcodeInvalid):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			h.logger.Error("looking up code", "error", err)
+			utils.Error(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+	utils.OK(w, http.StatusOK, utils.Envelope{"scan": scan}, "", nil)
+}
+
 // HandleGetLocalSale godoc
 // @Summary      Get a single local sale
 // @Description  Retrieves the details of a single local sale by its ID.
@@ -113,3 +159,92 @@ func (h *LocalSaleHandler) HandleListLocalSales(w http.ResponseWriter, r *http.R
 	}
 	utils.OK(w, http.StatusOK, utils.Envelope{"local_sales": sales}, "", nil)
 }
+
+// HandleGetReceipt godoc
+// @Summary      Get the ticket of a local sale
+// @Description  Renders the customer ticket of a sale. format=escpos returns the raw ESC/POS byte stream for a thermal printer; the default, format=html, returns a printable page.
+// @Tags         local_sales
+// @Produce      html
+// @Produce      octet-stream
+// @Param        id      path      int     true   "Sale ID"
+// @Param        format  query     string  false  "html (default) or escpos"
+// @Success      200     {file}    file
+// @Failure      400     {object}  utils.HTTPError
+// @Failure      404     {object}  utils.HTTPError
+// @Failure      500     {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/local_sales/{id}/receipt [get]
+func (h *LocalSaleHandler) HandleGetReceipt(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid sale ID")
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "html":
+		rc, err := h.receiptService.BuildReceipt(id)
+		if err != nil {
+			h.receiptError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := receipt.HTML(w, rc); err != nil {
+			h.logger.Error("rendering receipt", "error", err)
+		}
+	case "escpos":
+		data, err := h.receiptService.ESCPOS(id)
+		if err != nil {
+			h.receiptError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=ticket-%d.bin", id))
+		w.Write(data)
+	default:
+		utils.Error(w, http.StatusBadRequest, "format must be html or escpos")
+	}
+}
+
+// HandlePrintReceipt godoc
+// @Summary      Print the ticket of a local sale
+// @Description  Sends the ESC/POS ticket of a sale to the configured network thermal printer.
+// @Tags         local_sales
+// @Produce      json
+// @Param        id   path      int  true  "Sale ID"
+// @Success      204
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      502  {object}  utils.HTTPError "The printer could not be reached"
+// @Failure      503  {object}  utils.HTTPError "No printer is configured"
+// @Security     BearerAuth
+// @Router       /api/v1/local_sales/{id}/receipt/print [post]
+func (h *LocalSaleHandler) HandlePrintReceipt(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid sale ID")
+		return
+	}
+
+	if err := h.receiptService.PrintReceipt(r.Context(), id); err != nil {
+		h.receiptError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *LocalSaleHandler) receiptError(w http.ResponseWriter, err error) {
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, services.ErrSaleNotFound):
+		utils.Error(w, http.StatusNotFound, "sale not found")
+	case errors.Is(err, services.ErrPrinterNotConfigured):
+		utils.Error(w, http.StatusServiceUnavailable, err.Error())
+	case errors.As(err, &opErr):
+		h.logger.Error("printing receipt", "error", err)
+		utils.Error(w, http.StatusBadGateway, "could not reach the printer")
+	default:
+		h.logger.Error("building receipt", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
 	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	chi "github.com/go-chi/chi/v5"
 )
@@ -15,34 +19,53 @@ import (
 // --- DTOs for Requests ---
 
 type CreateInitialStockRequest struct {
-	ProductID       int64 `json:"product_id"`
-	InitialQuantity int   `json:"initial_quantity"`
+	ProductID       int64   `json:"product_id"`
+	InitialQuantity float64 `json:"initial_quantity"`
 }
 
 type AdjustStockRequest struct {
-	Delta int `json:"delta"`
+	Delta float64 `json:"delta"`
+	// LocationID is the stock location to adjust; the default location
+	// when omitted.
+	LocationID int64 `json:"location_id,omitempty"`
+	// Reason is "adjustment" (default) or "production".
+	Reason string `json:"reason,omitempty" example:"production"`
+	Note   string `json:"note,omitempty"`
+	// Lot opens a lot with the produced quantity. Only with reason
+	// "production".
+	Lot *LotRequest `json:"lot,omitempty"`
+}
+
+type LotRequest struct {
+	// Number is generated from the production date when empty.
+	Number     string `json:"number,omitempty" example:"L250301-1"`
+	ProducedOn string `json:"produced_on,omitempty" example:"2025-03-01"`
+	BestBefore string `json:"best_before,omitempty" example:"2025-03-04"`
 }
 
 // --- Handler ---
 
 type LocalStockHandler struct {
 	service *services.LocalStockService
+	audit   *audit.Recorder
 	logger  *slog.Logger
 }
 
-func NewLocalStockHandler(s *services.LocalStockService, l *slog.Logger) *LocalStockHandler {
-	return &LocalStockHandler{service: s, logger: l}
+func NewLocalStockHandler(s *services.LocalStockService, a *audit.Recorder, l *slog.Logger) *LocalStockHandler {
+	return &LocalStockHandler{service: s, audit: a, logger: l}
 }
 
 // --- Endpoints ---
 
 // HandleGetLocalStock godoc
 // @Summary      Get stock for a single product
-// @Description  Responds with the local stock quantity for a given product ID
+// @Description  Responds with the stock quantity of a product at a location (the default location unless location_id is given)
 // @Tags         local_stock
 // @Produce      json
 // @Param        product_id   path      int      true  "Product ID"
+// @Param        location_id  query     int      false "Stock location ID"
 // @Success      200  {object}  LocalStockResponse
+// @Failure      400  {object}  utils.HTTPError
 // @Failure      404  {object}  utils.HTTPError "Stock record not found"
 // @Failure      500  {object}  utils.HTTPError
 // @Security     BearerAuth
@@ -54,7 +77,13 @@ func (h *LocalStockHandler) HandleGetLocalStock(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	stock, err := h.service.GetStock(productID)
+	locationID, err := parseLocationID(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stock, err := h.service.GetStock(productID, locationID)
 	if err != nil {
 		h.logger.Error("getting local stock", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
@@ -71,16 +100,29 @@ func (h *LocalStockHandler) HandleGetLocalStock(w http.ResponseWriter, r *http.R
 
 // HandleListLocalStock godoc
 // @Summary      List all local stock
-// @Description  Responds with a list of all local stock records
+// @Description  Responds with the stock of every product at a location (the default location unless location_id is given)
 // @Tags         local_stock
 // @Produce      json
+// @Param        location_id  query     int  false  "Stock location ID"
 // @Success      200  {object}  LocalStocksResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError "Location not found"
 // @Failure      500  {object}  utils.HTTPError
 // @Security     BearerAuth
 // @Router       /api/v1/local_stock [get]
 func (h *LocalStockHandler) HandleListLocalStock(w http.ResponseWriter, r *http.Request) {
-	stocks, err := h.service.ListStock()
+	locationID, err := parseLocationID(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stocks, err := h.service.ListStock(locationID)
 	if err != nil {
+		if errors.Is(err, services.ErrLocationNotFound) {
+			utils.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
 		h.logger.Error("listing local stock", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
@@ -91,7 +133,7 @@ func (h *LocalStockHandler) HandleListLocalStock(w http.ResponseWriter, r *http.
 
 // HandleCreateInitialStock godoc
 // @Summary      Create initial stock for a product
-// @Description  Creates the first stock record for a product. Fails if a record already exists.
+// @Description  Creates the first stock record for a product at the default location. Fails if a record already exists.
 // @Tags         local_stock
 // @Accept       json
 // @Produce      json
@@ -110,14 +152,14 @@ func (h *LocalStockHandler) HandleCreateInitialStock(w http.ResponseWriter, r *h
 		return
 	}
 
-	stock, err := h.service.CreateInitialStock(req.ProductID, req.InitialQuantity)
+	stock, err := h.service.CreateInitialStock(req.ProductID, req.InitialQuantity, middleware.GetUser(r).ID)
 	if err != nil {
 		switch {
 		case errors.Is(err, services.ErrProductNotFound):
 			utils.Error(w, http.StatusNotFound, err.Error())
 		case errors.Is(err, services.ErrStockRecordExists):
 			utils.Error(w, http.StatusConflict, err.Error())
-		case errors.Is(err, services.ErrInitialQuantityInvalid):
+		case errors.Is(err, services.ErrInitialQuantityInvalid), errors.Is(err, services.ErrFractionalQuantity):
 			utils.Error(w, http.StatusBadRequest, err.Error())
 		default:
 			h.logger.Error("creating initial stock", "error", err)
@@ -126,12 +168,13 @@ func (h *LocalStockHandler) HandleCreateInitialStock(w http.ResponseWriter, r *h
 		return
 	}
 
+	h.audit.Created(r, store.AuditLocalStock, stock.ProductID, stock)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"local_stock": stock}, "", nil)
 }
 
 // HandleAdjustStock godoc
 // @Summary      Adjust stock for a product
-// @Description  Adjusts a product's stock quantity by a delta (can be positive or negative).
+// @Description  Adjusts a product's stock quantity at a location (default location unless location_id is given) by a delta (can be positive or negative). The change is recorded in the stock movement ledger as a manual adjustment or as production. Production can open a lot with production and best-before dates; stock taken out consumes lots first to expire first. Stock at a location other than the default one starts with its first entry.
 // @Tags         local_stock
 // @Accept       json
 // @Produce      json
@@ -139,6 +182,8 @@ func (h *LocalStockHandler) HandleCreateInitialStock(w http.ResponseWriter, r *h
 // @Param        body         body      AdjustStockRequest  true  "Adjustment data"
 // @Success      200          {object}  LocalStockResponse
 // @Failure      400          {object}  utils.HTTPError "Invalid input or insufficient stock"
+// @Failure      404          {object}  utils.HTTPError "Product, location or stock record not found"
+// @Failure      409          {object}  utils.HTTPError "Lot number already used for the product"
 // @Failure      500          {object}  utils.HTTPError
 // @Security     BearerAuth
 // @Router       /api/v1/local_stock/{product_id}/adjust [patch]
@@ -155,12 +200,31 @@ func (h *LocalStockHandler) HandleAdjustStock(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	stock, err := h.service.AdjustStock(productID, req.Delta)
+	change := store.StockChange{
+		LocationID: req.LocationID,
+		Reason:     req.Reason,
+		UserID:     middleware.GetUser(r).ID,
+		Note:       req.Note,
+	}
+	if req.Lot != nil {
+		change.Lot, err = parseLot(req.Lot.Number, req.Lot.ProducedOn, req.Lot.BestBefore)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	stock, err := h.service.AdjustStock(productID, req.Delta, change)
 	if err != nil {
 		switch {
-		case errors.Is(err, services.ErrInsufficientStock):
+		case errors.Is(err, services.ErrInsufficientStock), errors.Is(err, services.ErrFractionalQuantity),
+			errors.Is(err, services.ErrInvalidMovementReason), errors.Is(err, services.ErrLotNotAllowed),
+			errors.Is(err, services.ErrInvalidLotDates):
 			utils.Error(w, http.StatusBadRequest, err.Error())
-		case errors.Is(err, services.ErrStockRecordNotFound):
+		case errors.Is(err, store.ErrLotNumberTaken):
+			utils.Error(w, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrProductNotFound), errors.Is(err, services.ErrStockRecordNotFound),
+			errors.Is(err, services.ErrLocationNotFound):
 			// This case may not be hit if the service auto-creates the record.
 			utils.Error(w, http.StatusNotFound, err.Error())
 		default:
@@ -169,6 +233,301 @@ func (h *LocalStockHandler) HandleAdjustStock(w http.ResponseWriter, r *http.Req
 		}
 		return
 	}
+	h.audit.Updated(r, store.AuditLocalStock, productID,
+		map[string]any{"quantity": stock.Quantity - req.Delta},
+		map[string]any{"quantity": stock.Quantity, "location_id": stock.LocationID, "reason": change.Reason, "note": req.Note})
 
 	utils.OK(w, http.StatusOK, utils.Envelope{"local_stock": stock}, "", nil)
 }
+
+// HandleListMovements godoc
+// @Summary      List stock movements of a product
+// @Description  Responds with every change to a product's stock at a location (sales, voids, adjustments, production, waste, transfers) in a date range, oldest first. Each movement carries the balance at the location after it; opening_balance is the stock there at the start of the range.
+// @Tags         local_stock
+// @Produce      json
+// @Param        product_id  path      int     true   "Product ID"
+// @Param        location_id query     int     false  "Stock location ID (default location when omitted)"
+// @Param        from        query     string  false  "First day, YYYY-MM-DD (default: 30 days ago)"
+// @Param        to          query     string  false  "Last day, YYYY-MM-DD (default: today)"
+// @Success      200         {object}  StockMovementsResponse
+// @Failure      400         {object}  utils.HTTPError
+// @Failure      404         {object}  utils.HTTPError
+// @Failure      500         {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/local_stock/{product_id}/movements [get]
+func (h *LocalStockHandler) HandleListMovements(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+
+	from, to, err := parseMovementRange(r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	locationID, err := parseLocationID(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	movements, opening, err := h.service.ListMovements(productID, locationID, from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) || errors.Is(err, services.ErrLocationNotFound) {
+			utils.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logger.Error("listing stock movements", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if movements == nil {
+		movements = []*store.StockMovement{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"opening_balance": opening, "movements": movements}, "", nil)
+}
+
+// HandleGetStockAt godoc
+// @Summary      Get a product's stock at a past date
+// @Description  Reconstructs a product's stock at a location from the movement ledger. A plain date (YYYY-MM-DD) means the end of that day; an RFC 3339 timestamp is used as is.
+// @Tags         local_stock
+// @Produce      json
+// @Param        product_id  path      int     true  "Product ID"
+// @Param        location_id query     int     false "Stock location ID (default location when omitted)"
+// @Param        at          query     string  true  "Date (YYYY-MM-DD) or timestamp (RFC 3339)"
+// @Success      200         {object}  StockAtResponse
+// @Failure      400         {object}  utils.HTTPError
+// @Failure      404         {object}  utils.HTTPError
+// @Failure      500         {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/local_stock/{product_id}/at [get]
+func (h *LocalStockHandler) HandleGetStockAt(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+
+	at, err := parseStockInstant(r.URL.Query().Get("at"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "at must be a date (YYYY-MM-DD) or an RFC 3339 timestamp")
+		return
+	}
+
+	locationID, err := parseLocationID(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	quantity, err := h.service.StockAt(productID, locationID, at)
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) || errors.Is(err, services.ErrLocationNotFound) {
+			utils.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logger.Error("reconstructing stock", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"product_id": productID, "at": at, "quantity": quantity}, "", nil)
+}
+
+// HandleStockByLocation godoc
+// @Summary      Get a product's stock at every location
+// @Description  Responds with the stock records a product has, one per location.
+// @Tags         local_stock
+// @Produce      json
+// @Param        product_id  path      int  true  "Product ID"
+// @Success      200         {object}  LocalStocksResponse
+// @Failure      400         {object}  utils.HTTPError
+// @Failure      500         {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/local_stock/{product_id}/locations [get]
+func (h *LocalStockHandler) HandleStockByLocation(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+
+	stocks, err := h.service.StockByLocation(productID)
+	if err != nil {
+		h.logger.Error("listing stock by location", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if stocks == nil {
+		stocks = []*store.LocalStock{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"local_stock": stocks}, "", nil)
+}
+
+// HandleListLots godoc
+// @Summary      List the lots of a product
+// @Description  Responds with a product's lots in the order sales consume them (first to expire first). Lots already used up are only listed with all=true.
+// @Tags         local_stock
+// @Produce      json
+// @Param        product_id  path      int   true   "Product ID"
+// @Param        all         query     bool  false  "Include used up lots"
+// @Success      200         {object}  LotsResponse
+// @Failure      400         {object}  utils.HTTPError
+// @Failure      404         {object}  utils.HTTPError
+// @Failure      500         {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/local_stock/{product_id}/lots [get]
+func (h *LocalStockHandler) HandleListLots(w http.ResponseWriter, r *http.Request) {
+	productID, err := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid product ID")
+		return
+	}
+
+	includeEmpty, _ := strconv.ParseBool(r.URL.Query().Get("all"))
+	lots, err := h.service.ListLots(productID, includeEmpty)
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) {
+			utils.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logger.Error("listing lots", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if lots == nil {
+		lots = []*store.StockLot{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"lots": lots}, "", nil)
+}
+
+// HandleListExpiringLots godoc
+// @Summary      List lots about to expire
+// @Description  Responds with the lots still in stock whose best-before date is within the given number of days (default 1: today and tomorrow), including lots already expired.
+// @Tags         local_stock
+// @Produce      json
+// @Param        days  query     int  false  "Days ahead (default 1)"
+// @Success      200   {object}  LotsResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/local_stock/lots/expiring [get]
+func (h *LocalStockHandler) HandleListExpiringLots(w http.ResponseWriter, r *http.Request) {
+	days := 1
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			utils.Error(w, http.StatusBadRequest, "days must be a non-negative integer")
+			return
+		}
+		days = n
+	}
+
+	lots, err := h.service.ExpiringLots(days)
+	if err != nil {
+		h.logger.Error("listing expiring lots", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if lots == nil {
+		lots = []*store.StockLot{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"lots": lots}, "", nil)
+}
+
+// HandleGetLot godoc
+// @Summary      Get a lot with its movements
+// @Description  Responds with a lot and every stock movement that touched it (production, sales, voids, waste), to trace where its goods went.
+// @Tags         local_stock
+// @Produce      json
+// @Param        id   path      int  true  "Lot ID"
+// @Success      200  {object}  LotResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/local_stock/lots/{id} [get]
+func (h *LocalStockHandler) HandleGetLot(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid lot ID")
+		return
+	}
+
+	lot, movements, err := h.service.GetLot(id)
+	if err != nil {
+		if errors.Is(err, services.ErrLotNotFound) {
+			utils.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logger.Error("getting lot", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if movements == nil {
+		movements = []*store.LotMovement{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"lot": lot, "movements": movements}, "", nil)
+}
+
+// parseLocationID reads the optional location_id query parameter; zero means
+// the default location.
+func parseLocationID(r *http.Request) (int64, error) {
+	v := r.URL.Query().Get("location_id")
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("location_id must be a positive integer")
+	}
+	return id, nil
+}
+
+// parseMovementRange reads a from/to pair of days into a half-open range
+// covering both days. It defaults to the last 30 days.
+func parseMovementRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	to := today
+	if toStr != "" {
+		d, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a date (YYYY-MM-DD)")
+		}
+		to = d
+	}
+	from := to.AddDate(0, 0, -30)
+	if fromStr != "" {
+		d, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be a date (YYYY-MM-DD)")
+		}
+		from = d
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// parseStockInstant reads a timestamp, or a day meaning its end.
+func parseStockInstant(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return d.AddDate(0, 0, 1), nil
+}
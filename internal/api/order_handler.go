@@ -9,7 +9,7 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/RamunnoAJ/aesovoy-server/internal/billing"
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
@@ -26,14 +26,13 @@ type RegisterOrderRequest struct {
 }
 
 type OrderHandler struct {
-	orders   store.OrderStore
-	clients  store.ClientStore
-	products store.ProductStore
-	logger   *slog.Logger
+	orders store.OrderStore
+	audit  *audit.Recorder
+	logger *slog.Logger
 }
 
-func NewOrderHandler(os store.OrderStore, cs store.ClientStore, ps store.ProductStore, l *slog.Logger) *OrderHandler {
-	return &OrderHandler{orders: os, clients: cs, products: ps, logger: l}
+func NewOrderHandler(os store.OrderStore, a *audit.Recorder, l *slog.Logger) *OrderHandler {
+	return &OrderHandler{orders: os, audit: a, logger: l}
 }
 
 func (h *OrderHandler) validateCreate(req *RegisterOrderRequest) []utils.FieldError {
@@ -87,44 +86,19 @@ func (h *OrderHandler) HandleRegisterOrder(w http.ResponseWriter, r *http.Reques
 		State:    ternState(req.State, store.OrderTodo),
 	}
 	items := make([]store.OrderItem, len(req.Items))
-	productIDs := make([]int64, len(req.Items))
 	for i, it := range req.Items {
 		items[i] = store.OrderItem{
 			ProductID: it.ProductID,
 			Quantity:  it.Quantity,
 			Price:     it.Price,
 		}
-		productIDs[i] = it.ProductID
 	}
 	if err := h.orders.CreateOrder(o, items); err != nil {
 		h.logger.Error("create order", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
-
-	// Invoice generation
-	go func() {
-		client, err := h.clients.GetClientByID(o.ClientID)
-		if err != nil {
-			h.logger.Error("generating invoice: could not get client", "clientID", o.ClientID, "error", err)
-			return
-		}
-		if client == nil {
-			h.logger.Error("generating invoice: client not found", "clientID", o.ClientID)
-			return
-		}
-
-		products, err := h.products.GetProductsByIDs(productIDs)
-		if err != nil {
-			h.logger.Error("generating invoice: could not get products", "error", err)
-			return
-		}
-
-		// The order object 'o' from CreateOrder has the final items list.
-		if err := billing.GenerateInvoice(o, client, products); err != nil {
-			h.logger.Error("generating invoice for order", "orderID", o.ID, "error", err)
-		}
-	}()
+	h.audit.Created(r, store.AuditOrder, o.ID, o)
 
 	utils.OK(w, http.StatusCreated, utils.Envelope{"order": o}, "", nil)
 }
@@ -160,6 +134,16 @@ func (h *OrderHandler) HandleUpdateOrderState(w http.ResponseWriter, r *http.Req
 		utils.Fail(w, http.StatusBadRequest, "validation failed", []utils.FieldError{{Field: "state", Message: "required"}})
 		return
 	}
+	o, err := h.orders.GetOrderByID(id)
+	if err != nil {
+		h.logger.Error("get order", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if o == nil {
+		utils.Error(w, http.StatusNotFound, "order not found")
+		return
+	}
 	if err := h.orders.UpdateOrderState(id, req.State, nil); err != nil {
 		if err == sql.ErrNoRows {
 			utils.Error(w, http.StatusNotFound, "order not found")
@@ -169,6 +153,8 @@ func (h *OrderHandler) HandleUpdateOrderState(w http.ResponseWriter, r *http.Req
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Record(r, store.AuditOrder, id, store.AuditState,
+		map[string]any{"state": o.State}, map[string]any{"state": req.State})
 	utils.OK(w, http.StatusOK, utils.Envelope{"id": id, "state": req.State}, "", nil)
 }
 
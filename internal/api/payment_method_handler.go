@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
@@ -17,11 +18,12 @@ type registerPaymentMethodRequest struct {
 
 type PaymentMethodHandler struct {
 	store  store.PaymentMethodStore
+	audit  *audit.Recorder
 	logger *slog.Logger
 }
 
-func NewPaymentMethodHandler(s store.PaymentMethodStore, l *slog.Logger) *PaymentMethodHandler {
-	return &PaymentMethodHandler{store: s, logger: l}
+func NewPaymentMethodHandler(s store.PaymentMethodStore, a *audit.Recorder, l *slog.Logger) *PaymentMethodHandler {
+	return &PaymentMethodHandler{store: s, audit: a, logger: l}
 }
 
 func (h *PaymentMethodHandler) validateRequest(req *registerPaymentMethodRequest) error {
@@ -74,6 +76,7 @@ func (h *PaymentMethodHandler) HandleCreatePaymentMethod(w http.ResponseWriter,
 		return
 	}
 
+	h.audit.Created(r, store.AuditPaymentMethod, pm.ID, pm)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"payment_method": pm}, "", nil)
 }
 
@@ -148,6 +151,13 @@ func (h *PaymentMethodHandler) HandleDeletePaymentMethod(w http.ResponseWriter,
 		return
 	}
 
+	pm, err := h.store.GetPaymentMethodByID(id)
+	if err != nil {
+		h.logger.Error("getting payment method", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
 	if err := h.store.DeletePaymentMethod(id); err != nil {
 		if err.Error() == "sql: no rows in result set" {
 			utils.Error(w, http.StatusNotFound, "payment method not found")
@@ -161,6 +171,7 @@ func (h *PaymentMethodHandler) HandleDeletePaymentMethod(w http.ResponseWriter,
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Deleted(r, store.AuditPaymentMethod, id, pm)
 
 	w.WriteHeader(http.StatusNoContent)
 }
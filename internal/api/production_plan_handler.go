@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type ProductionPlanHandler struct {
+	service *services.ProductionPlanService
+	logger  *slog.Logger
+}
+
+func NewProductionPlanHandler(s *services.ProductionPlanService, l *slog.Logger) *ProductionPlanHandler {
+	return &ProductionPlanHandler{service: s, logger: l}
+}
+
+// HandleGetSuggestions godoc
+// @Summary      Suggest production quantities
+// @Description  Proposes how much of each product to make for a day. Stock at the default location is projected by taking off pending "todo" orders and the average sales of the same weekday over the previous weeks; products whose projection falls below their minimum are topped up to their target. Only products with something to make are listed.
+// @Tags         production
+// @Produce      json
+// @Param        date   query     string  false  "Day to plan, YYYY-MM-DD (default: tomorrow)"
+// @Param        weeks  query     int     false  "Past weeks to average sales over, 1 to 12 (default: 4)"
+// @Success      200    {object}  ProductionPlanResponse
+// @Failure      400    {object}  utils.HTTPError
+// @Failure      500    {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/production/suggestions [get]
+func (h *ProductionPlanHandler) HandleGetSuggestions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	day, weeks, err := parsePlanParams(q.Get("date"), q.Get("weeks"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	plan, err := h.service.Suggest(day, weeks)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPlanWeeks) {
+			utils.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.logger.Error("suggesting production", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"production_plan": plan}, "", nil)
+}
+
+// parsePlanParams reads the day to plan, tomorrow by default, and how many
+// weeks of sales to average.
+func parsePlanParams(dateStr, weeksStr string) (time.Time, int, error) {
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if dateStr != "" {
+		d, err := time.ParseInLocation("2006-01-02", dateStr, now.Location())
+		if err != nil {
+			return time.Time{}, 0, errors.New("date must be a date (YYYY-MM-DD)")
+		}
+		day = d
+	}
+
+	weeks := services.DefaultPlanWeeks
+	if weeksStr != "" {
+		n, err := strconv.Atoi(weeksStr)
+		if err != nil {
+			return time.Time{}, 0, errors.New("weeks must be an integer")
+		}
+		weeks = n
+	}
+	return day, weeks, nil
+}
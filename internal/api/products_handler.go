@@ -3,32 +3,43 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	chi "github.com/go-chi/chi/v5"
 )
 
 type registerProductRequest struct {
-	CategoryID        int64   `json:"category_id"`
-	Name              string  `json:"name"`
-	Description       string  `json:"description"`
-	UnitPrice         float64 `json:"unit_price"`
-	DistributionPrice float64 `json:"distribution_price"`
+	CategoryID         int64    `json:"category_id"`
+	Name               string   `json:"name"`
+	Description        string   `json:"description"`
+	UnitPrice          float64  `json:"unit_price"`
+	DistributionPrice  float64  `json:"distribution_price"`
+	SaleUnit           string   `json:"sale_unit" example:"kg"`
+	AllowNegativeStock bool     `json:"allow_negative_stock"`
+	Barcodes           []string `json:"barcodes"`
+	MinStock           *float64 `json:"min_stock"`    // low-stock alert level
+	TargetStock        *float64 `json:"target_stock"` // level production tops up to
 }
 
 type ProductHandler struct {
 	productStore store.ProductStore
+	audit        *audit.Recorder
 	logger       *slog.Logger
 }
 
-func NewProductHandler(productStore store.ProductStore, logger *slog.Logger) *ProductHandler {
+func NewProductHandler(productStore store.ProductStore, audit *audit.Recorder, logger *slog.Logger) *ProductHandler {
 	return &ProductHandler{
 		productStore: productStore,
+		audit:        audit,
 		logger:       logger,
 	}
 }
@@ -47,6 +58,15 @@ func (h *ProductHandler) validateRegisterRequest(req *registerProductRequest) er
 	if req.DistributionPrice <= 0 {
 		errs = append(errs, utils.FieldError{Field: "distribution_price", Message: "must be > 0"})
 	}
+	if _, err := services.ParseSaleUnit(req.SaleUnit); err != nil {
+		errs = append(errs, utils.FieldError{Field: "sale_unit", Message: "must be one of unit, kg, g"})
+	}
+	if _, err := services.NormalizeBarcodes(req.Barcodes); err != nil {
+		errs = append(errs, utils.FieldError{Field: "barcodes", Message: err.Error()})
+	}
+	if fe := stockLevelsFieldError(req.MinStock, req.TargetStock); fe != nil {
+		errs = append(errs, *fe)
+	}
 
 	if len(errs) > 0 {
 		return errs
@@ -63,6 +83,7 @@ func (h *ProductHandler) validateRegisterRequest(req *registerProductRequest) er
 // @Param        body  body      registerProductRequest  true  "Product data"
 // @Success      201   {object}  ProductResponse
 // @Failure      400   {object}  utils.HTTPError
+// @Failure      409   {object}  utils.HTTPError "A barcode already identifies another product"
 // @Failure      500   {object}  utils.HTTPError
 // @Security     BearerAuth
 // @Router       /api/v1/products [post]
@@ -82,12 +103,17 @@ func (h *ProductHandler) HandleRegisterProduct(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	saleUnit, _ := services.ParseSaleUnit(req.SaleUnit)
 	pr := &store.Product{
-		CategoryID:        req.CategoryID,
-		Name:              req.Name,
-		Description:       req.Description,
-		UnitPrice:         req.UnitPrice,
-		DistributionPrice: req.DistributionPrice,
+		CategoryID:         req.CategoryID,
+		Name:               req.Name,
+		Description:        req.Description,
+		UnitPrice:          req.UnitPrice,
+		DistributionPrice:  req.DistributionPrice,
+		SaleUnit:           saleUnit,
+		AllowNegativeStock: req.AllowNegativeStock,
+		MinStock:           req.MinStock,
+		TargetStock:        req.TargetStock,
 	}
 
 	if err := h.productStore.CreateProduct(pr); err != nil {
@@ -95,12 +121,18 @@ func (h *ProductHandler) HandleRegisterProduct(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+
+	ok := h.setBarcodes(w, pr, req.Barcodes)
+	h.audit.Created(r, store.AuditProduct, pr.ID, pr)
+	if !ok {
+		return
+	}
 	utils.OK(w, http.StatusCreated, utils.Envelope{"product": pr}, "", nil)
 }
 
 // HandleUpdateProduct godoc
 // @Summary      Updates a product
-// @Description  Updates a product's details
+// @Description  Updates a product's details. Fields left out are kept; min_stock and target_stock are cleared with null.
 // @Tags         products
 // @Accept       json
 // @Produce      json
@@ -108,7 +140,9 @@ func (h *ProductHandler) HandleRegisterProduct(w http.ResponseWriter, r *http.Re
 // @Param        body  body      registerProductRequest  true  "Product data"
 // @Success      200   {object}  ProductResponse
 // @Failure      400   {object}  utils.HTTPError
+// @Failure      403   {object}  utils.HTTPError "Changing prices requires prices.edit"
 // @Failure      404   {object}  utils.HTTPError
+// @Failure      409   {object}  utils.HTTPError "A barcode already identifies another product"
 // @Failure      500   {object}  utils.HTTPError
 // @Security     BearerAuth
 // @Router       /api/v1/products/{id} [patch]
@@ -132,11 +166,17 @@ func (h *ProductHandler) HandleUpdateProduct(w http.ResponseWriter, r *http.Requ
 	}
 
 	var req struct {
-		CategoryID        *int64   `json:"category_id"`
-		Name              *string  `json:"name"`
-		Description       *string  `json:"description"`
-		UnitPrice         *float64 `json:"unit_price"`
-		DistributionPrice *float64 `json:"distribution_price"`
+		CategoryID         *int64    `json:"category_id"`
+		Name               *string   `json:"name"`
+		Description        *string   `json:"description"`
+		UnitPrice          *float64  `json:"unit_price"`
+		DistributionPrice  *float64  `json:"distribution_price"`
+		SaleUnit           *string   `json:"sale_unit"`
+		AllowNegativeStock *bool     `json:"allow_negative_stock"`
+		Barcodes           *[]string `json:"barcodes"`
+		// Raw so that null (clear) can be told apart from absent (keep).
+		MinStock    json.RawMessage `json:"min_stock"`
+		TargetStock json.RawMessage `json:"target_stock"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.logger.Error("decoding update product", "error", err)
@@ -144,6 +184,7 @@ func (h *ProductHandler) HandleUpdateProduct(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	before := *pr
 	if req.CategoryID != nil {
 		pr.CategoryID = *req.CategoryID
 	}
@@ -153,21 +194,109 @@ func (h *ProductHandler) HandleUpdateProduct(w http.ResponseWriter, r *http.Requ
 	if req.Description != nil {
 		pr.Description = *req.Description
 	}
+	if (req.UnitPrice != nil || req.DistributionPrice != nil) && !middleware.GetUser(r).Can(store.PermPricesEdit) {
+		utils.Error(w, http.StatusForbidden, "insufficient privileges to edit prices")
+		return
+	}
 	if req.UnitPrice != nil {
 		pr.UnitPrice = *req.UnitPrice
 	}
 	if req.DistributionPrice != nil {
 		pr.DistributionPrice = *req.DistributionPrice
 	}
+	if req.SaleUnit != nil {
+		saleUnit, err := services.ParseSaleUnit(*req.SaleUnit)
+		if err != nil {
+			utils.Fail(w, http.StatusBadRequest, "validation failed", utils.ValidationErrors{{Field: "sale_unit", Message: "must be one of unit, kg, g"}})
+			return
+		}
+		pr.SaleUnit = saleUnit
+	}
+	if req.AllowNegativeStock != nil {
+		pr.AllowNegativeStock = *req.AllowNegativeStock
+	}
+	if pr.MinStock, err = decodeStockLevel(req.MinStock, pr.MinStock); err != nil {
+		utils.Fail(w, http.StatusBadRequest, "validation failed", utils.ValidationErrors{{Field: "min_stock", Message: "must be a number or null"}})
+		return
+	}
+	if pr.TargetStock, err = decodeStockLevel(req.TargetStock, pr.TargetStock); err != nil {
+		utils.Fail(w, http.StatusBadRequest, "validation failed", utils.ValidationErrors{{Field: "target_stock", Message: "must be a number or null"}})
+		return
+	}
+	if fe := stockLevelsFieldError(pr.MinStock, pr.TargetStock); fe != nil {
+		utils.Fail(w, http.StatusBadRequest, "validation failed", utils.ValidationErrors{*fe})
+		return
+	}
+
+	if req.Barcodes != nil {
+		if _, err := services.NormalizeBarcodes(*req.Barcodes); err != nil {
+			utils.Fail(w, http.StatusBadRequest, "validation failed", utils.ValidationErrors{{Field: "barcodes", Message: err.Error()}})
+			return
+		}
+	}
 
 	if err := h.productStore.UpdateProduct(pr); err != nil {
 		h.logger.Error("updating product", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+
+	ok := req.Barcodes == nil || h.setBarcodes(w, pr, *req.Barcodes)
+	h.audit.Updated(r, store.AuditProduct, pr.ID, before, pr)
+	if !ok {
+		return
+	}
 	utils.OK(w, http.StatusOK, utils.Envelope{"product": pr}, "", nil)
 }
 
+// decodeStockLevel applies a partial-update stock level: absent keeps cur,
+// null clears it.
+func decodeStockLevel(raw json.RawMessage, cur *float64) (*float64, error) {
+	if raw == nil {
+		return cur, nil
+	}
+	var v *float64
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return cur, err
+	}
+	return v, nil
+}
+
+func stockLevelsFieldError(minStock, targetStock *float64) *utils.FieldError {
+	switch err := services.ValidateStockLevels(minStock, targetStock); {
+	case errors.Is(err, services.ErrNegativeStockLevel):
+		field := "min_stock"
+		if minStock == nil || *minStock >= 0 {
+			field = "target_stock"
+		}
+		return &utils.FieldError{Field: field, Message: "must be >= 0"}
+	case errors.Is(err, services.ErrTargetBelowMin):
+		return &utils.FieldError{Field: "target_stock", Message: "must be >= min_stock"}
+	}
+	return nil
+}
+
+// setBarcodes replaces the product's codes, writing the error response and
+// returning false if they can't be saved.
+func (h *ProductHandler) setBarcodes(w http.ResponseWriter, pr *store.Product, codes []string) bool {
+	codes, err := services.NormalizeBarcodes(codes)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	if err := h.productStore.SetProductBarcodes(pr.ID, codes); err != nil {
+		if errors.Is(err, store.ErrBarcodeInUse) {
+			utils.Error(w, http.StatusConflict, err.Error())
+			return false
+		}
+		h.logger.Error("setting product barcodes", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return false
+	}
+	pr.Barcodes = codes
+	return true
+}
+
 // HandleGetProductByID godoc
 // @Summary      Gets a product
 // @Description  Responds with a single product with a given ID
@@ -267,6 +396,13 @@ func (h *ProductHandler) HandleDeleteProduct(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	pr, err := h.productStore.GetProductByID(productID)
+	if err != nil {
+		h.logger.Error("getProductByID", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
 	err = h.productStore.DeleteProduct(productID)
 	if err == sql.ErrNoRows {
 		utils.Error(w, http.StatusNotFound, "product not found")
@@ -277,6 +413,7 @@ func (h *ProductHandler) HandleDeleteProduct(w http.ResponseWriter, r *http.Requ
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Deleted(r, store.AuditProduct, productID, pr)
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -321,6 +458,7 @@ func (h *ProductHandler) HandleAddIngredientToProduct(w http.ResponseWriter, r *
 		return
 	}
 
+	h.audit.Created(r, store.AuditProductRecipe, productID, pi)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"product_ingredient": pi}, "", nil)
 }
 
@@ -363,6 +501,13 @@ func (h *ProductHandler) HandleUpdateProductIngredient(w http.ResponseWriter, r
 		return
 	}
 
+	before, err := recipeIngredient(h.productStore, productID, ingredientID)
+	if err != nil {
+		h.logger.Error("getting product ingredient", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
 	pi, err := h.productStore.UpdateProductIngredient(productID, ingredientID, req.Quantity, req.Unit)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -374,6 +519,7 @@ func (h *ProductHandler) HandleUpdateProductIngredient(w http.ResponseWriter, r
 		return
 	}
 
+	h.audit.Updated(r, store.AuditProductRecipe, productID, before, pi)
 	utils.OK(w, http.StatusOK, utils.Envelope{"product_ingredient": pi}, "", nil)
 }
 
@@ -403,6 +549,13 @@ func (h *ProductHandler) HandleRemoveIngredientFromProduct(w http.ResponseWriter
 		return
 	}
 
+	before, err := recipeIngredient(h.productStore, productID, ingredientID)
+	if err != nil {
+		h.logger.Error("getting product ingredient", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
 	err = h.productStore.RemoveIngredientFromProduct(productID, ingredientID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -414,5 +567,21 @@ func (h *ProductHandler) HandleRemoveIngredientFromProduct(w http.ResponseWriter
 		return
 	}
 
+	h.audit.Deleted(r, store.AuditProductRecipe, productID, before)
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// recipeIngredient finds an ingredient of the product's recipe, nil when
+// it isn't in it.
+func recipeIngredient(ps store.ProductStore, productID, ingredientID int64) (*store.ProductIngredient, error) {
+	pr, err := ps.GetProductByID(productID)
+	if err != nil || pr == nil {
+		return nil, err
+	}
+	for _, pi := range pr.Recipe {
+		if pi.IngredientID == ingredientID {
+			return pi, nil
+		}
+	}
+	return nil, nil
+}
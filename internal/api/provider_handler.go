@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
@@ -28,11 +29,12 @@ type registerProviderCategoryRequest struct {
 
 type ProviderHandler struct {
 	providerStore store.ProviderStore
+	audit         *audit.Recorder
 	logger        *slog.Logger
 }
 
-func NewProviderHandler(s store.ProviderStore, l *slog.Logger) *ProviderHandler {
-	return &ProviderHandler{providerStore: s, logger: l}
+func NewProviderHandler(s store.ProviderStore, a *audit.Recorder, l *slog.Logger) *ProviderHandler {
+	return &ProviderHandler{providerStore: s, audit: a, logger: l}
 }
 
 func (h *ProviderHandler) validateRegister(req *registerProviderRequest) error {
@@ -88,6 +90,7 @@ func (h *ProviderHandler) HandleRegisterProvider(w http.ResponseWriter, r *http.
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Created(r, store.AuditProvider, p.ID, p)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"provider": p}, "", nil)
 }
 
@@ -130,6 +133,7 @@ func (h *ProviderHandler) HandleUpdateProvider(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusBadRequest, "invalid request payload")
 		return
 	}
+	before := *p
 	if req.Name != nil {
 		p.Name = *req.Name
 	}
@@ -161,6 +165,7 @@ func (h *ProviderHandler) HandleUpdateProvider(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Updated(r, store.AuditProvider, p.ID, before, p)
 	utils.OK(w, http.StatusOK, utils.Envelope{"provider": p}, "", nil)
 }
 
@@ -261,6 +266,7 @@ func (h *ProviderHandler) HandleCreateCategory(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Created(r, store.AuditProviderCategory, pc.ID, pc)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"category": pc}, "", nil)
 }
 
@@ -289,6 +295,12 @@ func (h *ProviderHandler) HandleUpdateCategory(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusBadRequest, "name is required")
 		return
 	}
+	before, err := h.providerStore.GetProviderCategoryByID(id)
+	if err != nil {
+		h.logger.Error("getting provider category", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
 	pc := &store.ProviderCategory{ID: id, Name: req.Name}
 	if err := h.providerStore.UpdateProviderCategory(pc); err != nil {
 		if err == sql.ErrNoRows {
@@ -299,6 +311,7 @@ func (h *ProviderHandler) HandleUpdateCategory(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Updated(r, store.AuditProviderCategory, id, before, pc)
 	utils.OK(w, http.StatusOK, utils.Envelope{"category": pc}, "", nil)
 }
 
@@ -308,6 +321,12 @@ func (h *ProviderHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusBadRequest, "invalid category id")
 		return
 	}
+	pc, err := h.providerStore.GetProviderCategoryByID(id)
+	if err != nil {
+		h.logger.Error("getting provider category", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
 	if err := h.providerStore.DeleteProviderCategory(id); err != nil {
 		if err == sql.ErrNoRows {
 			utils.Error(w, http.StatusNotFound, "category not found")
@@ -322,5 +341,6 @@ func (h *ProviderHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Re
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	h.audit.Deleted(r, store.AuditProviderCategory, id, pc)
 	utils.OK(w, http.StatusOK, utils.Envelope{"message": "category deleted"}, "", nil)
 }
\ No newline at end of file
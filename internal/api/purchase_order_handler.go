@@ -0,0 +1,328 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	chi "github.com/go-chi/chi/v5"
+)
+
+type PurchaseOrderHandler struct {
+	service *services.PurchaseOrderService
+	audit   *audit.Recorder
+	logger  *slog.Logger
+}
+
+func NewPurchaseOrderHandler(s *services.PurchaseOrderService, a *audit.Recorder, l *slog.Logger) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{service: s, audit: a, logger: l}
+}
+
+// HandleListPurchaseOrders godoc
+// @Summary      List purchase orders
+// @Description  Responds with purchase orders, newest first, without their lines.
+// @Tags         purchase_orders
+// @Produce      json
+// @Param        provider_id  query     int     false  "Only this provider"
+// @Param        state        query     string  false  "draft, sent, partially_received, received or cancelled"
+// @Param        limit        query     int     false  "Page size (default: all)"
+// @Param        offset       query     int     false  "Offset"
+// @Success      200          {object}  PurchaseOrdersResponse
+// @Failure      400          {object}  utils.HTTPError
+// @Failure      500          {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/purchase_orders [get]
+func (h *PurchaseOrderHandler) HandleListPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var filter store.PurchaseOrderFilter
+	if v := q.Get("provider_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid provider_id")
+			return
+		}
+		filter.ProviderID = &id
+	}
+	if v := q.Get("state"); v != "" {
+		state := store.PurchaseOrderState(v)
+		switch state {
+		case store.PurchaseOrderDraft, store.PurchaseOrderSent, store.PurchaseOrderPartiallyReceived,
+			store.PurchaseOrderReceived, store.PurchaseOrderCancelled:
+		default:
+			utils.Error(w, http.StatusBadRequest, "invalid state")
+			return
+		}
+		filter.State = &state
+	}
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+	filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+
+	orders, err := h.service.List(filter)
+	if err != nil {
+		h.logger.Error("listing purchase orders", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if orders == nil {
+		orders = []*store.PurchaseOrder{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"purchase_orders": orders}, "", nil)
+}
+
+// HandleCreatePurchaseOrder godoc
+// @Summary      Create a purchase order
+// @Description  Creates a draft order to a provider with the ingredients, quantities (in the unit the ingredient cost is expressed in) and expected unit prices.
+// @Tags         purchase_orders
+// @Accept       json
+// @Produce      json
+// @Param        body  body      services.PurchaseOrderRequest  true  "Order data"
+// @Success      201   {object}  PurchaseOrderResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError "Provider or ingredient not found"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/purchase_orders [post]
+func (h *PurchaseOrderHandler) HandleCreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	var req services.PurchaseOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	req.UserID = middleware.GetUser(r).ID
+
+	order, err := h.service.Create(req)
+	if err != nil {
+		h.writePurchaseOrderError(w, err)
+		return
+	}
+
+	h.audit.Created(r, store.AuditPurchaseOrder, order.ID, order)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"purchase_order": order}, "", nil)
+}
+
+// HandleGetPurchaseOrder godoc
+// @Summary      Get a purchase order
+// @Description  Responds with the order, its lines with received quantities and the receptions recorded so far.
+// @Tags         purchase_orders
+// @Produce      json
+// @Param        id   path      int  true  "Purchase order ID"
+// @Success      200  {object}  PurchaseOrderResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/purchase_orders/{id} [get]
+func (h *PurchaseOrderHandler) HandleGetPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := purchaseOrderID(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := h.service.Get(id)
+	if err != nil {
+		h.writePurchaseOrderError(w, err)
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"purchase_order": order}, "", nil)
+}
+
+// HandleUpdatePurchaseOrder godoc
+// @Summary      Update a draft purchase order
+// @Description  Replaces the provider, date, notes and lines of an order that has not been sent yet.
+// @Tags         purchase_orders
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                            true  "Purchase order ID"
+// @Param        body  body      services.PurchaseOrderRequest  true  "Order data"
+// @Success      200   {object}  PurchaseOrderResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError
+// @Failure      409   {object}  utils.HTTPError "Order is no longer a draft"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/purchase_orders/{id} [patch]
+func (h *PurchaseOrderHandler) HandleUpdatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := purchaseOrderID(w, r)
+	if !ok {
+		return
+	}
+
+	var req services.PurchaseOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	before, err := h.service.Get(id)
+	if err != nil {
+		h.writePurchaseOrderError(w, err)
+		return
+	}
+
+	order, err := h.service.Update(id, req)
+	if err != nil {
+		h.writePurchaseOrderError(w, err)
+		return
+	}
+	h.audit.Updated(r, store.AuditPurchaseOrder, id, before, order)
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"purchase_order": order}, "", nil)
+}
+
+// HandleSendPurchaseOrder godoc
+// @Summary      Email a purchase order to the provider
+// @Description  Emails the order to the provider and marks a draft as sent. Sent orders can be emailed again.
+// @Tags         purchase_orders
+// @Produce      json
+// @Param        id   path      int  true  "Purchase order ID"
+// @Success      200  {object}  PurchaseOrderResponse
+// @Failure      400  {object}  utils.HTTPError "Provider has no email"
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      409  {object}  utils.HTTPError "Order already received or cancelled"
+// @Failure      502  {object}  utils.HTTPError "Email could not be sent"
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/purchase_orders/{id}/send [post]
+func (h *PurchaseOrderHandler) HandleSendPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.changeState(w, r, "send", h.service.Send)
+}
+
+// HandleMarkPurchaseOrderSent godoc
+// @Summary      Mark a purchase order as sent
+// @Description  Moves a draft to sent without emailing it, for orders placed by phone or in person.
+// @Tags         purchase_orders
+// @Produce      json
+// @Param        id   path      int  true  "Purchase order ID"
+// @Success      200  {object}  PurchaseOrderResponse
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      409  {object}  utils.HTTPError "Order is not a draft"
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/purchase_orders/{id}/mark_sent [post]
+func (h *PurchaseOrderHandler) HandleMarkPurchaseOrderSent(w http.ResponseWriter, r *http.Request) {
+	h.changeState(w, r, "mark_sent", h.service.MarkSent)
+}
+
+// HandleCancelPurchaseOrder godoc
+// @Summary      Cancel a purchase order
+// @Description  Cancels an order that has not been fully received. Goods already received stay in stock.
+// @Tags         purchase_orders
+// @Produce      json
+// @Param        id   path      int  true  "Purchase order ID"
+// @Success      200  {object}  PurchaseOrderResponse
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      409  {object}  utils.HTTPError "Order already received or cancelled"
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/purchase_orders/{id}/cancel [post]
+func (h *PurchaseOrderHandler) HandleCancelPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.changeState(w, r, "cancel", h.service.Cancel)
+}
+
+// HandleReceivePurchaseOrder godoc
+// @Summary      Receive goods of a purchase order
+// @Description  Records a delivery: quantities are added to ingredient stock and an expense for the provider is created at the invoiced prices (the expected ones when omitted). The order becomes partially_received or received.
+// @Tags         purchase_orders
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                              true  "Purchase order ID"
+// @Param        body  body      services.ReceivePurchaseRequest  true  "Received quantities"
+// @Success      200   {object}  PurchaseOrderResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError
+// @Failure      409   {object}  utils.HTTPError "Order is not sent"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/purchase_orders/{id}/receive [post]
+func (h *PurchaseOrderHandler) HandleReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, ok := purchaseOrderID(w, r)
+	if !ok {
+		return
+	}
+
+	var req services.ReceivePurchaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	req.UserID = middleware.GetUser(r).ID
+
+	before, err := h.service.Get(id)
+	if err != nil {
+		h.writePurchaseOrderError(w, err)
+		return
+	}
+
+	order, err := h.service.Receive(id, req)
+	if err != nil {
+		h.writePurchaseOrderError(w, err)
+		return
+	}
+	h.audit.Record(r, store.AuditPurchaseOrder, id, "receive", before, order)
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"purchase_order": order}, "", nil)
+}
+
+// changeState applies a state transition, recorded in the audit log as
+// action.
+func (h *PurchaseOrderHandler) changeState(w http.ResponseWriter, r *http.Request, action string, apply func(int64) (*store.PurchaseOrder, error)) {
+	id, ok := purchaseOrderID(w, r)
+	if !ok {
+		return
+	}
+
+	before, err := h.service.Get(id)
+	if err != nil {
+		h.writePurchaseOrderError(w, err)
+		return
+	}
+
+	order, err := apply(id)
+	if err != nil {
+		h.writePurchaseOrderError(w, err)
+		return
+	}
+	h.audit.Record(r, store.AuditPurchaseOrder, id, action, before, order)
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"purchase_order": order}, "", nil)
+}
+
+func purchaseOrderID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid purchase order id")
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *PurchaseOrderHandler) writePurchaseOrderError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrPurchaseOrderNotFound), errors.Is(err, services.ErrProviderNotFound),
+		errors.Is(err, services.ErrIngredientNotFound), errors.Is(err, services.ErrExpenseCategoryNotFound):
+		utils.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrPurchaseOrderNotDraft), errors.Is(err, services.ErrPurchaseOrderNotReceivable),
+		errors.Is(err, services.ErrPurchaseOrderClosed):
+		utils.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrPurchaseOrderEmpty), errors.Is(err, services.ErrDuplicatePurchaseItem),
+		errors.Is(err, services.ErrInvalidPurchaseQuantity), errors.Is(err, services.ErrInvalidPurchasePrice),
+		errors.Is(err, services.ErrInvalidExpectedDate), errors.Is(err, services.ErrProviderWithoutEmail),
+		errors.Is(err, services.ErrPurchaseReceiptEmpty), errors.Is(err, services.ErrPurchaseItemNotFound):
+		utils.Error(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrPurchaseOrderEmailFailed):
+		h.logger.Error("emailing purchase order", "error", err)
+		utils.Error(w, http.StatusBadGateway, services.ErrPurchaseOrderEmailFailed.Error())
+	default:
+		h.logger.Error("saving purchase order", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -1,7 +1,10 @@
 package api
 
 import (
+	"time"
+
 	"github.com/RamunnoAJ/aesovoy-server/internal/billing"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
@@ -67,10 +70,79 @@ type UserResponse struct {
 	User store.User `json:"user"`
 }
 
+type RoleResponse struct {
+	Role store.Role `json:"role"`
+}
+
+type APIKeysResponse struct {
+	APIKeys []store.APIKey `json:"api_keys"`
+}
+
+type APIKeyCreatedResponse struct {
+	APIKey store.APIKey `json:"api_key"`
+	Key    string       `json:"key" example:"aesk_..."`
+}
+
+type RolesResponse struct {
+	Roles []store.Role `json:"roles"`
+}
+
+type AuditLogResponse struct {
+	Entries []store.AuditEntry `json:"entries"`
+}
+
+type SecurityEventsResponse struct {
+	Events []store.SecurityEvent `json:"events"`
+}
+
+type WebhooksResponse struct {
+	Webhooks []store.Webhook `json:"webhooks"`
+}
+
+type WebhookResponse struct {
+	Webhook store.Webhook `json:"webhook"`
+}
+
+// WebhookSecretResponse also carries the secret, only returned when it is set.
+type WebhookSecretResponse struct {
+	Webhook store.Webhook `json:"webhook"`
+	Secret  string        `json:"secret" example:"whsec_..."`
+}
+
+type WebhookDeliveryResponse struct {
+	Delivery store.WebhookDelivery `json:"delivery"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []store.WebhookDelivery `json:"deliveries"`
+}
+
+type JobsResponse struct {
+	Jobs []store.Job `json:"jobs"`
+	// Counts has how many jobs there are in each status.
+	Counts map[string]int `json:"counts"`
+}
+
+type JobResponse struct {
+	Job store.Job `json:"job"`
+}
+
+type PermissionsResponse struct {
+	Permissions []store.PermissionInfo `json:"permissions"`
+}
+
 type TokenResponse struct {
 	AuthToken string `json:"auth_token"`
 }
 
+type TwoFactorChallengeResponse struct {
+	TwoFactorToken string `json:"two_factor_token"`
+}
+
+type SessionsResponse struct {
+	Sessions []store.Session `json:"sessions"`
+}
+
 type ProductIngredientResponse struct {
 	ProductIngredient store.ProductIngredient `json:"product_ingredient"`
 }
@@ -100,6 +172,86 @@ type LocalStocksResponse struct {
 	LocalStock []store.LocalStock `json:"local_stock"`
 }
 
+type StockMovementsResponse struct {
+	OpeningBalance float64               `json:"opening_balance"`
+	Movements      []store.StockMovement `json:"movements"`
+}
+
+type StockAtResponse struct {
+	ProductID int64     `json:"product_id"`
+	At        time.Time `json:"at"`
+	Quantity  float64   `json:"quantity"`
+}
+
+type LotsResponse struct {
+	Lots []store.StockLot `json:"lots"`
+}
+
+type LotResponse struct {
+	Lot       store.StockLot      `json:"lot"`
+	Movements []store.LotMovement `json:"movements"`
+}
+
+type StockLocationResponse struct {
+	StockLocation store.StockLocation `json:"stock_location"`
+}
+
+type StockLocationsResponse struct {
+	StockLocations []store.StockLocation `json:"stock_locations"`
+}
+
+type StockTransferResponse struct {
+	StockTransfer store.StockTransfer `json:"stock_transfer"`
+}
+
+type StockTransfersResponse struct {
+	StockTransfers []store.StockTransfer `json:"stock_transfers"`
+}
+
+type ProductionPlanResponse struct {
+	ProductionPlan services.ProductionPlan `json:"production_plan"`
+}
+
+type PurchaseOrderResponse struct {
+	PurchaseOrder store.PurchaseOrder `json:"purchase_order"`
+}
+
+type PurchaseOrdersResponse struct {
+	PurchaseOrders []store.PurchaseOrder `json:"purchase_orders"`
+}
+
+type PayablesResponse struct {
+	Payables services.Payables `json:"payables"`
+}
+
+type ProviderBalancesResponse struct {
+	Balances []store.ProviderBalance `json:"balances"`
+}
+
+type ProviderPaymentResponse struct {
+	Payment store.ProviderPayment `json:"payment"`
+}
+
+type ProviderPaymentsResponse struct {
+	Payments []store.ProviderPayment `json:"payments"`
+}
+
+type ProviderStatementResponse struct {
+	Statement services.ProviderStatement `json:"statement"`
+}
+
+type WasteRecordResponse struct {
+	Waste store.WasteRecord `json:"waste"`
+}
+
+type WasteListResponse struct {
+	Waste []store.WasteRecord `json:"waste"`
+}
+
+type WasteReportResponse struct {
+	Report services.WasteReport `json:"report"`
+}
+
 type LocalSaleResponse struct {
 	LocalSale store.LocalSale `json:"local_sale"`
 }
@@ -108,6 +260,10 @@ type LocalSalesResponse struct {
 	LocalSales []store.LocalSale `json:"local_sales"`
 }
 
+type ScanResponse struct {
+	Scan services.ScanResult `json:"scan"`
+}
+
 type ProvidersResponse struct {
 	Providers []store.Provider `json:"providers"`
 	Meta      utils.Meta       `json:"meta"`
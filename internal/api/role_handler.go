@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type RoleHandler struct {
+	service *services.RoleService
+	audit   *audit.Recorder
+	logger  *slog.Logger
+}
+
+func NewRoleHandler(s *services.RoleService, a *audit.Recorder, l *slog.Logger) *RoleHandler {
+	return &RoleHandler{service: s, audit: a, logger: l}
+}
+
+// HandleListPermissions godoc
+// @Summary      List permissions
+// @Description  Responds with every permission a role can grant, grouped by area.
+// @Tags         roles
+// @Produce      json
+// @Success      200  {object}  PermissionsResponse
+// @Security     BearerAuth
+// @Router       /api/v1/permissions [get]
+func (h *RoleHandler) HandleListPermissions(w http.ResponseWriter, r *http.Request) {
+	utils.OK(w, http.StatusOK, utils.Envelope{"permissions": store.PermissionCatalog}, "", nil)
+}
+
+// HandleListRoles godoc
+// @Summary      List roles
+// @Description  Responds with the roles, system ones first, with their permissions and how many users have them.
+// @Tags         roles
+// @Produce      json
+// @Success      200  {object}  RolesResponse
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/roles [get]
+func (h *RoleHandler) HandleListRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.service.List()
+	if err != nil {
+		h.logger.Error("listing roles", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if roles == nil {
+		roles = []*store.Role{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"roles": roles}, "", nil)
+}
+
+// HandleGetRole godoc
+// @Summary      Get a role
+// @Tags         roles
+// @Produce      json
+// @Param        id   path      int  true  "Role ID"
+// @Success      200  {object}  RoleResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/roles/{id} [get]
+func (h *RoleHandler) HandleGetRole(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid role id")
+		return
+	}
+
+	role, err := h.service.Get(id)
+	if err != nil {
+		h.writeRoleError(w, err)
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"role": role}, "", nil)
+}
+
+// HandleCreateRole godoc
+// @Summary      Create a role
+// @Description  Adds a role granting the given permissions (see GET /api/v1/permissions).
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Param        body  body      services.RoleRequest  true  "Role data"
+// @Success      201   {object}  RoleResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      409   {object}  utils.HTTPError "Name already taken"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/roles [post]
+func (h *RoleHandler) HandleCreateRole(w http.ResponseWriter, r *http.Request) {
+	var req services.RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	role, err := h.service.Create(req)
+	if err != nil {
+		h.writeRoleError(w, err)
+		return
+	}
+
+	h.audit.Created(r, store.AuditRole, role.ID, role)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"role": role}, "", nil)
+}
+
+// HandleUpdateRole godoc
+// @Summary      Update a role
+// @Description  Replaces the role's name, description and permissions. System roles can't be renamed and the administrator role can't be modified.
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                   true  "Role ID"
+// @Param        body  body      services.RoleRequest  true  "Role data"
+// @Success      200   {object}  RoleResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError
+// @Failure      409   {object}  utils.HTTPError "Name already taken or system role"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/roles/{id} [patch]
+func (h *RoleHandler) HandleUpdateRole(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid role id")
+		return
+	}
+
+	var req services.RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	before, err := h.service.Get(id)
+	if err != nil {
+		h.writeRoleError(w, err)
+		return
+	}
+
+	role, err := h.service.Update(id, req)
+	if err != nil {
+		h.writeRoleError(w, err)
+		return
+	}
+
+	h.audit.Updated(r, store.AuditRole, id, before, role)
+	utils.OK(w, http.StatusOK, utils.Envelope{"role": role}, "", nil)
+}
+
+// HandleDeleteRole godoc
+// @Summary      Delete a role
+// @Description  Deletes a role no user is assigned. System roles can't be deleted.
+// @Tags         roles
+// @Param        id   path  int  true  "Role ID"
+// @Success      204
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      409  {object}  utils.HTTPError "Role in use or system role"
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/roles/{id} [delete]
+func (h *RoleHandler) HandleDeleteRole(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid role id")
+		return
+	}
+
+	role, err := h.service.Get(id)
+	if err != nil {
+		h.writeRoleError(w, err)
+		return
+	}
+
+	if err := h.service.Delete(id); err != nil {
+		h.writeRoleError(w, err)
+		return
+	}
+	h.audit.Deleted(r, store.AuditRole, id, role)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *RoleHandler) writeRoleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound):
+		utils.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, store.ErrRoleNameTaken), errors.Is(err, store.ErrRoleInUse),
+		errors.Is(err, services.ErrSystemRole), errors.Is(err, services.ErrAdminRoleLocked):
+		utils.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrRoleNameEmpty), errors.Is(err, services.ErrUnknownPermission):
+		utils.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("saving role", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
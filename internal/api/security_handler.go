@@ -0,0 +1,161 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type SecurityHandler struct {
+	service   *services.LoginSecurityService
+	userStore store.UserStore
+	audit     *audit.Recorder
+	logger    *slog.Logger
+}
+
+func NewSecurityHandler(s *services.LoginSecurityService, userStore store.UserStore, a *audit.Recorder, l *slog.Logger) *SecurityHandler {
+	return &SecurityHandler{service: s, userStore: userStore, audit: a, logger: l}
+}
+
+// loginAttempt describes the request for the security events.
+func loginAttempt(r *http.Request, username string) services.LoginAttempt {
+	return services.LoginAttempt{
+		Username:  username,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// loginBlocked returns the *LoginBlockedError in err, logging when its
+// lockout email couldn't be sent.
+func loginBlocked(err error, logger *slog.Logger) (*services.LoginBlockedError, bool) {
+	var blocked *services.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		return nil, false
+	}
+	if blocked.NotifyErr != nil {
+		logger.Error("sending lockout email", "error", blocked.NotifyErr)
+	}
+	return blocked, true
+}
+
+// writeLoginError answers a failed API login: 401 for wrong credentials and
+// 429 with Retry-After while the account has to wait or is locked.
+func writeLoginError(w http.ResponseWriter, err error, logger *slog.Logger) {
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		utils.Error(w, http.StatusUnauthorized, "Credenciales incorrectas")
+		return
+	}
+	if blocked, ok := loginBlocked(err, logger); ok {
+		retry := int(math.Ceil(time.Until(blocked.Until).Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(max(retry, 1)))
+		utils.Error(w, http.StatusTooManyRequests, blocked.Error())
+		return
+	}
+	logger.Error("checking login", "error", err)
+	utils.Error(w, http.StatusInternalServerError, "internal server error")
+}
+
+// HandleListSecurityEvents godoc
+// @Summary      List security events
+// @Description  Responds with failed logins, two-factor codes and POS PINs, lockouts, unlocks and password resets, newest first. Failed logins for unknown usernames have no user_id.
+// @Tags         security
+// @Produce      json
+// @Param        user_id  query     int     false  "User ID"
+// @Param        event    query     string  false  "Event (login_failed, two_factor_failed, pin_failed, pin_lockout, lockout, unlock, password_reset_requested, password_reset)"
+// @Param        limit    query     int     false  "Limit (default 50)"
+// @Param        offset   query     int     false  "Offset"
+// @Success      200      {object}  SecurityEventsResponse
+// @Failure      400      {object}  utils.HTTPError
+// @Failure      500      {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/security_events [get]
+func (h *SecurityHandler) HandleListSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := store.SecurityEventFilter{Event: q.Get("event")}
+	if v := q.Get("user_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid user_id")
+			return
+		}
+		filter.UserID = &id
+	}
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+	filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	events, total, err := h.service.Events(filter)
+	if err != nil {
+		h.logger.Error("listing security events", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if events == nil {
+		events = []*store.SecurityEvent{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"events": events}, "", &utils.Meta{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Total:  total,
+	})
+}
+
+// HandleUnlockUser godoc
+// @Summary      Unlock a user
+// @Description  Lifts a lockout after too many failed logins and clears the failed attempts, so the user can log in right away.
+// @Tags         security
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  UserResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/users/{id}/unlock [post]
+func (h *SecurityHandler) HandleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	user, err := h.userStore.GetUserByID(id)
+	if err != nil {
+		h.logger.Error("getting user", "id", id, "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if user == nil {
+		utils.Error(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	admin := middleware.GetUser(r)
+	err = h.service.Unlock(id, loginAttempt(r, user.Username), "desbloqueado por "+admin.Username)
+	if errors.Is(err, services.ErrSecurityUserNotFound) {
+		utils.Error(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("unlocking user", "id", id, "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	h.audit.Record(r, store.AuditUser, id, "unlock", nil, nil)
+	user.LockedUntil = nil
+	utils.OK(w, http.StatusOK, utils.Envelope{"user": user}, "", nil)
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	chi "github.com/go-chi/chi/v5"
+)
+
+type StockLocationHandler struct {
+	service *services.StockLocationService
+	audit   *audit.Recorder
+	logger  *slog.Logger
+}
+
+func NewStockLocationHandler(s *services.StockLocationService, a *audit.Recorder, l *slog.Logger) *StockLocationHandler {
+	return &StockLocationHandler{service: s, audit: a, logger: l}
+}
+
+type StockLocationRequest struct {
+	Name     string `json:"name"`
+	IsActive *bool  `json:"is_active,omitempty"`
+}
+
+// HandleListLocations godoc
+// @Summary      List stock locations
+// @Description  Responds with the places that hold stock, the default one first.
+// @Tags         stock_locations
+// @Produce      json
+// @Param        active  query     bool  false  "Only active locations"
+// @Success      200     {object}  StockLocationsResponse
+// @Failure      500     {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/stock_locations [get]
+func (h *StockLocationHandler) HandleListLocations(w http.ResponseWriter, r *http.Request) {
+	locations, err := h.service.ListLocations(r.URL.Query().Get("active") == "true")
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if locations == nil {
+		locations = []*store.StockLocation{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"stock_locations": locations}, "", nil)
+}
+
+// HandleCreateLocation godoc
+// @Summary      Create a stock location
+// @Description  Adds a place that holds stock. It starts empty; stock arrives through transfers or adjustments.
+// @Tags         stock_locations
+// @Accept       json
+// @Produce      json
+// @Param        body  body      StockLocationRequest  true  "Location data"
+// @Success      201   {object}  StockLocationResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      409   {object}  utils.HTTPError "Name already taken"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/stock_locations [post]
+func (h *StockLocationHandler) HandleCreateLocation(w http.ResponseWriter, r *http.Request) {
+	var req StockLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	location, err := h.service.CreateLocation(req.Name)
+	if err != nil {
+		h.writeLocationError(w, err)
+		return
+	}
+
+	h.audit.Created(r, store.AuditStockLocation, location.ID, location)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"stock_location": location}, "", nil)
+}
+
+// HandleUpdateLocation godoc
+// @Summary      Update a stock location
+// @Description  Renames a location or (de)activates it. The default location cannot be deactivated.
+// @Tags         stock_locations
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                   true  "Location ID"
+// @Param        body  body      StockLocationRequest  true  "Location data"
+// @Success      200   {object}  StockLocationResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError
+// @Failure      409   {object}  utils.HTTPError "Name already taken"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/stock_locations/{id} [patch]
+func (h *StockLocationHandler) HandleUpdateLocation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid location id")
+		return
+	}
+
+	var req StockLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	before, err := h.service.GetLocation(id)
+	if err != nil {
+		h.writeLocationError(w, err)
+		return
+	}
+	location, err := h.service.UpdateLocation(id, req.Name, isActive)
+	if err != nil {
+		h.writeLocationError(w, err)
+		return
+	}
+
+	h.audit.Updated(r, store.AuditStockLocation, id, before, location)
+	utils.OK(w, http.StatusOK, utils.Envelope{"stock_location": location}, "", nil)
+}
+
+func (h *StockLocationHandler) writeLocationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrLocationNotFound):
+		utils.Error(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, store.ErrLocationNameTaken):
+		utils.Error(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrLocationNameEmpty), errors.Is(err, services.ErrDefaultLocationInactive):
+		utils.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("saving stock location", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+// HandleCreateTransfer godoc
+// @Summary      Create an internal transfer
+// @Description  Records a remito interno and moves its goods from one location to the other in the same transaction, lots included.
+// @Tags         stock_transfers
+// @Accept       json
+// @Produce      json
+// @Param        body  body      services.CreateTransferRequest  true  "Transfer data"
+// @Success      201   {object}  StockTransferResponse
+// @Failure      400   {object}  utils.HTTPError "Invalid input or insufficient stock"
+// @Failure      404   {object}  utils.HTTPError "Location or product not found"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/stock_transfers [post]
+func (h *StockLocationHandler) HandleCreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var req services.CreateTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	req.UserID = middleware.GetUser(r).ID
+
+	transfer, err := h.service.CreateTransfer(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrLocationNotFound), errors.Is(err, services.ErrProductNotFound):
+			utils.Error(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrSameLocation), errors.Is(err, services.ErrTransferEmpty),
+			errors.Is(err, services.ErrInvalidTransferQuantity), errors.Is(err, services.ErrLocationInactive),
+			errors.Is(err, services.ErrInsufficientStock), errors.Is(err, services.ErrFractionalQuantity):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			h.logger.Error("creating stock transfer", "error", err)
+			utils.Error(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	h.audit.Created(r, store.AuditStockTransfer, transfer.ID, transfer)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"stock_transfer": transfer}, "", nil)
+}
+
+// HandleListTransfers godoc
+// @Summary      List internal transfers
+// @Description  Responds with the remitos internos in a date range, newest first, without their items.
+// @Tags         stock_transfers
+// @Produce      json
+// @Param        from         query     string  false  "First day, YYYY-MM-DD (default: 30 days ago)"
+// @Param        to           query     string  false  "Last day, YYYY-MM-DD (default: today)"
+// @Param        location_id  query     int     false  "Only transfers leaving or entering this location"
+// @Success      200          {object}  StockTransfersResponse
+// @Failure      400          {object}  utils.HTTPError
+// @Failure      500          {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/stock_transfers [get]
+func (h *StockLocationHandler) HandleListTransfers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to, err := parseMovementRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	locationID, err := parseLocationID(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	transfers, err := h.service.ListTransfers(from, to, locationID)
+	if err != nil {
+		h.logger.Error("listing stock transfers", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if transfers == nil {
+		transfers = []*store.StockTransfer{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"stock_transfers": transfers}, "", nil)
+}
+
+// HandleGetTransfer godoc
+// @Summary      Get an internal transfer
+// @Description  Responds with a remito interno and its items.
+// @Tags         stock_transfers
+// @Produce      json
+// @Param        id   path      int  true  "Transfer ID"
+// @Success      200  {object}  StockTransferResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/stock_transfers/{id} [get]
+func (h *StockLocationHandler) HandleGetTransfer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid transfer id")
+		return
+	}
+
+	transfer, err := h.service.GetTransfer(id)
+	if err != nil {
+		if errors.Is(err, services.ErrTransferNotFound) {
+			utils.Error(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.logger.Error("getting stock transfer", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"stock_transfer": transfer}, "", nil)
+}
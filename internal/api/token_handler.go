@@ -1,20 +1,27 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
-	"time"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
 
 type TokenHandler struct {
-	tokenStore store.TokenStore
-	userStore  store.UserStore
-	logger     *slog.Logger
+	tokenStore       store.TokenStore
+	userStore        store.UserStore
+	twoFactorService *services.TwoFactorService
+	loginSecurity    *services.LoginSecurityService
+	audit            *audit.Recorder
+	logger           *slog.Logger
 }
 
 type createTokenRequest struct {
@@ -22,24 +29,35 @@ type createTokenRequest struct {
 	Password string `json:"password"`
 }
 
-func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, logger *slog.Logger) *TokenHandler {
+type createTwoFactorTokenRequest struct {
+	TwoFactorToken string `json:"two_factor_token"`
+	Code           string `json:"code"`
+}
+
+func NewTokenHandler(tokenStore store.TokenStore, userStore store.UserStore, twoFactorService *services.TwoFactorService, loginSecurity *services.LoginSecurityService, audit *audit.Recorder, logger *slog.Logger) *TokenHandler {
 	return &TokenHandler{
-		tokenStore: tokenStore,
-		userStore:  userStore,
-		logger:     logger,
+		tokenStore:       tokenStore,
+		userStore:        userStore,
+		twoFactorService: twoFactorService,
+		loginSecurity:    loginSecurity,
+		audit:            audit,
+		logger:           logger,
 	}
 }
 
 // HandleCreateToken godoc
 // @Summary      Creates an authentication token
-// @Description  Creates a new authentication token for a user
+// @Description  Creates a new authentication token for a user. When the user has two-factor authentication enabled it responds 202 with a two_factor_token instead, to exchange with a code at /api/v1/tokens/two_factor. After 3 failed attempts in a row each new one has to wait, and 10 lock the account for 15 minutes; meanwhile it responds 429 with Retry-After.
 // @Tags         tokens
 // @Accept       json
 // @Produce      json
 // @Param        body  body      createTokenRequest  true  "User credentials"
 // @Success      201   {object}  TokenResponse
+// @Success      202   {object}  TwoFactorChallengeResponse
 // @Failure      400   {object}  utils.HTTPError
 // @Failure      401   {object}  utils.HTTPError
+// @Failure      403   {object}  utils.HTTPError
+// @Failure      429   {object}  utils.HTTPError
 // @Failure      500   {object}  utils.HTTPError
 // @Router       /api/v1/tokens/authentication [post]
 func (h *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request) {
@@ -54,31 +72,251 @@ func (h *TokenHandler) HandleCreateToken(w http.ResponseWriter, r *http.Request)
 
 	// lets get the user
 	user, err := h.userStore.GetUserByUsername(req.Username)
-	if err != nil || user == nil {
+	if err != nil {
 		h.logger.Error("GetUserByUsername", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
 
-	passwordsDoMatch, err := user.PasswordHash.Matches(req.Password)
+	if err := h.loginSecurity.CheckPassword(user, req.Password, loginAttempt(r, req.Username)); err != nil {
+		writeLoginError(w, err, h.logger)
+		return
+	}
+
+	tf, err := h.twoFactorService.Status(user.ID)
 	if err != nil {
-		h.logger.Error("PasswordHash.Mathes", "error", err)
+		h.logger.Error("getting two-factor status", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
 	}
+	if tf.Enabled {
+		token, err := h.tokenStore.CreateNewToken(int(user.ID), services.TwoFactorLoginTTL, tokens.ScopeTwoFactor)
+		if err != nil {
+			h.logger.Error("Creating Token", "error", err)
+			utils.Error(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+		utils.OK(w, http.StatusAccepted, utils.Envelope{"two_factor_token": token}, "", nil)
+		return
+	}
+	if h.twoFactorService.Required(user) {
+		utils.Error(w, http.StatusForbidden, "two-factor authentication is required for this account; enable it from the web")
+		return
+	}
+
+	h.finishLogin(w, r, user)
+}
 
-	if !passwordsDoMatch {
-		utils.Error(w, http.StatusUnauthorized, "Credenciales incorrectas")
+// HandleCreateTwoFactorToken godoc
+// @Summary      Completes a two-factor login
+// @Description  Exchanges the two_factor_token from /api/v1/tokens/authentication and a code from the authenticator app, or an unused recovery code, for an authentication token. The two_factor_token lasts 5 minutes.
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Param        body  body      createTwoFactorTokenRequest  true  "Two-factor token and code"
+// @Success      201   {object}  TokenResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      401   {object}  utils.HTTPError
+// @Failure      429   {object}  utils.HTTPError
+// @Failure      500   {object}  utils.HTTPError
+// @Router       /api/v1/tokens/two_factor [post]
+func (h *TokenHandler) HandleCreateTwoFactorToken(w http.ResponseWriter, r *http.Request) {
+	var req createTwoFactorTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TwoFactorToken == "" {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
 		return
 	}
 
-	token, err := h.tokenStore.CreateNewToken(int(user.ID), 24*time.Hour, tokens.ScopeAuth)
+	user, err := h.userStore.GetUserToken(tokens.ScopeTwoFactor, req.TwoFactorToken)
 	if err != nil {
-		h.logger.Error("Creating Token", "error", err)
+		h.logger.Error("GetUserToken", "error", err)
 		utils.Error(w, http.StatusInternalServerError, "internal server error")
 		return
+	}
+	if user == nil {
+		utils.Error(w, http.StatusUnauthorized, "invalid or expired two-factor token")
+		return
+	}
 
+	// Codes count as failed logins too, or a stolen password would allow
+	// guessing them.
+	if err := h.loginSecurity.Check(user); err != nil {
+		writeLoginError(w, err, h.logger)
+		return
+	}
+	if err := h.twoFactorService.Verify(user.ID, req.Code); err != nil {
+		if errors.Is(err, services.ErrTwoFactorInvalidCode) || errors.Is(err, services.ErrTwoFactorNotEnabled) {
+			if err := h.loginSecurity.Failed(user, store.SecurityTwoFactorFailed, loginAttempt(r, user.Username)); err != nil {
+				writeLoginError(w, err, h.logger)
+				return
+			}
+			utils.Error(w, http.StatusUnauthorized, "invalid code")
+			return
+		}
+		h.logger.Error("verifying two-factor code", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if err := h.tokenStore.DeleteAllTokensForUser(int(user.ID), tokens.ScopeTwoFactor); err != nil {
+		h.logger.Error("deleting two-factor tokens", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	h.finishLogin(w, r, user)
+}
+
+// finishLogin clears the failed attempts of a complete login and issues its
+// token.
+func (h *TokenHandler) finishLogin(w http.ResponseWriter, r *http.Request, user *store.User) {
+	if err := h.loginSecurity.Succeeded(user); err != nil {
+		h.logger.Error("clearing failed logins", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	h.writeAuthToken(w, r, user)
+}
+
+func (h *TokenHandler) writeAuthToken(w http.ResponseWriter, r *http.Request, user *store.User) {
+	token, err := tokens.GenerateToken(int(user.ID), store.SessionTTL, tokens.ScopeAuth)
+	if err == nil {
+		token.UserAgent = r.UserAgent()
+		token.IP = utils.ClientIP(r)
+		err = h.tokenStore.Insert(token)
+	}
+	if err != nil {
+		h.logger.Error("Creating Token", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
 	}
 
 	utils.OK(w, http.StatusCreated, utils.Envelope{"auth_token": token}, "", nil)
 }
+
+// HandleListSessions godoc
+// @Summary      List my sessions
+// @Description  Responds with the authenticated user's active sessions, most recently used first. The one the request was made with is marked current.
+// @Tags         tokens
+// @Produce      json
+// @Success      200  {object}  SessionsResponse
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/sessions [get]
+func (h *TokenHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	h.writeSessions(w, user.ID, user.SessionID)
+}
+
+// HandleRevokeSession godoc
+// @Summary      Revoke one of my sessions
+// @Description  Logs out the device holding the session. Revoking the current session logs out the request's token.
+// @Tags         tokens
+// @Param        id   path  int  true  "Session ID"
+// @Success      204
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/sessions/{id} [delete]
+func (h *TokenHandler) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	user := middleware.GetUser(r)
+	if err := h.tokenStore.RevokeSession(user.ID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			utils.Error(w, http.StatusNotFound, "session not found")
+			return
+		}
+		h.logger.Error("revoking session", "session_id", id, "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleListUserSessions godoc
+// @Summary      List a user's sessions
+// @Tags         tokens
+// @Produce      json
+// @Param        id   path      int  true  "User ID"
+// @Success      200  {object}  SessionsResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/users/{id}/sessions [get]
+func (h *TokenHandler) HandleListUserSessions(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.readTargetUser(w, r)
+	if !ok {
+		return
+	}
+	h.writeSessions(w, target.ID, middleware.GetUser(r).SessionID)
+}
+
+// HandleLogoutUser godoc
+// @Summary      Force logout a user
+// @Description  Revokes every session of the user, on the web and the API.
+// @Tags         tokens
+// @Param        id   path  int  true  "User ID"
+// @Success      204
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/users/{id}/sessions [delete]
+func (h *TokenHandler) HandleLogoutUser(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.readTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.tokenStore.DeleteAllTokensForUser(int(target.ID), tokens.ScopeAuth); err != nil {
+		h.logger.Error("revoking user sessions", "user_id", target.ID, "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	h.audit.Record(r, store.AuditUser, target.ID, "logout", nil, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TokenHandler) readTargetUser(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid user id")
+		return nil, false
+	}
+	user, err := h.userStore.GetUserByID(id)
+	if err != nil {
+		h.logger.Error("getting user", "id", id, "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return nil, false
+	}
+	if user == nil {
+		utils.Error(w, http.StatusNotFound, "user not found")
+		return nil, false
+	}
+	return user, true
+}
+
+func (h *TokenHandler) writeSessions(w http.ResponseWriter, userID, currentID int64) {
+	sessions, err := h.tokenStore.ListSessions(userID)
+	if err != nil {
+		h.logger.Error("listing sessions", "user_id", userID, "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if sessions == nil {
+		sessions = []*store.Session{}
+	}
+	for _, s := range sessions {
+		s.Current = s.ID == currentID
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"sessions": sessions}, "", nil)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"regexp"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
@@ -20,12 +21,16 @@ type registerUserRequest struct {
 
 type UserHandler struct {
 	userStore store.UserStore
+	roleStore store.RoleStore
+	audit     *audit.Recorder
 	logger    *slog.Logger
 }
 
-func NewUserHandler(userStore store.UserStore, logger *slog.Logger) *UserHandler {
+func NewUserHandler(userStore store.UserStore, roleStore store.RoleStore, audit *audit.Recorder, logger *slog.Logger) *UserHandler {
 	return &UserHandler{
 		userStore: userStore,
+		roleStore: roleStore,
+		audit:     audit,
 		logger:    logger,
 	}
 }
@@ -60,16 +65,12 @@ func (h *UserHandler) validateRegisterRequest(req *registerUserRequest) error {
 		req.Role = "employee"
 	}
 
-	if req.Role != "employee" && req.Role != "administrator" {
-		return errors.New("invalid role")
-	}
-
 	return nil
 }
 
 // HandleRegisterUser godoc
 // @Summary      Creates a user
-// @Description  Creates a new user with a username, email, and password
+// @Description  Creates a new user with a username, email, password and role (one of GET /api/v1/roles, "employee" by default)
 // @Tags         users
 // @Accept       json
 // @Produce      json
@@ -77,6 +78,7 @@ func (h *UserHandler) validateRegisterRequest(req *registerUserRequest) error {
 // @Success      201   {object}  UserResponse
 // @Failure      400   {object}  utils.HTTPError
 // @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
 // @Router       /api/v1/users [post]
 func (h *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request) {
 	var req registerUserRequest
@@ -94,6 +96,17 @@ func (h *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	role, err := h.roleStore.GetByName(req.Role)
+	if err != nil {
+		h.logger.Error("getting role", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if role == nil {
+		utils.Error(w, http.StatusBadRequest, "invalid role")
+		return
+	}
+
 	user := &store.User{
 		Username: req.Username,
 		Email:    req.Email,
@@ -115,5 +128,6 @@ func (h *UserHandler) HandleRegisterUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.audit.Created(r, store.AuditUser, user.ID, user)
 	utils.OK(w, http.StatusCreated, utils.Envelope{"user": user}, "", nil)
 }
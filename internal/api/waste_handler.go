@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type WasteHandler struct {
+	service *services.WasteService
+	audit   *audit.Recorder
+	logger  *slog.Logger
+}
+
+func NewWasteHandler(s *services.WasteService, a *audit.Recorder, l *slog.Logger) *WasteHandler {
+	return &WasteHandler{service: s, audit: a, logger: l}
+}
+
+// HandleRegisterWaste godoc
+// @Summary      Register waste
+// @Description  Records goods thrown away (reason: expired, broken, tasting or other) and deducts them from local stock. The loss is valued at recipe cost, or at the retail price when the recipe has no ingredient costs.
+// @Tags         waste
+// @Accept       json
+// @Produce      json
+// @Param        body  body      services.RegisterWasteRequest  true  "Waste data"
+// @Success      201   {object}  WasteRecordResponse
+// @Failure      400   {object}  utils.HTTPError "Invalid input or insufficient stock"
+// @Failure      404   {object}  utils.HTTPError "Product not found"
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/waste [post]
+func (h *WasteHandler) HandleRegisterWaste(w http.ResponseWriter, r *http.Request) {
+	var req services.RegisterWasteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+	req.UserID = middleware.GetUser(r).ID
+
+	record, err := h.service.RegisterWaste(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrProductNotFound):
+			utils.Error(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInvalidWasteQuantity), errors.Is(err, services.ErrInvalidWasteReason),
+			errors.Is(err, services.ErrInsufficientStock), errors.Is(err, services.ErrFractionalQuantity):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			h.logger.Error("registering waste", "error", err)
+			utils.Error(w, http.StatusInternalServerError, "internal server error")
+		}
+		return
+	}
+
+	h.audit.Created(r, store.AuditWaste, record.ID, record)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"waste": record}, "", nil)
+}
+
+// HandleListWaste godoc
+// @Summary      List waste records
+// @Description  Responds with the waste registered in a date range, newest first.
+// @Tags         waste
+// @Produce      json
+// @Param        from        query     string  false  "First day, YYYY-MM-DD (default: 30 days ago)"
+// @Param        to          query     string  false  "Last day, YYYY-MM-DD (default: today)"
+// @Param        product_id  query     int     false  "Only this product"
+// @Success      200         {object}  WasteListResponse
+// @Failure      400         {object}  utils.HTTPError
+// @Failure      500         {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/waste [get]
+func (h *WasteHandler) HandleListWaste(w http.ResponseWriter, r *http.Request) {
+	from, to, productID, ok := parseWasteFilter(w, r)
+	if !ok {
+		return
+	}
+
+	records, err := h.service.ListWaste(from, to, productID)
+	if err != nil {
+		h.logger.Error("listing waste", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if records == nil {
+		records = []*store.WasteRecord{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"waste": records}, "", nil)
+}
+
+// HandleWasteReport godoc
+// @Summary      Waste report
+// @Description  Totals waste in a date range by product and by week (weeks start on Monday). With product_id the weekly series covers that product only; otherwise weekly quantities mix units and only the value is meaningful.
+// @Tags         waste
+// @Produce      json
+// @Param        from        query     string  false  "First day, YYYY-MM-DD (default: 30 days ago)"
+// @Param        to          query     string  false  "Last day, YYYY-MM-DD (default: today)"
+// @Param        product_id  query     int     false  "Weekly series for this product"
+// @Success      200         {object}  WasteReportResponse
+// @Failure      400         {object}  utils.HTTPError
+// @Failure      500         {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/waste/report [get]
+func (h *WasteHandler) HandleWasteReport(w http.ResponseWriter, r *http.Request) {
+	from, to, productID, ok := parseWasteFilter(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.Report(from, to, productID)
+	if err != nil {
+		h.logger.Error("building waste report", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if report.ByProduct == nil {
+		report.ByProduct = []*store.WasteByProduct{}
+	}
+	if report.ByWeek == nil {
+		report.ByWeek = []*store.WasteByWeek{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"report": report}, "", nil)
+}
+
+func parseWasteFilter(w http.ResponseWriter, r *http.Request) (from, to time.Time, productID int64, ok bool) {
+	q := r.URL.Query()
+	from, to, err := parseMovementRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, err.Error())
+		return from, to, 0, false
+	}
+	if v := q.Get("product_id"); v != "" {
+		productID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.Error(w, http.StatusBadRequest, "invalid product ID")
+			return from, to, 0, false
+		}
+	}
+	return from, to, productID, true
+}
@@ -5,7 +5,7 @@ import (
 	"net/http"
 	"strconv"
 
-	"github.com/RamunnoAJ/aesovoy-server/internal/mailer"
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
 	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
@@ -27,45 +27,99 @@ type WebHandler struct {
 	localStockService  *services.LocalStockService
 	localSaleService   *services.LocalSaleService
 	shiftService       *services.ShiftService
-	mailer             *mailer.Mailer
+	receiptService     *services.ReceiptService
+	inventoryService   *services.InventoryCountService
+	wasteService       *services.WasteService
+	locationService    *services.StockLocationService
+	planService        *services.ProductionPlanService
+	purchaseService    *services.PurchaseOrderService
+	payablesService    *services.AccountsPayableService
+	expenseService     *services.ExpenseService
+	extractionService  *services.ExpenseExtractionService
+	roleService        *services.RoleService
+	twoFactorService   *services.TwoFactorService
+	apiKeyService      *services.APIKeyService
+	loginSecurity      *services.LoginSecurityService
+	pinService         *services.PINService
+	webhookService     *services.WebhookService
+	jobStore           store.JobStore
+	auditStore         store.AuditStore
+	audit              *audit.Recorder
 	renderer           *views.Renderer
 	logger             *slog.Logger
 }
 
-func NewWebHandler(
-	userStore store.UserStore,
-	tokenStore store.TokenStore,
-	productStore store.ProductStore,
-	categoryStore store.CategoryStore,
-	ingredientStore store.IngredientStore,
-	clientStore store.ClientStore,
-	providerStore store.ProviderStore,
-	paymentMethodStore store.PaymentMethodStore,
-	orderStore store.OrderStore,
-	expenseStore store.ExpenseStore,
-	localStockService *services.LocalStockService,
-	localSaleService *services.LocalSaleService,
-	shiftService *services.ShiftService,
-	mailer *mailer.Mailer,
-	logger *slog.Logger,
-) *WebHandler {
+// WebHandlerDeps holds what the web handler needs; unset fields are left nil.
+type WebHandlerDeps struct {
+	UserStore          store.UserStore
+	TokenStore         store.TokenStore
+	ProductStore       store.ProductStore
+	CategoryStore      store.CategoryStore
+	IngredientStore    store.IngredientStore
+	ClientStore        store.ClientStore
+	ProviderStore      store.ProviderStore
+	PaymentMethodStore store.PaymentMethodStore
+	OrderStore         store.OrderStore
+	ExpenseStore       store.ExpenseStore
+	LocalStockService  *services.LocalStockService
+	LocalSaleService   *services.LocalSaleService
+	ShiftService       *services.ShiftService
+	ReceiptService     *services.ReceiptService
+	InventoryService   *services.InventoryCountService
+	WasteService       *services.WasteService
+	LocationService    *services.StockLocationService
+	PlanService        *services.ProductionPlanService
+	PurchaseService    *services.PurchaseOrderService
+	PayablesService    *services.AccountsPayableService
+	ExpenseService     *services.ExpenseService
+	ExtractionService  *services.ExpenseExtractionService
+	RoleService        *services.RoleService
+	TwoFactorService   *services.TwoFactorService
+	APIKeyService      *services.APIKeyService
+	LoginSecurity      *services.LoginSecurityService
+	PINService         *services.PINService
+	WebhookService     *services.WebhookService
+	JobStore           store.JobStore
+	AuditStore         store.AuditStore
+	Audit              *audit.Recorder
+	Logger             *slog.Logger
+}
+
+func NewWebHandler(d WebHandlerDeps) *WebHandler {
 	return &WebHandler{
-		userStore:          userStore,
-		tokenStore:         tokenStore,
-		productStore:       productStore,
-		categoryStore:      categoryStore,
-		ingredientStore:    ingredientStore,
-		clientStore:        clientStore,
-		providerStore:      providerStore,
-		paymentMethodStore: paymentMethodStore,
-		orderStore:         orderStore,
-		expenseStore:       expenseStore,
-		localStockService:  localStockService,
-		localSaleService:   localSaleService,
-		shiftService:       shiftService,
-		mailer:             mailer,
+		userStore:          d.UserStore,
+		tokenStore:         d.TokenStore,
+		productStore:       d.ProductStore,
+		categoryStore:      d.CategoryStore,
+		ingredientStore:    d.IngredientStore,
+		clientStore:        d.ClientStore,
+		providerStore:      d.ProviderStore,
+		paymentMethodStore: d.PaymentMethodStore,
+		orderStore:         d.OrderStore,
+		expenseStore:       d.ExpenseStore,
+		localStockService:  d.LocalStockService,
+		localSaleService:   d.LocalSaleService,
+		shiftService:       d.ShiftService,
+		receiptService:     d.ReceiptService,
+		inventoryService:   d.InventoryService,
+		wasteService:       d.WasteService,
+		locationService:    d.LocationService,
+		planService:        d.PlanService,
+		purchaseService:    d.PurchaseService,
+		payablesService:    d.PayablesService,
+		expenseService:     d.ExpenseService,
+		extractionService:  d.ExtractionService,
+		roleService:        d.RoleService,
+		twoFactorService:   d.TwoFactorService,
+		apiKeyService:      d.APIKeyService,
+		loginSecurity:      d.LoginSecurity,
+		pinService:         d.PINService,
+		webhookService:     d.WebhookService,
+		jobStore:           d.JobStore,
+		auditStore:         d.AuditStore,
+		audit:              d.Audit,
 		renderer:           views.NewRenderer(),
-		logger:             logger,
+		logger:             d.Logger,
 	}
 }
 
@@ -86,6 +140,7 @@ func (h *WebHandler) HandleCreateProviderCategory(w http.ResponseWriter, r *http
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditProviderCategory, category.ID, category)
 
 	data := map[string]any{
 		"Category": category,
@@ -112,6 +167,7 @@ func (h *WebHandler) HandleQuickCreateProviderCategory(w http.ResponseWriter, r
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditProviderCategory, category.ID, category)
 
 	utils.TriggerToast(w, "Categoría de proveedor creada", "success")
 	w.Header().Set("Content-Type", "text/html")
@@ -131,6 +187,13 @@ func (h *WebHandler) HandleUpdateProviderCategory(w http.ResponseWriter, r *http
 		return
 	}
 
+	before, err := h.providerStore.GetProviderCategoryByID(id)
+	if err != nil {
+		h.logger.Error("getting provider category", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	category := &store.ProviderCategory{
 		ID:   id,
 		Name: r.FormValue("name"),
@@ -141,6 +204,7 @@ func (h *WebHandler) HandleUpdateProviderCategory(w http.ResponseWriter, r *http
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Updated(r, store.AuditProviderCategory, id, before, category)
 
 	data := map[string]any{
 		"Category": category,
@@ -158,11 +222,19 @@ func (h *WebHandler) HandleDeleteProviderCategory(w http.ResponseWriter, r *http
 		return
 	}
 
+	category, err := h.providerStore.GetProviderCategoryByID(id)
+	if err != nil {
+		h.logger.Error("getting provider category", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	if err := h.providerStore.DeleteProviderCategory(id); err != nil {
 		h.logger.Error("deleting provider category", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Deleted(r, store.AuditProviderCategory, id, category)
 
 	w.WriteHeader(http.StatusOK)
 }
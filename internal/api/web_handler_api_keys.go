@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+// --- API Keys ---
+
+// apiKeyScopeGroups are the permissions user can give a key, grouped like
+// in the role form.
+func apiKeyScopeGroups(user *store.User) []PermissionGroup {
+	var groups []PermissionGroup
+	for _, g := range permissionGroups() {
+		var permissions []store.PermissionInfo
+		for _, p := range g.Permissions {
+			if user.Can(p.Key) && services.APIKeyScopeAllowed(p.Key) {
+				permissions = append(permissions, p)
+			}
+		}
+		if len(permissions) > 0 {
+			groups = append(groups, PermissionGroup{Name: g.Name, Permissions: permissions})
+		}
+	}
+	return groups
+}
+
+func (h *WebHandler) HandleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	keys, err := h.apiKeyService.List()
+	if err != nil {
+		h.logger.Error("listing api keys", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":    middleware.GetUser(r),
+		"APIKeys": keys,
+	}
+	if err := h.renderer.Render(w, "api_keys_list.html", data); err != nil {
+		h.logger.Error("rendering api keys list", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateAPIKeyView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	data := map[string]any{
+		"User":        user,
+		"ScopeGroups": apiKeyScopeGroups(user),
+		"MinExpiry":   time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+	}
+	if err := h.renderer.Render(w, "api_key_form.html", data); err != nil {
+		h.logger.Error("rendering api key form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	req := services.APIKeyRequest{
+		Name:   r.FormValue("name"),
+		Scopes: r.Form["scopes[]"],
+		AllowedIPs: strings.FieldsFunc(r.FormValue("allowed_ips"), func(c rune) bool {
+			return c == ',' || c == '\n' || c == '\r' || c == ' '
+		}),
+	}
+	if v := r.FormValue("expires_at"); v != "" {
+		day, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			http.Redirect(w, r, "/users/api-keys/new?error="+url.QueryEscape("Fecha de vencimiento inválida"), http.StatusSeeOther)
+			return
+		}
+		// The key works through the whole day picked.
+		expiresAt := day.AddDate(0, 0, 1)
+		req.ExpiresAt = &expiresAt
+	}
+
+	user := middleware.GetUser(r)
+	key, plaintext, err := h.apiKeyService.Create(user, req)
+	if err != nil {
+		msg := "Error al crear la clave"
+		if isAPIKeyValidationError(err) {
+			msg = err.Error()
+		} else {
+			h.logger.Error("creating api key", "error", err)
+		}
+		http.Redirect(w, r, "/users/api-keys/new?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	h.audit.Created(r, store.AuditAPIKey, key.ID, key)
+
+	data := map[string]any{
+		"User":   user,
+		"APIKey": key,
+		"Key":    plaintext,
+	}
+	if err := h.renderer.Render(w, "api_key_created.html", data); err != nil {
+		h.logger.Error("rendering api key", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(id); err != nil {
+		msg := "Error al revocar la clave"
+		if errors.Is(err, services.ErrAPIKeyNotFound) || errors.Is(err, services.ErrAPIKeyRevoked) {
+			msg = err.Error()
+		} else {
+			h.logger.Error("revoking api key", "id", id, "error", err)
+		}
+		http.Redirect(w, r, "/users/api-keys?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditAPIKey, id, "revoke", nil, nil)
+
+	http.Redirect(w, r, "/users/api-keys?success="+url.QueryEscape("Clave revocada"), http.StatusSeeOther)
+}
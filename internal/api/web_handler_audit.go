@@ -0,0 +1,136 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+// auditExportLimit caps the entries of one export; narrow the filters to get
+// older ones.
+const auditExportLimit = 10000
+
+type auditEntityOption struct {
+	Value string
+	Label string
+}
+
+var auditEntityOptions = []auditEntityOption{
+	{store.AuditProduct, "Productos"},
+	{store.AuditProductRecipe, "Recetas"},
+	{store.AuditCategory, "Categorías"},
+	{store.AuditIngredient, "Ingredientes"},
+	{store.AuditClient, "Clientes"},
+	{store.AuditProvider, "Proveedores"},
+	{store.AuditProviderCategory, "Rubros de proveedores"},
+	{store.AuditOrder, "Pedidos"},
+	{store.AuditPaymentMethod, "Medios de pago"},
+	{store.AuditExpense, "Gastos"},
+	{store.AuditExpenseCategory, "Categorías de gastos"},
+	{store.AuditLocalSale, "Ventas local"},
+	{store.AuditLocalStock, "Stock local"},
+	{store.AuditUser, "Usuarios"},
+	{store.AuditRole, "Roles"},
+	{store.AuditCashRegister, "Cajas"},
+	{store.AuditStockLocation, "Ubicaciones de stock"},
+	{store.AuditStockTransfer, "Remitos internos"},
+	{store.AuditWaste, "Mermas"},
+	{store.AuditInventoryCount, "Conteos de inventario"},
+	{store.AuditPurchaseOrder, "Órdenes de compra"},
+	{store.AuditProviderPayment, "Pagos a proveedores"},
+	{store.AuditAPIKey, "Claves API"},
+	{store.AuditWebhook, "Webhooks"},
+	{store.AuditJob, "Trabajos en segundo plano"},
+}
+
+func (h *WebHandler) HandleListAuditLog(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+	q := r.URL.Query()
+
+	filter, err := parseAuditFilter(q)
+	if err != nil {
+		http.Redirect(w, r, "/audit-log?error="+url.QueryEscape("Filtro inválido"), http.StatusSeeOther)
+		return
+	}
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit := 50
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	entries, total, err := h.auditStore.List(filter)
+	if err != nil {
+		h.logger.Error("listing audit log", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	users, err := h.userStore.GetAllUsers()
+	if err != nil {
+		h.logger.Error("getting users for audit log", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// The filters without the page, to build the pagination and export links.
+	q.Del("page")
+
+	data := map[string]any{
+		"User":     user,
+		"Entries":  entries,
+		"Total":    total,
+		"Users":    users,
+		"Entities": auditEntityOptions,
+		"Filter":   filter,
+		"From":     q.Get("from"),
+		"To":       q.Get("to"),
+		"Query":    template.URL(q.Encode()),
+		"Page":     page,
+		"HasNext":  page*limit < total,
+		"NextPage": page + 1,
+		"PrevPage": page - 1,
+	}
+
+	if err := h.renderer.Render(w, "audit_log.html", data); err != nil {
+		h.logger.Error("rendering audit log", "error", err)
+	}
+}
+
+// HandleExportAuditLog downloads the entries matching the filters as JSON.
+func (h *WebHandler) HandleExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Limit = auditExportLimit
+
+	entries, _, err := h.auditStore.List(filter)
+	if err != nil {
+		h.logger.Error("exporting audit log", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []*store.AuditEntry{}
+	}
+
+	filename := fmt.Sprintf("auditoria-%s.json", time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		h.logger.Error("writing audit log export", "error", err)
+	}
+}
@@ -1,12 +1,16 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
 
 type DashboardView struct {
@@ -21,6 +25,7 @@ type DashboardView struct {
 	TopProductsDistrib     []*store.TopProduct
 	ProductionRequirements []*store.ProductionRequirement
 	LowStockAlerts         []*store.ProductStock
+	ExpiringLots           []LotView
 }
 
 func (h *WebHandler) HandleHome(w http.ResponseWriter, r *http.Request) {
@@ -85,7 +90,7 @@ func (h *WebHandler) HandleHome(w http.ResponseWriter, r *http.Request) {
 	var pendingProduction []*store.ProductionRequirement = []*store.ProductionRequirement{}
 	var lowStockAlerts []*store.ProductStock = []*store.ProductStock{}
 
-	if user.Role == "administrator" {
+	if user.Can(store.PermReportsView) {
 		orderStats, err = h.orderStore.GetStats(start, end)
 		if err != nil {
 			h.logger.Error("getting order stats", "error", err)
@@ -106,12 +111,22 @@ func (h *WebHandler) HandleHome(w http.ResponseWriter, r *http.Request) {
 			h.logger.Error("getting pending production requirements", "error", err)
 		}
 
-		lowStockAlerts, err = h.localStockService.GetLowStockAlerts(10) // Threshold 10
+		lowStockAlerts, err = h.localStockService.GetLowStockAlerts(services.DefaultLowStockThreshold)
 		if err != nil {
 			h.logger.Error("getting low stock alerts", "error", err)
 		}
 	}
 
+	// Lots expiring today or tomorrow, and expired ones still in stock.
+	var expiringLots []LotView
+	lots, err := h.localStockService.ExpiringLots(1)
+	if err != nil {
+		h.logger.Error("getting expiring lots", "error", err)
+	}
+	for _, lot := range lots {
+		expiringLots = append(expiringLots, newLotView(lot, now))
+	}
+
 	topProductsLocal, err := h.productStore.GetTopSellingProductsLocal(start, end)
 	if err != nil {
 		h.logger.Error("getting top products local", "error", err)
@@ -121,7 +136,7 @@ func (h *WebHandler) HandleHome(w http.ResponseWriter, r *http.Request) {
 	combinedTotal := localStats.TotalAmount
 	combinedCount := localStats.TotalCount
 
-	if user.Role == "administrator" {
+	if user.Can(store.PermReportsView) {
 		combinedTotal += orderStats.TotalAmount
 		combinedCount += orderStats.TotalCount
 	}
@@ -138,6 +153,7 @@ func (h *WebHandler) HandleHome(w http.ResponseWriter, r *http.Request) {
 		TopProductsDistrib:     topProductsDistrib,
 		ProductionRequirements: pendingProduction,
 		LowStockAlerts:         lowStockAlerts,
+		ExpiringLots:           expiringLots,
 	}
 
 	data := map[string]any{
@@ -153,15 +169,14 @@ func (h *WebHandler) HandleHome(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *WebHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    "",
-		Expires:  time.Unix(0, 0),
-		HttpOnly: true,
-		Path:     "/",
-		SameSite: http.SameSiteStrictMode,
-		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
-	})
+	user := middleware.GetUser(r)
+	if !user.IsAnonymous() {
+		if err := h.tokenStore.RevokeSession(user.ID, user.SessionID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			h.logger.Error("revoking session on logout", "error", err)
+		}
+	}
+	middleware.SetAuthCookie(w, r, "", time.Unix(0, 0))
+	middleware.SetPOSOperatorCookie(w, r, "", time.Unix(0, 0))
 
 	if r.Header.Get("HX-Request") != "" {
 		w.Header().Set("HX-Redirect", "/login")
@@ -202,48 +217,73 @@ func (h *WebHandler) HandleWebLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if user == nil {
-		h.renderLoginError(w, "Credenciales incorrectas")
+	if err := h.loginSecurity.CheckPassword(user, password, loginAttempt(r, username)); err != nil {
+		h.renderLoginError(w, h.loginErrorMessage(err))
 		return
 	}
 
-	match, err := user.PasswordHash.Matches(password)
+	// With two-factor the session waits for the code, or for the user to
+	// enroll when the role requires it.
+	tf, err := h.twoFactorService.Status(user.ID)
 	if err != nil {
-		h.logger.Error("matching password", "error", err)
+		h.logger.Error("getting two-factor status", "error", err)
 		h.renderLoginError(w, "Error interno del servidor")
 		return
 	}
-
-	if !match {
-		h.renderLoginError(w, "Credenciales incorrectas")
+	if tf.Enabled || h.twoFactorService.Required(user) {
+		if err := h.startTwoFactorLogin(w, r, user); err != nil {
+			h.logger.Error("starting two-factor login", "error", err)
+			h.renderLoginError(w, "Error interno del servidor")
+			return
+		}
+		if tf.Enabled {
+			w.Header().Set("HX-Redirect", "/login/2fa")
+		} else {
+			w.Header().Set("HX-Redirect", "/login/2fa/setup")
+		}
 		return
 	}
 
-	token, err := tokens.GenerateToken(int(user.ID), 24*time.Hour, tokens.ScopeAuth)
-	if err != nil {
-		h.logger.Error("generating token", "error", err)
+	if err := h.startSession(w, r, user); err != nil {
+		h.logger.Error("starting session", "error", err)
 		h.renderLoginError(w, "Internal server error")
 		return
 	}
 
-	err = h.tokenStore.Insert(token)
+	w.Header().Set("HX-Redirect", "/")
+}
+
+// loginErrorMessage is what to show for a failed password or two-factor code.
+func (h *WebHandler) loginErrorMessage(err error) string {
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		return "Credenciales incorrectas"
+	}
+	if blocked, ok := loginBlocked(err, h.logger); ok {
+		return blocked.Error()
+	}
+	h.logger.Error("checking login", "error", err)
+	return "Error interno del servidor"
+}
+
+// startSession logs the user in on this browser, clearing the failed logins
+// before it.
+func (h *WebHandler) startSession(w http.ResponseWriter, r *http.Request, user *store.User) error {
+	if err := h.loginSecurity.Succeeded(user); err != nil {
+		return err
+	}
+	token, err := tokens.GenerateToken(int(user.ID), store.SessionTTL, tokens.ScopeAuth)
 	if err != nil {
-		h.logger.Error("inserting token", "error", err)
-		h.renderLoginError(w, "Internal server error")
-		return
+		return err
 	}
+	token.UserAgent = r.UserAgent()
+	token.IP = utils.ClientIP(r)
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "auth_token",
-		Value:    token.Plaintext,
-		Expires:  token.Expiry,
-		HttpOnly: true,
-		Path:     "/",
-		SameSite: http.SameSiteStrictMode,
-		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
-	})
+	if err := h.tokenStore.Insert(token); err != nil {
+		return err
+	}
 
-	w.Header().Set("HX-Redirect", "/")
+	middleware.SetAuthCookie(w, r, token.Plaintext, token.Expiry)
+	return nil
 }
 
 func (h *WebHandler) renderLoginError(w http.ResponseWriter, msg string) {
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 )
 
 func (h *WebHandler) HandleShowProductionCalculator(w http.ResponseWriter, r *http.Request) {
@@ -168,3 +169,37 @@ func (h *WebHandler) HandleShowPendingProductionIngredients(w http.ResponseWrite
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
+
+func (h *WebHandler) HandleShowProductionSuggestions(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	q := r.URL.Query()
+	day, weeks, err := parsePlanParams(q.Get("date"), q.Get("weeks"))
+	if err != nil {
+		utils.TriggerToast(w, "Parámetros inválidos", "error")
+		day, weeks, _ = parsePlanParams("", "")
+	}
+
+	plan, err := h.planService.Suggest(day, weeks)
+	if err != nil {
+		utils.TriggerToast(w, err.Error(), "error")
+		day, weeks, _ = parsePlanParams(q.Get("date"), "")
+		plan, err = h.planService.Suggest(day, weeks)
+	}
+	if err != nil {
+		h.logger.Error("suggesting production", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":  user,
+		"Plan":  plan,
+		"Date":  plan.Date.Format("2006-01-02"),
+		"Weeks": plan.Weeks,
+	}
+
+	if err := h.renderer.Render(w, "production_suggestions.html", data); err != nil {
+		h.logger.Error("rendering production suggestions", "error", err)
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// --- Cash Registers ---
+
+func (h *WebHandler) HandleListCashRegisters(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	registers, err := h.shiftService.ListRegisters(false)
+	if err != nil {
+		h.logger.Error("listing cash registers", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Registers": registers,
+	}
+
+	if err := h.renderer.Render(w, "cash_registers_list.html", data); err != nil {
+		h.logger.Error("rendering cash registers list", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateCashRegisterView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	locations, err := h.locationService.ListLocations(true)
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Register":  store.CashRegister{IsActive: true},
+		"Locations": locations,
+	}
+
+	if err := h.renderer.Render(w, "cash_register_form.html", data); err != nil {
+		h.logger.Error("rendering cash register form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateCashRegister(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	locationID, _ := strconv.ParseInt(r.FormValue("location_id"), 10, 64)
+	register, err := h.shiftService.CreateRegister(r.FormValue("name"), locationID)
+	if err != nil {
+		h.logger.Error("creating cash register", "error", err)
+		http.Redirect(w, r, "/cash-registers?error="+url.QueryEscape(cashRegisterErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+	h.audit.Created(r, store.AuditCashRegister, register.ID, register)
+
+	http.Redirect(w, r, "/cash-registers?success="+url.QueryEscape("Caja creada exitosamente"), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleEditCashRegisterView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	register, err := h.shiftService.GetRegister(id)
+	if err != nil {
+		h.logger.Error("getting cash register", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if register == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	locations, err := h.locationService.ListLocations(true)
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Register":  register,
+		"Locations": locations,
+	}
+
+	if err := h.renderer.Render(w, "cash_register_form.html", data); err != nil {
+		h.logger.Error("rendering cash register form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleUpdateCashRegister(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	isActive := r.FormValue("is_active") == "on"
+	locationID, _ := strconv.ParseInt(r.FormValue("location_id"), 10, 64)
+	before, err := h.shiftService.GetRegister(id)
+	if err != nil {
+		h.logger.Error("getting cash register", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	register, err := h.shiftService.UpdateRegister(id, r.FormValue("name"), locationID, isActive)
+	if err != nil {
+		h.logger.Error("updating cash register", "error", err)
+		http.Redirect(w, r, "/cash-registers?error="+url.QueryEscape(cashRegisterErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+	h.audit.Updated(r, store.AuditCashRegister, id, before, register)
+
+	http.Redirect(w, r, "/cash-registers?success="+url.QueryEscape("Caja actualizada correctamente"), http.StatusSeeOther)
+}
+
+func cashRegisterErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrCashRegisterNameEmpty),
+		errors.Is(err, services.ErrCashRegisterNotFound):
+		return err.Error()
+	case errors.Is(err, services.ErrShiftAlreadyOpen):
+		return "No se puede desactivar una caja con un turno abierto"
+	default:
+		return "Error al guardar la caja"
+	}
+}
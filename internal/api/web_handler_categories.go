@@ -62,6 +62,7 @@ func (h *WebHandler) HandleCreateCategory(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditCategory, category.ID, category)
 
 	http.Redirect(w, r, "/categories?success="+url.QueryEscape("Categoría creada exitosamente"), http.StatusSeeOther)
 }
@@ -83,6 +84,7 @@ func (h *WebHandler) HandleQuickCreateCategory(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditCategory, category.ID, category)
 
 	utils.TriggerToast(w, "Categoría creada correctamente", "success")
 	w.Header().Set("Content-Type", "text/html")
@@ -138,17 +140,27 @@ func (h *WebHandler) HandleUpdateCategory(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	category := &store.Category{
-		ID:          categoryID,
-		Name:        r.FormValue("name"),
-		Description: r.FormValue("description"),
+	category, err := h.categoryStore.GetCategoryByID(categoryID)
+	if err != nil {
+		h.logger.Error("getting category", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	if category == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	before := *category
+
+	category.Name = r.FormValue("name")
+	category.Description = r.FormValue("description")
 
 	if err := h.categoryStore.UpdateCategory(category); err != nil {
 		h.logger.Error("updating category", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Updated(r, store.AuditCategory, categoryID, before, category)
 
 	http.Redirect(w, r, "/categories?success="+url.QueryEscape("Categoría actualizada correctamente"), http.StatusSeeOther)
 }
@@ -161,12 +173,21 @@ func (h *WebHandler) HandleDeleteCategory(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	category, err := h.categoryStore.GetCategoryByID(categoryID)
+	if err != nil {
+		h.logger.Error("getting category", "error", err)
+		utils.TriggerToast(w, "Error al eliminar categoría", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	if err := h.categoryStore.DeleteCategory(categoryID); err != nil {
 		h.logger.Error("deleting category", "error", err)
 		utils.TriggerToast(w, "Error al eliminar categoría", "error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Deleted(r, store.AuditCategory, categoryID, category)
 
 	utils.TriggerToast(w, "Categoría eliminada", "success")
 	w.WriteHeader(http.StatusOK)
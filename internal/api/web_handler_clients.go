@@ -89,6 +89,7 @@ func (h *WebHandler) HandleCreateClient(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditClient, client.ID, client)
 
 	http.Redirect(w, r, "/clients?success="+url.QueryEscape("Cliente creado exitosamente"), http.StatusSeeOther)
 }
@@ -134,22 +135,32 @@ func (h *WebHandler) HandleUpdateClient(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	client := &store.Client{
-		ID:        clientID,
-		Name:      r.FormValue("name"),
-		Address:   r.FormValue("address"),
-		Phone:     r.FormValue("phone"),
-		Reference: r.FormValue("reference"),
-		Email:     r.FormValue("email"),
-		CUIT:      r.FormValue("cuit"),
-		Type:      store.ClientType(r.FormValue("type")),
+	client, err := h.clientStore.GetClientByID(clientID)
+	if err != nil {
+		h.logger.Error("getting client", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	if client == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	before := *client
+
+	client.Name = r.FormValue("name")
+	client.Address = r.FormValue("address")
+	client.Phone = r.FormValue("phone")
+	client.Reference = r.FormValue("reference")
+	client.Email = r.FormValue("email")
+	client.CUIT = r.FormValue("cuit")
+	client.Type = store.ClientType(r.FormValue("type"))
 
 	if err := h.clientStore.UpdateClient(client); err != nil {
 		h.logger.Error("updating client", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Updated(r, store.AuditClient, clientID, before, client)
 
 	http.Redirect(w, r, "/clients?success="+url.QueryEscape("Cliente actualizado correctamente"), http.StatusSeeOther)
 }
@@ -162,12 +173,21 @@ func (h *WebHandler) HandleDeleteClient(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	client, err := h.clientStore.GetClientByID(clientID)
+	if err != nil {
+		h.logger.Error("getting client", "error", err)
+		utils.TriggerToast(w, "Error al eliminar cliente", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	if err := h.clientStore.DeleteClient(clientID); err != nil {
 		h.logger.Error("deleting client", "error", err)
 		utils.TriggerToast(w, "Error al eliminar cliente", "error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Deleted(r, store.AuditClient, clientID, client)
 
 	utils.TriggerToast(w, "Cliente eliminado", "success")
 	w.WriteHeader(http.StatusOK)
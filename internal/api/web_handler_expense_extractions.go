@@ -0,0 +1,129 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+// --- Receipt OCR ---
+
+// HandleScanExpenseReceipt reads an uploaded receipt and opens the expense
+// form pre-filled with what was found, for confirmation.
+func (h *WebHandler) HandleScanExpenseReceipt(w http.ResponseWriter, r *http.Request) {
+	if !h.extractionService.Available() {
+		http.Redirect(w, r, "/expenses/new?error="+url.QueryEscape(services.ErrOCRUnavailable.Error()), http.StatusSeeOther)
+		return
+	}
+
+	r.ParseMultipartForm(10 << 20)
+	imagePath, err := saveExpenseImage(r)
+	if errors.Is(err, errExpenseImageUpload) {
+		http.Redirect(w, r, "/expenses/new?error="+url.QueryEscape("No se pudo subir la imagen"), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		h.logger.Error("saving receipt image", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if imagePath == "" {
+		http.Redirect(w, r, "/expenses/new?error="+url.QueryEscape("Seleccioná la imagen del comprobante"), http.StatusSeeOther)
+		return
+	}
+
+	var userID *int64
+	if user := middleware.GetUser(r); user != nil && !user.IsAnonymous() {
+		userID = &user.ID
+	}
+	extraction, err := h.extractionService.Extract(r.Context(), imagePath, userID)
+	if err != nil {
+		h.logger.Error("extracting expense receipt", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if extraction.Error != "" {
+		h.logger.Warn("reading expense receipt", "extraction_id", extraction.ID, "error", extraction.Error)
+		http.Redirect(w, r, newExpenseURL(&extraction.ID, "No se pudo leer el comprobante, completá los datos a mano"), http.StatusSeeOther)
+		return
+	}
+	msg := url.QueryEscape("Comprobante leído, revisá los datos antes de guardar")
+	http.Redirect(w, r, fmt.Sprintf("/expenses/new?extraction_id=%d&success=%s", extraction.ID, msg), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleExpenseExtractionsView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	statusParam := r.URL.Query().Get("status")
+	var status *store.ExtractionStatus
+	if statusParam != "" {
+		st := store.ExtractionStatus(statusParam)
+		status = &st
+	}
+
+	extractions, err := h.extractionService.List(status)
+	if err != nil {
+		h.logger.Error("listing expense extractions", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":         middleware.GetUser(r),
+		"Extractions":  extractions,
+		"StatusParam":  statusParam,
+		"OCRAvailable": h.extractionService.Available(),
+	}
+	if err := h.renderer.Render(w, "expense_extractions.html", data); err != nil {
+		h.logger.Error("rendering expense extractions", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleGetExpenseExtractionImage(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	extraction, err := h.extractionService.Get(id)
+	if errors.Is(err, services.ErrExtractionNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting expense extraction", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeFile(w, r, extraction.ImagePath)
+}
+
+func (h *WebHandler) HandleDiscardExpenseExtraction(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Redirect(w, r, "/expenses/extractions?error="+url.QueryEscape("ID inválido"), http.StatusSeeOther)
+		return
+	}
+
+	err = h.extractionService.Discard(id)
+	if errors.Is(err, services.ErrExtractionNotFound) {
+		http.Redirect(w, r, "/expenses/extractions?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		h.logger.Error("discarding expense extraction", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/expenses/extractions?success="+url.QueryEscape("Lectura descartada"), http.StatusSeeOther)
+}
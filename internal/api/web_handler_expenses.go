@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -8,9 +9,11 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	"github.com/go-chi/chi/v5"
@@ -46,6 +49,14 @@ func (h *WebHandler) HandleListExpenses(w http.ResponseWriter, r *http.Request)
 			filter.CategoryID = &cid
 		}
 	}
+	if pid, err := strconv.ParseInt(r.URL.Query().Get("provider_id"), 10, 64); err == nil {
+		filter.ProviderID = &pid
+	}
+	statusStr := r.URL.Query().Get("status")
+	if statusStr != "" {
+		st := store.ExpensePaymentStatus(statusStr)
+		filter.Status = &st
+	}
 	if startDateStr != "" {
 		if t, err := time.Parse("2006-01-02", startDateStr); err == nil {
 			filter.StartDate = &t
@@ -82,15 +93,16 @@ func (h *WebHandler) HandleListExpenses(w http.ResponseWriter, r *http.Request)
 	}
 
 	data := map[string]any{
-		"User":       user,
-		"Expenses":   expenses,
-		"Categories": categories,
-		"Filter":     filter,
-		"TypeParam":  typeStr,
-		"Page":       page,
-		"HasNext":    hasNext,
-		"NextPage":   page + 1,
-		"PrevPage":   page - 1,
+		"User":        user,
+		"Expenses":    expenses,
+		"Categories":  categories,
+		"Filter":      filter,
+		"TypeParam":   typeStr,
+		"StatusParam": statusStr,
+		"Page":        page,
+		"HasNext":     hasNext,
+		"NextPage":    page + 1,
+		"PrevPage":    page - 1,
 	}
 
 	if err := h.renderer.Render(w, "expenses_list.html", data); err != nil {
@@ -99,6 +111,7 @@ func (h *WebHandler) HandleListExpenses(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *WebHandler) HandleCreateExpenseView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	user := middleware.GetUser(r)
 	providers, err := h.providerStore.GetAllProviders()
 	if err != nil {
@@ -114,11 +127,34 @@ func (h *WebHandler) HandleCreateExpenseView(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	ingredients, err := h.ingredientStore.GetAllIngredients()
+	if err != nil {
+		h.logger.Error("getting ingredients for expense form", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	data := map[string]any{
-		"User":       user,
-		"Providers":  providers,
-		"Categories": categories,
-		"Today":      time.Now().Format("2006-01-02"),
+		"User":         user,
+		"Providers":    providers,
+		"Categories":   categories,
+		"Ingredients":  ingredients,
+		"VATRates":     services.VATRates,
+		"Today":        time.Now().Format("2006-01-02"),
+		"OCRAvailable": h.extractionService.Available(),
+	}
+
+	// Loading from a read receipt pre-fills the form with its proposals.
+	if id, err := strconv.ParseInt(r.URL.Query().Get("extraction_id"), 10, 64); err == nil {
+		extraction, err := h.extractionService.Get(id)
+		if err != nil && !errors.Is(err, services.ErrExtractionNotFound) {
+			h.logger.Error("getting expense extraction", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if extraction != nil && extraction.Status == store.ExtractionPending {
+			data["Extraction"] = extraction
+		}
 	}
 
 	if err := h.renderer.Render(w, "expense_form.html", data); err != nil {
@@ -130,92 +166,264 @@ func (h *WebHandler) HandleCreateExpense(w http.ResponseWriter, r *http.Request)
 	// Limit upload size (e.g. 10MB)
 	r.ParseMultipartForm(10 << 20)
 
-	amountStr := r.FormValue("amount")
-	categoryIDStr := r.FormValue("category_id")
-	typeStr := r.FormValue("type")
-	dateStr := r.FormValue("date")
-	providerIDStr := r.FormValue("provider_id")
+	req := services.ExpenseRequest{
+		Amount:        r.FormValue("amount"),
+		Type:          store.ExpenseType(r.FormValue("type")),
+		Date:          r.FormValue("date"),
+		DueDate:       r.FormValue("due_date"),
+		InvoiceNumber: r.FormValue("invoice_number"),
+		InvoiceType:   store.InvoiceType(r.FormValue("invoice_type")),
+	}
 
 	// Validation
-	if amountStr == "" || categoryIDStr == "" || typeStr == "" || dateStr == "" {
+	categoryIDStr := r.FormValue("category_id")
+	if categoryIDStr == "" || req.Type == "" || req.Date == "" {
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		return
 	}
 
-	date, err := time.Parse("2006-01-02", dateStr)
+	categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid date format", http.StatusBadRequest)
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
 		return
 	}
+	req.CategoryID = categoryID
 
-	categoryID, err := strconv.ParseInt(categoryIDStr, 10, 64)
+	if pid, err := strconv.ParseInt(r.FormValue("provider_id"), 10, 64); err == nil && pid != 0 {
+		req.ProviderID = &pid
+	}
+
+	if id, err := strconv.ParseInt(r.FormValue("extraction_id"), 10, 64); err == nil {
+		req.ExtractionID = &id
+	}
+
+	items, err := expenseItemsFromForm(r)
 	if err != nil {
-		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		http.Redirect(w, r, newExpenseURL(req.ExtractionID, err.Error()), http.StatusSeeOther)
+		return
+	}
+	req.Items = items
+	vat, err := expenseVATFromForm(r)
+	if err != nil {
+		http.Redirect(w, r, newExpenseURL(req.ExtractionID, err.Error()), http.StatusSeeOther)
+		return
+	}
+	req.VAT = vat
+
+	imagePath, err := saveExpenseImage(r)
+	if errors.Is(err, errExpenseImageUpload) {
+		http.Error(w, "File upload error", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.logger.Error("saving expense image", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	req.ImagePath = imagePath
 
-	var providerID *int64
-	if providerIDStr != "" {
-		pid, err := strconv.ParseInt(providerIDStr, 10, 64)
-		if err == nil && pid != 0 {
-			providerID = &pid
+	expense, err := h.expenseService.Create(req)
+	if err != nil {
+		if msg, ok := expenseErrorMessage(err); ok {
+			http.Redirect(w, r, newExpenseURL(req.ExtractionID, msg), http.StatusSeeOther)
+			return
 		}
+		h.logger.Error("creating expense", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	h.audit.Created(r, store.AuditExpense, expense.ID, expense)
+
+	// Redirect back to expenses list (preserving the type filter if possible)
+	http.Redirect(w, r, fmt.Sprintf("/expenses?type=%s&success=%s", req.Type, url.QueryEscape("Gasto registrado exitosamente")), http.StatusSeeOther)
+}
 
-	// Handle File Upload
-	var imagePath string
+var errExpenseImageUpload = errors.New("expense image upload failed")
+
+// saveExpenseImage stores the uploaded "image" file, if any, and returns its
+// path.
+func saveExpenseImage(r *http.Request) (string, error) {
 	file, header, err := r.FormFile("image")
-	if err == nil {
-		defer file.Close()
-		
-		uploadDir := "uploads/expenses"
-		if err := os.MkdirAll(uploadDir, 0755); err != nil {
-			h.logger.Error("failed to create upload directory", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+	if err == http.ErrMissingFile {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errExpenseImageUpload, err)
+	}
+	defer file.Close()
+
+	uploadDir := "uploads/expenses"
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return "", err
+	}
+
+	ext := filepath.Ext(header.Filename)
+	filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	destPath := filepath.Join(uploadDir, filename)
+
+	dst, err := os.Create(destPath)
+	if err != nil {
+		return "", err
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		return "", err
+	}
+	return destPath, nil
+}
+
+// newExpenseURL is the expense form showing msg, still loading from the
+// extraction when there is one.
+func newExpenseURL(extractionID *int64, msg string) string {
+	u := "/expenses/new?error=" + url.QueryEscape(msg)
+	if extractionID != nil {
+		u += fmt.Sprintf("&extraction_id=%d", *extractionID)
+	}
+	return u
+}
+
+// expenseItemsFromForm reads the item rows of the expense form. Rows without
+// ingredient, description and quantity are left out.
+func expenseItemsFromForm(r *http.Request) ([]services.ExpenseItemRequest, error) {
+	ingredientIDs := r.Form["item_ingredient_ids[]"]
+	descriptions := r.Form["item_descriptions[]"]
+	quantities := r.Form["item_quantities[]"]
+	prices := r.Form["item_unit_prices[]"]
+	if len(descriptions) != len(ingredientIDs) || len(quantities) != len(ingredientIDs) || len(prices) != len(ingredientIDs) {
+		return nil, services.ErrInvalidExpenseItem
+	}
+
+	var items []services.ExpenseItemRequest
+	for i := range ingredientIDs {
+		if ingredientIDs[i] == "" && strings.TrimSpace(descriptions[i]) == "" && quantities[i] == "" {
+			continue
+		}
+		item := services.ExpenseItemRequest{Description: descriptions[i]}
+		if ingredientIDs[i] != "" {
+			id, err := strconv.ParseInt(ingredientIDs[i], 10, 64)
+			if err != nil {
+				return nil, services.ErrInvalidExpenseItem
+			}
+			item.IngredientID = &id
 		}
+		qty, err := strconv.ParseFloat(strings.Replace(quantities[i], ",", ".", 1), 64)
+		if err != nil {
+			return nil, services.ErrInvalidExpenseItem
+		}
+		price, err := strconv.ParseFloat(strings.Replace(prices[i], ",", ".", 1), 64)
+		if err != nil {
+			return nil, services.ErrInvalidExpenseItem
+		}
+		item.Quantity = qty
+		item.UnitPrice = price
+		items = append(items, item)
+	}
+	return items, nil
+}
 
-		ext := filepath.Ext(header.Filename)
-		filename := fmt.Sprintf("%s%s", uuid.New().String(), ext)
-		destPath := filepath.Join(uploadDir, filename)
-		
-		dst, err := os.Create(destPath)
+// expenseVATFromForm reads the VAT rows of the expense form. A blank VAT
+// amount is computed from the rate.
+func expenseVATFromForm(r *http.Request) ([]services.ExpenseVATRequest, error) {
+	rates := r.Form["vat_rates[]"]
+	nets := r.Form["vat_nets[]"]
+	amounts := r.Form["vat_amounts[]"]
+	if len(nets) != len(rates) || len(amounts) != len(rates) {
+		return nil, services.ErrInvalidVATAmount
+	}
+
+	var lines []services.ExpenseVATRequest
+	for i := range rates {
+		if nets[i] == "" {
+			continue
+		}
+		rate, err := strconv.ParseFloat(rates[i], 64)
 		if err != nil {
-			h.logger.Error("failed to create file", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+			return nil, services.ErrInvalidVATRate
+		}
+		net, err := strconv.ParseFloat(strings.Replace(nets[i], ",", ".", 1), 64)
+		if err != nil {
+			return nil, services.ErrInvalidVATAmount
+		}
+		line := services.ExpenseVATRequest{Rate: rate, NetAmount: net}
+		if amounts[i] != "" {
+			if line.VATAmount, err = strconv.ParseFloat(strings.Replace(amounts[i], ",", ".", 1), 64); err != nil {
+				return nil, services.ErrInvalidVATAmount
+			}
 		}
-		defer dst.Close()
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
 
-		if _, err := io.Copy(dst, file); err != nil {
-			h.logger.Error("failed to save file", "error", err)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-			return
+// expenseErrorMessage returns the message to show for errors the user can
+// fix in the expense form.
+func expenseErrorMessage(err error) (string, bool) {
+	var dup *services.DuplicateInvoiceError
+	if errors.As(err, &dup) {
+		return dup.Error(), true
+	}
+	for _, known := range []error{
+		services.ErrMissingExpenseFields,
+		services.ErrInvalidExpenseDate,
+		services.ErrInvalidExpenseAmount,
+		services.ErrInvalidInvoiceType,
+		services.ErrInvoiceWithoutProvider,
+		services.ErrInvalidExpenseItem,
+		services.ErrIngredientNotFound,
+		services.ErrInvalidVATRate,
+		services.ErrDuplicateVATRate,
+		services.ErrInvalidVATAmount,
+		services.ErrInvalidDueDate,
+		services.ErrPayableWithoutProvider,
+		services.ErrExtractionNotFound,
+		store.ErrDuplicateExpenseInvoice,
+	} {
+		if errors.Is(err, known) {
+			return known.Error(), true
 		}
-		imagePath = destPath
-	} else if err != http.ErrMissingFile {
-		h.logger.Error("file upload error", "error", err)
-		http.Error(w, "File upload error", http.StatusBadRequest)
-		return
 	}
+	return "", false
+}
 
-	expense := &store.Expense{
-		Amount:     amountStr,
-		CategoryID: categoryID,
-		Type:       store.ExpenseType(typeStr),
-		Date:       date,
-		ProviderID: providerID,
-		ImagePath:  imagePath,
+func (h *WebHandler) HandleExpenseDetailView(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
 	}
 
-	if err := h.expenseStore.CreateExpense(expense); err != nil {
-		h.logger.Error("creating expense", "error", err)
+	expense, err := h.expenseStore.GetExpenseByID(id)
+	if err != nil {
+		h.logger.Error("getting expense", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	if expense == nil {
+		http.Error(w, "Expense not found", http.StatusNotFound)
+		return
+	}
 
-	// Redirect back to expenses list (preserving the type filter if possible)
-	http.Redirect(w, r, fmt.Sprintf("/expenses?type=%s&success=%s", typeStr, url.QueryEscape("Gasto registrado exitosamente")), http.StatusSeeOther)
+	itemsTotal := 0.0
+	for _, item := range expense.Items {
+		itemsTotal += item.Subtotal()
+	}
+	netTotal, vatTotal := 0.0, 0.0
+	for _, v := range expense.VAT {
+		netTotal += v.NetAmount
+		vatTotal += v.VATAmount
+	}
+
+	data := map[string]any{
+		"User":       middleware.GetUser(r),
+		"Expense":    expense,
+		"ItemsTotal": itemsTotal,
+		"NetTotal":   netTotal,
+		"VATTotal":   vatTotal,
+	}
+	if err := h.renderer.Render(w, "expense_detail.html", data); err != nil {
+		h.logger.Error("rendering expense detail", "error", err)
+	}
 }
 
 func (h *WebHandler) HandleDeleteExpense(w http.ResponseWriter, r *http.Request) {
@@ -226,13 +434,28 @@ func (h *WebHandler) HandleDeleteExpense(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.expenseStore.DeleteExpense(id); err != nil {
+	expense, err := h.expenseStore.GetExpenseByID(id)
+	if err != nil {
+		h.logger.Error("getting expense", "error", err)
+		utils.TriggerToast(w, "Error al eliminar gasto", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	err = h.expenseStore.DeleteExpense(id)
+	if errors.Is(err, store.ErrExpenseHasPayments) {
+		utils.TriggerToast(w, "No se puede eliminar un gasto con pagos aplicados", "error")
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	if err != nil {
 		h.logger.Error("deleting expense", "error", err)
 		utils.TriggerToast(w, "Error al eliminar gasto", "error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	h.audit.Deleted(r, store.AuditExpense, id, expense)
 	utils.TriggerToast(w, "Gasto eliminado", "success")
 	w.WriteHeader(http.StatusOK) // HTMX will remove the element
 }
@@ -279,6 +502,7 @@ func (h *WebHandler) HandleQuickCreateExpenseCategory(w http.ResponseWriter, r *
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditExpenseCategory, category.ID, category)
 
 	w.Header().Set("Content-Type", "text/html")
 	html := "<option value=\"" + strconv.FormatInt(category.ID, 10) + "\">" + category.Name + "</option>"
@@ -13,6 +13,7 @@ import (
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/api"
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	"github.com/RamunnoAJ/aesovoy-server/migrations"
@@ -43,7 +44,7 @@ func setupTestDB(t *testing.T) *sql.DB {
 	_, err = db.Exec(`TRUNCATE 
 		expenses, expense_categories, 
 		providers, provider_categories, 
-		shifts, cash_movements, 
+		shifts, cash_movements, cash_registers, shift_handovers,
 		users, tokens,
 		products, categories, ingredients, product_ingredients,
 		local_stock, local_sales, local_sale_items,
@@ -63,9 +64,18 @@ func TestWebHandler_Expenses(t *testing.T) {
 
 	// Create a minimal WebHandler with necessary stores
 	// We only need expenseStore and providerStore for this test
-	webHandler := api.NewWebHandler(
-		nil, nil, nil, nil, nil, nil, providerStore, nil, nil, expenseStore, nil, nil, nil, nil, logger,
-	)
+	ingredientStore := store.NewPostgresIngredientStore(db)
+	extractionStore := store.NewPostgresExpenseExtractionStore(db)
+	expenseService := services.NewExpenseService(db, expenseStore, ingredientStore, extractionStore)
+	extractionService := services.NewExpenseExtractionService(nil, extractionStore, providerStore, "")
+	webHandler := api.NewWebHandler(api.WebHandlerDeps{
+		IngredientStore:   ingredientStore,
+		ProviderStore:     providerStore,
+		ExpenseStore:      expenseStore,
+		ExpenseService:    expenseService,
+		ExtractionService: extractionService,
+		Logger:            logger,
+	})
 
 	// Create a provider category
 	err := providerStore.CreateProviderCategory(&store.ProviderCategory{Name: "General"})
@@ -152,4 +162,41 @@ func TestWebHandler_Expenses(t *testing.T) {
 		require.Contains(t, body, "admin")
 		require.Contains(t, body, "A Eso Voy")
 	})
+
+	t.Run("Rejects Duplicate Invoice", func(t *testing.T) {
+		post := func() *http.Response {
+			body := new(bytes.Buffer)
+			writer := multipart.NewWriter(body)
+			writer.WriteField("date", "2023-12-02")
+			writer.WriteField("type", "production")
+			writer.WriteField("category_id", "1")
+			writer.WriteField("provider_id", "1")
+			writer.WriteField("invoice_number", "0001-00000042")
+			writer.WriteField("invoice_type", "A")
+			writer.WriteField("vat_rates[]", "21")
+			writer.WriteField("vat_nets[]", "1000")
+			writer.WriteField("vat_amounts[]", "")
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/expenses/new", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			req = middleware.SetUser(req, testUser)
+			w := httptest.NewRecorder()
+			webHandler.HandleCreateExpense(w, req)
+			return w.Result()
+		}
+
+		resp := post()
+		require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+		require.Contains(t, resp.Header.Get("Location"), "success=")
+
+		resp = post()
+		require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+		require.Contains(t, resp.Header.Get("Location"), "/expenses/new?error=")
+
+		provider := int64(1)
+		expenses, err := expenseStore.ListExpenses(store.ExpenseFilter{ProviderID: &provider})
+		require.NoError(t, err)
+		require.Len(t, expenses, 2)
+	})
 }
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
@@ -34,6 +37,7 @@ func (h *WebHandler) HandleListIngredients(w http.ResponseWriter, r *http.Reques
 }
 
 func (h *WebHandler) HandleCreateIngredientView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	user := middleware.GetUser(r)
 
 	data := map[string]any{
@@ -52,8 +56,15 @@ func (h *WebHandler) HandleCreateIngredient(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	cost, err := parseIngredientCost(r.FormValue("cost"))
+	if err != nil {
+		http.Redirect(w, r, "/ingredients/new?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
 	ingredient := &store.Ingredient{
 		Name: r.FormValue("name"),
+		Cost: cost,
 	}
 
 	if err := h.ingredientStore.CreateIngredient(ingredient); err != nil {
@@ -61,11 +72,13 @@ func (h *WebHandler) HandleCreateIngredient(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditIngredient, ingredient.ID, ingredient)
 
 	http.Redirect(w, r, "/ingredients?success="+url.QueryEscape("Ingrediente creado exitosamente"), http.StatusSeeOther)
 }
 
 func (h *WebHandler) HandleEditIngredientView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	user := middleware.GetUser(r)
 	ingredientID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -106,16 +119,33 @@ func (h *WebHandler) HandleUpdateIngredient(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	ingredient := &store.Ingredient{
-		ID:   ingredientID,
-		Name: r.FormValue("name"),
+	cost, err := parseIngredientCost(r.FormValue("cost"))
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/ingredients/%d/edit?error=%s", ingredientID, url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
+	}
+
+	ingredient, err := h.ingredientStore.GetIngredientByID(ingredientID)
+	if err != nil {
+		h.logger.Error("getting ingredient", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	if ingredient == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	before := *ingredient
+
+	ingredient.Name = r.FormValue("name")
+	ingredient.Cost = cost
 
 	if err := h.ingredientStore.UpdateIngredient(ingredient); err != nil {
 		h.logger.Error("updating ingredient", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Updated(r, store.AuditIngredient, ingredientID, before, ingredient)
 
 	http.Redirect(w, r, "/ingredients?success="+url.QueryEscape("Ingrediente actualizado correctamente"), http.StatusSeeOther)
 }
@@ -128,13 +158,35 @@ func (h *WebHandler) HandleDeleteIngredient(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	ingredient, err := h.ingredientStore.GetIngredientByID(ingredientID)
+	if err != nil {
+		h.logger.Error("getting ingredient", "error", err)
+		utils.TriggerToast(w, "Error al eliminar ingrediente", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	if err := h.ingredientStore.DeleteIngredient(ingredientID); err != nil {
 		h.logger.Error("deleting ingredient", "error", err)
 		utils.TriggerToast(w, "Error al eliminar ingrediente", "error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Deleted(r, store.AuditIngredient, ingredientID, ingredient)
 
 	utils.TriggerToast(w, "Ingrediente eliminado", "success")
 	w.WriteHeader(http.StatusOK)
 }
+
+// parseIngredientCost reads the optional cost field; empty means no cost.
+func parseIngredientCost(v string) (float64, error) {
+	v = strings.TrimSpace(strings.ReplaceAll(v, ",", "."))
+	if v == "" {
+		return 0, nil
+	}
+	cost, err := strconv.ParseFloat(v, 64)
+	if err != nil || cost < 0 {
+		return 0, errors.New("El costo debe ser un número mayor o igual a 0")
+	}
+	return cost, nil
+}
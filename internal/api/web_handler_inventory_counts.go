@@ -0,0 +1,242 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	chi "github.com/go-chi/chi/v5"
+)
+
+const inventoryCountsPageSize = 20
+
+func (h *WebHandler) HandleListInventoryCounts(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	counts, err := h.inventoryService.ListCounts(inventoryCountsPageSize+1, (page-1)*inventoryCountsPageSize)
+	if err != nil {
+		h.logger.Error("listing inventory counts", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	hasNext := len(counts) > inventoryCountsPageSize
+	if hasNext {
+		counts = counts[:inventoryCountsPageSize]
+	}
+
+	open, err := h.inventoryService.GetOpenCount()
+	if err != nil {
+		h.logger.Error("getting open inventory count", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	locations, err := h.locationService.ListLocations(true)
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Counts":    counts,
+		"OpenCount": open,
+		"Locations": locations,
+		"Page":      page,
+		"HasNext":   hasNext,
+		"NextPage":  page + 1,
+		"PrevPage":  page - 1,
+	}
+
+	if err := h.renderer.Render(w, "inventory_counts_list.html", data); err != nil {
+		h.logger.Error("rendering inventory counts", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleStartInventoryCount(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	locationID, _ := strconv.ParseInt(r.FormValue("location_id"), 10, 64)
+	count, err := h.inventoryService.StartCount(user.ID, locationID, r.FormValue("notes"))
+	if err != nil {
+		h.logger.Error("starting inventory count", "error", err)
+		http.Redirect(w, r, "/inventory-counts?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	h.audit.Created(r, store.AuditInventoryCount, count.ID, count)
+
+	http.Redirect(w, r, fmt.Sprintf("/inventory-counts/%d?success=%s", count.ID, url.QueryEscape("Conteo iniciado")), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleInventoryCountView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.inventoryService.GetCount(id)
+	if errors.Is(err, services.ErrInventoryCountNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting inventory count", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var counted int
+	var varianceValue float64
+	for _, item := range count.Items {
+		if item.Counted != nil {
+			counted++
+			varianceValue += inventoryVarianceValue(item)
+		}
+	}
+
+	data := map[string]any{
+		"User":          user,
+		"Count":         count,
+		"CanManage":     user.Can(store.PermInventoryManage),
+		"Open":          count.Status == store.InventoryCountOpen,
+		"CountedItems":  counted,
+		"VarianceValue": varianceValue,
+	}
+
+	if err := h.renderer.Render(w, "inventory_count_detail.html", data); err != nil {
+		h.logger.Error("rendering inventory count", "error", err)
+	}
+}
+
+// HandleRecordInventoryCount saves one counted quantity and returns the
+// updated row. An empty value clears the entry.
+func (h *WebHandler) HandleRecordInventoryCount(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	countID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	productID, err := strconv.ParseInt(chi.URLParam(r, "product_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	var counted *float64
+	if v := strings.TrimSpace(strings.ReplaceAll(r.FormValue("counted"), ",", ".")); v != "" {
+		qty, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			utils.TriggerToast(w, "Cantidad inválida", "error")
+			http.Error(w, "Invalid quantity", http.StatusBadRequest)
+			return
+		}
+		counted = &qty
+	}
+
+	item, err := h.inventoryService.RecordCount(countID, productID, counted, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInventoryCountNotFound), errors.Is(err, services.ErrInventoryCountItem),
+			errors.Is(err, services.ErrProductNotFound):
+			utils.TriggerToast(w, err.Error(), "error")
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, services.ErrInventoryCountClosed), errors.Is(err, services.ErrInvalidCountedQuantity),
+			errors.Is(err, services.ErrFractionalQuantity):
+			utils.TriggerToast(w, err.Error(), "error")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			h.logger.Error("recording inventory count", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	data := map[string]any{
+		"Item":      item,
+		"CountID":   countID,
+		"Open":      true,
+		"CanManage": user.Can(store.PermInventoryManage),
+	}
+	if err := h.renderer.RenderBlock(w, "inventory_count_detail.html", "count_item_row", data); err != nil {
+		h.logger.Error("rendering inventory count row", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleApproveInventoryCount(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	redirect := fmt.Sprintf("/inventory-counts/%d", id)
+	count, err := h.inventoryService.ApproveCount(id, user.ID)
+	if err != nil {
+		h.logger.Error("approving inventory count", "error", err)
+		http.Redirect(w, r, redirect+"?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditInventoryCount, id, "approve", nil, count)
+
+	http.Redirect(w, r, redirect+"?success="+url.QueryEscape("Conteo aprobado, stock ajustado"), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleCancelInventoryCount(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.inventoryService.CancelCount(id, user.ID); err != nil {
+		h.logger.Error("cancelling inventory count", "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/inventory-counts/%d?error=%s", id, url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditInventoryCount, id, "cancel", nil, nil)
+
+	http.Redirect(w, r, "/inventory-counts?success="+url.QueryEscape("Conteo cancelado"), http.StatusSeeOther)
+}
+
+// inventoryVarianceValue prices a count variance at the retail price. Prices
+// of products sold by weight are per kg.
+func inventoryVarianceValue(item *store.InventoryCountItem) float64 {
+	variance := item.Variance()
+	if item.SaleUnit == store.SaleUnitGram {
+		variance /= 1000
+	}
+	return variance * item.UnitPrice
+}
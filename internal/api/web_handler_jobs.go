@@ -0,0 +1,102 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+// --- Background Jobs ---
+
+type jobKindOption struct {
+	Value string
+	Label string
+}
+
+var jobKindOptions = []jobKindOption{
+	{store.JobGenerateInvoice, "Remitos de pedidos"},
+	{store.JobSendEmail, "Emails"},
+	{store.JobDeliverWebhooks, "Envío de webhooks"},
+	{store.JobCleanupJobs, "Limpieza de trabajos"},
+}
+
+func (h *WebHandler) HandleListJobs(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	q := r.URL.Query()
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit := 50
+	filter := store.JobFilter{
+		Kind:   q.Get("kind"),
+		Status: q.Get("status"),
+		Limit:  limit,
+		Offset: (page - 1) * limit,
+	}
+
+	jobs, total, err := h.jobStore.List(filter)
+	if err != nil {
+		h.logger.Error("listing jobs", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	counts, err := h.jobStore.CountByStatus()
+	if err != nil {
+		h.logger.Error("counting jobs", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// The filters without the page, to build the pagination links.
+	q.Del("page")
+
+	data := map[string]any{
+		"User":     middleware.GetUser(r),
+		"Jobs":     jobs,
+		"Total":    total,
+		"Counts":   counts,
+		"Kinds":    jobKindOptions,
+		"Filter":   filter,
+		"Query":    template.URL(q.Encode()),
+		"Page":     page,
+		"HasNext":  page*limit < total,
+		"NextPage": page + 1,
+		"PrevPage": page - 1,
+	}
+	if err := h.renderer.Render(w, "jobs_list.html", data); err != nil {
+		h.logger.Error("rendering jobs list", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleRetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.jobStore.Retry(id); err != nil {
+		msg := "Error al reintentar el trabajo"
+		if errors.Is(err, sql.ErrNoRows) {
+			msg = "El trabajo no existe o no está fallido"
+		} else if errors.Is(err, store.ErrJobAlreadyQueued) {
+			msg = "Ya hay un trabajo igual en cola"
+		} else {
+			h.logger.Error("retrying job", "id", id, "error", err)
+		}
+		http.Redirect(w, r, "/jobs?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditJob, id, "retry", nil, nil)
+
+	http.Redirect(w, r, "/jobs?success="+url.QueryEscape("Trabajo encolado de nuevo"), http.StatusSeeOther)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -8,7 +9,9 @@ import (
 	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/receipt"
 	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	chi "github.com/go-chi/chi/v5"
 )
@@ -16,11 +19,6 @@ import (
 func (h *WebHandler) HandleListLocalSales(w http.ResponseWriter, r *http.Request) {
 	h.triggerMessages(w, r)
 	user := middleware.GetUser(r)
-	// Employee or Admin
-	if user.Role != "administrator" && user.Role != "employee" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
 
 	dateStr := r.URL.Query().Get("date")
 	targetDate := time.Now()
@@ -78,11 +76,8 @@ func (h *WebHandler) HandleListLocalSales(w http.ResponseWriter, r *http.Request
 }
 
 func (h *WebHandler) HandleCreateLocalSaleView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	user := middleware.GetUser(r)
-	if user.Role != "administrator" && user.Role != "employee" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
 
 	products, _ := h.productStore.GetAllProduct()
 	pMethods, _ := h.paymentMethodStore.GetAllPaymentMethods()
@@ -92,6 +87,7 @@ func (h *WebHandler) HandleCreateLocalSaleView(w http.ResponseWriter, r *http.Re
 		"Products":       products,
 		"PaymentMethods": pMethods,
 	}
+	h.addPOSOperator(data, "/local-sales/new")
 
 	if err := h.renderer.Render(w, "local_sale_form.html", data); err != nil {
 		h.logger.Error("rendering local sale form", "error", err)
@@ -100,10 +96,6 @@ func (h *WebHandler) HandleCreateLocalSaleView(w http.ResponseWriter, r *http.Re
 
 func (h *WebHandler) HandleCreateLocalSale(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
-	if user.Role != "administrator" && user.Role != "employee" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
 
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
@@ -114,14 +106,21 @@ func (h *WebHandler) HandleCreateLocalSale(w http.ResponseWriter, r *http.Reques
 
 	productIDs := r.PostForm["product_ids[]"]
 	quantities := r.PostForm["quantities[]"]
+	barcodes := r.PostForm["barcodes[]"]
 
 	var items []services.CreateLocalSaleItem
 	for i, pidStr := range productIDs {
 		pid, _ := strconv.ParseInt(pidStr, 10, 64)
-		qty, _ := strconv.Atoi(quantities[i])
-		if pid > 0 && qty > 0 {
+		qty, _ := strconv.ParseFloat(quantities[i], 64)
+		// Weighed labels send their code so the price is taken from it.
+		var barcode string
+		if i < len(barcodes) {
+			barcode = barcodes[i]
+		}
+		if (pid > 0 || barcode != "") && qty > 0 {
 			items = append(items, services.CreateLocalSaleItem{
 				ProductID: pid,
+				Barcode:   barcode,
 				Quantity:  qty,
 			})
 		}
@@ -130,9 +129,19 @@ func (h *WebHandler) HandleCreateLocalSale(w http.ResponseWriter, r *http.Reques
 	req := services.CreateLocalSaleRequest{
 		PaymentMethodID: pmID,
 		Items:           items,
+		UserID:          user.ID,
+	}
+
+	// Attach the sale to this device's drawer when it has an open session.
+	if registerID, err := h.currentRegisterID(r); err != nil {
+		h.logger.Error("getting current cash register", "error", err)
+	} else if registerID != 0 {
+		if shift, err := h.shiftService.GetCurrentShift(registerID); err == nil && shift != nil {
+			req.RegisterID = registerID
+		}
 	}
 
-	_, err := h.localSaleService.CreateLocalSale(req)
+	sale, err := h.localSaleService.CreateLocalSale(req)
 	if err != nil {
 		h.logger.Error("creating local sale", "error", err)
 		msg := err.Error()
@@ -142,16 +151,33 @@ func (h *WebHandler) HandleCreateLocalSale(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	h.audit.Created(r, store.AuditLocalSale, sale.ID, sale)
+
 	http.Redirect(w, r, "/local-sales?success="+url.QueryEscape("Venta registrada correctamente"), http.StatusSeeOther)
 }
 
-func (h *WebHandler) HandleGetLocalSaleView(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user.Role != "administrator" && user.Role != "employee" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+// HandleLookupLocalSaleCode resolves a scanned code for the sale form.
+func (h *WebHandler) HandleLookupLocalSaleCode(w http.ResponseWriter, r *http.Request) {
+	scan, err := h.localSaleService.LookupCode(r.URL.Query().Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBarcodeUnknown):
+			utils.Error(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrBarcodeEmpty), errors.Is(err, services.ErrBarcodeInvalid):
+			utils.Error(w, http.StatusBadRequest, err.Error())
+		default:
+			h.logger.Error("looking up code", "error", err)
+			utils.Error(w, http.StatusInternalServerError, "Error al buscar el código")
+		}
 		return
 	}
 
+	utils.OK(w, http.StatusOK, utils.Envelope{"scan": scan}, "", nil)
+}
+
+func (h *WebHandler) HandleGetLocalSaleView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid ID", http.StatusBadRequest)
@@ -189,7 +215,8 @@ func (h *WebHandler) HandleGetLocalSaleView(w http.ResponseWriter, r *http.Reque
 
 	type ItemView struct {
 		ProductName  string
-		Quantity     int
+		SaleUnit     string
+		Quantity     float64
 		UnitPrice    string
 		LineSubtotal string
 	}
@@ -197,11 +224,14 @@ func (h *WebHandler) HandleGetLocalSaleView(w http.ResponseWriter, r *http.Reque
 	var itemViews []ItemView
 	for _, item := range sale.Items {
 		pName := "Unknown Product"
+		saleUnit := store.SaleUnitUnit
 		if p, ok := products[item.ProductID]; ok {
 			pName = p.Name
+			saleUnit = p.SaleUnit
 		}
 		itemViews = append(itemViews, ItemView{
 			ProductName:  pName,
+			SaleUnit:     saleUnit,
 			Quantity:     item.Quantity,
 			UnitPrice:    item.UnitPrice,
 			LineSubtotal: item.LineSubtotal,
@@ -232,6 +262,7 @@ func (h *WebHandler) HandleGetLocalSaleView(w http.ResponseWriter, r *http.Reque
 		"Sale":              saleView,
 		"PaymentMethodName": pmName,
 		"BackDate":          backDate,
+		"HasPrinter":        h.receiptService.HasPrinter(),
 	}
 
 	if err := h.renderer.Render(w, "local_sale_detail.html", data); err != nil {
@@ -239,14 +270,59 @@ func (h *WebHandler) HandleGetLocalSaleView(w http.ResponseWriter, r *http.Reque
 	}
 }
 
-func (h *WebHandler) HandleRevokeLocalSale(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	// Employee or Admin
-	if user.Role != "administrator" && user.Role != "employee" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+// HandleLocalSaleReceipt shows the printable ticket of a sale, used when
+// there is no thermal printer or to save it as PDF.
+func (h *WebHandler) HandleLocalSaleReceipt(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	rc, err := h.receiptService.BuildReceipt(id)
+	if err != nil {
+		if errors.Is(err, services.ErrSaleNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("building receipt", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := receipt.HTML(w, rc); err != nil {
+		h.logger.Error("rendering receipt", "error", err)
+	}
+}
+
+func (h *WebHandler) HandlePrintLocalSaleReceipt(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.TriggerToast(w, "ID de venta inválido", "error")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.receiptService.PrintReceipt(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, services.ErrSaleNotFound), errors.Is(err, services.ErrPrinterNotConfigured):
+			utils.TriggerToast(w, err.Error(), "error")
+		default:
+			h.logger.Error("printing receipt", "error", err)
+			utils.TriggerToast(w, "No se pudo imprimir el ticket, verificá la impresora", "error")
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	utils.TriggerToast(w, "Ticket enviado a la impresora", "success")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *WebHandler) HandleRevokeLocalSale(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
 	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		utils.TriggerToast(w, "ID de venta inválido", "error")
@@ -255,8 +331,8 @@ func (h *WebHandler) HandleRevokeLocalSale(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// If employee, check time limit
-	if user.Role == "employee" {
+	// Without sales.void a sale can only be voided within its first hour.
+	if !user.Can(store.PermSalesVoid) {
 		sale, err := h.localSaleService.GetSale(id)
 		if err != nil {
 			utils.TriggerToast(w, "Error al verificar la venta", "error")
@@ -279,7 +355,7 @@ func (h *WebHandler) HandleRevokeLocalSale(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	if err := h.localSaleService.RevokeLocalSale(id); err != nil {
+	if err := h.localSaleService.RevokeLocalSale(id, user.ID); err != nil {
 		h.logger.Error("revoking local sale", "error", err)
 		utils.TriggerToast(w, "Error al anular venta: "+err.Error(), "error")
 		w.WriteHeader(http.StatusInternalServerError)
@@ -287,6 +363,8 @@ func (h *WebHandler) HandleRevokeLocalSale(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	h.audit.Record(r, store.AuditLocalSale, id, "void", nil, nil)
+
 	utils.TriggerToast(w, "Venta anulada correctamente", "success")
 
 	// Fetch updated sale to render the row
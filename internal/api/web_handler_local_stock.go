@@ -1,22 +1,23 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	chi "github.com/go-chi/chi/v5"
 )
 
 func (h *WebHandler) HandleUpdateLocalStock(w http.ResponseWriter, r *http.Request) {
 	// Used by HTMX to update stock
 	user := middleware.GetUser(r)
-	if user.Role != "administrator" && user.Role != "employee" {
-		utils.TriggerToast(w, "No tienes permiso para esta acción", "error")
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
 
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
@@ -25,11 +26,12 @@ func (h *WebHandler) HandleUpdateLocalStock(w http.ResponseWriter, r *http.Reque
 
 	pidStr := r.FormValue("product_id")
 	pid, _ := strconv.ParseInt(pidStr, 10, 64)
+	locationID, _ := strconv.ParseInt(r.FormValue("location_id"), 10, 64)
 
 	// Check if we are setting absolute quantity or delta
 	newQtyStr := r.FormValue("new_quantity")
 	
-	stock, err := h.localStockService.GetStock(pid)
+	stock, err := h.localStockService.GetStock(pid, locationID)
 	if err != nil {
 		h.logger.Error("getting stock", "error", err)
 		utils.TriggerToast(w, "Error al obtener stock", "error")
@@ -37,32 +39,48 @@ func (h *WebHandler) HandleUpdateLocalStock(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	var delta int
+	var delta float64
 
 	if newQtyStr != "" {
 		// Absolute Set
-		newQty, _ := strconv.Atoi(newQtyStr)
-		currentQty := 0
+		newQty, _ := strconv.ParseFloat(newQtyStr, 64)
+		currentQty := 0.0
 		if stock != nil {
 			currentQty = stock.Quantity
 		}
-		delta = newQty - currentQty
+		delta = services.RoundQuantity(newQty - currentQty)
 	} else {
 		// Relative Delta
 		deltaStr := r.FormValue("delta")
-		delta, _ = strconv.Atoi(deltaStr)
+		delta, _ = strconv.ParseFloat(deltaStr, 64)
 	}
 
-	if stock == nil {
-		initialQty := delta 
-		if initialQty < 0 {
-			utils.TriggerToast(w, "No se puede reducir stock de 0", "error")
-			http.Error(w, "Cannot decrease 0 stock", http.StatusBadRequest)
-			return
-		}
-		_, err = h.localStockService.CreateInitialStock(pid, initialQty)
+	if stock == nil && delta < 0 {
+		utils.TriggerToast(w, "No se puede reducir stock de 0", "error")
+		http.Error(w, "Cannot decrease 0 stock", http.StatusBadRequest)
+		return
+	}
+
+	var updated *store.LocalStock
+	if stock == nil && locationID == 0 {
+		updated, err = h.localStockService.CreateInitialStock(pid, delta, user.ID)
 	} else {
-		_, err = h.localStockService.AdjustStock(pid, delta)
+		change := store.StockChange{
+			LocationID: locationID,
+			Reason:     r.FormValue("reason"),
+			UserID:     user.ID,
+			Note:       strings.TrimSpace(r.FormValue("note")),
+		}
+		// Every production entry is a new lot.
+		if change.Reason == store.MovementProduction && delta > 0 {
+			change.Lot, err = parseLot(r.FormValue("lot_number"), r.FormValue("produced_on"), r.FormValue("best_before"))
+			if err != nil {
+				utils.TriggerToast(w, "Error: "+err.Error(), "error")
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		updated, err = h.localStockService.AdjustStock(pid, delta, change)
 	}
 
 	if err != nil {
@@ -71,6 +89,13 @@ func (h *WebHandler) HandleUpdateLocalStock(w http.ResponseWriter, r *http.Reque
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if stock == nil {
+		h.audit.Created(r, store.AuditLocalStock, pid, updated)
+	} else {
+		h.audit.Updated(r, store.AuditLocalStock, pid,
+			map[string]any{"quantity": stock.Quantity},
+			map[string]any{"quantity": updated.Quantity, "location_id": updated.LocationID, "reason": r.FormValue("reason"), "note": r.FormValue("note")})
+	}
 
 	// Redirect based on input or default to local-stock list
 	redirectTo := r.FormValue("redirect_to")
@@ -80,3 +105,131 @@ func (h *WebHandler) HandleUpdateLocalStock(w http.ResponseWriter, r *http.Reque
 
 	http.Redirect(w, r, redirectTo+"?success="+url.QueryEscape("Stock actualizado"), http.StatusSeeOther)
 }
+
+var movementReasonLabels = map[string]string{
+	store.MovementInitial:    "Saldo inicial",
+	store.MovementSale:       "Venta",
+	store.MovementVoid:       "Anulación",
+	store.MovementAdjustment: "Ajuste manual",
+	store.MovementProduction: "Producción",
+	store.MovementWaste:      "Merma",
+	store.MovementTransfer:   "Transferencia",
+	store.MovementCount:      "Conteo",
+}
+
+// HandleStockMovementsView lists a product's stock movements with running
+// balances and reconstructs its stock at a past date.
+func (h *WebHandler) HandleStockMovementsView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil {
+		h.logger.Error("getting product", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if product == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	from, to, err := parseMovementRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		utils.TriggerToast(w, "Rango de fechas inválido", "error")
+		from, to, _ = parseMovementRange("", "")
+	}
+
+	locations, err := h.locationService.ListLocations(false)
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	locationID, _ := strconv.ParseInt(q.Get("location_id"), 10, 64)
+
+	movements, opening, err := h.localStockService.ListMovements(productID, locationID, from, to)
+	if err != nil {
+		h.logger.Error("listing stock movements", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	type MovementView struct {
+		Date      string
+		Reason    string
+		Reference string
+		Link      string
+		User      string
+		Note      string
+		Delta     float64
+		Balance   float64
+	}
+
+	var views []MovementView
+	for _, m := range movements {
+		v := MovementView{
+			Date:    m.CreatedAt.Format("02/01/2006 15:04"),
+			Reason:  movementReasonLabels[m.Reason],
+			User:    m.Username,
+			Note:    m.Note,
+			Delta:   m.Delta,
+			Balance: m.Balance,
+		}
+		v.Reference, v.Link = movementReference(m.ReferenceType, m.ReferenceID, m.ProductID, m.CreatedAt)
+		views = append(views, v)
+	}
+
+	data := map[string]any{
+		"User":           user,
+		"Product":        product,
+		"Movements":      views,
+		"OpeningBalance": opening,
+		"Locations":      locations,
+		"LocationID":     locationID,
+		"From":           from.Format("2006-01-02"),
+		"To":             to.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+
+	if atStr := q.Get("at"); atStr != "" {
+		if at, err := parseStockInstant(atStr); err == nil {
+			stockAt, err := h.localStockService.StockAt(productID, locationID, at)
+			if err != nil {
+				h.logger.Error("reconstructing stock", "error", err)
+			} else {
+				data["At"] = atStr
+				data["StockAt"] = stockAt
+			}
+		}
+	}
+
+	if err := h.renderer.Render(w, "stock_movements.html", data); err != nil {
+		h.logger.Error("rendering stock movements", "error", err)
+	}
+}
+
+// movementReference names the document behind a stock movement and links to
+// it. Both are empty for movements without one.
+func movementReference(refType *string, refID *int64, productID int64, at time.Time) (string, string) {
+	if refType == nil || refID == nil {
+		return "", ""
+	}
+	switch *refType {
+	case store.ReferenceLocalSale:
+		return fmt.Sprintf("Venta #%d", *refID), fmt.Sprintf("/local-sales/%d", *refID)
+	case store.ReferenceInventoryCount:
+		return fmt.Sprintf("Conteo #%d", *refID), fmt.Sprintf("/inventory-counts/%d", *refID)
+	case store.ReferenceStockTransfer:
+		return fmt.Sprintf("Remito interno #%d", *refID), fmt.Sprintf("/stock-transfers/%d", *refID)
+	case store.ReferenceWaste:
+		day := at.Format("2006-01-02")
+		return fmt.Sprintf("Merma #%d", *refID), fmt.Sprintf("/waste?product_id=%d&from=%s&to=%s", productID, day, day)
+	}
+	return "", ""
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+var errInvalidLotDate = errors.New("fecha de lote inválida, use AAAA-MM-DD")
+
+// parseLot reads the lot of a production entry. Empty fields are filled in
+// when the lot is created: a generated number and today as production date.
+func parseLot(number, producedOn, bestBefore string) (*store.NewLot, error) {
+	lot := &store.NewLot{Number: strings.TrimSpace(number)}
+	if v := strings.TrimSpace(producedOn); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, errInvalidLotDate
+		}
+		lot.ProducedOn = d
+	}
+	if v := strings.TrimSpace(bestBefore); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, errInvalidLotDate
+		}
+		lot.BestBefore = &d
+	}
+	return lot, nil
+}
+
+// LotView is a lot with its expiry spelled out for the templates.
+type LotView struct {
+	*store.StockLot
+	ExpiryLabel string
+	Expired     bool
+	Expiring    bool
+}
+
+func newLotView(lot *store.StockLot, now time.Time) LotView {
+	v := LotView{StockLot: lot}
+	days, ok := lot.DaysToExpiry(now)
+	if !ok {
+		return v
+	}
+	switch {
+	case days < 0:
+		v.ExpiryLabel, v.Expired = "Vencido", true
+	case days == 0:
+		v.ExpiryLabel, v.Expiring = "Vence hoy", true
+	case days == 1:
+		v.ExpiryLabel, v.Expiring = "Vence mañana", true
+	}
+	return v
+}
+
+// HandleProductLotsView lists a product's lots in the order sales take them.
+func (h *WebHandler) HandleProductLotsView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil {
+		h.logger.Error("getting product", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if product == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	showAll := r.URL.Query().Get("all") == "1"
+	lots, err := h.localStockService.ListLots(productID, showAll)
+	if err != nil {
+		h.logger.Error("listing lots", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	stocks, err := h.localStockService.StockByLocation(productID)
+	if err != nil {
+		h.logger.Error("getting stock", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	var views []LotView
+	var inLots float64
+	for _, lot := range lots {
+		views = append(views, newLotView(lot, now))
+		inLots += lot.Quantity
+	}
+	var total float64
+	for _, stock := range stocks {
+		total += stock.Quantity
+	}
+	untracked := services.RoundQuantity(total - inLots)
+
+	data := map[string]any{
+		"User":      user,
+		"Product":   product,
+		"Lots":      views,
+		"ShowAll":   showAll,
+		"Untracked": untracked,
+	}
+
+	if err := h.renderer.Render(w, "product_lots.html", data); err != nil {
+		h.logger.Error("rendering product lots", "error", err)
+	}
+}
+
+// HandleLotView shows a lot and every movement that touched it, so the
+// sales and waste it went to can be traced.
+func (h *WebHandler) HandleLotView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	lot, movements, err := h.localStockService.GetLot(id)
+	if errors.Is(err, services.ErrLotNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting lot", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	type MovementView struct {
+		Date      string
+		Reason    string
+		Reference string
+		Link      string
+		User      string
+		Quantity  float64
+	}
+
+	var views []MovementView
+	for _, m := range movements {
+		v := MovementView{
+			Date:     m.CreatedAt.Format("02/01/2006 15:04"),
+			Reason:   movementReasonLabels[m.Reason],
+			User:     m.Username,
+			Quantity: m.Quantity,
+		}
+		v.Reference, v.Link = movementReference(m.ReferenceType, m.ReferenceID, lot.ProductID, m.CreatedAt)
+		views = append(views, v)
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Lot":       newLotView(lot, time.Now()),
+		"Movements": views,
+	}
+
+	if err := h.renderer.Render(w, "lot_detail.html", data); err != nil {
+		h.logger.Error("rendering lot", "error", err)
+	}
+}
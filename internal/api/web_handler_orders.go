@@ -6,7 +6,6 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/RamunnoAJ/aesovoy-server/internal/billing"
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
@@ -123,6 +122,19 @@ func (h *WebHandler) HandleUpdateOrderState(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	order, err := h.orderStore.GetOrderByID(orderID)
+	if err != nil {
+		h.logger.Error("getting order", "error", err)
+		utils.TriggerToast(w, "Error al actualizar estado: "+err.Error(), "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if order == nil {
+		utils.TriggerToast(w, "Orden no encontrada", "error")
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
 	if err := h.orderStore.UpdateOrderState(orderID, state, nil); err != nil {
 		h.logger.Error("updating order state", "error", err)
 		utils.TriggerToast(w, "Error al actualizar estado: "+err.Error(), "error")
@@ -130,6 +142,9 @@ func (h *WebHandler) HandleUpdateOrderState(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	h.audit.Record(r, store.AuditOrder, orderID, store.AuditState,
+		map[string]any{"state": order.State}, map[string]any{"state": state})
+
 	// Trigger a success toast
 	utils.TriggerToast(w, "Estado de orden actualizado correctamente", "success")
 	w.Header().Set("HX-Refresh", "true")
@@ -151,12 +166,23 @@ func (h *WebHandler) HandleMarkOrderPaid(w http.ResponseWriter, r *http.Request)
 		pmIDPtr = &pmID
 	}
 
+	order, err := h.orderStore.GetOrderByID(orderID)
+	if err != nil || order == nil {
+		h.logger.Error("getting order", "error", err)
+		utils.TriggerToast(w, "Error al marcar como pagada", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	if err := h.orderStore.UpdateOrderState(orderID, store.OrderPaid, pmIDPtr); err != nil {
 		h.logger.Error("marking order paid", "error", err)
 		utils.TriggerToast(w, "Error al marcar como pagada", "error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Record(r, store.AuditOrder, orderID, store.AuditState,
+		map[string]any{"state": order.State, "payment_method_id": order.PaymentMethodID},
+		map[string]any{"state": store.OrderPaid, "payment_method_id": pmIDPtr})
 
 	utils.TriggerToast(w, "Orden marcada como pagada", "success")
 	w.Header().Set("HX-Refresh", "true")
@@ -224,7 +250,6 @@ func (h *WebHandler) HandleCreateOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var items []store.OrderItem
-	var itemProductIDs []int64 // For invoice generation
 
 	for i, pidStr := range productIDs {
 		pid, _ := strconv.ParseInt(pidStr, 10, 64)
@@ -237,7 +262,6 @@ func (h *WebHandler) HandleCreateOrder(w http.ResponseWriter, r *http.Request) {
 				Quantity:  qty,
 				Price:     price,
 			})
-			itemProductIDs = append(itemProductIDs, pid)
 		}
 	}
 
@@ -252,24 +276,7 @@ func (h *WebHandler) HandleCreateOrder(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-
-	go func() {
-		client, err := h.clientStore.GetClientByID(clientID)
-		if err != nil || client == nil {
-			h.logger.Error("invoice gen: client not found", "error", err)
-			return
-		}
-
-		products, err := h.productStore.GetProductsByIDs(itemProductIDs)
-		if err != nil {
-			h.logger.Error("invoice gen: products error", "error", err)
-			return
-		}
-
-		if err := billing.GenerateInvoice(order, client, products); err != nil {
-			h.logger.Error("generating invoice", "error", err)
-		}
-	}()
+	h.audit.Created(r, store.AuditOrder, order.ID, order)
 
 	http.Redirect(w, r, "/orders?success="+url.QueryEscape("Orden creada exitosamente"), http.StatusSeeOther)
 }
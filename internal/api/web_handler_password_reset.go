@@ -1,10 +1,9 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
-	"time"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
 )
 
@@ -32,6 +31,14 @@ func (h *WebHandler) HandleSendPasswordResetEmail(w http.ResponseWriter, r *http
 		return
 	}
 
+	var userID *int64
+	if user != nil {
+		userID = &user.ID
+	}
+	if err := h.loginSecurity.Record(userID, store.SecurityPasswordResetRequested, loginAttempt(r, email), ""); err != nil {
+		h.logger.Error("recording security event", "error", err)
+	}
+
 	// If user not found, we pretend we sent it to avoid enumeration attacks
 	if user == nil {
 		data := map[string]any{
@@ -41,46 +48,25 @@ func (h *WebHandler) HandleSendPasswordResetEmail(w http.ResponseWriter, r *http
 		return
 	}
 
-	token, err := tokens.GenerateToken(int(user.ID), 1*time.Hour, tokens.ScopePasswordReset)
-	if err != nil {
-		h.logger.Error("generating token", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	err = h.tokenStore.Insert(token)
-	if err != nil {
-		h.logger.Error("inserting token", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	// Send email
-	// Construct link. Assuming localhost:8080 for now, but should be config.
-	// Ideally, we should get the base URL from config or request.
-	host := r.Host
+	// The link points back to the host the request came to. In production,
+	// it might be behind a proxy.
 	scheme := "http"
 	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
 		scheme = "https"
 	}
 
-	// For development, if host is localhost:8080, scheme is http.
-	// In production, it might be behind a proxy.
-	link := fmt.Sprintf("%s://%s/reset-password?token=%s", scheme, host, token.Plaintext)
-
-	data := struct {
-		Link string
-	}{
-		Link: link,
+	// Queued with the user only: the job creates the token and sends the
+	// link, so it never sits in the payload.
+	job, err := store.NewJob(store.JobSendPasswordReset, store.PasswordResetJob{
+		UserID:  user.ID,
+		BaseURL: scheme + "://" + r.Host,
+	})
+	if err == nil {
+		_, err = h.jobStore.Enqueue(job)
+	}
+	if err != nil {
+		h.logger.Error("queueing password reset email", "error", err)
 	}
-
-	// Run in background to not block response
-	go func() {
-		err = h.mailer.Send(user.Email, "password_reset.tmpl", data)
-		if err != nil {
-			h.logger.Error("sending password reset email", "error", err)
-		}
-	}()
 
 	renderData := map[string]any{
 		"Success": "Si el correo electrónico existe, recibirás un enlace para restablecer tu contraseña.",
@@ -160,6 +146,10 @@ func (h *WebHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Record(r, store.AuditUser, user.ID, "password_reset", nil, nil)
+	if err := h.loginSecurity.PasswordReset(user, loginAttempt(r, user.Username)); err != nil {
+		h.logger.Error("recording password reset", "error", err)
+	}
 
 	// Delete all password reset tokens for this user to prevent reuse?
 	// Or just this one? The store `GetUserToken` checks expiry.
@@ -169,6 +159,10 @@ func (h *WebHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request)
 		h.logger.Error("deleting tokens", "error", err)
 		// Non-critical error
 	}
+	// Whoever had the old password is logged out everywhere.
+	if err := h.tokenStore.DeleteAllTokensForUser(int(user.ID), tokens.ScopeAuth); err != nil {
+		h.logger.Error("revoking sessions after password reset", "error", err)
+	}
 
 	// Render success or redirect to login
 	// Since we are using HTMX for form submission, we can redirect using HX-Redirect
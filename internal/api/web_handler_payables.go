@@ -0,0 +1,228 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// --- Accounts Payable ---
+
+func (h *WebHandler) HandlePayablesView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	q := r.URL.Query()
+	var providerID *int64
+	if id, err := strconv.ParseInt(q.Get("provider_id"), 10, 64); err == nil && id > 0 {
+		providerID = &id
+	}
+	var until *time.Time
+	if d, err := time.Parse("2006-01-02", q.Get("until")); err == nil {
+		until = &d
+	}
+
+	payables, err := h.payablesService.Payables(providerID, until)
+	if err != nil {
+		h.logger.Error("listing payables", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	balances, err := h.payablesService.Balances()
+	if err != nil {
+		h.logger.Error("listing provider balances", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var selected int64
+	if providerID != nil {
+		selected = *providerID
+	}
+	data := map[string]any{
+		"User":            middleware.GetUser(r),
+		"Payables":        payables,
+		"ExpenseBalances": expenseBalances(payables.Items),
+		"Balances":        balances,
+		"ProviderID":      selected,
+		"Until":           q.Get("until"),
+	}
+
+	if err := h.renderer.Render(w, "payables.html", data); err != nil {
+		h.logger.Error("rendering payables", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleProviderStatementView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	q := r.URL.Query()
+	from, to, err := parseMovementRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		utils.TriggerToast(w, "Rango de fechas inválido", "error")
+		from, to, _ = parseMovementRange("", "")
+	}
+
+	statement, err := h.payablesService.Statement(id, from, to)
+	if errors.Is(err, services.ErrProviderNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting provider statement", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	payments, err := h.payablesService.ListPayments(id, from, to)
+	if err != nil {
+		h.logger.Error("listing provider payments", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":      middleware.GetUser(r),
+		"Statement": statement,
+		"Payments":  payments,
+		"From":      statement.From.Format("2006-01-02"),
+		"To":        statement.To.Format("2006-01-02"),
+	}
+
+	if err := h.renderer.Render(w, "provider_statement.html", data); err != nil {
+		h.logger.Error("rendering provider statement", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleProviderPaymentView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	provider, err := h.providerStore.GetProviderByID(id)
+	if err != nil {
+		h.logger.Error("getting provider", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if provider == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	payables, err := h.payablesService.Payables(&provider.ID, nil)
+	if err != nil {
+		h.logger.Error("listing payables", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	methods, err := h.paymentMethodStore.GetAllPaymentMethods()
+	if err != nil {
+		h.logger.Error("listing payment methods", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":            middleware.GetUser(r),
+		"Provider":        provider,
+		"Payables":        payables,
+		"ExpenseBalances": expenseBalances(payables.Items),
+		"PaymentMethods":  methods,
+		"Today":           time.Now().Format("2006-01-02"),
+	}
+
+	if err := h.renderer.Render(w, "provider_payment_form.html", data); err != nil {
+		h.logger.Error("rendering provider payment form", "error", err)
+	}
+}
+
+// HandleCreateProviderPayment reads the payment header and its
+// expense_ids[] / amounts[] lines, skipping expenses left blank.
+func (h *WebHandler) HandleCreateProviderPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	req := services.ProviderPaymentRequest{
+		ProviderID: id,
+		Date:       r.FormValue("date"),
+		Notes:      r.FormValue("notes"),
+		UserID:     middleware.GetUser(r).ID,
+	}
+	req.PaymentMethodID, _ = strconv.ParseInt(r.FormValue("payment_method_id"), 10, 64)
+
+	expenseIDs := r.PostForm["expense_ids[]"]
+	amounts := r.PostForm["amounts[]"]
+	for i, idStr := range expenseIDs {
+		if i >= len(amounts) {
+			break
+		}
+		amountStr := strings.ReplaceAll(strings.TrimSpace(amounts[i]), ",", ".")
+		if amountStr == "" {
+			continue
+		}
+		expenseID, _ := strconv.ParseInt(idStr, 10, 64)
+		amount, _ := strconv.ParseFloat(amountStr, 64)
+		if amount == 0 {
+			continue
+		}
+		req.Allocations = append(req.Allocations, services.PaymentAllocationRequest{ExpenseID: expenseID, Amount: amount})
+	}
+
+	payment, err := h.payablesService.RegisterPayment(req)
+	if err != nil {
+		h.logger.Error("registering provider payment", "provider_id", id, "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/providers/%d/payments/new?error=%s", id, url.QueryEscape(payablesErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
+	h.audit.Created(r, store.AuditProviderPayment, payment.ID, payment)
+
+	success := fmt.Sprintf("Pago de $%.2f registrado", payment.Amount)
+	http.Redirect(w, r, fmt.Sprintf("/providers/%d/statement?success=%s", id, url.QueryEscape(success)), http.StatusSeeOther)
+}
+
+// expenseBalances maps each expense to what is left to pay of it.
+func expenseBalances(expenses []*store.Expense) map[int64]float64 {
+	balances := make(map[int64]float64, len(expenses))
+	for _, e := range expenses {
+		balances[e.ID] = services.ExpenseBalance(e)
+	}
+	return balances
+}
+
+func payablesErrorMessage(err error) string {
+	for _, known := range []error{
+		services.ErrPaymentWithoutExpenses, services.ErrInvalidPaymentAmount, services.ErrDuplicatePaymentExpense,
+		services.ErrInvalidPaymentDate, services.ErrExpenseNotFound, services.ErrExpenseOtherProvider,
+		services.ErrPaymentExceedsBalance, services.ErrProviderNotFound, services.ErrPaymentMethodNotFound,
+	} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return "Error al registrar el pago"
+}
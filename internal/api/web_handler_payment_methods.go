@@ -36,10 +36,6 @@ func (h *WebHandler) HandleListPaymentMethods(w http.ResponseWriter, r *http.Req
 
 func (h *WebHandler) HandleCreatePaymentMethodView(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
-	if user.Role != "administrator" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
 
 	data := map[string]any{
 		"User":          user,
@@ -52,12 +48,6 @@ func (h *WebHandler) HandleCreatePaymentMethodView(w http.ResponseWriter, r *htt
 }
 
 func (h *WebHandler) HandleCreatePaymentMethod(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user.Role != "administrator" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
-
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
@@ -73,16 +63,13 @@ func (h *WebHandler) HandleCreatePaymentMethod(w http.ResponseWriter, r *http.Re
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditPaymentMethod, pm.ID, pm)
 
 	http.Redirect(w, r, "/payment_methods?success="+url.QueryEscape("Método de pago creado exitosamente"), http.StatusSeeOther)
 }
 
 func (h *WebHandler) HandleEditPaymentMethodView(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
-	if user.Role != "administrator" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
 
 	pmID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -112,12 +99,6 @@ func (h *WebHandler) HandleEditPaymentMethodView(w http.ResponseWriter, r *http.
 }
 
 func (h *WebHandler) HandleUpdatePaymentMethod(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user.Role != "administrator" {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
-
 	pmID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid ID", http.StatusBadRequest)
@@ -129,29 +110,32 @@ func (h *WebHandler) HandleUpdatePaymentMethod(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	pm := &store.PaymentMethod{
-		ID:        pmID,
-		Name:      r.FormValue("name"),
-		Reference: r.FormValue("reference"),
+	pm, err := h.paymentMethodStore.GetPaymentMethodByID(pmID)
+	if err != nil {
+		h.logger.Error("getting payment method", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if pm == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
 	}
+	before := *pm
+
+	pm.Name = r.FormValue("name")
+	pm.Reference = r.FormValue("reference")
 
 	if err := h.paymentMethodStore.UpdatePaymentMethod(pm); err != nil {
 		h.logger.Error("updating payment method", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Updated(r, store.AuditPaymentMethod, pmID, before, pm)
 
 	http.Redirect(w, r, "/payment_methods?success="+url.QueryEscape("Método de pago actualizado correctamente"), http.StatusSeeOther)
 }
 
 func (h *WebHandler) HandleDeletePaymentMethod(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user.Role != "administrator" {
-		utils.TriggerToast(w, "No tienes permiso para realizar esta acción", "error")
-		utils.Error(w, http.StatusForbidden, "Forbidden")
-		return
-	}
-
 	pmID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
 		utils.TriggerToast(w, "ID inválido", "error")
@@ -159,6 +143,14 @@ func (h *WebHandler) HandleDeletePaymentMethod(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	pm, err := h.paymentMethodStore.GetPaymentMethodByID(pmID)
+	if err != nil {
+		h.logger.Error("getting payment method", "error", err)
+		utils.TriggerToast(w, "Error interno del servidor", "error")
+		utils.Error(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
 	if err := h.paymentMethodStore.DeletePaymentMethod(pmID); err != nil {
 		if strings.Contains(err.Error(), "violates foreign key constraint") {
 			utils.TriggerToast(w, "No se puede eliminar: el método de pago tiene ventas asociadas.", "error")
@@ -171,6 +163,7 @@ func (h *WebHandler) HandleDeletePaymentMethod(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	h.audit.Deleted(r, store.AuditPaymentMethod, pmID, pm)
 	utils.TriggerToast(w, "Método de pago eliminado", "success")
 	w.WriteHeader(http.StatusOK)
 }
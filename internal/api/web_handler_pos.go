@@ -0,0 +1,206 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+)
+
+// posPages are the POS screens the operator can be switched from, to go
+// back to, with the permission each needs.
+var posPages = map[string]string{
+	"/local-sales/new": store.PermSalesCreate,
+	"/shifts":          store.PermShiftsOperate,
+}
+
+func posPage(r *http.Request) string {
+	if next := r.FormValue("next"); posPages[next] != "" {
+		return next
+	}
+	return "/local-sales/new"
+}
+
+// roleCan reports whether role grants permission.
+func (h *WebHandler) roleCan(role, permission string) (bool, error) {
+	roles, err := h.roleService.List()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range roles {
+		if r.Name == role {
+			return slices.Contains(r.Permissions, permission), nil
+		}
+	}
+	return false, nil
+}
+
+// addPOSOperator adds what the operator switch of the POS screens needs.
+func (h *WebHandler) addPOSOperator(data map[string]any, page string) {
+	users, err := h.pinService.Users()
+	if err != nil {
+		h.logger.Error("listing pin users", "error", err)
+	}
+	data["PINUsers"] = users
+	data["POSPage"] = page
+}
+
+// HandleSwitchPOSOperator lets an employee take over this device's POS with
+// their PIN. Sales and shift actions are theirs until they finish, another
+// employee switches in or POSOperatorTTL passes.
+func (h *WebHandler) HandleSwitchPOSOperator(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	page := posPage(r)
+
+	userID, _ := strconv.ParseInt(r.FormValue("user_id"), 10, 64)
+	operator, err := h.userStore.GetUserByID(userID)
+	if err != nil {
+		h.logger.Error("getting pos operator", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if operator == nil || !operator.IsActive {
+		http.Redirect(w, r, page+"?error="+url.QueryEscape("Elegí quién va a usar la caja"), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.pinService.Check(operator, r.FormValue("pin"), loginAttempt(r, operator.Username)); err != nil {
+		msg := err.Error()
+		var pinLocked *services.PINLockedError
+		if !errors.Is(err, services.ErrPINInvalid) && !errors.Is(err, services.ErrPINNotSet) && !errors.As(err, &pinLocked) {
+			msg = h.loginErrorMessage(err)
+		}
+		http.Redirect(w, r, page+"?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+
+	// Otherwise the screen would be closed to the device until the operator
+	// expires.
+	can, err := h.roleCan(operator.Role, posPages[page])
+	if err != nil {
+		h.logger.Error("listing roles", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !can {
+		http.Redirect(w, r, page+"?error="+url.QueryEscape(operator.Username+" no tiene permiso para usar esta pantalla"), http.StatusSeeOther)
+		return
+	}
+
+	token, err := h.tokenStore.CreateNewToken(int(operator.ID), services.POSOperatorTTL, tokens.ScopePOSOperator)
+	if err != nil {
+		h.logger.Error("creating pos operator token", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	middleware.SetPOSOperatorCookie(w, r, token.Plaintext, token.Expiry)
+
+	http.Redirect(w, r, page+"?success="+url.QueryEscape("Ahora opera "+operator.Username), http.StatusSeeOther)
+}
+
+// HandleClearPOSOperator gives the POS back to the user logged in on the
+// device.
+func (h *WebHandler) HandleClearPOSOperator(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	middleware.SetPOSOperatorCookie(w, r, "", time.Unix(0, 0))
+	http.Redirect(w, r, posPage(r)+"?success="+url.QueryEscape("Operador finalizado"), http.StatusSeeOther)
+}
+
+// --- PIN of the logged in user ---
+
+func (h *WebHandler) HandleShowPIN(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+	hasPIN, err := h.pinService.HasPIN(user.ID)
+	if err != nil {
+		h.logger.Error("getting pin", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":   user,
+		"HasPIN": hasPIN,
+	}
+	if err := h.renderer.Render(w, "pin.html", data); err != nil {
+		h.logger.Error("rendering pin", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleSetPIN(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if ok := h.confirmPassword(w, r, user, "/account/pin"); !ok {
+		return
+	}
+	pin := r.FormValue("pin")
+	if pin != r.FormValue("confirm_pin") {
+		http.Redirect(w, r, "/account/pin?error="+url.QueryEscape("Los PIN no coinciden"), http.StatusSeeOther)
+		return
+	}
+	if err := h.pinService.Set(user.ID, pin); err != nil {
+		if !errors.Is(err, services.ErrPINFormat) {
+			h.logger.Error("setting pin", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/account/pin?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditUser, user.ID, "pin", nil, nil)
+
+	http.Redirect(w, r, "/account/pin?success="+url.QueryEscape("PIN guardado"), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleRemovePIN(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if ok := h.confirmPassword(w, r, user, "/account/pin"); !ok {
+		return
+	}
+	if err := h.pinService.Remove(user.ID); err != nil {
+		h.logger.Error("removing pin", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.audit.Record(r, store.AuditUser, user.ID, "pin_remove", nil, nil)
+
+	http.Redirect(w, r, "/account/pin?success="+url.QueryEscape("PIN eliminado"), http.StatusSeeOther)
+}
+
+// confirmPassword checks the password field against the user's, counting
+// wrong ones as failed logins, and redirects back to page when it doesn't
+// match.
+func (h *WebHandler) confirmPassword(w http.ResponseWriter, r *http.Request, user *store.User, page string) bool {
+	err := h.loginSecurity.CheckPassword(user, r.FormValue("password"), loginAttempt(r, user.Username))
+	if err == nil {
+		return true
+	}
+	msg := "Contraseña incorrecta"
+	if !errors.Is(err, services.ErrInvalidCredentials) {
+		msg = h.loginErrorMessage(err)
+	}
+	http.Redirect(w, r, page+"?error="+url.QueryEscape(msg), http.StatusSeeOther)
+	return false
+}
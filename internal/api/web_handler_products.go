@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	chi "github.com/go-chi/chi/v5"
@@ -58,6 +60,7 @@ func (h *WebHandler) HandleListProducts(w http.ResponseWriter, r *http.Request)
 }
 
 func (h *WebHandler) HandleCreateProductView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	user := middleware.GetUser(r)
 	categories, err := h.categoryStore.GetAllCategories()
 	if err != nil {
@@ -95,16 +98,47 @@ func (h *WebHandler) HandleCreateProduct(w http.ResponseWriter, r *http.Request)
 		DistributionPrice: distPrice,
 	}
 
+	saleUnit, err := services.ParseSaleUnit(r.FormValue("sale_unit"))
+	if err != nil {
+		http.Redirect(w, r, "/products/new?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+	product.SaleUnit = saleUnit
+	product.AllowNegativeStock = r.FormValue("allow_negative_stock") == "on"
+
+	if product.MinStock, product.TargetStock, err = parseStockLevels(r); err != nil {
+		http.Redirect(w, r, "/products/new?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	barcodes, err := services.NormalizeBarcodes(splitBarcodes(r.FormValue("barcodes")))
+	if err != nil {
+		http.Redirect(w, r, "/products/new?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
 	if err := h.productStore.CreateProduct(product); err != nil {
 		h.logger.Error("creating product", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	err = h.productStore.SetProductBarcodes(product.ID, barcodes)
+	if err == nil {
+		product.Barcodes = barcodes
+	}
+	h.audit.Created(r, store.AuditProduct, product.ID, product)
+	if err != nil {
+		h.logger.Error("setting product barcodes", "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/products/%d/edit?error=%s", product.ID, url.QueryEscape(barcodeErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
 	http.Redirect(w, r, "/products?success="+url.QueryEscape("Producto creado exitosamente"), http.StatusSeeOther)
 }
 
 func (h *WebHandler) HandleEditProductView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	user := middleware.GetUser(r)
 	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -157,13 +191,44 @@ func (h *WebHandler) HandleUpdateProduct(w http.ResponseWriter, r *http.Request)
 	unitPrice, _ := strconv.ParseFloat(r.FormValue("unit_price"), 64)
 	distPrice, _ := strconv.ParseFloat(r.FormValue("distribution_price"), 64)
 
-	product := &store.Product{
-		ID:                productID,
-		Name:              r.FormValue("name"),
-		Description:       r.FormValue("description"),
-		CategoryID:        categoryID,
-		UnitPrice:         unitPrice,
-		DistributionPrice: distPrice,
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil {
+		h.logger.Error("getting product", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if product == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	before := *product
+
+	product.Name = r.FormValue("name")
+	product.Description = r.FormValue("description")
+	product.CategoryID = categoryID
+	// Without prices.edit the prices stay as they were.
+	if middleware.GetUser(r).Can(store.PermPricesEdit) {
+		product.UnitPrice = unitPrice
+		product.DistributionPrice = distPrice
+	}
+
+	saleUnit, err := services.ParseSaleUnit(r.FormValue("sale_unit"))
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/products/%d/edit?error=%s", productID, url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
+	}
+	product.SaleUnit = saleUnit
+	product.AllowNegativeStock = r.FormValue("allow_negative_stock") == "on"
+
+	if product.MinStock, product.TargetStock, err = parseStockLevels(r); err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/products/%d/edit?error=%s", productID, url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
+	}
+
+	barcodes, err := services.NormalizeBarcodes(splitBarcodes(r.FormValue("barcodes")))
+	if err != nil {
+		http.Redirect(w, r, fmt.Sprintf("/products/%d/edit?error=%s", productID, url.QueryEscape(err.Error())), http.StatusSeeOther)
+		return
 	}
 
 	if err := h.productStore.UpdateProduct(product); err != nil {
@@ -172,9 +237,41 @@ func (h *WebHandler) HandleUpdateProduct(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	err = h.productStore.SetProductBarcodes(productID, barcodes)
+	if err == nil {
+		product.Barcodes = barcodes
+	}
+	h.audit.Updated(r, store.AuditProduct, productID, before, product)
+	if err != nil {
+		h.logger.Error("setting product barcodes", "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/products/%d/edit?error=%s", productID, url.QueryEscape(barcodeErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
 	http.Redirect(w, r, "/products?success="+url.QueryEscape("Producto actualizado correctamente"), http.StatusSeeOther)
 }
 
+// parseStockLevels reads the optional min_stock and target_stock fields; an
+// empty field leaves the level unset.
+func parseStockLevels(r *http.Request) (*float64, *float64, error) {
+	var levels [2]*float64
+	for i, field := range []string{"min_stock", "target_stock"} {
+		v := strings.ReplaceAll(strings.TrimSpace(r.FormValue(field)), ",", ".")
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, nil, errors.New("nivel de stock inválido")
+		}
+		levels[i] = &f
+	}
+	if err := services.ValidateStockLevels(levels[0], levels[1]); err != nil {
+		return nil, nil, err
+	}
+	return levels[0], levels[1], nil
+}
+
 func (h *WebHandler) HandleDeleteProduct(w http.ResponseWriter, r *http.Request) {
 	productID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -183,17 +280,41 @@ func (h *WebHandler) HandleDeleteProduct(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	product, err := h.productStore.GetProductByID(productID)
+	if err != nil {
+		h.logger.Error("getting product", "error", err)
+		utils.TriggerToast(w, "Error al eliminar producto", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	if err := h.productStore.DeleteProduct(productID); err != nil {
 		h.logger.Error("deleting product", "error", err)
 		utils.TriggerToast(w, "Error al eliminar producto", "error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Deleted(r, store.AuditProduct, productID, product)
 
 	utils.TriggerToast(w, "Producto eliminado", "success")
 	w.WriteHeader(http.StatusOK) // HTMX will remove the element
 }
 
+// splitBarcodes splits the barcodes textarea, which accepts one code per line
+// or comma separated codes.
+func splitBarcodes(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ','
+	})
+}
+
+func barcodeErrorMessage(err error) string {
+	if errors.Is(err, store.ErrBarcodeInUse) {
+		return err.Error()
+	}
+	return "Error al guardar los códigos de barras"
+}
+
 // --- Recipes ---
 
 func (h *WebHandler) HandleManageRecipeView(w http.ResponseWriter, r *http.Request) {
@@ -256,7 +377,7 @@ func (h *WebHandler) HandleAddIngredientToRecipe(w http.ResponseWriter, r *http.
 	quantity, _ := strconv.ParseFloat(r.FormValue("quantity"), 64)
 	unit := r.FormValue("unit")
 
-	_, err = h.productStore.AddIngredientToProduct(productID, ingredientID, quantity, unit)
+	pi, err := h.productStore.AddIngredientToProduct(productID, ingredientID, quantity, unit)
 	if err != nil {
 		if strings.Contains(err.Error(), "duplicate key value") {
 			http.Redirect(w, r, "/products/"+strconv.FormatInt(productID, 10)+"/recipe?error="+url.QueryEscape("El ingrediente ya existe en la receta"), http.StatusSeeOther)
@@ -266,6 +387,7 @@ func (h *WebHandler) HandleAddIngredientToRecipe(w http.ResponseWriter, r *http.
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditProductRecipe, productID, pi)
 
 	http.Redirect(w, r, "/products/"+strconv.FormatInt(productID, 10)+"/recipe?success="+url.QueryEscape("Ingrediente agregado"), http.StatusSeeOther)
 }
@@ -285,6 +407,14 @@ func (h *WebHandler) HandleRemoveIngredientFromRecipe(w http.ResponseWriter, r *
 		return
 	}
 
+	before, err := recipeIngredient(h.productStore, productID, ingredientID)
+	if err != nil {
+		h.logger.Error("getting recipe ingredient", "error", err)
+		utils.TriggerToast(w, "Error al eliminar ingrediente", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	err = h.productStore.RemoveIngredientFromProduct(productID, ingredientID)
 	if err != nil {
 		h.logger.Error("removing ingredient from recipe", "error", err)
@@ -292,6 +422,7 @@ func (h *WebHandler) HandleRemoveIngredientFromRecipe(w http.ResponseWriter, r *
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Deleted(r, store.AuditProductRecipe, productID, before)
 
 	utils.TriggerToast(w, "Ingrediente eliminado", "success")
 	w.WriteHeader(http.StatusOK)
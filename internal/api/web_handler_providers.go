@@ -116,6 +116,7 @@ func (h *WebHandler) HandleCreateProvider(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditProvider, provider.ID, provider)
 
 	http.Redirect(w, r, "/providers?success="+url.QueryEscape("Proveedor creado exitosamente"), http.StatusSeeOther)
 }
@@ -171,22 +172,32 @@ func (h *WebHandler) HandleUpdateProvider(w http.ResponseWriter, r *http.Request
 
 	categoryID, _ := strconv.ParseInt(r.FormValue("category_id"), 10, 64)
 
-	provider := &store.Provider{
-		ID:         providerID,
-		Name:       r.FormValue("name"),
-		Address:    r.FormValue("address"),
-		Phone:      r.FormValue("phone"),
-		Reference:  r.FormValue("reference"),
-		Email:      r.FormValue("email"),
-		CUIT:       r.FormValue("cuit"),
-		CategoryID: categoryID,
+	provider, err := h.providerStore.GetProviderByID(providerID)
+	if err != nil {
+		h.logger.Error("getting provider", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
+	if provider == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	before := *provider
+
+	provider.Name = r.FormValue("name")
+	provider.Address = r.FormValue("address")
+	provider.Phone = r.FormValue("phone")
+	provider.Reference = r.FormValue("reference")
+	provider.Email = r.FormValue("email")
+	provider.CUIT = r.FormValue("cuit")
+	provider.CategoryID = categoryID
 
 	if err := h.providerStore.UpdateProvider(provider); err != nil {
 		h.logger.Error("updating provider", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Updated(r, store.AuditProvider, providerID, before, provider)
 
 	http.Redirect(w, r, "/providers?success="+url.QueryEscape("Proveedor actualizado correctamente"), http.StatusSeeOther)
 }
@@ -199,12 +210,21 @@ func (h *WebHandler) HandleDeleteProvider(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	provider, err := h.providerStore.GetProviderByID(providerID)
+	if err != nil {
+		h.logger.Error("getting provider", "error", err)
+		utils.TriggerToast(w, "Error al eliminar proveedor", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	if err := h.providerStore.DeleteProvider(providerID); err != nil {
 		h.logger.Error("deleting provider", "error", err)
 		utils.TriggerToast(w, "Error al eliminar proveedor", "error")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Deleted(r, store.AuditProvider, providerID, provider)
 
 	utils.TriggerToast(w, "Proveedor eliminado", "success")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// --- Purchase Orders ---
+
+func (h *WebHandler) HandleListPurchaseOrders(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	q := r.URL.Query()
+	var filter store.PurchaseOrderFilter
+	if providerID, err := strconv.ParseInt(q.Get("provider_id"), 10, 64); err == nil && providerID > 0 {
+		filter.ProviderID = &providerID
+	}
+	state := store.PurchaseOrderState(q.Get("state"))
+	if state != "" {
+		filter.State = &state
+	}
+
+	orders, err := h.purchaseService.List(filter)
+	if err != nil {
+		h.logger.Error("listing purchase orders", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	providers, err := h.providerStore.GetAllProviders()
+	if err != nil {
+		h.logger.Error("listing providers", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var providerID int64
+	if filter.ProviderID != nil {
+		providerID = *filter.ProviderID
+	}
+	data := map[string]any{
+		"User":       user,
+		"Orders":     orders,
+		"Providers":  providers,
+		"ProviderID": providerID,
+		"State":      string(state),
+	}
+
+	if err := h.renderer.Render(w, "purchase_orders_list.html", data); err != nil {
+		h.logger.Error("rendering purchase orders", "error", err)
+	}
+}
+
+func (h *WebHandler) renderPurchaseOrderForm(w http.ResponseWriter, r *http.Request, order *store.PurchaseOrder) {
+	providers, err := h.providerStore.GetAllProviders()
+	if err != nil {
+		h.logger.Error("listing providers", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	ingredients, err := h.ingredientStore.GetAllIngredients()
+	if err != nil {
+		h.logger.Error("listing ingredients", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	expectedDate := ""
+	if order.ExpectedDate != nil {
+		expectedDate = order.ExpectedDate.Format("2006-01-02")
+	}
+	data := map[string]any{
+		"User":         middleware.GetUser(r),
+		"Order":        order,
+		"ExpectedDate": expectedDate,
+		"Providers":    providers,
+		"Ingredients":  ingredients,
+	}
+
+	if err := h.renderer.Render(w, "purchase_order_form.html", data); err != nil {
+		h.logger.Error("rendering purchase order form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreatePurchaseOrderView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	order := &store.PurchaseOrder{}
+	order.ProviderID, _ = strconv.ParseInt(r.URL.Query().Get("provider_id"), 10, 64)
+	h.renderPurchaseOrderForm(w, r, order)
+}
+
+// purchaseOrderRequestFromForm reads the order header and its
+// ingredient_ids[] / quantities[] / prices[] lines, skipping empty ones.
+func purchaseOrderRequestFromForm(r *http.Request) services.PurchaseOrderRequest {
+	req := services.PurchaseOrderRequest{
+		ExpectedDate: r.FormValue("expected_date"),
+		Notes:        r.FormValue("notes"),
+	}
+	req.ProviderID, _ = strconv.ParseInt(r.FormValue("provider_id"), 10, 64)
+
+	ingredientIDs := r.PostForm["ingredient_ids[]"]
+	quantities := r.PostForm["quantities[]"]
+	prices := r.PostForm["prices[]"]
+	for i, idStr := range ingredientIDs {
+		if i >= len(quantities) || i >= len(prices) {
+			break
+		}
+		id, _ := strconv.ParseInt(idStr, 10, 64)
+		if id <= 0 {
+			continue
+		}
+		qty, _ := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(quantities[i]), ",", "."), 64)
+		price, _ := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(prices[i]), ",", "."), 64)
+		req.Items = append(req.Items, services.PurchaseOrderItemRequest{IngredientID: id, Quantity: qty, ExpectedPrice: price})
+	}
+	return req
+}
+
+func (h *WebHandler) HandleCreatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	req := purchaseOrderRequestFromForm(r)
+	req.UserID = middleware.GetUser(r).ID
+
+	order, err := h.purchaseService.Create(req)
+	if err != nil {
+		h.logger.Error("creating purchase order", "error", err)
+		http.Redirect(w, r, "/purchase-orders/new?error="+url.QueryEscape(purchaseOrderErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+
+	h.audit.Created(r, store.AuditPurchaseOrder, order.ID, order)
+
+	http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?success=%s", order.ID, url.QueryEscape("Orden de compra creada")), http.StatusSeeOther)
+}
+
+func (h *WebHandler) purchaseOrderFromURL(w http.ResponseWriter, r *http.Request) (*store.PurchaseOrder, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	order, err := h.purchaseService.Get(id)
+	if errors.Is(err, services.ErrPurchaseOrderNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		h.logger.Error("getting purchase order", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return order, true
+}
+
+func (h *WebHandler) HandlePurchaseOrderView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	order, ok := h.purchaseOrderFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	categories, err := h.expenseStore.GetAllExpenseCategories()
+	if err != nil {
+		h.logger.Error("listing expense categories", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":              middleware.GetUser(r),
+		"Order":             order,
+		"Business":          h.purchaseService.Business(),
+		"ExpenseCategories": categories,
+		"PurchasesCategory": services.PurchasesCategory,
+	}
+
+	if err := h.renderer.Render(w, "purchase_order_detail.html", data); err != nil {
+		h.logger.Error("rendering purchase order", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleEditPurchaseOrderView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	order, ok := h.purchaseOrderFromURL(w, r)
+	if !ok {
+		return
+	}
+	if order.State != store.PurchaseOrderDraft {
+		http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?error=%s", order.ID, url.QueryEscape(services.ErrPurchaseOrderNotDraft.Error())), http.StatusSeeOther)
+		return
+	}
+	h.renderPurchaseOrderForm(w, r, order)
+}
+
+func (h *WebHandler) HandleUpdatePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.purchaseService.Get(id)
+	if err != nil {
+		h.logger.Error("getting purchase order", "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d/edit?error=%s", id, url.QueryEscape(purchaseOrderErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
+	order, err := h.purchaseService.Update(id, purchaseOrderRequestFromForm(r))
+	if err != nil {
+		h.logger.Error("updating purchase order", "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d/edit?error=%s", id, url.QueryEscape(purchaseOrderErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+	h.audit.Updated(r, store.AuditPurchaseOrder, id, before, order)
+
+	http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?success=%s", id, url.QueryEscape("Orden de compra actualizada")), http.StatusSeeOther)
+}
+
+// purchaseOrderAction runs a state change from the detail page, recorded in
+// the audit log as action, and redirects back to it with the outcome.
+func (h *WebHandler) purchaseOrderAction(w http.ResponseWriter, r *http.Request, action string, apply func(int64) (*store.PurchaseOrder, error), success string) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.purchaseService.Get(id)
+	if err != nil {
+		h.logger.Error("getting purchase order", "id", id, "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?error=%s", id, url.QueryEscape(purchaseOrderErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
+	order, err := apply(id)
+	if err != nil {
+		h.logger.Error("updating purchase order", "id", id, "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?error=%s", id, url.QueryEscape(purchaseOrderErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditPurchaseOrder, id, action, before, order)
+
+	http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?success=%s", id, url.QueryEscape(success)), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleSendPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.purchaseOrderAction(w, r, "send", h.purchaseService.Send, "Orden enviada por email al proveedor")
+}
+
+func (h *WebHandler) HandleMarkPurchaseOrderSent(w http.ResponseWriter, r *http.Request) {
+	h.purchaseOrderAction(w, r, "mark_sent", h.purchaseService.MarkSent, "Orden marcada como enviada")
+}
+
+func (h *WebHandler) HandleCancelPurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	h.purchaseOrderAction(w, r, "cancel", h.purchaseService.Cancel, "Orden de compra cancelada")
+}
+
+func (h *WebHandler) HandleReceivePurchaseOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	req := services.ReceivePurchaseRequest{
+		Notes:  r.FormValue("notes"),
+		UserID: middleware.GetUser(r).ID,
+	}
+	req.CategoryID, _ = strconv.ParseInt(r.FormValue("category_id"), 10, 64)
+
+	itemIDs := r.PostForm["item_ids[]"]
+	quantities := r.PostForm["received[]"]
+	prices := r.PostForm["unit_prices[]"]
+	for i, idStr := range itemIDs {
+		if i >= len(quantities) || i >= len(prices) {
+			break
+		}
+		itemID, _ := strconv.ParseInt(idStr, 10, 64)
+		qty, _ := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(quantities[i]), ",", "."), 64)
+		item := services.ReceivePurchaseItem{ItemID: itemID, Quantity: qty}
+		if p := strings.ReplaceAll(strings.TrimSpace(prices[i]), ",", "."); p != "" {
+			if price, err := strconv.ParseFloat(p, 64); err == nil {
+				item.UnitPrice = &price
+			}
+		}
+		req.Items = append(req.Items, item)
+	}
+
+	before, err := h.purchaseService.Get(id)
+	if err != nil {
+		h.logger.Error("getting purchase order", "id", id, "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?error=%s", id, url.QueryEscape(purchaseOrderErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
+	order, err := h.purchaseService.Receive(id, req)
+	if err != nil {
+		h.logger.Error("receiving purchase order", "id", id, "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?error=%s", id, url.QueryEscape(purchaseOrderErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditPurchaseOrder, id, "receive", before, order)
+
+	http.Redirect(w, r, fmt.Sprintf("/purchase-orders/%d?success=%s", id, url.QueryEscape("Mercadería recibida y gasto registrado")), http.StatusSeeOther)
+}
+
+func purchaseOrderErrorMessage(err error) string {
+	for _, known := range []error{
+		services.ErrPurchaseOrderNotFound, services.ErrPurchaseOrderEmpty, services.ErrDuplicatePurchaseItem,
+		services.ErrInvalidPurchaseQuantity, services.ErrInvalidPurchasePrice, services.ErrInvalidExpectedDate,
+		services.ErrProviderNotFound, services.ErrProviderWithoutEmail, services.ErrIngredientNotFound,
+		services.ErrPurchaseOrderNotDraft, services.ErrPurchaseOrderNotReceivable, services.ErrPurchaseOrderClosed,
+		services.ErrPurchaseReceiptEmpty, services.ErrPurchaseItemNotFound, services.ErrExpenseCategoryNotFound,
+		services.ErrPurchaseOrderEmailFailed,
+	} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	return "Error al guardar la orden de compra"
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+// --- Roles and Permissions ---
+
+// PermissionGroup is a block of checkboxes in the role form.
+type PermissionGroup struct {
+	Name        string
+	Permissions []store.PermissionInfo
+}
+
+func permissionGroups() []PermissionGroup {
+	var groups []PermissionGroup
+	for _, p := range store.PermissionCatalog {
+		if len(groups) == 0 || groups[len(groups)-1].Name != p.Group {
+			groups = append(groups, PermissionGroup{Name: p.Group})
+		}
+		last := &groups[len(groups)-1]
+		last.Permissions = append(last.Permissions, p)
+	}
+	return groups
+}
+
+func (h *WebHandler) HandleListRoles(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	roles, err := h.roleService.List()
+	if err != nil {
+		h.logger.Error("listing roles", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":            middleware.GetUser(r),
+		"Roles":           roles,
+		"PermissionCount": len(store.PermissionCatalog),
+		"AdminRole":       store.AdminRole,
+	}
+
+	if err := h.renderer.Render(w, "roles_list.html", data); err != nil {
+		h.logger.Error("rendering roles list", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateRoleView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	data := map[string]any{
+		"User":             middleware.GetUser(r),
+		"Role":             &store.Role{},
+		"PermissionGroups": permissionGroups(),
+	}
+
+	if err := h.renderer.Render(w, "role_form.html", data); err != nil {
+		h.logger.Error("rendering role form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateRole(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	role, err := h.roleService.Create(roleRequestFromForm(r))
+	if err != nil {
+		h.logger.Error("creating role", "error", err)
+		http.Redirect(w, r, "/users/roles/new?error="+url.QueryEscape(roleErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+	h.audit.Created(r, store.AuditRole, role.ID, role)
+
+	http.Redirect(w, r, "/users/roles?success="+url.QueryEscape("Rol creado exitosamente"), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleEditRoleView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := h.roleService.Get(id)
+	if errors.Is(err, services.ErrRoleNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting role", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":             middleware.GetUser(r),
+		"Role":             role,
+		"PermissionGroups": permissionGroups(),
+		"Locked":           role.Name == store.AdminRole,
+	}
+
+	if err := h.renderer.Render(w, "role_form.html", data); err != nil {
+		h.logger.Error("rendering role form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleUpdateRole(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.roleService.Get(id)
+	if err != nil {
+		h.logger.Error("getting role", "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/users/roles/%d/edit?error=%s", id, url.QueryEscape(roleErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+
+	role, err := h.roleService.Update(id, roleRequestFromForm(r))
+	if err != nil {
+		h.logger.Error("updating role", "error", err)
+		http.Redirect(w, r, fmt.Sprintf("/users/roles/%d/edit?error=%s", id, url.QueryEscape(roleErrorMessage(err))), http.StatusSeeOther)
+		return
+	}
+	h.audit.Updated(r, store.AuditRole, id, before, role)
+
+	http.Redirect(w, r, "/users/roles?success="+url.QueryEscape("Rol actualizado exitosamente"), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleDeleteRole(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.TriggerToast(w, "ID de rol inválido", "error")
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	role, err := h.roleService.Get(id)
+	if err == nil {
+		err = h.roleService.Delete(id)
+	}
+	if errors.Is(err, services.ErrRoleNotFound) || errors.Is(err, services.ErrSystemRole) || errors.Is(err, store.ErrRoleInUse) {
+		utils.TriggerToast(w, err.Error(), "error")
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.logger.Error("deleting role", "error", err)
+		utils.TriggerToast(w, "Error al eliminar el rol", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.audit.Deleted(r, store.AuditRole, id, role)
+	utils.TriggerToast(w, "Rol eliminado", "success")
+	w.WriteHeader(http.StatusOK)
+}
+
+func roleRequestFromForm(r *http.Request) services.RoleRequest {
+	return services.RoleRequest{
+		Name:        r.FormValue("name"),
+		Description: r.FormValue("description"),
+		Permissions: r.Form["permissions[]"],
+	}
+}
+
+func roleErrorMessage(err error) string {
+	for _, known := range []error{
+		services.ErrRoleNotFound,
+		services.ErrRoleNameEmpty,
+		services.ErrUnknownPermission,
+		services.ErrSystemRole,
+		services.ErrAdminRoleLocked,
+		store.ErrRoleNameTaken,
+		store.ErrRoleInUse,
+	} {
+		if errors.Is(err, known) {
+			return err.Error()
+		}
+	}
+	return "Error al guardar el rol"
+}
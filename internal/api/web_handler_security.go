@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type securityEventOption struct {
+	Value string
+	Label string
+}
+
+var securityEventOptions = []securityEventOption{
+	{store.SecurityLoginFailed, "Inicio de sesión fallido"},
+	{store.SecurityTwoFactorFailed, "Código de dos pasos incorrecto"},
+	{store.SecurityPINFailed, "PIN incorrecto"},
+	{store.SecurityPINLockout, "PIN bloqueado"},
+	{store.SecurityLockout, "Cuenta bloqueada"},
+	{store.SecurityUnlock, "Cuenta desbloqueada"},
+	{store.SecurityPasswordResetRequested, "Pedido de nueva contraseña"},
+	{store.SecurityPasswordReset, "Contraseña restablecida"},
+}
+
+func (h *WebHandler) HandleListSecurityEvents(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	q := r.URL.Query()
+
+	filter := store.SecurityEventFilter{Event: q.Get("event")}
+	if id, err := strconv.ParseInt(q.Get("user_id"), 10, 64); err == nil {
+		filter.UserID = &id
+	}
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit := 50
+	filter.Limit = limit
+	filter.Offset = (page - 1) * limit
+
+	events, total, err := h.loginSecurity.Events(filter)
+	if err != nil {
+		h.logger.Error("listing security events", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	users, err := h.userStore.GetAllUsers()
+	if err != nil {
+		h.logger.Error("getting users for security events", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	labels := map[string]string{}
+	for _, o := range securityEventOptions {
+		labels[o.Value] = o.Label
+	}
+
+	q.Del("page")
+
+	data := map[string]any{
+		"User":     user,
+		"Events":   events,
+		"Total":    total,
+		"Users":    users,
+		"Options":  securityEventOptions,
+		"Labels":   labels,
+		"Filter":   filter,
+		"Query":    template.URL(q.Encode()),
+		"Page":     page,
+		"HasNext":  page*limit < total,
+		"NextPage": page + 1,
+		"PrevPage": page - 1,
+	}
+
+	if err := h.renderer.Render(w, "security_events.html", data); err != nil {
+		h.logger.Error("rendering security events", "error", err)
+	}
+}
+
+// HandleUnlockUser lifts a lockout for failed logins and re-renders the
+// user's row.
+func (h *WebHandler) HandleUnlockUser(w http.ResponseWriter, r *http.Request) {
+	admin := middleware.GetUser(r)
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.TriggerToast(w, "ID inválido", "error")
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.userStore.GetUserByID(id)
+	if err == nil && user == nil {
+		err = services.ErrSecurityUserNotFound
+	}
+	if err == nil {
+		err = h.loginSecurity.Unlock(id, loginAttempt(r, user.Username), "desbloqueado por "+admin.Username)
+	}
+	if errors.Is(err, services.ErrSecurityUserNotFound) {
+		utils.TriggerToast(w, "Usuario no encontrado", "error")
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("unlocking user", "id", id, "error", err)
+		utils.TriggerToast(w, "Error al desbloquear el usuario", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.audit.Record(r, store.AuditUser, id, "unlock", nil, nil)
+
+	utils.TriggerToast(w, "Usuario desbloqueado", "success")
+	user.LockedUntil = nil
+	if err := h.renderer.RenderPartial(w, "user_row.html", user); err != nil {
+		h.logger.Error("rendering user row", "error", err)
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type SessionView struct {
+	*store.Session
+	Device string
+}
+
+// describeUserAgent names the browser and system of a user agent, e.g.
+// "Chrome en Windows". Unknown agents are shown as they are.
+func describeUserAgent(ua string) string {
+	if ua == "" {
+		return "Desconocido"
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+
+	system := ""
+	switch {
+	case strings.Contains(ua, "Android"):
+		system = "Android"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		system = "iOS"
+	case strings.Contains(ua, "Windows"):
+		system = "Windows"
+	case strings.Contains(ua, "Mac OS X"):
+		system = "macOS"
+	case strings.Contains(ua, "Linux"):
+		system = "Linux"
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " en " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+	return ua
+}
+
+func (h *WebHandler) renderSessions(w http.ResponseWriter, r *http.Request, target *store.User) {
+	user := middleware.GetUser(r)
+	sessions, err := h.tokenStore.ListSessions(target.ID)
+	if err != nil {
+		h.logger.Error("listing sessions", "user_id", target.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]SessionView, 0, len(sessions))
+	for _, s := range sessions {
+		s.Current = s.ID == user.SessionID
+		views = append(views, SessionView{Session: s, Device: describeUserAgent(s.UserAgent)})
+	}
+
+	tf, err := h.twoFactorService.Status(target.ID)
+	if err != nil {
+		h.logger.Error("getting two-factor status", "user_id", target.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	own := target.ID == user.ID
+	baseURL := "/sessions"
+	if !own {
+		baseURL = "/users/" + strconv.FormatInt(target.ID, 10) + "/sessions"
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Target":    target,
+		"Own":       own,
+		"BaseURL":   baseURL,
+		"Sessions":  views,
+		"TwoFactor": tf,
+	}
+	if err := h.renderer.Render(w, "sessions.html", data); err != nil {
+		h.logger.Error("rendering sessions", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleListSessions(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	h.renderSessions(w, r, middleware.GetUser(r))
+}
+
+func (h *WebHandler) HandleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tokenStore.RevokeSession(user.ID, id); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Error("revoking session", "session_id", id, "error", err)
+		utils.TriggerToast(w, "Error al cerrar la sesión", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if id == user.SessionID {
+		middleware.SetAuthCookie(w, r, "", time.Unix(0, 0))
+		w.Header().Set("HX-Redirect", "/login")
+		return
+	}
+	utils.TriggerToast(w, "Sesión cerrada", "success")
+}
+
+func (h *WebHandler) HandleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := h.tokenStore.RevokeOtherSessions(user.ID, user.SessionID); err != nil {
+		h.logger.Error("revoking other sessions", "error", err)
+		http.Redirect(w, r, "/sessions?error="+url.QueryEscape("Error al cerrar las sesiones"), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/sessions?success="+url.QueryEscape("Se cerraron las demás sesiones"), http.StatusSeeOther)
+}
+
+// --- Users' sessions (Admin) ---
+
+func (h *WebHandler) sessionsTarget(w http.ResponseWriter, r *http.Request) (*store.User, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return nil, false
+	}
+	target, err := h.userStore.GetUserByID(id)
+	if err != nil {
+		h.logger.Error("getting user", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if target == nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	return target, true
+}
+
+func (h *WebHandler) HandleListUserSessions(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	target, ok := h.sessionsTarget(w, r)
+	if !ok {
+		return
+	}
+	h.renderSessions(w, r, target)
+}
+
+func (h *WebHandler) HandleRevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.sessionsTarget(w, r)
+	if !ok {
+		return
+	}
+	sessionID, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tokenStore.RevokeSession(target.ID, sessionID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Error("revoking session", "session_id", sessionID, "error", err)
+		utils.TriggerToast(w, "Error al cerrar la sesión", "error")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.audit.Record(r, store.AuditUser, target.ID, "logout", nil, map[string]any{"session_id": sessionID})
+	utils.TriggerToast(w, "Sesión cerrada", "success")
+}
+
+// HandleLogoutUser closes every session of the user.
+func (h *WebHandler) HandleLogoutUser(w http.ResponseWriter, r *http.Request) {
+	target, ok := h.sessionsTarget(w, r)
+	if !ok {
+		return
+	}
+	redirect := "/users/" + strconv.FormatInt(target.ID, 10) + "/sessions"
+
+	if err := h.tokenStore.DeleteAllTokensForUser(int(target.ID), tokens.ScopeAuth); err != nil {
+		h.logger.Error("revoking user sessions", "user_id", target.ID, "error", err)
+		http.Redirect(w, r, redirect+"?error="+url.QueryEscape("Error al cerrar las sesiones"), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditUser, target.ID, "logout", nil, nil)
+
+	if target.ID == middleware.GetUser(r).ID {
+		middleware.SetAuthCookie(w, r, "", time.Unix(0, 0))
+		w.Header().Set("HX-Redirect", "/login")
+		return
+	}
+	http.Redirect(w, r, redirect+"?success="+url.QueryEscape("Se cerraron todas las sesiones de "+target.Username), http.StatusSeeOther)
+}
@@ -4,28 +4,83 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 )
 
+// registerCookieName stores the cash register this device is used as.
+// The drawer is a physical object, so the choice belongs to the browser and
+// not to whichever employee is logged in.
+const registerCookieName = "cash_register_id"
+
+// currentRegisterID returns the register selected on this device, falling back
+// to the first active one. It returns 0 if there are no active registers.
+func (h *WebHandler) currentRegisterID(r *http.Request) (int64, error) {
+	if cookie, err := r.Cookie(registerCookieName); err == nil {
+		if id, err := strconv.ParseInt(cookie.Value, 10, 64); err == nil {
+			register, err := h.shiftService.GetRegister(id)
+			if err != nil {
+				return 0, err
+			}
+			if register != nil && register.IsActive {
+				return register.ID, nil
+			}
+		}
+	}
+
+	registers, err := h.shiftService.ListRegisters(true)
+	if err != nil {
+		return 0, err
+	}
+	if len(registers) == 0 {
+		return 0, nil
+	}
+	return registers[0].ID, nil
+}
+
 func (h *WebHandler) HandleShiftManagement(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	user := middleware.GetUser(r)
-	
-	currentShift, err := h.shiftService.GetCurrentShift(user.ID)
+
+	registers, err := h.shiftService.ListRegisters(true)
+	if err != nil {
+		h.logger.Error("listing cash registers", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	registerID, err := h.currentRegisterID(r)
 	if err != nil {
-		h.logger.Error("getting current shift", "error", err)
+		h.logger.Error("getting current cash register", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	var currentShift *store.Shift
+	if registerID != 0 {
+		currentShift, err = h.shiftService.GetCurrentShift(registerID)
+		if err != nil {
+			h.logger.Error("getting current shift", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	var movements []*store.CashMovement
+	var handovers []*store.ShiftHandover
 	if currentShift != nil {
 		movements, err = h.shiftService.ListMovements(currentShift.ID)
 		if err != nil {
 			h.logger.Error("listing movements", "error", err)
 			// Non-critical, continue
 		}
+		handovers, err = h.shiftService.ListHandovers(currentShift.ID)
+		if err != nil {
+			h.logger.Error("listing handovers", "error", err)
+			// Non-critical, continue
+		}
 	}
 
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
@@ -33,144 +88,204 @@ func (h *WebHandler) HandleShiftManagement(w http.ResponseWriter, r *http.Reques
 		page = 1
 	}
 
-	shifts, err := h.shiftService.ListUserShifts(user.ID, page)
+	var shifts []*store.Shift
+	if registerID != 0 {
+		shifts, err = h.shiftService.ListRegisterShifts(registerID, page)
+		if err != nil {
+			h.logger.Error("listing register shifts", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	users, err := h.userStore.GetAllUsers()
 	if err != nil {
-		h.logger.Error("listing user shifts", "error", err)
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
+		h.logger.Error("listing users", "error", err)
+	}
+	userNames := make(map[int64]string)
+	var activeUsers []*store.User
+	for _, u := range users {
+		userNames[u.ID] = u.Username
+		if u.IsActive {
+			activeUsers = append(activeUsers, u)
+		}
 	}
 
 	data := map[string]any{
 		"User":         user,
+		"Registers":    registers,
+		"RegisterID":   registerID,
 		"CurrentShift": currentShift,
 		"Movements":    movements,
+		"Handovers":    handovers,
 		"Shifts":       shifts,
+		"Users":        activeUsers,
+		"UserNames":    userNames,
 		"Page":         page,
 		"NextPage":     page + 1,
 		"PrevPage":     page - 1,
 	}
+	h.addPOSOperator(data, "/shifts")
 
 	if err := h.renderer.Render(w, "shifts.html", data); err != nil {
 		h.logger.Error("rendering shifts view", "error", err)
 	}
 }
 
-func (h *WebHandler) HandleOpenShift(w http.ResponseWriter, r *http.Request) {
-
-	user := middleware.GetUser(r)
-
+func (h *WebHandler) HandleSelectRegister(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-
 		http.Error(w, "Bad Request", http.StatusBadRequest)
-
 		return
+	}
 
+	registerID, _ := strconv.ParseInt(r.FormValue("register_id"), 10, 64)
+	register, err := h.shiftService.GetRegister(registerID)
+	if err != nil {
+		h.logger.Error("getting cash register", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if register == nil || !register.IsActive {
+		http.Redirect(w, r, "/shifts?error="+url.QueryEscape("Caja no disponible"), http.StatusSeeOther)
+		return
 	}
 
+	http.SetCookie(w, &http.Cookie{
+		Name:     registerCookieName,
+		Value:    strconv.FormatInt(register.ID, 10),
+		Expires:  time.Now().AddDate(1, 0, 0),
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+	})
+
+	http.Redirect(w, r, "/shifts?success="+url.QueryEscape("Este equipo ahora usa "+register.Name), http.StatusSeeOther)
+}
 
+func (h *WebHandler) HandleOpenShift(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
 
-	startCash, _ := strconv.ParseFloat(r.FormValue("start_cash"), 64)
+	registerID, err := h.currentRegisterID(r)
+	if err != nil {
+		h.logger.Error("getting current cash register", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
+	startCash, _ := strconv.ParseFloat(r.FormValue("start_cash"), 64)
 	notes := r.FormValue("notes")
 
-
-
-	_, err := h.shiftService.OpenShift(user.ID, startCash, notes)
-
+	shift, err := h.shiftService.OpenShift(registerID, user.ID, startCash, notes)
 	if err != nil {
-
 		h.logger.Error("opening shift", "error", err)
-
 		http.Redirect(w, r, "/shifts?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
-
 		return
-
 	}
 
-
+	h.audit.Record(r, store.AuditCashRegister, registerID, "open_shift", nil, shift)
 
 	http.Redirect(w, r, "/shifts?success="+url.QueryEscape("Caja abierta correctamente"), http.StatusSeeOther)
-
 }
 
-
-
 func (h *WebHandler) HandleCloseShift(w http.ResponseWriter, r *http.Request) {
-
 	user := middleware.GetUser(r)
-
 	if err := r.ParseForm(); err != nil {
-
 		http.Error(w, "Bad Request", http.StatusBadRequest)
-
 		return
-
 	}
 
-
+	registerID, err := h.currentRegisterID(r)
+	if err != nil {
+		h.logger.Error("getting current cash register", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
 	declaredCash, _ := strconv.ParseFloat(r.FormValue("end_cash_declared"), 64)
-
 	notes := r.FormValue("notes")
 
-
-
-	_, err := h.shiftService.CloseShift(user.ID, declaredCash, notes)
-
+	shift, err := h.shiftService.CloseShift(registerID, user.ID, declaredCash, notes)
 	if err != nil {
-
 		h.logger.Error("closing shift", "error", err)
-
 		http.Redirect(w, r, "/shifts?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
-
 		return
-
 	}
 
-
+	h.audit.Record(r, store.AuditCashRegister, registerID, "close_shift", nil, shift)
 
 	http.Redirect(w, r, "/shifts?success="+url.QueryEscape("Caja cerrada correctamente"), http.StatusSeeOther)
-
 }
 
-
-
-func (h *WebHandler) HandleRegisterMovement(w http.ResponseWriter, r *http.Request) {
-
+func (h *WebHandler) HandleHandoverShift(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
-
 	if err := r.ParseForm(); err != nil {
-
 		http.Error(w, "Bad Request", http.StatusBadRequest)
-
 		return
+	}
 
+	registerID, err := h.currentRegisterID(r)
+	if err != nil {
+		h.logger.Error("getting current cash register", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
 	}
 
+	// Without an explicit recipient the logged in user takes over the drawer.
+	toUserID := user.ID
+	if v := r.FormValue("to_user_id"); v != "" {
+		toUserID, _ = strconv.ParseInt(v, 10, 64)
+	}
 
+	var countedCash *float64
+	if v := r.FormValue("counted_cash"); v != "" {
+		if c, err := strconv.ParseFloat(v, 64); err == nil {
+			countedCash = &c
+		}
+	}
 
-	amount, _ := strconv.ParseFloat(r.FormValue("amount"), 64)
+	shift, err := h.shiftService.HandoverShift(registerID, toUserID, countedCash, r.FormValue("notes"))
+	if err != nil {
+		h.logger.Error("handing over shift", "error", err)
+		http.Redirect(w, r, "/shifts?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
 
-	typeStr := r.FormValue("type")
+	h.audit.Record(r, store.AuditCashRegister, registerID, "handover", nil, shift)
 
-	reason := r.FormValue("reason")
+	http.Redirect(w, r, "/shifts?success="+url.QueryEscape("Relevo de caja registrado"), http.StatusSeeOther)
+}
 
+func (h *WebHandler) HandleRegisterMovement(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
 
+	registerID, err := h.currentRegisterID(r)
+	if err != nil {
+		h.logger.Error("getting current cash register", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
 
-	_, err := h.shiftService.RegisterMovement(user.ID, amount, typeStr, reason)
+	amount, _ := strconv.ParseFloat(r.FormValue("amount"), 64)
+	typeStr := r.FormValue("type")
+	reason := r.FormValue("reason")
 
+	movement, err := h.shiftService.RegisterMovement(registerID, user.ID, amount, typeStr, reason)
 	if err != nil {
-
 		h.logger.Error("registering movement", "error", err)
-
 		http.Redirect(w, r, "/shifts?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
-
 		return
-
 	}
 
-
+	h.audit.Record(r, store.AuditCashRegister, registerID, "cash_movement", nil, movement)
 
 	http.Redirect(w, r, "/shifts?success="+url.QueryEscape("Movimiento registrado"), http.StatusSeeOther)
-
 }
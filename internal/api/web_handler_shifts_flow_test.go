@@ -33,23 +33,34 @@ func TestShiftFlow_WithSales(t *testing.T) {
 	userStore := store.NewPostgresUserStore(db)
 
 	// Initialize Services
-	localSaleService := services.NewLocalSaleService(db, saleStore, stockStore, paymentMethodStore, productStore)
+	localSaleService := services.NewLocalSaleService(db, saleStore, stockStore, paymentMethodStore, productStore, shiftStore)
 	
 	// Mock cashMovementStore inside shiftService? 
 	// No, NewShiftService requires it.
 	cashMovementStore := store.NewPostgresCashMovementStore(db)
-	shiftService := services.NewShiftService(shiftStore, saleStore, cashMovementStore)
+	cashRegisterStore := store.NewPostgresCashRegisterStore(db)
+	shiftService := services.NewShiftService(shiftStore, cashRegisterStore, saleStore, cashMovementStore)
 	
 	// Update handler with new service
-	webHandler := api.NewWebHandler(
-		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, localSaleService, shiftService, nil, logger,
-	)
+	webHandler := api.NewWebHandler(api.WebHandlerDeps{
+		LocalSaleService: localSaleService,
+		ShiftService:     shiftService,
+		Logger:           logger,
+	})
 
-	// 1. Setup Data: User, Payment Methods, Product, Stock
+	// 1. Setup Data: Users, Register, Payment Methods, Product, Stock
 	user := &store.User{Username: "cashier", Email: "c@test.com", Role: "employee", IsActive: true}
 	require.NoError(t, user.PasswordHash.Set("123456"))
 	require.NoError(t, userStore.CreateUser(user))
 
+	relief := &store.User{Username: "relief", Email: "r@test.com", Role: "employee", IsActive: true}
+	require.NoError(t, relief.PasswordHash.Set("123456"))
+	require.NoError(t, userStore.CreateUser(relief))
+
+	register := &store.CashRegister{Name: "Mostrador", IsActive: true}
+	require.NoError(t, cashRegisterStore.Create(register))
+	registerCookie := &http.Cookie{Name: "cash_register_id", Value: strconv.FormatInt(register.ID, 10)}
+
 	cashMethod := &store.PaymentMethod{Name: "Efectivo"}
 	require.NoError(t, paymentMethodStore.CreatePaymentMethod(cashMethod)) // ID likely 1
 
@@ -61,7 +72,7 @@ func TestShiftFlow_WithSales(t *testing.T) {
 	db.Exec("INSERT INTO categories (id, name) VALUES (1, 'General')")
 	require.NoError(t, productStore.CreateProduct(product))
 	
-	_, err := stockStore.Create(product.ID, 100)
+	_, err := stockStore.Create(product.ID, 100, store.StockChange{Reason: store.MovementInitial})
 	require.NoError(t, err)
 
 	// 2. Open Shift
@@ -69,6 +80,7 @@ func TestShiftFlow_WithSales(t *testing.T) {
 	formOpen := url.Values{"start_cash": {strconv.FormatFloat(startCash, 'f', 2, 64)}, "notes": {"Start"}}
 	reqOpen := httptest.NewRequest("POST", "/shifts/open", strings.NewReader(formOpen.Encode()))
 	reqOpen.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	reqOpen.AddCookie(registerCookie)
 	reqOpen = middleware.SetUser(reqOpen, user)
 	wOpen := httptest.NewRecorder()
 	
@@ -79,6 +91,7 @@ func TestShiftFlow_WithSales(t *testing.T) {
 	// Sale 1: Cash ($200) -> Should affect Shift
 	sale1 := services.CreateLocalSaleRequest{
 		PaymentMethodID: cashMethod.ID,
+		RegisterID:      register.ID,
 		Items: []services.CreateLocalSaleItem{{ProductID: product.ID, Quantity: 2}},
 	}
 	_, err = localSaleService.CreateLocalSale(sale1)
@@ -87,11 +100,41 @@ func TestShiftFlow_WithSales(t *testing.T) {
 	// Sale 2: Card ($300) -> Should NOT affect Shift Cash
 	sale2 := services.CreateLocalSaleRequest{
 		PaymentMethodID: cardMethod.ID,
+		RegisterID:      register.ID,
 		Items: []services.CreateLocalSaleItem{{ProductID: product.ID, Quantity: 3}},
 	}
 	_, err = localSaleService.CreateLocalSale(sale2)
 	require.NoError(t, err)
 
+	// Sale without a register is not part of the drawer session
+	_, err = localSaleService.CreateLocalSale(services.CreateLocalSaleRequest{
+		PaymentMethodID: cashMethod.ID,
+		Items:           []services.CreateLocalSaleItem{{ProductID: product.ID, Quantity: 1}},
+	})
+	require.NoError(t, err)
+
+	// 3.2. Handover: the relief employee takes the drawer without closing it
+	formHandover := url.Values{"to_user_id": {strconv.FormatInt(relief.ID, 10)}, "counted_cash": {"1200.00"}}
+	reqHandover := httptest.NewRequest("POST", "/shifts/handover", strings.NewReader(formHandover.Encode()))
+	reqHandover.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	reqHandover.AddCookie(registerCookie)
+	reqHandover = middleware.SetUser(reqHandover, user)
+	wHandover := httptest.NewRecorder()
+
+	webHandler.HandleHandoverShift(wHandover, reqHandover)
+	require.Equal(t, http.StatusSeeOther, wHandover.Result().StatusCode)
+
+	openShift, err := shiftStore.GetOpenShiftByRegisterID(register.ID)
+	require.NoError(t, err)
+	require.NotNil(t, openShift)
+	require.Equal(t, user.ID, openShift.UserID)
+	require.Equal(t, relief.ID, openShift.CurrentUserID)
+
+	handovers, err := shiftStore.ListHandovers(openShift.ID)
+	require.NoError(t, err)
+	require.Len(t, handovers, 1)
+	require.Equal(t, user.ID, handovers[0].FromUserID)
+
 	// 3.5. Register Cash Movement (Output)
 	// Withdraw 50.00 for supplies
 	formMovement := url.Values{
@@ -101,7 +144,8 @@ func TestShiftFlow_WithSales(t *testing.T) {
 	}
 	reqMovement := httptest.NewRequest("POST", "/shifts/movements", strings.NewReader(formMovement.Encode()))
 	reqMovement.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	reqMovement = middleware.SetUser(reqMovement, user)
+	reqMovement.AddCookie(registerCookie)
+	reqMovement = middleware.SetUser(reqMovement, relief)
 	wMovement := httptest.NewRecorder()
 
 	webHandler.HandleRegisterMovement(wMovement, reqMovement)
@@ -113,7 +157,8 @@ func TestShiftFlow_WithSales(t *testing.T) {
 	formClose := url.Values{"end_cash_declared": {strconv.FormatFloat(declaredCash, 'f', 2, 64)}, "notes": {"End"}}
 	reqClose := httptest.NewRequest("POST", "/shifts/close", strings.NewReader(formClose.Encode()))
 	reqClose.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	reqClose = middleware.SetUser(reqClose, user)
+	reqClose.AddCookie(registerCookie)
+	reqClose = middleware.SetUser(reqClose, relief)
 	wClose := httptest.NewRecorder()
 
 	webHandler.HandleCloseShift(wClose, reqClose)
@@ -121,7 +166,7 @@ func TestShiftFlow_WithSales(t *testing.T) {
 
 	// 5. Verify Results
 	// We need to fetch the shift to check the Difference and Expected fields
-	shifts, err := shiftStore.ListByUserID(user.ID, 1, 0)
+	shifts, err := shiftStore.ListByRegisterID(register.ID, 1, 0)
 	require.NoError(t, err)
 	require.Len(t, shifts, 1)
 	
@@ -130,4 +175,11 @@ func TestShiftFlow_WithSales(t *testing.T) {
 	require.NotNil(t, closedShift.EndCashExpected)
 	require.Equal(t, 1150.0, *closedShift.EndCashExpected, "Expected cash should be Start + CashSales - MovementsOut")
 	require.Equal(t, 0.0, *closedShift.Difference, "Difference should be 0 if declared matches expected")
+	require.NotNil(t, closedShift.ClosedBy)
+	require.Equal(t, relief.ID, *closedShift.ClosedBy)
+
+	movements, err := cashMovementStore.ListByShiftID(closedShift.ID)
+	require.NoError(t, err)
+	require.Len(t, movements, 1)
+	require.Equal(t, relief.ID, *movements[0].UserID)
 }
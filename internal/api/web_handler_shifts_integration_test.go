@@ -25,12 +25,17 @@ func TestWebHandler_Shifts(t *testing.T) {
 	shiftStore := store.NewPostgresShiftStore(db)
 	saleStore := store.NewPostgresLocalSaleStore(db)
 	cashMovementStore := store.NewPostgresCashMovementStore(db)
-	shiftService := services.NewShiftService(shiftStore, saleStore, cashMovementStore)
+	cashRegisterStore := store.NewPostgresCashRegisterStore(db)
+	shiftService := services.NewShiftService(shiftStore, cashRegisterStore, saleStore, cashMovementStore)
 	userStore := store.NewPostgresUserStore(db)
 
-	webHandler := api.NewWebHandler(
-		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, shiftService, nil, logger,
-	)
+	register := &store.CashRegister{Name: "Mostrador", IsActive: true}
+	require.NoError(t, cashRegisterStore.Create(register))
+
+	webHandler := api.NewWebHandler(api.WebHandlerDeps{
+		ShiftService: shiftService,
+		Logger:       logger,
+	})
 
 	testUser := &store.User{
 		Username: "admin",
@@ -65,6 +70,7 @@ func TestWebHandler_Shifts(t *testing.T) {
 		require.NotNil(t, shift)
 		require.Equal(t, 100.50, shift.StartCash)
 		require.Equal(t, "open", shift.Status)
+		require.Equal(t, register.ID, shift.RegisterID)
 	})
 
 	t.Run("Close Shift", func(t *testing.T) {
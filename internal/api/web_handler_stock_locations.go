@@ -0,0 +1,320 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	chi "github.com/go-chi/chi/v5"
+)
+
+// --- Stock Locations ---
+
+func (h *WebHandler) HandleListStockLocations(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	locations, err := h.locationService.ListLocations(false)
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Locations": locations,
+	}
+
+	if err := h.renderer.Render(w, "stock_locations_list.html", data); err != nil {
+		h.logger.Error("rendering stock locations list", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleStockLocationView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	location, err := h.locationService.GetLocation(id)
+	if errors.Is(err, services.ErrLocationNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting stock location", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	stock, err := h.localStockService.ListStock(id)
+	if err != nil {
+		h.logger.Error("listing location stock", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":     user,
+		"Location": location,
+		"Stock":    stock,
+	}
+
+	if err := h.renderer.Render(w, "stock_location_detail.html", data); err != nil {
+		h.logger.Error("rendering stock location", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateStockLocationView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	data := map[string]any{
+		"User":     user,
+		"Location": store.StockLocation{IsActive: true},
+	}
+
+	if err := h.renderer.Render(w, "stock_location_form.html", data); err != nil {
+		h.logger.Error("rendering stock location form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateStockLocation(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	location, err := h.locationService.CreateLocation(r.FormValue("name"))
+	if err != nil {
+		h.logger.Error("creating stock location", "error", err)
+		http.Redirect(w, r, "/stock-locations?error="+url.QueryEscape(stockLocationErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+	h.audit.Created(r, store.AuditStockLocation, location.ID, location)
+
+	http.Redirect(w, r, "/stock-locations?success="+url.QueryEscape("Ubicación creada exitosamente"), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleEditStockLocationView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	location, err := h.locationService.GetLocation(id)
+	if errors.Is(err, services.ErrLocationNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting stock location", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":     user,
+		"Location": location,
+	}
+
+	if err := h.renderer.Render(w, "stock_location_form.html", data); err != nil {
+		h.logger.Error("rendering stock location form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleUpdateStockLocation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	isActive := r.FormValue("is_active") == "on"
+	before, err := h.locationService.GetLocation(id)
+	if err != nil {
+		h.logger.Error("getting stock location", "error", err)
+		http.Redirect(w, r, "/stock-locations?error="+url.QueryEscape(stockLocationErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+
+	location, err := h.locationService.UpdateLocation(id, r.FormValue("name"), isActive)
+	if err != nil {
+		h.logger.Error("updating stock location", "error", err)
+		http.Redirect(w, r, "/stock-locations?error="+url.QueryEscape(stockLocationErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+	h.audit.Updated(r, store.AuditStockLocation, id, before, location)
+
+	http.Redirect(w, r, "/stock-locations?success="+url.QueryEscape("Ubicación actualizada correctamente"), http.StatusSeeOther)
+}
+
+func stockLocationErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrLocationNameEmpty),
+		errors.Is(err, services.ErrLocationNotFound),
+		errors.Is(err, services.ErrDefaultLocationInactive),
+		errors.Is(err, store.ErrLocationNameTaken):
+		return err.Error()
+	default:
+		return "Error al guardar la ubicación"
+	}
+}
+
+// --- Stock Transfers (remitos internos) ---
+
+func (h *WebHandler) HandleListStockTransfers(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	q := r.URL.Query()
+	from, to, err := parseMovementRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		utils.TriggerToast(w, "Rango de fechas inválido", "error")
+		from, to, _ = parseMovementRange("", "")
+	}
+	locationID, _ := strconv.ParseInt(q.Get("location_id"), 10, 64)
+
+	transfers, err := h.locationService.ListTransfers(from, to, locationID)
+	if err != nil {
+		h.logger.Error("listing stock transfers", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	locations, err := h.locationService.ListLocations(false)
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":       user,
+		"Transfers":  transfers,
+		"Locations":  locations,
+		"LocationID": locationID,
+		"From":       from.Format("2006-01-02"),
+		"To":         to.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+
+	if err := h.renderer.Render(w, "stock_transfers_list.html", data); err != nil {
+		h.logger.Error("rendering stock transfers", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateStockTransferView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	locations, err := h.locationService.ListLocations(true)
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	products, err := h.productStore.GetAllProduct()
+	if err != nil {
+		h.logger.Error("fetching products", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Locations": locations,
+		"Products":  products,
+	}
+
+	if err := h.renderer.Render(w, "stock_transfer_form.html", data); err != nil {
+		h.logger.Error("rendering stock transfer form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateStockTransfer(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	req := services.CreateTransferRequest{
+		Notes:  r.FormValue("notes"),
+		UserID: user.ID,
+	}
+	req.FromLocationID, _ = strconv.ParseInt(r.FormValue("from_location_id"), 10, 64)
+	req.ToLocationID, _ = strconv.ParseInt(r.FormValue("to_location_id"), 10, 64)
+
+	productIDs := r.PostForm["product_ids[]"]
+	quantities := r.PostForm["quantities[]"]
+	for i, pidStr := range productIDs {
+		if i >= len(quantities) {
+			break
+		}
+		pid, _ := strconv.ParseInt(pidStr, 10, 64)
+		qty, _ := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(quantities[i]), ",", "."), 64)
+		if pid > 0 && qty > 0 {
+			req.Items = append(req.Items, services.CreateTransferItem{ProductID: pid, Quantity: qty})
+		}
+	}
+
+	transfer, err := h.locationService.CreateTransfer(req)
+	if err != nil {
+		h.logger.Error("creating stock transfer", "error", err)
+		http.Redirect(w, r, "/stock-transfers?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	h.audit.Created(r, store.AuditStockTransfer, transfer.ID, transfer)
+
+	http.Redirect(w, r, fmt.Sprintf("/stock-transfers/%d?success=%s", transfer.ID, url.QueryEscape("Remito interno creado")), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleStockTransferView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	transfer, err := h.locationService.GetTransfer(id)
+	if errors.Is(err, services.ErrTransferNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting stock transfer", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":     user,
+		"Transfer": transfer,
+	}
+
+	if err := h.renderer.Render(w, "stock_transfer_detail.html", data); err != nil {
+		h.logger.Error("rendering stock transfer", "error", err)
+	}
+}
@@ -0,0 +1,318 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+	"github.com/go-chi/chi/v5"
+)
+
+// twoFactorCookie holds the token of a login waiting for its second factor.
+const twoFactorCookie = "two_factor_token"
+
+func setTwoFactorCookie(w http.ResponseWriter, r *http.Request, token string, expiry time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookie,
+		Value:    token,
+		Expires:  expiry,
+		HttpOnly: true,
+		Path:     "/login",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+	})
+}
+
+// startTwoFactorLogin remembers that the user got the password right, for the
+// next few minutes.
+func (h *WebHandler) startTwoFactorLogin(w http.ResponseWriter, r *http.Request, user *store.User) error {
+	token, err := h.tokenStore.CreateNewToken(int(user.ID), services.TwoFactorLoginTTL, tokens.ScopeTwoFactor)
+	if err != nil {
+		return err
+	}
+	setTwoFactorCookie(w, r, token.Plaintext, token.Expiry)
+	return nil
+}
+
+// twoFactorLoginUser returns the user halfway through logging in, nil when
+// there is none or it took too long.
+func (h *WebHandler) twoFactorLoginUser(r *http.Request) (*store.User, error) {
+	cookie, err := r.Cookie(twoFactorCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+	return h.userStore.GetUserToken(tokens.ScopeTwoFactor, cookie.Value)
+}
+
+// finishTwoFactorLogin swaps the two-factor token for a session.
+func (h *WebHandler) finishTwoFactorLogin(w http.ResponseWriter, r *http.Request, user *store.User) error {
+	if err := h.tokenStore.DeleteAllTokensForUser(int(user.ID), tokens.ScopeTwoFactor); err != nil {
+		return err
+	}
+	setTwoFactorCookie(w, r, "", time.Unix(0, 0))
+	return h.startSession(w, r, user)
+}
+
+func (h *WebHandler) HandleShowTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	user, err := h.twoFactorLoginUser(r)
+	if err != nil {
+		h.logger.Error("getting two-factor login", "error", err)
+	}
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if err := h.renderer.Render(w, "two_factor_login.html", nil); err != nil {
+		h.logger.Error("rendering two-factor login", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	user, err := h.twoFactorLoginUser(r)
+	if err != nil {
+		h.logger.Error("getting two-factor login", "error", err)
+	}
+	if user == nil {
+		w.Header().Set("HX-Redirect", "/login")
+		return
+	}
+
+	// Codes count as failed logins too, or a stolen password would allow
+	// guessing them.
+	if err := h.loginSecurity.Check(user); err != nil {
+		h.renderTwoFactorLoginError(w, h.loginErrorMessage(err))
+		return
+	}
+	if err := h.twoFactorService.Verify(user.ID, r.FormValue("code")); err != nil {
+		msg := "Código incorrecto"
+		if !errors.Is(err, services.ErrTwoFactorInvalidCode) {
+			h.logger.Error("verifying two-factor code", "error", err)
+			msg = "Error interno del servidor"
+		} else if err := h.loginSecurity.Failed(user, store.SecurityTwoFactorFailed, loginAttempt(r, user.Username)); err != nil {
+			msg = h.loginErrorMessage(err)
+		}
+		h.renderTwoFactorLoginError(w, msg)
+		return
+	}
+
+	if err := h.finishTwoFactorLogin(w, r, user); err != nil {
+		h.logger.Error("finishing two-factor login", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("HX-Redirect", "/")
+}
+
+func (h *WebHandler) renderTwoFactorLoginError(w http.ResponseWriter, msg string) {
+	if err := h.renderer.Render(w, "two_factor_login.html", map[string]any{"Error": msg}); err != nil {
+		h.logger.Error("rendering two-factor login", "error", err)
+	}
+}
+
+// renderTwoFactorSetup shows the secret to scan. Without an error a new one
+// is generated; with one, the pending secret is shown again.
+func (h *WebHandler) renderTwoFactorSetup(w http.ResponseWriter, r *http.Request, user *store.User, action string, errMsg string) {
+	var secret string
+	if errMsg == "" {
+		s, _, err := h.twoFactorService.Begin(user)
+		if errors.Is(err, services.ErrTwoFactorAlreadyEnabled) {
+			http.Redirect(w, r, "/account/2fa", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			h.logger.Error("starting two-factor enrollment", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		secret = s
+	} else {
+		tf, err := h.twoFactorService.Status(user.ID)
+		if err != nil {
+			h.logger.Error("getting two-factor status", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		secret = tf.Secret
+	}
+
+	data := map[string]any{
+		"Secret":   secret,
+		"URI":      h.twoFactorService.URI(user, secret),
+		"Action":   action,
+		"Required": h.twoFactorService.Required(user),
+		"Error":    errMsg,
+	}
+	// During the login there is no session yet, hence no menu.
+	if action != "/login/2fa/setup" {
+		data["User"] = user
+	}
+	if err := h.renderer.Render(w, "two_factor_setup.html", data); err != nil {
+		h.logger.Error("rendering two-factor setup", "error", err)
+	}
+}
+
+func (h *WebHandler) renderRecoveryCodes(w http.ResponseWriter, user *store.User, codes []string, next string) {
+	data := map[string]any{
+		"Codes": codes,
+		"Next":  next,
+	}
+	if next != "/" {
+		data["User"] = user
+	}
+	if err := h.renderer.Render(w, "two_factor_recovery_codes.html", data); err != nil {
+		h.logger.Error("rendering recovery codes", "error", err)
+	}
+}
+
+func twoFactorErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, services.ErrTwoFactorInvalidCode),
+		errors.Is(err, services.ErrTwoFactorNotPending),
+		errors.Is(err, services.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnabled),
+		errors.Is(err, services.ErrTwoFactorRequiredForRole):
+		return err.Error()
+	}
+	return "Error interno del servidor"
+}
+
+// HandleShowTwoFactorLoginSetup asks users whose role requires two-factor to
+// enroll before their first session.
+func (h *WebHandler) HandleShowTwoFactorLoginSetup(w http.ResponseWriter, r *http.Request) {
+	user, err := h.twoFactorLoginUser(r)
+	if err != nil {
+		h.logger.Error("getting two-factor login", "error", err)
+	}
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	h.renderTwoFactorSetup(w, r, user, "/login/2fa/setup", "")
+}
+
+func (h *WebHandler) HandleTwoFactorLoginSetup(w http.ResponseWriter, r *http.Request) {
+	user, err := h.twoFactorLoginUser(r)
+	if err != nil {
+		h.logger.Error("getting two-factor login", "error", err)
+	}
+	if user == nil {
+		w.Header().Set("HX-Redirect", "/login")
+		return
+	}
+
+	codes, err := h.twoFactorService.Confirm(user.ID, r.FormValue("code"))
+	if err != nil {
+		if msg := twoFactorErrorMessage(err); msg == "Error interno del servidor" {
+			h.logger.Error("confirming two-factor", "error", err)
+		}
+		h.renderTwoFactorSetup(w, r, user, "/login/2fa/setup", twoFactorErrorMessage(err))
+		return
+	}
+	h.audit.Record(r, store.AuditUser, user.ID, "2fa_enable", nil, nil)
+
+	if err := h.finishTwoFactorLogin(w, r, user); err != nil {
+		h.logger.Error("finishing two-factor login", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	h.renderRecoveryCodes(w, user, codes, "/")
+}
+
+// --- Two-factor settings of the logged in user ---
+
+func (h *WebHandler) HandleShowTwoFactor(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+	tf, err := h.twoFactorService.Status(user.ID)
+	if err != nil {
+		h.logger.Error("getting two-factor status", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"TwoFactor": tf,
+		"Required":  h.twoFactorService.Required(user),
+	}
+	if err := h.renderer.Render(w, "two_factor.html", data); err != nil {
+		h.logger.Error("rendering two-factor settings", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleShowTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	h.renderTwoFactorSetup(w, r, middleware.GetUser(r), "/account/2fa/setup", "")
+}
+
+func (h *WebHandler) HandleTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	codes, err := h.twoFactorService.Confirm(user.ID, r.FormValue("code"))
+	if err != nil {
+		if msg := twoFactorErrorMessage(err); msg == "Error interno del servidor" {
+			h.logger.Error("confirming two-factor", "error", err)
+		}
+		h.renderTwoFactorSetup(w, r, user, "/account/2fa/setup", twoFactorErrorMessage(err))
+		return
+	}
+	h.audit.Record(r, store.AuditUser, user.ID, "2fa_enable", nil, nil)
+	h.renderRecoveryCodes(w, user, codes, "/account/2fa")
+}
+
+func (h *WebHandler) HandleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := h.twoFactorService.Disable(user, r.FormValue("code")); err != nil {
+		msg := twoFactorErrorMessage(err)
+		if msg == "Error interno del servidor" {
+			h.logger.Error("disabling two-factor", "error", err)
+		}
+		http.Redirect(w, r, "/account/2fa?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditUser, user.ID, "2fa_disable", nil, nil)
+	http.Redirect(w, r, "/account/2fa?success="+url.QueryEscape("Verificación en dos pasos desactivada"), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleRegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(user.ID, r.FormValue("code"))
+	if err != nil {
+		msg := twoFactorErrorMessage(err)
+		if msg == "Error interno del servidor" {
+			h.logger.Error("regenerating recovery codes", "error", err)
+		}
+		http.Redirect(w, r, "/account/2fa?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditUser, user.ID, "2fa_recovery_codes", nil, nil)
+	h.renderRecoveryCodes(w, user, codes, "/account/2fa")
+}
+
+// HandleResetUserTwoFactor turns off a user's two-factor, for users who lost
+// both the app and the recovery codes.
+func (h *WebHandler) HandleResetUserTwoFactor(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	redirect := "/users/" + strconv.FormatInt(id, 10) + "/sessions"
+
+	if err := h.twoFactorService.Reset(id); err != nil {
+		if errors.Is(err, services.ErrTwoFactorUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("resetting two-factor", "user_id", id, "error", err)
+		http.Redirect(w, r, redirect+"?error="+url.QueryEscape("Error al restablecer la verificación en dos pasos"), http.StatusSeeOther)
+		return
+	}
+	h.audit.Record(r, store.AuditUser, id, "2fa_reset", nil, nil)
+	http.Redirect(w, r, redirect+"?success="+url.QueryEscape("Verificación en dos pasos restablecida"), http.StatusSeeOther)
+}
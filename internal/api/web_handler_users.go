@@ -67,6 +67,8 @@ func (h *WebHandler) HandleToggleUserStatus(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Updated(r, store.AuditUser, targetUserID,
+		map[string]any{"is_active": !updatedUser.IsActive}, map[string]any{"is_active": updatedUser.IsActive})
 
 	if err := h.renderer.RenderPartial(w, "user_row.html", updatedUser); err != nil {
 		h.logger.Error("rendering user row", "error", err)
@@ -74,10 +76,19 @@ func (h *WebHandler) HandleToggleUserStatus(w http.ResponseWriter, r *http.Reque
 }
 
 func (h *WebHandler) HandleCreateUserView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	currentUser := middleware.GetUser(r)
+	roles, err := h.roleService.List()
+	if err != nil {
+		h.logger.Error("listing roles", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	data := map[string]any{
 		"User":       currentUser,
-		"TargetUser": store.User{},
+		"TargetUser": store.User{Role: "employee"},
+		"Roles":      roles,
 	}
 
 	if err := h.renderer.Render(w, "user_form.html", data); err != nil {
@@ -107,6 +118,10 @@ func (h *WebHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if ok := h.checkUserRole(w, r, user.Role, "/users/new"); !ok {
+		return
+	}
+
 	if err := user.PasswordHash.Set(password); err != nil {
 		h.logger.Error("hashing password", "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -118,11 +133,13 @@ func (h *WebHandler) HandleCreateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Created(r, store.AuditUser, user.ID, user)
 
 	http.Redirect(w, r, "/users?success="+url.QueryEscape("Usuario creado exitosamente"), http.StatusSeeOther)
 }
 
 func (h *WebHandler) HandleEditUserView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
 	currentUser := middleware.GetUser(r)
 	targetUserID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
@@ -141,9 +158,17 @@ func (h *WebHandler) HandleEditUserView(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	roles, err := h.roleService.List()
+	if err != nil {
+		h.logger.Error("listing roles", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	data := map[string]any{
 		"User":       currentUser,
 		"TargetUser": targetUser,
+		"Roles":      roles,
 	}
 
 	if err := h.renderer.Render(w, "user_form.html", data); err != nil {
@@ -169,10 +194,18 @@ func (h *WebHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	if existingUser == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	before := *existingUser
 
 	existingUser.Username = r.FormValue("username")
 	existingUser.Email = r.FormValue("email")
 	existingUser.Role = r.FormValue("role")
+	if ok := h.checkUserRole(w, r, existingUser.Role, fmt.Sprintf("/users/%d/edit", targetUserID)); !ok {
+		return
+	}
 
 	newPassword := r.FormValue("password")
 	if newPassword != "" {
@@ -194,6 +227,25 @@ func (h *WebHandler) HandleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	h.audit.Updated(r, store.AuditUser, targetUserID, before, existingUser)
+	if newPassword != "" {
+		h.audit.Record(r, store.AuditUser, targetUserID, "password", nil, nil)
+	}
 
 	http.Redirect(w, r, "/users?success="+url.QueryEscape("Usuario actualizado exitosamente"), http.StatusSeeOther)
 }
+
+// checkUserRole redirects back to formURL when role isn't an existing role.
+func (h *WebHandler) checkUserRole(w http.ResponseWriter, r *http.Request, role, formURL string) bool {
+	exists, err := h.roleService.Exists(role)
+	if err != nil {
+		h.logger.Error("getting role", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if !exists {
+		http.Redirect(w, r, formURL+"?error="+url.QueryEscape("Rol inválido"), http.StatusSeeOther)
+		return false
+	}
+	return true
+}
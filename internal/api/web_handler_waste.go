@@ -0,0 +1,152 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+var wasteReasonLabels = map[string]string{
+	store.WasteExpired: "Vencido",
+	store.WasteBroken:  "Roto",
+	store.WasteTasting: "Degustación",
+	store.WasteOther:   "Otro",
+}
+
+// HandleWasteView shows the waste register form and the waste registered in
+// the selected range.
+func (h *WebHandler) HandleWasteView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+	user := middleware.GetUser(r)
+
+	q := r.URL.Query()
+	from, to, err := parseMovementRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		utils.TriggerToast(w, "Rango de fechas inválido", "error")
+		from, to, _ = parseMovementRange("", "")
+	}
+	productID, _ := strconv.ParseInt(q.Get("product_id"), 10, 64)
+
+	records, err := h.wasteService.ListWaste(from, to, productID)
+	if err != nil {
+		h.logger.Error("listing waste", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	products, err := h.productStore.GetAllProduct()
+	if err != nil {
+		h.logger.Error("listing products", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	locations, err := h.locationService.ListLocations(true)
+	if err != nil {
+		h.logger.Error("listing stock locations", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var total float64
+	for _, rec := range records {
+		total += rec.Value
+	}
+
+	data := map[string]any{
+		"User":         user,
+		"Records":      records,
+		"Products":     products,
+		"Locations":    locations,
+		"ProductID":    productID,
+		"ReasonLabels": wasteReasonLabels,
+		"TotalValue":   total,
+		"From":         from.Format("2006-01-02"),
+		"To":           to.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+
+	if err := h.renderer.Render(w, "waste.html", data); err != nil {
+		h.logger.Error("rendering waste", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleRegisterWaste(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	productID, _ := strconv.ParseInt(r.FormValue("product_id"), 10, 64)
+	locationID, _ := strconv.ParseInt(r.FormValue("location_id"), 10, 64)
+	quantity, err := strconv.ParseFloat(strings.TrimSpace(strings.ReplaceAll(r.FormValue("quantity"), ",", ".")), 64)
+	if err != nil {
+		http.Redirect(w, r, "/waste?error="+url.QueryEscape(services.ErrInvalidWasteQuantity.Error()), http.StatusSeeOther)
+		return
+	}
+
+	record, err := h.wasteService.RegisterWaste(services.RegisterWasteRequest{
+		ProductID:  productID,
+		LocationID: locationID,
+		Quantity:   quantity,
+		Reason:     r.FormValue("reason"),
+		Note:       r.FormValue("note"),
+		UserID:     user.ID,
+	})
+	if err != nil {
+		h.logger.Error("registering waste", "error", err)
+		http.Redirect(w, r, "/waste?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	h.audit.Created(r, store.AuditWaste, record.ID, record)
+
+	http.Redirect(w, r, "/waste?success="+url.QueryEscape("Merma registrada, stock descontado"), http.StatusSeeOther)
+}
+
+// HandleWasteReportView totals waste by product and by week, to see which
+// products are being overproduced.
+func (h *WebHandler) HandleWasteReportView(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+
+	q := r.URL.Query()
+	from, to, err := parseMovementRange(q.Get("from"), q.Get("to"))
+	if err != nil {
+		utils.TriggerToast(w, "Rango de fechas inválido", "error")
+		from, to, _ = parseMovementRange("", "")
+	}
+	productID, _ := strconv.ParseInt(q.Get("product_id"), 10, 64)
+
+	report, err := h.wasteService.Report(from, to, productID)
+	if err != nil {
+		h.logger.Error("building waste report", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var product *store.WasteByProduct
+	for _, p := range report.ByProduct {
+		if p.ProductID == productID {
+			product = p
+		}
+	}
+
+	data := map[string]any{
+		"User":      user,
+		"Report":    report,
+		"ProductID": productID,
+		"Product":   product,
+		"From":      from.Format("2006-01-02"),
+		"To":        to.AddDate(0, 0, -1).Format("2006-01-02"),
+	}
+
+	if err := h.renderer.Render(w, "waste_report.html", data); err != nil {
+		h.logger.Error("rendering waste report", "error", err)
+	}
+}
@@ -0,0 +1,292 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+// --- Webhooks ---
+
+func (h *WebHandler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	hooks, err := h.webhookService.List()
+	if err != nil {
+		h.logger.Error("listing webhooks", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":     middleware.GetUser(r),
+		"Webhooks": hooks,
+		"Events":   store.WebhookEventCatalog,
+	}
+	if err := h.renderer.Render(w, "webhooks_list.html", data); err != nil {
+		h.logger.Error("rendering webhooks list", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateWebhookView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	data := map[string]any{
+		"User":    middleware.GetUser(r),
+		"Webhook": &store.Webhook{IsActive: true},
+		"Events":  store.WebhookEventCatalog,
+	}
+	if err := h.renderer.Render(w, "webhook_form.html", data); err != nil {
+		h.logger.Error("rendering webhook form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.webhookService.Create(webhookRequestFromForm(r))
+	if err != nil {
+		msg := webhookErrorMessage(err)
+		if !isWebhookValidationError(err) {
+			h.logger.Error("creating webhook", "error", err)
+		}
+		http.Redirect(w, r, "/webhooks/new?error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	h.audit.Created(r, store.AuditWebhook, hook.ID, hook)
+
+	// The secret is only shown now, on the webhook's page.
+	h.renderWebhook(w, r, hook, hook.Secret)
+}
+
+func (h *WebHandler) HandleShowWebhook(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.webhookService.Get(id)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting webhook", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderWebhook(w, r, hook, "")
+}
+
+// renderWebhook shows the webhook with a page of its delivery log. secret is
+// only given right after it is set.
+func (h *WebHandler) renderWebhook(w http.ResponseWriter, r *http.Request, hook *store.Webhook, secret string) {
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit := 50
+	filter := store.WebhookDeliveryFilter{
+		WebhookID: &hook.ID,
+		Status:    q.Get("status"),
+		Limit:     limit,
+		Offset:    (page - 1) * limit,
+	}
+
+	deliveries, total, err := h.webhookService.Deliveries(filter)
+	if err != nil {
+		h.logger.Error("listing webhook deliveries", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// The filters without the page, to build the pagination links.
+	q.Del("page")
+
+	data := map[string]any{
+		"User":       middleware.GetUser(r),
+		"Webhook":    hook,
+		"Secret":     secret,
+		"Events":     store.WebhookEventCatalog,
+		"Deliveries": deliveries,
+		"Total":      total,
+		"Status":     filter.Status,
+		"Query":      template.URL(q.Encode()),
+		"Page":       page,
+		"HasNext":    page*limit < total,
+		"NextPage":   page + 1,
+		"PrevPage":   page - 1,
+	}
+	if err := h.renderer.Render(w, "webhook_detail.html", data); err != nil {
+		h.logger.Error("rendering webhook", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleEditWebhookView(w http.ResponseWriter, r *http.Request) {
+	h.triggerMessages(w, r)
+
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.webhookService.Get(id)
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("getting webhook", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{
+		"User":    middleware.GetUser(r),
+		"Webhook": hook,
+		"Events":  store.WebhookEventCatalog,
+	}
+	if err := h.renderer.Render(w, "webhook_form.html", data); err != nil {
+		h.logger.Error("rendering webhook form", "error", err)
+	}
+}
+
+func (h *WebHandler) HandleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.webhookService.Get(id)
+	if err == nil {
+		var hook *store.Webhook
+		hook, err = h.webhookService.Update(id, webhookRequestFromForm(r))
+		if err == nil {
+			h.audit.Updated(r, store.AuditWebhook, id, before, hook)
+			if hook.Secret != before.Secret {
+				h.renderWebhook(w, r, hook, hook.Secret)
+				return
+			}
+			http.Redirect(w, r, fmt.Sprintf("/webhooks/%d?success=%s", id, url.QueryEscape("Webhook actualizado")), http.StatusSeeOther)
+			return
+		}
+	}
+
+	if !isWebhookValidationError(err) && !errors.Is(err, services.ErrWebhookNotFound) {
+		h.logger.Error("updating webhook", "id", id, "error", err)
+	}
+	http.Redirect(w, r, fmt.Sprintf("/webhooks/%d/edit?error=%s", id, url.QueryEscape(webhookErrorMessage(err))), http.StatusSeeOther)
+}
+
+// HandleTestWebhook sends a ping to the webhook and goes back to its page
+// with the result.
+func (h *WebHandler) HandleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.webhookService.Test(r.Context(), id)
+	if err != nil {
+		msg := "Error al probar el webhook"
+		if errors.Is(err, services.ErrWebhookNotFound) {
+			msg = err.Error()
+		} else {
+			h.logger.Error("testing webhook", "id", id, "error", err)
+		}
+		http.Redirect(w, r, fmt.Sprintf("/webhooks/%d?error=%s", id, url.QueryEscape(msg)), http.StatusSeeOther)
+		return
+	}
+
+	if d.Status == store.WebhookDelivered {
+		http.Redirect(w, r, fmt.Sprintf("/webhooks/%d?success=%s", id, url.QueryEscape(fmt.Sprintf("Prueba entregada: respuesta %d", *d.ResponseStatus))), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/webhooks/%d?error=%s", id, url.QueryEscape("La prueba falló: "+d.LastError)), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.webhookService.Redeliver(id)
+	if errors.Is(err, services.ErrWebhookDeliveryNotFound) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.logger.Error("redelivering webhook", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/webhooks/%d?success=%s", d.WebhookID, url.QueryEscape("Entrega encolada de nuevo")), http.StatusSeeOther)
+}
+
+func (h *WebHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.webhookService.Get(id)
+	if err == nil {
+		err = h.webhookService.Delete(id)
+	}
+	if err != nil {
+		if !errors.Is(err, services.ErrWebhookNotFound) {
+			h.logger.Error("deleting webhook", "id", id, "error", err)
+		}
+		http.Redirect(w, r, "/webhooks?error="+url.QueryEscape(webhookErrorMessage(err)), http.StatusSeeOther)
+		return
+	}
+	h.audit.Deleted(r, store.AuditWebhook, id, hook)
+
+	http.Redirect(w, r, "/webhooks?success="+url.QueryEscape("Webhook eliminado"), http.StatusSeeOther)
+}
+
+func webhookRequestFromForm(r *http.Request) services.WebhookRequest {
+	active := r.FormValue("is_active") == "on"
+	return services.WebhookRequest{
+		Name:     r.FormValue("name"),
+		URL:      r.FormValue("url"),
+		Secret:   r.FormValue("secret"),
+		Events:   r.Form["events[]"],
+		IsActive: &active,
+	}
+}
+
+func webhookErrorMessage(err error) string {
+	if errors.Is(err, services.ErrWebhookNotFound) || isWebhookValidationError(err) {
+		return err.Error()
+	}
+	return "Error al guardar el webhook"
+}
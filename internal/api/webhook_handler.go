@@ -0,0 +1,313 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/services"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+)
+
+type WebhookHandler struct {
+	service *services.WebhookService
+	audit   *audit.Recorder
+	logger  *slog.Logger
+}
+
+func NewWebhookHandler(s *services.WebhookService, a *audit.Recorder, l *slog.Logger) *WebhookHandler {
+	return &WebhookHandler{service: s, audit: a, logger: l}
+}
+
+// HandleListWebhooks godoc
+// @Summary      List webhooks
+// @Description  Responds with every webhook. Secrets are only returned when set.
+// @Tags         webhooks
+// @Produce      json
+// @Success      200  {object}  WebhooksResponse
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/webhooks [get]
+func (h *WebhookHandler) HandleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.service.List()
+	if err != nil {
+		h.logger.Error("listing webhooks", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if hooks == nil {
+		hooks = []*store.Webhook{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"webhooks": hooks}, "", nil)
+}
+
+// HandleGetWebhook godoc
+// @Summary      Get a webhook
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      200  {object}  WebhookResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/webhooks/{id} [get]
+func (h *WebhookHandler) HandleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	hook, err := h.service.Get(id)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"webhook": hook}, "", nil)
+}
+
+// HandleCreateWebhook godoc
+// @Summary      Create a webhook
+// @Description  Business events (order.created, order.state_changed, local_sale.created) are POSTed as JSON to url, signed in X-Aesovoy-Signature with the secret. A secret is generated when none is given; it is only returned here and when it changes.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        body  body      services.WebhookRequest  true  "Webhook data"
+// @Success      201   {object}  WebhookSecretResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/webhooks [post]
+func (h *WebhookHandler) HandleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req services.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	hook, err := h.service.Create(req)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	h.audit.Created(r, store.AuditWebhook, hook.ID, hook)
+	utils.OK(w, http.StatusCreated, utils.Envelope{"webhook": hook, "secret": hook.Secret}, "", nil)
+}
+
+// HandleUpdateWebhook godoc
+// @Summary      Update a webhook
+// @Description  Replaces name, url, events and is_active. The secret is kept unless a new one is given, and then it is returned.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id    path      int                      true  "Webhook ID"
+// @Param        body  body      services.WebhookRequest  true  "Webhook data"
+// @Success      200   {object}  WebhookSecretResponse
+// @Failure      400   {object}  utils.HTTPError
+// @Failure      404   {object}  utils.HTTPError
+// @Failure      500   {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/webhooks/{id} [patch]
+func (h *WebhookHandler) HandleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	var req services.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	before, err := h.service.Get(id)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	hook, err := h.service.Update(id, req)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	h.audit.Updated(r, store.AuditWebhook, id, before, hook)
+	env := utils.Envelope{"webhook": hook}
+	if hook.Secret != before.Secret {
+		env["secret"] = hook.Secret
+	}
+	utils.OK(w, http.StatusOK, env, "", nil)
+}
+
+// HandleDeleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Deletes the webhook with its delivery log; pending deliveries are not sent.
+// @Tags         webhooks
+// @Param        id   path  int  true  "Webhook ID"
+// @Success      204
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/webhooks/{id} [delete]
+func (h *WebhookHandler) HandleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	hook, err := h.service.Get(id)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	if err := h.service.Delete(id); err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	h.audit.Deleted(r, store.AuditWebhook, id, hook)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleTestWebhook godoc
+// @Summary      Test a webhook
+// @Description  Sends a ping event to the webhook right away, even when inactive, and responds with the delivery and its result.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "Webhook ID"
+// @Success      200  {object}  WebhookDeliveryResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/webhooks/{id}/test [post]
+func (h *WebhookHandler) HandleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	d, err := h.service.Test(r.Context(), id)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"delivery": d}, "", nil)
+}
+
+// HandleListWebhookDeliveries godoc
+// @Summary      List a webhook's deliveries
+// @Description  Responds with the delivery log of a webhook, newest first: payload, attempts, next attempt and the last response or error.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id      path      int     true   "Webhook ID"
+// @Param        status  query     string  false  "Status (pending, delivered, failed)"
+// @Param        limit   query     int     false  "Limit (default 50)"
+// @Param        offset  query     int     false  "Offset"
+// @Success      200     {object}  WebhookDeliveriesResponse
+// @Failure      400     {object}  utils.HTTPError
+// @Failure      404     {object}  utils.HTTPError
+// @Failure      500     {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) HandleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	if _, err := h.service.Get(id); err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	q := r.URL.Query()
+	filter := store.WebhookDeliveryFilter{WebhookID: &id, Status: q.Get("status")}
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+	filter.Offset, _ = strconv.Atoi(q.Get("offset"))
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	deliveries, total, err := h.service.Deliveries(filter)
+	if err != nil {
+		h.logger.Error("listing webhook deliveries", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+	if deliveries == nil {
+		deliveries = []*store.WebhookDelivery{}
+	}
+
+	utils.OK(w, http.StatusOK, utils.Envelope{"deliveries": deliveries}, "", &utils.Meta{
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+		Total:  total,
+	})
+}
+
+// HandleRedeliverWebhook godoc
+// @Summary      Redeliver a webhook event
+// @Description  Queues the event of a delivery again, with the same payload and event id, as a new delivery.
+// @Tags         webhooks
+// @Produce      json
+// @Param        id   path      int  true  "Delivery ID"
+// @Success      202  {object}  WebhookDeliveryResponse
+// @Failure      400  {object}  utils.HTTPError
+// @Failure      404  {object}  utils.HTTPError
+// @Failure      500  {object}  utils.HTTPError
+// @Security     BearerAuth
+// @Router       /api/v1/webhooks/deliveries/{id}/redeliver [post]
+func (h *WebhookHandler) HandleRedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIDParam(r)
+	if err != nil {
+		utils.Error(w, http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+
+	d, err := h.service.Redeliver(id)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+
+	utils.OK(w, http.StatusAccepted, utils.Envelope{"delivery": d}, "", nil)
+}
+
+func (h *WebhookHandler) writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, services.ErrWebhookDeliveryNotFound):
+		utils.Error(w, http.StatusNotFound, err.Error())
+	case isWebhookValidationError(err):
+		utils.Error(w, http.StatusBadRequest, err.Error())
+	default:
+		h.logger.Error("saving webhook", "error", err)
+		utils.Error(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func isWebhookValidationError(err error) bool {
+	for _, known := range []error{
+		services.ErrWebhookNameEmpty,
+		services.ErrWebhookInvalidURL,
+		services.ErrWebhookNoEvents,
+		services.ErrWebhookUnknownEvent,
+	} {
+		if errors.Is(err, known) {
+			return true
+		}
+	}
+	return false
+}
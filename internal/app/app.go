@@ -1,46 +1,81 @@
 package app
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/api"
+	"github.com/RamunnoAJ/aesovoy-server/internal/audit"
+	"github.com/RamunnoAJ/aesovoy-server/internal/jobs"
 	"github.com/RamunnoAJ/aesovoy-server/internal/mailer"
 	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/ocr"
+	"github.com/RamunnoAJ/aesovoy-server/internal/receipt"
 	"github.com/RamunnoAJ/aesovoy-server/internal/services"
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	"github.com/RamunnoAJ/aesovoy-server/internal/views"
 	"github.com/RamunnoAJ/aesovoy-server/migrations"
 	lumberjack "gopkg.in/natefinch/lumberjack.v2"
 )
 
+const (
+	// webhookInterval is how often queued webhook deliveries are looked for.
+	webhookInterval = 10 * time.Second
+	// Done jobs are kept this long for the jobs page.
+	jobCleanupInterval = time.Hour
+	jobRetention       = 7 * 24 * time.Hour
+	// readinessTimeout bounds the database ping of the readiness check.
+	readinessTimeout = 2 * time.Second
+)
+
 type Application struct {
-	Logger               *slog.Logger
-	UserHandler          *api.UserHandler
-	TokenHandler         *api.TokenHandler
-	CategoryHandler      *api.CategoryHandler
-	ProductHandler       *api.ProductHandler
-	ClientHandler        *api.ClientHandler
-	ProviderHandler      *api.ProviderHandler
-	OrderHandler         *api.OrderHandler
-	IngredientHandler    *api.IngredientHandler
-	PaymentMethodHandler *api.PaymentMethodHandler
-	LocalStockHandler    *api.LocalStockHandler
-	LocalSaleHandler     *api.LocalSaleHandler
-	InvoiceHandler       *api.InvoiceHandler
-	ExpenseHandler       *api.ExpenseHandler
-	WebHandler           *api.WebHandler
-	Middleware           middleware.UserMiddleware
-	DB                   *sql.DB
+	Logger                 *slog.Logger
+	UserHandler            *api.UserHandler
+	TokenHandler           *api.TokenHandler
+	CategoryHandler        *api.CategoryHandler
+	ProductHandler         *api.ProductHandler
+	ClientHandler          *api.ClientHandler
+	ProviderHandler        *api.ProviderHandler
+	OrderHandler           *api.OrderHandler
+	IngredientHandler      *api.IngredientHandler
+	PaymentMethodHandler   *api.PaymentMethodHandler
+	LocalStockHandler      *api.LocalStockHandler
+	LocalSaleHandler       *api.LocalSaleHandler
+	InvoiceHandler         *api.InvoiceHandler
+	ExpenseHandler         *api.ExpenseHandler
+	WasteHandler           *api.WasteHandler
+	StockLocationHandler   *api.StockLocationHandler
+	ProductionPlanHandler  *api.ProductionPlanHandler
+	PurchaseOrderHandler   *api.PurchaseOrderHandler
+	AccountsPayableHandler *api.AccountsPayableHandler
+	RoleHandler            *api.RoleHandler
+	AuditHandler           *api.AuditHandler
+	APIKeyHandler          *api.APIKeyHandler
+	SecurityHandler        *api.SecurityHandler
+	WebhookHandler         *api.WebhookHandler
+	JobHandler             *api.JobHandler
+	WebHandler             *api.WebHandler
+	Jobs                   *jobs.Runner
+	Middleware             middleware.UserMiddleware
+	DB                     *sql.DB
 }
 
 func NewApplication() (*Application, error) {
 	LOG_FILE := os.Getenv("LOG_FILE")
 
+	if err := utils.SetTrustedProxies(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		return nil, err
+	}
+
 	pgDB, err := store.Open()
 	if err != nil {
 		return nil, err
@@ -81,11 +116,52 @@ func NewApplication() (*Application, error) {
 	expenseStore := store.NewPostgresExpenseStore(pgDB)
 	shiftStore := store.NewPostgresShiftStore(pgDB)
 	cashMovementStore := store.NewPostgresCashMovementStore(pgDB)
+	cashRegisterStore := store.NewPostgresCashRegisterStore(pgDB)
+	stockMovementStore := store.NewPostgresStockMovementStore(pgDB)
+	inventoryCountStore := store.NewPostgresInventoryCountStore(pgDB)
+	wasteStore := store.NewPostgresWasteStore(pgDB)
+	lotStore := store.NewPostgresLotStore(pgDB)
+	stockLocationStore := store.NewPostgresStockLocationStore(pgDB)
+	stockTransferStore := store.NewPostgresStockTransferStore(pgDB)
+	purchaseOrderStore := store.NewPostgresPurchaseOrderStore(pgDB)
+	providerPaymentStore := store.NewPostgresProviderPaymentStore(pgDB)
+	expenseExtractionStore := store.NewPostgresExpenseExtractionStore(pgDB)
+	roleStore := store.NewPostgresRoleStore(pgDB)
+	auditStore := store.NewPostgresAuditStore(pgDB)
+	twoFactorStore := store.NewPostgresTwoFactorStore(pgDB)
+	apiKeyStore := store.NewPostgresAPIKeyStore(pgDB)
+	securityStore := store.NewPostgresSecurityStore(pgDB)
+	webhookStore := store.NewPostgresWebhookStore(pgDB)
+	jobStore := store.NewPostgresJobStore(pgDB)
 
 	// our services will go here
-	localStockService := services.NewLocalStockService(localStockStore, productStore)
-	localSaleService := services.NewLocalSaleService(pgDB, localSaleStore, localStockStore, paymentMethodStore, productStore)
-	shiftService := services.NewShiftService(shiftStore, localSaleStore, cashMovementStore)
+	localStockService := services.NewLocalStockService(localStockStore, productStore, stockMovementStore, lotStore, stockLocationStore)
+	localSaleService := services.NewLocalSaleService(pgDB, localSaleStore, localStockStore, paymentMethodStore, productStore, shiftStore)
+	shiftService := services.NewShiftService(shiftStore, cashRegisterStore, localSaleStore, cashMovementStore)
+	inventoryCountService := services.NewInventoryCountService(pgDB, inventoryCountStore, localStockStore, productStore)
+	wasteService := services.NewWasteService(pgDB, wasteStore, localStockStore, productStore)
+	stockLocationService := services.NewStockLocationService(pgDB, stockLocationStore, stockTransferStore, localStockStore, productStore)
+	productionPlanService := services.NewProductionPlanService(productStore, localStockStore, orderStore, localSaleStore)
+	accountsPayableService := services.NewAccountsPayableService(pgDB, expenseStore, providerPaymentStore, providerStore, paymentMethodStore)
+	expenseService := services.NewExpenseService(pgDB, expenseStore, ingredientStore, expenseExtractionStore)
+	roleService := services.NewRoleService(roleStore)
+	apiKeyService := services.NewAPIKeyService(apiKeyStore)
+	twoFactorService := services.NewTwoFactorService(twoFactorStore, receipt.BusinessFromEnv().Name, services.TwoFactorRolesFromEnv())
+
+	// Receipts are read with a local OCR engine when one is installed.
+	ocrEngine := ocr.FromEnv()
+	if ocrEngine == nil {
+		logger.Info("no OCR engine available, receipt extraction disabled")
+	}
+	expenseExtractionService := services.NewExpenseExtractionService(ocrEngine, expenseExtractionStore, providerStore, receipt.BusinessFromEnv().TaxID)
+
+	// Tickets go to a network thermal printer when one is configured.
+	var receiptPrinter receipt.Printer
+	if addr := os.Getenv("RECEIPT_PRINTER_ADDR"); addr != "" {
+		receiptPrinter = receipt.NewNetworkPrinter(addr)
+	}
+	receiptColumns, _ := strconv.Atoi(os.Getenv("RECEIPT_COLUMNS"))
+	receiptService := services.NewReceiptService(localSaleStore, paymentMethodStore, productStore, receipt.BusinessFromEnv(), receiptPrinter, receiptColumns)
 
 	mailer := mailer.New(
 		os.Getenv("SMTP_HOST"),
@@ -94,52 +170,155 @@ func NewApplication() (*Application, error) {
 		os.Getenv("SMTP_PASSWORD"),
 		os.Getenv("SMTP_FROM"),
 	)
+	// Emails that don't need an answer right away are queued as jobs.
+	queuedMailer := jobs.NewMailer(jobStore)
+	purchaseOrderService := services.NewPurchaseOrderService(pgDB, purchaseOrderStore, providerStore, ingredientStore, expenseStore, mailer, receipt.BusinessFromEnv())
+	loginSecurityService := services.NewLoginSecurityService(securityStore, queuedMailer)
+	pinService := services.NewPINService(securityStore, loginSecurityService)
+	webhookService := services.NewWebhookService(webhookStore)
+
+	// Background jobs: side effects queued by requests and scheduled tasks.
+	jobWorkers, _ := strconv.Atoi(os.Getenv("JOB_WORKERS"))
+	if jobWorkers <= 0 {
+		jobWorkers = 2
+	}
+	jobRunner := jobs.NewRunner(jobStore, jobWorkers, logger)
+	jobRunner.Handle(store.JobGenerateInvoice, jobs.GenerateInvoice(orderStore, clientStore, productStore))
+	jobRunner.Handle(store.JobSendEmail, jobs.SendEmail(mailer))
+	jobRunner.Handle(store.JobSendPasswordReset, jobs.SendPasswordReset(userStore, tokenStore, mailer))
+	jobRunner.Handle(store.JobDeliverWebhooks, jobs.DeliverWebhooks(webhookService))
+	jobRunner.Handle(store.JobCleanupJobs, jobs.CleanupJobs(jobStore, jobRetention))
+	jobRunner.Every(store.JobDeliverWebhooks, webhookInterval)
+	jobRunner.Every(store.JobCleanupJobs, jobCleanupInterval)
+	jobRunner.Start()
 
 	// our handlers will go here
 	renderer := views.NewRenderer()
-	userHandler := api.NewUserHandler(userStore, logger)
-	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
-	middlewareHandler := middleware.UserMiddleware{UserStore: userStore}
-	categoryHandler := api.NewCategoryHandler(categoryStore, logger)
-	productHandler := api.NewProductHandler(productStore, logger)
-	clientHandler := api.NewClientHandler(clientStore, logger)
-	providerHandler := api.NewProviderHandler(providerStore, logger)
-	orderHandler := api.NewOrderHandler(orderStore, clientStore, productStore, logger)
-	ingredientHandler := api.NewIngredientHandler(ingredientStore, logger)
-	paymentMethodHandler := api.NewPaymentMethodHandler(paymentMethodStore, logger)
-	localStockHandler := api.NewLocalStockHandler(localStockService, logger)
-	localSaleHandler := api.NewLocalSaleHandler(localSaleService, logger)
+	auditRecorder := audit.NewRecorder(auditStore, logger)
+
+	userHandler := api.NewUserHandler(userStore, roleStore, auditRecorder, logger)
+	tokenHandler := api.NewTokenHandler(tokenStore, userStore, twoFactorService, loginSecurityService, auditRecorder, logger)
+	middlewareHandler := middleware.UserMiddleware{UserStore: userStore, TokenStore: tokenStore, APIKeyStore: apiKeyStore, Logger: logger}
+	categoryHandler := api.NewCategoryHandler(categoryStore, auditRecorder, logger)
+	productHandler := api.NewProductHandler(productStore, auditRecorder, logger)
+	clientHandler := api.NewClientHandler(clientStore, auditRecorder, logger)
+	providerHandler := api.NewProviderHandler(providerStore, auditRecorder, logger)
+	orderHandler := api.NewOrderHandler(orderStore, auditRecorder, logger)
+	ingredientHandler := api.NewIngredientHandler(ingredientStore, auditRecorder, logger)
+	paymentMethodHandler := api.NewPaymentMethodHandler(paymentMethodStore, auditRecorder, logger)
+	localStockHandler := api.NewLocalStockHandler(localStockService, auditRecorder, logger)
+	localSaleHandler := api.NewLocalSaleHandler(localSaleService, receiptService, auditRecorder, logger)
 	invoiceHandler := api.NewInvoiceHandler(renderer)
-	expenseHandler := api.NewExpenseHandler(expenseStore, logger)
-	webHandler := api.NewWebHandler(
-		userStore, tokenStore, productStore, categoryStore, ingredientStore,
-		clientStore, providerStore, paymentMethodStore, orderStore, expenseStore,
-		localStockService, localSaleService, shiftService, mailer, logger,
-	)
+	expenseHandler := api.NewExpenseHandler(expenseStore, expenseService, expenseExtractionService, auditRecorder, logger)
+	wasteHandler := api.NewWasteHandler(wasteService, auditRecorder, logger)
+	stockLocationHandler := api.NewStockLocationHandler(stockLocationService, auditRecorder, logger)
+	productionPlanHandler := api.NewProductionPlanHandler(productionPlanService, logger)
+	purchaseOrderHandler := api.NewPurchaseOrderHandler(purchaseOrderService, auditRecorder, logger)
+	accountsPayableHandler := api.NewAccountsPayableHandler(accountsPayableService, auditRecorder, logger)
+	roleHandler := api.NewRoleHandler(roleService, auditRecorder, logger)
+	auditHandler := api.NewAuditHandler(auditStore, logger)
+	apiKeyHandler := api.NewAPIKeyHandler(apiKeyService, auditRecorder, logger)
+	securityHandler := api.NewSecurityHandler(loginSecurityService, userStore, auditRecorder, logger)
+	webhookHandler := api.NewWebhookHandler(webhookService, auditRecorder, logger)
+	jobHandler := api.NewJobHandler(jobStore, auditRecorder, logger)
+	webHandler := api.NewWebHandler(api.WebHandlerDeps{
+		UserStore:          userStore,
+		TokenStore:         tokenStore,
+		ProductStore:       productStore,
+		CategoryStore:      categoryStore,
+		IngredientStore:    ingredientStore,
+		ClientStore:        clientStore,
+		ProviderStore:      providerStore,
+		PaymentMethodStore: paymentMethodStore,
+		OrderStore:         orderStore,
+		ExpenseStore:       expenseStore,
+		LocalStockService:  localStockService,
+		LocalSaleService:   localSaleService,
+		ShiftService:       shiftService,
+		ReceiptService:     receiptService,
+		InventoryService:   inventoryCountService,
+		WasteService:       wasteService,
+		LocationService:    stockLocationService,
+		PlanService:        productionPlanService,
+		PurchaseService:    purchaseOrderService,
+		PayablesService:    accountsPayableService,
+		ExpenseService:     expenseService,
+		ExtractionService:  expenseExtractionService,
+		RoleService:        roleService,
+		TwoFactorService:   twoFactorService,
+		APIKeyService:      apiKeyService,
+		LoginSecurity:      loginSecurityService,
+		PINService:         pinService,
+		WebhookService:     webhookService,
+		JobStore:           jobStore,
+		AuditStore:         auditStore,
+		Audit:              auditRecorder,
+		Logger:             logger,
+	})
 
 	app := &Application{
-		Logger:               logger,
-		UserHandler:          userHandler,
-		TokenHandler:         tokenHandler,
-		Middleware:           middlewareHandler,
-		CategoryHandler:      categoryHandler,
-		ProductHandler:       productHandler,
-		ClientHandler:        clientHandler,
-		ProviderHandler:      providerHandler,
-		OrderHandler:         orderHandler,
-		IngredientHandler:    ingredientHandler,
-		PaymentMethodHandler: paymentMethodHandler,
-		LocalStockHandler:    localStockHandler,
-		LocalSaleHandler:     localSaleHandler,
-		InvoiceHandler:       invoiceHandler,
-		ExpenseHandler:       expenseHandler,
-		WebHandler:           webHandler,
-		DB:                   pgDB,
+		Logger:                 logger,
+		UserHandler:            userHandler,
+		TokenHandler:           tokenHandler,
+		Middleware:             middlewareHandler,
+		CategoryHandler:        categoryHandler,
+		ProductHandler:         productHandler,
+		ClientHandler:          clientHandler,
+		ProviderHandler:        providerHandler,
+		OrderHandler:           orderHandler,
+		IngredientHandler:      ingredientHandler,
+		PaymentMethodHandler:   paymentMethodHandler,
+		LocalStockHandler:      localStockHandler,
+		LocalSaleHandler:       localSaleHandler,
+		InvoiceHandler:         invoiceHandler,
+		ExpenseHandler:         expenseHandler,
+		WasteHandler:           wasteHandler,
+		StockLocationHandler:   stockLocationHandler,
+		ProductionPlanHandler:  productionPlanHandler,
+		PurchaseOrderHandler:   purchaseOrderHandler,
+		AccountsPayableHandler: accountsPayableHandler,
+		RoleHandler:            roleHandler,
+		AuditHandler:           auditHandler,
+		APIKeyHandler:          apiKeyHandler,
+		SecurityHandler:        securityHandler,
+		WebhookHandler:         webhookHandler,
+		JobHandler:             jobHandler,
+		WebHandler:             webHandler,
+		Jobs:                   jobRunner,
+		DB:                     pgDB,
 	}
 
 	return app, nil
 }
 
-func (a *Application) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	fmt.Fprintf(w, "Status is available\n")
+// Shutdown stops the background workers, waiting for the running jobs until
+// ctx is done, and closes the database pool.
+func (a *Application) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := a.Jobs.Stop(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("stopping jobs: %w", err))
+	}
+	if err := a.DB.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing database: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// HandleLiveness reports that the process is up and serving requests.
+func (a *Application) HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	utils.OK(w, http.StatusOK, utils.Envelope{"status": "ok"}, "", nil)
+}
+
+// HandleReadiness reports whether the server can take traffic, which is
+// when the database answers.
+func (a *Application) HandleReadiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := a.DB.PingContext(ctx); err != nil {
+		a.Logger.Warn("readiness check failed", "error", err)
+		utils.Error(w, http.StatusServiceUnavailable, "database unavailable")
+		return
+	}
+	utils.OK(w, http.StatusOK, utils.Envelope{"status": "ok"}, "", nil)
 }
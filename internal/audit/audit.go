@@ -0,0 +1,150 @@
+// Package audit records who changed what in the audit log.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// Recorder appends the changes made by a request to the audit log, with the
+// authenticated user, the request ID and the client IP. Failing to record is
+// logged but doesn't fail the request, the change is already saved.
+//
+// A nil Recorder records nothing.
+type Recorder struct {
+	store  store.AuditStore
+	logger *slog.Logger
+}
+
+func NewRecorder(s store.AuditStore, l *slog.Logger) *Recorder {
+	return &Recorder{store: s, logger: l}
+}
+
+// Created records a new entity.
+func (a *Recorder) Created(r *http.Request, entity string, id int64, after any) {
+	a.Record(r, entity, id, store.AuditCreate, nil, after)
+}
+
+// Updated records the fields that changed between before and after. Nothing
+// is recorded when none did.
+func (a *Recorder) Updated(r *http.Request, entity string, id int64, before, after any) {
+	a.Record(r, entity, id, store.AuditUpdate, before, after)
+}
+
+// Deleted records a deleted entity, soft deletes included.
+func (a *Recorder) Deleted(r *http.Request, entity string, id int64, before any) {
+	a.Record(r, entity, id, store.AuditDelete, before, nil)
+}
+
+// Record appends an entry for action. When both before and after are given
+// only the fields that differ are kept.
+func (a *Recorder) Record(r *http.Request, entity string, id int64, action string, before, after any) {
+	if a == nil {
+		return
+	}
+
+	b, err := marshal(before)
+	if err != nil {
+		a.logger.Error("marshaling audit entry", "entity", entity, "id", id, "error", err)
+		return
+	}
+	f, err := marshal(after)
+	if err != nil {
+		a.logger.Error("marshaling audit entry", "entity", entity, "id", id, "error", err)
+		return
+	}
+	if b != nil && f != nil {
+		if b, f = Diff(b, f); b == nil && action == store.AuditUpdate {
+			return
+		}
+	}
+
+	entry := &store.AuditEntry{
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+		Before:    b,
+		After:     f,
+		RequestID: chimw.GetReqID(r.Context()),
+		IP:        utils.ClientIP(r),
+	}
+	if user, ok := r.Context().Value(middleware.UserContextKey).(*store.User); ok && !user.IsAnonymous() {
+		entry.UserID = &user.ID
+		entry.Username = user.Username
+		if user.APIKey != nil {
+			entry.APIKeyID = &user.APIKey.ID
+		}
+	}
+
+	if err := a.store.Insert(entry); err != nil {
+		a.logger.Error("recording audit entry", "entity", entity, "id", id, "action", action, "error", err)
+	}
+}
+
+func marshal(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// Diff reduces two JSON objects to the fields whose values differ, present
+// in either. It returns nil, nil when they are equal. Values that aren't
+// objects are returned as they are.
+func Diff(before, after json.RawMessage) (json.RawMessage, json.RawMessage) {
+	var b, f map[string]json.RawMessage
+	if json.Unmarshal(before, &b) != nil || json.Unmarshal(after, &f) != nil {
+		if bytes.Equal(before, after) {
+			return nil, nil
+		}
+		return before, after
+	}
+
+	changedBefore := map[string]json.RawMessage{}
+	changedAfter := map[string]json.RawMessage{}
+	for k, v := range b {
+		if w, ok := f[k]; !ok || !jsonEqual(v, w) {
+			changedBefore[k] = v
+			if ok {
+				changedAfter[k] = w
+			}
+		}
+	}
+	for k, w := range f {
+		if _, ok := b[k]; !ok {
+			changedAfter[k] = w
+		}
+	}
+	if len(changedBefore) == 0 && len(changedAfter) == 0 {
+		return nil, nil
+	}
+
+	// Marshaling maps of raw messages can't fail.
+	db, _ := json.Marshal(changedBefore)
+	da, _ := json.Marshal(changedAfter)
+	return db, da
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return bytes.Equal(a, b)
+	}
+	xa, _ := json.Marshal(x)
+	ya, _ := json.Marshal(y)
+	return bytes.Equal(xa, ya)
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     string
+		after      string
+		wantBefore string
+		wantAfter  string
+	}{
+		{
+			name:       "changed fields only",
+			before:     `{"id":1,"name":"Torta","unit_price":5000,"barcodes":["779"]}`,
+			after:      `{"id":1,"name":"Torta","unit_price":5500,"barcodes":["779"]}`,
+			wantBefore: `{"unit_price":5000}`,
+			wantAfter:  `{"unit_price":5500}`,
+		},
+		{
+			name:       "added and removed fields",
+			before:     `{"id":1,"deleted_at":null}`,
+			after:      `{"id":1,"deleted_at":"2026-10-18T10:00:00Z","state":"paid"}`,
+			wantBefore: `{"deleted_at":null}`,
+			wantAfter:  `{"deleted_at":"2026-10-18T10:00:00Z","state":"paid"}`,
+		},
+		{
+			name:       "nested values compared by content",
+			before:     `{"items":[{"id":1, "quantity":2}]}`,
+			after:      `{"items":[{"quantity":2,"id":1}]}`,
+			wantBefore: ``,
+			wantAfter:  ``,
+		},
+		{
+			name:       "not objects",
+			before:     `"pending"`,
+			after:      `"paid"`,
+			wantBefore: `"pending"`,
+			wantAfter:  `"paid"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, a := Diff(json.RawMessage(tt.before), json.RawMessage(tt.after))
+			if tt.wantBefore == "" {
+				assert.Nil(t, b)
+				assert.Nil(t, a)
+				return
+			}
+			assert.JSONEq(t, tt.wantBefore, string(b))
+			assert.JSONEq(t, tt.wantAfter, string(a))
+		})
+	}
+}
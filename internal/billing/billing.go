@@ -11,6 +11,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
@@ -37,6 +38,16 @@ func init() {
 	invoiceDir = filepath.Join(projectRoot, "facturas")
 }
 
+// dayLocks has a mutex per day's file. GenerateInvoice reads, changes and
+// saves the whole file, so two orders of the same day would lose one.
+var dayLocks sync.Map
+
+func lockDay(dateStr string) func() {
+	mu, _ := dayLocks.LoadOrStore(dateStr, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
 const (
 	templateSheet = "Hoja1"
 	itemsStartRow = 12
@@ -118,9 +129,11 @@ func GetInvoicePath(filename string) (string, error) {
 }
 
 // GenerateInvoice creates or updates an Excel invoice file based on an order.
+// Orders of the same day are added one at a time.
 func GenerateInvoice(order *store.Order, client *store.Client, products map[int64]*store.Product) error {
 	// 1. Determine filename
 	dateStr := order.Date.Format("2006-01-02")
+	defer lockDay(dateStr)()
 	fileName := fmt.Sprintf("remito_produccion-%s.xlsx", dateStr)
 	filePath := filepath.Join(invoiceDir, fileName)
 
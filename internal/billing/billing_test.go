@@ -12,6 +12,7 @@ import (
 	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/stretchr/testify/require"
+	excelize "github.com/xuri/excelize/v2"
 	_ "golang.org/x/image/webp"
 )
 
@@ -222,3 +223,38 @@ func TestListInvoices(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateInvoiceConcurrentOrdersOfOneDay(t *testing.T) {
+	originalInvoiceDir := invoiceDir
+	invoiceDir = t.TempDir()
+	defer func() { invoiceDir = originalInvoiceDir }()
+
+	product := &store.Product{ID: 1, Name: "Producto A"}
+	products := map[int64]*store.Product{product.ID: product}
+	date := time.Date(2001, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	clients := []*store.Client{{ID: 1, Name: "Cliente Uno"}, {ID: 2, Name: "Cliente Dos"}}
+	errs := make(chan error, len(clients))
+	for i, c := range clients {
+		order := &store.Order{
+			ID:       int64(i + 1),
+			ClientID: c.ID,
+			Date:     date,
+			State:    store.OrderTodo,
+			Items:    []store.OrderItem{{ProductID: product.ID, Quantity: 1, Price: "10"}},
+		}
+		go func() { errs <- GenerateInvoice(order, c, products) }()
+	}
+	for range clients {
+		require.NoError(t, <-errs)
+	}
+
+	f, err := excelize.OpenFile(filepath.Join(invoiceDir, "remito_produccion-2001-03-01.xlsx"))
+	require.NoError(t, err)
+	defer f.Close()
+	for _, c := range clients {
+		idx, err := f.GetSheetIndex(getSheetName(c.Name))
+		require.NoError(t, err)
+		require.NotEqual(t, -1, idx, "falta la hoja de %s", c.Name)
+	}
+}
@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/billing"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+)
+
+// Sender sends templated emails; *mailer.Mailer satisfies it.
+type Sender interface {
+	Send(to, templateFile string, data any) error
+}
+
+// Mailer queues emails as jobs instead of sending them while the request
+// waits, so a slow or failing SMTP server is retried in the background. It
+// satisfies services.Mailer.
+type Mailer struct {
+	store store.JobStore
+}
+
+func NewMailer(s store.JobStore) *Mailer {
+	return &Mailer{store: s}
+}
+
+// Send queues the email. data is kept as JSON, so templates see structs as
+// maps with the same field names. It is shown on the jobs page, so it must
+// not carry credentials; see SendPasswordReset.
+func (m *Mailer) Send(to, templateFile string, data any) error {
+	job, err := store.NewJob(store.JobSendEmail, store.EmailJob{To: to, Template: templateFile, Data: data})
+	if err != nil {
+		return err
+	}
+	if _, err := m.store.Enqueue(job); err != nil {
+		return fmt.Errorf("error queueing email: %w", err)
+	}
+	return nil
+}
+
+// SendEmail sends the emails queued by Mailer.
+func SendEmail(sender Sender) Handler {
+	return func(ctx context.Context, job *store.Job) error {
+		var p store.EmailJob
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("%w: bad payload: %v", ErrSkip, err)
+		}
+		return sender.Send(p.To, p.Template, p.Data)
+	}
+}
+
+// passwordResetTTL is how long a password reset link works.
+const passwordResetTTL = time.Hour
+
+// SendPasswordReset emails a password reset link. The token is created here
+// rather than by the request so it is only ever stored hashed.
+func SendPasswordReset(users store.UserStore, tokenStore store.TokenStore, sender Sender) Handler {
+	return func(ctx context.Context, job *store.Job) error {
+		var p store.PasswordResetJob
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("%w: bad payload: %v", ErrSkip, err)
+		}
+
+		user, err := users.GetUserByID(p.UserID)
+		if err != nil {
+			return fmt.Errorf("getting user: %w", err)
+		}
+		if user == nil || user.Email == "" {
+			return fmt.Errorf("%w: user %d not found", ErrSkip, p.UserID)
+		}
+
+		token, err := tokenStore.CreateNewToken(int(user.ID), passwordResetTTL, tokens.ScopePasswordReset)
+		if err != nil {
+			return fmt.Errorf("creating token: %w", err)
+		}
+		data := map[string]any{
+			"Link": fmt.Sprintf("%s/reset-password?token=%s", p.BaseURL, token.Plaintext),
+		}
+		return sender.Send(user.Email, "password_reset.tmpl", data)
+	}
+}
+
+// GenerateInvoice adds a new order to the day's remito file.
+func GenerateInvoice(orders store.OrderStore, clients store.ClientStore, products store.ProductStore) Handler {
+	return func(ctx context.Context, job *store.Job) error {
+		var p store.InvoiceJob
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("%w: bad payload: %v", ErrSkip, err)
+		}
+
+		order, err := orders.GetOrderByID(p.OrderID)
+		if err != nil {
+			return fmt.Errorf("getting order: %w", err)
+		}
+		if order == nil {
+			return fmt.Errorf("%w: order %d not found", ErrSkip, p.OrderID)
+		}
+		client, err := clients.GetClientByID(order.ClientID)
+		if err != nil {
+			return fmt.Errorf("getting client: %w", err)
+		}
+		if client == nil {
+			return fmt.Errorf("%w: client %d not found", ErrSkip, order.ClientID)
+		}
+
+		ids := make([]int64, len(order.Items))
+		for i, it := range order.Items {
+			ids[i] = it.ProductID
+		}
+		byID, err := products.GetProductsByIDs(ids)
+		if err != nil {
+			return fmt.Errorf("getting products: %w", err)
+		}
+
+		return billing.GenerateInvoice(order, client, byID)
+	}
+}
+
+// Deliverer sends the webhook deliveries that are due;
+// *services.WebhookService satisfies it.
+type Deliverer interface {
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+// DeliverWebhooks sends the queued webhook deliveries. Their retries are
+// kept by the deliveries themselves, so it is scheduled with Every.
+func DeliverWebhooks(d Deliverer) Handler {
+	return func(ctx context.Context, job *store.Job) error {
+		_, err := d.DeliverDue(ctx)
+		return err
+	}
+}
+
+// CleanupJobs deletes the jobs done more than keep ago.
+func CleanupJobs(s store.JobStore, keep time.Duration) Handler {
+	return func(ctx context.Context, job *store.Job) error {
+		_, err := s.DeleteFinished(time.Now().Add(-keep))
+		return err
+	}
+}
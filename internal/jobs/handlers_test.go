@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUserStore struct {
+	store.UserStore
+	users map[int64]*store.User
+}
+
+func (s *fakeUserStore) GetUserByID(id int64) (*store.User, error) {
+	return s.users[id], nil
+}
+
+type fakeTokenStore struct {
+	store.TokenStore
+	created []*tokens.Token
+}
+
+func (s *fakeTokenStore) CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
+	token, err := tokens.GenerateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+	s.created = append(s.created, token)
+	return token, nil
+}
+
+type sentEmail struct {
+	to, template string
+	data         any
+}
+
+type fakeSender struct {
+	sent []sentEmail
+}
+
+func (s *fakeSender) Send(to, templateFile string, data any) error {
+	s.sent = append(s.sent, sentEmail{to: to, template: templateFile, data: data})
+	return nil
+}
+
+func TestSendPasswordReset(t *testing.T) {
+	users := &fakeUserStore{users: map[int64]*store.User{7: {ID: 7, Email: "ana@example.com"}}}
+	tokenStore := &fakeTokenStore{}
+	sender := &fakeSender{}
+	h := SendPasswordReset(users, tokenStore, sender)
+
+	job, err := store.NewJob(store.JobSendPasswordReset, store.PasswordResetJob{UserID: 7, BaseURL: "https://aesovoy.test"})
+	require.NoError(t, err)
+	// The payload has nothing to log in with.
+	assert.NotContains(t, string(job.Payload), "token")
+
+	require.NoError(t, h(context.Background(), job))
+	require.Len(t, tokenStore.created, 1)
+	token := tokenStore.created[0]
+	assert.Equal(t, tokens.ScopePasswordReset, token.Scope)
+	assert.Equal(t, 7, token.UserID)
+
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, "ana@example.com", sender.sent[0].to)
+	assert.Equal(t, "password_reset.tmpl", sender.sent[0].template)
+	link := sender.sent[0].data.(map[string]any)["Link"].(string)
+	assert.Equal(t, "https://aesovoy.test/reset-password?token="+token.Plaintext, link)
+
+	// Users deleted since can never get it.
+	job, err = store.NewJob(store.JobSendPasswordReset, store.PasswordResetJob{UserID: 8})
+	require.NoError(t, err)
+	assert.ErrorIs(t, h(context.Background(), job), ErrSkip)
+	assert.Len(t, sender.sent, 1)
+}
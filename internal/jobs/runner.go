@@ -0,0 +1,219 @@
+// Package jobs runs the background work queued in the jobs table: side
+// effects of requests, like invoices and emails, and scheduled tasks.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+const (
+	// pollInterval is how long an idle worker waits before looking for due
+	// jobs again.
+	pollInterval = 2 * time.Second
+	// lease is how long a job is left to the worker running it before
+	// another one takes it, well over what any job takes.
+	lease     = 5 * time.Minute
+	retryBase = 30 * time.Second
+	retryMax  = time.Hour
+)
+
+// ErrSkip is returned, wrapped with the reason, by handlers of jobs that can
+// never succeed, like one about a deleted order. The job is marked done.
+var ErrSkip = errors.New("job skipped")
+
+// Handler runs a job. Returning an error retries it later, until it runs
+// out of attempts.
+type Handler func(ctx context.Context, job *store.Job) error
+
+type schedule struct {
+	kind     string
+	interval time.Duration
+}
+
+// Runner claims due jobs with a few workers and runs them with the handler
+// of their kind. Every server runs one; SKIP LOCKED keeps them from taking
+// the same job.
+type Runner struct {
+	store     store.JobStore
+	logger    *slog.Logger
+	workers   int
+	handlers  map[string]Handler
+	schedules []schedule
+
+	// stop ends the loops, abort cancels the jobs running.
+	stop  context.CancelFunc
+	abort context.CancelFunc
+	wg    sync.WaitGroup
+}
+
+func NewRunner(s store.JobStore, workers int, l *slog.Logger) *Runner {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Runner{store: s, logger: l, workers: workers, handlers: map[string]Handler{}}
+}
+
+// Handle sets the handler of a kind of job. Call it before Start.
+func (r *Runner) Handle(kind string, h Handler) {
+	r.handlers[kind] = h
+}
+
+// Every queues a job of kind each interval, unless one is still pending or
+// running. Its handler is set with Handle. Call it before Start.
+func (r *Runner) Every(kind string, interval time.Duration) {
+	r.schedules = append(r.schedules, schedule{kind: kind, interval: interval})
+}
+
+// Start launches the workers and the schedules.
+func (r *Runner) Start() {
+	loopCtx, stop := context.WithCancel(context.Background())
+	jobCtx, abort := context.WithCancel(context.Background())
+	r.stop, r.abort = stop, abort
+
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.work(loopCtx, jobCtx)
+		}()
+	}
+	for _, s := range r.schedules {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.schedule(loopCtx, s)
+		}()
+	}
+}
+
+// Stop stops taking jobs and waits for the running ones to finish. When ctx
+// is done first they are canceled and put back in the queue, and ctx's error
+// is returned.
+func (r *Runner) Stop(ctx context.Context) error {
+	if r.stop == nil {
+		return nil
+	}
+	r.stop()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.abort()
+		return nil
+	case <-ctx.Done():
+		r.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) work(loopCtx, jobCtx context.Context) {
+	for loopCtx.Err() == nil {
+		jobs, err := r.store.Claim(1, lease)
+		if err != nil {
+			r.logger.Error("claiming jobs", "error", err)
+		}
+		if len(jobs) == 0 {
+			select {
+			case <-loopCtx.Done():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		r.run(jobCtx, jobs[0])
+	}
+}
+
+// run runs a claimed job and saves the result.
+func (r *Runner) run(ctx context.Context, job *store.Job) {
+	err := r.call(ctx, job)
+	if errors.Is(err, ErrSkip) {
+		r.logger.Warn("job skipped", "job_id", job.ID, "kind", job.Kind, "reason", err)
+		err = nil
+	}
+	if err == nil {
+		if err := r.store.Complete(job.ID); err != nil {
+			r.logger.Error("completing job", "job_id", job.ID, "kind", job.Kind, "error", err)
+		}
+		return
+	}
+
+	// Cut by a shutdown: the job didn't get its chance, so it is queued
+	// again as it was.
+	if ctx.Err() != nil {
+		if err := r.store.Release([]int64{job.ID}); err != nil {
+			r.logger.Error("releasing job", "job_id", job.ID, "kind", job.Kind, "error", err)
+		}
+		return
+	}
+
+	if err := r.store.Fail(job, err.Error(), time.Now().Add(retryDelay(job.Attempts))); err != nil {
+		r.logger.Error("saving failed job", "job_id", job.ID, "kind", job.Kind, "error", err)
+		return
+	}
+	if job.Status == store.JobDead {
+		r.logger.Error("job failed for good", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", job.LastError)
+	} else {
+		r.logger.Warn("job failed, will retry", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", job.LastError)
+	}
+}
+
+// call runs the job's handler, turning a panic into an error.
+func (r *Runner) call(ctx context.Context, job *store.Job) (err error) {
+	h, ok := r.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %q", job.Kind)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, job)
+}
+
+func (r *Runner) schedule(ctx context.Context, s schedule) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// A failed run waits for the next tick instead of being retried.
+		job := &store.Job{Kind: s.kind, UniqueKey: s.kind, MaxAttempts: 1}
+		if _, err := r.store.Enqueue(job); err != nil {
+			r.logger.Error("queueing scheduled job", "kind", s.kind, "error", err)
+		}
+	}
+}
+
+// retryDelay is how long to wait after attempts failed: 30 seconds, then
+// twice as long every time, up to an hour.
+func retryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	d := retryBase
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= retryMax {
+			return retryMax
+		}
+	}
+	return d
+}
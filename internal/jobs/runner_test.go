@@ -0,0 +1,271 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJobStore keeps jobs in memory the way PostgresJobStore keeps them in
+// the jobs table.
+type fakeJobStore struct {
+	mu   sync.Mutex
+	jobs map[int64]*store.Job
+	next int64
+}
+
+func newFakeJobStore() *fakeJobStore {
+	return &fakeJobStore{jobs: map[int64]*store.Job{}}
+}
+
+func (s *fakeJobStore) Enqueue(j *store.Job) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j.UniqueKey != "" {
+		for _, other := range s.jobs {
+			if other.UniqueKey == j.UniqueKey && (other.Status == store.JobPending || other.Status == store.JobRunning) {
+				return false, nil
+			}
+		}
+	}
+	s.next++
+	j.ID = s.next
+	j.Status = store.JobPending
+	if j.MaxAttempts == 0 {
+		j.MaxAttempts = store.JobDefaultMaxAttempts
+	}
+	if j.RunAt.IsZero() {
+		j.RunAt = time.Now()
+	}
+	stored := *j
+	s.jobs[j.ID] = &stored
+	return true, nil
+}
+
+func (s *fakeJobStore) Claim(limit int, lease time.Duration) ([]*store.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var claimed []*store.Job
+	for id := int64(1); id <= s.next && len(claimed) < limit; id++ {
+		j, ok := s.jobs[id]
+		if !ok || j.Status != store.JobPending || j.RunAt.After(time.Now()) {
+			continue
+		}
+		lockedUntil := time.Now().Add(lease)
+		j.Status = store.JobRunning
+		j.Attempts++
+		j.LockedUntil = &lockedUntil
+		c := *j
+		claimed = append(claimed, &c)
+	}
+	return claimed, nil
+}
+
+func (s *fakeJobStore) Complete(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	j := s.jobs[id]
+	j.Status, j.LockedUntil, j.FinishedAt = store.JobDone, nil, &now
+	return nil
+}
+
+func (s *fakeJobStore) Fail(j *store.Job, errMsg string, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.jobs[j.ID]
+	stored.Status, stored.RunAt, stored.LockedUntil, stored.LastError = store.JobPending, retryAt, nil, errMsg
+	if stored.Attempts >= stored.MaxAttempts {
+		now := time.Now()
+		stored.Status, stored.FinishedAt = store.JobDead, &now
+	}
+	j.Status, j.RunAt, j.LastError, j.FinishedAt = stored.Status, stored.RunAt, stored.LastError, stored.FinishedAt
+	return nil
+}
+
+func (s *fakeJobStore) Release(ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if j := s.jobs[id]; j != nil && j.Status == store.JobRunning {
+			j.Status, j.Attempts, j.RunAt, j.LockedUntil = store.JobPending, max(j.Attempts-1, 0), time.Now(), nil
+		}
+	}
+	return nil
+}
+
+func (s *fakeJobStore) Retry(id int64) error {
+	return errors.New("not implemented")
+}
+
+func (s *fakeJobStore) GetByID(id int64) (*store.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	c := *j
+	return &c, nil
+}
+
+func (s *fakeJobStore) List(f store.JobFilter) ([]*store.Job, int, error) {
+	return nil, 0, errors.New("not implemented")
+}
+
+func (s *fakeJobStore) CountByStatus() (map[string]int, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *fakeJobStore) DeleteFinished(before time.Time) (int, error) {
+	return 0, errors.New("not implemented")
+}
+
+func newTestRunner(s store.JobStore) *Runner {
+	return NewRunner(s, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// enqueue queues a job of kind, failing the test on error.
+func enqueue(t *testing.T, s *fakeJobStore, kind string, maxAttempts int) int64 {
+	t.Helper()
+	j := &store.Job{Kind: kind, MaxAttempts: maxAttempts}
+	_, err := s.Enqueue(j)
+	require.NoError(t, err)
+	return j.ID
+}
+
+// waitForStatus waits until the job is in status and returns it.
+func waitForStatus(t *testing.T, s *fakeJobStore, id int64, status string) *store.Job {
+	t.Helper()
+	var j *store.Job
+	require.Eventually(t, func() bool {
+		j, _ = s.GetByID(id)
+		return j.Status == status
+	}, 2*time.Second, 10*time.Millisecond, "job %d never got %s", id, status)
+	return j
+}
+
+func TestRunnerRunsJobs(t *testing.T) {
+	s := newFakeJobStore()
+	r := newTestRunner(s)
+
+	ran := make(chan int64, 1)
+	r.Handle("ok", func(ctx context.Context, job *store.Job) error {
+		ran <- job.ID
+		return nil
+	})
+	r.Handle("skip", func(ctx context.Context, job *store.Job) error {
+		return fmt.Errorf("order deleted: %w", ErrSkip)
+	})
+	r.Handle("fail", func(ctx context.Context, job *store.Job) error {
+		return errors.New("boom")
+	})
+	r.Handle("panic", func(ctx context.Context, job *store.Job) error {
+		panic("nil map")
+	})
+
+	ok := enqueue(t, s, "ok", 0)
+	skipped := enqueue(t, s, "skip", 0)
+	dead := enqueue(t, s, "fail", 1)
+	retried := enqueue(t, s, "fail", 3)
+	panicked := enqueue(t, s, "panic", 1)
+	unknown := enqueue(t, s, "unknown", 1)
+
+	r.Start()
+	defer r.Stop(context.Background())
+
+	assert.Equal(t, ok, <-ran)
+	j := waitForStatus(t, s, ok, store.JobDone)
+	assert.Equal(t, 1, j.Attempts)
+	assert.NotNil(t, j.FinishedAt)
+
+	// Skipped jobs can never succeed, so they are done too.
+	j = waitForStatus(t, s, skipped, store.JobDone)
+	assert.Empty(t, j.LastError)
+
+	j = waitForStatus(t, s, dead, store.JobDead)
+	assert.Equal(t, "boom", j.LastError)
+	assert.Equal(t, 1, j.Attempts)
+
+	// With attempts left it is due again after the backoff.
+	require.Eventually(t, func() bool {
+		j, _ = s.GetByID(retried)
+		return j.LastError != ""
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, store.JobPending, j.Status)
+	assert.Equal(t, 1, j.Attempts)
+	assert.True(t, j.RunAt.After(time.Now().Add(20*time.Second)))
+
+	j = waitForStatus(t, s, panicked, store.JobDead)
+	assert.Equal(t, "panic: nil map", j.LastError)
+
+	j = waitForStatus(t, s, unknown, store.JobDead)
+	assert.Equal(t, `no handler for job kind "unknown"`, j.LastError)
+}
+
+func TestRunnerStopWaitsForRunningJobs(t *testing.T) {
+	s := newFakeJobStore()
+	r := newTestRunner(s)
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	r.Handle("slow", func(ctx context.Context, job *store.Job) error {
+		close(started)
+		<-finish
+		return nil
+	})
+	id := enqueue(t, s, "slow", 0)
+
+	r.Start()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- r.Stop(context.Background()) }()
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned before the job finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(finish)
+	require.NoError(t, <-stopped)
+	j, _ := s.GetByID(id)
+	assert.Equal(t, store.JobDone, j.Status)
+}
+
+func TestRunnerStopTimeoutReleasesJobs(t *testing.T) {
+	s := newFakeJobStore()
+	r := newTestRunner(s)
+
+	started := make(chan struct{})
+	r.Handle("stuck", func(ctx context.Context, job *store.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	id := enqueue(t, s, "stuck", 1)
+
+	r.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, r.Stop(ctx), context.DeadlineExceeded)
+
+	// Canceled by the shutdown, the job is due again without losing the
+	// attempt, even though it only had one.
+	j, _ := s.GetByID(id)
+	assert.Equal(t, store.JobPending, j.Status)
+	assert.Zero(t, j.Attempts)
+	assert.Empty(t, j.LastError)
+	assert.Nil(t, j.LockedUntil)
+}
@@ -2,8 +2,10 @@ package middleware
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
@@ -11,7 +13,44 @@ import (
 )
 
 type UserMiddleware struct {
-	UserStore store.UserStore
+	UserStore   store.UserStore
+	TokenStore  store.TokenStore
+	APIKeyStore store.APIKeyStore
+	Logger      *slog.Logger
+}
+
+// AuthCookie holds the web session token.
+const AuthCookie = "auth_token"
+
+// SetAuthCookie stores the web session token in the browser until expiry.
+func SetAuthCookie(w http.ResponseWriter, r *http.Request, token string, expiry time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AuthCookie,
+		Value:    token,
+		Expires:  expiry,
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+	})
+}
+
+// POSOperatorCookie holds the token of the employee operating this device's
+// POS with their PIN.
+const POSOperatorCookie = "pos_operator"
+
+// SetPOSOperatorCookie stores the POS operator token in the browser until
+// expiry.
+func SetPOSOperatorCookie(w http.ResponseWriter, r *http.Request, token string, expiry time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     POSOperatorCookie,
+		Value:    token,
+		Expires:  expiry,
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+	})
 }
 
 type contextKey string
@@ -40,12 +79,14 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 		authHeader := r.Header.Get("Authorization")
 
 		var token string
+		fromCookie := false
 
 		if authHeader == "" {
 			// Try to get token from cookie
-			cookie, err := r.Cookie("auth_token")
+			cookie, err := r.Cookie(AuthCookie)
 			if err == nil {
 				token = cookie.Value
+				fromCookie = true
 			}
 		} else {
 			headerParts := strings.Split(authHeader, " ") // Bearer <TOKEN>
@@ -60,6 +101,11 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		if !fromCookie && strings.HasPrefix(token, tokens.APIKeyPrefix) {
+			um.authenticateAPIKey(w, r, next, token)
+			return
+		}
+
 		user, err := um.UserStore.GetUserToken(tokens.ScopeAuth, token)
 		if err != nil {
 			utils.Error(w, http.StatusUnauthorized, "invalid token")
@@ -80,11 +126,79 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		// Web sessions slide with use and the cookie follows the new expiry.
+		expiry, touched, err := um.TokenStore.TouchSession(user.SessionID, utils.ClientIP(r), fromCookie)
+		if err != nil {
+			um.Logger.Error("touching session", "session_id", user.SessionID, "error", err)
+		} else if touched && fromCookie {
+			SetAuthCookie(w, r, token, expiry)
+		}
+
 		r = SetUser(r, user)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticateAPIKey lets an integration in as the user its key acts as.
+func (um *UserMiddleware) authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	user, err := um.APIKeyStore.GetUserAPIKey(tokens.Hash(key))
+	if err != nil {
+		um.Logger.Error("getting api key", "error", err)
+		utils.Error(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	if user == nil {
+		utils.Error(w, http.StatusUnauthorized, "api key revoked, expired or invalid")
+		return
+	}
+	if !user.IsActive {
+		utils.Error(w, http.StatusForbidden, "account disabled")
+		return
+	}
+
+	ip := utils.ClientIP(r)
+	if !user.APIKey.AllowsIP(ip) {
+		utils.Error(w, http.StatusForbidden, "api key not allowed from this address")
+		return
+	}
+	if err := um.APIKeyStore.Touch(user.APIKey.ID, ip); err != nil {
+		um.Logger.Error("touching api key", "api_key_id", user.APIKey.ID, "error", err)
+	}
+
+	next.ServeHTTP(w, SetUser(r, user))
+}
+
+// POSOperator makes the employee who entered their PIN on this device the
+// user of the request, so what they do on the POS is theirs while the
+// device's session stays open. The logged in user is kept as DeviceUser. It
+// goes after Authenticate and before the permission checks, which apply to
+// the operator.
+func (um *UserMiddleware) POSOperator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		device := GetUser(r)
+		cookie, err := r.Cookie(POSOperatorCookie)
+		if err != nil || cookie.Value == "" || device.IsAnonymous() || device.APIKey != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		operator, err := um.UserStore.GetUserToken(tokens.ScopePOSOperator, cookie.Value)
+		if err != nil {
+			um.Logger.Error("getting pos operator", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if operator == nil || !operator.IsActive {
+			SetPOSOperatorCookie(w, r, "", time.Unix(0, 0))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		operator.DeviceUser = device
+		next.ServeHTTP(w, SetUser(r, operator))
+	})
+}
+
 func (um *UserMiddleware) RequireUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := GetUser(r)
@@ -103,21 +217,24 @@ func (um *UserMiddleware) RequireUser(next http.Handler) http.Handler {
 	})
 }
 
-func (um *UserMiddleware) RequireAdmin(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user := GetUser(r)
-		if user.IsAnonymous() {
-			utils.Error(w, http.StatusUnauthorized, "authentication required")
-			return
-		}
+// RequirePermission only lets through users whose role grants permission.
+func (um *UserMiddleware) RequirePermission(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUser(r)
+			if user.IsAnonymous() {
+				utils.Error(w, http.StatusUnauthorized, "authentication required")
+				return
+			}
 
-		if user.Role != "administrator" {
-			utils.Error(w, http.StatusForbidden, "insufficient privileges")
-			return
-		}
+			if !user.Can(permission) {
+				utils.Error(w, http.StatusForbidden, "insufficient privileges")
+				return
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func AddSecurityHeaders(next http.Handler) http.Handler {
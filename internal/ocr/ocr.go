@@ -0,0 +1,30 @@
+// Package ocr reads the text of uploaded receipt images and proposes the
+// expense fields found in it.
+package ocr
+
+import (
+	"context"
+	"os"
+)
+
+// Engine turns an image into plain text.
+type Engine interface {
+	// Name identifies the engine in stored extraction results.
+	Name() string
+	Recognize(ctx context.Context, imagePath string) (string, error)
+}
+
+// FromEnv returns the engine set by OCR_ENGINE, or nil when OCR is disabled
+// ("none") or the engine is not installed. Tesseract is the default.
+func FromEnv() Engine {
+	switch os.Getenv("OCR_ENGINE") {
+	case "", "tesseract":
+		engine := NewTesseractEngine(os.Getenv("TESSERACT_PATH"), os.Getenv("OCR_LANG"))
+		if !engine.Available() {
+			return nil
+		}
+		return engine
+	default:
+		return nil
+	}
+}
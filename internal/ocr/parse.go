@@ -0,0 +1,190 @@
+package ocr
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fields are the expense values proposed from a receipt's text. Zero values
+// mean nothing was found.
+type Fields struct {
+	Amount *float64
+	Date   *time.Time
+	// CUITs are the valid tax IDs in order of appearance, digits only. The
+	// issuer usually comes first, followed by the buyer.
+	CUITs         []string
+	InvoiceNumber string
+	InvoiceType   string
+}
+
+var (
+	cuitRe        = regexp.MustCompile(`\b(20|23|24|27|30|33|34)[-\s.]?(\d{8})[-\s.]?(\d)\b`)
+	invoiceRe     = regexp.MustCompile(`\b(\d{4,5})\s*-\s*(\d{8})\b`)
+	pointOfSaleRe = regexp.MustCompile(`(?i)punto\s+de\s+venta:?\s*(\d{1,5})\s+comp\.?\s*nro\.?:?\s*(\d{1,8})`)
+	typeRe        = regexp.MustCompile(`(?i)\bfactura\s+([abcm])\b`)
+	typeCodeRe    = regexp.MustCompile(`(?i)\bc[oó]d(?:igo)?\.?\s*(?:n[°º]?\s*)?0?(01|06|11|51)\b`)
+	typeBoxRe     = regexp.MustCompile(`^\s*\|?\s*([ABCM])\s*\|?\s*$`)
+	dateRe        = regexp.MustCompile(`\b(\d{1,2})[/.-](\d{1,2})[/.-](\d{4}|\d{2})\b`)
+	moneyRe       = regexp.MustCompile(`\d{1,3}(?:[.,\s]\d{3})+(?:[.,]\d{2})?|\d+(?:[.,]\d{2})?`)
+	totalRe       = regexp.MustCompile(`(?i)\btotal\b`)
+	subtotalRe    = regexp.MustCompile(`(?i)sub\s*-?\s*total`)
+)
+
+var invoiceTypeCodes = map[string]string{"01": "A", "06": "B", "11": "C", "51": "M"}
+
+// Parse proposes expense fields from the text of a receipt.
+func Parse(text string) Fields {
+	var f Fields
+	lines := strings.Split(text, "\n")
+
+	seen := map[string]bool{}
+	for _, m := range cuitRe.FindAllStringSubmatch(text, -1) {
+		cuit := m[1] + m[2] + m[3]
+		if ValidCUIT(cuit) && !seen[cuit] {
+			seen[cuit] = true
+			f.CUITs = append(f.CUITs, cuit)
+		}
+	}
+
+	if m := pointOfSaleRe.FindStringSubmatch(text); m != nil {
+		f.InvoiceNumber = formatInvoiceNumber(m[1], m[2])
+	} else {
+		for _, m := range invoiceRe.FindAllStringSubmatch(text, -1) {
+			// A CUIT written as 20-12345678-3 doesn't match, but its middle
+			// part could if OCR dropped the prefix; skip matches inside CUITs.
+			if cuitRe.MatchString(m[0]) {
+				continue
+			}
+			f.InvoiceNumber = formatInvoiceNumber(m[1], m[2])
+			break
+		}
+	}
+
+	if m := typeRe.FindStringSubmatch(text); m != nil {
+		f.InvoiceType = strings.ToUpper(m[1])
+	} else if m := typeCodeRe.FindStringSubmatch(text); m != nil {
+		f.InvoiceType = invoiceTypeCodes[m[1]]
+	} else {
+		for _, line := range lines {
+			if m := typeBoxRe.FindStringSubmatch(line); m != nil {
+				f.InvoiceType = m[1]
+				break
+			}
+		}
+	}
+
+	f.Date = findDate(lines)
+	f.Amount = findTotal(lines)
+	return f
+}
+
+// ValidCUIT checks the length and verification digit of an 11 digit CUIT.
+func ValidCUIT(cuit string) bool {
+	if len(cuit) != 11 {
+		return false
+	}
+	weights := []int{5, 4, 3, 2, 7, 6, 5, 4, 3, 2}
+	sum := 0
+	for i, w := range weights {
+		d := cuit[i]
+		if d < '0' || d > '9' {
+			return false
+		}
+		sum += int(d-'0') * w
+	}
+	check := 11 - sum%11
+	switch check {
+	case 11:
+		check = 0
+	case 10:
+		check = 9
+	}
+	return int(cuit[10]-'0') == check
+}
+
+func formatInvoiceNumber(pointOfSale, number string) string {
+	pos, _ := strconv.Atoi(pointOfSale)
+	n, _ := strconv.Atoi(number)
+	width := 4
+	if len(strings.TrimLeft(pointOfSale, "0")) > 4 {
+		width = 5
+	}
+	return fmt.Sprintf("%0*d-%08d", width, pos, n)
+}
+
+// findDate prefers a date on a line mentioning "fecha" and falls back to the
+// first date in the text.
+func findDate(lines []string) *time.Time {
+	var first *time.Time
+	for _, line := range lines {
+		for _, m := range dateRe.FindAllStringSubmatch(line, -1) {
+			d, ok := parseDate(m[1], m[2], m[3])
+			if !ok {
+				continue
+			}
+			if strings.Contains(strings.ToLower(line), "fecha") {
+				return &d
+			}
+			if first == nil {
+				first = &d
+			}
+		}
+	}
+	return first
+}
+
+func parseDate(day, month, year string) (time.Time, bool) {
+	if len(year) == 2 {
+		year = "20" + year
+	}
+	d, err := time.Parse("2/1/2006", day+"/"+month+"/"+year)
+	if err != nil || d.Year() < 2000 {
+		return time.Time{}, false
+	}
+	return d, true
+}
+
+// findTotal returns the largest amount on the lines labelled total, leaving
+// out subtotals.
+func findTotal(lines []string) *float64 {
+	var total *float64
+	for _, line := range lines {
+		if !totalRe.MatchString(line) || subtotalRe.MatchString(line) {
+			continue
+		}
+		for _, m := range moneyRe.FindAllString(line, -1) {
+			v, ok := ParseAmount(m)
+			if ok && (total == nil || v > *total) {
+				total = &v
+			}
+		}
+	}
+	return total
+}
+
+// ParseAmount reads an amount written with either decimal comma or decimal
+// point, with optional thousand separators: 1.234,56, 1,234.56 and 1234,56
+// are all 1234.56.
+func ParseAmount(s string) (float64, bool) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	if s == "" {
+		return 0, false
+	}
+	decimals := ""
+	if i := strings.LastIndexAny(s, ".,"); i >= 0 && len(s)-i-1 == 2 {
+		decimals = s[i+1:]
+		s = s[:i]
+	}
+	s = strings.NewReplacer(".", "", ",", "").Replace(s)
+	if decimals != "" {
+		s += "." + decimals
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,89 @@
+package ocr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const electronicInvoice = `ORIGINAL
+MOLINO HARINERO S.A.            A
+                                COD. 01
+Razón Social: Molino Harinero S.A.
+Punto de Venta: 00003  Comp. Nro: 00001234
+Fecha de Emisión: 20/05/2026
+CUIT: 30-71234567-1
+Ingresos Brutos: 901-123456-7
+CUIT: 20-12345678-6   Apellido y Nombre / Razón Social: A Eso Voy
+Condición frente al IVA: IVA Responsable Inscripto
+Harina 000 x 25kg   2   18.000,00   36.000,00
+Subtotal: $ 36.000,00
+Importe Neto Gravado: $ 36.000,00
+IVA 21%: $ 7.560,00
+Importe Total: $ 43.560,00
+CAE N°: 74201234567890  Fecha de Vto. de CAE: 30/05/2026
+`
+
+func TestParse_ElectronicInvoice(t *testing.T) {
+	f := Parse(electronicInvoice)
+
+	require.NotNil(t, f.Amount)
+	assert.Equal(t, 43560.0, *f.Amount)
+	require.NotNil(t, f.Date)
+	assert.Equal(t, "2026-05-20", f.Date.Format("2006-01-02"))
+	assert.Equal(t, []string{"30712345671", "20123456786"}, f.CUITs)
+	assert.Equal(t, "0003-00001234", f.InvoiceNumber)
+	assert.Equal(t, "A", f.InvoiceType)
+}
+
+func TestParse_Ticket(t *testing.T) {
+	f := Parse(`DISTRIBUIDORA NORTE
+C.U.I.T. 30712345671
+FACTURA B 0002-00004567
+17/03/26 10:32
+AZUCAR 1KG   3 x 1200.00
+TOTAL $ 3,600.00
+`)
+
+	require.NotNil(t, f.Amount)
+	assert.Equal(t, 3600.0, *f.Amount)
+	require.NotNil(t, f.Date)
+	assert.Equal(t, "2026-03-17", f.Date.Format("2006-01-02"))
+	assert.Equal(t, []string{"30712345671"}, f.CUITs)
+	assert.Equal(t, "0002-00004567", f.InvoiceNumber)
+	assert.Equal(t, "B", f.InvoiceType)
+}
+
+func TestParse_NothingFound(t *testing.T) {
+	f := Parse("illegible")
+	assert.Nil(t, f.Amount)
+	assert.Nil(t, f.Date)
+	assert.Empty(t, f.CUITs)
+	assert.Empty(t, f.InvoiceNumber)
+	assert.Empty(t, f.InvoiceType)
+}
+
+func TestValidCUIT(t *testing.T) {
+	assert.True(t, ValidCUIT("30712345671"))
+	assert.True(t, ValidCUIT("20123456786"))
+	assert.False(t, ValidCUIT("30712345672"))
+	assert.False(t, ValidCUIT("3071234567"))
+}
+
+func TestParseAmount(t *testing.T) {
+	for in, want := range map[string]float64{
+		"1.234,56":  1234.56,
+		"1,234.56":  1234.56,
+		"1234,56":   1234.56,
+		"43.560":    43560,
+		"1 200,00":  1200,
+		"36.000,00": 36000,
+	} {
+		got, ok := ParseAmount(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, got, in)
+	}
+	_, ok := ParseAmount("")
+	assert.False(t, ok)
+}
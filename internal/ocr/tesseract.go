@@ -0,0 +1,48 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// TesseractEngine runs a locally installed tesseract binary.
+type TesseractEngine struct {
+	path string
+	lang string
+}
+
+// NewTesseractEngine returns an engine for the tesseract binary at path
+// (looked up in PATH by default) reading lang, Spanish by default.
+func NewTesseractEngine(path, lang string) *TesseractEngine {
+	if path == "" {
+		path = "tesseract"
+	}
+	if lang == "" {
+		lang = "spa"
+	}
+	return &TesseractEngine{path: path, lang: lang}
+}
+
+func (e *TesseractEngine) Name() string {
+	return "tesseract"
+}
+
+// Available reports whether the binary can be found.
+func (e *TesseractEngine) Available() bool {
+	_, err := exec.LookPath(e.path)
+	return err == nil
+}
+
+func (e *TesseractEngine) Recognize(ctx context.Context, imagePath string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.path, imagePath, "stdout", "-l", e.lang)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
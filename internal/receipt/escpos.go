@@ -0,0 +1,134 @@
+package receipt
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// DefaultColumns is the line width of an 80 mm printer using font A.
+const DefaultColumns = 48
+
+// ESC/POS commands used by the encoder.
+var (
+	cmdInit        = []byte{0x1b, '@'}
+	cmdCodePage850 = []byte{0x1b, 't', 2}
+	cmdAlignLeft   = []byte{0x1b, 'a', 0}
+	cmdAlignCenter = []byte{0x1b, 'a', 1}
+	cmdBoldOn      = []byte{0x1b, 'E', 1}
+	cmdBoldOff     = []byte{0x1b, 'E', 0}
+	cmdDoubleSize  = []byte{0x1d, '!', 0x11}
+	cmdNormalSize  = []byte{0x1d, '!', 0x00}
+	cmdFeedAndCut  = []byte{0x1d, 'V', 'A', 3}
+)
+
+// ESCPOS encodes the receipt for a thermal printer with the given number of
+// columns. Text is sent in code page 850 so accented characters print.
+func ESCPOS(r *Receipt, columns int) ([]byte, error) {
+	if columns <= 0 {
+		columns = DefaultColumns
+	}
+	e := &escposWriter{
+		columns: columns,
+		enc:     encoding.ReplaceUnsupported(charmap.CodePage850.NewEncoder()),
+	}
+
+	e.raw(cmdInit, cmdCodePage850, cmdAlignCenter)
+	e.raw(cmdDoubleSize, cmdBoldOn)
+	e.line(r.Business.Name)
+	e.raw(cmdBoldOff, cmdNormalSize)
+	e.line(r.Business.Address)
+	if r.Business.TaxID != "" {
+		e.line("CUIT: " + r.Business.TaxID)
+	}
+	if r.Business.Phone != "" {
+		e.line("Tel: " + r.Business.Phone)
+	}
+
+	e.raw(cmdAlignLeft)
+	e.separator()
+	e.columns2("Ticket N° "+r.NumberText(), r.Date.Format("02/01/2006 15:04"))
+	e.separator()
+
+	for _, item := range r.Items {
+		e.line(truncate(item.Name, columns))
+		e.columns2("  "+item.QuantityText()+" x "+FormatMoney(item.UnitPrice), FormatMoney(item.Subtotal))
+	}
+
+	e.separator()
+	e.raw(cmdBoldOn)
+	e.columns2("TOTAL", FormatMoney(r.Total))
+	e.raw(cmdBoldOff)
+	if r.PaymentMethod != "" {
+		e.line("Pago: " + r.PaymentMethod)
+	}
+
+	e.raw(cmdAlignCenter)
+	if r.Voided {
+		e.raw(cmdBoldOn)
+		e.line("*** VENTA ANULADA ***")
+		e.raw(cmdBoldOff)
+	}
+	e.line("")
+	e.line(r.Business.Footer)
+	e.line("")
+	e.line("")
+	e.raw(cmdFeedAndCut)
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type escposWriter struct {
+	buf     bytes.Buffer
+	columns int
+	enc     *encoding.Encoder
+	err     error
+}
+
+func (e *escposWriter) raw(cmds ...[]byte) {
+	for _, c := range cmds {
+		e.buf.Write(c)
+	}
+}
+
+func (e *escposWriter) line(s string) {
+	if e.err != nil {
+		return
+	}
+	b, err := e.enc.Bytes([]byte(s))
+	if err != nil {
+		e.err = err
+		return
+	}
+	e.buf.Write(b)
+	e.buf.WriteByte('\n')
+}
+
+// columns2 prints left and right on the same line, the right side aligned to
+// the edge. When both don't fit, right goes on its own line.
+func (e *escposWriter) columns2(left, right string) {
+	gap := e.columns - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if gap < 1 {
+		e.line(truncate(left, e.columns))
+		gap = e.columns - utf8.RuneCountInString(right)
+		left = ""
+	}
+	e.line(left + strings.Repeat(" ", max(gap, 0)) + right)
+}
+
+func (e *escposWriter) separator() {
+	e.line(strings.Repeat("-", e.columns))
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,72 @@
+package receipt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testReceipt() *Receipt {
+	return &Receipt{
+		Business: Business{Name: "Aesovoy", Address: "Av. Siempreviva 742", TaxID: "20-12345678-9", Footer: "¡Gracias por su compra!"},
+		Number:   42,
+		Date:     time.Date(2026, 3, 14, 10, 30, 0, 0, time.UTC),
+		Items: []Line{
+			{Name: "Medialunas", Quantity: 6, Unit: "unit", UnitPrice: 500, Subtotal: 3000},
+			{Name: "Masas Finas", Quantity: 0.35, Unit: "kg", UnitPrice: 16000, Subtotal: 5600},
+		},
+		Total:         8600,
+		PaymentMethod: "Efectivo",
+	}
+}
+
+func TestESCPOS(t *testing.T) {
+	data, err := ESCPOS(testReceipt(), 32)
+	require.NoError(t, err)
+
+	assert.True(t, bytes.HasPrefix(data, cmdInit), "starts by resetting the printer")
+	assert.True(t, bytes.HasSuffix(data, cmdFeedAndCut), "ends cutting the paper")
+	assert.Contains(t, string(data), "Ticket N\xf8 00000042")
+	assert.Contains(t, string(data), "Masas Finas\n")
+	assert.Contains(t, string(data), "  0.35 kg x $ 16.000,00")
+	assert.Contains(t, string(data), "\xadGracias por su compra!", "text is encoded in code page 850")
+	assert.NotContains(t, string(data), "ANULADA")
+
+	for _, line := range strings.Split(string(data), "\n") {
+		// Strip the commands that share a line with the text.
+		for _, cmd := range [][]byte{cmdInit, cmdCodePage850, cmdAlignLeft, cmdAlignCenter, cmdBoldOn, cmdBoldOff, cmdDoubleSize, cmdNormalSize, cmdFeedAndCut} {
+			line = strings.ReplaceAll(line, string(cmd), "")
+		}
+		assert.LessOrEqual(t, len(line), 32, "line %q overflows the paper", line)
+	}
+}
+
+func TestESCPOS_Voided(t *testing.T) {
+	rc := testReceipt()
+	rc.Voided = true
+
+	data, err := ESCPOS(rc, 0)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "*** VENTA ANULADA ***")
+	assert.Contains(t, string(data), strings.Repeat("-", DefaultColumns))
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, HTML(&buf, testReceipt()))
+
+	assert.Contains(t, buf.String(), "Ticket N° 00000042")
+	assert.Contains(t, buf.String(), "0.35 kg x $ 16.000,00")
+	assert.Contains(t, buf.String(), "$ 8.600,00")
+	assert.Contains(t, buf.String(), "Efectivo")
+}
+
+func TestFormatMoney(t *testing.T) {
+	assert.Equal(t, "$ 0,50", FormatMoney(0.5))
+	assert.Equal(t, "$ 1.234.567,89", FormatMoney(1234567.891))
+	assert.Equal(t, "$ -1.500,00", FormatMoney(-1500))
+}
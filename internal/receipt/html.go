@@ -0,0 +1,21 @@
+package receipt
+
+import (
+	"embed"
+	"html/template"
+	"io"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+var htmlTemplate = template.Must(template.New("receipt.html").Funcs(template.FuncMap{
+	"money": FormatMoney,
+}).ParseFS(templateFS, "templates/receipt.html"))
+
+// HTML renders the receipt as a standalone page sized for an 80 mm roll. It
+// is the fallback when there is no thermal printer: the browser prints it or
+// saves it as PDF.
+func HTML(w io.Writer, r *Receipt) error {
+	return htmlTemplate.Execute(w, r)
+}
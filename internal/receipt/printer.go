@@ -0,0 +1,57 @@
+package receipt
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Printer sends an encoded ticket to a device.
+type Printer interface {
+	Print(ctx context.Context, data []byte) error
+}
+
+// DefaultPrinterPort is the raw printing port (JetDirect / AppSocket) most
+// network thermal printers listen on.
+const DefaultPrinterPort = "9100"
+
+// NetworkPrinter writes tickets to a printer over a raw TCP connection.
+type NetworkPrinter struct {
+	addr    string
+	timeout time.Duration
+}
+
+// NewNetworkPrinter returns a printer for addr, a host or host:port. The port
+// defaults to 9100.
+func NewNetworkPrinter(addr string) *NetworkPrinter {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultPrinterPort)
+	}
+	return &NetworkPrinter{addr: addr, timeout: 5 * time.Second}
+}
+
+func (p *NetworkPrinter) Addr() string {
+	return p.addr
+}
+
+func (p *NetworkPrinter) Print(ctx context.Context, data []byte) error {
+	dialer := net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("connecting to printer %s: %w", p.addr, err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(p.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("writing to printer %s: %w", p.addr, err)
+	}
+	return nil
+}
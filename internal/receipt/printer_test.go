@@ -0,0 +1,50 @@
+package receipt
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewNetworkPrinter_DefaultPort(t *testing.T) {
+	assert.Equal(t, "192.168.1.50:9100", NewNetworkPrinter("192.168.1.50").Addr())
+	assert.Equal(t, "printer.local:9101", NewNetworkPrinter("printer.local:9101").Addr())
+}
+
+func TestNetworkPrinter_Print(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	data, err := ESCPOS(testReceipt(), DefaultColumns)
+	require.NoError(t, err)
+
+	printer := NewNetworkPrinter(ln.Addr().String())
+	require.NoError(t, printer.Print(context.Background(), data))
+	assert.Equal(t, data, <-received)
+}
+
+func TestNetworkPrinter_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	err = NewNetworkPrinter(addr).Print(context.Background(), []byte("x"))
+	assert.Error(t, err)
+}
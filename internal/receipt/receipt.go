@@ -0,0 +1,91 @@
+// Package receipt renders customer tickets for local sales, as ESC/POS byte
+// streams for thermal printers or as a printable HTML page.
+package receipt
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Business is the header printed at the top of every ticket.
+type Business struct {
+	Name    string
+	Address string
+	TaxID   string
+	Phone   string
+	Footer  string
+}
+
+// BusinessFromEnv reads the ticket header from the BUSINESS_* variables.
+func BusinessFromEnv() Business {
+	b := Business{
+		Name:    os.Getenv("BUSINESS_NAME"),
+		Address: os.Getenv("BUSINESS_ADDRESS"),
+		TaxID:   os.Getenv("BUSINESS_TAX_ID"),
+		Phone:   os.Getenv("BUSINESS_PHONE"),
+		Footer:  os.Getenv("RECEIPT_FOOTER"),
+	}
+	if b.Name == "" {
+		b.Name = "Aesovoy"
+	}
+	if b.Footer == "" {
+		b.Footer = "¡Gracias por su compra!"
+	}
+	return b
+}
+
+type Line struct {
+	Name      string
+	Quantity  float64
+	Unit      string
+	UnitPrice float64
+	Subtotal  float64
+}
+
+// QuantityText prints the quantity with its unit when sold by weight.
+func (l Line) QuantityText() string {
+	q := strconv.FormatFloat(l.Quantity, 'f', -1, 64)
+	if l.Unit == "" || l.Unit == "unit" {
+		return q
+	}
+	return q + " " + l.Unit
+}
+
+type Receipt struct {
+	Business      Business
+	Number        int64
+	Date          time.Time
+	Items         []Line
+	Total         float64
+	PaymentMethod string
+	Voided        bool
+}
+
+// NumberText is the sale number as printed on the ticket.
+func (r *Receipt) NumberText() string {
+	return fmt.Sprintf("%08d", r.Number)
+}
+
+// FormatMoney formats an amount the way prices are shown across the app,
+// e.g. "$ 1.234,50".
+func FormatMoney(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign = "-"
+		s = s[1:]
+	}
+	intPart, decPart, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(c)
+	}
+	return "$ " + sign + b.String() + "," + decPart
+}
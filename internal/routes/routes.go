@@ -6,6 +6,8 @@ import (
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/app"
 	mymw "github.com/RamunnoAJ/aesovoy-server/internal/middleware"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/utils"
 	_ "github.com/RamunnoAJ/aesovoy-server/swagger"
 	chi "github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -35,43 +37,86 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 	r.Use(httprate.Limit(
 		100,
 		1*time.Minute,
-		httprate.WithKeyFuncs(httprate.KeyByIP),
+		httprate.WithKeyFuncs(utils.KeyByClientIP),
 	))
 
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Use(app.Middleware.Authenticate)
 		r.Use(app.Middleware.RequireUser)
 
-		// Local Store (Accessible by Employees and Admins)
+		// Local Store
 		r.Route("/local_stock", func(r chi.Router) {
-			r.Get("/", app.LocalStockHandler.HandleListLocalStock)
-			r.Post("/", app.LocalStockHandler.HandleCreateInitialStock)
-			r.Get("/{product_id}", app.LocalStockHandler.HandleGetLocalStock)
-			r.Patch("/{product_id}/adjust", app.LocalStockHandler.HandleAdjustStock)
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermStockView))
+				r.Get("/", app.LocalStockHandler.HandleListLocalStock)
+				r.Get("/{product_id}", app.LocalStockHandler.HandleGetLocalStock)
+				r.Get("/{product_id}/movements", app.LocalStockHandler.HandleListMovements)
+				r.Get("/{product_id}/at", app.LocalStockHandler.HandleGetStockAt)
+				r.Get("/{product_id}/locations", app.LocalStockHandler.HandleStockByLocation)
+				r.Get("/{product_id}/lots", app.LocalStockHandler.HandleListLots)
+				r.Get("/lots/expiring", app.LocalStockHandler.HandleListExpiringLots)
+				r.Get("/lots/{id}", app.LocalStockHandler.HandleGetLot)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermStockAdjust))
+				r.Post("/", app.LocalStockHandler.HandleCreateInitialStock)
+				r.Patch("/{product_id}/adjust", app.LocalStockHandler.HandleAdjustStock)
+			})
 		})
 
 		r.Route("/local_sales", func(r chi.Router) {
-			r.Get("/", app.LocalSaleHandler.HandleListLocalSales)
-			r.Post("/", app.LocalSaleHandler.HandleCreateLocalSale)
-			r.Get("/{id}", app.LocalSaleHandler.HandleGetLocalSale)
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermSalesView))
+				r.Get("/", app.LocalSaleHandler.HandleListLocalSales)
+				r.Get("/lookup", app.LocalSaleHandler.HandleLookupCode)
+				r.Get("/{id}", app.LocalSaleHandler.HandleGetLocalSale)
+				r.Get("/{id}/receipt", app.LocalSaleHandler.HandleGetReceipt)
+				r.Post("/{id}/receipt/print", app.LocalSaleHandler.HandlePrintReceipt)
+			})
+			r.With(app.Middleware.RequirePermission(store.PermSalesCreate)).Post("/", app.LocalSaleHandler.HandleCreateLocalSale)
 		})
 
-		// Admin Only API
+		r.Get("/stock_locations", app.StockLocationHandler.HandleListLocations)
 		r.Group(func(r chi.Router) {
-			r.Use(app.Middleware.RequireAdmin)
+			r.Use(app.Middleware.RequirePermission(store.PermSettingsManage))
+			r.Post("/stock_locations", app.StockLocationHandler.HandleCreateLocation)
+			r.Patch("/stock_locations/{id}", app.StockLocationHandler.HandleUpdateLocation)
+		})
 
-			r.Route("/categories", func(r chi.Router) {
-				r.Get("/", app.CategoryHandler.HandleGetCategories)
-				r.Get("/{id}", app.CategoryHandler.HandleGetCategoryByID)
-				r.Get("/{id}/products", app.ProductHandler.HandleGetProductsByCategory)
+		r.Route("/stock_transfers", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermStockTransfer))
+			r.Get("/", app.StockLocationHandler.HandleListTransfers)
+			r.Post("/", app.StockLocationHandler.HandleCreateTransfer)
+			r.Get("/{id}", app.StockLocationHandler.HandleGetTransfer)
+		})
+
+		r.Route("/waste", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermWasteRegister))
+				r.Get("/", app.WasteHandler.HandleListWaste)
+				r.Post("/", app.WasteHandler.HandleRegisterWaste)
+			})
+			r.With(app.Middleware.RequirePermission(store.PermWasteReport)).Get("/report", app.WasteHandler.HandleWasteReport)
+		})
+
+		// Catalog: readable by every user, edited with products.edit
+		r.Route("/categories", func(r chi.Router) {
+			r.Get("/", app.CategoryHandler.HandleGetCategories)
+			r.Get("/{id}", app.CategoryHandler.HandleGetCategoryByID)
+			r.Get("/{id}/products", app.ProductHandler.HandleGetProductsByCategory)
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermProductsEdit))
 				r.Post("/", app.CategoryHandler.HandleRegisterCategory)
 				r.Patch("/{id}", app.CategoryHandler.HandleUpdateCategory)
 				r.Delete("/{id}", app.CategoryHandler.HandleDeleteCategory)
 			})
+		})
 
-			r.Route("/products", func(r chi.Router) {
-				r.Get("/", app.ProductHandler.HandleGetProducts)
-				r.Get("/{id}", app.ProductHandler.HandleGetProductByID)
+		r.Route("/products", func(r chi.Router) {
+			r.Get("/", app.ProductHandler.HandleGetProducts)
+			r.Get("/{id}", app.ProductHandler.HandleGetProductByID)
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermProductsEdit))
 				r.Post("/", app.ProductHandler.HandleRegisterProduct)
 				r.Patch("/{id}", app.ProductHandler.HandleUpdateProduct)
 				r.Delete("/{id}", app.ProductHandler.HandleDeleteProduct)
@@ -80,55 +125,150 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 				r.Patch("/{productID}/ingredients/{ingredientID}", app.ProductHandler.HandleUpdateProductIngredient)
 				r.Delete("/{productID}/ingredients/{ingredientID}", app.ProductHandler.HandleRemoveIngredientFromProduct)
 			})
+		})
 
-			r.Route("/ingredients", func(r chi.Router) {
-				r.Get("/", app.IngredientHandler.HandleGetAllIngredients)
+		r.Route("/ingredients", func(r chi.Router) {
+			r.Get("/", app.IngredientHandler.HandleGetAllIngredients)
+			r.Get("/{id}", app.IngredientHandler.HandleGetIngredientByID)
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermProductsEdit))
 				r.Post("/", app.IngredientHandler.HandleCreateIngredient)
-				r.Get("/{id}", app.IngredientHandler.HandleGetIngredientByID)
 				r.Patch("/{id}", app.IngredientHandler.HandleUpdateIngredient)
 				r.Delete("/{id}", app.IngredientHandler.HandleDeleteIngredient)
 			})
+		})
 
-			r.Route("/clients", func(r chi.Router) {
-				r.Get("/", app.ClientHandler.HandleGetClients)
-				r.Get("/{id}", app.ClientHandler.HandleGetClientByID)
-				r.Post("/", app.ClientHandler.HandleRegisterClient)
-				r.Patch("/{id}", app.ClientHandler.HandleUpdateClient)
-			})
+		r.With(app.Middleware.RequirePermission(store.PermProductionPlan)).Get("/production/suggestions", app.ProductionPlanHandler.HandleGetSuggestions)
+
+		r.Route("/purchase_orders", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermPurchasesManage))
+			r.Get("/", app.PurchaseOrderHandler.HandleListPurchaseOrders)
+			r.Post("/", app.PurchaseOrderHandler.HandleCreatePurchaseOrder)
+			r.Get("/{id}", app.PurchaseOrderHandler.HandleGetPurchaseOrder)
+			r.Patch("/{id}", app.PurchaseOrderHandler.HandleUpdatePurchaseOrder)
+			r.Post("/{id}/send", app.PurchaseOrderHandler.HandleSendPurchaseOrder)
+			r.Post("/{id}/mark_sent", app.PurchaseOrderHandler.HandleMarkPurchaseOrderSent)
+			r.Post("/{id}/cancel", app.PurchaseOrderHandler.HandleCancelPurchaseOrder)
+			r.Post("/{id}/receive", app.PurchaseOrderHandler.HandleReceivePurchaseOrder)
+		})
+
+		r.Route("/clients", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermClientsManage))
+			r.Get("/", app.ClientHandler.HandleGetClients)
+			r.Get("/{id}", app.ClientHandler.HandleGetClientByID)
+			r.Post("/", app.ClientHandler.HandleRegisterClient)
+			r.Patch("/{id}", app.ClientHandler.HandleUpdateClient)
+		})
 
-			r.Route("/providers", func(r chi.Router) {
+		r.Route("/providers", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermProvidersManage))
 				r.Get("/", app.ProviderHandler.HandleGetProviders)
 				r.Get("/{id}", app.ProviderHandler.HandleGetProviderByID)
 				r.Post("/", app.ProviderHandler.HandleRegisterProvider)
 				r.Patch("/{id}", app.ProviderHandler.HandleUpdateProvider)
 			})
+			r.With(app.Middleware.RequirePermission(store.PermPayablesManage)).Get("/{id}/statement", app.AccountsPayableHandler.HandleGetProviderStatement)
+		})
 
-			r.Route("/orders", func(r chi.Router) {
-				r.Get("/", app.OrderHandler.HandleListOrders)
-				r.Get("/{id}", app.OrderHandler.HandleGetOrderByID)
-				r.Post("/", app.OrderHandler.HandleRegisterOrder)
-				r.Patch("/{id}/state", app.OrderHandler.HandleUpdateOrderState)
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermPayablesManage))
+			r.Get("/payables", app.AccountsPayableHandler.HandleListPayables)
+			r.Get("/payables/balances", app.AccountsPayableHandler.HandleListProviderBalances)
+			r.Route("/provider_payments", func(r chi.Router) {
+				r.Get("/", app.AccountsPayableHandler.HandleListProviderPayments)
+				r.Post("/", app.AccountsPayableHandler.HandleCreateProviderPayment)
+				r.Get("/{id}", app.AccountsPayableHandler.HandleGetProviderPayment)
 			})
+		})
 
-			r.Route("/payment_methods", func(r chi.Router) {
-				r.Get("/", app.PaymentMethodHandler.HandleGetPaymentMethods)
-				r.Get("/{id}", app.PaymentMethodHandler.HandleGetPaymentMethodByID)
+		r.Route("/orders", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermOrdersManage))
+			r.Get("/", app.OrderHandler.HandleListOrders)
+			r.Get("/{id}", app.OrderHandler.HandleGetOrderByID)
+			r.Post("/", app.OrderHandler.HandleRegisterOrder)
+			r.Patch("/{id}/state", app.OrderHandler.HandleUpdateOrderState)
+		})
+
+		r.Route("/payment_methods", func(r chi.Router) {
+			r.Get("/", app.PaymentMethodHandler.HandleGetPaymentMethods)
+			r.Get("/{id}", app.PaymentMethodHandler.HandleGetPaymentMethodByID)
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermSettingsManage))
 				r.Post("/", app.PaymentMethodHandler.HandleCreatePaymentMethod)
 				r.Delete("/{id}", app.PaymentMethodHandler.HandleDeletePaymentMethod)
 			})
+		})
 
-			r.Route("/expenses", func(r chi.Router) {
+		r.Route("/expenses", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermExpensesView))
 				r.Get("/", app.ExpenseHandler.HandleGetExpenses)
-				r.Post("/", app.ExpenseHandler.HandleCreateExpense)
+				r.Get("/extractions", app.ExpenseHandler.HandleListExpenseExtractions)
+				r.Get("/extractions/{id}", app.ExpenseHandler.HandleGetExpenseExtraction)
 				r.Get("/{id}", app.ExpenseHandler.HandleGetExpenseByID)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermExpensesCreate))
+				r.Post("/", app.ExpenseHandler.HandleCreateExpense)
+				r.Post("/extractions", app.ExpenseHandler.HandleCreateExpenseExtraction)
+				r.Post("/extractions/{id}/discard", app.ExpenseHandler.HandleDiscardExpenseExtraction)
 				r.Delete("/{id}", app.ExpenseHandler.HandleDeleteExpense)
 			})
+		})
 
-			r.Get("/invoices", app.InvoiceHandler.HandleListInvoicesJSON)
+		r.With(app.Middleware.RequirePermission(store.PermInvoicesView)).Get("/invoices", app.InvoiceHandler.HandleListInvoicesJSON)
 
-			// API Tokens
+		// Own sessions, any user
+		r.Get("/sessions", app.TokenHandler.HandleListSessions)
+		r.Delete("/sessions/{id}", app.TokenHandler.HandleRevokeSession)
+
+		// Users, Roles and API Tokens
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermUsersManage))
 			r.Post("/users", app.UserHandler.HandleRegisterUser)
 			r.Post("/tokens/authentication", app.TokenHandler.HandleCreateToken)
+			r.Post("/tokens/two_factor", app.TokenHandler.HandleCreateTwoFactorToken)
+			r.Get("/users/{id}/sessions", app.TokenHandler.HandleListUserSessions)
+			r.Delete("/users/{id}/sessions", app.TokenHandler.HandleLogoutUser)
+			r.Post("/users/{id}/unlock", app.SecurityHandler.HandleUnlockUser)
+			r.Get("/security_events", app.SecurityHandler.HandleListSecurityEvents)
+
+			r.Get("/api_keys", app.APIKeyHandler.HandleListAPIKeys)
+			r.Post("/api_keys", app.APIKeyHandler.HandleCreateAPIKey)
+			r.Delete("/api_keys/{id}", app.APIKeyHandler.HandleRevokeAPIKey)
+
+			r.Get("/permissions", app.RoleHandler.HandleListPermissions)
+			r.Route("/roles", func(r chi.Router) {
+				r.Get("/", app.RoleHandler.HandleListRoles)
+				r.Post("/", app.RoleHandler.HandleCreateRole)
+				r.Get("/{id}", app.RoleHandler.HandleGetRole)
+				r.Patch("/{id}", app.RoleHandler.HandleUpdateRole)
+				r.Delete("/{id}", app.RoleHandler.HandleDeleteRole)
+			})
+		})
+
+		// Audit Log
+		r.With(app.Middleware.RequirePermission(store.PermAuditView)).Get("/audit_log", app.AuditHandler.HandleListAuditLog)
+
+		// Webhooks
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermWebhooksManage))
+			r.Get("/", app.WebhookHandler.HandleListWebhooks)
+			r.Post("/", app.WebhookHandler.HandleCreateWebhook)
+			r.Post("/deliveries/{id}/redeliver", app.WebhookHandler.HandleRedeliverWebhook)
+			r.Get("/{id}", app.WebhookHandler.HandleGetWebhook)
+			r.Patch("/{id}", app.WebhookHandler.HandleUpdateWebhook)
+			r.Delete("/{id}", app.WebhookHandler.HandleDeleteWebhook)
+			r.Post("/{id}/test", app.WebhookHandler.HandleTestWebhook)
+			r.Get("/{id}/deliveries", app.WebhookHandler.HandleListWebhookDeliveries)
+		})
+
+		// Background Jobs
+		r.Route("/jobs", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermJobsManage))
+			r.Get("/", app.JobHandler.HandleListJobs)
+			r.Post("/{id}/retry", app.JobHandler.HandleRetryJob)
 		})
 	})
 
@@ -136,16 +276,30 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 	r.Handle("/uploads/*", http.StripPrefix("/uploads", http.FileServer(http.Dir("uploads"))))
 
 	r.Get("/swagger/*", httpSwagger.Handler(httpSwagger.URL("http://localhost:8080/swagger/doc.json")))
-	r.Get("/health", app.HealthCheck)
+	r.Get("/health", app.HandleReadiness)
+	r.Get("/health/live", app.HandleLiveness)
+	r.Get("/health/ready", app.HandleReadiness)
 
 	// Public Web Views
 	r.Get("/login", app.WebHandler.HandleShowLogin)
 	r.With(httprate.Limit(
 		10,
 		1*time.Minute,
-		httprate.WithKeyFuncs(httprate.KeyByIP),
+		httprate.WithKeyFuncs(utils.KeyByClientIP),
 	)).Post("/login", app.WebHandler.HandleWebLogin)
 
+	r.Get("/login/2fa", app.WebHandler.HandleShowTwoFactorLogin)
+	r.Get("/login/2fa/setup", app.WebHandler.HandleShowTwoFactorLoginSetup)
+	r.Group(func(r chi.Router) {
+		r.Use(httprate.Limit(
+			10,
+			1*time.Minute,
+			httprate.WithKeyFuncs(utils.KeyByClientIP),
+		))
+		r.Post("/login/2fa", app.WebHandler.HandleTwoFactorLogin)
+		r.Post("/login/2fa/setup", app.WebHandler.HandleTwoFactorLoginSetup)
+	})
+
 	r.Get("/forgot-password", app.WebHandler.HandleShowForgotPassword)
 	r.Post("/forgot-password", app.WebHandler.HandleSendPasswordResetEmail)
 	r.Get("/reset-password", app.WebHandler.HandleShowResetPassword)
@@ -159,136 +313,331 @@ func SetupRoutes(app *app.Application) *chi.Mux {
 		r.Get("/", app.WebHandler.HandleHome)
 		r.Get("/time", app.WebHandler.HandleTime)
 		r.Post("/logout", app.WebHandler.HandleLogout)
-
-		// Web Users Management (Admin)
+		r.Get("/sessions", app.WebHandler.HandleListSessions)
+		r.Delete("/sessions/{id}", app.WebHandler.HandleRevokeSession)
+		r.Post("/sessions/revoke-others", app.WebHandler.HandleRevokeOtherSessions)
+		r.Get("/account/2fa", app.WebHandler.HandleShowTwoFactor)
+		r.Get("/account/2fa/setup", app.WebHandler.HandleShowTwoFactorSetup)
+		r.Post("/account/2fa/setup", app.WebHandler.HandleTwoFactorSetup)
+		r.Post("/account/2fa/disable", app.WebHandler.HandleDisableTwoFactor)
+		r.Post("/account/2fa/recovery-codes", app.WebHandler.HandleRegenerateRecoveryCodes)
+		r.Get("/account/pin", app.WebHandler.HandleShowPIN)
+		r.Post("/account/pin", app.WebHandler.HandleSetPIN)
+		r.Post("/account/pin/remove", app.WebHandler.HandleRemovePIN)
+
+		// Web Users and Roles Management
 		r.Group(func(r chi.Router) {
-			r.Use(app.Middleware.RequireAdmin)
+			r.Use(app.Middleware.RequirePermission(store.PermUsersManage))
 			r.Get("/users", app.WebHandler.HandleListUsers)
 			r.Get("/users/new", app.WebHandler.HandleCreateUserView)
 			r.Post("/users/new", app.WebHandler.HandleCreateUser)
 			r.Get("/users/{id}/edit", app.WebHandler.HandleEditUserView)
 			r.Post("/users/{id}/edit", app.WebHandler.HandleUpdateUser)
 			r.Patch("/users/{id}/toggle-status", app.WebHandler.HandleToggleUserStatus)
+			r.Get("/users/{id}/sessions", app.WebHandler.HandleListUserSessions)
+			r.Delete("/users/{id}/sessions/{sessionID}", app.WebHandler.HandleRevokeUserSession)
+			r.Post("/users/{id}/logout", app.WebHandler.HandleLogoutUser)
+			r.Post("/users/{id}/2fa/reset", app.WebHandler.HandleResetUserTwoFactor)
+			r.Post("/users/{id}/unlock", app.WebHandler.HandleUnlockUser)
+			r.Get("/users/security-events", app.WebHandler.HandleListSecurityEvents)
+
+			r.Get("/users/api-keys", app.WebHandler.HandleListAPIKeys)
+			r.Get("/users/api-keys/new", app.WebHandler.HandleCreateAPIKeyView)
+			r.Post("/users/api-keys/new", app.WebHandler.HandleCreateAPIKey)
+			r.Post("/users/api-keys/{id}/revoke", app.WebHandler.HandleRevokeAPIKey)
+
+			r.Route("/users/roles", func(r chi.Router) {
+				r.Get("/", app.WebHandler.HandleListRoles)
+				r.Get("/new", app.WebHandler.HandleCreateRoleView)
+				r.Post("/new", app.WebHandler.HandleCreateRole)
+				r.Get("/{id}/edit", app.WebHandler.HandleEditRoleView)
+				r.Post("/{id}/edit", app.WebHandler.HandleUpdateRole)
+				r.Delete("/{id}", app.WebHandler.HandleDeleteRole)
+			})
+		})
 
-			// Invoices (Web View - Admin Only)
-			r.Route("/invoices", func(r chi.Router) {
-				r.Get("/", app.InvoiceHandler.List)
-				r.Get("/download/{filename}", app.InvoiceHandler.Download)
-				r.Delete("/{filename}", app.InvoiceHandler.Delete)
+		// Audit Log
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermAuditView))
+			r.Get("/audit-log", app.WebHandler.HandleListAuditLog)
+			r.Get("/audit-log/export", app.WebHandler.HandleExportAuditLog)
+		})
+
+		// Webhooks
+		r.Route("/webhooks", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermWebhooksManage))
+			r.Get("/", app.WebHandler.HandleListWebhooks)
+			r.Get("/new", app.WebHandler.HandleCreateWebhookView)
+			r.Post("/new", app.WebHandler.HandleCreateWebhook)
+			r.Post("/deliveries/{id}/redeliver", app.WebHandler.HandleRedeliverWebhook)
+			r.Get("/{id}", app.WebHandler.HandleShowWebhook)
+			r.Get("/{id}/edit", app.WebHandler.HandleEditWebhookView)
+			r.Post("/{id}/edit", app.WebHandler.HandleUpdateWebhook)
+			r.Post("/{id}/test", app.WebHandler.HandleTestWebhook)
+			r.Post("/{id}/delete", app.WebHandler.HandleDeleteWebhook)
+		})
+
+		// Background Jobs
+		r.Route("/jobs", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermJobsManage))
+			r.Get("/", app.WebHandler.HandleListJobs)
+			r.Post("/{id}/retry", app.WebHandler.HandleRetryJob)
+		})
+
+		// Settings: Cash Registers and Stock Locations
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermSettingsManage))
+			r.Route("/cash-registers", func(r chi.Router) {
+				r.Get("/", app.WebHandler.HandleListCashRegisters)
+				r.Get("/new", app.WebHandler.HandleCreateCashRegisterView)
+				r.Post("/new", app.WebHandler.HandleCreateCashRegister)
+				r.Get("/{id}/edit", app.WebHandler.HandleEditCashRegisterView)
+				r.Post("/{id}/edit", app.WebHandler.HandleUpdateCashRegister)
+			})
+
+			r.Route("/stock-locations", func(r chi.Router) {
+				r.Get("/", app.WebHandler.HandleListStockLocations)
+				r.Get("/new", app.WebHandler.HandleCreateStockLocationView)
+				r.Post("/new", app.WebHandler.HandleCreateStockLocation)
+				r.Get("/{id}", app.WebHandler.HandleStockLocationView)
+				r.Get("/{id}/edit", app.WebHandler.HandleEditStockLocationView)
+				r.Post("/{id}/edit", app.WebHandler.HandleUpdateStockLocation)
 			})
 		})
 
-		// Products
-		r.Get("/products", app.WebHandler.HandleListProducts)
-		r.Get("/products/new", app.WebHandler.HandleCreateProductView)
-		r.Post("/products/new", app.WebHandler.HandleCreateProduct)
-		r.Get("/products/{id}/edit", app.WebHandler.HandleEditProductView)
-		r.Post("/products/{id}/edit", app.WebHandler.HandleUpdateProduct)
-		r.Delete("/products/{id}/delete", app.WebHandler.HandleDeleteProduct)
-
-		// Categories
-		r.Get("/categories", app.WebHandler.HandleListCategories)
-		r.Get("/categories/new", app.WebHandler.HandleCreateCategoryView)
-		r.Post("/categories/new", app.WebHandler.HandleCreateCategory)
-		r.Post("/categories/quick", app.WebHandler.HandleQuickCreateCategory)
-		r.Get("/categories/{id}/edit", app.WebHandler.HandleEditCategoryView)
-		r.Post("/categories/{id}/edit", app.WebHandler.HandleUpdateCategory)
-		r.Delete("/categories/{id}/delete", app.WebHandler.HandleDeleteCategory)
-
-		// Ingredients
-		r.Get("/ingredients", app.WebHandler.HandleListIngredients)
-		r.Get("/ingredients/new", app.WebHandler.HandleCreateIngredientView)
-		r.Post("/ingredients/new", app.WebHandler.HandleCreateIngredient)
-		r.Get("/ingredients/{id}/edit", app.WebHandler.HandleEditIngredientView)
-		r.Post("/ingredients/{id}/edit", app.WebHandler.HandleUpdateIngredient)
-		r.Delete("/ingredients/{id}/delete", app.WebHandler.HandleDeleteIngredient)
+		// Invoices (Web View)
+		r.Route("/invoices", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermInvoicesView))
+			r.Get("/", app.InvoiceHandler.List)
+			r.Get("/download/{filename}", app.InvoiceHandler.Download)
+			r.Delete("/{filename}", app.InvoiceHandler.Delete)
+		})
 
-		// Clients
-		r.Get("/clients", app.WebHandler.HandleListClients)
-		r.Get("/clients/new", app.WebHandler.HandleCreateClientView)
-		r.Post("/clients/new", app.WebHandler.HandleCreateClient)
-		r.Get("/clients/{id}/edit", app.WebHandler.HandleEditClientView)
-		r.Post("/clients/{id}/edit", app.WebHandler.HandleUpdateClient)
-		r.Delete("/clients/{id}/delete", app.WebHandler.HandleDeleteClient)
-
-		// Providers
-		r.Get("/providers", app.WebHandler.HandleListProviders)
-		r.Get("/providers/new", app.WebHandler.HandleCreateProviderView)
-		r.Post("/providers/new", app.WebHandler.HandleCreateProvider)
-		r.Get("/providers/{id}/edit", app.WebHandler.HandleEditProviderView)
-		r.Post("/providers/{id}/edit", app.WebHandler.HandleUpdateProvider)
-		r.Delete("/providers/{id}/delete", app.WebHandler.HandleDeleteProvider)
-
-		// Provider Categories (integrated into Providers UI)
-		r.Route("/providers/categories", func(r chi.Router) {
-			r.Post("/new", app.WebHandler.HandleCreateProviderCategory)
-			r.Post("/quick", app.WebHandler.HandleQuickCreateProviderCategory)
-			r.Delete("/{id}/delete", app.WebHandler.HandleDeleteProviderCategory)
-			r.Get("/{id}/edit-form", app.WebHandler.HandleGetProviderCategoryEditForm)
-			r.Put("/{id}/edit", app.WebHandler.HandleUpdateProviderCategory)
-		})
-
-		// Recipes
+		// Products and Recipes (everyone views, products.edit edits)
+		r.Get("/products", app.WebHandler.HandleListProducts)
 		r.Get("/products/{id}/recipe", app.WebHandler.HandleManageRecipeView)
 		r.Get("/products/{id}/recipe-modal", app.WebHandler.HandleGetRecipeModal)
-		r.Post("/products/{id}/recipe", app.WebHandler.HandleAddIngredientToRecipe)
-		r.Delete("/products/{id}/ingredients/{ingredient_id}", app.WebHandler.HandleRemoveIngredientFromRecipe)
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermProductsEdit))
+			r.Get("/products/new", app.WebHandler.HandleCreateProductView)
+			r.Post("/products/new", app.WebHandler.HandleCreateProduct)
+			r.Get("/products/{id}/edit", app.WebHandler.HandleEditProductView)
+			r.Post("/products/{id}/edit", app.WebHandler.HandleUpdateProduct)
+			r.Delete("/products/{id}/delete", app.WebHandler.HandleDeleteProduct)
+
+			r.Post("/products/{id}/recipe", app.WebHandler.HandleAddIngredientToRecipe)
+			r.Delete("/products/{id}/ingredients/{ingredient_id}", app.WebHandler.HandleRemoveIngredientFromRecipe)
+
+			// Categories
+			r.Get("/categories", app.WebHandler.HandleListCategories)
+			r.Get("/categories/new", app.WebHandler.HandleCreateCategoryView)
+			r.Post("/categories/new", app.WebHandler.HandleCreateCategory)
+			r.Post("/categories/quick", app.WebHandler.HandleQuickCreateCategory)
+			r.Get("/categories/{id}/edit", app.WebHandler.HandleEditCategoryView)
+			r.Post("/categories/{id}/edit", app.WebHandler.HandleUpdateCategory)
+			r.Delete("/categories/{id}/delete", app.WebHandler.HandleDeleteCategory)
+
+			// Ingredients
+			r.Get("/ingredients", app.WebHandler.HandleListIngredients)
+			r.Get("/ingredients/new", app.WebHandler.HandleCreateIngredientView)
+			r.Post("/ingredients/new", app.WebHandler.HandleCreateIngredient)
+			r.Get("/ingredients/{id}/edit", app.WebHandler.HandleEditIngredientView)
+			r.Post("/ingredients/{id}/edit", app.WebHandler.HandleUpdateIngredient)
+			r.Delete("/ingredients/{id}/delete", app.WebHandler.HandleDeleteIngredient)
+		})
 
-		// Payment Methods
+		// Clients
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermClientsManage))
+			r.Get("/clients", app.WebHandler.HandleListClients)
+			r.Get("/clients/new", app.WebHandler.HandleCreateClientView)
+			r.Post("/clients/new", app.WebHandler.HandleCreateClient)
+			r.Get("/clients/{id}/edit", app.WebHandler.HandleEditClientView)
+			r.Post("/clients/{id}/edit", app.WebHandler.HandleUpdateClient)
+			r.Delete("/clients/{id}/delete", app.WebHandler.HandleDeleteClient)
+		})
+
+		// Providers and their Categories
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermProvidersManage))
+			r.Get("/providers", app.WebHandler.HandleListProviders)
+			r.Get("/providers/new", app.WebHandler.HandleCreateProviderView)
+			r.Post("/providers/new", app.WebHandler.HandleCreateProvider)
+			r.Get("/providers/{id}/edit", app.WebHandler.HandleEditProviderView)
+			r.Post("/providers/{id}/edit", app.WebHandler.HandleUpdateProvider)
+			r.Delete("/providers/{id}/delete", app.WebHandler.HandleDeleteProvider)
+
+			r.Route("/providers/categories", func(r chi.Router) {
+				r.Post("/new", app.WebHandler.HandleCreateProviderCategory)
+				r.Post("/quick", app.WebHandler.HandleQuickCreateProviderCategory)
+				r.Delete("/{id}/delete", app.WebHandler.HandleDeleteProviderCategory)
+				r.Get("/{id}/edit-form", app.WebHandler.HandleGetProviderCategoryEditForm)
+				r.Put("/{id}/edit", app.WebHandler.HandleUpdateProviderCategory)
+			})
+		})
+
+		// Payment Methods (everyone views, settings.manage edits)
 		r.Get("/payment_methods", app.WebHandler.HandleListPaymentMethods)
-		r.Get("/payment_methods/new", app.WebHandler.HandleCreatePaymentMethodView)
-		r.Post("/payment_methods/new", app.WebHandler.HandleCreatePaymentMethod)
-		r.Get("/payment_methods/{id}/edit", app.WebHandler.HandleEditPaymentMethodView)
-		r.Post("/payment_methods/{id}/edit", app.WebHandler.HandleUpdatePaymentMethod)
-		r.Delete("/payment_methods/{id}/delete", app.WebHandler.HandleDeletePaymentMethod)
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermSettingsManage))
+			r.Get("/payment_methods/new", app.WebHandler.HandleCreatePaymentMethodView)
+			r.Post("/payment_methods/new", app.WebHandler.HandleCreatePaymentMethod)
+			r.Get("/payment_methods/{id}/edit", app.WebHandler.HandleEditPaymentMethodView)
+			r.Post("/payment_methods/{id}/edit", app.WebHandler.HandleUpdatePaymentMethod)
+			r.Delete("/payment_methods/{id}/delete", app.WebHandler.HandleDeletePaymentMethod)
+		})
 
 		// Orders
-		r.Get("/orders", app.WebHandler.HandleListOrders)
-		r.Get("/orders/new", app.WebHandler.HandleCreateOrderView)
-		r.Post("/orders/new", app.WebHandler.HandleCreateOrder)
-		r.Get("/orders/{id}", app.WebHandler.HandleGetOrderView)
-		r.Patch("/orders/{id}/state", app.WebHandler.HandleUpdateOrderState)
-		r.Post("/orders/mark-paid", app.WebHandler.HandleMarkOrderPaid)
-
-		// Local Stock (Admin only checked in handler)
-		r.Post("/local-stock/update", app.WebHandler.HandleUpdateLocalStock)
-
-		// Local Sales (Admin/Employee checked in handler)
-		r.Get("/local-sales", app.WebHandler.HandleListLocalSales)
-		r.Get("/local-sales/new", app.WebHandler.HandleCreateLocalSaleView)
-		r.Post("/local-sales/new", app.WebHandler.HandleCreateLocalSale)
-		r.Get("/local-sales/{id}", app.WebHandler.HandleGetLocalSaleView)
-		r.Delete("/local-sales/{id}", app.WebHandler.HandleRevokeLocalSale)
-
-		// Shift Management (Admin/Employee)
-		r.Get("/shifts", app.WebHandler.HandleShiftManagement)
-		r.Post("/shifts/open", app.WebHandler.HandleOpenShift)
-		r.Post("/shifts/close", app.WebHandler.HandleCloseShift)
-		r.Post("/shifts/movements", app.WebHandler.HandleRegisterMovement)
-
-		// Production Calculator (Employee and Admin)
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermOrdersManage))
+			r.Get("/orders", app.WebHandler.HandleListOrders)
+			r.Get("/orders/new", app.WebHandler.HandleCreateOrderView)
+			r.Post("/orders/new", app.WebHandler.HandleCreateOrder)
+			r.Get("/orders/{id}", app.WebHandler.HandleGetOrderView)
+			r.Patch("/orders/{id}/state", app.WebHandler.HandleUpdateOrderState)
+			r.Post("/orders/mark-paid", app.WebHandler.HandleMarkOrderPaid)
+		})
+
+		// Local Stock
+		r.With(app.Middleware.RequirePermission(store.PermStockAdjust)).Post("/local-stock/update", app.WebHandler.HandleUpdateLocalStock)
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermStockView))
+			r.Get("/products/{id}/stock-movements", app.WebHandler.HandleStockMovementsView)
+			r.Get("/products/{id}/lots", app.WebHandler.HandleProductLotsView)
+			r.Get("/lots/{id}", app.WebHandler.HandleLotView)
+		})
+
+		// Switching the POS operator with a PIN, limited like the login.
+		r.Group(func(r chi.Router) {
+			r.Use(httprate.Limit(
+				10,
+				1*time.Minute,
+				httprate.WithKeyFuncs(utils.KeyByClientIP),
+			))
+			r.Post("/pos/operator", app.WebHandler.HandleSwitchPOSOperator)
+			r.Post("/pos/operator/clear", app.WebHandler.HandleClearPOSOperator)
+		})
+
+		// POS: an employee who entered their PIN on the device acts instead
+		// of the logged in user.
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.POSOperator)
+
+			// Local Sales (voiding past the first hour is checked in the handler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermSalesView))
+				r.Get("/local-sales", app.WebHandler.HandleListLocalSales)
+				r.Get("/local-sales/lookup", app.WebHandler.HandleLookupLocalSaleCode)
+				r.Get("/local-sales/{id}", app.WebHandler.HandleGetLocalSaleView)
+				r.Get("/local-sales/{id}/receipt", app.WebHandler.HandleLocalSaleReceipt)
+				r.Post("/local-sales/{id}/print", app.WebHandler.HandlePrintLocalSaleReceipt)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermSalesCreate))
+				r.Get("/local-sales/new", app.WebHandler.HandleCreateLocalSaleView)
+				r.Post("/local-sales/new", app.WebHandler.HandleCreateLocalSale)
+				r.Delete("/local-sales/{id}", app.WebHandler.HandleRevokeLocalSale)
+			})
+
+			// Shift Management
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermShiftsOperate))
+				r.Get("/shifts", app.WebHandler.HandleShiftManagement)
+				r.Post("/shifts/open", app.WebHandler.HandleOpenShift)
+				r.Post("/shifts/close", app.WebHandler.HandleCloseShift)
+				r.Post("/shifts/movements", app.WebHandler.HandleRegisterMovement)
+				r.Post("/shifts/handover", app.WebHandler.HandleHandoverShift)
+				r.Post("/shifts/register", app.WebHandler.HandleSelectRegister)
+			})
+		})
+
+		// Waste
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermWasteRegister))
+			r.Get("/waste", app.WebHandler.HandleWasteView)
+			r.Post("/waste", app.WebHandler.HandleRegisterWaste)
+		})
+		r.With(app.Middleware.RequirePermission(store.PermWasteReport)).Get("/waste/report", app.WebHandler.HandleWasteReportView)
+
+		// Stock Transfers
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermStockTransfer))
+			r.Get("/stock-transfers", app.WebHandler.HandleListStockTransfers)
+			r.Get("/stock-transfers/new", app.WebHandler.HandleCreateStockTransferView)
+			r.Post("/stock-transfers/new", app.WebHandler.HandleCreateStockTransfer)
+			r.Get("/stock-transfers/{id}", app.WebHandler.HandleStockTransferView)
+		})
+
+		// Inventory Counts (inventory.count counts, inventory.manage starts and closes)
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermInventoryCount))
+			r.Get("/inventory-counts", app.WebHandler.HandleListInventoryCounts)
+			r.Get("/inventory-counts/{id}", app.WebHandler.HandleInventoryCountView)
+			r.Post("/inventory-counts/{id}/items/{product_id}", app.WebHandler.HandleRecordInventoryCount)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermInventoryManage))
+			r.Post("/inventory-counts", app.WebHandler.HandleStartInventoryCount)
+			r.Post("/inventory-counts/{id}/approve", app.WebHandler.HandleApproveInventoryCount)
+			r.Post("/inventory-counts/{id}/cancel", app.WebHandler.HandleCancelInventoryCount)
+		})
+
+		// Production Calculator (every user)
 		r.Get("/production-calculator", app.WebHandler.HandleShowProductionCalculator)
 		r.Post("/production-calculator", app.WebHandler.HandleCalculateProduction)
 
-		// Pending Production Ingredients (Admin Only)
+		// Pending Production Ingredients and Suggestions
 		r.Group(func(r chi.Router) {
-			r.Use(app.Middleware.RequireAdmin)
+			r.Use(app.Middleware.RequirePermission(store.PermProductionPlan))
 			r.Get("/pending-production-ingredients", app.WebHandler.HandleShowPendingProductionIngredients)
+			r.Get("/production-suggestions", app.WebHandler.HandleShowProductionSuggestions)
 		})
 
-		// Expenses (Admin Only)
-		r.Group(func(r chi.Router) {
-			r.Use(app.Middleware.RequireAdmin)
-			r.Route("/expenses", func(r chi.Router) {
+		// Expenses (expenses.view views, expenses.create loads and deletes)
+		r.Route("/expenses", func(r chi.Router) {
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermExpensesView))
 				r.Get("/", app.WebHandler.HandleListExpenses)
+				r.Get("/extractions", app.WebHandler.HandleExpenseExtractionsView)
+				r.Get("/extractions/{id}/image", app.WebHandler.HandleGetExpenseExtractionImage)
+				r.Get("/{id}", app.WebHandler.HandleExpenseDetailView)
+				r.Get("/{id}/image", app.WebHandler.HandleGetExpenseImage)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(app.Middleware.RequirePermission(store.PermExpensesCreate))
 				r.Get("/new", app.WebHandler.HandleCreateExpenseView)
 				r.Post("/new", app.WebHandler.HandleCreateExpense)
+				r.Post("/scan", app.WebHandler.HandleScanExpenseReceipt)
+				r.Post("/extractions/{id}/discard", app.WebHandler.HandleDiscardExpenseExtraction)
 				r.Delete("/{id}", app.WebHandler.HandleDeleteExpense)
-				r.Get("/{id}/image", app.WebHandler.HandleGetExpenseImage)
 				r.Post("/categories/quick", app.WebHandler.HandleQuickCreateExpenseCategory)
 			})
 		})
 
+		// Purchase Orders
+		r.Route("/purchase-orders", func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermPurchasesManage))
+			r.Get("/", app.WebHandler.HandleListPurchaseOrders)
+			r.Get("/new", app.WebHandler.HandleCreatePurchaseOrderView)
+			r.Post("/new", app.WebHandler.HandleCreatePurchaseOrder)
+			r.Get("/{id}", app.WebHandler.HandlePurchaseOrderView)
+			r.Get("/{id}/edit", app.WebHandler.HandleEditPurchaseOrderView)
+			r.Post("/{id}/edit", app.WebHandler.HandleUpdatePurchaseOrder)
+			r.Post("/{id}/send", app.WebHandler.HandleSendPurchaseOrder)
+			r.Post("/{id}/mark-sent", app.WebHandler.HandleMarkPurchaseOrderSent)
+			r.Post("/{id}/cancel", app.WebHandler.HandleCancelPurchaseOrder)
+			r.Post("/{id}/receive", app.WebHandler.HandleReceivePurchaseOrder)
+		})
+
+		// Accounts Payable
+		r.Group(func(r chi.Router) {
+			r.Use(app.Middleware.RequirePermission(store.PermPayablesManage))
+			r.Get("/payables", app.WebHandler.HandlePayablesView)
+			r.Get("/providers/{id}/statement", app.WebHandler.HandleProviderStatementView)
+			r.Get("/providers/{id}/payments/new", app.WebHandler.HandleProviderPaymentView)
+			r.Post("/providers/{id}/payments/new", app.WebHandler.HandleCreateProviderPayment)
+		})
+
 	})
 
 	return r
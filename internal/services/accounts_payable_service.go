@@ -0,0 +1,298 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrPaymentWithoutExpenses  = errors.New("el pago debe aplicarse al menos a un gasto")
+	ErrInvalidPaymentAmount    = errors.New("los importes a pagar deben ser mayores a 0")
+	ErrDuplicatePaymentExpense = errors.New("hay gastos repetidos en el pago")
+	ErrInvalidPaymentDate      = errors.New("fecha de pago inválida")
+	ErrExpenseNotFound         = errors.New("gasto no encontrado")
+	ErrExpenseOtherProvider    = errors.New("el gasto no pertenece al proveedor")
+	ErrPaymentExceedsBalance   = errors.New("el importe supera el saldo pendiente del gasto")
+	ErrPayableWithoutProvider  = errors.New("los gastos a pagar deben tener un proveedor")
+	ErrInvalidDueDate          = errors.New("fecha de vencimiento inválida")
+	ErrInvalidStatementRange   = errors.New("rango de fechas inválido")
+	ErrPaymentNotFound         = errors.New("pago no encontrado")
+)
+
+// ApplyPaymentTerms sets whether an expense being recorded is owed or paid.
+// With a due date (YYYY-MM-DD) it stays pending until a provider payment is
+// applied to it, which needs a provider; without one it is paid on the spot.
+func ApplyPaymentTerms(e *store.Expense, dueDate string) error {
+	dueDate = strings.TrimSpace(dueDate)
+	if dueDate == "" {
+		e.DueDate = nil
+		e.PaidAmount = e.Amount
+		return nil
+	}
+
+	d, err := time.Parse("2006-01-02", dueDate)
+	if err != nil {
+		return ErrInvalidDueDate
+	}
+	if e.ProviderID == nil {
+		return ErrPayableWithoutProvider
+	}
+	e.DueDate = &d
+	e.PaidAmount = "0"
+	return nil
+}
+
+type PaymentAllocationRequest struct {
+	ExpenseID int64   `json:"expense_id"`
+	Amount    float64 `json:"amount"`
+}
+
+type ProviderPaymentRequest struct {
+	ProviderID      int64                      `json:"provider_id"`
+	PaymentMethodID int64                      `json:"payment_method_id"`
+	Date            string                     `json:"date,omitempty" example:"2026-05-20"` // today when empty
+	Notes           string                     `json:"notes"`
+	Allocations     []PaymentAllocationRequest `json:"allocations"`
+	// UserID is who registered the payment.
+	UserID int64 `json:"-"`
+}
+
+// Payables are the expenses left to pay, by due date.
+type Payables struct {
+	Items   []*store.Expense `json:"items"`
+	Total   float64          `json:"total"`
+	Overdue float64          `json:"overdue"`
+}
+
+// ProviderStatement is a provider account from its first to its last day.
+type ProviderStatement struct {
+	ProviderID     int64                  `json:"provider_id"`
+	ProviderName   string                 `json:"provider_name"`
+	From           time.Time              `json:"from"`
+	To             time.Time              `json:"to"`
+	OpeningBalance float64                `json:"opening_balance"`
+	Lines          []*store.StatementLine `json:"lines"`
+	ClosingBalance float64                `json:"closing_balance"`
+}
+
+// AccountsPayableService tracks what is owed to providers and the payments
+// applied to their expenses.
+type AccountsPayableService struct {
+	db                 *sql.DB
+	expenseStore       store.ExpenseStore
+	paymentStore       store.ProviderPaymentStore
+	providerStore      store.ProviderStore
+	paymentMethodStore store.PaymentMethodStore
+}
+
+func NewAccountsPayableService(
+	db *sql.DB,
+	expenseStore store.ExpenseStore,
+	paymentStore store.ProviderPaymentStore,
+	providerStore store.ProviderStore,
+	paymentMethodStore store.PaymentMethodStore,
+) *AccountsPayableService {
+	return &AccountsPayableService{
+		db:                 db,
+		expenseStore:       expenseStore,
+		paymentStore:       paymentStore,
+		providerStore:      providerStore,
+		paymentMethodStore: paymentMethodStore,
+	}
+}
+
+// ExpenseBalance is what is left to pay of an expense.
+func ExpenseBalance(e *store.Expense) float64 {
+	amount, _ := strconv.ParseFloat(e.Amount, 64)
+	paid, _ := strconv.ParseFloat(e.PaidAmount, 64)
+	return RoundMoney(amount - paid)
+}
+
+// RegisterPayment records a payment to a provider and applies it to the
+// given expenses, all of which must belong to the provider and have enough
+// balance left. The payment amount is the sum of the allocations.
+func (s *AccountsPayableService) RegisterPayment(req ProviderPaymentRequest) (*store.ProviderPayment, error) {
+	if len(req.Allocations) == 0 {
+		return nil, ErrPaymentWithoutExpenses
+	}
+
+	provider, err := s.providerStore.GetProviderByID(req.ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting provider: %w", err)
+	}
+	if provider == nil {
+		return nil, ErrProviderNotFound
+	}
+	method, err := s.paymentMethodStore.GetPaymentMethodByID(req.PaymentMethodID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting payment method: %w", err)
+	}
+	if method == nil {
+		return nil, ErrPaymentMethodNotFound
+	}
+
+	now := time.Now()
+	date := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if d := strings.TrimSpace(req.Date); d != "" {
+		date, err = time.Parse("2006-01-02", d)
+		if err != nil {
+			return nil, ErrInvalidPaymentDate
+		}
+	}
+
+	payment := &store.ProviderPayment{
+		ProviderID:      provider.ID,
+		PaymentMethodID: method.ID,
+		Date:            date,
+		Notes:           strings.TrimSpace(req.Notes),
+	}
+	if req.UserID != 0 {
+		payment.UserID = &req.UserID
+	}
+	seen := make(map[int64]bool)
+	for _, a := range req.Allocations {
+		amount := RoundMoney(a.Amount)
+		if amount <= 0 {
+			return nil, ErrInvalidPaymentAmount
+		}
+		if seen[a.ExpenseID] {
+			return nil, ErrDuplicatePaymentExpense
+		}
+		seen[a.ExpenseID] = true
+		payment.Allocations = append(payment.Allocations, &store.PaymentAllocation{ExpenseID: a.ExpenseID, Amount: amount})
+		payment.Amount += amount
+	}
+	payment.Amount = RoundMoney(payment.Amount)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, a := range payment.Allocations {
+		expense, err := s.expenseStore.LockExpenseTx(tx, a.ExpenseID)
+		if err != nil {
+			return nil, fmt.Errorf("error locking expense: %w", err)
+		}
+		if expense == nil {
+			return nil, fmt.Errorf("%w: id %d", ErrExpenseNotFound, a.ExpenseID)
+		}
+		if expense.ProviderID == nil || *expense.ProviderID != provider.ID {
+			return nil, fmt.Errorf("%w: id %d", ErrExpenseOtherProvider, a.ExpenseID)
+		}
+		if a.Amount > ExpenseBalance(expense) {
+			return nil, fmt.Errorf("%w: id %d", ErrPaymentExceedsBalance, a.ExpenseID)
+		}
+		a.ExpenseDate = expense.Date
+	}
+
+	if err := s.paymentStore.CreateTx(tx, payment); err != nil {
+		return nil, fmt.Errorf("error creating payment: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing payment: %w", err)
+	}
+
+	return s.GetPayment(payment.ID)
+}
+
+func (s *AccountsPayableService) GetPayment(id int64) (*store.ProviderPayment, error) {
+	payment, err := s.paymentStore.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting payment: %w", err)
+	}
+	if payment == nil {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, nil
+}
+
+// ListPayments returns payments made in [from, to), newest first. A zero
+// providerID lists every provider.
+func (s *AccountsPayableService) ListPayments(providerID int64, from, to time.Time) ([]*store.ProviderPayment, error) {
+	if !to.After(from) {
+		return nil, ErrInvalidStatementRange
+	}
+	return s.paymentStore.List(providerID, from, to)
+}
+
+// Payables lists the expenses left to pay, soonest due first, with what is
+// owed in total and overdue. A nil until lists them all.
+func (s *AccountsPayableService) Payables(providerID *int64, until *time.Time) (*Payables, error) {
+	expenses, err := s.expenseStore.ListPayables(providerID, until)
+	if err != nil {
+		return nil, fmt.Errorf("error listing payables: %w", err)
+	}
+
+	payables := &Payables{Items: expenses}
+	if payables.Items == nil {
+		payables.Items = []*store.Expense{}
+	}
+	for _, e := range expenses {
+		balance := ExpenseBalance(e)
+		payables.Total += balance
+		if e.Overdue {
+			payables.Overdue += balance
+		}
+	}
+	payables.Total = RoundMoney(payables.Total)
+	payables.Overdue = RoundMoney(payables.Overdue)
+	return payables, nil
+}
+
+// Balances returns what is owed to each provider, largest first.
+func (s *AccountsPayableService) Balances() ([]*store.ProviderBalance, error) {
+	balances, err := s.paymentStore.Balances()
+	if err != nil {
+		return nil, fmt.Errorf("error getting provider balances: %w", err)
+	}
+	if balances == nil {
+		balances = []*store.ProviderBalance{}
+	}
+	return balances, nil
+}
+
+// Statement returns a provider account over [from, to): the balance owed
+// before from, each expense and payment with the running balance, and the
+// balance at the end.
+func (s *AccountsPayableService) Statement(providerID int64, from, to time.Time) (*ProviderStatement, error) {
+	if !to.After(from) {
+		return nil, ErrInvalidStatementRange
+	}
+	provider, err := s.providerStore.GetProviderByID(providerID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting provider: %w", err)
+	}
+	if provider == nil {
+		return nil, ErrProviderNotFound
+	}
+
+	opening, lines, err := s.paymentStore.Statement(providerID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error getting provider statement: %w", err)
+	}
+
+	statement := &ProviderStatement{
+		ProviderID:     provider.ID,
+		ProviderName:   provider.Name,
+		From:           from,
+		To:             to.AddDate(0, 0, -1),
+		OpeningBalance: RoundMoney(opening),
+		Lines:          lines,
+		ClosingBalance: RoundMoney(opening),
+	}
+	if statement.Lines == nil {
+		statement.Lines = []*store.StatementLine{}
+	}
+	if len(lines) > 0 {
+		statement.ClosingBalance = RoundMoney(lines[len(lines)-1].Balance)
+	}
+	return statement, nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyPaymentTerms(t *testing.T) {
+	providerID := int64(1)
+
+	e := &store.Expense{Amount: "500.00"}
+	require.NoError(t, ApplyPaymentTerms(e, ""))
+	assert.Equal(t, "500.00", e.PaidAmount, "paid on the spot")
+	assert.Nil(t, e.DueDate)
+
+	assert.ErrorIs(t, ApplyPaymentTerms(e, "2026-05-20"), ErrPayableWithoutProvider)
+
+	e.ProviderID = &providerID
+	assert.ErrorIs(t, ApplyPaymentTerms(e, "20/05/2026"), ErrInvalidDueDate)
+	require.NoError(t, ApplyPaymentTerms(e, "2026-05-20"))
+	assert.Equal(t, "0", e.PaidAmount)
+	require.NotNil(t, e.DueDate)
+	assert.Equal(t, "2026-05-20", e.DueDate.Format("2006-01-02"))
+}
+
+func TestAccountsPayableService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	providerStore := store.NewPostgresProviderStore(db)
+	expenseStore := store.NewPostgresExpenseStore(db)
+	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
+	service := NewAccountsPayableService(db, expenseStore, store.NewPostgresProviderPaymentStore(db), providerStore, paymentMethodStore)
+
+	provider := &store.Provider{Name: "Molino", Reference: "ref-ap", CUIT: "cuit-ap"}
+	require.NoError(t, providerStore.CreateProvider(provider))
+	other := &store.Provider{Name: "Granja", Reference: "ref-ap-2", CUIT: "cuit-ap-2"}
+	require.NoError(t, providerStore.CreateProvider(other))
+	pm := &store.PaymentMethod{Name: "payables-tester", Reference: "cash"}
+	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
+	category, err := expenseStore.GetExpenseCategoryByName(PurchasesCategory)
+	require.NoError(t, err)
+	require.NotNil(t, category)
+
+	invoice := func(providerID int64, amount, due string) *store.Expense {
+		t.Helper()
+		e := &store.Expense{Amount: amount, CategoryID: category.ID, Type: store.ExpenseTypeProduction, Date: time.Now(), ProviderID: &providerID}
+		require.NoError(t, ApplyPaymentTerms(e, due))
+		require.NoError(t, expenseStore.CreateExpense(e))
+		return e
+	}
+	late := invoice(provider.ID, "1000.00", time.Now().AddDate(0, 0, -3).Format("2006-01-02"))
+	upcoming := invoice(provider.ID, "250.00", time.Now().AddDate(0, 0, 10).Format("2006-01-02"))
+	foreign := invoice(other.ID, "80.00", time.Now().AddDate(0, 0, 5).Format("2006-01-02"))
+
+	t.Run("lists payables by due date", func(t *testing.T) {
+		payables, err := service.Payables(nil, nil)
+		require.NoError(t, err)
+		require.Len(t, payables.Items, 3)
+		assert.Equal(t, late.ID, payables.Items[0].ID)
+		assert.Equal(t, foreign.ID, payables.Items[1].ID)
+		assert.Equal(t, upcoming.ID, payables.Items[2].ID)
+		assert.Equal(t, 1330.0, payables.Total)
+		assert.Equal(t, 1000.0, payables.Overdue)
+
+		payables, err = service.Payables(&provider.ID, nil)
+		require.NoError(t, err)
+		assert.Len(t, payables.Items, 2)
+	})
+
+	t.Run("validates payments", func(t *testing.T) {
+		_, err := service.RegisterPayment(ProviderPaymentRequest{ProviderID: provider.ID, PaymentMethodID: pm.ID})
+		assert.ErrorIs(t, err, ErrPaymentWithoutExpenses)
+
+		_, err = service.RegisterPayment(ProviderPaymentRequest{ProviderID: provider.ID, PaymentMethodID: 9999,
+			Allocations: []PaymentAllocationRequest{{ExpenseID: late.ID, Amount: 10}}})
+		assert.ErrorIs(t, err, ErrPaymentMethodNotFound)
+
+		_, err = service.RegisterPayment(ProviderPaymentRequest{ProviderID: provider.ID, PaymentMethodID: pm.ID,
+			Allocations: []PaymentAllocationRequest{{ExpenseID: late.ID, Amount: 10}, {ExpenseID: late.ID, Amount: 5}}})
+		assert.ErrorIs(t, err, ErrDuplicatePaymentExpense)
+
+		_, err = service.RegisterPayment(ProviderPaymentRequest{ProviderID: provider.ID, PaymentMethodID: pm.ID,
+			Allocations: []PaymentAllocationRequest{{ExpenseID: foreign.ID, Amount: 10}}})
+		assert.ErrorIs(t, err, ErrExpenseOtherProvider)
+
+		_, err = service.RegisterPayment(ProviderPaymentRequest{ProviderID: provider.ID, PaymentMethodID: pm.ID,
+			Allocations: []PaymentAllocationRequest{{ExpenseID: late.ID, Amount: 1000.01}}})
+		assert.ErrorIs(t, err, ErrPaymentExceedsBalance)
+	})
+
+	t.Run("applies one payment to several invoices", func(t *testing.T) {
+		payment, err := service.RegisterPayment(ProviderPaymentRequest{
+			ProviderID:      provider.ID,
+			PaymentMethodID: pm.ID,
+			Notes:           "Transferencia",
+			Allocations: []PaymentAllocationRequest{
+				{ExpenseID: late.ID, Amount: 1000},
+				{ExpenseID: upcoming.ID, Amount: 100},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1100.0, payment.Amount)
+		assert.Len(t, payment.Allocations, 2)
+
+		paid, err := expenseStore.GetExpenseByID(late.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.ExpensePaid, paid.PaymentStatus)
+		assert.False(t, paid.Overdue)
+
+		payables, err := service.Payables(&provider.ID, nil)
+		require.NoError(t, err)
+		require.Len(t, payables.Items, 1)
+		assert.Equal(t, 150.0, payables.Total)
+		assert.Equal(t, 0.0, payables.Overdue)
+
+		balances, err := service.Balances()
+		require.NoError(t, err)
+		require.Len(t, balances, 2)
+		assert.Equal(t, provider.ID, balances[0].ProviderID)
+		assert.Equal(t, 150.0, balances[0].Balance)
+	})
+
+	t.Run("builds the account statement", func(t *testing.T) {
+		today := time.Now()
+		from := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+
+		statement, err := service.Statement(provider.ID, from, from.AddDate(0, 0, 1))
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, statement.OpeningBalance)
+		require.Len(t, statement.Lines, 3)
+		assert.Equal(t, 150.0, statement.ClosingBalance)
+		assert.Equal(t, from.Format("2006-01-02"), statement.To.Format("2006-01-02"))
+
+		_, err = service.Statement(provider.ID, from, from)
+		assert.ErrorIs(t, err, ErrInvalidStatementRange)
+		_, err = service.Statement(9999, from, from.AddDate(0, 0, 1))
+		assert.ErrorIs(t, err, ErrProviderNotFound)
+	})
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+)
+
+var (
+	ErrAPIKeyNotFound        = errors.New("clave API no encontrada")
+	ErrAPIKeyRevoked         = errors.New("la clave API ya está revocada")
+	ErrAPIKeyNameEmpty       = errors.New("el nombre de la clave es obligatorio")
+	ErrAPIKeyNoScopes        = errors.New("la clave necesita al menos un permiso")
+	ErrAPIKeyScopeNotGranted = errors.New("no podés dar a una clave un permiso que tu rol no tiene")
+	ErrAPIKeyScopeForbidden  = errors.New("las claves API no pueden gestionar usuarios, roles ni otras claves")
+	ErrAPIKeyInvalidIP       = errors.New("IP o rango inválido")
+	ErrAPIKeyExpired         = errors.New("la fecha de vencimiento ya pasó")
+)
+
+// apiKeyPrefixLen is how much of a key is kept to tell keys apart.
+const apiKeyPrefixLen = len(tokens.APIKeyPrefix) + 6
+
+type APIKeyRequest struct {
+	Name   string   `json:"name" example:"tienda online"`
+	Scopes []string `json:"scopes" example:"orders.manage,clients.manage"`
+	// AllowedIPs are IPs or CIDR ranges; empty allows any.
+	AllowedIPs []string   `json:"allowed_ips" example:"203.0.113.10,10.0.0.0/8"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// APIKeyService manages the long-lived keys integrations use instead of a
+// user token. A key acts as the user who created it and only gets scopes
+// that user's role has, never users.manage.
+type APIKeyService struct {
+	store store.APIKeyStore
+}
+
+func NewAPIKeyService(s store.APIKeyStore) *APIKeyService {
+	return &APIKeyService{store: s}
+}
+
+func (s *APIKeyService) List() ([]*store.APIKey, error) {
+	keys, err := s.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *APIKeyService) Get(id int64) (*store.APIKey, error) {
+	key, err := s.store.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting api key: %w", err)
+	}
+	if key == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// Create issues a key acting as owner. The key itself is only returned here;
+// just its hash is kept.
+func (s *APIKeyService) Create(owner *store.User, req APIKeyRequest) (*store.APIKey, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", ErrAPIKeyNameEmpty
+	}
+
+	seen := map[string]bool{}
+	scopes := []string{}
+	for _, scope := range req.Scopes {
+		scope = strings.TrimSpace(scope)
+		switch {
+		case !store.IsPermission(scope):
+			return nil, "", fmt.Errorf("%w: %s", ErrUnknownPermission, scope)
+		case !APIKeyScopeAllowed(scope):
+			return nil, "", ErrAPIKeyScopeForbidden
+		case !owner.Can(scope):
+			return nil, "", fmt.Errorf("%w: %s", ErrAPIKeyScopeNotGranted, scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, "", ErrAPIKeyNoScopes
+	}
+	sort.Strings(scopes)
+
+	allowedIPs := []string{}
+	for _, ip := range req.AllowedIPs {
+		ip = strings.TrimSpace(ip)
+		if ip == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(ip); err == nil {
+			ip = prefix.Masked().String()
+		} else if addr, err := netip.ParseAddr(ip); err == nil {
+			ip = addr.String()
+		} else {
+			return nil, "", fmt.Errorf("%w: %s", ErrAPIKeyInvalidIP, ip)
+		}
+		allowedIPs = append(allowedIPs, ip)
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, "", ErrAPIKeyExpired
+	}
+
+	plaintext, hash, err := tokens.GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &store.APIKey{
+		Name:       name,
+		Prefix:     plaintext[:apiKeyPrefixLen],
+		UserID:     owner.ID,
+		Username:   owner.Username,
+		Scopes:     scopes,
+		AllowedIPs: allowedIPs,
+		ExpiresAt:  req.ExpiresAt,
+	}
+	if err := s.store.Create(key, hash); err != nil {
+		return nil, "", fmt.Errorf("error creating api key: %w", err)
+	}
+	return key, plaintext, nil
+}
+
+func (s *APIKeyService) Revoke(id int64) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	if err := s.store.Revoke(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAPIKeyRevoked
+		}
+		return fmt.Errorf("error revoking api key: %w", err)
+	}
+	return nil
+}
+
+// APIKeyScopeAllowed reports whether a key can be given scope. Keys can't
+// manage users, so they can't create more keys either.
+func APIKeyScopeAllowed(scope string) bool {
+	return scope != store.PermUsersManage
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrBarcodeEmpty   = errors.New("el código está vacío")
+	ErrBarcodeInvalid = errors.New("el código contiene caracteres inválidos")
+	ErrBarcodeUnknown = errors.New("no hay ningún producto con ese código")
+)
+
+const maxBarcodeLength = 64
+
+// ScannedCode is the result of parsing what the scanner (or the cashier) typed.
+//
+// Label scales print in-store EAN-13 codes (prefix 20-29) laid out as
+//
+//	2T IIIII VVVVV C
+//
+// where T selects what V holds, IIIII is the item code (PLU) registered on
+// the product and C is the EAN check digit. For T in 0-4 the value is the
+// price of the label in whole pesos; for T in 5-9 it is the weight in grams.
+type ScannedCode struct {
+	Code   string   // code as scanned
+	Lookup []string // codes to search for, in order of preference
+	Price  *float64 // price embedded in a weighed label
+	Weight *float64 // weight in kilograms embedded in a weighed label
+}
+
+// NormalizeBarcode trims a code and validates that it only holds letters,
+// digits or dashes, which is what scanners and PLU keypads produce.
+func NormalizeBarcode(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", ErrBarcodeEmpty
+	}
+	if len(code) > maxBarcodeLength {
+		return "", ErrBarcodeInvalid
+	}
+	for _, r := range code {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-') {
+			return "", ErrBarcodeInvalid
+		}
+	}
+	return code, nil
+}
+
+// NormalizeBarcodes normalizes a list of codes dropping blanks and duplicates.
+func NormalizeBarcodes(codes []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	for _, c := range codes {
+		if strings.TrimSpace(c) == "" {
+			continue
+		}
+		code, err := NormalizeBarcode(c)
+		if err != nil {
+			return nil, err
+		}
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		out = append(out, code)
+	}
+	return out, nil
+}
+
+// ParseScannedCode interprets a scanned code, extracting the item code and the
+// embedded value of weighed-item labels.
+func ParseScannedCode(code string) (*ScannedCode, error) {
+	code, err := NormalizeBarcode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &ScannedCode{Code: code, Lookup: []string{code}}
+	if !isWeighedEAN13(code) {
+		return sc, nil
+	}
+
+	plu := code[2:7]
+	value, _ := strconv.Atoi(code[7:12])
+	sc.Lookup = append(sc.Lookup, plu)
+	if trimmed := strings.TrimLeft(plu, "0"); trimmed != "" && trimmed != plu {
+		sc.Lookup = append(sc.Lookup, trimmed)
+	}
+
+	if code[1] < '5' {
+		price := float64(value)
+		sc.Price = &price
+	} else {
+		weight := float64(value) / 1000
+		sc.Weight = &weight
+	}
+	return sc, nil
+}
+
+func isWeighedEAN13(code string) bool {
+	return len(code) == 13 && code[0] == '2' && validEAN13(code)
+}
+
+func validEAN13(code string) bool {
+	if len(code) != 13 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := code[i]
+		if d < '0' || d > '9' {
+			return false
+		}
+		n := int(d - '0')
+		if i%2 == 1 {
+			n *= 3
+		}
+		sum += n
+	}
+	check := (10 - sum%10) % 10
+	return code[12] == byte('0'+check)
+}
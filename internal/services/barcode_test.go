@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScannedCode(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name       string
+		code       string
+		wantErr    error
+		wantLookup []string
+		wantPrice  *float64
+		wantWeight *float64
+	}{
+		{
+			name:       "regular EAN-13",
+			code:       "7791234567898",
+			wantLookup: []string{"7791234567898"},
+		},
+		{
+			name:       "PLU typed by hand",
+			code:       " 123 ",
+			wantLookup: []string{"123"},
+		},
+		{
+			name:       "weighed label with price",
+			code:       "2000123015002",
+			wantLookup: []string{"2000123015002", "00123", "123"},
+			wantPrice:  price(1500),
+		},
+		{
+			name:       "weighed label with weight",
+			code:       "2500123007507",
+			wantLookup: []string{"2500123007507", "00123", "123"},
+			wantWeight: price(0.75),
+		},
+		{
+			name:       "in-store prefix with bad check digit is not a label",
+			code:       "2000123015003",
+			wantLookup: []string{"2000123015003"},
+		},
+		{
+			name:    "empty",
+			code:    "   ",
+			wantErr: ErrBarcodeEmpty,
+		},
+		{
+			name:    "invalid characters",
+			code:    "12 34",
+			wantErr: ErrBarcodeInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScannedCode(tt.code)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantLookup, got.Lookup)
+			assert.Equal(t, tt.wantPrice, got.Price)
+			if tt.wantWeight == nil {
+				assert.Nil(t, got.Weight)
+			} else {
+				require.NotNil(t, got.Weight)
+				assert.InDelta(t, *tt.wantWeight, *got.Weight, 0.0001)
+			}
+		})
+	}
+}
+
+func TestNormalizeBarcodes(t *testing.T) {
+	got, err := NormalizeBarcodes([]string{" 7791234567898", "", "123", "7791234567898 "})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"7791234567898", "123"}, got)
+
+	_, err = NormalizeBarcodes([]string{"ok", "no válido"})
+	assert.ErrorIs(t, err, ErrBarcodeInvalid)
+}
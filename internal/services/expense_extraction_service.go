@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/ocr"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var ErrOCRUnavailable = errors.New("la lectura automática de comprobantes no está disponible")
+
+// extractionTimeout bounds how long the OCR engine can take on one image.
+const extractionTimeout = 30 * time.Second
+
+type ExpenseExtractionService struct {
+	engine          ocr.Engine
+	extractionStore store.ExpenseExtractionStore
+	providerStore   store.ProviderStore
+	// ownCUIT is the business tax ID, printed on receipts as the buyer's.
+	ownCUIT string
+}
+
+// NewExpenseExtractionService returns the service reading receipts with
+// engine, which may be nil when no OCR engine is available.
+func NewExpenseExtractionService(
+	engine ocr.Engine,
+	extractionStore store.ExpenseExtractionStore,
+	providerStore store.ProviderStore,
+	ownCUIT string,
+) *ExpenseExtractionService {
+	return &ExpenseExtractionService{
+		engine:          engine,
+		extractionStore: extractionStore,
+		providerStore:   providerStore,
+		ownCUIT:         digits(ownCUIT),
+	}
+}
+
+// Available reports whether receipts can be read.
+func (s *ExpenseExtractionService) Available() bool {
+	return s.engine != nil
+}
+
+// Extract reads the receipt at imagePath and stores the proposed fields for
+// review. When the engine fails the extraction is stored with its error and
+// no proposals, so the expense can still be loaded by hand from the image.
+func (s *ExpenseExtractionService) Extract(ctx context.Context, imagePath string, userID *int64) (*store.ExpenseExtraction, error) {
+	if s.engine == nil {
+		return nil, ErrOCRUnavailable
+	}
+
+	extraction := &store.ExpenseExtraction{
+		ImagePath: imagePath,
+		Engine:    s.engine.Name(),
+		UserID:    userID,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, extractionTimeout)
+	defer cancel()
+	text, err := s.engine.Recognize(ctx, imagePath)
+	if err != nil {
+		extraction.Error = err.Error()
+	} else {
+		extraction.RawText = text
+		if err := s.propose(extraction, ocr.Parse(text)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.extractionStore.Create(extraction); err != nil {
+		return nil, err
+	}
+	return extraction, nil
+}
+
+// propose copies the parsed fields and matches the issuer CUIT with a
+// provider.
+func (s *ExpenseExtractionService) propose(extraction *store.ExpenseExtraction, fields ocr.Fields) error {
+	if fields.Amount != nil {
+		amount := RoundMoney(*fields.Amount)
+		extraction.Amount = &amount
+	}
+	extraction.Date = fields.Date
+	extraction.InvoiceNumber = fields.InvoiceNumber
+	extraction.InvoiceType = store.InvoiceType(fields.InvoiceType)
+
+	for _, cuit := range fields.CUITs {
+		if cuit == s.ownCUIT {
+			continue
+		}
+		extraction.ProviderCUIT = cuit
+		provider, err := s.providerStore.GetProviderByCUIT(cuit)
+		if err != nil {
+			return err
+		}
+		if provider != nil {
+			extraction.ProviderID = &provider.ID
+			extraction.ProviderName = provider.Name
+		}
+		break
+	}
+	return nil
+}
+
+func (s *ExpenseExtractionService) Get(id int64) (*store.ExpenseExtraction, error) {
+	extraction, err := s.extractionStore.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if extraction == nil {
+		return nil, ErrExtractionNotFound
+	}
+	return extraction, nil
+}
+
+func (s *ExpenseExtractionService) List(status *store.ExtractionStatus) ([]*store.ExpenseExtraction, error) {
+	return s.extractionStore.List(status, 200)
+}
+
+// Discard marks a pending extraction as reviewed without loading an expense.
+func (s *ExpenseExtractionService) Discard(id int64) error {
+	err := s.extractionStore.Discard(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrExtractionNotFound
+	}
+	return err
+}
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeOCREngine struct {
+	text string
+	err  error
+}
+
+func (e *fakeOCREngine) Name() string { return "fake" }
+
+func (e *fakeOCREngine) Recognize(ctx context.Context, imagePath string) (string, error) {
+	return e.text, e.err
+}
+
+func TestExpenseExtractionService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	expenseStore := store.NewPostgresExpenseStore(db)
+	extractionStore := store.NewPostgresExpenseExtractionStore(db)
+	providerStore := store.NewPostgresProviderStore(db)
+	engine := &fakeOCREngine{}
+	service := NewExpenseExtractionService(engine, extractionStore, providerStore, "20-12345678-6")
+	expenseService := NewExpenseService(db, expenseStore, store.NewPostgresIngredientStore(db), extractionStore)
+
+	provider := &store.Provider{Name: "Molino", Reference: "ref-ocr", CUIT: "30-71234567-1"}
+	require.NoError(t, providerStore.CreateProvider(provider))
+	category, err := expenseStore.GetExpenseCategoryByName(PurchasesCategory)
+	require.NoError(t, err)
+	require.NotNil(t, category)
+
+	t.Run("proposes fields and matches the provider", func(t *testing.T) {
+		engine.text = "FACTURA A 0003-00001234\nFecha: 20/05/2026\nCUIT 20-12345678-6\nCUIT 30712345671\nTOTAL $ 43.560,00\n"
+		extraction, err := service.Extract(context.Background(), "uploads/expenses/a.jpg", nil)
+		require.NoError(t, err)
+		require.NotNil(t, extraction.Amount)
+		assert.Equal(t, 43560.0, *extraction.Amount)
+		assert.Equal(t, "30712345671", extraction.ProviderCUIT, "the buyer CUIT is skipped")
+		require.NotNil(t, extraction.ProviderID)
+		assert.Equal(t, provider.ID, *extraction.ProviderID)
+		assert.Equal(t, "0003-00001234", extraction.InvoiceNumber)
+		assert.Equal(t, store.InvoiceTypeA, extraction.InvoiceType)
+		assert.Equal(t, store.ExtractionPending, extraction.Status)
+
+		expense, err := expenseService.Create(ExpenseRequest{
+			Amount: "43560.00", CategoryID: category.ID, Type: store.ExpenseTypeProduction, Date: "2026-05-20",
+			ProviderID: &provider.ID, InvoiceNumber: extraction.InvoiceNumber, ExtractionID: &extraction.ID,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "uploads/expenses/a.jpg", expense.ImagePath)
+
+		got, err := service.Get(extraction.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.ExtractionConfirmed, got.Status)
+		require.NotNil(t, got.ExpenseID)
+		assert.Equal(t, expense.ID, *got.ExpenseID)
+		require.NotNil(t, got.ExpenseAmount)
+		assert.Equal(t, "43560.00", *got.ExpenseAmount)
+
+		_, err = expenseService.Create(ExpenseRequest{
+			Amount: "1", CategoryID: category.ID, Type: store.ExpenseTypeProduction, Date: "2026-05-20", ExtractionID: &extraction.ID,
+		})
+		assert.ErrorIs(t, err, ErrExtractionNotFound, "an extraction loads a single expense")
+	})
+
+	t.Run("stores engine failures for review", func(t *testing.T) {
+		engine.err = errors.New("unreadable image")
+		extraction, err := service.Extract(context.Background(), "uploads/expenses/b.jpg", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "unreadable image", extraction.Error)
+		assert.Nil(t, extraction.Amount)
+
+		require.NoError(t, service.Discard(extraction.ID))
+		assert.ErrorIs(t, service.Discard(extraction.ID), ErrExtractionNotFound)
+
+		discarded := store.ExtractionDiscarded
+		list, err := service.List(&discarded)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.Equal(t, extraction.ID, list[0].ID)
+	})
+
+	t.Run("needs an engine", func(t *testing.T) {
+		unavailable := NewExpenseExtractionService(nil, extractionStore, providerStore, "")
+		assert.False(t, unavailable.Available())
+		_, err := unavailable.Extract(context.Background(), "x.jpg", nil)
+		assert.ErrorIs(t, err, ErrOCRUnavailable)
+	})
+}
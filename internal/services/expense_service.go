@@ -0,0 +1,265 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrInvalidExpenseAmount   = errors.New("el monto del gasto debe ser mayor a 0")
+	ErrMissingExpenseFields   = errors.New("faltan datos obligatorios del gasto")
+	ErrInvalidExpenseDate     = errors.New("fecha del gasto inválida")
+	ErrInvalidInvoiceType     = errors.New("tipo de factura inválido")
+	ErrInvoiceWithoutProvider = errors.New("los gastos con número de factura deben tener un proveedor")
+	ErrInvalidExpenseItem     = errors.New("cada ítem debe tener un ingrediente o una descripción, cantidad mayor a 0 y precio no negativo")
+	ErrInvalidVATRate         = errors.New("alícuota de IVA inválida")
+	ErrDuplicateVATRate       = errors.New("hay alícuotas de IVA repetidas")
+	ErrInvalidVATAmount       = errors.New("los importes de IVA no pueden ser negativos")
+	ErrExtractionNotFound     = errors.New("lectura de comprobante no encontrada o ya revisada")
+)
+
+// VATRates are the rates a provider invoice can discriminate.
+var VATRates = []float64{0, 2.5, 5, 10.5, 21, 27}
+
+// DuplicateInvoiceError is returned when the provider invoice was already
+// loaded as another expense.
+type DuplicateInvoiceError struct {
+	ExpenseID int64
+}
+
+func (e *DuplicateInvoiceError) Error() string {
+	return fmt.Sprintf("%s (gasto #%d)", store.ErrDuplicateExpenseInvoice, e.ExpenseID)
+}
+
+func (e *DuplicateInvoiceError) Unwrap() error {
+	return store.ErrDuplicateExpenseInvoice
+}
+
+type ExpenseItemRequest struct {
+	IngredientID *int64  `json:"ingredient_id,omitempty"`
+	Description  string  `json:"description"`
+	Quantity     float64 `json:"quantity"`
+	UnitPrice    float64 `json:"unit_price"`
+}
+
+type ExpenseVATRequest struct {
+	Rate      float64 `json:"rate"`
+	NetAmount float64 `json:"net_amount"`
+	// VATAmount is computed from the rate when zero.
+	VATAmount float64 `json:"vat_amount"`
+}
+
+type ExpenseRequest struct {
+	// Amount is derived from the VAT breakdown, or else the items, when empty.
+	Amount        string               `json:"amount"`
+	CategoryID    int64                `json:"category_id"`
+	Type          store.ExpenseType    `json:"type"`
+	Date          string               `json:"date" example:"2026-05-20"`
+	DueDate       string               `json:"due_date,omitempty" example:"2026-06-20"`
+	ProviderID    *int64               `json:"provider_id,omitempty"`
+	InvoiceNumber string               `json:"invoice_number,omitempty" example:"0001-00001234"`
+	InvoiceType   store.InvoiceType    `json:"invoice_type,omitempty" example:"A"`
+	Items         []ExpenseItemRequest `json:"items,omitempty"`
+	VAT           []ExpenseVATRequest  `json:"vat,omitempty"`
+	// ExtractionID is the OCR reading the expense was loaded from. Its
+	// image is used when no other is uploaded.
+	ExtractionID *int64 `json:"extraction_id,omitempty"`
+	ImagePath    string `json:"-"`
+}
+
+type ExpenseService struct {
+	db              *sql.DB
+	expenseStore    store.ExpenseStore
+	ingredientStore store.IngredientStore
+	extractionStore store.ExpenseExtractionStore
+}
+
+func NewExpenseService(
+	db *sql.DB,
+	expenseStore store.ExpenseStore,
+	ingredientStore store.IngredientStore,
+	extractionStore store.ExpenseExtractionStore,
+) *ExpenseService {
+	return &ExpenseService{
+		db:              db,
+		expenseStore:    expenseStore,
+		ingredientStore: ingredientStore,
+		extractionStore: extractionStore,
+	}
+}
+
+// Create records an expense with its line items and VAT breakdown. A
+// provider can't have two expenses with the same invoice number. Loading it
+// from an OCR extraction confirms the extraction.
+func (s *ExpenseService) Create(req ExpenseRequest) (*store.Expense, error) {
+	if req.CategoryID == 0 || req.Type == "" || req.Date == "" {
+		return nil, ErrMissingExpenseFields
+	}
+	date, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		return nil, ErrInvalidExpenseDate
+	}
+
+	expense := &store.Expense{
+		CategoryID:    req.CategoryID,
+		Type:          req.Type,
+		Date:          date,
+		ProviderID:    req.ProviderID,
+		ImagePath:     req.ImagePath,
+		InvoiceNumber: strings.ToUpper(strings.TrimSpace(req.InvoiceNumber)),
+		InvoiceType:   store.InvoiceType(strings.ToUpper(strings.TrimSpace(string(req.InvoiceType)))),
+	}
+	if !validInvoiceType(expense.InvoiceType) {
+		return nil, ErrInvalidInvoiceType
+	}
+	if expense.InvoiceNumber != "" && expense.ProviderID == nil {
+		return nil, ErrInvoiceWithoutProvider
+	}
+
+	itemsTotal, err := s.buildItems(expense, req.Items)
+	if err != nil {
+		return nil, err
+	}
+	vatTotal, err := buildVAT(expense, req.VAT)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := strings.TrimSpace(req.Amount)
+	if amount == "" {
+		switch {
+		case len(expense.VAT) > 0:
+			amount = strconv.FormatFloat(RoundMoney(vatTotal), 'f', 2, 64)
+		case len(expense.Items) > 0:
+			amount = strconv.FormatFloat(RoundMoney(itemsTotal), 'f', 2, 64)
+		}
+	}
+	if v, err := strconv.ParseFloat(amount, 64); err != nil || v <= 0 || math.IsInf(v, 0) {
+		return nil, ErrInvalidExpenseAmount
+	}
+	expense.Amount = amount
+
+	if err := ApplyPaymentTerms(expense, req.DueDate); err != nil {
+		return nil, err
+	}
+
+	if expense.InvoiceNumber != "" {
+		existing, err := s.expenseStore.GetExpenseByInvoice(*expense.ProviderID, expense.InvoiceNumber)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, &DuplicateInvoiceError{ExpenseID: existing.ID}
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if req.ExtractionID != nil {
+		extraction, err := s.extractionStore.LockTx(tx, *req.ExtractionID)
+		if err != nil {
+			return nil, err
+		}
+		if extraction == nil {
+			return nil, ErrExtractionNotFound
+		}
+		if expense.ImagePath == "" {
+			expense.ImagePath = extraction.ImagePath
+		}
+	}
+
+	if err := s.expenseStore.CreateExpenseTx(tx, expense); err != nil {
+		return nil, err
+	}
+	if req.ExtractionID != nil {
+		if err := s.extractionStore.ConfirmTx(tx, *req.ExtractionID, expense.ID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return expense, nil
+}
+
+func (s *ExpenseService) buildItems(expense *store.Expense, items []ExpenseItemRequest) (float64, error) {
+	total := 0.0
+	for _, req := range items {
+		item := &store.ExpenseItem{
+			IngredientID: req.IngredientID,
+			Description:  strings.TrimSpace(req.Description),
+			Quantity:     RoundQuantity(req.Quantity),
+			UnitPrice:    RoundMoney(req.UnitPrice),
+		}
+		if (item.IngredientID == nil && item.Description == "") || item.Quantity <= 0 || item.UnitPrice < 0 {
+			return 0, ErrInvalidExpenseItem
+		}
+		if item.IngredientID != nil {
+			ingredient, err := s.ingredientStore.GetIngredientByID(*item.IngredientID)
+			if err != nil {
+				return 0, err
+			}
+			if ingredient == nil {
+				return 0, fmt.Errorf("%w: id %d", ErrIngredientNotFound, *item.IngredientID)
+			}
+			item.IngredientName = ingredient.Name
+		}
+		expense.Items = append(expense.Items, item)
+		total += item.Subtotal()
+	}
+	return total, nil
+}
+
+// buildVAT returns the invoice total: the taxed amounts plus their VAT.
+func buildVAT(expense *store.Expense, lines []ExpenseVATRequest) (float64, error) {
+	total := 0.0
+	seen := make(map[float64]bool, len(lines))
+	for _, req := range lines {
+		if !validVATRate(req.Rate) {
+			return 0, ErrInvalidVATRate
+		}
+		if seen[req.Rate] {
+			return 0, ErrDuplicateVATRate
+		}
+		seen[req.Rate] = true
+		if req.NetAmount < 0 || req.VATAmount < 0 {
+			return 0, ErrInvalidVATAmount
+		}
+
+		vat := &store.ExpenseVAT{Rate: req.Rate, NetAmount: RoundMoney(req.NetAmount), VATAmount: RoundMoney(req.VATAmount)}
+		if vat.VATAmount == 0 {
+			vat.VATAmount = RoundMoney(vat.NetAmount * vat.Rate / 100)
+		}
+		expense.VAT = append(expense.VAT, vat)
+		total += vat.NetAmount + vat.VATAmount
+	}
+	return total, nil
+}
+
+func validInvoiceType(t store.InvoiceType) bool {
+	switch t {
+	case "", store.InvoiceTypeA, store.InvoiceTypeB, store.InvoiceTypeC, store.InvoiceTypeM, store.InvoiceTypeX:
+		return true
+	}
+	return false
+}
+
+func validVATRate(rate float64) bool {
+	for _, r := range VATRates {
+		if r == rate {
+			return true
+		}
+	}
+	return false
+}
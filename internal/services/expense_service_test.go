@@ -0,0 +1,110 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpenseService_Create(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	expenseStore := store.NewPostgresExpenseStore(db)
+	ingredientStore := store.NewPostgresIngredientStore(db)
+	providerStore := store.NewPostgresProviderStore(db)
+	service := NewExpenseService(db, expenseStore, ingredientStore, store.NewPostgresExpenseExtractionStore(db))
+
+	provider := &store.Provider{Name: "Molino", Reference: "ref-exp", CUIT: "cuit-exp"}
+	require.NoError(t, providerStore.CreateProvider(provider))
+	flour := &store.Ingredient{Name: "Harina"}
+	require.NoError(t, ingredientStore.CreateIngredient(flour))
+	category, err := expenseStore.GetExpenseCategoryByName(PurchasesCategory)
+	require.NoError(t, err)
+	require.NotNil(t, category)
+
+	base := func() ExpenseRequest {
+		return ExpenseRequest{CategoryID: category.ID, Type: store.ExpenseTypeProduction, Date: "2026-05-20", ProviderID: &provider.ID}
+	}
+
+	t.Run("derives the amount from the VAT breakdown", func(t *testing.T) {
+		req := base()
+		req.InvoiceNumber = " 0001-00000077 "
+		req.InvoiceType = "a"
+		req.Items = []ExpenseItemRequest{{IngredientID: &flour.ID, Quantity: 25, UnitPrice: 36}, {Description: "Flete", Quantity: 1, UnitPrice: 100}}
+		req.VAT = []ExpenseVATRequest{{Rate: 21, NetAmount: 1000}, {Rate: 10.5, NetAmount: 200, VATAmount: 21}}
+
+		expense, err := service.Create(req)
+		require.NoError(t, err)
+		assert.Equal(t, "1431.00", expense.Amount)
+		assert.Equal(t, "0001-00000077", expense.InvoiceNumber)
+		assert.Equal(t, store.InvoiceTypeA, expense.InvoiceType)
+		assert.Equal(t, 210.0, expense.VAT[0].VATAmount)
+
+		got, err := expenseStore.GetExpenseByID(expense.ID)
+		require.NoError(t, err)
+		require.Len(t, got.Items, 2)
+		assert.Equal(t, "Harina", got.Items[0].IngredientName)
+		assert.Len(t, got.VAT, 2)
+	})
+
+	t.Run("derives the amount from the items", func(t *testing.T) {
+		req := base()
+		req.Items = []ExpenseItemRequest{{Description: "Bolsas", Quantity: 3, UnitPrice: 12.5}}
+		expense, err := service.Create(req)
+		require.NoError(t, err)
+		assert.Equal(t, "37.50", expense.Amount)
+	})
+
+	t.Run("detects duplicate invoices", func(t *testing.T) {
+		req := base()
+		req.Amount = "100.00"
+		req.InvoiceNumber = "0001-00000077"
+		_, err := service.Create(req)
+		var dup *DuplicateInvoiceError
+		require.True(t, errors.As(err, &dup))
+		assert.NotZero(t, dup.ExpenseID)
+		assert.ErrorIs(t, err, store.ErrDuplicateExpenseInvoice)
+	})
+
+	t.Run("validates", func(t *testing.T) {
+		req := base()
+		_, err := service.Create(req)
+		assert.ErrorIs(t, err, ErrInvalidExpenseAmount)
+
+		req = base()
+		req.Amount = "10"
+		req.InvoiceType = "Z"
+		_, err = service.Create(req)
+		assert.ErrorIs(t, err, ErrInvalidInvoiceType)
+
+		req = base()
+		req.Amount = "10"
+		req.ProviderID = nil
+		req.InvoiceNumber = "123"
+		_, err = service.Create(req)
+		assert.ErrorIs(t, err, ErrInvoiceWithoutProvider)
+
+		req = base()
+		req.Items = []ExpenseItemRequest{{Quantity: 1, UnitPrice: 10}}
+		_, err = service.Create(req)
+		assert.ErrorIs(t, err, ErrInvalidExpenseItem)
+
+		missing := int64(9999)
+		req.Items = []ExpenseItemRequest{{IngredientID: &missing, Quantity: 1, UnitPrice: 10}}
+		_, err = service.Create(req)
+		assert.ErrorIs(t, err, ErrIngredientNotFound)
+
+		req = base()
+		req.VAT = []ExpenseVATRequest{{Rate: 19, NetAmount: 100}}
+		_, err = service.Create(req)
+		assert.ErrorIs(t, err, ErrInvalidVATRate)
+
+		req.VAT = []ExpenseVATRequest{{Rate: 21, NetAmount: 100}, {Rate: 21, NetAmount: 50}}
+		_, err = service.Create(req)
+		assert.ErrorIs(t, err, ErrDuplicateVATRate)
+	})
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrInventoryCountNotFound   = errors.New("conteo de inventario no encontrado")
+	ErrInventoryCountClosed     = errors.New("el conteo de inventario ya fue cerrado")
+	ErrInventoryCountItem       = errors.New("el producto no forma parte del conteo")
+	ErrInvalidCountedQuantity   = errors.New("la cantidad contada debe ser 0 o mayor")
+	ErrInventoryCountNotCounted = errors.New("no se cargó ninguna cantidad en el conteo")
+)
+
+type InventoryCountService struct {
+	db           *sql.DB
+	countStore   store.InventoryCountStore
+	stockStore   store.LocalStockStore
+	productStore store.ProductStore
+}
+
+func NewInventoryCountService(
+	db *sql.DB,
+	countStore store.InventoryCountStore,
+	stockStore store.LocalStockStore,
+	productStore store.ProductStore,
+) *InventoryCountService {
+	return &InventoryCountService{
+		db:           db,
+		countStore:   countStore,
+		stockStore:   stockStore,
+		productStore: productStore,
+	}
+}
+
+// StartCount opens a count at a location (zero is the default one) with the
+// current stock of every product there as the expected quantity. Only one
+// count can be open at a time.
+func (s *InventoryCountService) StartCount(userID, locationID int64, notes string) (*store.InventoryCount, error) {
+	count := &store.InventoryCount{LocationID: locationID, Notes: strings.TrimSpace(notes)}
+	if userID != 0 {
+		count.CreatedBy = &userID
+	}
+
+	if err := s.countStore.Create(count); err != nil {
+		if errors.Is(err, store.ErrInventoryCountInProgress) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error creating inventory count: %w", err)
+	}
+	return s.GetCount(count.ID)
+}
+
+func (s *InventoryCountService) GetCount(id int64) (*store.InventoryCount, error) {
+	count, err := s.countStore.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting inventory count: %w", err)
+	}
+	if count == nil {
+		return nil, ErrInventoryCountNotFound
+	}
+	return count, nil
+}
+
+func (s *InventoryCountService) GetOpenCount() (*store.InventoryCount, error) {
+	return s.countStore.GetOpen()
+}
+
+func (s *InventoryCountService) ListCounts(limit, offset int) ([]*store.InventoryCount, error) {
+	return s.countStore.List(limit, offset)
+}
+
+// RecordCount stores what was counted for a product. A nil quantity clears a
+// previous entry. Entries can come from several devices; the last one wins.
+func (s *InventoryCountService) RecordCount(countID, productID int64, counted *float64, userID int64) (*store.InventoryCountItem, error) {
+	if counted != nil {
+		if *counted < 0 {
+			return nil, ErrInvalidCountedQuantity
+		}
+		product, err := s.productStore.GetProductByID(productID)
+		if err != nil {
+			return nil, fmt.Errorf("error checking product existence: %w", err)
+		}
+		if product == nil {
+			return nil, ErrProductNotFound
+		}
+		if err := ValidateQuantity(product, *counted); err != nil {
+			return nil, err
+		}
+	}
+
+	count, err := s.GetCount(countID)
+	if err != nil {
+		return nil, err
+	}
+	if count.Status != store.InventoryCountOpen {
+		return nil, ErrInventoryCountClosed
+	}
+
+	item, err := s.countStore.SetCounted(countID, productID, counted, userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInventoryCountItem
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error recording count: %w", err)
+	}
+	return item, nil
+}
+
+// ApproveCount closes the count and posts a "count" movement for every counted
+// product whose quantity differs from the snapshot. The variance is applied on
+// top of the current stock, so sales made while counting are kept.
+func (s *InventoryCountService) ApproveCount(id, userID int64) (*store.InventoryCount, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	count, err := s.lockOpenCount(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.countStore.ListItemsTx(tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error listing count items: %w", err)
+	}
+
+	var adjust []*store.InventoryCountItem
+	var productIDs []int64
+	counted := 0
+	for _, item := range items {
+		if item.Counted == nil {
+			continue
+		}
+		counted++
+		if RoundQuantity(item.Variance()) == 0 {
+			continue
+		}
+		adjust = append(adjust, item)
+		productIDs = append(productIDs, item.ProductID)
+	}
+	if counted == 0 {
+		return nil, ErrInventoryCountNotCounted
+	}
+
+	for _, pid := range productIDs {
+		if err := s.stockStore.EnsureTx(tx, count.LocationID, pid); err != nil {
+			return nil, fmt.Errorf("error preparing stock: %w", err)
+		}
+	}
+	stocks, err := s.stockStore.LockByProductIDsTx(tx, count.LocationID, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error locking stock: %w", err)
+	}
+
+	change := store.StockChange{
+		LocationID:    count.LocationID,
+		Reason:        store.MovementCount,
+		UserID:        userID,
+		ReferenceType: store.ReferenceInventoryCount,
+		ReferenceID:   count.ID,
+	}
+	for _, item := range adjust {
+		_, err := s.stockStore.AdjustQuantityTx(tx, item.ProductID, RoundQuantity(item.Variance()), change)
+		if errors.Is(err, store.ErrNegativeStock) {
+			return nil, &InsufficientStockError{
+				Product:   item.ProductName,
+				Available: stocks[item.ProductID].Quantity,
+				Required:  RoundQuantity(-item.Variance()),
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error adjusting stock: %w", err)
+		}
+	}
+
+	if err := s.countStore.CloseTx(tx, id, store.InventoryCountApproved, userID); err != nil {
+		return nil, fmt.Errorf("error closing inventory count: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return s.GetCount(id)
+}
+
+// CancelCount discards an open count without touching stock.
+func (s *InventoryCountService) CancelCount(id, userID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := s.lockOpenCount(tx, id); err != nil {
+		return err
+	}
+	if err := s.countStore.CloseTx(tx, id, store.InventoryCountCancelled, userID); err != nil {
+		return fmt.Errorf("error closing inventory count: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (s *InventoryCountService) lockOpenCount(tx *sql.Tx, id int64) (*store.InventoryCount, error) {
+	count, err := s.countStore.LockTx(tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error locking inventory count: %w", err)
+	}
+	if count == nil {
+		return nil, ErrInventoryCountNotFound
+	}
+	if count.Status != store.InventoryCountOpen {
+		return nil, ErrInventoryCountClosed
+	}
+	return count, nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryCountService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	movementStore := store.NewPostgresStockMovementStore(db)
+	service := NewInventoryCountService(db, store.NewPostgresInventoryCountStore(db), localStockStore, productStore)
+	saleService := NewLocalSaleService(db, store.NewPostgresLocalSaleStore(db), localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
+
+	cat := &store.Category{Name: "Category For Count Test"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+	pm := &store.PaymentMethod{Name: "count-tester", Reference: "cash"}
+	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
+
+	short := &store.Product{CategoryID: cat.ID, Name: "Short", UnitPrice: 10}
+	require.NoError(t, productStore.CreateProduct(short))
+	over := &store.Product{CategoryID: cat.ID, Name: "Over", UnitPrice: 10}
+	require.NoError(t, productStore.CreateProduct(over))
+	exact := &store.Product{CategoryID: cat.ID, Name: "Exact", UnitPrice: 10}
+	require.NoError(t, productStore.CreateProduct(exact))
+	uncounted := &store.Product{CategoryID: cat.ID, Name: "Uncounted", UnitPrice: 10}
+	require.NoError(t, productStore.CreateProduct(uncounted))
+
+	for _, p := range []*store.Product{short, over, exact, uncounted} {
+		_, err := localStockStore.Create(p.ID, 10, store.StockChange{Reason: store.MovementInitial})
+		require.NoError(t, err)
+	}
+
+	count, err := service.StartCount(0, 0, " semanal ")
+	require.NoError(t, err)
+	assert.Equal(t, "semanal", count.Notes)
+	assert.Len(t, count.Items, 4)
+
+	_, err = service.StartCount(0, 0, "")
+	assert.ErrorIs(t, err, store.ErrInventoryCountInProgress)
+
+	t.Run("approve without counts", func(t *testing.T) {
+		_, err := service.ApproveCount(count.ID, 0)
+		assert.ErrorIs(t, err, ErrInventoryCountNotCounted)
+	})
+
+	t.Run("invalid entries", func(t *testing.T) {
+		neg := -1.0
+		_, err := service.RecordCount(count.ID, short.ID, &neg, 0)
+		assert.ErrorIs(t, err, ErrInvalidCountedQuantity)
+
+		frac := 1.5
+		_, err = service.RecordCount(count.ID, short.ID, &frac, 0)
+		assert.ErrorIs(t, err, ErrFractionalQuantity)
+
+		qty := 1.0
+		_, err = service.RecordCount(9999, short.ID, &qty, 0)
+		assert.ErrorIs(t, err, ErrInventoryCountNotFound)
+	})
+
+	// A sale while counting is kept: the variance is applied on top of it.
+	_, err = saleService.CreateLocalSale(CreateLocalSaleRequest{
+		PaymentMethodID: pm.ID,
+		Items:           []CreateLocalSaleItem{{ProductID: short.ID, Quantity: 1}},
+	})
+	require.NoError(t, err)
+
+	record := func(productID int64, qty float64) {
+		t.Helper()
+		_, err := service.RecordCount(count.ID, productID, &qty, 0)
+		require.NoError(t, err)
+	}
+	record(short.ID, 7)
+	record(over.ID, 12)
+	record(exact.ID, 10)
+
+	approved, err := service.ApproveCount(count.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, store.InventoryCountApproved, approved.Status)
+	assert.NotNil(t, approved.ClosedAt)
+
+	want := map[int64]float64{short.ID: 6, over.ID: 12, exact.ID: 10, uncounted.ID: 10}
+	for id, qty := range want {
+		stock, err := localStockStore.GetByProductID(id, 0)
+		require.NoError(t, err)
+		assert.Equal(t, qty, stock.Quantity)
+	}
+
+	since, until := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	movements, err := movementStore.ListByProduct(short.ID, 0, since, until)
+	require.NoError(t, err)
+	last := movements[len(movements)-1]
+	assert.Equal(t, store.MovementCount, last.Reason)
+	assert.Equal(t, -3.0, last.Delta)
+	require.NotNil(t, last.ReferenceID)
+	assert.Equal(t, count.ID, *last.ReferenceID)
+
+	exactMovements, err := movementStore.ListByProduct(exact.ID, 0, since, until)
+	require.NoError(t, err)
+	for _, m := range exactMovements {
+		assert.NotEqual(t, store.MovementCount, m.Reason)
+	}
+
+	t.Run("closed count", func(t *testing.T) {
+		qty := 1.0
+		_, err := service.RecordCount(count.ID, short.ID, &qty, 0)
+		assert.ErrorIs(t, err, ErrInventoryCountClosed)
+
+		_, err = service.ApproveCount(count.ID, 0)
+		assert.ErrorIs(t, err, ErrInventoryCountClosed)
+
+		assert.ErrorIs(t, service.CancelCount(count.ID, 0), ErrInventoryCountClosed)
+	})
+
+	t.Run("cancel leaves stock untouched", func(t *testing.T) {
+		next, err := service.StartCount(0, 0, "")
+		require.NoError(t, err)
+		record := 0.0
+		_, err = service.RecordCount(next.ID, over.ID, &record, 0)
+		require.NoError(t, err)
+		require.NoError(t, service.CancelCount(next.ID, 0))
+
+		stock, err := localStockStore.GetByProductID(over.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 12.0, stock.Quantity)
+	})
+}
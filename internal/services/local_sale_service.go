@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"time"
 
@@ -15,13 +16,30 @@ var (
 )
 
 type CreateLocalSaleItem struct {
-	ProductID int64 `json:"product_id"`
-	Quantity  int   `json:"quantity"`
+	ProductID int64   `json:"product_id,omitempty"`
+	Barcode   string  `json:"barcode,omitempty"`
+	Quantity  float64 `json:"quantity"`
+}
+
+// ScanResult describes the sale line a scanned code stands for.
+type ScanResult struct {
+	Code      string         `json:"code"`
+	Product   *store.Product `json:"product"`
+	Quantity  float64        `json:"quantity"`
+	UnitPrice float64        `json:"unit_price"`
+	Subtotal  float64        `json:"subtotal"`
+	// FixedPrice is set for labels whose price is printed on them; the line
+	// then takes quantity and subtotal from the label.
+	FixedPrice bool     `json:"fixed_price"`
+	Weight     *float64 `json:"weight,omitempty"`
 }
 
 type CreateLocalSaleRequest struct {
 	PaymentMethodID int64                 `json:"payment_method_id"`
+	RegisterID      int64                 `json:"register_id,omitempty"`
 	Items           []CreateLocalSaleItem `json:"items"`
+	// UserID is who rang up the sale, recorded in the stock movements.
+	UserID int64 `json:"-"`
 }
 
 type LocalSaleService struct {
@@ -30,6 +48,7 @@ type LocalSaleService struct {
 	stockStore         store.LocalStockStore
 	paymentMethodStore store.PaymentMethodStore
 	productStore       store.ProductStore
+	shiftStore         store.ShiftStore
 }
 
 func NewLocalSaleService(
@@ -38,6 +57,7 @@ func NewLocalSaleService(
 	stockStore store.LocalStockStore,
 	paymentMethodStore store.PaymentMethodStore,
 	productStore store.ProductStore,
+	shiftStore store.ShiftStore,
 ) *LocalSaleService {
 	return &LocalSaleService{
 		db:                 db,
@@ -45,6 +65,7 @@ func NewLocalSaleService(
 		stockStore:         stockStore,
 		paymentMethodStore: paymentMethodStore,
 		productStore:       productStore,
+		shiftStore:         shiftStore,
 	}
 }
 
@@ -63,6 +84,48 @@ func (s *LocalSaleService) CreateLocalSale(req CreateLocalSaleRequest) (*store.L
 		return nil, ErrPaymentMethodNotFound
 	}
 
+	// Sales made at a drawer are attached to its open session so the cash
+	// trail follows the register rather than the employee, and take stock
+	// from the register's location. Other sales use the default location.
+	var shiftID *int64
+	var locationID int64
+	if req.RegisterID != 0 {
+		shift, err := s.shiftStore.GetOpenShiftByRegisterID(req.RegisterID)
+		if err != nil {
+			return nil, fmt.Errorf("error al verificar la caja: %w", err)
+		}
+		if shift == nil {
+			return nil, ErrNoOpenShift
+		}
+		shiftID = &shift.ID
+		locationID = shift.LocationID
+	}
+
+	// Scanned codes are resolved to products first; labels with a printed
+	// price fix the whole line.
+	fixedLines := make(map[int]*ScanResult)
+	for i, item := range req.Items {
+		if item.Barcode == "" {
+			continue
+		}
+		scan, err := s.LookupCode(item.Barcode)
+		if err != nil {
+			return nil, err
+		}
+		if item.ProductID != 0 && item.ProductID != scan.Product.ID {
+			return nil, fmt.Errorf("el código %s no corresponde al producto %d", item.Barcode, item.ProductID)
+		}
+		req.Items[i].ProductID = scan.Product.ID
+		// Quantities printed on a label (weight, or derived from the price)
+		// win over whatever was typed.
+		if scan.FixedPrice || scan.Weight != nil || req.Items[i].Quantity == 0 {
+			req.Items[i].Quantity = scan.Quantity
+		}
+		if scan.FixedPrice {
+			fixedLines[i] = scan
+		}
+	}
+
 	var saleItems []store.LocalSaleItem
 	var productIDs []int64
 	for _, item := range req.Items {
@@ -75,34 +138,36 @@ func (s *LocalSaleService) CreateLocalSale(req CreateLocalSaleRequest) (*store.L
 	}
 
 	var subtotal float64 = 0.0
+	// The same product can show up in several lines (e.g. two weighed labels),
+	// so stock is checked against the total per product.
+	required := make(map[int64]float64)
 
-	for _, itemReq := range req.Items {
+	for i, itemReq := range req.Items {
 		product, ok := products[itemReq.ProductID]
 		if !ok {
 			return nil, fmt.Errorf("producto no encontrado: id %d", itemReq.ProductID)
 		}
-
-		stock, err := s.stockStore.GetByProductID(itemReq.ProductID)
-		if err != nil {
-			return nil, fmt.Errorf("error al verificar stock para producto %d: %w", itemReq.ProductID, err)
+		if itemReq.Quantity <= 0 {
+			return nil, fmt.Errorf("cantidad inválida para '%s'", product.Name)
 		}
-
-		currentQty := 0
-		if stock != nil {
-			currentQty = stock.Quantity
+		if err := ValidateQuantity(product, itemReq.Quantity); err != nil {
+			return nil, fmt.Errorf("'%s': %w", product.Name, err)
 		}
 
-		if currentQty < itemReq.Quantity {
-			return nil, fmt.Errorf("stock insuficiente para '%s' (disponible: %d, requerido: %d)", product.Name, currentQty, itemReq.Quantity)
-		}
+		required[itemReq.ProductID] = RoundQuantity(required[itemReq.ProductID] + itemReq.Quantity)
 
-		lineSubtotal := product.UnitPrice * float64(itemReq.Quantity)
+		unitPrice := product.UnitPrice
+		lineSubtotal := LineSubtotal(product, unitPrice, itemReq.Quantity)
+		if scan, ok := fixedLines[i]; ok {
+			unitPrice = scan.UnitPrice
+			lineSubtotal = scan.Subtotal
+		}
 		subtotal += lineSubtotal
 
 		saleItems = append(saleItems, store.LocalSaleItem{
 			ProductID:    itemReq.ProductID,
 			Quantity:     itemReq.Quantity,
-			UnitPrice:    strconv.FormatFloat(product.UnitPrice, 'f', 2, 64),
+			UnitPrice:    strconv.FormatFloat(unitPrice, 'f', 2, 64),
 			LineSubtotal: strconv.FormatFloat(lineSubtotal, 'f', 2, 64),
 		})
 	}
@@ -116,16 +181,32 @@ func (s *LocalSaleService) CreateLocalSale(req CreateLocalSaleRequest) (*store.L
 
 	sale := &store.LocalSale{
 		PaymentMethodID: req.PaymentMethodID,
+		ShiftID:         shiftID,
+		LocationID:      locationID,
 		Subtotal:        strconv.FormatFloat(subtotal, 'f', 2, 64),
 		Total:           strconv.FormatFloat(subtotal, 'f', 2, 64),
 	}
 
+	if err := s.checkStockTx(tx, locationID, products, saleItems, required); err != nil {
+		return nil, err
+	}
+
 	if err := s.saleStore.CreateInTx(tx, sale, saleItems); err != nil {
 		return nil, fmt.Errorf("error al crear la venta: %w", err)
 	}
 
+	change := store.StockChange{
+		LocationID:    sale.LocationID,
+		Reason:        store.MovementSale,
+		UserID:        req.UserID,
+		ReferenceType: store.ReferenceLocalSale,
+		ReferenceID:   sale.ID,
+	}
 	for _, item := range saleItems {
-		if _, err := s.stockStore.AdjustQuantityTx(tx, item.ProductID, -item.Quantity); err != nil {
+		if _, err := s.stockStore.AdjustQuantityTx(tx, item.ProductID, -item.Quantity, change); err != nil {
+			if errors.Is(err, store.ErrNegativeStock) {
+				return nil, fmt.Errorf("%w: producto %d", ErrInsufficientStock, item.ProductID)
+			}
 			return nil, fmt.Errorf("error al descontar stock del producto %d: %w", item.ProductID, err)
 		}
 	}
@@ -137,6 +218,123 @@ func (s *LocalSaleService) CreateLocalSale(req CreateLocalSaleRequest) (*store.L
 	return sale, nil
 }
 
+// checkStockTx locks the stock of the sold products at the location and
+// verifies it covers the sale. Holding the locks until commit keeps two
+// concurrent sales from both passing the check on the same units.
+func (s *LocalSaleService) checkStockTx(tx *sql.Tx, locationID int64, products map[int64]*store.Product, items []store.LocalSaleItem, required map[int64]float64) error {
+	productIDs := make([]int64, 0, len(required))
+	for id := range required {
+		productIDs = append(productIDs, id)
+	}
+
+	stocks, err := s.stockStore.LockByProductIDsTx(tx, locationID, productIDs)
+	if err != nil {
+		return fmt.Errorf("error al verificar stock: %w", err)
+	}
+
+	checked := make(map[int64]bool)
+	for _, item := range items {
+		if checked[item.ProductID] {
+			continue
+		}
+		checked[item.ProductID] = true
+
+		product := products[item.ProductID]
+		stock := stocks[item.ProductID]
+		if product.AllowNegativeStock {
+			// Deducting needs a row to update.
+			if stock == nil {
+				if err := s.stockStore.EnsureTx(tx, locationID, item.ProductID); err != nil {
+					return fmt.Errorf("error al crear stock para '%s': %w", product.Name, err)
+				}
+			}
+			continue
+		}
+
+		available := 0.0
+		if stock != nil {
+			available = stock.Quantity
+		}
+		if available < required[item.ProductID] {
+			return &InsufficientStockError{Product: product.Name, Available: available, Required: required[item.ProductID]}
+		}
+	}
+	return nil
+}
+
+// InsufficientStockError details which product ran out. It matches
+// ErrInsufficientStock with errors.Is.
+type InsufficientStockError struct {
+	Product   string
+	Available float64
+	Required  float64
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("stock insuficiente para '%s' (disponible: %s, requerido: %s)", e.Product,
+		formatQuantity(e.Available), formatQuantity(e.Required))
+}
+
+func (e *InsufficientStockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+// LookupCode resolves a scanned barcode or PLU to the product and the sale
+// line it represents.
+func (s *LocalSaleService) LookupCode(code string) (*ScanResult, error) {
+	scanned, err := ParseScannedCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.productStore.GetProductByBarcode(scanned.Lookup)
+	if err != nil {
+		return nil, fmt.Errorf("error al buscar el código: %w", err)
+	}
+	if product == nil {
+		return nil, ErrBarcodeUnknown
+	}
+
+	result := &ScanResult{
+		Code:      scanned.Code,
+		Product:   product,
+		Quantity:  1,
+		UnitPrice: product.UnitPrice,
+		Weight:    scanned.Weight,
+	}
+
+	switch {
+	case scanned.Weight != nil && IsSoldByWeight(product):
+		result.Quantity = QuantityForWeight(product, *scanned.Weight)
+	case scanned.Weight != nil:
+		// A product sold per unit but weighed at the scale: the label stands
+		// for one unit priced by weight.
+		result.FixedPrice = true
+		result.UnitPrice = math.Round(product.UnitPrice**scanned.Weight*100) / 100
+		result.Subtotal = result.UnitPrice
+		return result, nil
+	case scanned.Price != nil && IsSoldByWeight(product) && product.UnitPrice > 0:
+		// Only the price is printed, the quantity sold is derived from it.
+		result.FixedPrice = true
+		result.Quantity = QuantityForWeight(product, *scanned.Price/product.UnitPrice)
+		result.Subtotal = *scanned.Price
+		return result, nil
+	case scanned.Price != nil:
+		result.FixedPrice = true
+		result.UnitPrice = *scanned.Price
+		result.Subtotal = *scanned.Price
+		return result, nil
+	}
+
+	result.Subtotal = LineSubtotal(product, result.UnitPrice, result.Quantity)
+	return result, nil
+}
+
+// formatQuantity prints a quantity without trailing zeros.
+func formatQuantity(q float64) string {
+	return strconv.FormatFloat(RoundQuantity(q), 'f', -1, 64)
+}
+
 func (s *LocalSaleService) GetSale(id int64) (*store.LocalSale, error) {
 	return s.saleStore.GetByID(id)
 }
@@ -155,7 +353,7 @@ func (s *LocalSaleService) GetStats(start, end time.Time) (*store.DailySalesStat
 	return s.saleStore.GetStats(start, end)
 }
 
-func (s *LocalSaleService) RevokeLocalSale(id int64) error {
+func (s *LocalSaleService) RevokeLocalSale(id, userID int64) error {
 	// 1. Get the sale details to know which items to return
 	sale, err := s.saleStore.GetByID(id)
 	if err != nil {
@@ -176,19 +374,30 @@ func (s *LocalSaleService) RevokeLocalSale(id int64) error {
 	}
 	defer tx.Rollback()
 
-	// 3. Restore Stock
+	// 3. Void the sale first: the row lock makes a concurrent void wait and
+	// then find it already voided, so stock is only restored once.
+	if err := s.saleStore.DeleteInTx(tx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("la venta ya ha sido anulada")
+		}
+		return fmt.Errorf("error al eliminar la venta: %w", err)
+	}
+
+	// 4. Restore Stock where it was taken from
+	change := store.StockChange{
+		LocationID:    sale.LocationID,
+		Reason:        store.MovementVoid,
+		UserID:        userID,
+		ReferenceType: store.ReferenceLocalSale,
+		ReferenceID:   id,
+	}
 	for _, item := range sale.Items {
 		// We add the quantity back (positive value)
-		if _, err := s.stockStore.AdjustQuantityTx(tx, item.ProductID, item.Quantity); err != nil {
+		if _, err := s.stockStore.AdjustQuantityTx(tx, item.ProductID, item.Quantity, change); err != nil {
 			return fmt.Errorf("error al restaurar stock del producto %d: %w", item.ProductID, err)
 		}
 	}
 
-	// 4. Delete Sale
-	if err := s.saleStore.DeleteInTx(tx, id); err != nil {
-		return fmt.Errorf("error al eliminar la venta: %w", err)
-	}
-
 	// 5. Commit
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("error al confirmar anulación: %w", err)
@@ -2,6 +2,7 @@ package services
 
 import (
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -23,7 +24,7 @@ func TestLocalSaleService_CreateLocalSale_Integration(t *testing.T) {
 	localStockStore := store.NewPostgresLocalStockStore(db)
 	localSaleStore := store.NewPostgresLocalSaleStore(db)
 
-	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore)
+	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
 
 	// --- Setup Data ---
 	cat := &store.Category{Name: "Category For Sale Test"}
@@ -39,9 +40,9 @@ func TestLocalSaleService_CreateLocalSale_Integration(t *testing.T) {
 	require.NoError(t, productStore.CreateProduct(prod20))
 
 	// Create initial stock
-	_, err := localStockStore.Create(prod10.ID, 10)
+	_, err := localStockStore.Create(prod10.ID, 10, store.StockChange{Reason: store.MovementInitial})
 	require.NoError(t, err)
-	_, err = localStockStore.Create(prod20.ID, 5)
+	_, err = localStockStore.Create(prod20.ID, 5, store.StockChange{Reason: store.MovementInitial})
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -64,13 +65,13 @@ func TestLocalSaleService_CreateLocalSale_Integration(t *testing.T) {
 			wantTotal: "250.00",
 			postCheck: func(t *testing.T) {
 				// Check if stock was correctly deduced
-				stock10, err := localStockStore.GetByProductID(prod10.ID)
+				stock10, err := localStockStore.GetByProductID(prod10.ID, 0)
 				require.NoError(t, err)
-				assert.Equal(t, 8, stock10.Quantity) // 10 - 2
+				assert.Equal(t, 8.0, stock10.Quantity) // 10 - 2
 
-				stock20, err := localStockStore.GetByProductID(prod20.ID)
+				stock20, err := localStockStore.GetByProductID(prod20.ID, 0)
 				require.NoError(t, err)
-				assert.Equal(t, 4, stock20.Quantity) // 5 - 1
+				assert.Equal(t, 4.0, stock20.Quantity) // 5 - 1
 			},
 		},
 		{
@@ -137,7 +138,7 @@ func TestLocalSaleService_GetStats(t *testing.T) {
 	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
 	localStockStore := store.NewPostgresLocalStockStore(db)
 	localSaleStore := store.NewPostgresLocalSaleStore(db)
-	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore)
+	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
 
 	// Setup
 	cat := &store.Category{Name: "Category Stats"}
@@ -146,7 +147,7 @@ func TestLocalSaleService_GetStats(t *testing.T) {
 	require.NoError(t, productStore.CreateProduct(prod))
 	pm := &store.PaymentMethod{Name: "Cash", Reference: "cash"}
 	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
-	_, err := localStockStore.Create(prod.ID, 100)
+	_, err := localStockStore.Create(prod.ID, 100, store.StockChange{Reason: store.MovementInitial})
 	require.NoError(t, err)
 
 	// Create Sale via Service
@@ -178,7 +179,7 @@ func TestLocalSaleService_ListSalesByDate(t *testing.T) {
 	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
 	localStockStore := store.NewPostgresLocalStockStore(db)
 	localSaleStore := store.NewPostgresLocalSaleStore(db)
-	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore)
+	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
 
 	// Setup
 	cat := &store.Category{Name: "Category Date"}
@@ -187,7 +188,7 @@ func TestLocalSaleService_ListSalesByDate(t *testing.T) {
 	require.NoError(t, productStore.CreateProduct(prod))
 	pm := &store.PaymentMethod{Name: "Cash", Reference: "cash"}
 	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
-	_, err := localStockStore.Create(prod.ID, 100)
+	_, err := localStockStore.Create(prod.ID, 100, store.StockChange{Reason: store.MovementInitial})
 	require.NoError(t, err)
 
 	// Create Sale (implicitly today)
@@ -209,4 +210,239 @@ func TestLocalSaleService_ListSalesByDate(t *testing.T) {
 	sales, err = service.ListSalesByDate(tomorrow)
 	require.NoError(t, err)
 	assert.Len(t, sales, 0)
-}
\ No newline at end of file
+}
+func TestLocalSaleService_ScannedCodes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	localSaleStore := store.NewPostgresLocalSaleStore(db)
+	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
+
+	cat := &store.Category{Name: "Category Scan"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+	pm := &store.PaymentMethod{Name: "Cash", Reference: "cash"}
+	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
+
+	packaged := &store.Product{CategoryID: cat.ID, Name: "Alfajor", UnitPrice: 800}
+	require.NoError(t, productStore.CreateProduct(packaged))
+	require.NoError(t, productStore.SetProductBarcodes(packaged.ID, []string{"7791234567898"}))
+
+	weighed := &store.Product{CategoryID: cat.ID, Name: "Masas Secas", UnitPrice: 12000}
+	require.NoError(t, productStore.CreateProduct(weighed))
+	require.NoError(t, productStore.SetProductBarcodes(weighed.ID, []string{"123"}))
+
+	_, err := localStockStore.Create(packaged.ID, 10, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+	_, err = localStockStore.Create(weighed.ID, 10, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+
+	t.Run("lookup regular barcode", func(t *testing.T) {
+		res, err := service.LookupCode("7791234567898")
+		require.NoError(t, err)
+		assert.Equal(t, packaged.ID, res.Product.ID)
+		assert.False(t, res.FixedPrice)
+		assert.Equal(t, 800.0, res.UnitPrice)
+	})
+
+	t.Run("lookup weighed label", func(t *testing.T) {
+		res, err := service.LookupCode("2500123007507") // 750 g
+		require.NoError(t, err)
+		assert.Equal(t, weighed.ID, res.Product.ID)
+		assert.True(t, res.FixedPrice)
+		assert.Equal(t, 9000.0, res.UnitPrice)
+	})
+
+	t.Run("lookup unknown code", func(t *testing.T) {
+		_, err := service.LookupCode("0000000000")
+		assert.ErrorIs(t, err, ErrBarcodeUnknown)
+	})
+
+	t.Run("sale with scanned codes", func(t *testing.T) {
+		sale, err := service.CreateLocalSale(CreateLocalSaleRequest{
+			PaymentMethodID: pm.ID,
+			Items: []CreateLocalSaleItem{
+				{Barcode: "7791234567898", Quantity: 2}, // 2 * 800
+				{Barcode: "2000123015002"},              // label priced at 1500
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "3100.00", sale.Total)
+
+		stock, err := localStockStore.GetByProductID(weighed.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 9.0, stock.Quantity)
+	})
+
+	t.Run("barcode of another product", func(t *testing.T) {
+		_, err := service.CreateLocalSale(CreateLocalSaleRequest{
+			PaymentMethodID: pm.ID,
+			Items:           []CreateLocalSaleItem{{ProductID: weighed.ID, Barcode: "7791234567898", Quantity: 1}},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestLocalSaleService_SellByWeight(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	localSaleStore := store.NewPostgresLocalSaleStore(db)
+	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
+
+	cat := &store.Category{Name: "Category Weight"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+	pm := &store.PaymentMethod{Name: "Cash", Reference: "cash"}
+	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
+
+	byKg := &store.Product{CategoryID: cat.ID, Name: "Masas Finas", UnitPrice: 16000, SaleUnit: store.SaleUnitKg}
+	require.NoError(t, productStore.CreateProduct(byKg))
+	require.NoError(t, productStore.SetProductBarcodes(byKg.ID, []string{"124"}))
+	byGram := &store.Product{CategoryID: cat.ID, Name: "Grisines", UnitPrice: 6000, SaleUnit: store.SaleUnitGram}
+	require.NoError(t, productStore.CreateProduct(byGram))
+	perUnit := &store.Product{CategoryID: cat.ID, Name: "Medialuna", UnitPrice: 500}
+	require.NoError(t, productStore.CreateProduct(perUnit))
+
+	_, err := localStockStore.Create(byKg.ID, 5, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+	_, err = localStockStore.Create(byGram.ID, 2000, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+	_, err = localStockStore.Create(perUnit.ID, 10, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+
+	t.Run("decimal quantities priced per kg", func(t *testing.T) {
+		sale, err := service.CreateLocalSale(CreateLocalSaleRequest{
+			PaymentMethodID: pm.ID,
+			Items: []CreateLocalSaleItem{
+				{ProductID: byKg.ID, Quantity: 0.35},  // 0.35 * 16000
+				{ProductID: byGram.ID, Quantity: 250}, // 0.25 * 6000
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "7100.00", sale.Total)
+
+		stock, err := localStockStore.GetByProductID(byKg.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 4.65, stock.Quantity)
+
+		stock, err = localStockStore.GetByProductID(byGram.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1750.0, stock.Quantity)
+	})
+
+	t.Run("weighed label on a product sold by kg", func(t *testing.T) {
+		res, err := service.LookupCode("2500124005007") // 500 g
+		require.NoError(t, err)
+		assert.False(t, res.FixedPrice)
+		assert.Equal(t, 0.5, res.Quantity)
+		assert.Equal(t, 8000.0, res.Subtotal)
+	})
+
+	t.Run("fractional quantity of a unit product", func(t *testing.T) {
+		_, err := service.CreateLocalSale(CreateLocalSaleRequest{
+			PaymentMethodID: pm.ID,
+			Items:           []CreateLocalSaleItem{{ProductID: perUnit.ID, Quantity: 1.5}},
+		})
+		assert.ErrorIs(t, err, ErrFractionalQuantity)
+	})
+}
+
+func TestLocalSaleService_ConcurrentSales(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	localSaleStore := store.NewPostgresLocalSaleStore(db)
+	service := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
+
+	cat := &store.Category{Name: "Category Concurrency"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+	pm := &store.PaymentMethod{Name: "Cash", Reference: "cash"}
+	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
+
+	scarce := &store.Product{CategoryID: cat.ID, Name: "Torta Rogel", UnitPrice: 100}
+	require.NoError(t, productStore.CreateProduct(scarce))
+	other := &store.Product{CategoryID: cat.ID, Name: "Pan Dulce", UnitPrice: 100}
+	require.NoError(t, productStore.CreateProduct(other))
+	_, err := localStockStore.Create(scarce.ID, 10, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+	_, err = localStockStore.Create(other.ID, 100, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+
+	t.Run("never oversells", func(t *testing.T) {
+		const buyers = 30
+		var wg sync.WaitGroup
+		errs := make(chan error, buyers)
+		for i := 0; i < buyers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				// Alternate the line order so locks are requested in both orders.
+				items := []CreateLocalSaleItem{{ProductID: scarce.ID, Quantity: 1}, {ProductID: other.ID, Quantity: 1}}
+				if i%2 == 1 {
+					items[0], items[1] = items[1], items[0]
+				}
+				_, err := service.CreateLocalSale(CreateLocalSaleRequest{PaymentMethodID: pm.ID, Items: items})
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		var sold, rejected int
+		for err := range errs {
+			switch {
+			case err == nil:
+				sold++
+			case errors.Is(err, ErrInsufficientStock):
+				rejected++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}
+		assert.Equal(t, 10, sold)
+		assert.Equal(t, buyers-10, rejected)
+
+		stock, err := localStockStore.GetByProductID(scarce.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, stock.Quantity)
+
+		stock, err = localStockStore.GetByProductID(other.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 90.0, stock.Quantity)
+	})
+
+	t.Run("products allowed to go negative", func(t *testing.T) {
+		madeToOrder := &store.Product{CategoryID: cat.ID, Name: "Torta por Encargo", UnitPrice: 100, AllowNegativeStock: true}
+		require.NoError(t, productStore.CreateProduct(madeToOrder))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := service.CreateLocalSale(CreateLocalSaleRequest{
+					PaymentMethodID: pm.ID,
+					Items:           []CreateLocalSaleItem{{ProductID: madeToOrder.ID, Quantity: 1}},
+				})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		stock, err := localStockStore.GetByProductID(madeToOrder.ID, 0)
+		require.NoError(t, err)
+		require.NotNil(t, stock)
+		assert.Equal(t, -5.0, stock.Quantity)
+	})
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 )
@@ -13,29 +15,65 @@ var (
 	ErrProductNotFound        = errors.New("producto no encontrado")
 	ErrInsufficientStock      = errors.New("no hay stock suficiente")
 	ErrInitialQuantityInvalid = errors.New("cantidad inicial debe ser 0 o mayor")
+	ErrInvalidMovementReason  = errors.New("motivo de movimiento de stock inválido")
+	ErrLotNotAllowed          = errors.New("solo se puede cargar un lote al registrar producción")
+	ErrInvalidLotDates        = errors.New("la fecha de vencimiento no puede ser anterior a la de producción")
+	ErrLotNotFound            = errors.New("lote no encontrado")
 )
 
+// LocalStockService reads and adjusts stock. Methods taking a locationID
+// treat zero as the default location.
 type LocalStockService struct {
-	stockStore   store.LocalStockStore
-	productStore store.ProductStore
+	stockStore    store.LocalStockStore
+	productStore  store.ProductStore
+	movementStore store.StockMovementStore
+	lotStore      store.LotStore
+	locationStore store.StockLocationStore
 }
 
-func NewLocalStockService(stockStore store.LocalStockStore, productStore store.ProductStore) *LocalStockService {
+func NewLocalStockService(stockStore store.LocalStockStore, productStore store.ProductStore, movementStore store.StockMovementStore, lotStore store.LotStore, locationStore store.StockLocationStore) *LocalStockService {
 	return &LocalStockService{
-		stockStore:   stockStore,
-		productStore: productStore,
+		stockStore:    stockStore,
+		productStore:  productStore,
+		movementStore: movementStore,
+		lotStore:      lotStore,
+		locationStore: locationStore,
 	}
 }
 
-func (s *LocalStockService) GetStock(productID int64) (*store.LocalStock, error) {
-	return s.stockStore.GetByProductID(productID)
+func (s *LocalStockService) GetStock(productID, locationID int64) (*store.LocalStock, error) {
+	return s.stockStore.GetByProductID(productID, locationID)
 }
 
-func (s *LocalStockService) ListStock() ([]*store.ProductStock, error) {
-	return s.stockStore.ListStockWithProductDetails()
+// StockByLocation returns the stock of a product at every location that has
+// a record for it.
+func (s *LocalStockService) StockByLocation(productID int64) ([]*store.LocalStock, error) {
+	return s.stockStore.ListByProductID(productID)
 }
 
-func (s *LocalStockService) CreateInitialStock(productID int64, initialQuantity int) (*store.LocalStock, error) {
+func (s *LocalStockService) ListStock(locationID int64) ([]*store.ProductStock, error) {
+	if err := s.checkLocation(locationID); err != nil {
+		return nil, err
+	}
+	return s.stockStore.ListStockWithProductDetails(locationID)
+}
+
+// checkLocation verifies that a non-zero location exists.
+func (s *LocalStockService) checkLocation(locationID int64) error {
+	if locationID == 0 {
+		return nil
+	}
+	location, err := s.locationStore.GetByID(locationID)
+	if err != nil {
+		return fmt.Errorf("error checking location: %w", err)
+	}
+	if location == nil {
+		return ErrLocationNotFound
+	}
+	return nil
+}
+
+func (s *LocalStockService) CreateInitialStock(productID int64, initialQuantity float64, userID int64) (*store.LocalStock, error) {
 	if initialQuantity < 0 {
 		return nil, ErrInitialQuantityInvalid
 	}
@@ -47,8 +85,11 @@ func (s *LocalStockService) CreateInitialStock(productID int64, initialQuantity
 	if product == nil {
 		return nil, ErrProductNotFound
 	}
+	if err := ValidateQuantity(product, initialQuantity); err != nil {
+		return nil, err
+	}
 
-	existing, err := s.stockStore.GetByProductID(productID)
+	existing, err := s.stockStore.GetByProductID(productID, 0)
 	if err != nil {
 		return nil, fmt.Errorf("error checking existing stock: %w", err)
 	}
@@ -56,28 +97,165 @@ func (s *LocalStockService) CreateInitialStock(productID int64, initialQuantity
 		return nil, ErrStockRecordExists
 	}
 
-	return s.stockStore.Create(productID, initialQuantity)
+	return s.stockStore.Create(productID, initialQuantity, store.StockChange{Reason: store.MovementInitial, UserID: userID})
 }
 
-func (s *LocalStockService) AdjustStock(productID int64, delta int) (*store.LocalStock, error) {
-	stock, err := s.stockStore.GetByProductID(productID)
+// AdjustStock applies a change made by hand at change.LocationID: a
+// correction or freshly produced goods, optionally as a lot. Stock at a
+// location other than the default one starts with its first entry. Sales and
+// voids go through LocalSaleService, transfers through StockLocationService.
+func (s *LocalStockService) AdjustStock(productID int64, delta float64, change store.StockChange) (*store.LocalStock, error) {
+	if change.Reason == "" {
+		change.Reason = store.MovementAdjustment
+	}
+	if change.Reason != store.MovementAdjustment && change.Reason != store.MovementProduction {
+		return nil, ErrInvalidMovementReason
+	}
+	if change.Lot != nil {
+		if change.Reason != store.MovementProduction || delta <= 0 {
+			return nil, ErrLotNotAllowed
+		}
+		change.Lot.Number = strings.TrimSpace(change.Lot.Number)
+		if change.Lot.ProducedOn.IsZero() {
+			change.Lot.ProducedOn = time.Now()
+		}
+		if change.Lot.BestBefore != nil && change.Lot.BestBefore.Before(dateOnly(change.Lot.ProducedOn)) {
+			return nil, ErrInvalidLotDates
+		}
+	}
+
+	product, err := s.productStore.GetProductByID(productID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking product existence: %w", err)
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+	if err := ValidateQuantity(product, delta); err != nil {
+		return nil, err
+	}
+	if err := s.checkLocation(change.LocationID); err != nil {
+		return nil, err
+	}
+
+	stock, err := s.stockStore.GetByProductID(productID, change.LocationID)
 	if err != nil {
 		return nil, fmt.Errorf("error getting current stock: %w", err)
 	}
+	if stock == nil && change.LocationID != 0 && (delta >= 0 || product.AllowNegativeStock) {
+		created, err := s.stockStore.Create(productID, delta, change)
+		if errors.Is(err, store.ErrNegativeStock) {
+			return nil, ErrInsufficientStock
+		}
+		return created, err
+	}
 	if stock == nil {
 		return nil, ErrStockRecordNotFound
 	}
 
-	if stock.Quantity+delta < 0 {
+	if stock.Quantity+delta < 0 && !product.AllowNegativeStock {
+		return nil, ErrInsufficientStock
+	}
+
+	// The check above can race with a sale; the database has the last word.
+	updated, err := s.stockStore.AdjustQuantity(productID, delta, change)
+	if errors.Is(err, store.ErrNegativeStock) {
 		return nil, ErrInsufficientStock
 	}
+	return updated, err
+}
+
+// ListMovements returns the movements of a product at a location between
+// from and to, with the stock it had there at from as opening balance.
+func (s *LocalStockService) ListMovements(productID, locationID int64, from, to time.Time) ([]*store.StockMovement, float64, error) {
+	product, err := s.productStore.GetProductByID(productID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error checking product existence: %w", err)
+	}
+	if product == nil {
+		return nil, 0, ErrProductNotFound
+	}
+
+	if err := s.checkLocation(locationID); err != nil {
+		return nil, 0, err
+	}
 
-	return s.stockStore.AdjustQuantity(productID, delta)
+	opening, err := s.movementStore.BalanceAt(productID, locationID, from)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error getting opening balance: %w", err)
+	}
+	movements, err := s.movementStore.ListByProduct(productID, locationID, from, to)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing stock movements: %w", err)
+	}
+	return movements, RoundQuantity(opening), nil
+}
+
+// StockAt reconstructs the stock a product had at a location at the given
+// instant from the movement ledger.
+func (s *LocalStockService) StockAt(productID, locationID int64, at time.Time) (float64, error) {
+	product, err := s.productStore.GetProductByID(productID)
+	if err != nil {
+		return 0, fmt.Errorf("error checking product existence: %w", err)
+	}
+	if product == nil {
+		return 0, ErrProductNotFound
+	}
+
+	if err := s.checkLocation(locationID); err != nil {
+		return 0, err
+	}
+
+	balance, err := s.movementStore.BalanceAt(productID, locationID, at)
+	if err != nil {
+		return 0, err
+	}
+	return RoundQuantity(balance), nil
+}
+
+// ListLots returns a product's lots at every location, in the order sales
+// consume them.
+func (s *LocalStockService) ListLots(productID int64, includeEmpty bool) ([]*store.StockLot, error) {
+	product, err := s.productStore.GetProductByID(productID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking product existence: %w", err)
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+	return s.lotStore.ListByProduct(productID, includeEmpty)
+}
+
+// GetLot returns a lot with every movement that touched it, to trace where
+// its goods went.
+func (s *LocalStockService) GetLot(id int64) (*store.StockLot, []*store.LotMovement, error) {
+	lot, err := s.lotStore.GetByID(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting lot: %w", err)
+	}
+	if lot == nil {
+		return nil, nil, ErrLotNotFound
+	}
+	movements, err := s.lotStore.ListMovements(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error listing lot movements: %w", err)
+	}
+	return lot, movements, nil
+}
+
+// ExpiringLots returns the lots in stock that expire within days of today,
+// including those already past their date.
+func (s *LocalStockService) ExpiringLots(days int) ([]*store.StockLot, error) {
+	return s.lotStore.ListExpiring(dateOnly(time.Now()).AddDate(0, 0, days))
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
 }
 
 // HasSufficientStock is for future integration with sales flow.
-func (s *LocalStockService) HasSufficientStock(productID int64, quantityNeeded int) (bool, error) {
-	stock, err := s.stockStore.GetByProductID(productID)
+func (s *LocalStockService) HasSufficientStock(productID int64, quantityNeeded float64) (bool, error) {
+	stock, err := s.stockStore.GetByProductID(productID, 0)
 	if err != nil {
 		return false, err
 	}
@@ -87,6 +265,10 @@ func (s *LocalStockService) HasSufficientStock(productID int64, quantityNeeded i
 	return stock.Quantity >= quantityNeeded, nil
 }
 
-func (s *LocalStockService) GetLowStockAlerts(threshold int) ([]*store.ProductStock, error) {
-	return s.stockStore.GetLowStockAlerts(threshold)
+// DefaultLowStockThreshold is the alert level for products without a minimum
+// of their own.
+const DefaultLowStockThreshold = 10
+
+func (s *LocalStockService) GetLowStockAlerts(defaultThreshold float64) ([]*store.ProductStock, error) {
+	return s.stockStore.GetLowStockAlerts(defaultThreshold)
 }
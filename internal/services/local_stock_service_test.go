@@ -2,6 +2,7 @@ package services
 
 import (
 	"testing"
+	"time"
 
 	"github.com/RamunnoAJ/aesovoy-server/internal/store"
 	"github.com/stretchr/testify/assert"
@@ -16,7 +17,7 @@ func TestLocalStockService_CreateInitialStock(t *testing.T) {
 	productStore := store.NewPostgresProductStore(db)
 	categoryStore := store.NewPostgresCategoryStore(db)
 	localStockStore := store.NewPostgresLocalStockStore(db)
-	service := NewLocalStockService(localStockStore, productStore)
+	service := NewLocalStockService(localStockStore, productStore, store.NewPostgresStockMovementStore(db), store.NewPostgresLotStore(db), store.NewPostgresStockLocationStore(db))
 
 	// Setup a product that exists for all subtests
 	cat := &store.Category{Name: "Category For Create Test"}
@@ -25,13 +26,13 @@ func TestLocalStockService_CreateInitialStock(t *testing.T) {
 	require.NoError(t, productStore.CreateProduct(prod))
 
 	// Pre-create a stock record for the "already exists" case
-	_, err := localStockStore.Create(prod.ID, 50)
+	_, err := localStockStore.Create(prod.ID, 50, store.StockChange{Reason: store.MovementInitial})
 	require.NoError(t, err)
 
 	tests := []struct {
 		name            string
 		productID       int64
-		initialQuantity int
+		initialQuantity float64
 		wantErr         error
 	}{
 		{
@@ -56,7 +57,7 @@ func TestLocalStockService_CreateInitialStock(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			stock, err := service.CreateInitialStock(tt.productID, tt.initialQuantity)
+			stock, err := service.CreateInitialStock(tt.productID, tt.initialQuantity, 0)
 			assert.ErrorIs(t, err, tt.wantErr)
 			assert.Nil(t, stock)
 		})
@@ -67,10 +68,10 @@ func TestLocalStockService_CreateInitialStock(t *testing.T) {
 		prod2 := &store.Product{CategoryID: cat.ID, Name: "Product 2 For Create Test", UnitPrice: 1}
 		require.NoError(t, productStore.CreateProduct(prod2))
 
-		stock, err := service.CreateInitialStock(prod2.ID, 10)
+		stock, err := service.CreateInitialStock(prod2.ID, 10, 0)
 		require.NoError(t, err)
 		assert.NotNil(t, stock)
-		assert.Equal(t, 10, stock.Quantity)
+		assert.Equal(t, 10.0, stock.Quantity)
 	})
 }
 
@@ -81,19 +82,19 @@ func TestLocalStockService_AdjustStock(t *testing.T) {
 	productStore := store.NewPostgresProductStore(db)
 	categoryStore := store.NewPostgresCategoryStore(db)
 	localStockStore := store.NewPostgresLocalStockStore(db)
-	service := NewLocalStockService(localStockStore, productStore)
+	service := NewLocalStockService(localStockStore, productStore, store.NewPostgresStockMovementStore(db), store.NewPostgresLotStore(db), store.NewPostgresStockLocationStore(db))
 
 	cat := &store.Category{Name: "Category For Adjust Test"}
 	require.NoError(t, categoryStore.CreateCategory(cat))
 	prod := &store.Product{CategoryID: cat.ID, Name: "Product For Adjust Test", UnitPrice: 1}
 	require.NoError(t, productStore.CreateProduct(prod))
-	_, err := localStockStore.Create(prod.ID, 50)
+	_, err := localStockStore.Create(prod.ID, 50, store.StockChange{Reason: store.MovementInitial})
 	require.NoError(t, err)
 
 	tests := []struct {
 		name    string
-		delta   int
-		wantQty int
+		delta   float64
+		wantQty float64
 		wantErr error
 	}{
 		{
@@ -123,7 +124,7 @@ func TestLocalStockService_AdjustStock(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			stock, err := service.AdjustStock(prod.ID, tt.delta)
+			stock, err := service.AdjustStock(prod.ID, tt.delta, store.StockChange{})
 			if tt.wantErr != nil {
 				assert.ErrorIs(t, err, tt.wantErr)
 				assert.Nil(t, stock)
@@ -133,7 +134,7 @@ func TestLocalStockService_AdjustStock(t *testing.T) {
 				assert.Equal(t, tt.wantQty, stock.Quantity)
 
 				// Verify persistence
-				persisted, err := localStockStore.GetByProductID(prod.ID)
+				persisted, err := localStockStore.GetByProductID(prod.ID, 0)
 				require.NoError(t, err)
 				assert.Equal(t, tt.wantQty, persisted.Quantity)
 			}
@@ -141,11 +142,125 @@ func TestLocalStockService_AdjustStock(t *testing.T) {
 	}
 
 	t.Run("adjust non-existent stock record", func(t *testing.T) {
-		_, err := service.AdjustStock(9999, 10)
+		_, err := service.AdjustStock(9999, 10, store.StockChange{})
 		assert.ErrorIs(t, err, ErrStockRecordNotFound)
 	})
 }
 
+func TestLocalStockService_Lots(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	service := NewLocalStockService(localStockStore, productStore, store.NewPostgresStockMovementStore(db), store.NewPostgresLotStore(db), store.NewPostgresStockLocationStore(db))
+
+	cat := &store.Category{Name: "Category For Lot Test"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+	prod := &store.Product{CategoryID: cat.ID, Name: "Product For Lot Test", UnitPrice: 1}
+	require.NoError(t, productStore.CreateProduct(prod))
+	_, err := localStockStore.Create(prod.ID, 0, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+	tomorrow := time.Now().AddDate(0, 0, 1)
+
+	t.Run("lot only on production", func(t *testing.T) {
+		_, err := service.AdjustStock(prod.ID, 5, store.StockChange{Reason: store.MovementAdjustment, Lot: &store.NewLot{}})
+		assert.ErrorIs(t, err, ErrLotNotAllowed)
+	})
+
+	t.Run("best before earlier than production", func(t *testing.T) {
+		_, err := service.AdjustStock(prod.ID, 5, store.StockChange{Reason: store.MovementProduction, Lot: &store.NewLot{BestBefore: &yesterday}})
+		assert.ErrorIs(t, err, ErrInvalidLotDates)
+	})
+
+	t.Run("production opens a lot", func(t *testing.T) {
+		_, err := service.AdjustStock(prod.ID, 5, store.StockChange{Reason: store.MovementProduction, Lot: &store.NewLot{Number: " A1 ", BestBefore: &tomorrow}})
+		require.NoError(t, err)
+
+		lots, err := service.ListLots(prod.ID, false)
+		require.NoError(t, err)
+		require.Len(t, lots, 1)
+		assert.Equal(t, "A1", lots[0].LotNumber)
+
+		expiring, err := service.ExpiringLots(1)
+		require.NoError(t, err)
+		assert.Len(t, expiring, 1)
+
+		lot, movements, err := service.GetLot(lots[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, lot.Quantity)
+		assert.Len(t, movements, 1)
+	})
+
+	t.Run("unknown lot", func(t *testing.T) {
+		_, _, err := service.GetLot(9999)
+		assert.ErrorIs(t, err, ErrLotNotFound)
+	})
+}
+
+func TestLocalStockService_Movements(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	service := NewLocalStockService(localStockStore, productStore, store.NewPostgresStockMovementStore(db), store.NewPostgresLotStore(db), store.NewPostgresStockLocationStore(db))
+	saleService := NewLocalSaleService(db, store.NewPostgresLocalSaleStore(db), localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
+
+	cat := &store.Category{Name: "Category For Movements Test"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+	prod := &store.Product{CategoryID: cat.ID, Name: "Product For Movements Test", UnitPrice: 10}
+	require.NoError(t, productStore.CreateProduct(prod))
+	pm := &store.PaymentMethod{Name: "movements-tester", Reference: "cash"}
+	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
+
+	from := time.Now().Add(-time.Second)
+	_, err := service.CreateInitialStock(prod.ID, 20, 0)
+	require.NoError(t, err)
+	_, err = service.AdjustStock(prod.ID, 6, store.StockChange{Reason: store.MovementProduction})
+	require.NoError(t, err)
+	sale, err := saleService.CreateLocalSale(CreateLocalSaleRequest{
+		PaymentMethodID: pm.ID,
+		Items:           []CreateLocalSaleItem{{ProductID: prod.ID, Quantity: 4}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, saleService.RevokeLocalSale(sale.ID, 0))
+	assert.Error(t, saleService.RevokeLocalSale(sale.ID, 0))
+	to := time.Now().Add(time.Second)
+
+	_, err = service.AdjustStock(prod.ID, 1, store.StockChange{Reason: store.MovementSale})
+	assert.ErrorIs(t, err, ErrInvalidMovementReason)
+
+	movements, opening, err := service.ListMovements(prod.ID, 0, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, opening)
+	require.Len(t, movements, 4)
+
+	wantReasons := []string{store.MovementInitial, store.MovementProduction, store.MovementSale, store.MovementVoid}
+	wantBalances := []float64{20, 26, 22, 26}
+	for i, m := range movements {
+		assert.Equal(t, wantReasons[i], m.Reason)
+		assert.Equal(t, wantBalances[i], m.Balance)
+	}
+	for _, m := range movements[2:] {
+		require.NotNil(t, m.ReferenceID)
+		assert.Equal(t, sale.ID, *m.ReferenceID)
+		assert.Equal(t, store.ReferenceLocalSale, *m.ReferenceType)
+	}
+
+	stock, err := service.StockAt(prod.ID, 0, movements[3].CreatedAt)
+	require.NoError(t, err)
+	assert.Equal(t, 22.0, stock)
+
+	_, _, err = service.ListMovements(9999, 0, from, to)
+	assert.ErrorIs(t, err, ErrProductNotFound)
+}
+
 func TestLocalStockService_ListStock(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -153,7 +268,7 @@ func TestLocalStockService_ListStock(t *testing.T) {
 	productStore := store.NewPostgresProductStore(db)
 	categoryStore := store.NewPostgresCategoryStore(db)
 	localStockStore := store.NewPostgresLocalStockStore(db)
-	service := NewLocalStockService(localStockStore, productStore)
+	service := NewLocalStockService(localStockStore, productStore, store.NewPostgresStockMovementStore(db), store.NewPostgresLotStore(db), store.NewPostgresStockLocationStore(db))
 
 	cat := &store.Category{Name: "Category List Test"}
 	require.NoError(t, categoryStore.CreateCategory(cat))
@@ -164,11 +279,11 @@ func TestLocalStockService_ListStock(t *testing.T) {
 	require.NoError(t, productStore.CreateProduct(prodB))
 
 	// Create stock for A
-	_, err := localStockStore.Create(prodA.ID, 50)
+	_, err := localStockStore.Create(prodA.ID, 50, store.StockChange{Reason: store.MovementInitial})
 	require.NoError(t, err)
 
 	// Test ListStock
-	list, err := service.ListStock()
+	list, err := service.ListStock(0)
 	require.NoError(t, err)
 	require.Len(t, list, 2)
 
@@ -176,10 +291,10 @@ func TestLocalStockService_ListStock(t *testing.T) {
 	assert.Equal(t, prodA.ID, list[0].ProductID)
 	assert.Equal(t, "Product A", list[0].ProductName)
 	assert.Equal(t, 10.5, list[0].Price)
-	assert.Equal(t, 50, list[0].Quantity)
+	assert.Equal(t, 50.0, list[0].Quantity)
 
 	assert.Equal(t, prodB.ID, list[1].ProductID)
 	assert.Equal(t, "Product B", list[1].ProductName)
 	assert.Equal(t, 20.0, list[1].Price)
-	assert.Equal(t, 0, list[1].Quantity)
+	assert.Equal(t, 0.0, list[1].Quantity)
 }
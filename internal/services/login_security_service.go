@@ -0,0 +1,224 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrInvalidCredentials   = errors.New("credenciales incorrectas")
+	ErrSecurityUserNotFound = errors.New("usuario no encontrado")
+)
+
+const (
+	// LoginFreeAttempts is how many logins can fail in a row before each
+	// new attempt has to wait: 1 second, then twice as long every time.
+	LoginFreeAttempts = 3
+	// LoginLockoutThreshold failed logins in a row lock the account for
+	// LoginLockoutDuration.
+	LoginLockoutThreshold = 10
+	LoginLockoutDuration  = 15 * time.Minute
+)
+
+// LoginBlockedError is returned while an account can't try to log in, either
+// waiting after failed attempts or locked.
+type LoginBlockedError struct {
+	Locked bool
+	Until  time.Time
+	// NotifyErr is set when this attempt locked the account and the email
+	// telling the user failed.
+	NotifyErr error
+}
+
+func (e *LoginBlockedError) Error() string {
+	wait := time.Until(e.Until)
+	if e.Locked {
+		return fmt.Sprintf("La cuenta está bloqueada por demasiados intentos fallidos. Probá de nuevo en %d minutos o restablecé tu contraseña.", int(math.Ceil(wait.Minutes())))
+	}
+	return fmt.Sprintf("Demasiados intentos fallidos. Esperá %d segundos antes de volver a intentar.", int(math.Ceil(wait.Seconds())))
+}
+
+// LoginAttempt is where a login or password reset came from, for the
+// security events.
+type LoginAttempt struct {
+	// Username is the username or email that was given.
+	Username  string
+	IP        string
+	UserAgent string
+}
+
+// LoginSecurityService guards logins against guessing: after a few failures
+// in a row each attempt has to wait longer, and too many lock the account
+// for a while and email its user. It also keeps the security events.
+type LoginSecurityService struct {
+	store  store.SecurityStore
+	mailer Mailer
+}
+
+func NewLoginSecurityService(s store.SecurityStore, mailer Mailer) *LoginSecurityService {
+	return &LoginSecurityService{store: s, mailer: mailer}
+}
+
+// CheckPassword logs user in with password, returning ErrInvalidCredentials
+// or a *LoginBlockedError when it can't. user is nil when nobody has the
+// username.
+func (s *LoginSecurityService) CheckPassword(user *store.User, password string, a LoginAttempt) error {
+	if user == nil {
+		if err := s.Record(nil, store.SecurityLoginFailed, a, ""); err != nil {
+			return err
+		}
+		return ErrInvalidCredentials
+	}
+
+	if err := s.Check(user); err != nil {
+		return err
+	}
+
+	match, err := user.PasswordHash.Matches(password)
+	if err != nil {
+		return fmt.Errorf("error matching password: %w", err)
+	}
+	if !match {
+		if err := s.Failed(user, store.SecurityLoginFailed, a); err != nil {
+			return err
+		}
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// Check returns a *LoginBlockedError while the user can't try to log in.
+func (s *LoginSecurityService) Check(user *store.User) error {
+	st, err := s.store.GetLoginState(user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting login state: %w", err)
+	}
+	if st == nil {
+		return ErrSecurityUserNotFound
+	}
+
+	now := time.Now()
+	if st.LockedUntil != nil && st.LockedUntil.After(now) {
+		return &LoginBlockedError{Locked: true, Until: *st.LockedUntil}
+	}
+	if st.FailedLogins >= LoginFreeAttempts && st.LastFailedLoginAt != nil {
+		until := st.LastFailedLoginAt.Add(loginDelay(st.FailedLogins))
+		if until.After(now) {
+			return &LoginBlockedError{Until: until}
+		}
+	}
+	return nil
+}
+
+// loginDelay is how long to wait after failed logins in a row.
+func loginDelay(failed int) time.Duration {
+	if failed < LoginFreeAttempts {
+		return 0
+	}
+	return time.Second << (failed - LoginFreeAttempts)
+}
+
+// Failed counts a wrong password or two-factor code. When it locks the
+// account it emails the user and returns the *LoginBlockedError.
+func (s *LoginSecurityService) Failed(user *store.User, event string, a LoginAttempt) error {
+	st, locked, err := s.store.RecordLoginFailure(user.ID, LoginLockoutThreshold, LoginLockoutDuration)
+	if err != nil {
+		return fmt.Errorf("error recording failed login: %w", err)
+	}
+	if err := s.Record(&user.ID, event, a, ""); err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	details := fmt.Sprintf("%d intentos fallidos", LoginLockoutThreshold)
+	if err := s.Record(&user.ID, store.SecurityLockout, a, details); err != nil {
+		return err
+	}
+	blocked := &LoginBlockedError{Locked: true, Until: *st.LockedUntil}
+	if user.Email != "" {
+		data := map[string]any{
+			"Username": user.Username,
+			"Until":    st.LockedUntil.Local().Format("15:04"),
+			"IP":       a.IP,
+		}
+		if err := s.mailer.Send(user.Email, "account_locked.tmpl", data); err != nil {
+			blocked.NotifyErr = err
+		}
+	}
+	return blocked
+}
+
+// Succeeded clears the failed logins after a complete login.
+func (s *LoginSecurityService) Succeeded(user *store.User) error {
+	st, err := s.store.GetLoginState(user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting login state: %w", err)
+	}
+	if st == nil || (st.FailedLogins == 0 && st.LockedUntil == nil) {
+		return nil
+	}
+	if err := s.store.ResetLoginFailures(user.ID); err != nil {
+		return fmt.Errorf("error resetting failed logins: %w", err)
+	}
+	return nil
+}
+
+// Unlock lets a locked user try again right away, e.g. after an
+// administrator checked with them or a password reset. It unlocks their PIN
+// too.
+func (s *LoginSecurityService) Unlock(userID int64, a LoginAttempt, details string) error {
+	if err := s.store.ResetLoginFailures(userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSecurityUserNotFound
+		}
+		return fmt.Errorf("error unlocking user: %w", err)
+	}
+	if err := s.store.ResetPINFailures(userID); err != nil {
+		return fmt.Errorf("error unlocking user pin: %w", err)
+	}
+	return s.Record(&userID, store.SecurityUnlock, a, details)
+}
+
+// PasswordReset records a completed password reset. It also lifts any
+// lockout, since whoever reset the password controls the account's email.
+func (s *LoginSecurityService) PasswordReset(user *store.User, a LoginAttempt) error {
+	if err := s.store.ResetLoginFailures(user.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("error resetting failed logins: %w", err)
+	}
+	return s.Record(&user.ID, store.SecurityPasswordReset, a, "")
+}
+
+// Record adds a security event. userID is nil when the attempt names nobody.
+func (s *LoginSecurityService) Record(userID *int64, event string, a LoginAttempt, details string) error {
+	username := a.Username
+	if len(username) > 100 {
+		username = username[:100]
+	}
+	e := &store.SecurityEvent{
+		UserID:    userID,
+		Username:  username,
+		Event:     event,
+		IP:        a.IP,
+		UserAgent: a.UserAgent,
+		Details:   details,
+	}
+	if err := s.store.InsertEvent(e); err != nil {
+		return fmt.Errorf("error recording security event: %w", err)
+	}
+	return nil
+}
+
+func (s *LoginSecurityService) Events(f store.SecurityEventFilter) ([]*store.SecurityEvent, int, error) {
+	events, total, err := s.store.ListEvents(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing security events: %w", err)
+	}
+	return events, total, nil
+}
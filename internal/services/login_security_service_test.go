@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failed int
+		want   time.Duration
+	}{
+		{0, 0},
+		{LoginFreeAttempts - 1, 0},
+		{LoginFreeAttempts, time.Second},
+		{LoginFreeAttempts + 1, 2 * time.Second},
+		{LoginFreeAttempts + 4, 16 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, loginDelay(tt.failed), "failed=%d", tt.failed)
+	}
+}
+
+func TestLoginSecurityService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	userStore := store.NewPostgresUserStore(db)
+	securityStore := store.NewPostgresSecurityStore(db)
+	mailer := &fakeMailer{}
+	service := NewLoginSecurityService(securityStore, mailer)
+
+	user := &store.User{Username: "cajero", Email: "cajero@example.com", Role: "employee"}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, userStore.CreateUser(user))
+	attempt := LoginAttempt{Username: "cajero", IP: "10.0.0.1"}
+
+	assert.ErrorIs(t, service.CheckPassword(nil, "x", LoginAttempt{Username: "nadie"}), ErrInvalidCredentials)
+	assert.ErrorIs(t, service.CheckPassword(user, "wrong", attempt), ErrInvalidCredentials)
+	require.NoError(t, service.CheckPassword(user, "password", attempt))
+
+	// Past the free attempts the next one has to wait, even with the right
+	// password.
+	for i := 1; i < LoginFreeAttempts; i++ {
+		assert.ErrorIs(t, service.CheckPassword(user, "wrong", attempt), ErrInvalidCredentials)
+	}
+	var blocked *LoginBlockedError
+	require.ErrorAs(t, service.CheckPassword(user, "password", attempt), &blocked)
+	assert.False(t, blocked.Locked)
+
+	// Reaching the threshold locks the account and emails the user.
+	for i := LoginFreeAttempts; i < LoginLockoutThreshold-1; i++ {
+		require.NoError(t, service.Failed(user, store.SecurityTwoFactorFailed, attempt))
+	}
+	require.ErrorAs(t, service.Failed(user, store.SecurityLoginFailed, attempt), &blocked)
+	assert.True(t, blocked.Locked)
+	assert.NoError(t, blocked.NotifyErr)
+	assert.Equal(t, []string{"cajero@example.com"}, mailer.to)
+	require.ErrorAs(t, service.Check(user), &blocked)
+	assert.True(t, blocked.Locked)
+
+	_, total, err := service.Events(store.SecurityEventFilter{UserID: &user.ID, Event: store.SecurityLockout})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	require.NoError(t, service.Unlock(user.ID, attempt, "desbloqueado por admin"))
+	require.NoError(t, service.Check(user))
+	assert.ErrorIs(t, service.Unlock(999999, attempt, ""), ErrSecurityUserNotFound)
+
+	events, total, err := service.Events(store.SecurityEventFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, LoginLockoutThreshold+3, total)
+	assert.Equal(t, store.SecurityUnlock, events[0].Event)
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrPINFormat  = errors.New("el PIN debe tener entre 4 y 6 dígitos")
+	ErrPINNotSet  = errors.New("el usuario no tiene PIN")
+	ErrPINInvalid = errors.New("PIN incorrecto")
+)
+
+const (
+	// POSOperatorTTL is how long an employee stays the POS operator after
+	// entering their PIN.
+	POSOperatorTTL = 15 * time.Minute
+	// PINLockoutThreshold wrong PINs in a row lock the user's PIN for
+	// PINLockoutDuration.
+	PINLockoutThreshold = 5
+	PINLockoutDuration  = 15 * time.Minute
+)
+
+// PINLockedError is returned while a user's PIN is locked after too many
+// wrong ones.
+type PINLockedError struct {
+	Until time.Time
+}
+
+func (e *PINLockedError) Error() string {
+	return fmt.Sprintf("Demasiados PIN incorrectos. El PIN de este usuario se puede volver a usar en %d minutos.", int(math.Ceil(time.Until(e.Until).Minutes())))
+}
+
+// PINService handles the numeric PINs employees identify with on a shared
+// POS, without logging the device out. Wrong PINs are counted apart from
+// failed logins: too many lock only the user's PIN, so anyone at the counter
+// can't lock the account out of the system.
+type PINService struct {
+	store    store.SecurityStore
+	security *LoginSecurityService
+}
+
+func NewPINService(s store.SecurityStore, security *LoginSecurityService) *PINService {
+	return &PINService{store: s, security: security}
+}
+
+// ValidPIN reports whether pin is 4 to 6 digits.
+func ValidPIN(pin string) bool {
+	if len(pin) < 4 || len(pin) > 6 {
+		return false
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *PINService) Set(userID int64, pin string) error {
+	if !ValidPIN(pin) {
+		return ErrPINFormat
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("error hashing pin: %w", err)
+	}
+	return s.setHash(userID, hash)
+}
+
+func (s *PINService) Remove(userID int64) error {
+	return s.setHash(userID, nil)
+}
+
+func (s *PINService) setHash(userID int64, hash []byte) error {
+	if err := s.store.SetPIN(userID, hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSecurityUserNotFound
+		}
+		return fmt.Errorf("error setting pin: %w", err)
+	}
+	return nil
+}
+
+func (s *PINService) HasPIN(userID int64) (bool, error) {
+	hash, err := s.store.GetPINHash(userID)
+	if err != nil {
+		return false, fmt.Errorf("error getting pin: %w", err)
+	}
+	return hash != nil, nil
+}
+
+// Users returns the active users who can identify with a PIN.
+func (s *PINService) Users() ([]*store.User, error) {
+	users, err := s.store.ListPINUsers()
+	if err != nil {
+		return nil, fmt.Errorf("error listing pin users: %w", err)
+	}
+	return users, nil
+}
+
+// Check verifies user's PIN, returning ErrPINInvalid, ErrPINNotSet, a
+// *PINLockedError, or a *LoginBlockedError while the account is locked.
+func (s *PINService) Check(user *store.User, pin string, a LoginAttempt) error {
+	if err := s.security.Check(user); err != nil {
+		return err
+	}
+
+	st, err := s.store.GetPINState(user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting pin state: %w", err)
+	}
+	if st == nil {
+		return ErrSecurityUserNotFound
+	}
+	if st.LockedUntil != nil && st.LockedUntil.After(time.Now()) {
+		return &PINLockedError{Until: *st.LockedUntil}
+	}
+
+	hash, err := s.store.GetPINHash(user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting pin: %w", err)
+	}
+	if hash == nil {
+		return ErrPINNotSet
+	}
+
+	err = bcrypt.CompareHashAndPassword(hash, []byte(pin))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return s.failed(user, a)
+	}
+	if err != nil {
+		return fmt.Errorf("error matching pin: %w", err)
+	}
+
+	if st.FailedLogins > 0 || st.LockedUntil != nil {
+		if err := s.store.ResetPINFailures(user.ID); err != nil {
+			return fmt.Errorf("error resetting wrong pins: %w", err)
+		}
+	}
+	return nil
+}
+
+// failed counts a wrong PIN, returning ErrPINInvalid or, when it locks the
+// PIN, the *PINLockedError.
+func (s *PINService) failed(user *store.User, a LoginAttempt) error {
+	st, locked, err := s.store.RecordPINFailure(user.ID, PINLockoutThreshold, PINLockoutDuration)
+	if err != nil {
+		return fmt.Errorf("error recording wrong pin: %w", err)
+	}
+	if err := s.security.Record(&user.ID, store.SecurityPINFailed, a, ""); err != nil {
+		return err
+	}
+	if !locked {
+		return ErrPINInvalid
+	}
+
+	details := fmt.Sprintf("%d PIN incorrectos", PINLockoutThreshold)
+	if err := s.security.Record(&user.ID, store.SecurityPINLockout, a, details); err != nil {
+		return err
+	}
+	return &PINLockedError{Until: *st.LockedUntil}
+}
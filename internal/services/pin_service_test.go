@@ -0,0 +1,88 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidPIN(t *testing.T) {
+	tests := []struct {
+		pin  string
+		want bool
+	}{
+		{"1234", true},
+		{"123456", true},
+		{"123", false},
+		{"1234567", false},
+		{"12a4", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ValidPIN(tt.pin), "pin=%q", tt.pin)
+	}
+}
+
+func TestPINService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	userStore := store.NewPostgresUserStore(db)
+	securityStore := store.NewPostgresSecurityStore(db)
+	service := NewPINService(securityStore, NewLoginSecurityService(securityStore, &fakeMailer{}))
+
+	user := &store.User{Username: "cajero", Email: "cajero@example.com", Role: "employee"}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, userStore.CreateUser(user))
+	attempt := LoginAttempt{Username: "cajero", IP: "10.0.0.1"}
+
+	assert.ErrorIs(t, service.Check(user, "1234", attempt), ErrPINNotSet)
+	assert.ErrorIs(t, service.Set(user.ID, "12"), ErrPINFormat)
+	assert.ErrorIs(t, service.Set(999999, "1234"), ErrSecurityUserNotFound)
+
+	require.NoError(t, service.Set(user.ID, "1234"))
+	hasPIN, err := service.HasPIN(user.ID)
+	require.NoError(t, err)
+	assert.True(t, hasPIN)
+
+	require.NoError(t, service.Check(user, "1234", attempt))
+	assert.ErrorIs(t, service.Check(user, "4321", attempt), ErrPINInvalid)
+
+	// Wrong PINs are counted apart from failed logins.
+	st, err := securityStore.GetLoginState(user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, st.FailedLogins)
+	st, err = securityStore.GetPINState(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, st.FailedLogins)
+	events, _, err := securityStore.ListEvents(store.SecurityEventFilter{Event: store.SecurityPINFailed})
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	require.NoError(t, service.Check(user, "1234", attempt))
+	st, err = securityStore.GetPINState(user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, st.FailedLogins)
+
+	// Too many lock the PIN, not the account.
+	for i := 1; i < PINLockoutThreshold; i++ {
+		assert.ErrorIs(t, service.Check(user, "4321", attempt), ErrPINInvalid)
+	}
+	var locked *PINLockedError
+	require.ErrorAs(t, service.Check(user, "4321", attempt), &locked)
+	require.ErrorAs(t, service.Check(user, "1234", attempt), &locked)
+	security := NewLoginSecurityService(securityStore, &fakeMailer{})
+	require.NoError(t, security.CheckPassword(user, "password", attempt))
+	events, _, err = securityStore.ListEvents(store.SecurityEventFilter{Event: store.SecurityPINLockout})
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	require.NoError(t, security.Unlock(user.ID, attempt, ""))
+	require.NoError(t, service.Check(user, "1234", attempt))
+
+	require.NoError(t, service.Remove(user.ID))
+	hasPIN, err = service.HasPIN(user.ID)
+	require.NoError(t, err)
+	assert.False(t, hasPIN)
+}
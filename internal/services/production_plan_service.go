@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrNegativeStockLevel = errors.New("los niveles de stock no pueden ser negativos")
+	ErrTargetBelowMin     = errors.New("el stock objetivo no puede ser menor al mínimo")
+	ErrInvalidPlanWeeks   = errors.New("la cantidad de semanas debe estar entre 1 y 12")
+)
+
+const (
+	// DefaultPlanWeeks is how many past weeks sales are averaged over.
+	DefaultPlanWeeks = 4
+	maxPlanWeeks     = 12
+)
+
+// ValidateStockLevels checks a product's minimum and target stock. Either may
+// be nil (unset).
+func ValidateStockLevels(minStock, targetStock *float64) error {
+	if (minStock != nil && *minStock < 0) || (targetStock != nil && *targetStock < 0) {
+		return ErrNegativeStockLevel
+	}
+	if minStock != nil && targetStock != nil && *targetStock < *minStock {
+		return ErrTargetBelowMin
+	}
+	return nil
+}
+
+// ProductionSuggestion is how much of a product to make for a day and the
+// figures behind it. Quantities are in the product's sale unit.
+type ProductionSuggestion struct {
+	ProductID     int64    `json:"product_id"`
+	ProductName   string   `json:"product_name"`
+	SaleUnit      string   `json:"sale_unit"`
+	Stock         float64  `json:"stock"`
+	MinStock      *float64 `json:"min_stock"`
+	TargetStock   *float64 `json:"target_stock"`
+	PendingOrders float64  `json:"pending_orders"`
+	AverageSales  float64  `json:"average_sales"`
+	Projected     float64  `json:"projected"`
+	Suggested     float64  `json:"suggested"`
+}
+
+// ProductionPlan lists the products worth making on Date.
+type ProductionPlan struct {
+	Date  time.Time               `json:"date"`
+	Weeks int                     `json:"weeks"`
+	Items []*ProductionSuggestion `json:"items"`
+}
+
+// ProductionPlanService proposes production quantities from stock levels,
+// pending orders and past sales.
+type ProductionPlanService struct {
+	productStore store.ProductStore
+	stockStore   store.LocalStockStore
+	orderStore   store.OrderStore
+	saleStore    store.LocalSaleStore
+}
+
+func NewProductionPlanService(productStore store.ProductStore, stockStore store.LocalStockStore, orderStore store.OrderStore, saleStore store.LocalSaleStore) *ProductionPlanService {
+	return &ProductionPlanService{
+		productStore: productStore,
+		stockStore:   stockStore,
+		orderStore:   orderStore,
+		saleStore:    saleStore,
+	}
+}
+
+// Suggest proposes what to produce for day. Each product's stock at the
+// default location is projected by taking off its pending "todo" orders and
+// its average sales on the same weekday over the previous weeks. When the
+// projection falls below the minimum (the target if there is no minimum, or
+// zero if neither is set), the suggestion tops it up to the target, or to
+// the minimum when there is no target. Products sold per unit are rounded up
+// to whole units.
+func (s *ProductionPlanService) Suggest(day time.Time, weeks int) (*ProductionPlan, error) {
+	if weeks < 1 || weeks > maxPlanWeeks {
+		return nil, ErrInvalidPlanWeeks
+	}
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+
+	products, err := s.productStore.GetAllProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	stock := make(map[int64]float64)
+	stocks, err := s.stockStore.ListStockWithProductDetails(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range stocks {
+		stock[st.ProductID] = st.Quantity
+	}
+
+	pending := make(map[int64]float64)
+	requirements, err := s.orderStore.GetPendingProductionRequirements()
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range requirements {
+		pending[req.ProductID] = float64(req.Quantity)
+	}
+
+	sales := make(map[int64]float64)
+	for k := 1; k <= weeks; k++ {
+		start := day.AddDate(0, 0, -7*k)
+		sold, err := s.saleStore.QuantitiesSold(start, start.AddDate(0, 0, 1), 0)
+		if err != nil {
+			return nil, err
+		}
+		for productID, qty := range sold {
+			sales[productID] += qty
+		}
+	}
+
+	plan := &ProductionPlan{Date: day, Weeks: weeks, Items: []*ProductionSuggestion{}}
+	for _, p := range products {
+		avg := RoundQuantity(sales[p.ID] / float64(weeks))
+		projected := RoundQuantity(stock[p.ID] - pending[p.ID] - avg)
+
+		trigger, level := 0.0, 0.0
+		switch {
+		case p.MinStock != nil:
+			trigger = *p.MinStock
+		case p.TargetStock != nil:
+			trigger = *p.TargetStock
+		}
+		level = trigger
+		if p.TargetStock != nil {
+			level = *p.TargetStock
+		}
+		if projected >= trigger {
+			continue
+		}
+
+		suggested := RoundQuantity(level - projected)
+		if !IsSoldByWeight(p) {
+			suggested = math.Ceil(suggested)
+		}
+		if suggested <= 0 {
+			continue
+		}
+
+		plan.Items = append(plan.Items, &ProductionSuggestion{
+			ProductID:     p.ID,
+			ProductName:   p.Name,
+			SaleUnit:      p.SaleUnit,
+			Stock:         stock[p.ID],
+			MinStock:      p.MinStock,
+			TargetStock:   p.TargetStock,
+			PendingOrders: pending[p.ID],
+			AverageSales:  avg,
+			Projected:     projected,
+			Suggested:     suggested,
+		})
+	}
+	return plan, nil
+}
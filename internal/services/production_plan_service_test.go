@@ -0,0 +1,99 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateStockLevels(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+
+	assert.NoError(t, ValidateStockLevels(nil, nil))
+	assert.NoError(t, ValidateStockLevels(f(5), nil))
+	assert.NoError(t, ValidateStockLevels(nil, f(5)))
+	assert.NoError(t, ValidateStockLevels(f(5), f(5)))
+	assert.ErrorIs(t, ValidateStockLevels(f(-1), nil), ErrNegativeStockLevel)
+	assert.ErrorIs(t, ValidateStockLevels(nil, f(-1)), ErrNegativeStockLevel)
+	assert.ErrorIs(t, ValidateStockLevels(f(10), f(5)), ErrTargetBelowMin)
+}
+
+func TestProductionPlanService_Suggest(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	orderStore := store.NewPostgresOrderStore(db)
+	saleStore := store.NewPostgresLocalSaleStore(db)
+	service := NewProductionPlanService(productStore, localStockStore, orderStore, saleStore)
+
+	cat := &store.Category{Name: "Category For Plan Test"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+
+	minCake, targetCake, minBread := 5.0, 10.0, 500.0
+	cake := &store.Product{CategoryID: cat.ID, Name: "Torta", UnitPrice: 5000, MinStock: &minCake, TargetStock: &targetCake}
+	require.NoError(t, productStore.CreateProduct(cake))
+	bread := &store.Product{CategoryID: cat.ID, Name: "Pan", UnitPrice: 2000, SaleUnit: store.SaleUnitGram, MinStock: &minBread}
+	require.NoError(t, productStore.CreateProduct(bread))
+	cookie := &store.Product{CategoryID: cat.ID, Name: "Galletita", UnitPrice: 100}
+	require.NoError(t, productStore.CreateProduct(cookie))
+
+	_, err := localStockStore.Create(cake.ID, 8, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+	_, err = localStockStore.Create(bread.ID, 1000, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+
+	client := &store.Client{Name: "Cliente", Type: store.ClientTypeIndividual, Reference: "ref-plan", CUIT: "cuit-plan"}
+	require.NoError(t, store.NewPostgresClientStore(db).CreateClient(client))
+	require.NoError(t, orderStore.CreateOrder(&store.Order{ClientID: client.ID, State: store.OrderTodo}, []store.OrderItem{
+		{ProductID: cake.ID, Quantity: 2, Price: "4000"},
+		{ProductID: cookie.ID, Quantity: 3, Price: "80"},
+	}))
+
+	pm := &store.PaymentMethod{Name: "Efectivo", Reference: "cash"}
+	require.NoError(t, store.NewPostgresPaymentMethodStore(db).CreatePaymentMethod(pm))
+	sell := func(productID int64, qty float64, at time.Time) {
+		t.Helper()
+		sale := &store.LocalSale{PaymentMethodID: pm.ID, Subtotal: "1", Total: "1"}
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, saleStore.CreateInTx(tx, sale, []store.LocalSaleItem{{ProductID: productID, Quantity: qty, UnitPrice: "1", LineSubtotal: "1"}}))
+		require.NoError(t, tx.Commit())
+		_, err = db.Exec("UPDATE local_sales SET created_at = $1 WHERE id = $2", at, sale.ID)
+		require.NoError(t, err)
+	}
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	sell(cake.ID, 3, day.AddDate(0, 0, -7).Add(10*time.Hour))
+	sell(cake.ID, 4, day.AddDate(0, 0, -14).Add(10*time.Hour))
+	sell(cake.ID, 50, day.AddDate(0, 0, -8).Add(10*time.Hour)) // another weekday
+	sell(bread.ID, 300, day.AddDate(0, 0, -7).Add(10*time.Hour))
+
+	plan, err := service.Suggest(day, 2)
+	require.NoError(t, err)
+
+	byProduct := make(map[int64]*ProductionSuggestion)
+	for _, item := range plan.Items {
+		byProduct[item.ProductID] = item
+	}
+
+	require.Contains(t, byProduct, cake.ID)
+	assert.Equal(t, 2.0, byProduct[cake.ID].PendingOrders)
+	assert.Equal(t, 3.5, byProduct[cake.ID].AverageSales)
+	assert.Equal(t, 2.5, byProduct[cake.ID].Projected)
+	assert.Equal(t, 8.0, byProduct[cake.ID].Suggested, "tops up to the target, rounded up to whole units")
+
+	assert.NotContains(t, byProduct, bread.ID, "projection stays above the minimum")
+
+	require.Contains(t, byProduct, cookie.ID)
+	assert.Equal(t, 3.0, byProduct[cookie.ID].Suggested, "without levels only pending orders are covered")
+
+	_, err = service.Suggest(day, 0)
+	assert.ErrorIs(t, err, ErrInvalidPlanWeeks)
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/receipt"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrPurchaseOrderNotFound      = errors.New("orden de compra no encontrada")
+	ErrPurchaseOrderEmpty         = errors.New("la orden de compra debe tener al menos un ingrediente")
+	ErrDuplicatePurchaseItem      = errors.New("hay ingredientes repetidos en la orden de compra")
+	ErrInvalidPurchaseQuantity    = errors.New("las cantidades deben ser mayores a 0")
+	ErrInvalidPurchasePrice       = errors.New("los precios no pueden ser negativos")
+	ErrInvalidExpectedDate        = errors.New("fecha de entrega inválida")
+	ErrProviderNotFound           = errors.New("proveedor no encontrado")
+	ErrProviderWithoutEmail       = errors.New("el proveedor no tiene un email cargado")
+	ErrIngredientNotFound         = errors.New("ingrediente no encontrado")
+	ErrPurchaseOrderNotDraft      = errors.New("solo se pueden modificar órdenes en borrador")
+	ErrPurchaseOrderNotReceivable = errors.New("solo se puede recibir mercadería de órdenes enviadas")
+	ErrPurchaseOrderClosed        = errors.New("la orden de compra ya está cerrada")
+	ErrPurchaseReceiptEmpty       = errors.New("indicá al menos una cantidad recibida")
+	ErrPurchaseItemNotFound       = errors.New("el ítem no pertenece a la orden de compra")
+	ErrExpenseCategoryNotFound    = errors.New("categoría de gasto no encontrada")
+	ErrPurchaseOrderEmailFailed   = errors.New("no se pudo enviar el email al proveedor")
+)
+
+// PurchasesCategory is the expense category receptions are booked under when
+// none is given.
+const PurchasesCategory = "Compras"
+
+// Mailer sends templated emails; *mailer.Mailer satisfies it.
+type Mailer interface {
+	Send(to, templateFile string, data any) error
+}
+
+type PurchaseOrderItemRequest struct {
+	IngredientID  int64   `json:"ingredient_id"`
+	Quantity      float64 `json:"quantity"`
+	ExpectedPrice float64 `json:"expected_price"`
+}
+
+type PurchaseOrderRequest struct {
+	ProviderID   int64                      `json:"provider_id"`
+	ExpectedDate string                     `json:"expected_date,omitempty" example:"2026-05-20"`
+	Notes        string                     `json:"notes"`
+	Items        []PurchaseOrderItemRequest `json:"items"`
+	// UserID is who placed the order.
+	UserID int64 `json:"-"`
+}
+
+type ReceivePurchaseItem struct {
+	ItemID   int64   `json:"item_id"`
+	Quantity float64 `json:"quantity"`
+	// UnitPrice is the invoiced price; the expected one when omitted.
+	UnitPrice *float64 `json:"unit_price,omitempty"`
+}
+
+type ReceivePurchaseRequest struct {
+	Items []ReceivePurchaseItem `json:"items"`
+	// CategoryID is the expense category; "Compras" when zero.
+	CategoryID int64  `json:"category_id,omitempty"`
+	Notes      string `json:"notes"`
+	// UserID is who received the goods.
+	UserID int64 `json:"-"`
+}
+
+// PurchaseOrderService places orders with providers and receives the goods,
+// which go into ingredient stock and are booked as an expense.
+type PurchaseOrderService struct {
+	db              *sql.DB
+	orderStore      store.PurchaseOrderStore
+	providerStore   store.ProviderStore
+	ingredientStore store.IngredientStore
+	expenseStore    store.ExpenseStore
+	mailer          Mailer
+	business        receipt.Business
+}
+
+func NewPurchaseOrderService(
+	db *sql.DB,
+	orderStore store.PurchaseOrderStore,
+	providerStore store.ProviderStore,
+	ingredientStore store.IngredientStore,
+	expenseStore store.ExpenseStore,
+	mailer Mailer,
+	business receipt.Business,
+) *PurchaseOrderService {
+	return &PurchaseOrderService{
+		db:              db,
+		orderStore:      orderStore,
+		providerStore:   providerStore,
+		ingredientStore: ingredientStore,
+		expenseStore:    expenseStore,
+		mailer:          mailer,
+		business:        business,
+	}
+}
+
+// Business is who the orders are placed by, for printed orders.
+func (s *PurchaseOrderService) Business() receipt.Business {
+	return s.business
+}
+
+func (s *PurchaseOrderService) buildOrder(req PurchaseOrderRequest) (*store.PurchaseOrder, error) {
+	provider, err := s.providerStore.GetProviderByID(req.ProviderID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting provider: %w", err)
+	}
+	if provider == nil {
+		return nil, ErrProviderNotFound
+	}
+	if len(req.Items) == 0 {
+		return nil, ErrPurchaseOrderEmpty
+	}
+
+	po := &store.PurchaseOrder{
+		ProviderID:   provider.ID,
+		ProviderName: provider.Name,
+		Notes:        strings.TrimSpace(req.Notes),
+		State:        store.PurchaseOrderDraft,
+	}
+	if req.UserID != 0 {
+		po.UserID = &req.UserID
+	}
+	if d := strings.TrimSpace(req.ExpectedDate); d != "" {
+		date, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			return nil, ErrInvalidExpectedDate
+		}
+		po.ExpectedDate = &date
+	}
+
+	seen := make(map[int64]bool)
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, ErrInvalidPurchaseQuantity
+		}
+		if item.ExpectedPrice < 0 {
+			return nil, ErrInvalidPurchasePrice
+		}
+		if seen[item.IngredientID] {
+			return nil, ErrDuplicatePurchaseItem
+		}
+		seen[item.IngredientID] = true
+
+		ingredient, err := s.ingredientStore.GetIngredientByID(item.IngredientID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting ingredient: %w", err)
+		}
+		if ingredient == nil {
+			return nil, fmt.Errorf("%w: id %d", ErrIngredientNotFound, item.IngredientID)
+		}
+		po.Items = append(po.Items, &store.PurchaseOrderItem{
+			IngredientID:   ingredient.ID,
+			IngredientName: ingredient.Name,
+			Quantity:       RoundQuantity(item.Quantity),
+			ExpectedPrice:  RoundMoney(item.ExpectedPrice),
+		})
+	}
+	return po, nil
+}
+
+// Create saves a new order as a draft.
+func (s *PurchaseOrderService) Create(req PurchaseOrderRequest) (*store.PurchaseOrder, error) {
+	po, err := s.buildOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.orderStore.Create(po); err != nil {
+		return nil, fmt.Errorf("error creating purchase order: %w", err)
+	}
+	return s.Get(po.ID)
+}
+
+// Update replaces the provider, date, notes and items of a draft order.
+func (s *PurchaseOrderService) Update(id int64, req PurchaseOrderRequest) (*store.PurchaseOrder, error) {
+	current, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if current.State != store.PurchaseOrderDraft {
+		return nil, ErrPurchaseOrderNotDraft
+	}
+
+	po, err := s.buildOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	po.ID = id
+	if err := s.orderStore.UpdateDraft(po); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPurchaseOrderNotDraft
+		}
+		return nil, fmt.Errorf("error updating purchase order: %w", err)
+	}
+	return s.Get(id)
+}
+
+func (s *PurchaseOrderService) Get(id int64) (*store.PurchaseOrder, error) {
+	po, err := s.orderStore.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting purchase order: %w", err)
+	}
+	if po == nil {
+		return nil, ErrPurchaseOrderNotFound
+	}
+	return po, nil
+}
+
+func (s *PurchaseOrderService) List(f store.PurchaseOrderFilter) ([]*store.PurchaseOrder, error) {
+	return s.orderStore.List(f)
+}
+
+// MarkSent records that a draft was sent to the provider by other means
+// (phone, WhatsApp).
+func (s *PurchaseOrderService) MarkSent(id int64) (*store.PurchaseOrder, error) {
+	po, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if po.State != store.PurchaseOrderDraft {
+		return nil, ErrPurchaseOrderNotDraft
+	}
+	if err := s.orderStore.SetState(id, store.PurchaseOrderSent); err != nil {
+		return nil, fmt.Errorf("error updating purchase order: %w", err)
+	}
+	return s.Get(id)
+}
+
+// Send emails the order to the provider and marks a draft as sent. Orders
+// already sent can be emailed again.
+func (s *PurchaseOrderService) Send(id int64) (*store.PurchaseOrder, error) {
+	po, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if po.State != store.PurchaseOrderDraft && po.State != store.PurchaseOrderSent {
+		return nil, ErrPurchaseOrderClosed
+	}
+	if strings.TrimSpace(po.ProviderEmail) == "" {
+		return nil, ErrProviderWithoutEmail
+	}
+
+	data := map[string]any{"Order": po, "Business": s.business}
+	if err := s.mailer.Send(po.ProviderEmail, "purchase_order.tmpl", data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPurchaseOrderEmailFailed, err)
+	}
+
+	if po.State == store.PurchaseOrderDraft {
+		if err := s.orderStore.SetState(id, store.PurchaseOrderSent); err != nil {
+			return nil, fmt.Errorf("error updating purchase order: %w", err)
+		}
+	}
+	return s.Get(id)
+}
+
+// Cancel closes an order that has not been fully received. Goods already
+// received stay in stock.
+func (s *PurchaseOrderService) Cancel(id int64) (*store.PurchaseOrder, error) {
+	po, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if po.State == store.PurchaseOrderReceived || po.State == store.PurchaseOrderCancelled {
+		return nil, ErrPurchaseOrderClosed
+	}
+	if err := s.orderStore.SetState(id, store.PurchaseOrderCancelled); err != nil {
+		return nil, fmt.Errorf("error updating purchase order: %w", err)
+	}
+	return s.Get(id)
+}
+
+func (s *PurchaseOrderService) expenseCategory(id int64) (*store.ExpenseCategory, error) {
+	if id != 0 {
+		category, err := s.expenseStore.GetExpenseCategoryByID(id)
+		if err != nil {
+			return nil, fmt.Errorf("error getting expense category: %w", err)
+		}
+		if category == nil {
+			return nil, ErrExpenseCategoryNotFound
+		}
+		return category, nil
+	}
+
+	category, err := s.expenseStore.GetExpenseCategoryByName(PurchasesCategory)
+	if err != nil {
+		return nil, fmt.Errorf("error getting expense category: %w", err)
+	}
+	if category == nil {
+		category = &store.ExpenseCategory{Name: PurchasesCategory}
+		if err := s.expenseStore.CreateExpenseCategory(category); err != nil {
+			return nil, fmt.Errorf("error creating expense category: %w", err)
+		}
+	}
+	return category, nil
+}
+
+// Receive records a delivery against a sent order. In one transaction the
+// received quantities are added to ingredient stock, an expense for the
+// provider is created at the invoiced prices, and the order moves to
+// partially received or received. Lines with a zero quantity are ignored.
+func (s *PurchaseOrderService) Receive(id int64, req ReceivePurchaseRequest) (*store.PurchaseOrder, error) {
+	var lines []ReceivePurchaseItem
+	for _, item := range req.Items {
+		if item.Quantity < 0 {
+			return nil, ErrInvalidPurchaseQuantity
+		}
+		if item.UnitPrice != nil && *item.UnitPrice < 0 {
+			return nil, ErrInvalidPurchasePrice
+		}
+		if item.Quantity > 0 {
+			lines = append(lines, item)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, ErrPurchaseReceiptEmpty
+	}
+
+	category, err := s.expenseCategory(req.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	po, err := s.orderStore.LockTx(tx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error locking purchase order: %w", err)
+	}
+	if po == nil {
+		return nil, ErrPurchaseOrderNotFound
+	}
+	if po.State != store.PurchaseOrderSent && po.State != store.PurchaseOrderPartiallyReceived {
+		return nil, ErrPurchaseOrderNotReceivable
+	}
+
+	items := make(map[int64]*store.PurchaseOrderItem, len(po.Items))
+	for _, item := range po.Items {
+		items[item.ID] = item
+	}
+
+	rcpt := &store.PurchaseReceipt{
+		PurchaseOrderID: id,
+		Notes:           strings.TrimSpace(req.Notes),
+	}
+	if req.UserID != 0 {
+		rcpt.UserID = &req.UserID
+	}
+	for _, line := range lines {
+		item, ok := items[line.ItemID]
+		if !ok {
+			return nil, ErrPurchaseItemNotFound
+		}
+		price := item.ExpectedPrice
+		if line.UnitPrice != nil {
+			price = RoundMoney(*line.UnitPrice)
+		}
+		qty := RoundQuantity(line.Quantity)
+		rcpt.Items = append(rcpt.Items, &store.PurchaseReceiptItem{
+			ItemID:         item.ID,
+			IngredientID:   item.IngredientID,
+			IngredientName: item.IngredientName,
+			Quantity:       qty,
+			UnitPrice:      price,
+		})
+		rcpt.Amount += qty * price
+		item.ReceivedQuantity = RoundQuantity(item.ReceivedQuantity + qty)
+
+		if err := s.ingredientStore.AdjustStockTx(tx, item.IngredientID, qty); err != nil {
+			return nil, fmt.Errorf("error updating ingredient stock: %w", err)
+		}
+	}
+	rcpt.Amount = RoundMoney(rcpt.Amount)
+
+	if rcpt.Amount > 0 {
+		providerID := po.ProviderID
+		expense := &store.Expense{
+			Amount:     fmt.Sprintf("%.2f", rcpt.Amount),
+			ProviderID: &providerID,
+			CategoryID: category.ID,
+			Type:       store.ExpenseTypeProduction,
+			Date:       time.Now(),
+		}
+		if err := s.expenseStore.CreateExpenseTx(tx, expense); err != nil {
+			return nil, fmt.Errorf("error creating expense: %w", err)
+		}
+		rcpt.ExpenseID = &expense.ID
+	}
+
+	if err := s.orderStore.CreateReceiptTx(tx, rcpt); err != nil {
+		return nil, fmt.Errorf("error recording receipt: %w", err)
+	}
+
+	state := store.PurchaseOrderReceived
+	for _, item := range po.Items {
+		if item.ReceivedQuantity < item.Quantity {
+			state = store.PurchaseOrderPartiallyReceived
+			break
+		}
+	}
+	if err := s.orderStore.SetStateTx(tx, id, state); err != nil {
+		return nil, fmt.Errorf("error updating purchase order: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing receipt: %w", err)
+	}
+	return s.Get(id)
+}
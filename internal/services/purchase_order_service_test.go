@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/receipt"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMailer struct {
+	to   []string
+	data []any
+	err  error
+}
+
+func (m *fakeMailer) Send(to, templateFile string, data any) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.to = append(m.to, to)
+	m.data = append(m.data, data)
+	return nil
+}
+
+func TestPurchaseOrderService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	providerStore := store.NewPostgresProviderStore(db)
+	ingredientStore := store.NewPostgresIngredientStore(db)
+	expenseStore := store.NewPostgresExpenseStore(db)
+	mailer := &fakeMailer{}
+	service := NewPurchaseOrderService(db, store.NewPostgresPurchaseOrderStore(db), providerStore, ingredientStore,
+		expenseStore, mailer, receipt.Business{Name: "Panadería"})
+
+	provider := &store.Provider{Name: "Molino", Reference: "ref-po", CUIT: "cuit-po", Email: "ventas@molino.test"}
+	require.NoError(t, providerStore.CreateProvider(provider))
+	flour := &store.Ingredient{Name: "Harina", Cost: 900}
+	require.NoError(t, ingredientStore.CreateIngredient(flour))
+	butter := &store.Ingredient{Name: "Manteca", Cost: 8000}
+	require.NoError(t, ingredientStore.CreateIngredient(butter))
+
+	stockOf := func(id int64) float64 {
+		t.Helper()
+		ingredient, err := ingredientStore.GetIngredientByID(id)
+		require.NoError(t, err)
+		return ingredient.Stock
+	}
+
+	t.Run("validates the order", func(t *testing.T) {
+		_, err := service.Create(PurchaseOrderRequest{ProviderID: provider.ID})
+		assert.ErrorIs(t, err, ErrPurchaseOrderEmpty)
+
+		_, err = service.Create(PurchaseOrderRequest{ProviderID: provider.ID, Items: []PurchaseOrderItemRequest{
+			{IngredientID: flour.ID, Quantity: 0, ExpectedPrice: 900},
+		}})
+		assert.ErrorIs(t, err, ErrInvalidPurchaseQuantity)
+
+		_, err = service.Create(PurchaseOrderRequest{ProviderID: provider.ID, Items: []PurchaseOrderItemRequest{
+			{IngredientID: flour.ID, Quantity: 1, ExpectedPrice: 900},
+			{IngredientID: flour.ID, Quantity: 2, ExpectedPrice: 900},
+		}})
+		assert.ErrorIs(t, err, ErrDuplicatePurchaseItem)
+
+		_, err = service.Create(PurchaseOrderRequest{ProviderID: 9999, Items: []PurchaseOrderItemRequest{
+			{IngredientID: flour.ID, Quantity: 1, ExpectedPrice: 900},
+		}})
+		assert.ErrorIs(t, err, ErrProviderNotFound)
+
+		_, err = service.Create(PurchaseOrderRequest{ProviderID: provider.ID, ExpectedDate: "mañana", Items: []PurchaseOrderItemRequest{
+			{IngredientID: flour.ID, Quantity: 1, ExpectedPrice: 900},
+		}})
+		assert.ErrorIs(t, err, ErrInvalidExpectedDate)
+	})
+
+	t.Run("receives in parts into stock and expenses", func(t *testing.T) {
+		po, err := service.Create(PurchaseOrderRequest{
+			ProviderID:   provider.ID,
+			ExpectedDate: "2026-05-20",
+			Items: []PurchaseOrderItemRequest{
+				{IngredientID: flour.ID, Quantity: 25, ExpectedPrice: 900},
+				{IngredientID: butter.ID, Quantity: 2, ExpectedPrice: 8000},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, store.PurchaseOrderDraft, po.State)
+		assert.Equal(t, 38500.0, po.Total)
+
+		_, err = service.Receive(po.ID, ReceivePurchaseRequest{Items: []ReceivePurchaseItem{{ItemID: po.Items[0].ID, Quantity: 1}}})
+		assert.ErrorIs(t, err, ErrPurchaseOrderNotReceivable, "drafts cannot be received")
+
+		po, err = service.Update(po.ID, PurchaseOrderRequest{
+			ProviderID: provider.ID,
+			Items: []PurchaseOrderItemRequest{
+				{IngredientID: flour.ID, Quantity: 50, ExpectedPrice: 900},
+				{IngredientID: butter.ID, Quantity: 2, ExpectedPrice: 8000},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, po.ExpectedDate)
+
+		po, err = service.Send(po.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.PurchaseOrderSent, po.State)
+		assert.NotNil(t, po.SentAt)
+		assert.Equal(t, []string{"ventas@molino.test"}, mailer.to)
+
+		_, err = service.Update(po.ID, PurchaseOrderRequest{ProviderID: provider.ID, Items: []PurchaseOrderItemRequest{
+			{IngredientID: flour.ID, Quantity: 1, ExpectedPrice: 900},
+		}})
+		assert.ErrorIs(t, err, ErrPurchaseOrderNotDraft)
+
+		var flourItem, butterItem *store.PurchaseOrderItem
+		for _, item := range po.Items {
+			switch item.IngredientID {
+			case flour.ID:
+				flourItem = item
+			case butter.ID:
+				butterItem = item
+			}
+		}
+		require.NotNil(t, flourItem)
+		require.NotNil(t, butterItem)
+
+		invoiced := 950.0
+		po, err = service.Receive(po.ID, ReceivePurchaseRequest{
+			Items: []ReceivePurchaseItem{
+				{ItemID: flourItem.ID, Quantity: 25, UnitPrice: &invoiced},
+				{ItemID: butterItem.ID, Quantity: 0},
+			},
+			Notes: "Remito 0001-123",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, store.PurchaseOrderPartiallyReceived, po.State)
+		assert.Equal(t, 25.0, stockOf(flour.ID))
+		assert.Equal(t, 0.0, stockOf(butter.ID))
+		require.Len(t, po.Receipts, 1)
+		assert.Equal(t, 23750.0, po.Receipts[0].Amount)
+		require.NotNil(t, po.Receipts[0].ExpenseID)
+
+		expense, err := expenseStore.GetExpenseByID(*po.Receipts[0].ExpenseID)
+		require.NoError(t, err)
+		require.NotNil(t, expense)
+		assert.Equal(t, "23750.00", expense.Amount)
+		assert.Equal(t, store.ExpenseTypeProduction, expense.Type)
+		assert.Equal(t, PurchasesCategory, expense.CategoryName)
+		require.NotNil(t, expense.ProviderID)
+		assert.Equal(t, provider.ID, *expense.ProviderID)
+
+		po, err = service.Receive(po.ID, ReceivePurchaseRequest{Items: []ReceivePurchaseItem{
+			{ItemID: flourItem.ID, Quantity: 25},
+			{ItemID: butterItem.ID, Quantity: 2},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, store.PurchaseOrderReceived, po.State)
+		assert.Equal(t, 50.0, stockOf(flour.ID))
+		assert.Equal(t, 2.0, stockOf(butter.ID))
+		require.Len(t, po.Receipts, 2)
+
+		_, err = service.Receive(po.ID, ReceivePurchaseRequest{Items: []ReceivePurchaseItem{{ItemID: flourItem.ID, Quantity: 1}}})
+		assert.ErrorIs(t, err, ErrPurchaseOrderNotReceivable)
+		_, err = service.Cancel(po.ID)
+		assert.ErrorIs(t, err, ErrPurchaseOrderClosed)
+	})
+
+	t.Run("rejects items from another order and empty receipts", func(t *testing.T) {
+		po, err := service.Create(PurchaseOrderRequest{ProviderID: provider.ID, Items: []PurchaseOrderItemRequest{
+			{IngredientID: flour.ID, Quantity: 5, ExpectedPrice: 900},
+		}})
+		require.NoError(t, err)
+		po, err = service.MarkSent(po.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.PurchaseOrderSent, po.State)
+
+		_, err = service.Receive(po.ID, ReceivePurchaseRequest{Items: []ReceivePurchaseItem{{ItemID: po.Items[0].ID, Quantity: 0}}})
+		assert.ErrorIs(t, err, ErrPurchaseReceiptEmpty)
+		_, err = service.Receive(po.ID, ReceivePurchaseRequest{Items: []ReceivePurchaseItem{{ItemID: 9999, Quantity: 1}}})
+		assert.ErrorIs(t, err, ErrPurchaseItemNotFound)
+
+		po, err = service.Cancel(po.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.PurchaseOrderCancelled, po.State)
+	})
+
+	t.Run("send needs a provider email and a working mailer", func(t *testing.T) {
+		silent := &store.Provider{Name: "Granja", Reference: "ref-po-2", CUIT: "cuit-po-2"}
+		require.NoError(t, providerStore.CreateProvider(silent))
+		po, err := service.Create(PurchaseOrderRequest{ProviderID: silent.ID, Items: []PurchaseOrderItemRequest{
+			{IngredientID: butter.ID, Quantity: 1, ExpectedPrice: 8000},
+		}})
+		require.NoError(t, err)
+		_, err = service.Send(po.ID)
+		assert.ErrorIs(t, err, ErrProviderWithoutEmail)
+
+		po, err = service.Create(PurchaseOrderRequest{ProviderID: provider.ID, Items: []PurchaseOrderItemRequest{
+			{IngredientID: butter.ID, Quantity: 1, ExpectedPrice: 8000},
+		}})
+		require.NoError(t, err)
+		mailer.err = errors.New("smtp down")
+		defer func() { mailer.err = nil }()
+		_, err = service.Send(po.ID)
+		assert.ErrorIs(t, err, ErrPurchaseOrderEmailFailed)
+
+		po, err = service.Get(po.ID)
+		require.NoError(t, err)
+		assert.Equal(t, store.PurchaseOrderDraft, po.State, "stays a draft when the email fails")
+	})
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/receipt"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrSaleNotFound         = errors.New("venta no encontrada")
+	ErrPrinterNotConfigured = errors.New("no hay una impresora de tickets configurada")
+)
+
+// ReceiptService builds customer tickets for local sales and sends them to
+// the configured thermal printer.
+type ReceiptService struct {
+	saleStore          store.LocalSaleStore
+	paymentMethodStore store.PaymentMethodStore
+	productStore       store.ProductStore
+	business           receipt.Business
+	printer            receipt.Printer
+	columns            int
+}
+
+// NewReceiptService creates the service. printer may be nil when no thermal
+// printer is set up; tickets can then only be rendered as HTML.
+func NewReceiptService(
+	saleStore store.LocalSaleStore,
+	paymentMethodStore store.PaymentMethodStore,
+	productStore store.ProductStore,
+	business receipt.Business,
+	printer receipt.Printer,
+	columns int,
+) *ReceiptService {
+	return &ReceiptService{
+		saleStore:          saleStore,
+		paymentMethodStore: paymentMethodStore,
+		productStore:       productStore,
+		business:           business,
+		printer:            printer,
+		columns:            columns,
+	}
+}
+
+// HasPrinter reports whether tickets can be sent to a thermal printer.
+func (s *ReceiptService) HasPrinter() bool {
+	return s.printer != nil
+}
+
+func (s *ReceiptService) BuildReceipt(saleID int64) (*receipt.Receipt, error) {
+	sale, err := s.saleStore.GetByID(saleID)
+	if err != nil {
+		return nil, fmt.Errorf("error al obtener la venta: %w", err)
+	}
+	if sale == nil {
+		return nil, ErrSaleNotFound
+	}
+
+	rc := &receipt.Receipt{
+		Business: s.business,
+		Number:   sale.ID,
+		Date:     sale.CreatedAt,
+		Voided:   sale.DeletedAt != nil,
+	}
+	rc.Total, _ = strconv.ParseFloat(sale.Total, 64)
+
+	pm, err := s.paymentMethodStore.GetPaymentMethodByID(sale.PaymentMethodID)
+	if err != nil {
+		return nil, fmt.Errorf("error al obtener el método de pago: %w", err)
+	}
+	if pm != nil {
+		rc.PaymentMethod = pm.Name
+	}
+
+	productIDs := make([]int64, 0, len(sale.Items))
+	for _, item := range sale.Items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	products, err := s.productStore.GetProductsByIDs(productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error al obtener los productos: %w", err)
+	}
+
+	for _, item := range sale.Items {
+		line := receipt.Line{
+			Name:     fmt.Sprintf("Producto #%d", item.ProductID),
+			Quantity: item.Quantity,
+			Unit:     store.SaleUnitUnit,
+		}
+		if p, ok := products[item.ProductID]; ok {
+			line.Name = p.Name
+			line.Unit = p.SaleUnit
+		}
+		line.UnitPrice, _ = strconv.ParseFloat(item.UnitPrice, 64)
+		line.Subtotal, _ = strconv.ParseFloat(item.LineSubtotal, 64)
+		rc.Items = append(rc.Items, line)
+	}
+
+	return rc, nil
+}
+
+// ESCPOS returns the ticket of a sale encoded for a thermal printer.
+func (s *ReceiptService) ESCPOS(saleID int64) ([]byte, error) {
+	rc, err := s.BuildReceipt(saleID)
+	if err != nil {
+		return nil, err
+	}
+	return receipt.ESCPOS(rc, s.columns)
+}
+
+// PrintReceipt sends the ticket of a sale to the thermal printer.
+func (s *ReceiptService) PrintReceipt(ctx context.Context, saleID int64) error {
+	if s.printer == nil {
+		return ErrPrinterNotConfigured
+	}
+	data, err := s.ESCPOS(saleID)
+	if err != nil {
+		return err
+	}
+	return s.printer.Print(ctx, data)
+}
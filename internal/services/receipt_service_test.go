@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/receipt"
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiptService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	paymentMethodStore := store.NewPostgresPaymentMethodStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	localSaleStore := store.NewPostgresLocalSaleStore(db)
+	saleService := NewLocalSaleService(db, localSaleStore, localStockStore, paymentMethodStore, productStore, store.NewPostgresShiftStore(db))
+
+	cat := &store.Category{Name: "Category Receipt"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+	pm := &store.PaymentMethod{Name: "Efectivo", Reference: "cash"}
+	require.NoError(t, paymentMethodStore.CreatePaymentMethod(pm))
+	p := &store.Product{CategoryID: cat.ID, Name: "Masas Finas", UnitPrice: 16000, SaleUnit: store.SaleUnitKg}
+	require.NoError(t, productStore.CreateProduct(p))
+	_, err := localStockStore.Create(p.ID, 5, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+
+	sale, err := saleService.CreateLocalSale(CreateLocalSaleRequest{
+		PaymentMethodID: pm.ID,
+		Items:           []CreateLocalSaleItem{{ProductID: p.ID, Quantity: 0.5}},
+	})
+	require.NoError(t, err)
+
+	business := receipt.Business{Name: "Aesovoy", Footer: "Gracias"}
+
+	t.Run("build receipt", func(t *testing.T) {
+		service := NewReceiptService(localSaleStore, paymentMethodStore, productStore, business, nil, 0)
+		rc, err := service.BuildReceipt(sale.ID)
+		require.NoError(t, err)
+
+		assert.Equal(t, sale.ID, rc.Number)
+		assert.Equal(t, "Efectivo", rc.PaymentMethod)
+		assert.Equal(t, 8000.0, rc.Total)
+		require.Len(t, rc.Items, 1)
+		assert.Equal(t, "Masas Finas", rc.Items[0].Name)
+		assert.Equal(t, "0.5 kg", rc.Items[0].QuantityText())
+	})
+
+	t.Run("unknown sale", func(t *testing.T) {
+		service := NewReceiptService(localSaleStore, paymentMethodStore, productStore, business, nil, 0)
+		_, err := service.BuildReceipt(999999)
+		assert.ErrorIs(t, err, ErrSaleNotFound)
+	})
+
+	t.Run("print without printer", func(t *testing.T) {
+		service := NewReceiptService(localSaleStore, paymentMethodStore, productStore, business, nil, 0)
+		err := service.PrintReceipt(context.Background(), sale.ID)
+		assert.ErrorIs(t, err, ErrPrinterNotConfigured)
+	})
+
+	t.Run("print to network printer", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+
+		received := make(chan []byte, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			data, _ := io.ReadAll(conn)
+			received <- data
+		}()
+
+		printer := receipt.NewNetworkPrinter(ln.Addr().String())
+		service := NewReceiptService(localSaleStore, paymentMethodStore, productStore, business, printer, 32)
+		require.NoError(t, service.PrintReceipt(context.Background(), sale.ID))
+
+		expected, err := service.ESCPOS(sale.ID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, <-received)
+	})
+}
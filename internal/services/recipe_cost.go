@@ -0,0 +1,24 @@
+package services
+
+import "github.com/RamunnoAJ/aesovoy-server/internal/store"
+
+// RecipeCost is what the ingredients of one sale unit of the product cost
+// (per kg for products sold by weight). Ingredient costs are per kg, liter or
+// unit, so recipe quantities in g and ml are scaled down. ok is false when the
+// product has no recipe or an ingredient has no cost yet.
+func RecipeCost(p *store.Product) (cost float64, ok bool) {
+	if len(p.Recipe) == 0 {
+		return 0, false
+	}
+	for _, pi := range p.Recipe {
+		if pi.Cost <= 0 {
+			return 0, false
+		}
+		qty := pi.Quantity
+		if pi.Unit == "g" || pi.Unit == "ml" {
+			qty = qty / 1000
+		}
+		cost += qty * pi.Cost
+	}
+	return cost, true
+}
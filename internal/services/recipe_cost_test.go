@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecipeCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		recipe []*store.ProductIngredient
+		want   float64
+		wantOK bool
+	}{
+		{name: "no recipe", wantOK: false},
+		{
+			name: "grams, milliliters and units",
+			recipe: []*store.ProductIngredient{
+				{Quantity: 250, Unit: "g", Cost: 1200},
+				{Quantity: 100, Unit: "ml", Cost: 2000},
+				{Quantity: 2, Unit: "u", Cost: 150},
+			},
+			want:   300 + 200 + 300,
+			wantOK: true,
+		},
+		{
+			name: "ingredient without cost",
+			recipe: []*store.ProductIngredient{
+				{Quantity: 250, Unit: "g", Cost: 1200},
+				{Quantity: 1, Unit: "u"},
+			},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RecipeCost(&store.Product{Recipe: tt.recipe})
+			assert.Equal(t, tt.wantOK, ok)
+			assert.InDelta(t, tt.want, got, 0.001)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("rol no encontrado")
+	ErrRoleNameEmpty     = errors.New("el nombre del rol es obligatorio")
+	ErrUnknownPermission = errors.New("permiso desconocido")
+	ErrSystemRole        = errors.New("los roles del sistema no se pueden renombrar ni eliminar")
+	ErrAdminRoleLocked   = errors.New("el rol administrador tiene todos los permisos y no se puede modificar")
+)
+
+type RoleRequest struct {
+	Name        string   `json:"name" example:"cajero"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions" example:"sales.view,sales.create"`
+}
+
+// RoleService manages the configurable roles users are assigned. The
+// administrator role always keeps every permission so there is always
+// someone able to manage roles.
+type RoleService struct {
+	roleStore store.RoleStore
+}
+
+func NewRoleService(roleStore store.RoleStore) *RoleService {
+	return &RoleService{roleStore: roleStore}
+}
+
+func (s *RoleService) List() ([]*store.Role, error) {
+	return s.roleStore.List()
+}
+
+func (s *RoleService) Get(id int64) (*store.Role, error) {
+	role, err := s.roleStore.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting role: %w", err)
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// Exists reports whether name is a role users can be assigned.
+func (s *RoleService) Exists(name string) (bool, error) {
+	role, err := s.roleStore.GetByName(name)
+	if err != nil {
+		return false, err
+	}
+	return role != nil, nil
+}
+
+func (s *RoleService) Create(req RoleRequest) (*store.Role, error) {
+	role := &store.Role{}
+	if err := applyRoleRequest(role, req); err != nil {
+		return nil, err
+	}
+	if err := s.roleStore.Create(role); err != nil {
+		if errors.Is(err, store.ErrRoleNameTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error creating role: %w", err)
+	}
+	return role, nil
+}
+
+func (s *RoleService) Update(id int64, req RoleRequest) (*store.Role, error) {
+	role, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if role.Name == store.AdminRole {
+		return nil, ErrAdminRoleLocked
+	}
+	name := role.Name
+	if err := applyRoleRequest(role, req); err != nil {
+		return nil, err
+	}
+	if role.IsSystem && role.Name != name {
+		return nil, ErrSystemRole
+	}
+
+	if err := s.roleStore.Update(role); err != nil {
+		if errors.Is(err, store.ErrRoleNameTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error updating role: %w", err)
+	}
+	return role, nil
+}
+
+// Delete removes a role no user is assigned.
+func (s *RoleService) Delete(id int64) error {
+	role, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if role.IsSystem {
+		return ErrSystemRole
+	}
+	if role.UserCount > 0 {
+		return store.ErrRoleInUse
+	}
+
+	if err := s.roleStore.Delete(id); err != nil {
+		if errors.Is(err, store.ErrRoleInUse) {
+			return err
+		}
+		return fmt.Errorf("error deleting role: %w", err)
+	}
+	return nil
+}
+
+func applyRoleRequest(role *store.Role, req RoleRequest) error {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if name == "" {
+		return ErrRoleNameEmpty
+	}
+
+	seen := make(map[string]bool, len(req.Permissions))
+	permissions := []string{}
+	for _, p := range req.Permissions {
+		p = strings.TrimSpace(p)
+		if !store.IsPermission(p) {
+			return fmt.Errorf("%w: %s", ErrUnknownPermission, p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			permissions = append(permissions, p)
+		}
+	}
+	sort.Strings(permissions)
+
+	role.Name = name
+	role.Description = strings.TrimSpace(req.Description)
+	role.Permissions = permissions
+	return nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	roleStore := store.NewPostgresRoleStore(db)
+	service := NewRoleService(roleStore)
+
+	name := fmt.Sprintf("encargado-%d", time.Now().UnixNano())
+	role, err := service.Create(RoleRequest{
+		Name:        "  E" + name[1:] + " ",
+		Permissions: []string{store.PermSalesVoid, store.PermSalesView, store.PermSalesVoid},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, name, role.Name)
+	assert.Equal(t, []string{store.PermSalesView, store.PermSalesVoid}, role.Permissions)
+
+	_, err = service.Create(RoleRequest{Name: "x", Permissions: []string{"sales.fly"}})
+	assert.ErrorIs(t, err, ErrUnknownPermission)
+	_, err = service.Create(RoleRequest{Name: " "})
+	assert.ErrorIs(t, err, ErrRoleNameEmpty)
+
+	admin, err := roleStore.GetByName(store.AdminRole)
+	require.NoError(t, err)
+	_, err = service.Update(admin.ID, RoleRequest{Name: store.AdminRole})
+	assert.ErrorIs(t, err, ErrAdminRoleLocked)
+
+	employee, err := roleStore.GetByName("employee")
+	require.NoError(t, err)
+	// What employees could do on the web before roles.
+	assert.ElementsMatch(t, []string{
+		store.PermSalesView, store.PermSalesCreate, store.PermShiftsOperate,
+		store.PermStockView, store.PermStockAdjust, store.PermStockTransfer, store.PermWasteRegister,
+		store.PermInventoryCount,
+		store.PermProductsEdit, store.PermClientsManage, store.PermProvidersManage, store.PermOrdersManage,
+	}, employee.Permissions)
+	_, err = service.Update(employee.ID, RoleRequest{Name: "cashier", Permissions: employee.Permissions})
+	assert.ErrorIs(t, err, ErrSystemRole)
+	assert.ErrorIs(t, service.Delete(employee.ID), ErrSystemRole)
+
+	require.NoError(t, service.Delete(role.ID))
+	_, err = service.Get(role.ID)
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"strings"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrInvalidSaleUnit    = errors.New("unidad de venta inválida")
+	ErrFractionalQuantity = errors.New("este producto se vende por unidad, la cantidad debe ser un número entero")
+)
+
+// ValidSaleUnit reports whether u is one of the supported sale units.
+func ValidSaleUnit(u string) bool {
+	switch u {
+	case store.SaleUnitUnit, store.SaleUnitKg, store.SaleUnitGram:
+		return true
+	}
+	return false
+}
+
+// ParseSaleUnit validates a sale unit, defaulting to selling per unit.
+func ParseSaleUnit(u string) (string, error) {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if u == "" {
+		return store.SaleUnitUnit, nil
+	}
+	if !ValidSaleUnit(u) {
+		return "", ErrInvalidSaleUnit
+	}
+	return u, nil
+}
+
+// IsSoldByWeight reports whether the product's quantities are a weight.
+func IsSoldByWeight(p *store.Product) bool {
+	return p.SaleUnit == store.SaleUnitKg || p.SaleUnit == store.SaleUnitGram
+}
+
+// ValidateQuantity checks a quantity is expressible in the product's sale
+// unit: products sold per unit only take whole quantities.
+func ValidateQuantity(p *store.Product, qty float64) error {
+	if !IsSoldByWeight(p) && qty != math.Trunc(qty) {
+		return ErrFractionalQuantity
+	}
+	return nil
+}
+
+// LineSubtotal prices qty of the product at unitPrice. Weighed products are
+// priced per kilogram, so quantities kept in grams are converted first.
+func LineSubtotal(p *store.Product, unitPrice, qty float64) float64 {
+	if p.SaleUnit == store.SaleUnitGram {
+		qty = qty / 1000
+	}
+	return RoundMoney(unitPrice * qty)
+}
+
+// QuantityForWeight converts a weight in kilograms to the product's sale unit.
+func QuantityForWeight(p *store.Product, kg float64) float64 {
+	if p.SaleUnit == store.SaleUnitGram {
+		return RoundQuantity(kg * 1000)
+	}
+	return RoundQuantity(kg)
+}
+
+// RoundQuantity rounds to the three decimals quantities are stored with.
+func RoundQuantity(q float64) float64 {
+	return math.Round(q*1000) / 1000
+}
+
+// RoundMoney rounds an amount to cents.
+func RoundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineSubtotal(t *testing.T) {
+	tests := []struct {
+		name     string
+		saleUnit string
+		price    float64
+		qty      float64
+		want     float64
+	}{
+		{name: "per unit", saleUnit: store.SaleUnitUnit, price: 150, qty: 3, want: 450},
+		{name: "per kg", saleUnit: store.SaleUnitKg, price: 12000, qty: 0.35, want: 4200},
+		{name: "grams priced per kg", saleUnit: store.SaleUnitGram, price: 12000, qty: 250, want: 3000},
+		{name: "rounded to cents", saleUnit: store.SaleUnitKg, price: 999.99, qty: 0.333, want: 333},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &store.Product{SaleUnit: tt.saleUnit}
+			assert.Equal(t, tt.want, LineSubtotal(p, tt.price, tt.qty))
+		})
+	}
+}
+
+func TestValidateQuantity(t *testing.T) {
+	unit := &store.Product{SaleUnit: store.SaleUnitUnit}
+	kg := &store.Product{SaleUnit: store.SaleUnitKg}
+
+	assert.NoError(t, ValidateQuantity(unit, 2))
+	assert.ErrorIs(t, ValidateQuantity(unit, 1.5), ErrFractionalQuantity)
+	assert.NoError(t, ValidateQuantity(kg, 1.5))
+}
+
+func TestQuantityForWeight(t *testing.T) {
+	assert.Equal(t, 0.75, QuantityForWeight(&store.Product{SaleUnit: store.SaleUnitKg}, 0.75))
+	assert.Equal(t, 750.0, QuantityForWeight(&store.Product{SaleUnit: store.SaleUnitGram}, 0.75))
+}
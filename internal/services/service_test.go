@@ -27,7 +27,7 @@ func setupTestDB(t *testing.T) *sql.DB {
 	require.NoError(t, err)
 	require.NoError(t, store.Migrate(db, "../../migrations/"))
 
-	_, err = db.Exec(`TRUNCATE order_products, orders, product_ingredients, products, categories, providers, clients, tokens, users, ingredients, payment_methods, local_stock, local_sales, local_sale_items RESTART IDENTITY CASCADE`)
+	_, err = db.Exec(`TRUNCATE order_products, orders, product_ingredients, products, categories, providers, clients, tokens, users, ingredients, payment_methods, local_stock, local_sales, local_sale_items, inventory_counts, waste_records, stock_lots, stock_transfers, purchase_orders, provider_payments, expense_extractions, webhooks, jobs RESTART IDENTITY CASCADE`)
 	require.NoError(t, err)
 	return db
 }
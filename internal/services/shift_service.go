@@ -1,6 +1,7 @@
 package services
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
@@ -10,33 +11,129 @@ import (
 )
 
 var (
-	ErrShiftAlreadyOpen   = errors.New("ya hay un turno abierto para este usuario")
-	ErrNoOpenShift        = errors.New("no hay un turno abierto para cerrar")
-	ErrShiftAlreadyClosed = errors.New("este turno ya está cerrado")
-	ErrInvalidAmount      = errors.New("el monto debe ser mayor a 0")
+	ErrShiftAlreadyOpen       = errors.New("ya hay un turno abierto para esta caja")
+	ErrNoOpenShift            = errors.New("no hay un turno abierto en esta caja")
+	ErrShiftAlreadyClosed     = errors.New("este turno ya está cerrado")
+	ErrInvalidAmount          = errors.New("el monto debe ser mayor a 0")
+	ErrCashRegisterNotFound   = errors.New("caja no encontrada")
+	ErrCashRegisterInactive   = errors.New("la caja está desactivada")
+	ErrCashRegisterNameEmpty  = errors.New("el nombre de la caja es obligatorio")
+	ErrHandoverToCurrentOwner = errors.New("el turno ya está a cargo de este usuario")
 )
 
 type ShiftService struct {
 	shiftStore    store.ShiftStore
+	registerStore store.CashRegisterStore
 	saleStore     store.LocalSaleStore
 	movementStore store.CashMovementStore
 }
 
 func NewShiftService(
 	shiftStore store.ShiftStore,
+	registerStore store.CashRegisterStore,
 	saleStore store.LocalSaleStore,
 	movementStore store.CashMovementStore,
 ) *ShiftService {
 	return &ShiftService{
 		shiftStore:    shiftStore,
+		registerStore: registerStore,
 		saleStore:     saleStore,
 		movementStore: movementStore,
 	}
 }
 
-func (s *ShiftService) OpenShift(userID int64, startCash float64, notes string) (*store.Shift, error) {
-	// Check if user already has an open shift
-	existing, err := s.shiftStore.GetOpenShiftByUserID(userID)
+// --- Cash registers ---
+
+func (s *ShiftService) ListRegisters(activeOnly bool) ([]*store.CashRegister, error) {
+	return s.registerStore.List(activeOnly)
+}
+
+func (s *ShiftService) GetRegister(id int64) (*store.CashRegister, error) {
+	return s.registerStore.GetByID(id)
+}
+
+// CreateRegister adds a register selling from the given stock location; zero
+// is the default location.
+func (s *ShiftService) CreateRegister(name string, locationID int64) (*store.CashRegister, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrCashRegisterNameEmpty
+	}
+
+	register := &store.CashRegister{Name: name, LocationID: locationID, IsActive: true}
+	if err := s.registerStore.Create(register); err != nil {
+		return nil, fmt.Errorf("error creating cash register: %w", err)
+	}
+	return register, nil
+}
+
+func (s *ShiftService) UpdateRegister(id int64, name string, locationID int64, isActive bool) (*store.CashRegister, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrCashRegisterNameEmpty
+	}
+
+	register, err := s.registerStore.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting cash register: %w", err)
+	}
+	if register == nil {
+		return nil, ErrCashRegisterNotFound
+	}
+
+	if !isActive && register.IsActive {
+		open, err := s.shiftStore.GetOpenShiftByRegisterID(id)
+		if err != nil {
+			return nil, fmt.Errorf("error checking open shift: %w", err)
+		}
+		if open != nil {
+			return nil, ErrShiftAlreadyOpen
+		}
+	}
+
+	register.Name = name
+	register.LocationID = locationID
+	register.IsActive = isActive
+	if err := s.registerStore.Update(register); err != nil {
+		return nil, fmt.Errorf("error updating cash register: %w", err)
+	}
+	return register, nil
+}
+
+func (s *ShiftService) activeRegister(registerID int64) (*store.CashRegister, error) {
+	register, err := s.registerStore.GetByID(registerID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting cash register: %w", err)
+	}
+	if register == nil {
+		return nil, ErrCashRegisterNotFound
+	}
+	if !register.IsActive {
+		return nil, ErrCashRegisterInactive
+	}
+	return register, nil
+}
+
+func (s *ShiftService) openShift(registerID int64) (*store.Shift, error) {
+	shift, err := s.shiftStore.GetOpenShiftByRegisterID(registerID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting open shift: %w", err)
+	}
+	if shift == nil {
+		return nil, ErrNoOpenShift
+	}
+	return shift, nil
+}
+
+// --- Register sessions ---
+
+func (s *ShiftService) OpenShift(registerID, userID int64, startCash float64, notes string) (*store.Shift, error) {
+	if _, err := s.activeRegister(registerID); err != nil {
+		return nil, err
+	}
+
+	// A drawer can only have one open session at a time
+	existing, err := s.shiftStore.GetOpenShiftByRegisterID(registerID)
 	if err != nil {
 		return nil, fmt.Errorf("error checking existing shifts: %w", err)
 	}
@@ -45,11 +142,13 @@ func (s *ShiftService) OpenShift(userID int64, startCash float64, notes string)
 	}
 
 	shift := &store.Shift{
-		UserID:    userID,
-		StartTime: time.Now(),
-		StartCash: startCash,
-		Status:    "open",
-		Notes:     notes,
+		RegisterID:    registerID,
+		UserID:        userID,
+		CurrentUserID: userID,
+		StartTime:     time.Now(),
+		StartCash:     startCash,
+		Status:        "open",
+		Notes:         notes,
 	}
 
 	if err := s.shiftStore.Create(shift); err != nil {
@@ -59,17 +158,15 @@ func (s *ShiftService) OpenShift(userID int64, startCash float64, notes string)
 	return shift, nil
 }
 
-func (s *ShiftService) CloseShift(userID int64, declaredCash float64, notes string) (*store.Shift, error) {
-	shift, err := s.shiftStore.GetOpenShiftByUserID(userID)
+func (s *ShiftService) CloseShift(registerID, userID int64, declaredCash float64, notes string) (*store.Shift, error) {
+	shift, err := s.openShift(registerID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting open shift: %w", err)
-	}
-	if shift == nil {
-		return nil, ErrNoOpenShift
+		return nil, err
 	}
 
 	now := time.Now()
 	shift.EndTime = &now
+	shift.ClosedBy = &userID
 	shift.EndCashDeclared = &declaredCash
 	shift.Status = "closed"
 	if notes != "" {
@@ -77,8 +174,8 @@ func (s *ShiftService) CloseShift(userID int64, declaredCash float64, notes stri
 	}
 
 	// Calculate expected cash
-	// 1. Get total sales during the shift
-	sales, err := s.saleStore.GetStats(shift.StartTime, *shift.EndTime)
+	// 1. Get total sales registered against this drawer session
+	sales, err := s.saleStore.GetStatsByShiftID(shift.ID)
 	if err != nil {
 		return nil, fmt.Errorf("error calculating sales stats: %w", err)
 	}
@@ -110,31 +207,58 @@ func (s *ShiftService) CloseShift(userID int64, declaredCash float64, notes stri
 	return shift, nil
 }
 
-func (s *ShiftService) GetCurrentShift(userID int64) (*store.Shift, error) {
-	return s.shiftStore.GetOpenShiftByUserID(userID)
+// HandoverShift passes an open drawer session to another employee without
+// closing it. countedCash is optional and only recorded for reference.
+func (s *ShiftService) HandoverShift(registerID, toUserID int64, countedCash *float64, notes string) (*store.ShiftHandover, error) {
+	shift, err := s.openShift(registerID)
+	if err != nil {
+		return nil, err
+	}
+	if shift.CurrentUserID == toUserID {
+		return nil, ErrHandoverToCurrentOwner
+	}
+
+	handover := &store.ShiftHandover{
+		ShiftID:     shift.ID,
+		FromUserID:  shift.CurrentUserID,
+		ToUserID:    toUserID,
+		CountedCash: countedCash,
+		Notes:       notes,
+	}
+
+	if err := s.shiftStore.Handover(handover); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoOpenShift
+		}
+		return nil, fmt.Errorf("error registering handover: %w", err)
+	}
+
+	return handover, nil
+}
+
+func (s *ShiftService) GetCurrentShift(registerID int64) (*store.Shift, error) {
+	return s.shiftStore.GetOpenShiftByRegisterID(registerID)
 }
 
-func (s *ShiftService) ListUserShifts(userID int64, page int) ([]*store.Shift, error) {
+func (s *ShiftService) ListRegisterShifts(registerID int64, page int) ([]*store.Shift, error) {
 	limit := 20
 	offset := (page - 1) * limit
-	return s.shiftStore.ListByUserID(userID, limit, offset)
+	return s.shiftStore.ListByRegisterID(registerID, limit, offset)
 }
 
-func (s *ShiftService) RegisterMovement(userID int64, amount float64, typeStr string, reason string) (*store.CashMovement, error) {
+func (s *ShiftService) RegisterMovement(registerID, userID int64, amount float64, typeStr string, reason string) (*store.CashMovement, error) {
 	if amount <= 0 {
 		return nil, ErrInvalidAmount
 	}
 
-	shift, err := s.shiftStore.GetOpenShiftByUserID(userID)
+	shift, err := s.openShift(registerID)
 	if err != nil {
-		return nil, fmt.Errorf("error getting open shift: %w", err)
-	}
-	if shift == nil {
-		return nil, ErrNoOpenShift
+		return nil, err
 	}
 
 	movement := &store.CashMovement{
 		ShiftID: shift.ID,
+		UserID:  &userID,
 		Amount:  amount,
 		Type:    store.CashMovementType(typeStr),
 		Reason:  reason,
@@ -149,4 +273,8 @@ func (s *ShiftService) RegisterMovement(userID int64, amount float64, typeStr st
 
 func (s *ShiftService) ListMovements(shiftID int64) ([]*store.CashMovement, error) {
 	return s.movementStore.ListByShiftID(shiftID)
-}
\ No newline at end of file
+}
+
+func (s *ShiftService) ListHandovers(shiftID int64) ([]*store.ShiftHandover, error) {
+	return s.shiftStore.ListHandovers(shiftID)
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrLocationNotFound        = errors.New("ubicación no encontrada")
+	ErrLocationNameEmpty       = errors.New("el nombre de la ubicación es obligatorio")
+	ErrLocationInactive        = errors.New("la ubicación está inactiva")
+	ErrDefaultLocationInactive = errors.New("la ubicación principal no se puede desactivar")
+	ErrSameLocation            = errors.New("el origen y el destino deben ser distintos")
+	ErrTransferEmpty           = errors.New("el remito debe tener al menos un producto")
+	ErrInvalidTransferQuantity = errors.New("la cantidad a transferir debe ser mayor a 0")
+	ErrTransferNotFound        = errors.New("remito interno no encontrado")
+)
+
+type CreateTransferItem struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+}
+
+type CreateTransferRequest struct {
+	FromLocationID int64                `json:"from_location_id"`
+	ToLocationID   int64                `json:"to_location_id"`
+	Notes          string               `json:"notes"`
+	Items          []CreateTransferItem `json:"items"`
+	// UserID is who sent the goods, recorded in the stock movements.
+	UserID int64 `json:"-"`
+}
+
+// StockLocationService manages the places stock is kept and the internal
+// delivery notes (remitos internos) that move it between them.
+type StockLocationService struct {
+	db            *sql.DB
+	locationStore store.StockLocationStore
+	transferStore store.StockTransferStore
+	stockStore    store.LocalStockStore
+	productStore  store.ProductStore
+}
+
+func NewStockLocationService(
+	db *sql.DB,
+	locationStore store.StockLocationStore,
+	transferStore store.StockTransferStore,
+	stockStore store.LocalStockStore,
+	productStore store.ProductStore,
+) *StockLocationService {
+	return &StockLocationService{
+		db:            db,
+		locationStore: locationStore,
+		transferStore: transferStore,
+		stockStore:    stockStore,
+		productStore:  productStore,
+	}
+}
+
+func (s *StockLocationService) ListLocations(activeOnly bool) ([]*store.StockLocation, error) {
+	return s.locationStore.List(activeOnly)
+}
+
+func (s *StockLocationService) GetLocation(id int64) (*store.StockLocation, error) {
+	location, err := s.locationStore.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting location: %w", err)
+	}
+	if location == nil {
+		return nil, ErrLocationNotFound
+	}
+	return location, nil
+}
+
+func (s *StockLocationService) CreateLocation(name string) (*store.StockLocation, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrLocationNameEmpty
+	}
+
+	location := &store.StockLocation{Name: name, IsActive: true}
+	if err := s.locationStore.Create(location); err != nil {
+		if errors.Is(err, store.ErrLocationNameTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error creating location: %w", err)
+	}
+	return location, nil
+}
+
+func (s *StockLocationService) UpdateLocation(id int64, name string, isActive bool) (*store.StockLocation, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrLocationNameEmpty
+	}
+
+	location, err := s.GetLocation(id)
+	if err != nil {
+		return nil, err
+	}
+	if location.IsDefault && !isActive {
+		return nil, ErrDefaultLocationInactive
+	}
+
+	location.Name = name
+	location.IsActive = isActive
+	if err := s.locationStore.Update(location); err != nil {
+		if errors.Is(err, store.ErrLocationNameTaken) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error updating location: %w", err)
+	}
+	return location, nil
+}
+
+// CreateTransfer records a remito interno and moves its goods in the same
+// transaction: each product leaves the origin with a "transfer" movement and
+// enters the destination with another, taking its lots along.
+func (s *StockLocationService) CreateTransfer(req CreateTransferRequest) (*store.StockTransfer, error) {
+	if req.FromLocationID == req.ToLocationID {
+		return nil, ErrSameLocation
+	}
+	if len(req.Items) == 0 {
+		return nil, ErrTransferEmpty
+	}
+	for _, id := range []int64{req.FromLocationID, req.ToLocationID} {
+		location, err := s.GetLocation(id)
+		if err != nil {
+			return nil, err
+		}
+		if !location.IsActive {
+			return nil, fmt.Errorf("%w: %s", ErrLocationInactive, location.Name)
+		}
+	}
+
+	// Lines for the same product are merged so each moves once.
+	quantities := make(map[int64]float64)
+	var productIDs []int64
+	for _, item := range req.Items {
+		if item.Quantity <= 0 {
+			return nil, ErrInvalidTransferQuantity
+		}
+		if _, ok := quantities[item.ProductID]; !ok {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		quantities[item.ProductID] = RoundQuantity(quantities[item.ProductID] + item.Quantity)
+	}
+	sort.Slice(productIDs, func(i, j int) bool { return productIDs[i] < productIDs[j] })
+
+	products, err := s.productStore.GetProductsByIDs(productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error getting products: %w", err)
+	}
+
+	transfer := &store.StockTransfer{
+		FromLocationID: req.FromLocationID,
+		ToLocationID:   req.ToLocationID,
+		Notes:          strings.TrimSpace(req.Notes),
+	}
+	if req.UserID != 0 {
+		transfer.UserID = &req.UserID
+	}
+	for _, id := range productIDs {
+		product, ok := products[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: id %d", ErrProductNotFound, id)
+		}
+		if err := ValidateQuantity(product, quantities[id]); err != nil {
+			return nil, fmt.Errorf("'%s': %w", product.Name, err)
+		}
+		transfer.Items = append(transfer.Items, &store.StockTransferItem{
+			ProductID:   id,
+			ProductName: product.Name,
+			SaleUnit:    product.SaleUnit,
+			Quantity:    quantities[id],
+		})
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range productIDs {
+		if products[id].AllowNegativeStock {
+			if err := s.stockStore.EnsureTx(tx, req.FromLocationID, id); err != nil {
+				return nil, fmt.Errorf("error preparing stock: %w", err)
+			}
+		}
+		if err := s.stockStore.EnsureTx(tx, req.ToLocationID, id); err != nil {
+			return nil, fmt.Errorf("error preparing stock: %w", err)
+		}
+	}
+	stocks, err := s.stockStore.LockByProductIDsTx(tx, req.FromLocationID, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error locking stock: %w", err)
+	}
+	for _, id := range productIDs {
+		product := products[id]
+		var available float64
+		if stock, ok := stocks[id]; ok {
+			available = stock.Quantity
+		}
+		if available < quantities[id] && !product.AllowNegativeStock {
+			return nil, &InsufficientStockError{Product: product.Name, Available: available, Required: quantities[id]}
+		}
+	}
+
+	if err := s.transferStore.CreateTx(tx, transfer); err != nil {
+		return nil, fmt.Errorf("error creating transfer: %w", err)
+	}
+
+	out := store.StockChange{
+		LocationID:    req.FromLocationID,
+		Reason:        store.MovementTransfer,
+		UserID:        req.UserID,
+		ReferenceType: store.ReferenceStockTransfer,
+		ReferenceID:   transfer.ID,
+		Note:          transfer.Notes,
+	}
+	in := out
+	in.LocationID = req.ToLocationID
+	for _, id := range productIDs {
+		if _, err := s.stockStore.AdjustQuantityTx(tx, id, -quantities[id], out); err != nil {
+			if errors.Is(err, store.ErrNegativeStock) {
+				return nil, fmt.Errorf("%w: %s", ErrInsufficientStock, products[id].Name)
+			}
+			return nil, fmt.Errorf("error taking stock out: %w", err)
+		}
+		if _, err := s.stockStore.AdjustQuantityTx(tx, id, quantities[id], in); err != nil {
+			return nil, fmt.Errorf("error bringing stock in: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return s.GetTransfer(transfer.ID)
+}
+
+func (s *StockLocationService) GetTransfer(id int64) (*store.StockTransfer, error) {
+	transfer, err := s.transferStore.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting transfer: %w", err)
+	}
+	if transfer == nil {
+		return nil, ErrTransferNotFound
+	}
+	return transfer, nil
+}
+
+// ListTransfers returns the transfers in [from, to). locationID 0 means every
+// location.
+func (s *StockLocationService) ListTransfers(from, to time.Time, locationID int64) ([]*store.StockTransfer, error) {
+	return s.transferStore.List(from, to, locationID)
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockLocationService_CreateTransfer(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	service := NewStockLocationService(db, store.NewPostgresStockLocationStore(db), store.NewPostgresStockTransferStore(db),
+		localStockStore, productStore)
+
+	cat := &store.Category{Name: "Category For Transfer Test"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+
+	cake := &store.Product{CategoryID: cat.ID, Name: "Torta", UnitPrice: 5000}
+	require.NoError(t, productStore.CreateProduct(cake))
+	bread := &store.Product{CategoryID: cat.ID, Name: "Pan", UnitPrice: 2000, SaleUnit: store.SaleUnitGram}
+	require.NoError(t, productStore.CreateProduct(bread))
+
+	_, err := localStockStore.Create(cake.ID, 5, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+	_, err = localStockStore.Create(bread.ID, 1000, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+
+	locations, err := service.ListLocations(true)
+	require.NoError(t, err)
+	require.NotEmpty(t, locations)
+	shop := locations[0]
+	branch, err := service.CreateLocation(fmt.Sprintf("Sucursal %d", time.Now().UnixNano()))
+	require.NoError(t, err)
+
+	quantityAt := func(productID, locationID int64) float64 {
+		t.Helper()
+		stock, err := localStockStore.GetByProductID(productID, locationID)
+		require.NoError(t, err)
+		if stock == nil {
+			return 0
+		}
+		return stock.Quantity
+	}
+
+	t.Run("moves stock between locations", func(t *testing.T) {
+		transfer, err := service.CreateTransfer(CreateTransferRequest{
+			FromLocationID: shop.ID,
+			ToLocationID:   branch.ID,
+			Notes:          " mañana ",
+			Items: []CreateTransferItem{
+				{ProductID: cake.ID, Quantity: 1},
+				{ProductID: bread.ID, Quantity: 400},
+				{ProductID: cake.ID, Quantity: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "mañana", transfer.Notes)
+		require.Len(t, transfer.Items, 2, "lines for the same product are merged")
+
+		assert.Equal(t, 3.0, quantityAt(cake.ID, shop.ID))
+		assert.Equal(t, 2.0, quantityAt(cake.ID, branch.ID))
+		assert.Equal(t, 600.0, quantityAt(bread.ID, shop.ID))
+		assert.Equal(t, 400.0, quantityAt(bread.ID, branch.ID))
+	})
+
+	t.Run("insufficient stock moves nothing", func(t *testing.T) {
+		_, err := service.CreateTransfer(CreateTransferRequest{
+			FromLocationID: branch.ID,
+			ToLocationID:   shop.ID,
+			Items: []CreateTransferItem{
+				{ProductID: bread.ID, Quantity: 100},
+				{ProductID: cake.ID, Quantity: 5},
+			},
+		})
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+		assert.Equal(t, 400.0, quantityAt(bread.ID, branch.ID))
+		assert.Equal(t, 600.0, quantityAt(bread.ID, shop.ID))
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		_, err := service.CreateTransfer(CreateTransferRequest{FromLocationID: shop.ID, ToLocationID: shop.ID,
+			Items: []CreateTransferItem{{ProductID: cake.ID, Quantity: 1}}})
+		assert.ErrorIs(t, err, ErrSameLocation)
+
+		_, err = service.CreateTransfer(CreateTransferRequest{FromLocationID: shop.ID, ToLocationID: branch.ID})
+		assert.ErrorIs(t, err, ErrTransferEmpty)
+
+		_, err = service.CreateTransfer(CreateTransferRequest{FromLocationID: shop.ID, ToLocationID: branch.ID,
+			Items: []CreateTransferItem{{ProductID: cake.ID, Quantity: 0.5}}})
+		assert.ErrorIs(t, err, ErrFractionalQuantity)
+
+		_, err = service.CreateTransfer(CreateTransferRequest{FromLocationID: shop.ID, ToLocationID: 9999,
+			Items: []CreateTransferItem{{ProductID: cake.ID, Quantity: 1}}})
+		assert.ErrorIs(t, err, ErrLocationNotFound)
+	})
+
+	t.Run("the default location stays active", func(t *testing.T) {
+		_, err := service.UpdateLocation(shop.ID, shop.Name, false)
+		assert.ErrorIs(t, err, ErrDefaultLocationInactive)
+	})
+}
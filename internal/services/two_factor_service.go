@@ -0,0 +1,233 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/totp"
+)
+
+var (
+	ErrTwoFactorInvalidCode     = errors.New("código incorrecto")
+	ErrTwoFactorNotPending      = errors.New("no hay una activación de verificación en dos pasos en curso")
+	ErrTwoFactorAlreadyEnabled  = errors.New("la verificación en dos pasos ya está activada")
+	ErrTwoFactorNotEnabled      = errors.New("la verificación en dos pasos no está activada")
+	ErrTwoFactorRequiredForRole = errors.New("tu rol requiere la verificación en dos pasos, no se puede desactivar")
+	ErrTwoFactorUserNotFound    = errors.New("usuario no encontrado")
+)
+
+const (
+	// RecoveryCodeCount is how many recovery codes are issued at once. Each
+	// one replaces a code from the app, once.
+	RecoveryCodeCount = 10
+	// TwoFactorLoginTTL is how long the second step of a login can wait
+	// after the password.
+	TwoFactorLoginTTL = 5 * time.Minute
+)
+
+// TwoFactorRolesFromEnv returns the roles that must use two-factor
+// authentication: TWO_FACTOR_REQUIRED_ROLES, comma separated. Unset, only
+// administrators must; set empty, nobody.
+func TwoFactorRolesFromEnv() []string {
+	v, ok := os.LookupEnv("TWO_FACTOR_REQUIRED_ROLES")
+	if !ok {
+		return []string{store.AdminRole}
+	}
+	var roles []string
+	for _, r := range strings.Split(v, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+// TwoFactorService handles TOTP two-factor authentication: enrolling with an
+// authenticator app, checking codes at login and the one-time recovery codes
+// for when the app is lost.
+type TwoFactorService struct {
+	store         store.TwoFactorStore
+	issuer        string
+	requiredRoles map[string]bool
+}
+
+func NewTwoFactorService(s store.TwoFactorStore, issuer string, requiredRoles []string) *TwoFactorService {
+	required := make(map[string]bool, len(requiredRoles))
+	for _, r := range requiredRoles {
+		required[r] = true
+	}
+	return &TwoFactorService{store: s, issuer: issuer, requiredRoles: required}
+}
+
+// Required reports whether the user's role must use two-factor.
+func (s *TwoFactorService) Required(user *store.User) bool {
+	return s.requiredRoles[user.Role]
+}
+
+func (s *TwoFactorService) Status(userID int64) (*store.TwoFactor, error) {
+	tf, err := s.store.Get(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting two-factor status: %w", err)
+	}
+	if tf == nil {
+		return nil, ErrTwoFactorUserNotFound
+	}
+	return tf, nil
+}
+
+// Begin starts an enrollment with a new secret, returned with the otpauth
+// URI for the authenticator app. It replaces any unconfirmed one.
+func (s *TwoFactorService) Begin(user *store.User) (string, string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.store.SetPending(user.ID, secret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", ErrTwoFactorAlreadyEnabled
+		}
+		return "", "", fmt.Errorf("error starting two-factor enrollment: %w", err)
+	}
+	return secret, s.URI(user, secret), nil
+}
+
+// URI is the otpauth link for the user's authenticator app.
+func (s *TwoFactorService) URI(user *store.User, secret string) string {
+	return totp.URI(s.issuer, user.Username, secret)
+}
+
+// Confirm enables two-factor once the user proves the app works with a code
+// from it. It returns the recovery codes, which are only shown this once.
+func (s *TwoFactorService) Confirm(userID int64, code string) ([]string, error) {
+	tf, err := s.Status(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if tf.Secret == "" {
+		return nil, ErrTwoFactorNotPending
+	}
+
+	counter, ok := totp.Validate(tf.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrTwoFactorInvalidCode
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.Enable(userID, counter, hashes); err != nil {
+		return nil, fmt.Errorf("error enabling two-factor: %w", err)
+	}
+	return codes, nil
+}
+
+// Verify checks a code from the app or an unused recovery code. A code from
+// the app is accepted once; a recovery code is spent.
+func (s *TwoFactorService) Verify(userID int64, code string) error {
+	tf, err := s.Status(userID)
+	if err != nil {
+		return err
+	}
+	if !tf.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	code = strings.TrimSpace(code)
+	if counter, ok := totp.Validate(tf.Secret, code, time.Now()); ok {
+		fresh, err := s.store.UseCounter(userID, counter)
+		if err != nil {
+			return fmt.Errorf("error recording two-factor code: %w", err)
+		}
+		if !fresh {
+			return ErrTwoFactorInvalidCode
+		}
+		return nil
+	}
+
+	used, err := s.store.UseRecoveryCode(userID, hashRecoveryCode(code))
+	if err != nil {
+		return fmt.Errorf("error using recovery code: %w", err)
+	}
+	if !used {
+		return ErrTwoFactorInvalidCode
+	}
+	return nil
+}
+
+// Disable turns two-factor off after checking a code. Users whose role
+// requires it can't.
+func (s *TwoFactorService) Disable(user *store.User, code string) error {
+	if s.Required(user) {
+		return ErrTwoFactorRequiredForRole
+	}
+	if err := s.Verify(user.ID, code); err != nil {
+		return err
+	}
+	return s.Reset(user.ID)
+}
+
+// Reset turns two-factor off without a code, for an administrator to let in
+// a user who lost the app and the recovery codes. Users whose role requires
+// it enroll again on their next login.
+func (s *TwoFactorService) Reset(userID int64) error {
+	if err := s.store.Disable(userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTwoFactorUserNotFound
+		}
+		return fmt.Errorf("error disabling two-factor: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a code.
+func (s *TwoFactorService) RegenerateRecoveryCodes(userID int64, code string) ([]string, error) {
+	if err := s.Verify(userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, fmt.Errorf("error replacing recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes returns codes like "k7f2q-9xw4m" and their hashes.
+func newRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([][]byte, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		c := strings.ToLower(recoveryEncoding.EncodeToString(b))[:10]
+		codes[i] = c[:5] + "-" + c[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes, as codes are typed by
+// hand.
+func hashRecoveryCode(code string) []byte {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	h := sha256.Sum256([]byte(code))
+	return h[:]
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+)
+
+var (
+	ErrInvalidWasteReason   = errors.New("motivo de merma inválido")
+	ErrInvalidWasteQuantity = errors.New("la cantidad de merma debe ser mayor a 0")
+)
+
+type RegisterWasteRequest struct {
+	ProductID int64   `json:"product_id"`
+	Quantity  float64 `json:"quantity"`
+	Reason    string  `json:"reason" example:"expired"`
+	Note      string  `json:"note"`
+	// LocationID is where the goods are taken from; zero is the default
+	// location.
+	LocationID int64 `json:"location_id,omitempty"`
+	// UserID is who registered the waste.
+	UserID int64 `json:"-"`
+}
+
+// WasteReport summarizes waste over a period.
+type WasteReport struct {
+	From       time.Time               `json:"from"`
+	To         time.Time               `json:"to"`
+	ByProduct  []*store.WasteByProduct `json:"by_product"`
+	ByWeek     []*store.WasteByWeek    `json:"by_week"`
+	TotalValue float64                 `json:"total_value"`
+}
+
+type WasteService struct {
+	db           *sql.DB
+	wasteStore   store.WasteStore
+	stockStore   store.LocalStockStore
+	productStore store.ProductStore
+}
+
+func NewWasteService(db *sql.DB, wasteStore store.WasteStore, stockStore store.LocalStockStore, productStore store.ProductStore) *WasteService {
+	return &WasteService{
+		db:           db,
+		wasteStore:   wasteStore,
+		stockStore:   stockStore,
+		productStore: productStore,
+	}
+}
+
+func validWasteReason(reason string) bool {
+	switch reason {
+	case store.WasteExpired, store.WasteBroken, store.WasteTasting, store.WasteOther:
+		return true
+	}
+	return false
+}
+
+// WasteUnitCost values one sale unit of the product at recipe cost, falling
+// back to the retail price while the recipe can't be costed.
+func WasteUnitCost(p *store.Product) (float64, string) {
+	if cost, ok := RecipeCost(p); ok {
+		return cost, store.CostBasisRecipe
+	}
+	return p.UnitPrice, store.CostBasisPrice
+}
+
+// RegisterWaste records the waste and deducts it from the stock at its
+// location with a "waste" movement, in one transaction.
+func (s *WasteService) RegisterWaste(req RegisterWasteRequest) (*store.WasteRecord, error) {
+	if req.Quantity <= 0 {
+		return nil, ErrInvalidWasteQuantity
+	}
+	if !validWasteReason(req.Reason) {
+		return nil, ErrInvalidWasteReason
+	}
+
+	product, err := s.productStore.GetProductByID(req.ProductID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking product existence: %w", err)
+	}
+	if product == nil {
+		return nil, ErrProductNotFound
+	}
+	if err := ValidateQuantity(product, req.Quantity); err != nil {
+		return nil, err
+	}
+
+	unitCost, basis := WasteUnitCost(product)
+	record := &store.WasteRecord{
+		ProductID:   product.ID,
+		ProductName: product.Name,
+		SaleUnit:    product.SaleUnit,
+		Quantity:    req.Quantity,
+		Reason:      req.Reason,
+		Note:        strings.TrimSpace(req.Note),
+		UnitCost:    RoundMoney(unitCost),
+		CostBasis:   basis,
+		Value:       LineSubtotal(product, unitCost, req.Quantity),
+	}
+	if req.UserID != 0 {
+		record.UserID = &req.UserID
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if product.AllowNegativeStock {
+		if err := s.stockStore.EnsureTx(tx, req.LocationID, product.ID); err != nil {
+			return nil, fmt.Errorf("error preparing stock: %w", err)
+		}
+	}
+	stocks, err := s.stockStore.LockByProductIDsTx(tx, req.LocationID, []int64{product.ID})
+	if err != nil {
+		return nil, fmt.Errorf("error locking stock: %w", err)
+	}
+	var available float64
+	if stock, ok := stocks[product.ID]; ok {
+		available = stock.Quantity
+	}
+	if available < req.Quantity && !product.AllowNegativeStock {
+		return nil, &InsufficientStockError{Product: product.Name, Available: available, Required: req.Quantity}
+	}
+
+	if err := s.wasteStore.CreateTx(tx, record); err != nil {
+		return nil, fmt.Errorf("error creating waste record: %w", err)
+	}
+
+	_, err = s.stockStore.AdjustQuantityTx(tx, product.ID, -req.Quantity, store.StockChange{
+		LocationID:    req.LocationID,
+		Reason:        store.MovementWaste,
+		UserID:        req.UserID,
+		ReferenceType: store.ReferenceWaste,
+		ReferenceID:   record.ID,
+		Note:          record.Note,
+	})
+	if errors.Is(err, store.ErrNegativeStock) {
+		return nil, &InsufficientStockError{Product: product.Name, Available: available, Required: req.Quantity}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error deducting stock: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return record, nil
+}
+
+// ListWaste returns the waste registered in [from, to). productID 0 means
+// every product.
+func (s *WasteService) ListWaste(from, to time.Time, productID int64) ([]*store.WasteRecord, error) {
+	return s.wasteStore.List(from, to, productID)
+}
+
+// Report totals waste in [from, to) by product and by week. With a productID
+// the weekly series is for that product only.
+func (s *WasteService) Report(from, to time.Time, productID int64) (*WasteReport, error) {
+	byProduct, err := s.wasteStore.ReportByProduct(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error building waste report by product: %w", err)
+	}
+	byWeek, err := s.wasteStore.ReportByWeek(from, to, productID)
+	if err != nil {
+		return nil, fmt.Errorf("error building waste report by week: %w", err)
+	}
+
+	report := &WasteReport{From: from, To: to, ByProduct: byProduct, ByWeek: byWeek}
+	for _, p := range byProduct {
+		report.TotalValue += p.Value
+	}
+	report.TotalValue = RoundMoney(report.TotalValue)
+	return report, nil
+}
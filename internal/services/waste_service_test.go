@@ -0,0 +1,117 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasteService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	productStore := store.NewPostgresProductStore(db)
+	categoryStore := store.NewPostgresCategoryStore(db)
+	ingredientStore := store.NewPostgresIngredientStore(db)
+	localStockStore := store.NewPostgresLocalStockStore(db)
+	movementStore := store.NewPostgresStockMovementStore(db)
+	service := NewWasteService(db, store.NewPostgresWasteStore(db), localStockStore, productStore)
+
+	cat := &store.Category{Name: "Category For Waste Test"}
+	require.NoError(t, categoryStore.CreateCategory(cat))
+
+	flour := &store.Ingredient{Name: "Harina", Cost: 1000}
+	require.NoError(t, ingredientStore.CreateIngredient(flour))
+
+	cake := &store.Product{CategoryID: cat.ID, Name: "Torta", UnitPrice: 5000}
+	require.NoError(t, productStore.CreateProduct(cake))
+	_, err := productStore.AddIngredientToProduct(cake.ID, flour.ID, 300, "g")
+	require.NoError(t, err)
+
+	bread := &store.Product{CategoryID: cat.ID, Name: "Pan", UnitPrice: 2000, SaleUnit: store.SaleUnitGram}
+	require.NoError(t, productStore.CreateProduct(bread))
+
+	_, err = localStockStore.Create(cake.ID, 5, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+	_, err = localStockStore.Create(bread.ID, 3000, store.StockChange{Reason: store.MovementInitial})
+	require.NoError(t, err)
+
+	t.Run("valued at recipe cost", func(t *testing.T) {
+		record, err := service.RegisterWaste(RegisterWasteRequest{
+			ProductID: cake.ID,
+			Quantity:  2,
+			Reason:    store.WasteExpired,
+			Note:      " vitrina ",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, store.CostBasisRecipe, record.CostBasis)
+		assert.Equal(t, 300.0, record.UnitCost)
+		assert.Equal(t, 600.0, record.Value)
+		assert.Equal(t, "vitrina", record.Note)
+
+		stock, err := localStockStore.GetByProductID(cake.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, stock.Quantity)
+
+		movements, err := movementStore.ListByProduct(cake.ID, 0, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		last := movements[len(movements)-1]
+		assert.Equal(t, store.MovementWaste, last.Reason)
+		assert.Equal(t, -2.0, last.Delta)
+		require.NotNil(t, last.ReferenceType)
+		assert.Equal(t, store.ReferenceWaste, *last.ReferenceType)
+		require.NotNil(t, last.ReferenceID)
+		assert.Equal(t, record.ID, *last.ReferenceID)
+	})
+
+	t.Run("valued at price without recipe", func(t *testing.T) {
+		record, err := service.RegisterWaste(RegisterWasteRequest{
+			ProductID: bread.ID,
+			Quantity:  500,
+			Reason:    store.WasteTasting,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, store.CostBasisPrice, record.CostBasis)
+		assert.Equal(t, 1000.0, record.Value)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		_, err := service.RegisterWaste(RegisterWasteRequest{ProductID: cake.ID, Quantity: 0, Reason: store.WasteBroken})
+		assert.ErrorIs(t, err, ErrInvalidWasteQuantity)
+
+		_, err = service.RegisterWaste(RegisterWasteRequest{ProductID: cake.ID, Quantity: 1, Reason: "lost"})
+		assert.ErrorIs(t, err, ErrInvalidWasteReason)
+
+		_, err = service.RegisterWaste(RegisterWasteRequest{ProductID: cake.ID, Quantity: 1.5, Reason: store.WasteBroken})
+		assert.ErrorIs(t, err, ErrFractionalQuantity)
+
+		_, err = service.RegisterWaste(RegisterWasteRequest{ProductID: 9999, Quantity: 1, Reason: store.WasteBroken})
+		assert.ErrorIs(t, err, ErrProductNotFound)
+
+		_, err = service.RegisterWaste(RegisterWasteRequest{ProductID: cake.ID, Quantity: 10, Reason: store.WasteBroken})
+		assert.ErrorIs(t, err, ErrInsufficientStock)
+
+		stock, err := localStockStore.GetByProductID(cake.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, stock.Quantity)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+		report, err := service.Report(from, to, 0)
+		require.NoError(t, err)
+		require.Len(t, report.ByProduct, 2)
+		assert.Equal(t, bread.ID, report.ByProduct[0].ProductID)
+		assert.Equal(t, 1600.0, report.TotalValue)
+		require.Len(t, report.ByWeek, 1)
+		assert.Equal(t, 1600.0, report.ByWeek[0].Value)
+
+		report, err = service.Report(from, to, cake.ID)
+		require.NoError(t, err)
+		require.Len(t, report.ByWeek, 1)
+		assert.Equal(t, 2.0, report.ByWeek[0].Quantity)
+	})
+}
@@ -0,0 +1,378 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook no encontrado")
+	ErrWebhookNameEmpty        = errors.New("el nombre del webhook es obligatorio")
+	ErrWebhookInvalidURL       = errors.New("la URL debe ser http:// o https://")
+	ErrWebhookNoEvents         = errors.New("el webhook necesita al menos un evento")
+	ErrWebhookUnknownEvent     = errors.New("evento desconocido")
+	ErrWebhookDeliveryNotFound = errors.New("entrega no encontrada")
+)
+
+const (
+	// WebhookMaxAttempts is how many times a delivery is tried before it
+	// fails for good: 30 seconds after the first failure, then twice as long
+	// every time, about an hour in all.
+	WebhookMaxAttempts = 8
+	webhookRetryBase   = 30 * time.Second
+	webhookTimeout     = 10 * time.Second
+	// webhookLease is how long a claimed delivery is left to the worker
+	// sending it, well over webhookTimeout.
+	webhookLease = time.Minute
+	webhookBatch = 20
+	// webhookResponseLimit is how much of a response is kept in the log.
+	webhookResponseLimit = 1024
+)
+
+// Headers of the webhook requests.
+const (
+	WebhookEventHeader     = "X-Aesovoy-Event"
+	WebhookEventIDHeader   = "X-Aesovoy-Event-Id"
+	WebhookDeliveryHeader  = "X-Aesovoy-Delivery"
+	WebhookTimestampHeader = "X-Aesovoy-Timestamp"
+	WebhookSignatureHeader = "X-Aesovoy-Signature"
+)
+
+type WebhookRequest struct {
+	Name string `json:"name" example:"planilla de pedidos"`
+	URL  string `json:"url" example:"https://example.com/hooks/aesovoy"`
+	// Secret signs the payloads. One is generated when it's empty on create,
+	// and the current one is kept when it's empty on update.
+	Secret string   `json:"secret"`
+	Events []string `json:"events" example:"order.created,order.state_changed"`
+	// IsActive defaults to true.
+	IsActive *bool `json:"is_active"`
+}
+
+// WebhookService sends business events to the URLs other systems configure.
+// Events are queued in Postgres with a delivery per webhook, and DeliverDue
+// sends them, signed with the webhook's secret, retrying with backoff until
+// the receiver answers 2xx or WebhookMaxAttempts run out.
+type WebhookService struct {
+	store  store.WebhookStore
+	client *http.Client
+}
+
+func NewWebhookService(s store.WebhookStore) *WebhookService {
+	return &WebhookService{store: s, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// SignWebhook returns the signature header of a body sent at timestamp:
+// "sha256=" and the hex HMAC-SHA256, keyed with the secret, of the
+// timestamp, a dot and the body.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay is how long to wait after attempts failed.
+func webhookRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	return webhookRetryBase << (attempts - 1)
+}
+
+func (s *WebhookService) List() ([]*store.Webhook, error) {
+	hooks, err := s.store.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+func (s *WebhookService) Get(id int64) (*store.Webhook, error) {
+	hook, err := s.store.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook: %w", err)
+	}
+	if hook == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return hook, nil
+}
+
+func (s *WebhookService) Create(req WebhookRequest) (*store.Webhook, error) {
+	hook := &store.Webhook{}
+	if err := applyWebhookRequest(hook, req); err != nil {
+		return nil, err
+	}
+	if hook.Secret == "" {
+		secret, err := tokens.GenerateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		hook.Secret = secret
+	}
+	if err := s.store.Create(hook); err != nil {
+		return nil, fmt.Errorf("error creating webhook: %w", err)
+	}
+	return hook, nil
+}
+
+func (s *WebhookService) Update(id int64, req WebhookRequest) (*store.Webhook, error) {
+	hook, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyWebhookRequest(hook, req); err != nil {
+		return nil, err
+	}
+	if err := s.store.Update(hook); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("error updating webhook: %w", err)
+	}
+	return hook, nil
+}
+
+// applyWebhookRequest validates req into hook, keeping its secret when req
+// has none.
+func applyWebhookRequest(hook *store.Webhook, req WebhookRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return ErrWebhookNameEmpty
+	}
+
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrWebhookInvalidURL
+	}
+
+	wanted := map[string]bool{}
+	for _, e := range req.Events {
+		e = strings.TrimSpace(e)
+		if !store.IsWebhookEvent(e) {
+			return fmt.Errorf("%w: %s", ErrWebhookUnknownEvent, e)
+		}
+		wanted[e] = true
+	}
+	// Kept in catalog order.
+	events := []string{}
+	for _, e := range store.WebhookEventCatalog {
+		if wanted[e.Key] {
+			events = append(events, e.Key)
+		}
+	}
+	if len(events) == 0 {
+		return ErrWebhookNoEvents
+	}
+
+	hook.Name = name
+	hook.URL = u.String()
+	hook.Events = events
+	hook.IsActive = req.IsActive == nil || *req.IsActive
+	if secret := strings.TrimSpace(req.Secret); secret != "" {
+		hook.Secret = secret
+	}
+	return nil
+}
+
+func (s *WebhookService) Delete(id int64) error {
+	if err := s.store.Delete(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrWebhookNotFound
+		}
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	return nil
+}
+
+// Emit queues event with data for every active webhook that gets it. They
+// are sent by DeliverDue. Changes that tell about themselves queue their
+// event in their own transaction instead. A nil service emits nothing.
+func (s *WebhookService) Emit(event string, data any) error {
+	if s == nil {
+		return nil
+	}
+	if _, err := s.store.Enqueue(event, data); err != nil {
+		return fmt.Errorf("error queueing webhooks: %w", err)
+	}
+	return nil
+}
+
+// Test sends a ping to the webhook right away, active or not, and returns
+// the delivery with the result.
+func (s *WebhookService) Test(ctx context.Context, id int64) (*store.WebhookDelivery, error) {
+	hook, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	eventID, body, err := store.NewWebhookPayload(store.WebhookPing, map[string]any{"webhook_id": hook.ID, "name": hook.Name})
+	if err != nil {
+		return nil, err
+	}
+
+	// Queued as already claimed, so workers leave it alone.
+	d := &store.WebhookDelivery{
+		WebhookID:     hook.ID,
+		EventID:       eventID,
+		Event:         store.WebhookPing,
+		Payload:       body,
+		Status:        store.WebhookPending,
+		Attempts:      1,
+		NextAttemptAt: time.Now().Add(webhookLease),
+	}
+	if err := s.store.InsertDelivery(d); err != nil {
+		return nil, fmt.Errorf("error queueing webhook test: %w", err)
+	}
+	d.URL, d.Secret = hook.URL, hook.Secret
+	if err := s.attempt(ctx, d); err != nil {
+		if ctx.Err() != nil {
+			return nil, s.release(ctx, []*store.WebhookDelivery{d})
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// Redeliver queues the event of a delivery again, as a new delivery.
+func (s *WebhookService) Redeliver(deliveryID int64) (*store.WebhookDelivery, error) {
+	d, err := s.store.Redeliver(deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("error redelivering webhook: %w", err)
+	}
+	if d == nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return d, nil
+}
+
+func (s *WebhookService) Deliveries(f store.WebhookDeliveryFilter) ([]*store.WebhookDelivery, int, error) {
+	deliveries, total, err := s.store.ListDeliveries(f)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing webhook deliveries: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+// DeliverDue sends the deliveries that are due until none is left or ctx is
+// done, returning how many were attempted. Failed attempts are kept in the
+// deliveries, not returned; the ones ctx cut short are left due, and ctx's
+// error is returned.
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	sent := 0
+	for ctx.Err() == nil {
+		deliveries, err := s.store.ClaimDeliveries(webhookBatch, webhookLease)
+		if err != nil {
+			return sent, fmt.Errorf("error claiming webhook deliveries: %w", err)
+		}
+		for i, d := range deliveries {
+			if err := s.attempt(ctx, d); err != nil {
+				if ctx.Err() != nil {
+					return sent, s.release(ctx, deliveries[i:])
+				}
+				return sent, err
+			}
+			sent++
+		}
+		if len(deliveries) < webhookBatch {
+			break
+		}
+	}
+	return sent, nil
+}
+
+// attempt sends a claimed delivery and saves the result.
+func (s *WebhookService) attempt(ctx context.Context, d *store.WebhookDelivery) error {
+	start := time.Now()
+	status, body, err := s.send(ctx, d)
+	if ctx.Err() != nil {
+		// Cut short, e.g. by a shutdown: the receiver's answer is unknown,
+		// so the caller releases it.
+		return ctx.Err()
+	}
+	elapsed := int(time.Since(start).Milliseconds())
+	d.DurationMS = &elapsed
+	d.ResponseStatus = nil
+	if status != 0 {
+		d.ResponseStatus = &status
+	}
+	d.ResponseBody = body
+	d.LastError = ""
+
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		now := time.Now()
+		d.Status = store.WebhookDelivered
+		d.DeliveredAt = &now
+	default:
+		if err != nil {
+			d.LastError = err.Error()
+		} else {
+			d.LastError = fmt.Sprintf("respuesta %d", status)
+		}
+		d.Status = store.WebhookPending
+		d.NextAttemptAt = time.Now().Add(webhookRetryDelay(d.Attempts))
+		if d.Attempts >= WebhookMaxAttempts {
+			d.Status = store.WebhookFailed
+		}
+	}
+
+	if err := s.store.FinishDelivery(d); err != nil {
+		return fmt.Errorf("error saving webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// release leaves claimed deliveries due again without counting the attempt
+// ctx cut short, and returns ctx's error.
+func (s *WebhookService) release(ctx context.Context, deliveries []*store.WebhookDelivery) error {
+	ids := make([]int64, len(deliveries))
+	for i, d := range deliveries {
+		ids[i] = d.ID
+	}
+	if err := s.store.ReleaseDeliveries(ids); err != nil {
+		return fmt.Errorf("error releasing webhook deliveries: %w", err)
+	}
+	return ctx.Err()
+}
+
+// send POSTs the delivery's payload, returning the response status and the
+// start of its body.
+func (s *WebhookService) send(ctx context.Context, d *store.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "aesovoy-webhooks")
+	req.Header.Set(WebhookEventHeader, d.Event)
+	req.Header.Set(WebhookEventIDHeader, d.EventID)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(d.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	return resp.StatusCode, strings.ToValidUTF8(string(body), ""), nil
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// echo -n '1700000000.{"a":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=49f24e537407743fa4a0242bb63b94b9a47ee99cbbe071ccd8a22550ae411686",
+		SignWebhook("secret", 1700000000, []byte(`{"a":1}`)))
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, time.Minute, webhookRetryDelay(2))
+	assert.Equal(t, 4*time.Minute, webhookRetryDelay(4))
+}
+
+// webhookReceiver is a local HTTP receiver that checks signatures and
+// answers with the status it is told.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	secret   string
+	status   int
+	received []store.WebhookPayload
+	headers  []http.Header
+	badSigs  int
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	ts, _ := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if r.Header.Get(WebhookSignatureHeader) != SignWebhook(rc.secret, ts, body) {
+		rc.badSigs++
+	}
+	var p store.WebhookPayload
+	_ = json.Unmarshal(body, &p)
+	rc.received = append(rc.received, p)
+	rc.headers = append(rc.headers, r.Header.Clone())
+	w.WriteHeader(rc.status)
+	_, _ = w.Write([]byte("ok"))
+}
+
+func TestWebhookService(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	webhookStore := store.NewPostgresWebhookStore(db)
+	service := NewWebhookService(webhookStore)
+	ctx := context.Background()
+
+	receiver := &webhookReceiver{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(receiver)
+	defer srv.Close()
+
+	_, err := service.Create(WebhookRequest{Name: "x", URL: "ftp://example.com", Events: []string{store.WebhookOrderCreated}})
+	assert.ErrorIs(t, err, ErrWebhookInvalidURL)
+	_, err = service.Create(WebhookRequest{Name: "x", URL: srv.URL, Events: []string{"order.deleted"}})
+	assert.ErrorIs(t, err, ErrWebhookUnknownEvent)
+	_, err = service.Create(WebhookRequest{Name: "x", URL: srv.URL})
+	assert.ErrorIs(t, err, ErrWebhookNoEvents)
+
+	hook, err := service.Create(WebhookRequest{Name: "pedidos", URL: srv.URL, Events: []string{store.WebhookOrderCreated}})
+	require.NoError(t, err)
+	assert.True(t, hook.IsActive)
+	assert.NotEmpty(t, hook.Secret)
+	receiver.secret = hook.Secret
+
+	// Not subscribed: nothing is queued.
+	require.NoError(t, service.Emit(store.WebhookLocalSaleCreated, map[string]any{"id": 1}))
+	sent, err := service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	require.NoError(t, service.Emit(store.WebhookOrderCreated, map[string]any{"id": 7}))
+	sent, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	// The receiver failed: the delivery waits for its retry.
+	deliveries, _, err := service.Deliveries(store.WebhookDeliveryFilter{WebhookID: &hook.ID})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	d := deliveries[0]
+	assert.Equal(t, store.WebhookPending, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	require.NotNil(t, d.ResponseStatus)
+	assert.Equal(t, http.StatusInternalServerError, *d.ResponseStatus)
+	assert.True(t, d.NextAttemptAt.After(time.Now().Add(20*time.Second)))
+
+	sent, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, sent, "not due yet")
+
+	// Due now, and the receiver is back.
+	_, err = db.Exec(`UPDATE webhook_deliveries SET next_attempt_at = NOW() WHERE id = $1`, d.ID)
+	require.NoError(t, err)
+	receiver.status = http.StatusOK
+	sent, err = service.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	deliveries, _, err = service.Deliveries(store.WebhookDeliveryFilter{WebhookID: &hook.ID})
+	require.NoError(t, err)
+	assert.Equal(t, store.WebhookDelivered, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, "ok", deliveries[0].ResponseBody)
+
+	require.Len(t, receiver.received, 2)
+	assert.Zero(t, receiver.badSigs)
+	assert.Equal(t, store.WebhookOrderCreated, receiver.received[1].Event)
+	assert.Equal(t, receiver.received[0].ID, receiver.received[1].ID, "retries keep the event id")
+	assert.Equal(t, store.WebhookOrderCreated, receiver.headers[1].Get(WebhookEventHeader))
+
+	// The test button pings right away, even a paused webhook.
+	inactive := false
+	_, err = service.Update(hook.ID, WebhookRequest{Name: "pedidos", URL: srv.URL, Events: []string{store.WebhookOrderCreated}, IsActive: &inactive})
+	require.NoError(t, err)
+	ping, err := service.Test(ctx, hook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.WebhookDelivered, ping.Status)
+	assert.Equal(t, store.WebhookPing, receiver.received[2].Event)
+
+	_, err = service.Test(ctx, 999999)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestWebhookServiceShutdown(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	service := NewWebhookService(store.NewPostgresWebhookStore(db))
+
+	// A slow receiver, still answering when the worker is stopped.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	hook, err := service.Create(WebhookRequest{Name: "lento", URL: srv.URL, Events: []string{store.WebhookOrderCreated}})
+	require.NoError(t, err)
+	require.NoError(t, service.Emit(store.WebhookOrderCreated, map[string]any{"id": 1}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	sent, err := service.DeliverDue(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Zero(t, sent)
+
+	// Left due, without counting the attempt.
+	deliveries, _, err := service.Deliveries(store.WebhookDeliveryFilter{WebhookID: &hook.ID})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, store.WebhookPending, deliveries[0].Status)
+	assert.Zero(t, deliveries[0].Attempts)
+	assert.Empty(t, deliveries[0].LastError)
+	assert.False(t, deliveries[0].NextAttemptAt.After(time.Now()))
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"database/sql"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// APIKey is a long-lived credential for integrations. It acts as the user
+// who created it, limited to its scopes.
+type APIKey struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Prefix   string `json:"prefix"`
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	// Scopes are the permissions the key grants, out of the ones its user's
+	// role has.
+	Scopes []string `json:"scopes"`
+	// AllowedIPs are the IPs and CIDR ranges the key can be used from; any
+	// when empty.
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// Has reports whether the key grants scope.
+func (k *APIKey) Has(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key is neither revoked nor expired.
+func (k *APIKey) Active() bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(time.Now()))
+}
+
+// AllowsIP reports whether the key can be used from ip.
+func (k *APIKey) AllowsIP(ip string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, allowed := range k.AllowedIPs {
+		if prefix, err := netip.ParsePrefix(allowed); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if a, err := netip.ParseAddr(allowed); err == nil && a.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKeyStore interface {
+	// Create stores the key with the hash of its secret.
+	Create(key *APIKey, keyHash []byte) error
+	// List returns every key, revoked ones included, newest first.
+	List() ([]*APIKey, error)
+	GetByID(id int64) (*APIKey, error)
+	// Revoke disables a key for good. sql.ErrNoRows when there is no such
+	// active key.
+	Revoke(id int64) error
+	// GetUserAPIKey returns the user an active key acts as, with User.APIKey
+	// set and only the role permissions the key has as scopes. nil when
+	// there is no such key.
+	GetUserAPIKey(keyHash []byte) (*User, error)
+	// Touch records a use of the key from ip, at most once every minute.
+	Touch(id int64, ip string) error
+}
+
+type PostgresAPIKeyStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAPIKeyStore(db *sql.DB) *PostgresAPIKeyStore {
+	return &PostgresAPIKeyStore{db: db}
+}
+
+func (s *PostgresAPIKeyStore) Create(key *APIKey, keyHash []byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+	INSERT INTO api_keys (name, prefix, key_hash, user_id, allowed_ips, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`,
+		key.Name, key.Prefix, keyHash, key.UserID, strings.Join(key.AllowedIPs, ","), key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return err
+	}
+	for _, scope := range key.Scopes {
+		_, err := tx.Exec(`
+		INSERT INTO api_key_scopes (api_key_id, permission) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, key.ID, scope)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const apiKeyColumns = `
+	k.id, k.name, k.prefix, k.user_id, u.username, k.allowed_ips, k.expires_at,
+	k.last_used_at, k.last_used_ip, k.created_at, k.revoked_at,
+	COALESCE((SELECT string_agg(s.permission, ',' ORDER BY s.permission)
+	          FROM api_key_scopes s WHERE s.api_key_id = k.id), '')`
+
+const apiKeyQuery = `SELECT ` + apiKeyColumns + `
+	FROM api_keys k
+	INNER JOIN users u ON u.id = k.user_id`
+
+func scanAPIKey(row interface{ Scan(...any) error }, extra ...any) (*APIKey, error) {
+	k := &APIKey{}
+	var allowedIPs, scopes string
+	dest := []any{&k.ID, &k.Name, &k.Prefix, &k.UserID, &k.Username, &allowedIPs, &k.ExpiresAt,
+		&k.LastUsedAt, &k.LastUsedIP, &k.CreatedAt, &k.RevokedAt, &scopes}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	k.Scopes = splitPermissions(scopes)
+	k.AllowedIPs = []string{}
+	if allowedIPs != "" {
+		k.AllowedIPs = strings.Split(allowedIPs, ",")
+	}
+	return k, nil
+}
+
+func (s *PostgresAPIKeyStore) List() ([]*APIKey, error) {
+	rows, err := s.db.Query(apiKeyQuery + ` ORDER BY k.created_at DESC, k.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *PostgresAPIKeyStore) GetByID(id int64) (*APIKey, error) {
+	k, err := scanAPIKey(s.db.QueryRow(apiKeyQuery+` WHERE k.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return k, err
+}
+
+func (s *PostgresAPIKeyStore) Revoke(id int64) error {
+	return expectOneRow(s.db.Exec(`UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`, id))
+}
+
+func (s *PostgresAPIKeyStore) GetUserAPIKey(keyHash []byte) (*User, error) {
+	query := `SELECT ` + apiKeyColumns + `,
+	       u.email, u.role, u.is_active, u.created_at,
+	       COALESCE((SELECT string_agg(rp.permission, ',')
+	                 FROM role_permissions rp
+	                 INNER JOIN roles r ON r.id = rp.role_id
+	                 WHERE r.name = u.role), '')
+	FROM api_keys k
+	INNER JOIN users u ON u.id = k.user_id
+	WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+	  AND (k.expires_at IS NULL OR k.expires_at > NOW())
+	  AND u.deleted_at IS NULL`
+
+	user := &User{PasswordHash: Password{}}
+	var permissions string
+	key, err := scanAPIKey(s.db.QueryRow(query, keyHash),
+		&user.Email, &user.Role, &user.IsActive, &user.CreatedAt, &permissions)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user.ID = key.UserID
+	user.Username = key.Username
+	user.APIKey = key
+	user.Permissions = []string{}
+	for _, p := range splitPermissions(permissions) {
+		if key.Has(p) {
+			user.Permissions = append(user.Permissions, p)
+		}
+	}
+	return user, nil
+}
+
+func (s *PostgresAPIKeyStore) Touch(id int64, ip string) error {
+	_, err := s.db.Exec(`
+	UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute' OR last_used_ip <> $2)`, id, ip)
+	return err
+}
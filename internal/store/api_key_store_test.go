@@ -0,0 +1,91 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKey_AllowsIP(t *testing.T) {
+	open := &APIKey{AllowedIPs: []string{}}
+	assert.True(t, open.AllowsIP("198.51.100.7"))
+
+	k := &APIKey{AllowedIPs: []string{"203.0.113.10", "10.0.0.0/8", "2001:db8::/32"}}
+	assert.True(t, k.AllowsIP("203.0.113.10"))
+	assert.True(t, k.AllowsIP("10.20.30.40"))
+	assert.True(t, k.AllowsIP("::ffff:10.1.1.1"))
+	assert.True(t, k.AllowsIP("2001:db8::1"))
+	assert.False(t, k.AllowsIP("203.0.113.11"))
+	assert.False(t, k.AllowsIP("not an ip"))
+}
+
+func TestAPIKeyStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	us := NewPostgresUserStore(db)
+	s := NewPostgresAPIKeyStore(db)
+
+	// The employee role grants sales.view and sales.create, not orders.manage.
+	user := &User{Username: "integrador", Email: "integrador@example.com", Role: "employee"}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, us.CreateUser(user))
+
+	plaintext, hash, err := tokens.GenerateAPIKey()
+	require.NoError(t, err)
+	key := &APIKey{
+		Name:       "tienda online",
+		Prefix:     plaintext[:11],
+		UserID:     user.ID,
+		Scopes:     []string{PermSalesView, PermOrdersManage},
+		AllowedIPs: []string{"10.0.0.0/8"},
+	}
+	require.NoError(t, s.Create(key, hash))
+	assert.NotZero(t, key.ID)
+
+	got, err := s.GetUserAPIKey(tokens.Hash(plaintext))
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, user.ID, got.ID)
+	require.NotNil(t, got.APIKey)
+	assert.Equal(t, key.ID, got.APIKey.ID)
+	assert.Equal(t, []string{"10.0.0.0/8"}, got.APIKey.AllowedIPs)
+	assert.True(t, got.Can(PermSalesView))
+	assert.False(t, got.Can(PermSalesCreate), "only the key's scopes")
+	assert.False(t, got.Can(PermOrdersManage), "only scopes the role has")
+
+	require.NoError(t, s.Touch(key.ID, "10.0.0.1"))
+	listed, err := s.GetByID(key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, listed.LastUsedAt)
+	assert.Equal(t, "10.0.0.1", listed.LastUsedIP)
+
+	missing, err := s.GetUserAPIKey(tokens.Hash("aesk_nope"))
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	require.NoError(t, s.Revoke(key.ID))
+	assert.ErrorIs(t, s.Revoke(key.ID), sql.ErrNoRows)
+	got, err = s.GetUserAPIKey(tokens.Hash(plaintext))
+	require.NoError(t, err)
+	assert.Nil(t, got, "revoked keys don't authenticate")
+
+	// Expired keys don't either, but are still listed.
+	plaintext, hash, err = tokens.GenerateAPIKey()
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, s.Create(&APIKey{Name: "vieja", Prefix: plaintext[:11], UserID: user.ID, Scopes: []string{PermSalesView}, ExpiresAt: &past}, hash))
+	got, err = s.GetUserAPIKey(tokens.Hash(plaintext))
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	keys, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, "vieja", keys[0].Name)
+	assert.False(t, keys[0].Active())
+	assert.Equal(t, []string{PermOrdersManage, PermSalesView}, keys[1].Scopes)
+}
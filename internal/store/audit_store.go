@@ -0,0 +1,190 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Audited entities.
+const (
+	AuditProduct          = "product"
+	AuditProductRecipe    = "product_ingredient" // entity_id is the product
+	AuditCategory         = "category"
+	AuditIngredient       = "ingredient"
+	AuditClient           = "client"
+	AuditProvider         = "provider"
+	AuditProviderCategory = "provider_category"
+	AuditOrder            = "order"
+	AuditPaymentMethod    = "payment_method"
+	AuditExpense          = "expense"
+	AuditExpenseCategory  = "expense_category"
+	AuditLocalSale        = "local_sale"
+	AuditLocalStock       = "local_stock"
+	AuditUser             = "user"
+	AuditRole             = "role"
+	AuditCashRegister     = "cash_register"
+	AuditStockLocation    = "stock_location"
+	AuditStockTransfer    = "stock_transfer"
+	AuditWaste            = "waste"
+	AuditInventoryCount   = "inventory_count"
+	AuditPurchaseOrder    = "purchase_order"
+	AuditProviderPayment  = "provider_payment"
+	AuditAPIKey           = "api_key"
+	AuditWebhook          = "webhook"
+	AuditJob              = "job"
+)
+
+// Audit actions. Entities with a state record their transitions with the
+// action named after it (e.g. "approve", "cancel").
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditState  = "state"
+)
+
+// AuditEntry is one change to an entity. Before and After hold the whole
+// entity on create and delete and only the changed fields on update.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	UserID    *int64          `json:"user_id"`
+	Username  string          `json:"username"`
+	APIKeyID  *int64          `json:"api_key_id"` // the key the change was made with, if any
+	Entity    string          `json:"entity"`
+	EntityID  int64           `json:"entity_id"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	RequestID string          `json:"request_id"`
+	IP        string          `json:"ip"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type AuditFilter struct {
+	Entity    string
+	EntityID  *int64
+	UserID    *int64
+	Action    string
+	RequestID string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+}
+
+// AuditStore only appends: the table rejects updates and deletes.
+type AuditStore interface {
+	Insert(e *AuditEntry) error
+	// List returns the entries matching the filter newest first, with the
+	// total number of matches.
+	List(f AuditFilter) ([]*AuditEntry, int, error)
+}
+
+type PostgresAuditStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditStore(db *sql.DB) *PostgresAuditStore {
+	return &PostgresAuditStore{db: db}
+}
+
+func (s *PostgresAuditStore) Insert(e *AuditEntry) error {
+	query := `
+	INSERT INTO audit_log (user_id, username, api_key_id, entity, entity_id, action, before, after, request_id, ip)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id, created_at`
+
+	var userID, apiKeyID int64
+	if e.UserID != nil {
+		userID = *e.UserID
+	}
+	if e.APIKeyID != nil {
+		apiKeyID = *e.APIKeyID
+	}
+	return s.db.QueryRow(query, nullInt64(userID), e.Username, nullInt64(apiKeyID), e.Entity, e.EntityID, e.Action,
+		nullJSON(e.Before), nullJSON(e.After), e.RequestID, e.IP).Scan(&e.ID, &e.CreatedAt)
+}
+
+func nullJSON(v json.RawMessage) sql.NullString {
+	return nullString(string(v))
+}
+
+func (s *PostgresAuditStore) List(f AuditFilter) ([]*AuditEntry, int, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	where := "WHERE TRUE"
+	args := []any{}
+
+	if f.Entity != "" {
+		where += fmt.Sprintf(" AND entity=$%d", len(args)+1)
+		args = append(args, f.Entity)
+	}
+	if f.EntityID != nil {
+		where += fmt.Sprintf(" AND entity_id=$%d", len(args)+1)
+		args = append(args, *f.EntityID)
+	}
+	if f.UserID != nil {
+		where += fmt.Sprintf(" AND user_id=$%d", len(args)+1)
+		args = append(args, *f.UserID)
+	}
+	if f.Action != "" {
+		where += fmt.Sprintf(" AND action=$%d", len(args)+1)
+		args = append(args, f.Action)
+	}
+	if f.RequestID != "" {
+		where += fmt.Sprintf(" AND request_id=$%d", len(args)+1)
+		args = append(args, f.RequestID)
+	}
+	if f.From != nil {
+		where += fmt.Sprintf(" AND created_at >= $%d", len(args)+1)
+		args = append(args, *f.From)
+	}
+	if f.To != nil {
+		where += fmt.Sprintf(" AND created_at < $%d", len(args)+1)
+		args = append(args, *f.To)
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM audit_log `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	q := `
+	SELECT id, user_id, username, api_key_id, entity, entity_id, action, before, after, request_id, ip, created_at
+	FROM audit_log ` + where + fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var out []*AuditEntry
+	for rows.Next() {
+		e := &AuditEntry{}
+		var userID, apiKeyID sql.NullInt64
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &userID, &e.Username, &apiKeyID, &e.Entity, &e.EntityID, &e.Action,
+			&before, &after, &e.RequestID, &e.IP, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if userID.Valid {
+			e.UserID = &userID.Int64
+		}
+		if apiKeyID.Valid {
+			e.APIKeyID = &apiKeyID.Int64
+		}
+		e.Before = before
+		e.After = after
+		out = append(out, e)
+	}
+	return out, total, rows.Err()
+}
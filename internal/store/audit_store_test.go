@@ -0,0 +1,70 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditStore_InsertAndList(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	s := NewPostgresAuditStore(db)
+
+	// The log is never truncated, each run filters on its own request ID.
+	reqID := fmt.Sprintf("audit-test-%d", time.Now().UnixNano())
+	userID := int64(7)
+
+	created := &AuditEntry{UserID: &userID, Username: "admin", Entity: AuditProduct, EntityID: 1, Action: AuditCreate,
+		After: json.RawMessage(`{"name":"Alfajor"}`), RequestID: reqID, IP: "10.0.0.1"}
+	require.NoError(t, s.Insert(created))
+	assert.NotZero(t, created.ID)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	updated := &AuditEntry{Entity: AuditProduct, EntityID: 1, Action: AuditUpdate,
+		Before: json.RawMessage(`{"name":"Alfajor"}`), After: json.RawMessage(`{"name":"Alfajor triple"}`), RequestID: reqID}
+	require.NoError(t, s.Insert(updated))
+	require.NoError(t, s.Insert(&AuditEntry{Entity: AuditOrder, EntityID: 2, Action: "cancel", RequestID: reqID}))
+
+	entries, total, err := s.List(AuditFilter{RequestID: reqID})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, entries, 3)
+	assert.Equal(t, AuditOrder, entries[0].Entity, "newest first")
+	assert.Nil(t, entries[0].Before)
+	assert.Nil(t, entries[0].UserID)
+
+	entries, total, err = s.List(AuditFilter{RequestID: reqID, Entity: AuditProduct, Action: AuditUpdate})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, entries, 1)
+	assert.JSONEq(t, `{"name":"Alfajor"}`, string(entries[0].Before))
+	assert.JSONEq(t, `{"name":"Alfajor triple"}`, string(entries[0].After))
+
+	entries, _, err = s.List(AuditFilter{RequestID: reqID, UserID: &userID})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "admin", entries[0].Username)
+	assert.Equal(t, "10.0.0.1", entries[0].IP)
+
+	entries, total, err = s.List(AuditFilter{RequestID: reqID, Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, entries, 1)
+	assert.Equal(t, updated.ID, entries[0].ID)
+
+	from := time.Now().Add(time.Hour)
+	_, total, err = s.List(AuditFilter{RequestID: reqID, From: &from})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	// The log is append-only.
+	_, err = db.Exec(`UPDATE audit_log SET action = 'delete' WHERE id = $1`, created.ID)
+	assert.Error(t, err)
+	_, err = db.Exec(`DELETE FROM audit_log WHERE id = $1`, created.ID)
+	assert.Error(t, err)
+}
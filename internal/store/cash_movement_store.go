@@ -15,6 +15,7 @@ const (
 type CashMovement struct {
 	ID        int64            `json:"id"`
 	ShiftID   int64            `json:"shift_id"`
+	UserID    *int64           `json:"user_id"`
 	Amount    float64          `json:"amount"`
 	Type      CashMovementType `json:"type"`
 	Reason    string           `json:"reason"`
@@ -37,15 +38,15 @@ func NewPostgresCashMovementStore(db *sql.DB) *PostgresCashMovementStore {
 
 func (s *PostgresCashMovementStore) Create(m *CashMovement) error {
 	const q = `
-	INSERT INTO cash_movements (shift_id, amount, type, reason)
-	VALUES ($1, $2, $3, $4)
+	INSERT INTO cash_movements (shift_id, user_id, amount, type, reason)
+	VALUES ($1, $2, $3, $4, $5)
 	RETURNING id, created_at`
-	return s.db.QueryRow(q, m.ShiftID, m.Amount, m.Type, m.Reason).Scan(&m.ID, &m.CreatedAt)
+	return s.db.QueryRow(q, m.ShiftID, m.UserID, m.Amount, m.Type, m.Reason).Scan(&m.ID, &m.CreatedAt)
 }
 
 func (s *PostgresCashMovementStore) ListByShiftID(shiftID int64) ([]*CashMovement, error) {
 	const q = `
-	SELECT id, shift_id, amount, type, reason, created_at
+	SELECT id, shift_id, user_id, amount, type, reason, created_at
 	FROM cash_movements
 	WHERE shift_id = $1
 	ORDER BY created_at DESC`
@@ -59,7 +60,7 @@ func (s *PostgresCashMovementStore) ListByShiftID(shiftID int64) ([]*CashMovemen
 	var list []*CashMovement
 	for rows.Next() {
 		m := &CashMovement{}
-		if err := rows.Scan(&m.ID, &m.ShiftID, &m.Amount, &m.Type, &m.Reason, &m.CreatedAt); err != nil {
+		if err := rows.Scan(&m.ID, &m.ShiftID, &m.UserID, &m.Amount, &m.Type, &m.Reason, &m.CreatedAt); err != nil {
 			return nil, err
 		}
 		list = append(list, m)
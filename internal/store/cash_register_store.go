@@ -0,0 +1,95 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+type CashRegister struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// LocationID is the stock location the register sells from; zero on
+	// create means the default location.
+	LocationID   int64     `json:"location_id"`
+	LocationName string    `json:"location_name,omitempty"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type CashRegisterStore interface {
+	Create(register *CashRegister) error
+	Update(register *CashRegister) error
+	GetByID(id int64) (*CashRegister, error)
+	List(activeOnly bool) ([]*CashRegister, error)
+}
+
+type PostgresCashRegisterStore struct {
+	db *sql.DB
+}
+
+func NewPostgresCashRegisterStore(db *sql.DB) *PostgresCashRegisterStore {
+	return &PostgresCashRegisterStore{db: db}
+}
+
+func (s *PostgresCashRegisterStore) Create(register *CashRegister) error {
+	q := `
+	INSERT INTO cash_registers (name, location_id, is_active)
+	VALUES ($1, ` + locationSQL("$2") + `, $3)
+	RETURNING id, location_id, created_at, updated_at`
+	return s.db.QueryRow(q, register.Name, nullInt64(register.LocationID), register.IsActive).
+		Scan(&register.ID, &register.LocationID, &register.CreatedAt, &register.UpdatedAt)
+}
+
+func (s *PostgresCashRegisterStore) Update(register *CashRegister) error {
+	q := `
+	UPDATE cash_registers
+	SET name = $1, location_id = ` + locationSQL("$2") + `, is_active = $3, updated_at = NOW()
+	WHERE id = $4
+	RETURNING location_id, updated_at`
+	return s.db.QueryRow(q, register.Name, nullInt64(register.LocationID), register.IsActive, register.ID).
+		Scan(&register.LocationID, &register.UpdatedAt)
+}
+
+func (s *PostgresCashRegisterStore) GetByID(id int64) (*CashRegister, error) {
+	const q = `
+	SELECT cr.id, cr.name, cr.location_id, l.name, cr.is_active, cr.created_at, cr.updated_at
+	FROM cash_registers cr
+	JOIN stock_locations l ON l.id = cr.location_id
+	WHERE cr.id = $1`
+
+	var cr CashRegister
+	err := s.db.QueryRow(q, id).Scan(&cr.ID, &cr.Name, &cr.LocationID, &cr.LocationName, &cr.IsActive, &cr.CreatedAt, &cr.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cr, nil
+}
+
+func (s *PostgresCashRegisterStore) List(activeOnly bool) ([]*CashRegister, error) {
+	const q = `
+	SELECT cr.id, cr.name, cr.location_id, l.name, cr.is_active, cr.created_at, cr.updated_at
+	FROM cash_registers cr
+	JOIN stock_locations l ON l.id = cr.location_id
+	WHERE ($1 = FALSE OR cr.is_active = TRUE)
+	ORDER BY cr.name`
+
+	rows, err := s.db.Query(q, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*CashRegister
+	for rows.Next() {
+		cr := &CashRegister{}
+		if err := rows.Scan(&cr.ID, &cr.Name, &cr.LocationID, &cr.LocationName, &cr.IsActive, &cr.CreatedAt, &cr.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, cr)
+	}
+	return list, rows.Err()
+}
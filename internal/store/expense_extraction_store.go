@@ -0,0 +1,161 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+type ExtractionStatus string
+
+const (
+	ExtractionPending   ExtractionStatus = "pending"
+	ExtractionConfirmed ExtractionStatus = "confirmed"
+	ExtractionDiscarded ExtractionStatus = "discarded"
+)
+
+// ExpenseExtraction is what OCR read from an uploaded receipt and the expense
+// fields proposed from it. Once an expense is loaded from it, it is confirmed
+// and points to the expense.
+type ExpenseExtraction struct {
+	ID            int64            `json:"id"`
+	ImagePath     string           `json:"image_path"`
+	Engine        string           `json:"engine"`
+	RawText       string           `json:"raw_text"`
+	Error         string           `json:"error,omitempty"`
+	Amount        *float64         `json:"amount"`
+	Date          *time.Time       `json:"date"`
+	ProviderCUIT  string           `json:"provider_cuit"`
+	ProviderID    *int64           `json:"provider_id"`
+	ProviderName  string           `json:"provider_name,omitempty"`
+	InvoiceNumber string           `json:"invoice_number"`
+	InvoiceType   InvoiceType      `json:"invoice_type"`
+	Status        ExtractionStatus `json:"status"`
+	ExpenseID     *int64           `json:"expense_id"`
+	// ExpenseAmount is the amount of the expense finally loaded, to compare
+	// with the proposed one.
+	ExpenseAmount *string    `json:"expense_amount,omitempty"`
+	UserID        *int64     `json:"user_id"`
+	Username      string     `json:"username,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+}
+
+type ExpenseExtractionStore interface {
+	Create(e *ExpenseExtraction) error
+	GetByID(id int64) (*ExpenseExtraction, error)
+	// List returns extractions newest first, of one status when given.
+	List(status *ExtractionStatus, limit int) ([]*ExpenseExtraction, error)
+	// LockTx locks a pending extraction for loading its expense. It returns
+	// nil when the extraction doesn't exist or was already reviewed.
+	LockTx(tx *sql.Tx, id int64) (*ExpenseExtraction, error)
+	ConfirmTx(tx *sql.Tx, id, expenseID int64) error
+	// Discard marks a pending extraction as discarded.
+	Discard(id int64) error
+}
+
+type PostgresExpenseExtractionStore struct {
+	db *sql.DB
+}
+
+func NewPostgresExpenseExtractionStore(db *sql.DB) *PostgresExpenseExtractionStore {
+	return &PostgresExpenseExtractionStore{db: db}
+}
+
+func (s *PostgresExpenseExtractionStore) Create(e *ExpenseExtraction) error {
+	query := `
+	INSERT INTO expense_extractions (image_path, engine, raw_text, error, amount, date, provider_cuit, provider_id,
+	                                 invoice_number, invoice_type, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	RETURNING id, status, created_at`
+
+	var userID int64
+	if e.UserID != nil {
+		userID = *e.UserID
+	}
+	return s.db.QueryRow(query, e.ImagePath, e.Engine, e.RawText, e.Error, e.Amount, e.Date, e.ProviderCUIT, e.ProviderID,
+		e.InvoiceNumber, e.InvoiceType, nullInt64(userID)).Scan(&e.ID, &e.Status, &e.CreatedAt)
+}
+
+const expenseExtractionQuery = `
+	SELECT x.id, x.image_path, x.engine, x.raw_text, x.error, x.amount, x.date, x.provider_cuit, x.provider_id,
+	       COALESCE(p.name, ''), x.invoice_number, x.invoice_type, x.status, x.expense_id, e.amount::text,
+	       x.user_id, COALESCE(u.username, ''), x.created_at, x.reviewed_at
+	FROM expense_extractions x
+	LEFT JOIN providers p ON p.id = x.provider_id
+	LEFT JOIN expenses e ON e.id = x.expense_id
+	LEFT JOIN users u ON u.id = x.user_id`
+
+func scanExpenseExtraction(row interface{ Scan(...any) error }) (*ExpenseExtraction, error) {
+	e := &ExpenseExtraction{}
+	err := row.Scan(&e.ID, &e.ImagePath, &e.Engine, &e.RawText, &e.Error, &e.Amount, &e.Date, &e.ProviderCUIT,
+		&e.ProviderID, &e.ProviderName, &e.InvoiceNumber, &e.InvoiceType, &e.Status, &e.ExpenseID, &e.ExpenseAmount,
+		&e.UserID, &e.Username, &e.CreatedAt, &e.ReviewedAt)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *PostgresExpenseExtractionStore) GetByID(id int64) (*ExpenseExtraction, error) {
+	e, err := scanExpenseExtraction(s.db.QueryRow(expenseExtractionQuery+` WHERE x.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return e, err
+}
+
+func (s *PostgresExpenseExtractionStore) List(status *ExtractionStatus, limit int) ([]*ExpenseExtraction, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var st *string
+	if status != nil {
+		v := string(*status)
+		st = &v
+	}
+	rows, err := s.db.Query(expenseExtractionQuery+`
+	WHERE ($1::text IS NULL OR x.status = $1)
+	ORDER BY x.created_at DESC, x.id DESC
+	LIMIT $2`, st, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*ExpenseExtraction
+	for rows.Next() {
+		e, err := scanExpenseExtraction(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (s *PostgresExpenseExtractionStore) LockTx(tx *sql.Tx, id int64) (*ExpenseExtraction, error) {
+	e := &ExpenseExtraction{}
+	err := tx.QueryRow(`
+	SELECT id, image_path, status FROM expense_extractions
+	WHERE id = $1 AND status = 'pending'
+	FOR UPDATE`, id).Scan(&e.ID, &e.ImagePath, &e.Status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *PostgresExpenseExtractionStore) ConfirmTx(tx *sql.Tx, id, expenseID int64) error {
+	return expectOneRow(tx.Exec(`
+	UPDATE expense_extractions SET status = 'confirmed', expense_id = $1, reviewed_at = NOW()
+	WHERE id = $2 AND status = 'pending'`, expenseID, id))
+}
+
+func (s *PostgresExpenseExtractionStore) Discard(id int64) error {
+	return expectOneRow(s.db.Exec(`
+	UPDATE expense_extractions SET status = 'discarded', reviewed_at = NOW()
+	WHERE id = $1 AND status = 'pending'`, id))
+}
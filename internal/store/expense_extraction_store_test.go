@@ -0,0 +1,75 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpenseExtractionStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	db.Exec("INSERT INTO provider_categories (id, name) VALUES (1, 'Sin Categoría') ON CONFLICT (id) DO NOTHING")
+
+	s := NewPostgresExpenseExtractionStore(db)
+	providerStore := NewPostgresProviderStore(db)
+	expenseStore := NewPostgresExpenseStore(db)
+
+	provider := &Provider{Name: "Molino", Reference: "ref-ocr", CUIT: "30-71234567-1"}
+	require.NoError(t, providerStore.CreateProvider(provider))
+
+	found, err := providerStore.GetProviderByCUIT("30712345671")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, provider.ID, found.ID)
+	missing, err := providerStore.GetProviderByCUIT("20123456786")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	amount := 1210.0
+	date := time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC)
+	extraction := &ExpenseExtraction{ImagePath: "uploads/expenses/r.jpg", Engine: "tesseract", RawText: "TOTAL 1.210,00",
+		Amount: &amount, Date: &date, ProviderCUIT: "30712345671", ProviderID: &provider.ID, InvoiceNumber: "0001-00000001", InvoiceType: InvoiceTypeA}
+	require.NoError(t, s.Create(extraction))
+	assert.Equal(t, ExtractionPending, extraction.Status)
+
+	got, err := s.GetByID(extraction.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "Molino", got.ProviderName)
+	assert.Equal(t, 1210.0, *got.Amount)
+	assert.Nil(t, got.ExpenseID)
+
+	category := &ExpenseCategory{Name: "Insumos OCR"}
+	require.NoError(t, expenseStore.CreateExpenseCategory(category))
+	expense := &Expense{Amount: "1210.00", PaidAmount: "1210.00", CategoryID: category.ID, Type: ExpenseTypeProduction, Date: date, ProviderID: &provider.ID}
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	locked, err := s.LockTx(tx, extraction.ID)
+	require.NoError(t, err)
+	require.NotNil(t, locked)
+	require.NoError(t, expenseStore.CreateExpenseTx(tx, expense))
+	require.NoError(t, s.ConfirmTx(tx, extraction.ID, expense.ID))
+	require.NoError(t, tx.Commit())
+
+	got, err = s.GetByID(extraction.ID)
+	require.NoError(t, err)
+	assert.Equal(t, ExtractionConfirmed, got.Status)
+	require.NotNil(t, got.ExpenseAmount)
+	assert.Equal(t, "1210.00", *got.ExpenseAmount)
+	assert.NotNil(t, got.ReviewedAt)
+
+	assert.Error(t, s.Discard(extraction.ID), "only pending extractions are discarded")
+
+	pending := ExtractionPending
+	list, err := s.List(&pending, 0)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	list, err = s.List(nil, 0)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
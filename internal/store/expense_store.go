@@ -2,8 +2,11 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type ExpenseType string
@@ -13,6 +16,35 @@ const (
 	ExpenseTypeProduction ExpenseType = "production"
 )
 
+// ExpensePaymentStatus is derived from how much of an expense has been paid.
+type ExpensePaymentStatus string
+
+const (
+	ExpensePaid        ExpensePaymentStatus = "paid"
+	ExpensePartialPaid ExpensePaymentStatus = "partial"
+	ExpensePending     ExpensePaymentStatus = "pending"
+)
+
+// InvoiceType is the letter of a provider invoice. X stands for non-fiscal
+// tickets and delivery notes.
+type InvoiceType string
+
+const (
+	InvoiceTypeA InvoiceType = "A"
+	InvoiceTypeB InvoiceType = "B"
+	InvoiceTypeC InvoiceType = "C"
+	InvoiceTypeM InvoiceType = "M"
+	InvoiceTypeX InvoiceType = "X"
+)
+
+// ErrExpenseHasPayments is returned when deleting an expense that provider
+// payments were applied to.
+var ErrExpenseHasPayments = errors.New("expense has payments applied")
+
+// ErrDuplicateExpenseInvoice is returned when the provider already has an
+// expense with the invoice number.
+var ErrDuplicateExpenseInvoice = errors.New("ya hay un gasto cargado con ese número de factura para el proveedor")
+
 type ExpenseCategory struct {
 	ID        int64     `json:"id"`
 	Name      string    `json:"name"`
@@ -29,25 +61,74 @@ type Expense struct {
 	CategoryName string      `json:"category_name"`
 	Type         ExpenseType `json:"type"`
 	Date         time.Time   `json:"date"`
-	CreatedAt    time.Time   `json:"created_at"`
-	DeletedAt    *time.Time  `json:"deleted_at,omitempty"`
+	// DueDate is when a pending expense has to be paid; nil means on Date.
+	DueDate *time.Time `json:"due_date,omitempty"`
+	// PaidAmount is how much has been paid so far. Expenses paid on the spot
+	// are created with the full amount.
+	PaidAmount    string               `json:"paid_amount"`
+	PaymentStatus ExpensePaymentStatus `json:"payment_status"`
+	// Overdue is set on unpaid expenses past their due date.
+	Overdue       bool        `json:"overdue"`
+	InvoiceNumber string      `json:"invoice_number,omitempty"`
+	InvoiceType   InvoiceType `json:"invoice_type,omitempty"`
+	// Items and VAT are only loaded by GetExpenseByID.
+	Items     []*ExpenseItem `json:"items,omitempty"`
+	VAT       []*ExpenseVAT  `json:"vat,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt *time.Time     `json:"deleted_at,omitempty"`
+}
+
+// ExpenseItem is a line of an expense: an ingredient or a free-text
+// description.
+type ExpenseItem struct {
+	ID             int64   `json:"id"`
+	ExpenseID      int64   `json:"expense_id"`
+	IngredientID   *int64  `json:"ingredient_id,omitempty"`
+	IngredientName string  `json:"ingredient_name,omitempty"`
+	Description    string  `json:"description"`
+	Quantity       float64 `json:"quantity"`
+	UnitPrice      float64 `json:"unit_price"`
+}
+
+func (i *ExpenseItem) Subtotal() float64 {
+	return i.Quantity * i.UnitPrice
+}
+
+// ExpenseVAT is the net taxed amount and VAT of an invoice at one rate.
+type ExpenseVAT struct {
+	Rate      float64 `json:"rate"`
+	NetAmount float64 `json:"net_amount"`
+	VATAmount float64 `json:"vat_amount"`
 }
 
 type ExpenseStore interface {
 	CreateExpense(e *Expense) error
+	CreateExpenseTx(tx *sql.Tx, e *Expense) error
 	UpdateExpense(e *Expense) error
 	DeleteExpense(id int64) error
+	// GetExpenseByID returns the expense with its items and VAT breakdown.
 	GetExpenseByID(id int64) (*Expense, error)
+	// GetExpenseByInvoice returns the provider's expense with the invoice
+	// number, if any.
+	GetExpenseByInvoice(providerID int64, invoiceNumber string) (*Expense, error)
+	// LockExpenseTx locks the expense until the transaction ends.
+	LockExpenseTx(tx *sql.Tx, id int64) (*Expense, error)
 	ListExpenses(f ExpenseFilter) ([]*Expense, error)
+	// ListPayables returns expenses with a balance to pay, by due date. A nil
+	// until lists them all.
+	ListPayables(providerID *int64, until *time.Time) ([]*Expense, error)
 
 	CreateExpenseCategory(c *ExpenseCategory) error
 	GetAllExpenseCategories() ([]*ExpenseCategory, error)
 	GetExpenseCategoryByID(id int64) (*ExpenseCategory, error)
+	GetExpenseCategoryByName(name string) (*ExpenseCategory, error)
 }
 
 type ExpenseFilter struct {
 	Type       *ExpenseType
 	CategoryID *int64
+	ProviderID *int64
+	Status     *ExpensePaymentStatus
 	StartDate  *time.Time
 	EndDate    *time.Time
 	Limit      int
@@ -60,23 +141,68 @@ func NewPostgresExpenseStore(db *sql.DB) *PostgresExpenseStore {
 	return &PostgresExpenseStore{db: db}
 }
 
+// CreateExpense inserts the expense with its items and VAT breakdown.
 func (s *PostgresExpenseStore) CreateExpense(e *Expense) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.CreateExpenseTx(tx, e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresExpenseStore) CreateExpenseTx(tx *sql.Tx, e *Expense) error {
 	const q = `
-	INSERT INTO expenses (amount, image_path, provider_id, category_id, type, date)
-	VALUES ($1, $2, $3, $4, $5, $6)
-	RETURNING id, created_at`
-	
-	return s.db.QueryRow(q, e.Amount, e.ImagePath, e.ProviderID, e.CategoryID, e.Type, e.Date).
-		Scan(&e.ID, &e.CreatedAt)
+	INSERT INTO expenses (amount, image_path, provider_id, category_id, type, date, due_date, paid_amount, invoice_number, invoice_type)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE(NULLIF($8, '')::numeric, 0), $9, $10)
+	RETURNING id, paid_amount::text, created_at`
+
+	err := tx.QueryRow(q, e.Amount, e.ImagePath, e.ProviderID, e.CategoryID, e.Type, e.Date, e.DueDate, e.PaidAmount,
+		e.InvoiceNumber, e.InvoiceType).Scan(&e.ID, &e.PaidAmount, &e.CreatedAt)
+	if err != nil {
+		return expenseWriteError(err)
+	}
+
+	for _, item := range e.Items {
+		item.ExpenseID = e.ID
+		err := tx.QueryRow(`
+		INSERT INTO expense_items (expense_id, ingredient_id, description, quantity, unit_price)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`, e.ID, item.IngredientID, item.Description, item.Quantity, item.UnitPrice).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+	for _, v := range e.VAT {
+		_, err := tx.Exec(`
+		INSERT INTO expense_vat (expense_id, rate, net_amount, vat_amount)
+		VALUES ($1, $2, $3, $4)`, e.ID, v.Rate, v.NetAmount, v.VATAmount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expenseWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "idx_expenses_provider_invoice" {
+		return ErrDuplicateExpenseInvoice
+	}
+	return err
 }
 
 func (s *PostgresExpenseStore) UpdateExpense(e *Expense) error {
 	const q = `
 	UPDATE expenses
-	SET amount=$1, image_path=$2, provider_id=$3, category_id=$4, type=$5, date=$6
-	WHERE id=$7 AND deleted_at IS NULL`
+	SET amount=$1, image_path=$2, provider_id=$3, category_id=$4, type=$5, date=$6, due_date=$7
+	WHERE id=$8 AND deleted_at IS NULL`
 	
-	res, err := s.db.Exec(q, e.Amount, e.ImagePath, e.ProviderID, e.CategoryID, e.Type, e.Date, e.ID)
+	res, err := s.db.Exec(q, e.Amount, e.ImagePath, e.ProviderID, e.CategoryID, e.Type, e.Date, e.DueDate, e.ID)
 	if err != nil {
 		return err
 	}
@@ -91,6 +217,15 @@ func (s *PostgresExpenseStore) UpdateExpense(e *Expense) error {
 }
 
 func (s *PostgresExpenseStore) DeleteExpense(id int64) error {
+	var hasPayments bool
+	err := s.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM provider_payment_allocations WHERE expense_id = $1)`, id).Scan(&hasPayments)
+	if err != nil {
+		return err
+	}
+	if hasPayments {
+		return ErrExpenseHasPayments
+	}
+
 	const q = `UPDATE expenses SET deleted_at = NOW() WHERE id=$1 AND deleted_at IS NULL`
 	res, err := s.db.Exec(q, id)
 	if err != nil {
@@ -106,28 +241,101 @@ func (s *PostgresExpenseStore) DeleteExpense(id int64) error {
 	return nil
 }
 
-func (s *PostgresExpenseStore) GetExpenseByID(id int64) (*Expense, error) {
-	const q = `
-	SELECT e.id, e.amount::text, COALESCE(e.image_path, ''), e.provider_id, p.name, e.category_id, ec.name, e.type, e.date, e.created_at, e.deleted_at
+// expenseStatusSQL derives an expense payment status from what was paid.
+const expenseStatusSQL = `CASE WHEN e.paid_amount >= e.amount THEN 'paid' WHEN e.paid_amount > 0 THEN 'partial' ELSE 'pending' END`
+
+// expenseColumns selects an expense joined with its provider and category,
+// in the order scanExpense reads them.
+const expenseColumns = `
+	SELECT e.id, e.amount::text, COALESCE(e.image_path, ''), e.provider_id, p.name, e.category_id, ec.name, e.type, e.date,
+	       e.due_date, e.paid_amount::text,
+	       ` + expenseStatusSQL + `,
+	       e.paid_amount < e.amount AND COALESCE(e.due_date, e.date::date) < CURRENT_DATE,
+	       e.invoice_number, e.invoice_type, e.created_at, e.deleted_at
 	FROM expenses e
 	LEFT JOIN providers p ON p.id = e.provider_id
-	LEFT JOIN expense_categories ec ON ec.id = e.category_id
-	WHERE e.id=$1 AND e.deleted_at IS NULL`
-	
+	LEFT JOIN expense_categories ec ON ec.id = e.category_id`
+
+func scanExpense(row interface{ Scan(...any) error }) (*Expense, error) {
 	e := &Expense{}
 	var providerName sql.NullString
-	
-	err := s.db.QueryRow(q, id).Scan(
-		&e.ID, &e.Amount, &e.ImagePath, &e.ProviderID, &providerName, &e.CategoryID, &e.CategoryName, &e.Type, &e.Date, &e.CreatedAt, &e.DeletedAt,
+	err := row.Scan(
+		&e.ID, &e.Amount, &e.ImagePath, &e.ProviderID, &providerName, &e.CategoryID, &e.CategoryName, &e.Type, &e.Date,
+		&e.DueDate, &e.PaidAmount, &e.PaymentStatus, &e.Overdue, &e.InvoiceNumber, &e.InvoiceType, &e.CreatedAt, &e.DeletedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	e.ProviderName = providerName.String
+	return e, nil
+}
+
+func (s *PostgresExpenseStore) GetExpenseByID(id int64) (*Expense, error) {
+	e, err := scanExpense(s.db.QueryRow(expenseColumns+` WHERE e.id=$1 AND e.deleted_at IS NULL`, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	if providerName.Valid {
-		e.ProviderName = providerName.String
+
+	rows, err := s.db.Query(`
+	SELECT ei.id, ei.expense_id, ei.ingredient_id, COALESCE(i.name, ''), ei.description, ei.quantity, ei.unit_price
+	FROM expense_items ei
+	LEFT JOIN ingredients i ON i.id = ei.ingredient_id
+	WHERE ei.expense_id = $1
+	ORDER BY ei.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		item := &ExpenseItem{}
+		err := rows.Scan(&item.ID, &item.ExpenseID, &item.IngredientID, &item.IngredientName, &item.Description,
+			&item.Quantity, &item.UnitPrice)
+		if err != nil {
+			return nil, err
+		}
+		e.Items = append(e.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	vatRows, err := s.db.Query(`SELECT rate, net_amount, vat_amount FROM expense_vat WHERE expense_id = $1 ORDER BY rate DESC`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer vatRows.Close()
+	for vatRows.Next() {
+		v := &ExpenseVAT{}
+		if err := vatRows.Scan(&v.Rate, &v.NetAmount, &v.VATAmount); err != nil {
+			return nil, err
+		}
+		e.VAT = append(e.VAT, v)
+	}
+	return e, vatRows.Err()
+}
+
+func (s *PostgresExpenseStore) GetExpenseByInvoice(providerID int64, invoiceNumber string) (*Expense, error) {
+	e, err := scanExpense(s.db.QueryRow(expenseColumns+`
+	WHERE e.provider_id=$1 AND e.invoice_number=$2 AND e.deleted_at IS NULL`, providerID, invoiceNumber))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *PostgresExpenseStore) LockExpenseTx(tx *sql.Tx, id int64) (*Expense, error) {
+	e, err := scanExpense(tx.QueryRow(expenseColumns+` WHERE e.id=$1 AND e.deleted_at IS NULL FOR UPDATE OF e`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 	return e, nil
 }
@@ -140,11 +348,7 @@ func (s *PostgresExpenseStore) ListExpenses(f ExpenseFilter) ([]*Expense, error)
 		f.Offset = 0
 	}
 
-	q := `
-	SELECT e.id, e.amount::text, COALESCE(e.image_path, ''), e.provider_id, p.name, e.category_id, ec.name, e.type, e.date, e.created_at, e.deleted_at
-	FROM expenses e
-	LEFT JOIN providers p ON p.id = e.provider_id
-	LEFT JOIN expense_categories ec ON ec.id = e.category_id`
+	q := expenseColumns
 	where := "WHERE e.deleted_at IS NULL"
 	args := []any{}
 
@@ -156,6 +360,14 @@ func (s *PostgresExpenseStore) ListExpenses(f ExpenseFilter) ([]*Expense, error)
 		where += fmt.Sprintf(" AND e.category_id=$%d", len(args)+1)
 		args = append(args, *f.CategoryID)
 	}
+	if f.ProviderID != nil {
+		where += fmt.Sprintf(" AND e.provider_id=$%d", len(args)+1)
+		args = append(args, *f.ProviderID)
+	}
+	if f.Status != nil {
+		where += fmt.Sprintf(" AND %s=$%d", expenseStatusSQL, len(args)+1)
+		args = append(args, string(*f.Status))
+	}
 	if f.StartDate != nil {
 		where += fmt.Sprintf(" AND e.date >= $%d", len(args)+1)
 		args = append(args, *f.StartDate)
@@ -174,18 +386,32 @@ func (s *PostgresExpenseStore) ListExpenses(f ExpenseFilter) ([]*Expense, error)
 	}
 	defer rows.Close()
 
+	return collectExpenses(rows)
+}
+
+func (s *PostgresExpenseStore) ListPayables(providerID *int64, until *time.Time) ([]*Expense, error) {
+	q := expenseColumns + `
+	WHERE e.deleted_at IS NULL AND e.paid_amount < e.amount
+	  AND ($1::BIGINT IS NULL OR e.provider_id = $1)
+	  AND ($2::DATE IS NULL OR COALESCE(e.due_date, e.date::date) <= $2)
+	ORDER BY COALESCE(e.due_date, e.date::date), e.id`
+
+	rows, err := s.db.Query(q, providerID, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return collectExpenses(rows)
+}
+
+func collectExpenses(rows *sql.Rows) ([]*Expense, error) {
 	var out []*Expense
 	for rows.Next() {
-		e := &Expense{}
-		var providerName sql.NullString
-		if err := rows.Scan(
-			&e.ID, &e.Amount, &e.ImagePath, &e.ProviderID, &providerName, &e.CategoryID, &e.CategoryName, &e.Type, &e.Date, &e.CreatedAt, &e.DeletedAt,
-		); err != nil {
+		e, err := scanExpense(rows)
+		if err != nil {
 			return nil, err
 		}
-		if providerName.Valid {
-			e.ProviderName = providerName.String
-		}
 		out = append(out, e)
 	}
 	return out, rows.Err()
@@ -225,4 +451,16 @@ func (s *PostgresExpenseStore) GetExpenseCategoryByID(id int64) (*ExpenseCategor
 		return nil, err
 	}
 	return &c, nil
-}
\ No newline at end of file
+}
+func (s *PostgresExpenseStore) GetExpenseCategoryByName(name string) (*ExpenseCategory, error) {
+	const q = `SELECT id, name, created_at FROM expense_categories WHERE name=$1`
+	var c ExpenseCategory
+	err := s.db.QueryRow(q, name).Scan(&c.ID, &c.Name, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
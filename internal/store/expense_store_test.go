@@ -111,6 +111,51 @@ func TestPostgresExpenseStore_CRUD(t *testing.T) {
 		}
 	})
 
+	t.Run("ItemsAndInvoice", func(t *testing.T) {
+		ingredient := &Ingredient{Name: "Harina 000"}
+		require.NoError(t, NewPostgresIngredientStore(db).CreateIngredient(ingredient))
+
+		invoice := &Expense{
+			Amount:        "1210.00",
+			PaidAmount:    "1210.00",
+			CategoryID:    ec.ID,
+			Type:          ExpenseTypeProduction,
+			Date:          time.Now(),
+			ProviderID:    &p.ID,
+			InvoiceNumber: "0001-00000123",
+			InvoiceType:   InvoiceTypeA,
+			Items: []*ExpenseItem{
+				{IngredientID: &ingredient.ID, Quantity: 25, UnitPrice: 36},
+				{Description: "Flete", Quantity: 1, UnitPrice: 100},
+			},
+			VAT: []*ExpenseVAT{{Rate: 21, NetAmount: 1000, VATAmount: 210}},
+		}
+		require.NoError(t, store.CreateExpense(invoice))
+
+		got, err := store.GetExpenseByID(invoice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, InvoiceTypeA, got.InvoiceType)
+		require.Len(t, got.Items, 2)
+		assert.Equal(t, "Harina 000", got.Items[0].IngredientName)
+		assert.Equal(t, 900.0, got.Items[0].Subtotal())
+		assert.Equal(t, "Flete", got.Items[1].Description)
+		require.Len(t, got.VAT, 1)
+		assert.Equal(t, 210.0, got.VAT[0].VATAmount)
+
+		found, err := store.GetExpenseByInvoice(p.ID, "0001-00000123")
+		require.NoError(t, err)
+		require.NotNil(t, found)
+		assert.Equal(t, invoice.ID, found.ID)
+
+		duplicate := &Expense{Amount: "10.00", PaidAmount: "10.00", CategoryID: ec.ID, Type: ExpenseTypeProduction, Date: time.Now(),
+			ProviderID: &p.ID, InvoiceNumber: "0001-00000123"}
+		assert.ErrorIs(t, store.CreateExpense(duplicate), ErrDuplicateExpenseInvoice)
+
+		missing, err := store.GetExpenseByInvoice(p.ID, "0001-99999999")
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
 	// Delete
 	t.Run("DeleteExpense", func(t *testing.T) {
 		err := store.DeleteExpense(e.ID)
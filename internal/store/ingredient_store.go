@@ -6,8 +6,12 @@ import (
 )
 
 type Ingredient struct {
-	ID        int64      `json:"id"`
-	Name      string     `json:"name"`
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Cost is per kg, liter or unit, matching the g, ml or u used in recipes.
+	Cost float64 `json:"cost"`
+	// Stock on hand, in the same unit as Cost. Purchases add to it.
+	Stock     float64    `json:"stock"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at"`
@@ -19,6 +23,8 @@ type IngredientStore interface {
 	GetAllIngredients() ([]*Ingredient, error)
 	UpdateIngredient(*Ingredient) error
 	DeleteIngredient(id int64) error
+	// AdjustStockTx adds delta to the ingredient's stock.
+	AdjustStockTx(tx *sql.Tx, id int64, delta float64) error
 }
 
 type PostgresIngredientStore struct {
@@ -33,12 +39,12 @@ func NewPostgresIngredientStore(db *sql.DB) *PostgresIngredientStore {
 
 func (s *PostgresIngredientStore) CreateIngredient(ingredient *Ingredient) error {
 	query := `
-	INSERT INTO ingredients (name)
-	VALUES ($1)
+	INSERT INTO ingredients (name, cost)
+	VALUES ($1, $2)
 	RETURNING id, created_at, updated_at
 	`
 
-	err := s.db.QueryRow(query, ingredient.Name).Scan(
+	err := s.db.QueryRow(query, ingredient.Name, ingredient.Cost).Scan(
 		&ingredient.ID,
 		&ingredient.CreatedAt,
 		&ingredient.UpdatedAt,
@@ -52,7 +58,7 @@ func (s *PostgresIngredientStore) CreateIngredient(ingredient *Ingredient) error
 
 func (s *PostgresIngredientStore) GetAllIngredients() ([]*Ingredient, error) {
 	query := `
-	SELECT id, name, created_at, updated_at, deleted_at
+	SELECT id, name, cost, stock, created_at, updated_at, deleted_at
 	FROM ingredients
 	WHERE deleted_at IS NULL
 	ORDER BY name
@@ -67,7 +73,7 @@ func (s *PostgresIngredientStore) GetAllIngredients() ([]*Ingredient, error) {
 	var ingredients []*Ingredient
 	for rows.Next() {
 		i := &Ingredient{}
-		if err := rows.Scan(&i.ID, &i.Name, &i.CreatedAt, &i.UpdatedAt, &i.DeletedAt); err != nil {
+		if err := rows.Scan(&i.ID, &i.Name, &i.Cost, &i.Stock, &i.CreatedAt, &i.UpdatedAt, &i.DeletedAt); err != nil {
 			return nil, err
 		}
 		ingredients = append(ingredients, i)
@@ -84,7 +90,7 @@ func (s *PostgresIngredientStore) GetIngredientByID(id int64) (*Ingredient, erro
 	ingredient := &Ingredient{}
 
 	query := `
-	SELECT id, name, created_at, updated_at, deleted_at
+	SELECT id, name, cost, stock, created_at, updated_at, deleted_at
 	FROM ingredients
 	WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -92,6 +98,8 @@ func (s *PostgresIngredientStore) GetIngredientByID(id int64) (*Ingredient, erro
 	err := s.db.QueryRow(query, id).Scan(
 		&ingredient.ID,
 		&ingredient.Name,
+		&ingredient.Cost,
+		&ingredient.Stock,
 		&ingredient.CreatedAt,
 		&ingredient.UpdatedAt,
 		&ingredient.DeletedAt,
@@ -111,12 +119,12 @@ func (s *PostgresIngredientStore) GetIngredientByID(id int64) (*Ingredient, erro
 func (s *PostgresIngredientStore) UpdateIngredient(ingredient *Ingredient) error {
 	query := `
 	UPDATE ingredients
-	SET name = $1, updated_at = NOW()
-	WHERE id = $2 AND deleted_at IS NULL
+	SET name = $1, cost = $2, updated_at = NOW()
+	WHERE id = $3 AND deleted_at IS NULL
 	RETURNING updated_at
 	`
 
-	err := s.db.QueryRow(query, ingredient.Name, ingredient.ID).Scan(&ingredient.UpdatedAt)
+	err := s.db.QueryRow(query, ingredient.Name, ingredient.Cost, ingredient.ID).Scan(&ingredient.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return sql.ErrNoRows
@@ -150,3 +158,23 @@ func (s *PostgresIngredientStore) DeleteIngredient(id int64) error {
 
 	return nil
 }
+
+func (s *PostgresIngredientStore) AdjustStockTx(tx *sql.Tx, id int64, delta float64) error {
+	query := `
+	UPDATE ingredients
+	SET stock = stock + $1, updated_at = NOW()
+	WHERE id = $2`
+
+	result, err := tx.Exec(query, delta, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrInventoryCountInProgress is returned when starting a count while another
+// one is still open.
+var ErrInventoryCountInProgress = errors.New("ya hay un conteo de inventario en curso")
+
+const (
+	InventoryCountOpen      = "open"
+	InventoryCountApproved  = "approved"
+	InventoryCountCancelled = "cancelled"
+)
+
+// InventoryCount is a physical stock count. It stays as a historical document
+// once approved or cancelled.
+type InventoryCount struct {
+	ID            int64                 `json:"id"`
+	LocationID    int64                 `json:"location_id"`
+	LocationName  string                `json:"location_name,omitempty"`
+	Status        string                `json:"status"`
+	Notes         string                `json:"notes"`
+	CreatedBy     *int64                `json:"created_by"`
+	CreatedByName string                `json:"created_by_name,omitempty"`
+	ClosedBy      *int64                `json:"closed_by"`
+	ClosedByName  string                `json:"closed_by_name,omitempty"`
+	CreatedAt     time.Time             `json:"created_at"`
+	ClosedAt      *time.Time            `json:"closed_at"`
+	Items         []*InventoryCountItem `json:"items,omitempty"`
+}
+
+type InventoryCountItem struct {
+	ID            int64      `json:"id"`
+	CountID       int64      `json:"count_id"`
+	ProductID     int64      `json:"product_id"`
+	ProductName   string     `json:"product_name"`
+	SaleUnit      string     `json:"sale_unit"`
+	UnitPrice     float64    `json:"unit_price"`
+	Expected      float64    `json:"expected"`
+	Counted       *float64   `json:"counted"`
+	CountedBy     *int64     `json:"counted_by"`
+	CountedByName string     `json:"counted_by_name,omitempty"`
+	CountedAt     *time.Time `json:"counted_at"`
+}
+
+// Variance is counted minus expected, zero while the item hasn't been counted.
+func (i *InventoryCountItem) Variance() float64 {
+	if i.Counted == nil {
+		return 0
+	}
+	return *i.Counted - i.Expected
+}
+
+type InventoryCountStore interface {
+	// Create opens a count at a location (zero is the default one) and
+	// snapshots the current stock of every product there.
+	Create(count *InventoryCount) error
+	GetByID(id int64) (*InventoryCount, error)
+	GetOpen() (*InventoryCount, error)
+	List(limit, offset int) ([]*InventoryCount, error)
+	// SetCounted records (or clears, with nil) the counted quantity of a
+	// product in an open count.
+	SetCounted(countID, productID int64, counted *float64, userID int64) (*InventoryCountItem, error)
+
+	// Transactional methods.
+	LockTx(tx *sql.Tx, id int64) (*InventoryCount, error)
+	ListItemsTx(tx *sql.Tx, countID int64) ([]*InventoryCountItem, error)
+	CloseTx(tx *sql.Tx, id int64, status string, userID int64) error
+}
+
+type PostgresInventoryCountStore struct {
+	db *sql.DB
+}
+
+func NewPostgresInventoryCountStore(db *sql.DB) *PostgresInventoryCountStore {
+	return &PostgresInventoryCountStore{db: db}
+}
+
+const inventoryCountColumns = `
+	c.id, c.location_id, l.name, c.status, c.notes, c.created_by, COALESCE(cu.username, ''), c.closed_by,
+	COALESCE(xu.username, ''), c.created_at, c.closed_at
+	FROM inventory_counts c
+	JOIN stock_locations l ON l.id = c.location_id
+	LEFT JOIN users cu ON cu.id = c.created_by
+	LEFT JOIN users xu ON xu.id = c.closed_by`
+
+func scanInventoryCount(row interface{ Scan(dest ...any) error }) (*InventoryCount, error) {
+	var c InventoryCount
+	err := row.Scan(&c.ID, &c.LocationID, &c.LocationName, &c.Status, &c.Notes, &c.CreatedBy, &c.CreatedByName,
+		&c.ClosedBy, &c.ClosedByName, &c.CreatedAt, &c.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+const inventoryCountItemQuery = `
+	SELECT i.id, i.count_id, i.product_id, p.name, p.sale_unit, p.unit_price, i.expected, i.counted,
+	       i.counted_by, COALESCE(u.username, ''), i.counted_at
+	FROM inventory_count_items i
+	JOIN products p ON p.id = i.product_id
+	LEFT JOIN users u ON u.id = i.counted_by`
+
+func scanInventoryCountItem(row interface{ Scan(dest ...any) error }) (*InventoryCountItem, error) {
+	var i InventoryCountItem
+	err := row.Scan(&i.ID, &i.CountID, &i.ProductID, &i.ProductName, &i.SaleUnit, &i.UnitPrice, &i.Expected,
+		&i.Counted, &i.CountedBy, &i.CountedByName, &i.CountedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (s *PostgresInventoryCountStore) Create(count *InventoryCount) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO inventory_counts (location_id, notes, created_by)
+	VALUES (` + locationSQL("$1") + `, $2, $3)
+	RETURNING id, location_id, status, created_at`
+
+	var createdBy int64
+	if count.CreatedBy != nil {
+		createdBy = *count.CreatedBy
+	}
+	err = tx.QueryRow(query, nullInt64(count.LocationID), count.Notes, nullInt64(createdBy)).
+		Scan(&count.ID, &count.LocationID, &count.Status, &count.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "idx_inventory_counts_one_open" {
+			return ErrInventoryCountInProgress
+		}
+		return err
+	}
+
+	snapshot := `
+	INSERT INTO inventory_count_items (count_id, product_id, expected)
+	SELECT $1, p.id, COALESCE(ls.quantity, 0)
+	FROM products p
+	LEFT JOIN local_stock ls ON ls.product_id = p.id AND ls.location_id = $2
+	WHERE p.deleted_at IS NULL`
+
+	if _, err := tx.Exec(snapshot, count.ID, count.LocationID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresInventoryCountStore) GetByID(id int64) (*InventoryCount, error) {
+	count, err := scanInventoryCount(s.db.QueryRow(`SELECT `+inventoryCountColumns+` WHERE c.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(inventoryCountItemQuery+` WHERE i.count_id = $1 ORDER BY p.name`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanInventoryCountItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		count.Items = append(count.Items, item)
+	}
+	return count, rows.Err()
+}
+
+func (s *PostgresInventoryCountStore) GetOpen() (*InventoryCount, error) {
+	count, err := scanInventoryCount(s.db.QueryRow(`SELECT ` + inventoryCountColumns + ` WHERE c.status = 'open'`))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return count, err
+}
+
+func (s *PostgresInventoryCountStore) List(limit, offset int) ([]*InventoryCount, error) {
+	rows, err := s.db.Query(`SELECT `+inventoryCountColumns+` ORDER BY c.created_at DESC, c.id DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []*InventoryCount
+	for rows.Next() {
+		count, err := scanInventoryCount(rows)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+func (s *PostgresInventoryCountStore) SetCounted(countID, productID int64, counted *float64, userID int64) (*InventoryCountItem, error) {
+	query := `
+	UPDATE inventory_count_items i
+	SET counted = $1,
+	    counted_by = CASE WHEN $1::NUMERIC IS NULL THEN NULL ELSE $2::BIGINT END,
+	    counted_at = CASE WHEN $1::NUMERIC IS NULL THEN NULL ELSE NOW() END
+	FROM inventory_counts c
+	WHERE c.id = i.count_id AND c.status = 'open' AND i.count_id = $3 AND i.product_id = $4`
+
+	res, err := s.db.Exec(query, counted, nullInt64(userID), countID, productID)
+	if err != nil {
+		return nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	return scanInventoryCountItem(s.db.QueryRow(inventoryCountItemQuery+` WHERE i.count_id = $1 AND i.product_id = $2`, countID, productID))
+}
+
+func (s *PostgresInventoryCountStore) LockTx(tx *sql.Tx, id int64) (*InventoryCount, error) {
+	query := `
+	SELECT id, location_id, status, notes, created_by, created_at
+	FROM inventory_counts
+	WHERE id = $1
+	FOR UPDATE`
+
+	var c InventoryCount
+	err := tx.QueryRow(query, id).Scan(&c.ID, &c.LocationID, &c.Status, &c.Notes, &c.CreatedBy, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *PostgresInventoryCountStore) ListItemsTx(tx *sql.Tx, countID int64) ([]*InventoryCountItem, error) {
+	rows, err := tx.Query(inventoryCountItemQuery+` WHERE i.count_id = $1 ORDER BY i.product_id`, countID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*InventoryCountItem
+	for rows.Next() {
+		item, err := scanInventoryCountItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresInventoryCountStore) CloseTx(tx *sql.Tx, id int64, status string, userID int64) error {
+	query := `
+	UPDATE inventory_counts
+	SET status = $1, closed_by = $2, closed_at = NOW()
+	WHERE id = $3 AND status = 'open'`
+
+	res, err := tx.Exec(query, status, nullInt64(userID), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventoryCountStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	stockStore := NewPostgresLocalStockStore(db)
+	s := NewPostgresInventoryCountStore(db)
+
+	withStock := setupProductForStockTest(t, db)
+	withoutStock := setupProductForStockTest(t, db)
+	_, err := stockStore.Create(withStock.ID, 12, StockChange{Reason: MovementInitial})
+	require.NoError(t, err)
+
+	count := &InventoryCount{Notes: "cierre de mes"}
+	require.NoError(t, s.Create(count))
+	assert.NotZero(t, count.ID)
+	assert.Equal(t, InventoryCountOpen, count.Status)
+
+	t.Run("only one open count", func(t *testing.T) {
+		err := s.Create(&InventoryCount{})
+		assert.ErrorIs(t, err, ErrInventoryCountInProgress)
+
+		open, err := s.GetOpen()
+		require.NoError(t, err)
+		require.NotNil(t, open)
+		assert.Equal(t, count.ID, open.ID)
+	})
+
+	t.Run("snapshots expected stock", func(t *testing.T) {
+		got, err := s.GetByID(count.ID)
+		require.NoError(t, err)
+		require.Len(t, got.Items, 2)
+
+		expected := map[int64]float64{}
+		for _, item := range got.Items {
+			assert.Nil(t, item.Counted)
+			expected[item.ProductID] = item.Expected
+		}
+		assert.Equal(t, 12.0, expected[withStock.ID])
+		assert.Equal(t, 0.0, expected[withoutStock.ID])
+	})
+
+	t.Run("set and clear counted", func(t *testing.T) {
+		qty := 10.0
+		item, err := s.SetCounted(count.ID, withStock.ID, &qty, 0)
+		require.NoError(t, err)
+		require.NotNil(t, item.Counted)
+		assert.Equal(t, 10.0, *item.Counted)
+		assert.Equal(t, -2.0, item.Variance())
+		assert.NotNil(t, item.CountedAt)
+
+		item, err = s.SetCounted(count.ID, withoutStock.ID, &qty, 0)
+		require.NoError(t, err)
+		item, err = s.SetCounted(count.ID, withoutStock.ID, nil, 0)
+		require.NoError(t, err)
+		assert.Nil(t, item.Counted)
+		assert.Nil(t, item.CountedAt)
+		assert.Equal(t, 0.0, item.Variance())
+
+		_, err = s.SetCounted(count.ID, 99999, &qty, 0)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("closed counts are read only", func(t *testing.T) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		locked, err := s.LockTx(tx, count.ID)
+		require.NoError(t, err)
+		require.NotNil(t, locked)
+		require.NoError(t, s.CloseTx(tx, count.ID, InventoryCountCancelled, 0))
+		require.NoError(t, tx.Commit())
+
+		qty := 1.0
+		_, err = s.SetCounted(count.ID, withStock.ID, &qty, 0)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		open, err := s.GetOpen()
+		require.NoError(t, err)
+		assert.Nil(t, open)
+
+		counts, err := s.List(10, 0)
+		require.NoError(t, err)
+		require.Len(t, counts, 1)
+		assert.Equal(t, InventoryCountCancelled, counts[0].Status)
+		assert.NotNil(t, counts[0].ClosedAt)
+	})
+}
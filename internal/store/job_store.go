@@ -0,0 +1,343 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Job kinds.
+const (
+	// JobGenerateInvoice adds an order to the day's remito file.
+	JobGenerateInvoice = "invoice.generate"
+	JobSendEmail       = "email.send"
+	// JobSendPasswordReset creates a reset token and emails its link, so the
+	// token is never kept in a payload.
+	JobSendPasswordReset = "password_reset.send"
+	JobDeliverWebhooks   = "webhooks.deliver"
+	JobCleanupJobs       = "jobs.cleanup"
+)
+
+// Job statuses.
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	// JobDead jobs ran out of attempts and wait for someone to retry them.
+	JobDead = "dead"
+)
+
+// JobDefaultMaxAttempts is how many times a job is tried when it doesn't
+// say.
+const JobDefaultMaxAttempts = 5
+
+// ErrJobAlreadyQueued is returned when retrying a job whose UniqueKey a
+// pending or running job already has.
+var ErrJobAlreadyQueued = errors.New("a job with the same key is already queued")
+
+type Job struct {
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+	// Payload is only for the handler; it is never shown.
+	Payload     json.RawMessage `json:"-"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	// RunAt is when a pending job is due.
+	RunAt       time.Time  `json:"run_at"`
+	LockedUntil *time.Time `json:"locked_until"`
+	UniqueKey   string     `json:"unique_key,omitempty"`
+	LastError   string     `json:"last_error"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+// NewJob returns a job of kind due now, with payload marshaled to JSON.
+func NewJob(kind string, payload any) (*Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling %s job: %w", kind, err)
+	}
+	return &Job{Kind: kind, Payload: b}, nil
+}
+
+// InvoiceJob is the payload of JobGenerateInvoice.
+type InvoiceJob struct {
+	OrderID int64 `json:"order_id"`
+}
+
+// EmailJob is the payload of JobSendEmail.
+type EmailJob struct {
+	To       string `json:"to"`
+	Template string `json:"template"`
+	Data     any    `json:"data"`
+}
+
+// PasswordResetJob is the payload of JobSendPasswordReset. BaseURL is the
+// scheme and host the link points to.
+type PasswordResetJob struct {
+	UserID  int64  `json:"user_id"`
+	BaseURL string `json:"base_url"`
+}
+
+type JobFilter struct {
+	Kind   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+type JobStore interface {
+	// Enqueue queues j. When a pending or running job has the same
+	// UniqueKey it is not queued and false is returned.
+	Enqueue(j *Job) (bool, error)
+	// Claim takes up to limit due jobs, pending or running ones whose worker
+	// is gone, counting an attempt and marking them running for lease.
+	Claim(limit int, lease time.Duration) ([]*Job, error)
+	// Complete marks a running job done.
+	Complete(id int64) error
+	// Fail records a failed attempt: the job is due again at retryAt, or
+	// dead when it ran out of attempts.
+	Fail(j *Job, errMsg string, retryAt time.Time) error
+	// Release puts running jobs back as pending, due now, without counting
+	// the attempt. Used when shutting down before running them.
+	Release(ids []int64) error
+	// Retry queues a dead job again with its attempts reset. sql.ErrNoRows
+	// when there is no such dead job, ErrJobAlreadyQueued when another job
+	// with its UniqueKey is pending or running.
+	Retry(id int64) error
+	// GetByID returns nil when there is no such job.
+	GetByID(id int64) (*Job, error)
+	// List returns the jobs matching the filter newest first, with the total
+	// number of matches.
+	List(f JobFilter) ([]*Job, int, error)
+	// CountByStatus returns how many jobs are in each status.
+	CountByStatus() (map[string]int, error)
+	// DeleteFinished removes done jobs finished before, returning how many.
+	DeleteFinished(before time.Time) (int, error)
+}
+
+type PostgresJobStore struct {
+	db *sql.DB
+}
+
+func NewPostgresJobStore(db *sql.DB) *PostgresJobStore {
+	return &PostgresJobStore{db: db}
+}
+
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// enqueueJob queues j with q, a transaction for jobs that must only run if
+// it commits.
+func enqueueJob(q rowQuerier, j *Job) (bool, error) {
+	if j.MaxAttempts <= 0 {
+		j.MaxAttempts = JobDefaultMaxAttempts
+	}
+	if j.RunAt.IsZero() {
+		j.RunAt = time.Now()
+	}
+	if len(j.Payload) == 0 {
+		j.Payload = json.RawMessage(`{}`)
+	}
+	var unique sql.NullString
+	if j.UniqueKey != "" {
+		unique = sql.NullString{String: j.UniqueKey, Valid: true}
+	}
+
+	err := q.QueryRow(`
+	INSERT INTO jobs (kind, payload, max_attempts, run_at, unique_key)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (unique_key) WHERE status IN ('pending', 'running') DO NOTHING
+	RETURNING id, status, created_at, updated_at`,
+		j.Kind, []byte(j.Payload), j.MaxAttempts, j.RunAt, unique).
+		Scan(&j.ID, &j.Status, &j.CreatedAt, &j.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *PostgresJobStore) Enqueue(j *Job) (bool, error) {
+	return enqueueJob(s.db, j)
+}
+
+const jobColumns = `
+	id, kind, payload, status, attempts, max_attempts, run_at, locked_until,
+	COALESCE(unique_key, ''), last_error, created_at, updated_at, finished_at`
+
+func scanJob(row interface{ Scan(...any) error }) (*Job, error) {
+	j := &Job{}
+	var payload []byte
+	if err := row.Scan(&j.ID, &j.Kind, &payload, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LockedUntil,
+		&j.UniqueKey, &j.LastError, &j.CreatedAt, &j.UpdatedAt, &j.FinishedAt); err != nil {
+		return nil, err
+	}
+	j.Payload = payload
+	return j, nil
+}
+
+func (s *PostgresJobStore) Claim(limit int, lease time.Duration) ([]*Job, error) {
+	rows, err := s.db.Query(`
+	UPDATE jobs
+	SET status = 'running', attempts = attempts + 1,
+	    locked_until = NOW() + $2::int * INTERVAL '1 second', updated_at = NOW()
+	WHERE id IN (
+		SELECT id FROM jobs
+		WHERE (status = 'pending' AND run_at <= NOW())
+		   OR (status = 'running' AND locked_until < NOW())
+		ORDER BY run_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING `+jobColumns,
+		limit, int(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *PostgresJobStore) Complete(id int64) error {
+	return expectOneRow(s.db.Exec(`
+	UPDATE jobs SET status = 'done', locked_until = NULL, last_error = '',
+	    finished_at = NOW(), updated_at = NOW()
+	WHERE id = $1`, id))
+}
+
+func (s *PostgresJobStore) Fail(j *Job, errMsg string, retryAt time.Time) error {
+	err := s.db.QueryRow(`
+	UPDATE jobs
+	SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+	    run_at = $2, locked_until = NULL, last_error = $3, updated_at = NOW(),
+	    finished_at = CASE WHEN attempts >= max_attempts THEN NOW() END
+	WHERE id = $1
+	RETURNING status, run_at, last_error, finished_at`,
+		j.ID, retryAt, errMsg).
+		Scan(&j.Status, &j.RunAt, &j.LastError, &j.FinishedAt)
+	return err
+}
+
+func (s *PostgresJobStore) Release(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := s.db.Exec(`
+	UPDATE jobs
+	SET status = 'pending', attempts = GREATEST(attempts - 1, 0), run_at = NOW(),
+	    locked_until = NULL, updated_at = NOW()
+	WHERE id = ANY($1) AND status = 'running'`, ids)
+	return err
+}
+
+func (s *PostgresJobStore) Retry(id int64) error {
+	err := expectOneRow(s.db.Exec(`
+	UPDATE jobs
+	SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+	WHERE id = $1 AND status = 'dead'`, id))
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "idx_jobs_unique_key" {
+		return ErrJobAlreadyQueued
+	}
+	return err
+}
+
+func (s *PostgresJobStore) GetByID(id int64) (*Job, error) {
+	j, err := scanJob(s.db.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return j, err
+}
+
+func (s *PostgresJobStore) List(f JobFilter) ([]*Job, int, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	where := "WHERE TRUE"
+	args := []any{}
+	if f.Kind != "" {
+		where += fmt.Sprintf(" AND kind=$%d", len(args)+1)
+		args = append(args, f.Kind)
+	}
+	if f.Status != "" {
+		where += fmt.Sprintf(" AND status=$%d", len(args)+1)
+		args = append(args, f.Status)
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM jobs `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	q := `SELECT ` + jobColumns + ` FROM jobs ` + where +
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, total, rows.Err()
+}
+
+func (s *PostgresJobStore) CountByStatus() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+func (s *PostgresJobStore) DeleteFinished(before time.Time) (int, error) {
+	res, err := s.db.Exec(`DELETE FROM jobs WHERE status = 'done' AND finished_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	s := NewPostgresJobStore(db)
+
+	job, err := NewJob(JobSendEmail, EmailJob{To: "a@example.com", Template: "password_reset.tmpl"})
+	require.NoError(t, err)
+	job.MaxAttempts = 2
+	queued, err := s.Enqueue(job)
+	require.NoError(t, err)
+	assert.True(t, queued)
+	assert.Equal(t, JobPending, job.Status)
+
+	// Scheduled jobs don't pile up: one pending per unique key.
+	first := &Job{Kind: JobCleanupJobs, UniqueKey: JobCleanupJobs}
+	queued, err = s.Enqueue(first)
+	require.NoError(t, err)
+	assert.True(t, queued)
+	queued, err = s.Enqueue(&Job{Kind: JobCleanupJobs, UniqueKey: JobCleanupJobs})
+	require.NoError(t, err)
+	assert.False(t, queued)
+
+	later := &Job{Kind: JobSendEmail, RunAt: time.Now().Add(time.Hour)}
+	_, err = s.Enqueue(later)
+	require.NoError(t, err)
+
+	// Only due jobs are claimed, and only once while leased.
+	claimed, err := s.Claim(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	for _, j := range claimed {
+		assert.Equal(t, JobRunning, j.Status)
+		assert.Equal(t, 1, j.Attempts)
+		assert.NotNil(t, j.LockedUntil)
+	}
+	again, err := s.Claim(10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	require.NoError(t, s.Complete(first.ID))
+	done, err := s.GetByID(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobDone, done.Status)
+	assert.NotNil(t, done.FinishedAt)
+
+	// Done, the unique key is free again.
+	queued, err = s.Enqueue(&Job{Kind: JobCleanupJobs, UniqueKey: JobCleanupJobs})
+	require.NoError(t, err)
+	assert.True(t, queued)
+
+	// Failing retries until the attempts run out, then the job is dead.
+	job.Attempts = 1
+	require.NoError(t, s.Fail(job, "smtp down", time.Now().Add(-time.Second)))
+	assert.Equal(t, JobPending, job.Status)
+	retried, err := s.Claim(10, time.Minute)
+	require.NoError(t, err)
+	var mail *Job
+	for _, j := range retried {
+		if j.ID == job.ID {
+			mail = j
+		}
+	}
+	require.NotNil(t, mail)
+	assert.Equal(t, 2, mail.Attempts)
+	require.NoError(t, s.Fail(mail, "smtp down", time.Now()))
+	assert.Equal(t, JobDead, mail.Status)
+	assert.Equal(t, "smtp down", mail.LastError)
+
+	counts, err := s.CountByStatus()
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts[JobDead])
+
+	list, total, err := s.List(JobFilter{Status: JobDead})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, job.ID, list[0].ID)
+
+	require.NoError(t, s.Retry(job.ID))
+	assert.ErrorIs(t, s.Retry(job.ID), sql.ErrNoRows, "not dead anymore")
+	retry, err := s.GetByID(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobPending, retry.Status)
+	assert.Zero(t, retry.Attempts)
+
+	// Released jobs are pending again without the attempt.
+	claimed, err = s.Claim(10, time.Minute)
+	require.NoError(t, err)
+	require.NotEmpty(t, claimed)
+	ids := []int64{}
+	for _, j := range claimed {
+		ids = append(ids, j.ID)
+	}
+	require.NoError(t, s.Release(ids))
+	released, err := s.GetByID(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobPending, released.Status)
+	assert.Zero(t, released.Attempts)
+
+	// A dead scheduled job can't be retried while the next one is queued.
+	var deadID int64
+	require.NoError(t, db.QueryRow(`
+	INSERT INTO jobs (kind, payload, status, unique_key) VALUES ($1, '{}', 'dead', $1)
+	RETURNING id`, JobCleanupJobs).Scan(&deadID))
+	assert.ErrorIs(t, s.Retry(deadID), ErrJobAlreadyQueued)
+
+	n, err := s.DeleteFinished(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestCreateOrderQueuesInvoice(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	s := NewPostgresJobStore(db)
+
+	client := &Client{Name: "Cliente", Type: ClientTypeIndividual, Reference: "ref-job", CUIT: "cuit-job"}
+	require.NoError(t, NewPostgresClientStore(db).CreateClient(client))
+	category := &Category{Name: "Categoria"}
+	require.NoError(t, NewPostgresCategoryStore(db).CreateCategory(category))
+	product := &Product{CategoryID: category.ID, Name: "Producto", UnitPrice: 10.0, DistributionPrice: 8.0}
+	require.NoError(t, NewPostgresProductStore(db).CreateProduct(product))
+
+	order := &Order{ClientID: client.ID, State: OrderTodo}
+	items := []OrderItem{{ProductID: product.ID, Quantity: 1, Price: "10"}}
+	require.NoError(t, NewPostgresOrderStore(db).CreateOrder(order, items))
+
+	jobs, total, err := s.List(JobFilter{Kind: JobGenerateInvoice})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	assert.JSONEq(t, fmt.Sprintf(`{"order_id":%d}`, order.ID), string(jobs[0].Payload))
+}
@@ -8,6 +8,10 @@ import (
 type LocalSale struct {
 	ID              int64           `json:"id"`
 	PaymentMethodID int64           `json:"payment_method_id"`
+	ShiftID         *int64          `json:"shift_id"`
+	// LocationID is the stock location the sale was taken from; zero on
+	// create means the default location.
+	LocationID      int64           `json:"location_id"`
 	Subtotal        string          `json:"subtotal"`
 	Total           string          `json:"total"`
 	CreatedAt       time.Time       `json:"created_at"`
@@ -17,12 +21,12 @@ type LocalSale struct {
 }
 
 type LocalSaleItem struct {
-	ID           int64  `json:"id"`
-	LocalSaleID  int64  `json:"local_sale_id"`
-	ProductID    int64  `json:"product_id"`
-	Quantity     int    `json:"quantity"`
-	UnitPrice    string `json:"unit_price"`
-	LineSubtotal string `json:"line_subtotal"`
+	ID           int64   `json:"id"`
+	LocalSaleID  int64   `json:"local_sale_id"`
+	ProductID    int64   `json:"product_id"`
+	Quantity     float64 `json:"quantity"`
+	UnitPrice    string  `json:"unit_price"`
+	LineSubtotal string  `json:"line_subtotal"`
 }
 
 type DailySalesStats struct {
@@ -32,12 +36,20 @@ type DailySalesStats struct {
 }
 
 type LocalSaleStore interface {
+	// CreateInTx saves the sale with its items and queues its
+	// local_sale.created webhooks in tx.
 	CreateInTx(tx *sql.Tx, sale *LocalSale, items []LocalSaleItem) error
+	// DeleteInTx voids a sale; it returns sql.ErrNoRows when the sale
+	// doesn't exist or is already voided.
 	DeleteInTx(tx *sql.Tx, id int64) error
 	GetByID(id int64) (*LocalSale, error)
 	ListAll() ([]*LocalSale, error)
 	ListByDate(start, end time.Time) ([]*LocalSale, error)
 	GetStats(start, end time.Time) (*DailySalesStats, error)
+	GetStatsByShiftID(shiftID int64) (*DailySalesStats, error)
+	// QuantitiesSold sums the non-voided quantities per product sold at a
+	// location (0 for the default one) in [start, end).
+	QuantitiesSold(start, end time.Time, locationID int64) (map[int64]float64, error)
 }
 
 type PostgresLocalSaleStore struct {
@@ -49,7 +61,7 @@ func NewPostgresLocalSaleStore(db *sql.DB) *PostgresLocalSaleStore {
 }
 
 func (s *PostgresLocalSaleStore) DeleteInTx(tx *sql.Tx, id int64) error {
-	query := `UPDATE local_sales SET deleted_at = NOW() WHERE id = $1`
+	query := `UPDATE local_sales SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
 	result, err := tx.Exec(query, id)
 	if err != nil {
 		return err
@@ -66,7 +78,7 @@ func (s *PostgresLocalSaleStore) DeleteInTx(tx *sql.Tx, id int64) error {
 
 func (s *PostgresLocalSaleStore) ListByDate(start, end time.Time) ([]*LocalSale, error) {
 	query := `
-		SELECT id, payment_method_id, subtotal::text, total::text, created_at, updated_at, deleted_at
+		SELECT id, payment_method_id, shift_id, location_id, subtotal::text, total::text, created_at, updated_at, deleted_at
 		FROM local_sales 
 		WHERE created_at >= $1 AND created_at < $2
 		ORDER BY created_at DESC`
@@ -80,7 +92,7 @@ func (s *PostgresLocalSaleStore) ListByDate(start, end time.Time) ([]*LocalSale,
 	var sales []*LocalSale
 	for rows.Next() {
 		var sale LocalSale
-		if err := rows.Scan(&sale.ID, &sale.PaymentMethodID, &sale.Subtotal, &sale.Total, &sale.CreatedAt, &sale.UpdatedAt, &sale.DeletedAt); err != nil {
+		if err := rows.Scan(&sale.ID, &sale.PaymentMethodID, &sale.ShiftID, &sale.LocationID, &sale.Subtotal, &sale.Total, &sale.CreatedAt, &sale.UpdatedAt, &sale.DeletedAt); err != nil {
 			return nil, err
 		}
 		sales = append(sales, &sale)
@@ -130,14 +142,83 @@ func (s *PostgresLocalSaleStore) GetStats(start, end time.Time) (*DailySalesStat
 	return stats, rows.Err()
 }
 
+// GetStatsByShiftID aggregates the non-voided sales registered against a cash
+// register session.
+func (s *PostgresLocalSaleStore) GetStatsByShiftID(shiftID int64) (*DailySalesStats, error) {
+	stats := &DailySalesStats{
+		ByMethod: make(map[string]float64),
+	}
+
+	queryTotal := `
+		SELECT COALESCE(SUM(total), 0), COUNT(*)
+		FROM local_sales
+		WHERE shift_id = $1 AND deleted_at IS NULL`
+
+	err := s.db.QueryRow(queryTotal, shiftID).Scan(&stats.TotalAmount, &stats.TotalCount)
+	if err != nil {
+		return nil, err
+	}
+
+	queryMethod := `
+		SELECT pm.name, COALESCE(SUM(ls.total), 0)
+		FROM local_sales ls
+		JOIN payment_methods pm ON ls.payment_method_id = pm.id
+		WHERE ls.shift_id = $1 AND ls.deleted_at IS NULL
+		GROUP BY pm.name`
+
+	rows, err := s.db.Query(queryMethod, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var total float64
+		if err := rows.Scan(&name, &total); err != nil {
+			return nil, err
+		}
+		stats.ByMethod[name] = total
+	}
+
+	return stats, rows.Err()
+}
+
+func (s *PostgresLocalSaleStore) QuantitiesSold(start, end time.Time, locationID int64) (map[int64]float64, error) {
+	query := `
+		SELECT lsi.product_id, SUM(lsi.quantity)
+		FROM local_sale_items lsi
+		JOIN local_sales ls ON ls.id = lsi.local_sale_id
+		WHERE ls.created_at >= $1 AND ls.created_at < $2 AND ls.deleted_at IS NULL
+		  AND ls.location_id = ` + locationSQL("$3") + `
+		GROUP BY lsi.product_id`
+
+	rows, err := s.db.Query(query, start, end, nullInt64(locationID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sold := make(map[int64]float64)
+	for rows.Next() {
+		var productID int64
+		var quantity float64
+		if err := rows.Scan(&productID, &quantity); err != nil {
+			return nil, err
+		}
+		sold[productID] = quantity
+	}
+	return sold, rows.Err()
+}
+
 func (s *PostgresLocalSaleStore) CreateInTx(tx *sql.Tx, sale *LocalSale, items []LocalSaleItem) error {
 	// 1. Create the LocalSale record
 	saleQuery := `
-		INSERT INTO local_sales (payment_method_id, subtotal, total)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at, updated_at`
-	err := tx.QueryRow(saleQuery, sale.PaymentMethodID, sale.Subtotal, sale.Total).
-		Scan(&sale.ID, &sale.CreatedAt, &sale.UpdatedAt)
+		INSERT INTO local_sales (payment_method_id, shift_id, location_id, subtotal, total)
+		VALUES ($1, $2, ` + locationSQL("$3") + `, $4, $5)
+		RETURNING id, location_id, created_at, updated_at`
+	err := tx.QueryRow(saleQuery, sale.PaymentMethodID, sale.ShiftID, nullInt64(sale.LocationID), sale.Subtotal, sale.Total).
+		Scan(&sale.ID, &sale.LocationID, &sale.CreatedAt, &sale.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -157,16 +238,19 @@ func (s *PostgresLocalSaleStore) CreateInTx(tx *sql.Tx, sale *LocalSale, items [
 		}
 	}
 	sale.Items = items
-	return nil
+
+	// 3. Queue the local_sale.created webhooks, sent only if the sale commits.
+	_, err = enqueueWebhookEvent(tx, WebhookLocalSaleCreated, sale)
+	return err
 }
 
 func (s *PostgresLocalSaleStore) GetByID(id int64) (*LocalSale, error) {
 	query := `
-		SELECT id, payment_method_id, subtotal::text, total::text, created_at, updated_at, deleted_at
+		SELECT id, payment_method_id, shift_id, location_id, subtotal::text, total::text, created_at, updated_at, deleted_at
 		FROM local_sales WHERE id = $1`
 
 	sale := &LocalSale{}
-	err := s.db.QueryRow(query, id).Scan(&sale.ID, &sale.PaymentMethodID, &sale.Subtotal, &sale.Total, &sale.CreatedAt, &sale.UpdatedAt, &sale.DeletedAt)
+	err := s.db.QueryRow(query, id).Scan(&sale.ID, &sale.PaymentMethodID, &sale.ShiftID, &sale.LocationID, &sale.Subtotal, &sale.Total, &sale.CreatedAt, &sale.UpdatedAt, &sale.DeletedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -196,7 +280,7 @@ func (s *PostgresLocalSaleStore) GetByID(id int64) (*LocalSale, error) {
 
 func (s *PostgresLocalSaleStore) ListAll() ([]*LocalSale, error) {
 	query := `
-		SELECT id, payment_method_id, subtotal::text, total::text, created_at, updated_at, deleted_at
+		SELECT id, payment_method_id, shift_id, location_id, subtotal::text, total::text, created_at, updated_at, deleted_at
 		FROM local_sales ORDER BY created_at DESC`
 
 	rows, err := s.db.Query(query)
@@ -208,7 +292,7 @@ func (s *PostgresLocalSaleStore) ListAll() ([]*LocalSale, error) {
 	var sales []*LocalSale
 	for rows.Next() {
 		var sale LocalSale
-		if err := rows.Scan(&sale.ID, &sale.PaymentMethodID, &sale.Subtotal, &sale.Total, &sale.CreatedAt, &sale.UpdatedAt, &sale.DeletedAt); err != nil {
+		if err := rows.Scan(&sale.ID, &sale.PaymentMethodID, &sale.ShiftID, &sale.LocationID, &sale.Subtotal, &sale.Total, &sale.CreatedAt, &sale.UpdatedAt, &sale.DeletedAt); err != nil {
 			return nil, err
 		}
 		sales = append(sales, &sale)
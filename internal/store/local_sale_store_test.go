@@ -1,6 +1,7 @@
 package store
 
 import (
+	"database/sql"
 	"testing"
 	"time"
 
@@ -122,6 +123,49 @@ func TestLocalSaleStore_GetStats(t *testing.T) {
 	assert.NotContains(t, stats.ByMethod, "Other")
 }
 
+func TestLocalSaleStore_QuantitiesSold(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	s := NewPostgresLocalSaleStore(db)
+	pm := &PaymentMethod{Name: "Cash", Reference: "cash"}
+	require.NoError(t, NewPostgresPaymentMethodStore(db).CreatePaymentMethod(pm))
+	prod := setupProductForStockTest(t, db)
+
+	createSale := func(qty float64, date time.Time) int64 {
+		sale := &LocalSale{PaymentMethodID: pm.ID, Subtotal: "10", Total: "10"}
+		items := []LocalSaleItem{{ProductID: prod.ID, Quantity: qty, UnitPrice: "10", LineSubtotal: "10"}}
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		require.NoError(t, s.CreateInTx(tx, sale, items))
+		require.NoError(t, tx.Commit())
+		_, err = db.Exec("UPDATE local_sales SET created_at = $1 WHERE id = $2", date, sale.ID)
+		require.NoError(t, err)
+		return sale.ID
+	}
+
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	createSale(2, dayStart.Add(time.Hour))
+	createSale(3, dayStart.Add(2*time.Hour))
+	voided := createSale(7, dayStart.Add(3*time.Hour))
+	createSale(11, dayStart.Add(-time.Hour)) // previous day
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteInTx(tx, voided))
+	require.NoError(t, tx.Commit())
+
+	tx, err = db.Begin()
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.DeleteInTx(tx, voided), sql.ErrNoRows)
+	require.NoError(t, tx.Rollback())
+
+	sold, err := s.QuantitiesSold(dayStart, dayStart.AddDate(0, 0, 1), 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]float64{prod.ID: 5}, sold)
+}
+
 func TestLocalSaleStore_ListByDate(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -2,35 +2,60 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// ErrNegativeStock is returned when a write would leave a product that
+// doesn't allow it with negative stock.
+var ErrNegativeStock = errors.New("el stock no puede quedar negativo")
+
 type LocalStock struct {
-	ID        int64     `json:"id"`
-	ProductID int64     `json:"product_id"`
-	Quantity  int       `json:"quantity"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           int64     `json:"id"`
+	ProductID    int64     `json:"product_id"`
+	LocationID   int64     `json:"location_id"`
+	LocationName string    `json:"location_name,omitempty"`
+	Quantity     float64   `json:"quantity"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// LocalStockStore keeps stock per product per location. A zero locationID,
+// here and in StockChange, stands for the default location.
 type LocalStockStore interface {
-	Create(productID int64, quantity int) (*LocalStock, error)
-	GetByProductID(productID int64) (*LocalStock, error)
+	Create(productID int64, quantity float64, change StockChange) (*LocalStock, error)
+	GetByProductID(productID, locationID int64) (*LocalStock, error)
+	// ListByProductID returns the stock of a product at every location.
+	ListByProductID(productID int64) ([]*LocalStock, error)
 	ListAll() ([]*LocalStock, error)
-	ListStockWithProductDetails() ([]*ProductStock, error)
-	AdjustQuantity(productID int64, delta int) (*LocalStock, error)
-	GetLowStockAlerts(threshold int) ([]*ProductStock, error)
+	ListStockWithProductDetails(locationID int64) ([]*ProductStock, error)
+	AdjustQuantity(productID int64, delta float64, change StockChange) (*LocalStock, error)
+	// GetLowStockAlerts looks at the default location, where sales happen.
+	// Products at or below their own minimum are listed; those without one
+	// are compared to defaultThreshold.
+	GetLowStockAlerts(defaultThreshold float64) ([]*ProductStock, error)
 
-	// Transactional methods
-	CreateInTx(tx *sql.Tx, productID int64, quantity int) (*LocalStock, error)
-	AdjustQuantityTx(tx *sql.Tx, productID int64, delta int) (*LocalStock, error)
+	// Transactional methods. Writes record the change in stock_movements.
+	CreateInTx(tx *sql.Tx, productID int64, quantity float64, change StockChange) (*LocalStock, error)
+	AdjustQuantityTx(tx *sql.Tx, productID int64, delta float64, change StockChange) (*LocalStock, error)
+	// LockByProductIDsTx locks the stock rows of the given products at a
+	// location until the transaction ends. Products without stock there are
+	// missing from the map.
+	LockByProductIDsTx(tx *sql.Tx, locationID int64, productIDs []int64) (map[int64]*LocalStock, error)
+	// EnsureTx creates an empty stock row for the product at the location if
+	// it has none.
+	EnsureTx(tx *sql.Tx, locationID, productID int64) error
 }
 
 type ProductStock struct {
-	ProductID   int64   `json:"product_id"`
-	ProductName string  `json:"product_name"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
+	ProductID   int64    `json:"product_id"`
+	ProductName string   `json:"product_name"`
+	Price       float64  `json:"price"`
+	SaleUnit    string   `json:"sale_unit"`
+	Quantity    float64  `json:"quantity"`
+	MinStock    *float64 `json:"min_stock,omitempty"`
 }
 
 type PostgresLocalStockStore struct {
@@ -41,14 +66,24 @@ func NewPostgresLocalStockStore(db *sql.DB) *PostgresLocalStockStore {
 	return &PostgresLocalStockStore{DB: db}
 }
 
-func (s *PostgresLocalStockStore) ListStockWithProductDetails() ([]*ProductStock, error) {
+const localStockColumns = `id, product_id, location_id, quantity, created_at, updated_at`
+
+func scanLocalStock(row interface{ Scan(dest ...any) error }) (*LocalStock, error) {
+	var stock LocalStock
+	if err := row.Scan(&stock.ID, &stock.ProductID, &stock.LocationID, &stock.Quantity, &stock.CreatedAt, &stock.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
+
+func (s *PostgresLocalStockStore) ListStockWithProductDetails(locationID int64) ([]*ProductStock, error) {
 	query := `
-		SELECT p.id, p.name, p.unit_price, COALESCE(ls.quantity, 0)
+		SELECT p.id, p.name, p.unit_price, p.sale_unit, COALESCE(ls.quantity, 0), p.min_stock
 		FROM products p
-		LEFT JOIN local_stock ls ON p.id = ls.product_id
+		LEFT JOIN local_stock ls ON p.id = ls.product_id AND ls.location_id = ` + locationSQL("$1") + `
 		ORDER BY p.name`
 
-	rows, err := s.DB.Query(query)
+	rows, err := s.DB.Query(query, nullInt64(locationID))
 	if err != nil {
 		return nil, err
 	}
@@ -57,62 +92,89 @@ func (s *PostgresLocalStockStore) ListStockWithProductDetails() ([]*ProductStock
 	var stocks []*ProductStock
 	for rows.Next() {
 		var ps ProductStock
-		if err := rows.Scan(&ps.ProductID, &ps.ProductName, &ps.Price, &ps.Quantity); err != nil {
+		if err := rows.Scan(&ps.ProductID, &ps.ProductName, &ps.Price, &ps.SaleUnit, &ps.Quantity, &ps.MinStock); err != nil {
 			return nil, err
 		}
 		stocks = append(stocks, &ps)
 	}
-	return stocks, nil
+	return stocks, rows.Err()
 }
 
-func (s *PostgresLocalStockStore) Create(productID int64, quantity int) (*LocalStock, error) {
-	query := `
-		INSERT INTO local_stock (product_id, quantity)
-		VALUES ($1, $2)
-		RETURNING id, product_id, quantity, created_at, updated_at`
+func (s *PostgresLocalStockStore) Create(productID int64, quantity float64, change StockChange) (*LocalStock, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	var stock LocalStock
-	err := s.DB.QueryRow(query, productID, quantity).Scan(&stock.ID, &stock.ProductID, &stock.Quantity, &stock.CreatedAt, &stock.UpdatedAt)
+	stock, err := s.CreateInTx(tx, productID, quantity, change)
 	if err != nil {
 		return nil, err
 	}
-	return &stock, nil
+	return stock, tx.Commit()
 }
 
-func (s *PostgresLocalStockStore) CreateInTx(tx *sql.Tx, productID int64, quantity int) (*LocalStock, error) {
+func (s *PostgresLocalStockStore) CreateInTx(tx *sql.Tx, productID int64, quantity float64, change StockChange) (*LocalStock, error) {
 	query := `
-		INSERT INTO local_stock (product_id, quantity)
-		VALUES ($1, $2)
-		RETURNING id, product_id, quantity, created_at, updated_at`
+		INSERT INTO local_stock (product_id, location_id, quantity)
+		VALUES ($1, ` + locationSQL("$2") + `, $3)
+		RETURNING ` + localStockColumns
 
-	var stock LocalStock
-	err := tx.QueryRow(query, productID, quantity).Scan(&stock.ID, &stock.ProductID, &stock.Quantity, &stock.CreatedAt, &stock.UpdatedAt)
+	stock, err := scanLocalStock(tx.QueryRow(query, productID, nullInt64(change.LocationID), quantity))
 	if err != nil {
+		return nil, stockWriteError(err)
+	}
+	if err := insertStockMovement(tx, stock, quantity, change); err != nil {
 		return nil, err
 	}
-	return &stock, nil
+	return stock, nil
 }
 
-func (s *PostgresLocalStockStore) GetByProductID(productID int64) (*LocalStock, error) {
+func (s *PostgresLocalStockStore) GetByProductID(productID, locationID int64) (*LocalStock, error) {
 	query := `
-		SELECT id, product_id, quantity, created_at, updated_at
+		SELECT ` + localStockColumns + `
 		FROM local_stock
-		WHERE product_id = $1`
+		WHERE product_id = $1 AND location_id = ` + locationSQL("$2")
 
-	var stock LocalStock
-	err := s.DB.QueryRow(query, productID).Scan(&stock.ID, &stock.ProductID, &stock.Quantity, &stock.CreatedAt, &stock.UpdatedAt)
+	stock, err := scanLocalStock(s.DB.QueryRow(query, productID, nullInt64(locationID)))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Not found is not an error
 		}
 		return nil, err
 	}
-	return &stock, nil
+	return stock, nil
+}
+
+func (s *PostgresLocalStockStore) ListByProductID(productID int64) ([]*LocalStock, error) {
+	query := `
+		SELECT ls.id, ls.product_id, ls.location_id, ls.quantity, ls.created_at, ls.updated_at, l.name
+		FROM local_stock ls
+		JOIN stock_locations l ON l.id = ls.location_id
+		WHERE ls.product_id = $1
+		ORDER BY l.is_default DESC, l.name`
+
+	rows, err := s.DB.Query(query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocks []*LocalStock
+	for rows.Next() {
+		var stock LocalStock
+		if err := rows.Scan(&stock.ID, &stock.ProductID, &stock.LocationID, &stock.Quantity, &stock.CreatedAt, &stock.UpdatedAt,
+			&stock.LocationName); err != nil {
+			return nil, err
+		}
+		stocks = append(stocks, &stock)
+	}
+	return stocks, rows.Err()
 }
 
 func (s *PostgresLocalStockStore) ListAll() ([]*LocalStock, error) {
 	query := `
-		SELECT id, product_id, quantity, created_at, updated_at
+		SELECT ` + localStockColumns + `
 		FROM local_stock
 		ORDER BY id`
 
@@ -124,54 +186,104 @@ func (s *PostgresLocalStockStore) ListAll() ([]*LocalStock, error) {
 
 	var stocks []*LocalStock
 	for rows.Next() {
-		var stock LocalStock
-		if err := rows.Scan(&stock.ID, &stock.ProductID, &stock.Quantity, &stock.CreatedAt, &stock.UpdatedAt); err != nil {
+		stock, err := scanLocalStock(rows)
+		if err != nil {
 			return nil, err
 		}
-		stocks = append(stocks, &stock)
+		stocks = append(stocks, stock)
 	}
 	return stocks, nil
 }
 
-func (s *PostgresLocalStockStore) AdjustQuantity(productID int64, delta int) (*LocalStock, error) {
+func (s *PostgresLocalStockStore) AdjustQuantity(productID int64, delta float64, change StockChange) (*LocalStock, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stock, err := s.AdjustQuantityTx(tx, productID, delta, change)
+	if err != nil {
+		return nil, err
+	}
+	return stock, tx.Commit()
+}
+
+func (s *PostgresLocalStockStore) AdjustQuantityTx(tx *sql.Tx, productID int64, delta float64, change StockChange) (*LocalStock, error) {
 	query := `
 		UPDATE local_stock
 		SET quantity = quantity + $1, updated_at = NOW()
-		WHERE product_id = $2
-		RETURNING id, product_id, quantity, created_at, updated_at`
+		WHERE product_id = $2 AND location_id = ` + locationSQL("$3") + `
+		RETURNING ` + localStockColumns
 
-	var stock LocalStock
-	err := s.DB.QueryRow(query, delta, productID).Scan(&stock.ID, &stock.ProductID, &stock.Quantity, &stock.CreatedAt, &stock.UpdatedAt)
+	stock, err := scanLocalStock(tx.QueryRow(query, delta, productID, nullInt64(change.LocationID)))
 	if err != nil {
+		return nil, stockWriteError(err)
+	}
+	if err := insertStockMovement(tx, stock, delta, change); err != nil {
 		return nil, err
 	}
-	return &stock, nil
+	return stock, nil
 }
 
-func (s *PostgresLocalStockStore) AdjustQuantityTx(tx *sql.Tx, productID int64, delta int) (*LocalStock, error) {
+func (s *PostgresLocalStockStore) LockByProductIDsTx(tx *sql.Tx, locationID int64, productIDs []int64) (map[int64]*LocalStock, error) {
+	// Rows are locked in product order so concurrent sales of the same
+	// products queue up instead of deadlocking.
 	query := `
-		UPDATE local_stock
-		SET quantity = quantity + $1, updated_at = NOW()
-		WHERE product_id = $2
-		RETURNING id, product_id, quantity, created_at, updated_at`
+		SELECT ` + localStockColumns + `
+		FROM local_stock
+		WHERE product_id = ANY($1) AND location_id = ` + locationSQL("$2") + `
+		ORDER BY product_id
+		FOR UPDATE`
 
-	var stock LocalStock
-	err := tx.QueryRow(query, delta, productID).Scan(&stock.ID, &stock.ProductID, &stock.Quantity, &stock.CreatedAt, &stock.UpdatedAt)
+	rows, err := tx.Query(query, productIDs, nullInt64(locationID))
 	if err != nil {
 		return nil, err
 	}
-	return &stock, nil
+	defer rows.Close()
+
+	stocks := make(map[int64]*LocalStock)
+	for rows.Next() {
+		stock, err := scanLocalStock(rows)
+		if err != nil {
+			return nil, err
+		}
+		stocks[stock.ProductID] = stock
+	}
+	return stocks, rows.Err()
+}
+
+func (s *PostgresLocalStockStore) EnsureTx(tx *sql.Tx, locationID, productID int64) error {
+	query := `
+		INSERT INTO local_stock (product_id, location_id, quantity)
+		VALUES ($1, ` + locationSQL("$2") + `, 0)
+		ON CONFLICT (product_id, location_id) DO NOTHING`
+
+	_, err := tx.Exec(query, productID, nullInt64(locationID))
+	return err
+}
+
+// stockWriteError maps the non-negative stock trigger to ErrNegativeStock.
+func stockWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "local_stock_non_negative" {
+		return ErrNegativeStock
+	}
+	return err
 }
 
-func (s *PostgresLocalStockStore) GetLowStockAlerts(threshold int) ([]*ProductStock, error) {
+func (s *PostgresLocalStockStore) GetLowStockAlerts(defaultThreshold float64) ([]*ProductStock, error) {
+	// A product with a minimum alerts even before it has a stock record.
 	query := `
-		SELECT p.id, p.name, p.unit_price, ls.quantity
+		SELECT p.id, p.name, p.unit_price, p.sale_unit, COALESCE(ls.quantity, 0), p.min_stock
 		FROM products p
-		JOIN local_stock ls ON p.id = ls.product_id
-		WHERE ls.quantity <= $1 AND p.deleted_at IS NULL
-		ORDER BY ls.quantity ASC`
+		LEFT JOIN local_stock ls ON p.id = ls.product_id AND ls.location_id = ` + defaultLocationSQL + `
+		WHERE (ls.id IS NOT NULL OR p.min_stock IS NOT NULL)
+		  AND COALESCE(ls.quantity, 0) <= COALESCE(p.min_stock, $1)
+		  AND p.deleted_at IS NULL
+		ORDER BY COALESCE(ls.quantity, 0) ASC`
 
-	rows, err := s.DB.Query(query, threshold)
+	rows, err := s.DB.Query(query, defaultThreshold)
 	if err != nil {
 		return nil, err
 	}
@@ -180,7 +292,7 @@ func (s *PostgresLocalStockStore) GetLowStockAlerts(threshold int) ([]*ProductSt
 	var stocks []*ProductStock
 	for rows.Next() {
 		var ps ProductStock
-		if err := rows.Scan(&ps.ProductID, &ps.ProductName, &ps.Price, &ps.Quantity); err != nil {
+		if err := rows.Scan(&ps.ProductID, &ps.ProductName, &ps.Price, &ps.SaleUnit, &ps.Quantity, &ps.MinStock); err != nil {
 			return nil, err
 		}
 		stocks = append(stocks, &ps)
@@ -20,7 +20,7 @@ func TestLocalStockStore_Create(t *testing.T) {
 	tests := []struct {
 		name      string
 		productID int64
-		quantity  int
+		quantity  float64
 		wantErr   bool
 	}{
 		{
@@ -39,7 +39,7 @@ func TestLocalStockStore_Create(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			stock, err := s.Create(tt.productID, tt.quantity)
+			stock, err := s.Create(tt.productID, tt.quantity, StockChange{Reason: MovementInitial})
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -59,7 +59,7 @@ func TestLocalStockStore_GetByProductID(t *testing.T) {
 
 	s := NewPostgresLocalStockStore(db)
 	prod := setupProductForStockTest(t, db)
-	_, err := s.Create(prod.ID, 100)
+	_, err := s.Create(prod.ID, 100, StockChange{Reason: MovementInitial})
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -83,7 +83,7 @@ func TestLocalStockStore_GetByProductID(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			stock, err := s.GetByProductID(tt.productID)
+			stock, err := s.GetByProductID(tt.productID, 0)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -91,7 +91,7 @@ func TestLocalStockStore_GetByProductID(t *testing.T) {
 			require.NoError(t, err)
 			if tt.shouldExist {
 				assert.NotNil(t, stock)
-				assert.Equal(t, 100, stock.Quantity)
+				assert.Equal(t, 100.0, stock.Quantity)
 			} else {
 				assert.Nil(t, stock)
 			}
@@ -104,22 +104,23 @@ func TestLocalStockStore_AdjustQuantity(t *testing.T) {
 
 	s := NewPostgresLocalStockStore(db)
 	prod := setupProductForStockTest(t, db)
-	_, err := s.Create(prod.ID, 100)
+	_, err := s.Create(prod.ID, 100, StockChange{Reason: MovementInitial})
 	require.NoError(t, err)
 
 	tests := []struct {
 		name    string
-		delta   int
-		wantQty int
+		delta   float64
+		wantQty float64
 		wantErr bool
 	}{
 		{name: "decrease stock", delta: -20, wantQty: 80, wantErr: false},
 		{name: "increase stock", delta: 30, wantQty: 110, wantErr: false},
+		{name: "below zero", delta: -200, wantErr: true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			adjusted, err := s.AdjustQuantity(prod.ID, tt.delta)
+			adjusted, err := s.AdjustQuantity(prod.ID, tt.delta, StockChange{Reason: MovementAdjustment})
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -128,7 +129,7 @@ func TestLocalStockStore_AdjustQuantity(t *testing.T) {
 			assert.Equal(t, tt.wantQty, adjusted.Quantity)
 
 			// Verify persistence
-			stock, err := s.GetByProductID(prod.ID)
+			stock, err := s.GetByProductID(prod.ID, 0)
 			require.NoError(t, err)
 			assert.Equal(t, tt.wantQty, stock.Quantity)
 		})
@@ -143,9 +144,9 @@ func TestLocalStockStore_ListAll(t *testing.T) {
 	prod1 := setupProductForStockTest(t, db)
 	prod2 := setupProductForStockTest(t, db)
 
-	_, err := s.Create(prod1.ID, 10)
+	_, err := s.Create(prod1.ID, 10, StockChange{Reason: MovementInitial})
 	require.NoError(t, err)
-	_, err = s.Create(prod2.ID, 20)
+	_, err = s.Create(prod2.ID, 20, StockChange{Reason: MovementInitial})
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -186,11 +187,11 @@ func TestLocalStockStore_ListStockWithProductDetails(t *testing.T) {
 	require.NoError(t, productStore.CreateProduct(prodB))
 
 	// Create stock for A only
-	_, err := s.Create(prodA.ID, 50)
+	_, err := s.Create(prodA.ID, 50, StockChange{Reason: MovementInitial})
 	require.NoError(t, err)
 
 	// Test
-	list, err := s.ListStockWithProductDetails()
+	list, err := s.ListStockWithProductDetails(0)
 	require.NoError(t, err)
 	require.Len(t, list, 2)
 
@@ -198,13 +199,119 @@ func TestLocalStockStore_ListStockWithProductDetails(t *testing.T) {
 	assert.Equal(t, prodA.ID, list[0].ProductID)
 	assert.Equal(t, "A Product", list[0].ProductName)
 	assert.Equal(t, 10.5, list[0].Price)
-	assert.Equal(t, 50, list[0].Quantity)
+	assert.Equal(t, 50.0, list[0].Quantity)
 
 	// B Product (should have 0 quantity)
 	assert.Equal(t, prodB.ID, list[1].ProductID)
 	assert.Equal(t, "B Product", list[1].ProductName)
 	assert.Equal(t, 20.0, list[1].Price)
-	assert.Equal(t, 0, list[1].Quantity)
+	assert.Equal(t, 0.0, list[1].Quantity)
+}
+
+func TestLocalStockStore_NonNegative(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	s := NewPostgresLocalStockStore(db)
+	productStore := NewPostgresProductStore(db)
+
+	t.Run("rejected by default", func(t *testing.T) {
+		prod := setupProductForStockTest(t, db)
+		_, err := s.Create(prod.ID, 2, StockChange{Reason: MovementInitial})
+		require.NoError(t, err)
+
+		_, err = s.AdjustQuantity(prod.ID, -3, StockChange{Reason: MovementAdjustment})
+		assert.ErrorIs(t, err, ErrNegativeStock)
+
+		_, err = s.Create(setupProductForStockTest(t, db).ID, -1, StockChange{Reason: MovementInitial})
+		assert.ErrorIs(t, err, ErrNegativeStock)
+
+		stock, err := s.GetByProductID(prod.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2.0, stock.Quantity)
+	})
+
+	t.Run("allowed per product", func(t *testing.T) {
+		prod := setupProductForStockTest(t, db)
+		prod.AllowNegativeStock = true
+		require.NoError(t, productStore.UpdateProduct(prod))
+
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		require.NoError(t, s.EnsureTx(tx, 0, prod.ID))
+		require.NoError(t, s.EnsureTx(tx, 0, prod.ID))
+		adjusted, err := s.AdjustQuantityTx(tx, prod.ID, -3, StockChange{Reason: MovementAdjustment})
+		require.NoError(t, err)
+		assert.Equal(t, -3.0, adjusted.Quantity)
+
+		locked, err := s.LockByProductIDsTx(tx, 0, []int64{prod.ID})
+		require.NoError(t, err)
+		require.Contains(t, locked, prod.ID)
+		assert.Equal(t, -3.0, locked[prod.ID].Quantity)
+	})
+}
+
+func TestLocalStockStore_GetLowStockAlerts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	s := NewPostgresLocalStockStore(db)
+	productStore := NewPostgresProductStore(db)
+
+	minFive := 5.0
+	withMin := setupProductForStockTest(t, db)
+	withMin.MinStock = &minFive
+	require.NoError(t, productStore.UpdateProduct(withMin))
+	_, err := s.Create(withMin.ID, 6, StockChange{Reason: MovementInitial})
+	require.NoError(t, err)
+
+	noMin := setupProductForStockTest(t, db)
+	_, err = s.Create(noMin.ID, 3, StockChange{Reason: MovementInitial})
+	require.NoError(t, err)
+
+	noStock := setupProductForStockTest(t, db)
+	noStock.MinStock = &minFive
+	require.NoError(t, productStore.UpdateProduct(noStock))
+
+	alertIDs := func() []int64 {
+		t.Helper()
+		alerts, err := s.GetLowStockAlerts(4)
+		require.NoError(t, err)
+		var ids []int64
+		for _, a := range alerts {
+			ids = append(ids, a.ProductID)
+		}
+		return ids
+	}
+
+	ids := alertIDs()
+	assert.NotContains(t, ids, withMin.ID, "above its own minimum")
+	assert.Contains(t, ids, noMin.ID, "at or below the default threshold")
+	assert.Contains(t, ids, noStock.ID, "a minimum alerts without a stock record")
+
+	_, err = s.AdjustQuantity(withMin.ID, -1, StockChange{Reason: MovementAdjustment})
+	require.NoError(t, err)
+	assert.Contains(t, alertIDs(), withMin.ID)
+
+	alerts, err := s.GetLowStockAlerts(4)
+	require.NoError(t, err)
+	for _, a := range alerts {
+		if a.ProductID == withMin.ID {
+			require.NotNil(t, a.MinStock)
+			assert.Equal(t, minFive, *a.MinStock)
+		}
+	}
+
+	stocks, err := s.ListStockWithProductDetails(0)
+	require.NoError(t, err)
+	for _, ps := range stocks {
+		if ps.ProductID == noStock.ID {
+			require.NotNil(t, ps.MinStock)
+			assert.Equal(t, minFive, *ps.MinStock)
+		}
+	}
 }
 
 func setupProductForStockTest(t *testing.T, db *sql.DB) *Product {
@@ -0,0 +1,374 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrLotNumberTaken is returned when a product already has a lot with the
+// given number.
+var ErrLotNumberTaken = errors.New("ya existe un lote con ese número para el producto")
+
+const lotDateLayout = "2006-01-02"
+
+// NewLot describes the lot that stock being added comes from. An empty Number
+// is generated from the production date.
+type NewLot struct {
+	Number     string
+	ProducedOn time.Time
+	BestBefore *time.Time
+}
+
+// StockLot is the part of a production batch of a product held at a location.
+// Quantity is what is left of it there.
+type StockLot struct {
+	ID              int64      `json:"id"`
+	ProductID       int64      `json:"product_id"`
+	ProductName     string     `json:"product_name"`
+	SaleUnit        string     `json:"sale_unit"`
+	LocationID      int64      `json:"location_id"`
+	LocationName    string     `json:"location_name"`
+	LotNumber       string     `json:"lot_number"`
+	ProducedOn      time.Time  `json:"produced_on"`
+	BestBefore      *time.Time `json:"best_before"`
+	InitialQuantity float64    `json:"initial_quantity"`
+	Quantity        float64    `json:"quantity"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// DaysToExpiry counts the days from now's date to the best-before date:
+// 0 expires today, negative is already expired. ok is false without a date.
+func (l *StockLot) DaysToExpiry(now time.Time) (days int, ok bool) {
+	if l.BestBefore == nil {
+		return 0, false
+	}
+	bb := time.Date(l.BestBefore.Year(), l.BestBefore.Month(), l.BestBefore.Day(), 0, 0, 0, 0, time.UTC)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return int(bb.Sub(today).Hours() / 24), true
+}
+
+// LotMovement is the part of a stock movement that hit a lot.
+type LotMovement struct {
+	ID            int64     `json:"id"`
+	LotID         int64     `json:"lot_id"`
+	MovementID    int64     `json:"movement_id"`
+	Quantity      float64   `json:"quantity"`
+	Reason        string    `json:"reason"`
+	UserID        *int64    `json:"user_id"`
+	Username      string    `json:"username,omitempty"`
+	ReferenceType *string   `json:"reference_type"`
+	ReferenceID   *int64    `json:"reference_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// LotStore reads lots. Lots are written by LocalStockStore along with the
+// stock movements that create and consume them.
+type LotStore interface {
+	GetByID(id int64) (*StockLot, error)
+	// ListByProduct returns the lots of a product at every location, in
+	// consumption order. Empty lots are only included when asked for.
+	ListByProduct(productID int64, includeEmpty bool) ([]*StockLot, error)
+	// ListExpiring returns the lots still in stock whose best-before date is
+	// on or before the given day, soonest first.
+	ListExpiring(until time.Time) ([]*StockLot, error)
+	ListMovements(lotID int64) ([]*LotMovement, error)
+}
+
+type PostgresLotStore struct {
+	db *sql.DB
+}
+
+func NewPostgresLotStore(db *sql.DB) *PostgresLotStore {
+	return &PostgresLotStore{db: db}
+}
+
+// lotFIFOOrder is the order lots are consumed in: first to expire first,
+// lots without a best-before date last.
+const lotFIFOOrder = `l.best_before ASC NULLS LAST, l.produced_on, l.id`
+
+const lotQuery = `
+	SELECT l.id, l.product_id, p.name, p.sale_unit, l.location_id, sl.name, l.lot_number, l.produced_on, l.best_before,
+	       l.initial_quantity, l.quantity, l.created_at
+	FROM stock_lots l
+	JOIN products p ON p.id = l.product_id
+	JOIN stock_locations sl ON sl.id = l.location_id`
+
+func scanLot(row interface{ Scan(dest ...any) error }) (*StockLot, error) {
+	var l StockLot
+	err := row.Scan(&l.ID, &l.ProductID, &l.ProductName, &l.SaleUnit, &l.LocationID, &l.LocationName, &l.LotNumber, &l.ProducedOn, &l.BestBefore,
+		&l.InitialQuantity, &l.Quantity, &l.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (s *PostgresLotStore) queryLots(query string, args ...any) ([]*StockLot, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []*StockLot
+	for rows.Next() {
+		lot, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+func (s *PostgresLotStore) GetByID(id int64) (*StockLot, error) {
+	lot, err := scanLot(s.db.QueryRow(lotQuery+` WHERE l.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return lot, err
+}
+
+func (s *PostgresLotStore) ListByProduct(productID int64, includeEmpty bool) ([]*StockLot, error) {
+	return s.queryLots(lotQuery+`
+	WHERE l.product_id = $1 AND ($2 OR l.quantity > 0)
+	ORDER BY l.quantity = 0, sl.is_default DESC, sl.name, `+lotFIFOOrder, productID, includeEmpty)
+}
+
+func (s *PostgresLotStore) ListExpiring(until time.Time) ([]*StockLot, error) {
+	return s.queryLots(lotQuery+`
+	WHERE l.quantity > 0 AND l.best_before <= $1::DATE AND p.deleted_at IS NULL
+	ORDER BY l.best_before, p.name, l.id`, until.Format(lotDateLayout))
+}
+
+func (s *PostgresLotStore) ListMovements(lotID int64) ([]*LotMovement, error) {
+	query := `
+	SELECT lm.id, lm.lot_id, lm.movement_id, lm.quantity, sm.reason, sm.user_id, COALESCE(u.username, ''),
+	       sm.reference_type, sm.reference_id, sm.created_at
+	FROM stock_lot_movements lm
+	JOIN stock_movements sm ON sm.id = lm.movement_id
+	LEFT JOIN users u ON u.id = sm.user_id
+	WHERE lm.lot_id = $1
+	ORDER BY sm.created_at, lm.id`
+
+	rows, err := s.db.Query(query, lotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []*LotMovement
+	for rows.Next() {
+		var m LotMovement
+		if err := rows.Scan(&m.ID, &m.LotID, &m.MovementID, &m.Quantity, &m.Reason, &m.UserID, &m.Username,
+			&m.ReferenceType, &m.ReferenceID, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, &m)
+	}
+	return movements, rows.Err()
+}
+
+// createLotTx opens a lot at a location with the quantity added by a movement.
+func createLotTx(tx *sql.Tx, productID, locationID int64, quantity float64, movementID int64, lot *NewLot) error {
+	producedOn := lot.ProducedOn
+	if producedOn.IsZero() {
+		producedOn = time.Now()
+	}
+	number := lot.Number
+	if number == "" {
+		var n int
+		err := tx.QueryRow(`SELECT COUNT(DISTINCT lot_number) FROM stock_lots WHERE product_id = $1 AND produced_on = $2::DATE`,
+			productID, producedOn.Format(lotDateLayout)).Scan(&n)
+		if err != nil {
+			return err
+		}
+		number = fmt.Sprintf("L%s-%d", producedOn.Format("060102"), n+1)
+	}
+	var bestBefore sql.NullString
+	if lot.BestBefore != nil {
+		bestBefore = sql.NullString{String: lot.BestBefore.Format(lotDateLayout), Valid: true}
+	}
+
+	query := `
+	INSERT INTO stock_lots (product_id, location_id, lot_number, produced_on, best_before, initial_quantity, quantity)
+	VALUES ($1, $2, $3, $4::DATE, $5::DATE, $6, $6)
+	RETURNING id`
+
+	var lotID int64
+	err := tx.QueryRow(query, productID, locationID, number, producedOn.Format(lotDateLayout), bestBefore, quantity).Scan(&lotID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "stock_lots_lot_number_key" {
+			return ErrLotNumberTaken
+		}
+		return err
+	}
+	return insertLotMovement(tx, lotID, movementID, quantity)
+}
+
+// consumeLotsTx takes quantity out of the product's lots at a location in
+// FIFO order. What the lots can't cover comes out of untracked stock.
+func consumeLotsTx(tx *sql.Tx, productID, locationID int64, quantity float64, movementID int64) error {
+	rows, err := tx.Query(`
+	SELECT l.id, l.quantity
+	FROM stock_lots l
+	WHERE l.product_id = $1 AND l.location_id = $2 AND l.quantity > 0
+	ORDER BY `+lotFIFOOrder+`
+	FOR UPDATE`, productID, locationID)
+	if err != nil {
+		return err
+	}
+
+	type lotQty struct {
+		id  int64
+		qty float64
+	}
+	var lots []lotQty
+	for rows.Next() {
+		var l lotQty
+		if err := rows.Scan(&l.id, &l.qty); err != nil {
+			rows.Close()
+			return err
+		}
+		lots = append(lots, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range lots {
+		if quantity <= 0 {
+			break
+		}
+		take := min(quantity, l.qty)
+		if _, err := tx.Exec(`UPDATE stock_lots SET quantity = quantity - $1 WHERE id = $2`, take, l.id); err != nil {
+			return err
+		}
+		if err := insertLotMovement(tx, l.id, movementID, -take); err != nil {
+			return err
+		}
+		quantity -= take
+	}
+	return nil
+}
+
+// restoreLotsTx puts back into their lots up to quantity of what earlier
+// movements with the same reference took, e.g. when a sale is voided.
+func restoreLotsTx(tx *sql.Tx, productID int64, quantity float64, movementID int64, referenceType string, referenceID int64) error {
+	rows, err := tx.Query(`
+	SELECT lm.lot_id, -SUM(lm.quantity)
+	FROM stock_lot_movements lm
+	JOIN stock_movements sm ON sm.id = lm.movement_id
+	WHERE sm.product_id = $1 AND sm.reference_type = $2 AND sm.reference_id = $3 AND sm.id <> $4
+	GROUP BY lm.lot_id
+	HAVING SUM(lm.quantity) < 0
+	ORDER BY lm.lot_id DESC`, productID, referenceType, referenceID, movementID)
+	if err != nil {
+		return err
+	}
+
+	type lotQty struct {
+		id  int64
+		qty float64
+	}
+	var taken []lotQty
+	for rows.Next() {
+		var l lotQty
+		if err := rows.Scan(&l.id, &l.qty); err != nil {
+			rows.Close()
+			return err
+		}
+		taken = append(taken, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range taken {
+		if quantity <= 0 {
+			break
+		}
+		back := min(quantity, l.qty)
+		if _, err := tx.Exec(`UPDATE stock_lots SET quantity = quantity + $1 WHERE id = $2`, back, l.id); err != nil {
+			return err
+		}
+		if err := insertLotMovement(tx, l.id, movementID, back); err != nil {
+			return err
+		}
+		quantity -= back
+	}
+	return nil
+}
+
+// transferLotsTx brings to a location, up to quantity, the lots that the other
+// movements of the same transfer took out of their location. Each keeps its
+// number and dates at the destination.
+func transferLotsTx(tx *sql.Tx, productID, locationID int64, quantity float64, movementID int64, referenceType string, referenceID int64) error {
+	rows, err := tx.Query(`
+	SELECT lm.lot_id, -SUM(lm.quantity)
+	FROM stock_lot_movements lm
+	JOIN stock_movements sm ON sm.id = lm.movement_id
+	WHERE sm.product_id = $1 AND sm.reference_type = $2 AND sm.reference_id = $3 AND sm.location_id <> $4
+	GROUP BY lm.lot_id
+	HAVING SUM(lm.quantity) < 0
+	ORDER BY lm.lot_id`, productID, referenceType, referenceID, locationID)
+	if err != nil {
+		return err
+	}
+
+	type lotQty struct {
+		id  int64
+		qty float64
+	}
+	var moved []lotQty
+	for rows.Next() {
+		var l lotQty
+		if err := rows.Scan(&l.id, &l.qty); err != nil {
+			rows.Close()
+			return err
+		}
+		moved = append(moved, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, l := range moved {
+		if quantity <= 0 {
+			break
+		}
+		in := min(quantity, l.qty)
+		var lotID int64
+		err := tx.QueryRow(`
+		INSERT INTO stock_lots (product_id, location_id, lot_number, produced_on, best_before, initial_quantity, quantity)
+		SELECT product_id, $2, lot_number, produced_on, best_before, $3, $3
+		FROM stock_lots
+		WHERE id = $1
+		ON CONFLICT (product_id, location_id, lot_number) DO UPDATE
+		SET quantity = stock_lots.quantity + EXCLUDED.quantity,
+		    initial_quantity = stock_lots.initial_quantity + EXCLUDED.initial_quantity
+		RETURNING id`, l.id, locationID, in).Scan(&lotID)
+		if err != nil {
+			return err
+		}
+		if err := insertLotMovement(tx, lotID, movementID, in); err != nil {
+			return err
+		}
+		quantity -= in
+	}
+	return nil
+}
+
+func insertLotMovement(tx *sql.Tx, lotID, movementID int64, quantity float64) error {
+	_, err := tx.Exec(`INSERT INTO stock_lot_movements (lot_id, movement_id, quantity) VALUES ($1, $2, $3)`,
+		lotID, movementID, quantity)
+	return err
+}
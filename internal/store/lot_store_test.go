@@ -0,0 +1,127 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLotStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	stock := NewPostgresLocalStockStore(db)
+	lots := NewPostgresLotStore(db)
+	prod := setupProductForStockTest(t, db)
+
+	// Stock from before lots stays untracked.
+	_, err := stock.Create(prod.ID, 2, StockChange{Reason: MovementInitial})
+	require.NoError(t, err)
+
+	today := time.Now()
+	tomorrow := today.AddDate(0, 0, 1)
+	nextWeek := today.AddDate(0, 0, 7)
+
+	_, err = stock.AdjustQuantity(prod.ID, 5, StockChange{Reason: MovementProduction, Lot: &NewLot{BestBefore: &nextWeek}})
+	require.NoError(t, err)
+	_, err = stock.AdjustQuantity(prod.ID, 3, StockChange{Reason: MovementProduction, Lot: &NewLot{Number: "B-1", BestBefore: &tomorrow}})
+	require.NoError(t, err)
+
+	list, err := lots.ListByProduct(prod.ID, false)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "B-1", list[0].LotNumber, "the lot expiring first is consumed first")
+	assert.Equal(t, "L"+today.Format("060102")+"-1", list[1].LotNumber)
+	assert.Equal(t, 5.0, list[1].InitialQuantity)
+
+	t.Run("duplicate number", func(t *testing.T) {
+		_, err := stock.AdjustQuantity(prod.ID, 1, StockChange{Reason: MovementProduction, Lot: &NewLot{Number: "B-1"}})
+		assert.ErrorIs(t, err, ErrLotNumberTaken)
+	})
+
+	t.Run("consumption is FIFO and voids restore", func(t *testing.T) {
+		_, err := stock.AdjustQuantity(prod.ID, -4, StockChange{Reason: MovementSale, ReferenceType: ReferenceLocalSale, ReferenceID: 42})
+		require.NoError(t, err)
+
+		list, err := lots.ListByProduct(prod.ID, true)
+		require.NoError(t, err)
+		require.Len(t, list, 2)
+		assert.Equal(t, 0.0, list[1].Quantity, "used up lots are listed last")
+		assert.Equal(t, "B-1", list[1].LotNumber)
+		assert.Equal(t, 4.0, list[0].Quantity)
+
+		withStock, err := lots.ListByProduct(prod.ID, false)
+		require.NoError(t, err)
+		assert.Len(t, withStock, 1)
+
+		_, err = stock.AdjustQuantity(prod.ID, 4, StockChange{Reason: MovementVoid, ReferenceType: ReferenceLocalSale, ReferenceID: 42})
+		require.NoError(t, err)
+
+		b1, err := lots.GetByID(list[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3.0, b1.Quantity)
+
+		movements, err := lots.ListMovements(b1.ID)
+		require.NoError(t, err)
+		require.Len(t, movements, 3)
+		assert.Equal(t, []float64{3, -3, 3}, []float64{movements[0].Quantity, movements[1].Quantity, movements[2].Quantity})
+		assert.Equal(t, MovementSale, movements[1].Reason)
+	})
+
+	t.Run("untracked stock is taken after the lots", func(t *testing.T) {
+		_, err := stock.AdjustQuantity(prod.ID, -9, StockChange{Reason: MovementAdjustment})
+		require.NoError(t, err)
+
+		list, err := lots.ListByProduct(prod.ID, false)
+		require.NoError(t, err)
+		assert.Empty(t, list)
+
+		current, err := stock.GetByProductID(prod.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, current.Quantity)
+	})
+
+	t.Run("expiring", func(t *testing.T) {
+		_, err := stock.AdjustQuantity(prod.ID, 2, StockChange{Reason: MovementProduction, Lot: &NewLot{Number: "C-1", BestBefore: &today}})
+		require.NoError(t, err)
+
+		expiring, err := lots.ListExpiring(tomorrow)
+		require.NoError(t, err)
+		require.Len(t, expiring, 1)
+		assert.Equal(t, "C-1", expiring[0].LotNumber)
+		days, ok := expiring[0].DaysToExpiry(today)
+		assert.True(t, ok)
+		assert.Equal(t, 0, days)
+	})
+}
+
+func TestStockLot_DaysToExpiry(t *testing.T) {
+	now := time.Date(2025, 3, 10, 23, 30, 0, 0, time.Local)
+	date := func(d int) *time.Time {
+		v := time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC)
+		return &v
+	}
+
+	tests := []struct {
+		name       string
+		bestBefore *time.Time
+		wantDays   int
+		wantOK     bool
+	}{
+		{name: "no date", bestBefore: nil, wantOK: false},
+		{name: "expired", bestBefore: date(8), wantDays: -2, wantOK: true},
+		{name: "today", bestBefore: date(10), wantDays: 0, wantOK: true},
+		{name: "tomorrow", bestBefore: date(11), wantDays: 1, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lot := &StockLot{BestBefore: tt.bestBefore}
+			days, ok := lot.DaysToExpiry(now)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantDays, days)
+		})
+	}
+}
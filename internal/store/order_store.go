@@ -49,7 +49,12 @@ type OrderItem struct {
 }
 
 type OrderStore interface {
+	// CreateOrder saves the order with its items and queues, in the same
+	// transaction, the job that adds it to the remito and the order.created
+	// webhooks.
 	CreateOrder(o *Order, items []OrderItem) error
+	// UpdateOrderState changes the state, and the payment method when not
+	// nil, queuing the order.state_changed webhooks in the same transaction.
 	UpdateOrderState(id int64, state OrderState, paymentMethodID *int64) error
 	DeleteOrder(id int64) error
 	GetOrderByID(id int64) (*Order, error)
@@ -164,36 +169,59 @@ func (s *PostgresOrderStore) CreateOrder(o *Order, items []OrderItem) error {
 		return err
 	}
 
-	if err = tx.Commit(); err != nil {
+	// The remito is generated in the background, only once the order is
+	// saved.
+	var job *Job
+	if job, err = NewJob(JobGenerateInvoice, InvoiceJob{OrderID: o.ID}); err != nil {
+		return err
+	}
+	if _, err = enqueueJob(tx, job); err != nil {
 		return err
 	}
 	o.Items = items
-	return nil
+	if _, err = enqueueWebhookEvent(tx, WebhookOrderCreated, o); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (s *PostgresOrderStore) UpdateOrderState(id int64, state OrderState, paymentMethodID *int64) error {
-	var res sql.Result
-	var err error
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous OrderState
+	err = tx.QueryRow(`SELECT state FROM orders WHERE id=$1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(&previous)
+	if err != nil {
+		return err
+	}
 
 	if paymentMethodID != nil {
-		const q = `UPDATE orders SET state=$1, payment_method_id=$3 WHERE id=$2 AND deleted_at IS NULL`
-		res, err = s.db.Exec(q, state, id, *paymentMethodID)
+		const q = `UPDATE orders SET state=$1, payment_method_id=$3 WHERE id=$2`
+		_, err = tx.Exec(q, state, id, *paymentMethodID)
 	} else {
-		const q = `UPDATE orders SET state=$1 WHERE id=$2 AND deleted_at IS NULL`
-		res, err = s.db.Exec(q, state, id)
+		const q = `UPDATE orders SET state=$1 WHERE id=$2`
+		_, err = tx.Exec(q, state, id)
 	}
-
 	if err != nil {
 		return err
 	}
-	n, err := res.RowsAffected()
+
+	o, err := getOrder(tx, id)
 	if err != nil {
 		return err
 	}
-	if n == 0 {
-		return sql.ErrNoRows
+	if _, err := enqueueWebhookEvent(tx, WebhookOrderStateChanged, map[string]any{
+		"order":          o,
+		"previous_state": previous,
+	}); err != nil {
+		return err
 	}
-	return nil
+
+	return tx.Commit()
 }
 
 func (s *PostgresOrderStore) DeleteOrder(id int64) error {
@@ -213,14 +241,24 @@ func (s *PostgresOrderStore) DeleteOrder(id int64) error {
 }
 
 func (s *PostgresOrderStore) GetOrderByID(id int64) (*Order, error) {
-	const q = `
+	return getOrder(s.db, id)
+}
+
+type rowsQuerier interface {
+	rowQuerier
+	querier
+}
+
+// getOrder loads an order with its items with q, which can be a transaction.
+func getOrder(q rowsQuerier, id int64) (*Order, error) {
+	const qo = `
 	SELECT o.id, o.client_id, c.name, o.total::text, o.date, o.state, o.payment_method_id, COALESCE(pm.name, ''), o.created_at, o.deleted_at
 	FROM orders o
 	JOIN clients c ON c.id = o.client_id
 	LEFT JOIN payment_methods pm ON pm.id = o.payment_method_id
 	WHERE o.id=$1 AND o.deleted_at IS NULL`
 	o := &Order{}
-	if err := s.db.QueryRow(q, id).Scan(&o.ID, &o.ClientID, &o.ClientName, &o.Total, &o.Date, &o.State, &o.PaymentMethodID, &o.PaymentMethodName, &o.CreatedAt, &o.DeletedAt); err != nil {
+	if err := q.QueryRow(qo, id).Scan(&o.ID, &o.ClientID, &o.ClientName, &o.Total, &o.Date, &o.State, &o.PaymentMethodID, &o.PaymentMethodName, &o.CreatedAt, &o.DeletedAt); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
@@ -232,7 +270,7 @@ func (s *PostgresOrderStore) GetOrderByID(id int64) (*Order, error) {
 	JOIN products p ON p.id = op.product_id
 	WHERE op.order_id=$1 
 	ORDER BY op.id`
-	rows, err := s.db.Query(qi, id)
+	rows, err := q.Query(qi, id)
 	if err != nil {
 		return nil, err
 	}
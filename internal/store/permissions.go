@@ -0,0 +1,103 @@
+package store
+
+// Permissions granted through roles. Routes and handlers check one of these
+// instead of a role name, so roles can be configured from the users screens.
+const (
+	PermSalesView     = "sales.view"
+	PermSalesCreate   = "sales.create"
+	PermSalesVoid     = "sales.void"
+	PermShiftsOperate = "shifts.operate"
+
+	PermStockView       = "stock.view"
+	PermStockAdjust     = "stock.adjust"
+	PermStockTransfer   = "stock.transfer"
+	PermWasteRegister   = "waste.register"
+	PermWasteReport     = "waste.report"
+	PermInventoryCount  = "inventory.count"
+	PermInventoryManage = "inventory.manage"
+
+	PermProductsEdit = "products.edit"
+	PermPricesEdit   = "prices.edit"
+
+	PermClientsManage   = "clients.manage"
+	PermProvidersManage = "providers.manage"
+	PermOrdersManage    = "orders.manage"
+	PermInvoicesView    = "invoices.view"
+
+	PermPurchasesManage = "purchases.manage"
+	PermPayablesManage  = "payables.manage"
+	PermExpensesView    = "expenses.view"
+	PermExpensesCreate  = "expenses.create"
+	PermProductionPlan  = "production.plan"
+
+	PermReportsView    = "reports.view"
+	PermSettingsManage = "settings.manage"
+	PermUsersManage    = "users.manage"
+	PermAuditView      = "audit.view"
+	PermWebhooksManage = "webhooks.manage"
+	PermJobsManage     = "jobs.manage"
+)
+
+// PermissionInfo describes a permission for the role screens.
+type PermissionInfo struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+	Group string `json:"group"`
+}
+
+// PermissionCatalog lists every permission, grouped as shown when editing a
+// role.
+var PermissionCatalog = []PermissionInfo{
+	{PermSalesView, "Ver ventas del local", "Ventas"},
+	{PermSalesCreate, "Registrar ventas y anularlas dentro de la primera hora", "Ventas"},
+	{PermSalesVoid, "Anular ventas en cualquier momento", "Ventas"},
+	{PermShiftsOperate, "Abrir, cerrar y operar turnos de caja", "Ventas"},
+
+	{PermStockView, "Ver stock, movimientos y lotes", "Stock"},
+	{PermStockAdjust, "Ajustar stock", "Stock"},
+	{PermStockTransfer, "Transferir stock entre ubicaciones", "Stock"},
+	{PermWasteRegister, "Registrar mermas", "Stock"},
+	{PermWasteReport, "Ver el reporte de mermas", "Stock"},
+	{PermInventoryCount, "Cargar conteos de inventario", "Stock"},
+	{PermInventoryManage, "Iniciar, aprobar y cancelar conteos de inventario", "Stock"},
+
+	{PermProductsEdit, "Crear y editar productos, categorías, ingredientes y recetas", "Catálogo"},
+	{PermPricesEdit, "Editar precios de productos", "Catálogo"},
+
+	{PermClientsManage, "Gestionar clientes", "Comercial"},
+	{PermProvidersManage, "Gestionar proveedores", "Comercial"},
+	{PermOrdersManage, "Gestionar pedidos de distribución", "Comercial"},
+	{PermInvoicesView, "Ver y descargar facturas", "Comercial"},
+
+	{PermPurchasesManage, "Gestionar órdenes de compra", "Compras y gastos"},
+	{PermPayablesManage, "Gestionar cuentas a pagar y pagos a proveedores", "Compras y gastos"},
+	{PermExpensesView, "Ver gastos", "Compras y gastos"},
+	{PermExpensesCreate, "Cargar y eliminar gastos", "Compras y gastos"},
+	{PermProductionPlan, "Ver producción pendiente y sugerencias de producción", "Compras y gastos"},
+
+	{PermReportsView, "Ver estadísticas completas del inicio", "Administración"},
+	{PermSettingsManage, "Configurar cajas, ubicaciones de stock y medios de pago", "Administración"},
+	{PermUsersManage, "Gestionar usuarios y roles", "Administración"},
+	{PermAuditView, "Ver y exportar el registro de auditoría", "Administración"},
+	{PermWebhooksManage, "Configurar webhooks hacia otros sistemas", "Administración"},
+	{PermJobsManage, "Ver y reintentar los trabajos en segundo plano", "Administración"},
+}
+
+// IsPermission reports whether key is in PermissionCatalog.
+func IsPermission(key string) bool {
+	for _, p := range PermissionCatalog {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// AllPermissions returns the keys of every permission in PermissionCatalog.
+func AllPermissions() []string {
+	keys := make([]string, len(PermissionCatalog))
+	for i, p := range PermissionCatalog {
+		keys[i] = p.Key
+	}
+	return keys
+}
@@ -2,10 +2,22 @@ package store
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 )
 
+var ErrBarcodeInUse = errors.New("el código ya está asignado a otro producto")
+
+// Sale units a product can be sold in at the counter. Products sold by weight
+// are priced per kilogram whether their quantities are kept in kg or g.
+const (
+	SaleUnitUnit = "unit"
+	SaleUnitKg   = "kg"
+	SaleUnitGram = "g"
+)
+
 type PostgresProductStore struct {
 	db *sql.DB
 }
@@ -17,17 +29,22 @@ func NewPostgresProductStore(db *sql.DB) *PostgresProductStore {
 }
 
 type Product struct {
-	ID                int64                `json:"id"`
-	CategoryID        int64                `json:"category_id"`
-	CategoryName      string               `json:"category_name"`
-	Name              string               `json:"name"`
-	Description       string               `json:"description,omitempty"`
-	UnitPrice         float64              `json:"unit_price"`
-	DistributionPrice float64              `json:"distribution_price"`
-	CreatedAt         time.Time            `json:"created_at"`
-	DeletedAt         *time.Time           `json:"deleted_at"`
-	CurrentStock      float64              `json:"current_stock"`
-	Recipe            []*ProductIngredient `json:"recipe,omitempty"`
+	ID                 int64                `json:"id"`
+	CategoryID         int64                `json:"category_id"`
+	CategoryName       string               `json:"category_name"`
+	Name               string               `json:"name"`
+	Description        string               `json:"description,omitempty"`
+	UnitPrice          float64              `json:"unit_price"`
+	DistributionPrice  float64              `json:"distribution_price"`
+	SaleUnit           string               `json:"sale_unit"`
+	AllowNegativeStock bool                 `json:"allow_negative_stock"`
+	MinStock           *float64             `json:"min_stock"`    // low-stock alert level, nil when unset
+	TargetStock        *float64             `json:"target_stock"` // level production tops up to
+	CreatedAt          time.Time            `json:"created_at"`
+	DeletedAt          *time.Time           `json:"deleted_at"`
+	CurrentStock       float64              `json:"current_stock"` // at the default location
+	Recipe             []*ProductIngredient `json:"recipe,omitempty"`
+	Barcodes           []string             `json:"barcodes,omitempty"`
 }
 
 type ProductIngredient struct {
@@ -36,6 +53,7 @@ type ProductIngredient struct {
 	Name         string    `json:"name"`
 	Quantity     float64   `json:"quantity"`
 	Unit         string    `json:"unit"`
+	Cost         float64   `json:"cost"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
@@ -55,6 +73,9 @@ type ProductStore interface {
 	GetTopSellingProducts(start, end time.Time) ([]*TopProduct, error)
 	GetTopSellingProductsLocal(start, end time.Time) ([]*TopProduct, error)
 	GetTopSellingProductsDistribution(start, end time.Time) ([]*TopProduct, error)
+	GetProductBarcodes(productID int64) ([]string, error)
+	SetProductBarcodes(productID int64, codes []string) error
+	GetProductByBarcode(codes []string) (*Product, error)
 }
 
 type TopProduct struct {
@@ -65,11 +86,16 @@ type TopProduct struct {
 
 func (s *PostgresProductStore) CreateProduct(product *Product) error {
 	query := `
-	INSERT INTO products (category_id, name, description, unit_price, distribution_price)
-	VALUES ($1, $2, $3, $4, $5)
+	INSERT INTO products (category_id, name, description, unit_price, distribution_price, sale_unit, allow_negative_stock,
+	                      min_stock, target_stock)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	RETURNING id, created_at 
 	`
 
+	if product.SaleUnit == "" {
+		product.SaleUnit = SaleUnitUnit
+	}
+
 	err := s.db.QueryRow(
 		query,
 		product.CategoryID,
@@ -77,6 +103,10 @@ func (s *PostgresProductStore) CreateProduct(product *Product) error {
 		product.Description,
 		product.UnitPrice,
 		product.DistributionPrice,
+		product.SaleUnit,
+		product.AllowNegativeStock,
+		product.MinStock,
+		product.TargetStock,
 	).Scan(
 		&product.ID,
 		&product.CreatedAt,
@@ -91,14 +121,14 @@ func (s *PostgresProductStore) CreateProduct(product *Product) error {
 func (s *PostgresProductStore) GetProductByID(id int64) (*Product, error) {
 	const q = `
 	SELECT p.id, p.category_id, c.name AS category_name,
-	       p.name, p.description, p.unit_price, p.distribution_price, p.created_at, p.deleted_at
+	       p.name, p.description, p.unit_price, p.distribution_price, p.sale_unit, p.allow_negative_stock, p.min_stock, p.target_stock, p.created_at, p.deleted_at
 	FROM products p
 	JOIN categories c ON c.id = p.category_id
 	WHERE p.id = $1 AND p.deleted_at IS NULL`
 	pr := &Product{}
 	err := s.db.QueryRow(q, id).Scan(
 		&pr.ID, &pr.CategoryID, &pr.CategoryName,
-		&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.CreatedAt, &pr.DeletedAt,
+		&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.SaleUnit, &pr.AllowNegativeStock, &pr.MinStock, &pr.TargetStock, &pr.CreatedAt, &pr.DeletedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -108,7 +138,7 @@ func (s *PostgresProductStore) GetProductByID(id int64) (*Product, error) {
 	}
 
 	const qi = `
-	SELECT pi.id, pi.ingredient_id, i.name, pi.quantity, pi.unit, pi.created_at, pi.updated_at
+	SELECT pi.id, pi.ingredient_id, i.name, pi.quantity, pi.unit, i.cost, pi.created_at, pi.updated_at
 	FROM product_ingredients pi
 	JOIN ingredients i ON i.id = pi.ingredient_id
 	WHERE pi.product_id = $1
@@ -122,7 +152,7 @@ func (s *PostgresProductStore) GetProductByID(id int64) (*Product, error) {
 
 	for rows.Next() {
 		pi := &ProductIngredient{}
-		if err := rows.Scan(&pi.ID, &pi.IngredientID, &pi.Name, &pi.Quantity, &pi.Unit, &pi.CreatedAt, &pi.UpdatedAt); err != nil {
+		if err := rows.Scan(&pi.ID, &pi.IngredientID, &pi.Name, &pi.Quantity, &pi.Unit, &pi.Cost, &pi.CreatedAt, &pi.UpdatedAt); err != nil {
 			return nil, err
 		}
 		pr.Recipe = append(pr.Recipe, pi)
@@ -131,16 +161,26 @@ func (s *PostgresProductStore) GetProductByID(id int64) (*Product, error) {
 		return nil, err
 	}
 
+	pr.Barcodes, err = s.GetProductBarcodes(id)
+	if err != nil {
+		return nil, err
+	}
+
 	return pr, nil
 }
 
 func (s *PostgresProductStore) UpdateProduct(product *Product) error {
 	query := `
 	UPDATE products
-	SET category_id = $1, name = $2, description = $3, unit_price = $4, distribution_price = $5
-	WHERE id = $6 AND deleted_at IS NULL
+	SET category_id = $1, name = $2, description = $3, unit_price = $4, distribution_price = $5, sale_unit = $6,
+	    allow_negative_stock = $7, min_stock = $8, target_stock = $9
+	WHERE id = $10 AND deleted_at IS NULL
 	`
 
+	if product.SaleUnit == "" {
+		product.SaleUnit = SaleUnitUnit
+	}
+
 	result, err := s.db.Exec(
 		query,
 		product.CategoryID,
@@ -148,6 +188,10 @@ func (s *PostgresProductStore) UpdateProduct(product *Product) error {
 		product.Description,
 		product.UnitPrice,
 		product.DistributionPrice,
+		product.SaleUnit,
+		product.AllowNegativeStock,
+		product.MinStock,
+		product.TargetStock,
 		product.ID,
 	)
 	if err != nil {
@@ -173,7 +217,13 @@ func (s *PostgresProductStore) DeleteProduct(id int64) error {
 	WHERE id = $1 AND deleted_at IS NULL
 	`
 
-	result, err := s.db.Exec(query, id)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(query, id)
 	if err != nil {
 		return err
 	}
@@ -187,13 +237,18 @@ func (s *PostgresProductStore) DeleteProduct(id int64) error {
 		return sql.ErrNoRows
 	}
 
-	return nil
+	// Free the product's codes so they can be assigned to a replacement.
+	if _, err := tx.Exec(`DELETE FROM product_barcodes WHERE product_id = $1`, id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (s *PostgresProductStore) GetAllProduct() ([]*Product, error) {
 	const q = `
 	SELECT p.id, p.category_id, c.name AS category_name,
-	       p.name, p.description, p.unit_price, p.distribution_price, p.created_at, p.deleted_at
+	       p.name, p.description, p.unit_price, p.distribution_price, p.sale_unit, p.allow_negative_stock, p.min_stock, p.target_stock, p.created_at, p.deleted_at
 	FROM products p
 	JOIN categories c ON c.id = p.category_id
 	WHERE p.deleted_at IS NULL
@@ -209,7 +264,7 @@ func (s *PostgresProductStore) GetAllProduct() ([]*Product, error) {
 		pr := &Product{}
 		if err := rows.Scan(
 			&pr.ID, &pr.CategoryID, &pr.CategoryName,
-			&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.CreatedAt, &pr.DeletedAt,
+			&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.SaleUnit, &pr.AllowNegativeStock, &pr.MinStock, &pr.TargetStock, &pr.CreatedAt, &pr.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -224,7 +279,7 @@ func (s *PostgresProductStore) GetAllProduct() ([]*Product, error) {
 func (s *PostgresProductStore) GetProductsByCategoryID(categoryID int64) ([]*Product, error) {
 	const query = `
     SELECT p.id, p.category_id, c.name AS category_name,
-           p.name, p.description, p.unit_price, p.distribution_price, p.created_at, p.deleted_at
+           p.name, p.description, p.unit_price, p.distribution_price, p.sale_unit, p.allow_negative_stock, p.min_stock, p.target_stock, p.created_at, p.deleted_at
     FROM products p
     JOIN categories c ON c.id = p.category_id
     WHERE p.category_id = $1 AND p.deleted_at IS NULL
@@ -240,7 +295,7 @@ func (s *PostgresProductStore) GetProductsByCategoryID(categoryID int64) ([]*Pro
 		pr := &Product{}
 		if err := rows.Scan(
 			&pr.ID, &pr.CategoryID, &pr.CategoryName,
-			&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.CreatedAt, &pr.DeletedAt,
+			&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.SaleUnit, &pr.AllowNegativeStock, &pr.MinStock, &pr.TargetStock, &pr.CreatedAt, &pr.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -312,7 +367,7 @@ func (s *PostgresProductStore) RemoveIngredientFromProduct(productID, ingredient
 func (s *PostgresProductStore) GetProductsByIDs(ids []int64) (map[int64]*Product, error) {
 	const q = `
 	SELECT p.id, p.category_id, c.name AS category_name,
-	       p.name, p.description, p.unit_price, p.distribution_price, p.created_at, p.deleted_at
+	       p.name, p.description, p.unit_price, p.distribution_price, p.sale_unit, p.allow_negative_stock, p.min_stock, p.target_stock, p.created_at, p.deleted_at
 	FROM products p
 	JOIN categories c ON c.id = p.category_id
 	WHERE p.id = ANY($1) AND p.deleted_at IS NULL`
@@ -328,7 +383,7 @@ func (s *PostgresProductStore) GetProductsByIDs(ids []int64) (map[int64]*Product
 		pr := &Product{}
 		if err := rows.Scan(
 			&pr.ID, &pr.CategoryID, &pr.CategoryName,
-			&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.CreatedAt, &pr.DeletedAt,
+			&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.SaleUnit, &pr.AllowNegativeStock, &pr.MinStock, &pr.TargetStock, &pr.CreatedAt, &pr.DeletedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -352,7 +407,7 @@ func (s *PostgresProductStore) list(query string, args ...any) ([]*Product, erro
 		pr := &Product{}
 		if err := rows.Scan(
 			&pr.ID, &pr.CategoryID, &pr.CategoryName,
-			&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.CreatedAt,
+			&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.SaleUnit, &pr.AllowNegativeStock, &pr.MinStock, &pr.TargetStock, &pr.CreatedAt,
 			&pr.CurrentStock,
 			&pr.DeletedAt,
 		); err != nil {
@@ -377,11 +432,11 @@ func (s *PostgresProductStore) SearchProductsFTS(q string, limit, offset int) ([
 	if q == "" {
 		const allq = `
 		SELECT p.id, p.category_id, c.name AS category_name,
-		       p.name, p.description, p.unit_price, p.distribution_price, p.created_at,
+		       p.name, p.description, p.unit_price, p.distribution_price, p.sale_unit, p.allow_negative_stock, p.min_stock, p.target_stock, p.created_at,
 		       COALESCE(ls.quantity, 0) as current_stock, p.deleted_at
 		FROM products p
 		JOIN categories c ON c.id = p.category_id
-		LEFT JOIN local_stock ls ON ls.product_id = p.id
+		LEFT JOIN local_stock ls ON ls.product_id = p.id AND ls.location_id = ` + defaultLocationSQL + `
 		WHERE p.deleted_at IS NULL
 		ORDER BY p.name
 		LIMIT $1 OFFSET $2`
@@ -399,11 +454,11 @@ func (s *PostgresProductStore) SearchProductsFTS(q string, limit, offset int) ([
 	if len(terms) == 0 {
 		const allq = `
 		SELECT p.id, p.category_id, c.name AS category_name,
-		       p.name, p.description, p.unit_price, p.distribution_price, p.created_at,
+		       p.name, p.description, p.unit_price, p.distribution_price, p.sale_unit, p.allow_negative_stock, p.min_stock, p.target_stock, p.created_at,
 		       COALESCE(ls.quantity, 0) as current_stock, p.deleted_at
 		FROM products p
 		JOIN categories c ON c.id = p.category_id
-		LEFT JOIN local_stock ls ON ls.product_id = p.id
+		LEFT JOIN local_stock ls ON ls.product_id = p.id AND ls.location_id = ` + defaultLocationSQL + `
 		WHERE p.deleted_at IS NULL
 		ORDER BY p.name
 		LIMIT $1 OFFSET $2`
@@ -418,11 +473,11 @@ func (s *PostgresProductStore) SearchProductsFTS(q string, limit, offset int) ([
 
 	const sqlq = `
 	SELECT p.id, p.category_id, c.name AS category_name,
-	       p.name, p.description, p.unit_price, p.distribution_price, p.created_at,
+	       p.name, p.description, p.unit_price, p.distribution_price, p.sale_unit, p.allow_negative_stock, p.min_stock, p.target_stock, p.created_at,
 	       COALESCE(ls.quantity, 0) as current_stock, p.deleted_at
 	FROM products p
 	JOIN categories c ON c.id = p.category_id
-	LEFT JOIN local_stock ls ON ls.product_id = p.id
+	LEFT JOIN local_stock ls ON ls.product_id = p.id AND ls.location_id = ` + defaultLocationSQL + `
 	WHERE p.search_tsv @@ to_tsquery('spanish', unaccent($1)) AND p.deleted_at IS NULL
 	ORDER BY ts_rank(p.search_tsv, to_tsquery('spanish', unaccent($1))) DESC, p.name
 	LIMIT $2 OFFSET $3`
@@ -525,3 +580,85 @@ func (s *PostgresProductStore) GetTopSellingProductsDistribution(start, end time
 	}
 	return topProducts, rows.Err()
 }
+
+func (s *PostgresProductStore) GetProductBarcodes(productID int64) ([]string, error) {
+	rows, err := s.db.Query(`SELECT code FROM product_barcodes WHERE product_id = $1 ORDER BY id`, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, rows.Err()
+}
+
+// SetProductBarcodes replaces the codes of a product. It returns
+// ErrBarcodeInUse if any of them already identifies another product.
+func (s *PostgresProductStore) SetProductBarcodes(productID int64, codes []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var taken string
+	err = tx.QueryRow(`
+	SELECT code FROM product_barcodes
+	WHERE code = ANY($1) AND product_id <> $2
+	LIMIT 1`, codes, productID).Scan(&taken)
+	if err == nil {
+		return fmt.Errorf("%w: %s", ErrBarcodeInUse, taken)
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM product_barcodes WHERE product_id = $1`, productID); err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		if _, err := tx.Exec(`INSERT INTO product_barcodes (product_id, code) VALUES ($1, $2)`, productID, code); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetProductByBarcode returns the product identified by the first of the
+// given codes that is registered, or nil if none is.
+func (s *PostgresProductStore) GetProductByBarcode(codes []string) (*Product, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	const q = `
+	SELECT p.id, p.category_id, c.name AS category_name,
+	       p.name, p.description, p.unit_price, p.distribution_price, p.sale_unit, p.allow_negative_stock, p.min_stock, p.target_stock, p.created_at, p.deleted_at
+	FROM product_barcodes pb
+	JOIN products p ON p.id = pb.product_id
+	JOIN categories c ON c.id = p.category_id
+	WHERE pb.code = ANY($1) AND p.deleted_at IS NULL
+	ORDER BY array_position($1::text[], pb.code::text)
+	LIMIT 1`
+	pr := &Product{}
+	err := s.db.QueryRow(q, codes).Scan(
+		&pr.ID, &pr.CategoryID, &pr.CategoryName,
+		&pr.Name, &pr.Description, &pr.UnitPrice, &pr.DistributionPrice, &pr.SaleUnit, &pr.AllowNegativeStock, &pr.MinStock, &pr.TargetStock, &pr.CreatedAt, &pr.DeletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
@@ -428,3 +428,45 @@ func TestProductStore_SoftDelete(t *testing.T) {
 	assert.Equal(t, 20.0, gotNew.UnitPrice)
 }
 
+
+func TestProductStore_Barcodes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	s := NewPostgresProductStore(db)
+	category := setupCategory(t, db)
+
+	p1 := &Product{CategoryID: category.ID, Name: "Scanned 1", UnitPrice: 10}
+	require.NoError(t, s.CreateProduct(p1))
+	p2 := &Product{CategoryID: category.ID, Name: "Scanned 2", UnitPrice: 20}
+	require.NoError(t, s.CreateProduct(p2))
+
+	require.NoError(t, s.SetProductBarcodes(p1.ID, []string{"7791234567898", "123"}))
+
+	got, err := s.GetProductByID(p1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"7791234567898", "123"}, got.Barcodes)
+
+	// Lookup honours the order of the candidates
+	found, err := s.GetProductByBarcode([]string{"999", "123"})
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, p1.ID, found.ID)
+
+	found, err = s.GetProductByBarcode([]string{"999"})
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	// A code can only identify one product
+	err = s.SetProductBarcodes(p2.ID, []string{"123"})
+	assert.ErrorIs(t, err, ErrBarcodeInUse)
+
+	// Replacing drops codes no longer listed
+	require.NoError(t, s.SetProductBarcodes(p1.ID, []string{"456"}))
+	codes, err := s.GetProductBarcodes(p1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"456"}, codes)
+
+	// Deleting the product frees its codes
+	require.NoError(t, s.DeleteProduct(p1.ID))
+	require.NoError(t, s.SetProductBarcodes(p2.ID, []string{"456"}))
+}
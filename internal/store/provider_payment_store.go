@@ -0,0 +1,268 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ProviderPayment is money paid to a provider with one payment method,
+// applied to one or more of its expenses.
+type ProviderPayment struct {
+	ID                int64                `json:"id"`
+	ProviderID        int64                `json:"provider_id"`
+	ProviderName      string               `json:"provider_name"`
+	PaymentMethodID   int64                `json:"payment_method_id"`
+	PaymentMethodName string               `json:"payment_method_name"`
+	Amount            float64              `json:"amount"`
+	Date              time.Time            `json:"date"`
+	Notes             string               `json:"notes"`
+	UserID            *int64               `json:"user_id"`
+	Username          string               `json:"username,omitempty"`
+	CreatedAt         time.Time            `json:"created_at"`
+	Allocations       []*PaymentAllocation `json:"allocations,omitempty"`
+}
+
+// PaymentAllocation is the part of a payment applied to one expense.
+type PaymentAllocation struct {
+	ID          int64     `json:"id"`
+	PaymentID   int64     `json:"payment_id"`
+	ExpenseID   int64     `json:"expense_id"`
+	ExpenseDate time.Time `json:"expense_date"`
+	Amount      float64   `json:"amount"`
+}
+
+// StatementLine is a movement in a provider account: an expense owed
+// (debit) or a payment (credit), with the balance after it.
+type StatementLine struct {
+	Date        time.Time `json:"date"`
+	Kind        string    `json:"kind"` // expense, payment or paid_on_entry
+	ReferenceID int64     `json:"reference_id"`
+	Description string    `json:"description"`
+	Debit       float64   `json:"debit"`
+	Credit      float64   `json:"credit"`
+	Balance     float64   `json:"balance"`
+}
+
+const (
+	StatementExpense     = "expense"
+	StatementPayment     = "payment"
+	StatementPaidOnEntry = "paid_on_entry"
+)
+
+// ProviderBalance is what is owed to a provider.
+type ProviderBalance struct {
+	ProviderID   int64      `json:"provider_id"`
+	ProviderName string     `json:"provider_name"`
+	Balance      float64    `json:"balance"`
+	Overdue      float64    `json:"overdue"`
+	NextDue      *time.Time `json:"next_due"`
+}
+
+type ProviderPaymentStore interface {
+	// CreateTx records a payment and its allocations and adds them to the
+	// expenses' paid amount.
+	CreateTx(tx *sql.Tx, p *ProviderPayment) error
+	// GetByID returns the payment with its allocations.
+	GetByID(id int64) (*ProviderPayment, error)
+	// List returns payments made in [from, to), newest first, without
+	// allocations. A zero providerID lists every provider.
+	List(providerID int64, from, to time.Time) ([]*ProviderPayment, error)
+	// Statement returns the balance owed before from and the movements in
+	// [from, to), oldest first, with running balances.
+	Statement(providerID int64, from, to time.Time) (opening float64, lines []*StatementLine, err error)
+	// Balances returns providers with something left to pay, largest first.
+	Balances() ([]*ProviderBalance, error)
+}
+
+type PostgresProviderPaymentStore struct {
+	db *sql.DB
+}
+
+func NewPostgresProviderPaymentStore(db *sql.DB) *PostgresProviderPaymentStore {
+	return &PostgresProviderPaymentStore{db: db}
+}
+
+func (s *PostgresProviderPaymentStore) CreateTx(tx *sql.Tx, p *ProviderPayment) error {
+	query := `
+	INSERT INTO provider_payments (provider_id, payment_method_id, amount, date, notes, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`
+
+	var userID int64
+	if p.UserID != nil {
+		userID = *p.UserID
+	}
+	err := tx.QueryRow(query, p.ProviderID, p.PaymentMethodID, p.Amount, p.Date, p.Notes, nullInt64(userID)).
+		Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range p.Allocations {
+		a.PaymentID = p.ID
+		err := tx.QueryRow(`
+		INSERT INTO provider_payment_allocations (payment_id, expense_id, amount)
+		VALUES ($1, $2, $3)
+		RETURNING id`, p.ID, a.ExpenseID, a.Amount).Scan(&a.ID)
+		if err != nil {
+			return err
+		}
+
+		err = expectOneRow(tx.Exec(`UPDATE expenses SET paid_amount = paid_amount + $1 WHERE id = $2`, a.Amount, a.ExpenseID))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const providerPaymentQuery = `
+	SELECT pp.id, pp.provider_id, pr.name, pp.payment_method_id, pm.name, pp.amount, pp.date, pp.notes,
+	       pp.user_id, COALESCE(u.username, ''), pp.created_at
+	FROM provider_payments pp
+	JOIN providers pr ON pr.id = pp.provider_id
+	JOIN payment_methods pm ON pm.id = pp.payment_method_id
+	LEFT JOIN users u ON u.id = pp.user_id`
+
+func scanProviderPayment(row interface{ Scan(...any) error }) (*ProviderPayment, error) {
+	p := &ProviderPayment{}
+	err := row.Scan(&p.ID, &p.ProviderID, &p.ProviderName, &p.PaymentMethodID, &p.PaymentMethodName, &p.Amount,
+		&p.Date, &p.Notes, &p.UserID, &p.Username, &p.CreatedAt)
+	return p, err
+}
+
+func (s *PostgresProviderPaymentStore) GetByID(id int64) (*ProviderPayment, error) {
+	p, err := scanProviderPayment(s.db.QueryRow(providerPaymentQuery+` WHERE pp.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(`
+	SELECT a.id, a.payment_id, a.expense_id, e.date, a.amount
+	FROM provider_payment_allocations a
+	JOIN expenses e ON e.id = a.expense_id
+	WHERE a.payment_id = $1
+	ORDER BY e.date, a.expense_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a := &PaymentAllocation{}
+		if err := rows.Scan(&a.ID, &a.PaymentID, &a.ExpenseID, &a.ExpenseDate, &a.Amount); err != nil {
+			return nil, err
+		}
+		p.Allocations = append(p.Allocations, a)
+	}
+	return p, rows.Err()
+}
+
+func (s *PostgresProviderPaymentStore) List(providerID int64, from, to time.Time) ([]*ProviderPayment, error) {
+	query := providerPaymentQuery + `
+	WHERE ($1::BIGINT = 0 OR pp.provider_id = $1) AND pp.date >= $2 AND pp.date < $3
+	ORDER BY pp.date DESC, pp.id DESC`
+
+	rows, err := s.db.Query(query, providerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []*ProviderPayment
+	for rows.Next() {
+		p, err := scanProviderPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// statementMovements lists every movement of a provider account. Expenses
+// paid without a provider payment (recorded as paid, or before payments
+// existed) show as settled the same day.
+const statementMovements = `
+	SELECT e.date::date AS day, 0 AS ord, 'expense' AS kind, e.id::BIGINT AS ref, e.amount AS debit, 0::NUMERIC AS credit
+	FROM expenses e
+	WHERE e.provider_id = $1 AND e.deleted_at IS NULL
+	UNION ALL
+	SELECT e.date::date, 1, 'paid_on_entry', e.id::BIGINT, 0,
+	       e.paid_amount - COALESCE((SELECT SUM(a.amount) FROM provider_payment_allocations a WHERE a.expense_id = e.id), 0)
+	FROM expenses e
+	WHERE e.provider_id = $1 AND e.deleted_at IS NULL
+	  AND e.paid_amount > COALESCE((SELECT SUM(a.amount) FROM provider_payment_allocations a WHERE a.expense_id = e.id), 0)
+	UNION ALL
+	SELECT pp.date, 2, 'payment', pp.id, 0, pp.amount
+	FROM provider_payments pp
+	WHERE pp.provider_id = $1`
+
+func (s *PostgresProviderPaymentStore) Statement(providerID int64, from, to time.Time) (float64, []*StatementLine, error) {
+	var opening float64
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(debit - credit), 0) FROM (`+statementMovements+`) m WHERE m.day < $2`,
+		providerID, from).Scan(&opening)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := s.db.Query(`
+	SELECT m.day, m.kind, m.ref, m.debit, m.credit,
+	       CASE m.kind
+	           WHEN 'payment' THEN pm.name || CASE WHEN pp.notes <> '' THEN ' - ' || pp.notes ELSE '' END
+	           ELSE COALESCE(ec.name, '')
+	       END
+	FROM (`+statementMovements+`) m
+	LEFT JOIN expenses e ON m.kind <> 'payment' AND e.id = m.ref
+	LEFT JOIN expense_categories ec ON ec.id = e.category_id
+	LEFT JOIN provider_payments pp ON m.kind = 'payment' AND pp.id = m.ref
+	LEFT JOIN payment_methods pm ON pm.id = pp.payment_method_id
+	WHERE m.day >= $2 AND m.day < $3
+	ORDER BY m.day, m.ord, m.ref`, providerID, from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	balance := opening
+	var lines []*StatementLine
+	for rows.Next() {
+		l := &StatementLine{}
+		if err := rows.Scan(&l.Date, &l.Kind, &l.ReferenceID, &l.Debit, &l.Credit, &l.Description); err != nil {
+			return 0, nil, err
+		}
+		balance += l.Debit - l.Credit
+		l.Balance = balance
+		lines = append(lines, l)
+	}
+	return opening, lines, rows.Err()
+}
+
+func (s *PostgresProviderPaymentStore) Balances() ([]*ProviderBalance, error) {
+	rows, err := s.db.Query(`
+	SELECT p.id, p.name, SUM(e.amount - e.paid_amount),
+	       COALESCE(SUM(e.amount - e.paid_amount) FILTER (WHERE COALESCE(e.due_date, e.date::date) < CURRENT_DATE), 0),
+	       MIN(COALESCE(e.due_date, e.date::date)) FILTER (WHERE COALESCE(e.due_date, e.date::date) >= CURRENT_DATE)
+	FROM expenses e
+	JOIN providers p ON p.id = e.provider_id
+	WHERE e.deleted_at IS NULL AND e.paid_amount < e.amount
+	GROUP BY p.id, p.name
+	ORDER BY 3 DESC, p.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []*ProviderBalance
+	for rows.Next() {
+		b := &ProviderBalance{}
+		if err := rows.Scan(&b.ProviderID, &b.ProviderName, &b.Balance, &b.Overdue, &b.NextDue); err != nil {
+			return nil, err
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviderPaymentStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	db.Exec("INSERT INTO provider_categories (id, name) VALUES (1, 'Sin Categoría') ON CONFLICT (id) DO NOTHING")
+
+	s := NewPostgresProviderPaymentStore(db)
+	expenseStore := NewPostgresExpenseStore(db)
+
+	provider := &Provider{Name: "Molino", Reference: "ref-pay", CUIT: "cuit-pay"}
+	require.NoError(t, NewPostgresProviderStore(db).CreateProvider(provider))
+	category := &ExpenseCategory{Name: "Insumos"}
+	require.NoError(t, expenseStore.CreateExpenseCategory(category))
+	pm := &PaymentMethod{Name: "Transferencia", Reference: "transfer"}
+	require.NoError(t, NewPostgresPaymentMethodStore(db).CreatePaymentMethod(pm))
+
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	due := day(20)
+	paid := &Expense{Amount: "300.00", PaidAmount: "300.00", CategoryID: category.ID, Type: ExpenseTypeProduction, Date: day(1), ProviderID: &provider.ID}
+	require.NoError(t, expenseStore.CreateExpense(paid))
+	invoice := &Expense{Amount: "1000.00", CategoryID: category.ID, Type: ExpenseTypeProduction, Date: day(5), DueDate: &due, ProviderID: &provider.ID}
+	require.NoError(t, expenseStore.CreateExpense(invoice))
+	assert.Equal(t, "0.00", invoice.PaidAmount, "no paid amount means pending")
+
+	payables, err := expenseStore.ListPayables(&provider.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, payables, 1)
+	assert.Equal(t, invoice.ID, payables[0].ID)
+	assert.Equal(t, ExpensePending, payables[0].PaymentStatus)
+	assert.True(t, payables[0].Overdue)
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	payment := &ProviderPayment{ProviderID: provider.ID, PaymentMethodID: pm.ID, Amount: 400, Date: day(10), Notes: "Transf. 123",
+		Allocations: []*PaymentAllocation{{ExpenseID: invoice.ID, Amount: 400}}}
+	require.NoError(t, s.CreateTx(tx, payment))
+	require.NoError(t, tx.Commit())
+	assert.NotZero(t, payment.ID)
+
+	got, err := s.GetByID(payment.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "Molino", got.ProviderName)
+	assert.Equal(t, "Transferencia", got.PaymentMethodName)
+	require.Len(t, got.Allocations, 1)
+	assert.Equal(t, 400.0, got.Allocations[0].Amount)
+
+	updated, err := expenseStore.GetExpenseByID(invoice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "400.00", updated.PaidAmount)
+	assert.Equal(t, ExpensePartialPaid, updated.PaymentStatus)
+	assert.ErrorIs(t, expenseStore.DeleteExpense(invoice.ID), ErrExpenseHasPayments)
+
+	status := ExpensePartialPaid
+	partial, err := expenseStore.ListExpenses(ExpenseFilter{ProviderID: &provider.ID, Status: &status})
+	require.NoError(t, err)
+	require.Len(t, partial, 1)
+
+	payments, err := s.List(0, day(1), day(31))
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+
+	opening, lines, err := s.Statement(provider.ID, day(2), day(31))
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, opening, "the expense paid on entry is settled")
+	require.Len(t, lines, 2)
+	assert.Equal(t, StatementExpense, lines[0].Kind)
+	assert.Equal(t, 1000.0, lines[0].Balance)
+	assert.Equal(t, StatementPayment, lines[1].Kind)
+	assert.Equal(t, "Transferencia - Transf. 123", lines[1].Description)
+	assert.Equal(t, 600.0, lines[1].Balance)
+
+	balances, err := s.Balances()
+	require.NoError(t, err)
+	require.Len(t, balances, 1)
+	assert.Equal(t, 600.0, balances[0].Balance)
+	assert.Equal(t, 600.0, balances[0].Overdue)
+
+	missing, err := s.GetByID(payment.ID + 100)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -32,6 +32,8 @@ type ProviderStore interface {
 	UpdateProvider(*Provider) error
 	DeleteProvider(id int64) error
 	GetProviderByID(id int64) (*Provider, error)
+	// GetProviderByCUIT matches the CUIT by its digits, ignoring dashes.
+	GetProviderByCUIT(cuit string) (*Provider, error)
 	GetAllProviders() ([]*Provider, error)
 	SearchProvidersFTS(q string, categoryID *int64, limit, offset int) ([]*Provider, error)
 
@@ -144,6 +146,17 @@ func (s *PostgresProviderStore) GetProviderByID(id int64) (*Provider, error) {
 	return scanProvider(s.db.QueryRow(q, id))
 }
 
+func (s *PostgresProviderStore) GetProviderByCUIT(cuit string) (*Provider, error) {
+	const q = `
+	SELECT p.id, p.name, p.address, p.phone, p.reference, p.email, p.cuit, p.created_at, p.deleted_at,
+	       p.category_id, pc.name
+	FROM providers p
+	LEFT JOIN provider_categories pc ON p.category_id = pc.id
+	WHERE regexp_replace(p.cuit, '\D', '', 'g') = regexp_replace($1, '\D', '', 'g') AND p.deleted_at IS NULL
+	LIMIT 1`
+	return scanProvider(s.db.QueryRow(q, cuit))
+}
+
 func (s *PostgresProviderStore) GetAllProviders() ([]*Provider, error) {
 	const q = `
 	SELECT p.id, p.name, p.address, p.phone, p.reference, p.email, p.cuit, p.created_at, p.deleted_at,
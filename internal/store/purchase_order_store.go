@@ -0,0 +1,431 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+type PurchaseOrderState string
+
+const (
+	PurchaseOrderDraft             PurchaseOrderState = "draft"
+	PurchaseOrderSent              PurchaseOrderState = "sent"
+	PurchaseOrderPartiallyReceived PurchaseOrderState = "partially_received"
+	PurchaseOrderReceived          PurchaseOrderState = "received"
+	PurchaseOrderCancelled         PurchaseOrderState = "cancelled"
+)
+
+// PurchaseOrder is an order of ingredients placed with a provider. Items
+// can be edited while it is a draft; receiving goods against it adds them
+// to ingredient stock.
+type PurchaseOrder struct {
+	ID            int64                `json:"id"`
+	ProviderID    int64                `json:"provider_id"`
+	ProviderName  string               `json:"provider_name"`
+	ProviderEmail string               `json:"provider_email,omitempty"`
+	State         PurchaseOrderState   `json:"state"`
+	ExpectedDate  *time.Time           `json:"expected_date"`
+	Notes         string               `json:"notes"`
+	UserID        *int64               `json:"user_id"`
+	Username      string               `json:"username,omitempty"`
+	Total         float64              `json:"total"` // at expected prices
+	SentAt        *time.Time           `json:"sent_at"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	Items         []*PurchaseOrderItem `json:"items,omitempty"`
+	Receipts      []*PurchaseReceipt   `json:"receipts,omitempty"`
+}
+
+type PurchaseOrderItem struct {
+	ID               int64   `json:"id"`
+	PurchaseOrderID  int64   `json:"purchase_order_id"`
+	IngredientID     int64   `json:"ingredient_id"`
+	IngredientName   string  `json:"ingredient_name"`
+	Quantity         float64 `json:"quantity"`
+	ExpectedPrice    float64 `json:"expected_price"`
+	ReceivedQuantity float64 `json:"received_quantity"`
+}
+
+// Subtotal is the line's value at the expected price.
+func (i *PurchaseOrderItem) Subtotal() float64 {
+	return i.Quantity * i.ExpectedPrice
+}
+
+// Pending is how much is still to be received.
+func (i *PurchaseOrderItem) Pending() float64 {
+	if i.ReceivedQuantity >= i.Quantity {
+		return 0
+	}
+	return i.Quantity - i.ReceivedQuantity
+}
+
+// PurchaseReceipt is a delivery received against a purchase order and the
+// expense recorded for it.
+type PurchaseReceipt struct {
+	ID              int64                  `json:"id"`
+	PurchaseOrderID int64                  `json:"purchase_order_id"`
+	ExpenseID       *int64                 `json:"expense_id"`
+	Amount          float64                `json:"amount"`
+	Notes           string                 `json:"notes"`
+	UserID          *int64                 `json:"user_id"`
+	Username        string                 `json:"username,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	Items           []*PurchaseReceiptItem `json:"items,omitempty"`
+}
+
+type PurchaseReceiptItem struct {
+	ID             int64   `json:"id"`
+	ReceiptID      int64   `json:"receipt_id"`
+	ItemID         int64   `json:"item_id"`
+	IngredientID   int64   `json:"ingredient_id"`
+	IngredientName string  `json:"ingredient_name"`
+	Quantity       float64 `json:"quantity"`
+	UnitPrice      float64 `json:"unit_price"`
+}
+
+type PurchaseOrderFilter struct {
+	ProviderID *int64
+	State      *PurchaseOrderState
+	Limit      int
+	Offset     int
+}
+
+type PurchaseOrderStore interface {
+	Create(po *PurchaseOrder) error
+	// UpdateDraft replaces the header fields and items of a draft order.
+	// It returns sql.ErrNoRows when the order is missing or not a draft.
+	UpdateDraft(po *PurchaseOrder) error
+	// GetByID returns the order with its items and receipts.
+	GetByID(id int64) (*PurchaseOrder, error)
+	// List returns orders newest first, without items.
+	List(f PurchaseOrderFilter) ([]*PurchaseOrder, error)
+	// SetState moves the order to state; sent also stamps sent_at.
+	SetState(id int64, state PurchaseOrderState) error
+
+	// LockTx locks the order until the transaction ends and returns it with
+	// its items.
+	LockTx(tx *sql.Tx, id int64) (*PurchaseOrder, error)
+	SetStateTx(tx *sql.Tx, id int64, state PurchaseOrderState) error
+	// CreateReceiptTx records a delivery and adds its quantities to the
+	// items' received quantity.
+	CreateReceiptTx(tx *sql.Tx, receipt *PurchaseReceipt) error
+}
+
+type PostgresPurchaseOrderStore struct {
+	db *sql.DB
+}
+
+func NewPostgresPurchaseOrderStore(db *sql.DB) *PostgresPurchaseOrderStore {
+	return &PostgresPurchaseOrderStore{db: db}
+}
+
+func (s *PostgresPurchaseOrderStore) Create(po *PurchaseOrder) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO purchase_orders (provider_id, expected_date, notes, user_id)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, state, created_at, updated_at`
+
+	var userID int64
+	if po.UserID != nil {
+		userID = *po.UserID
+	}
+	err = tx.QueryRow(query, po.ProviderID, po.ExpectedDate, po.Notes, nullInt64(userID)).
+		Scan(&po.ID, &po.State, &po.CreatedAt, &po.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertPurchaseOrderItems(tx, po); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertPurchaseOrderItems(tx *sql.Tx, po *PurchaseOrder) error {
+	query := `
+	INSERT INTO purchase_order_items (purchase_order_id, ingredient_id, quantity, expected_price)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id`
+
+	po.Total = 0
+	for _, item := range po.Items {
+		item.PurchaseOrderID = po.ID
+		if err := tx.QueryRow(query, po.ID, item.IngredientID, item.Quantity, item.ExpectedPrice).Scan(&item.ID); err != nil {
+			return err
+		}
+		po.Total += item.Quantity * item.ExpectedPrice
+	}
+	return nil
+}
+
+func (s *PostgresPurchaseOrderStore) UpdateDraft(po *PurchaseOrder) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE purchase_orders
+	SET provider_id = $1, expected_date = $2, notes = $3, updated_at = NOW()
+	WHERE id = $4 AND state = 'draft'
+	RETURNING updated_at`
+
+	if err := tx.QueryRow(query, po.ProviderID, po.ExpectedDate, po.Notes, po.ID).Scan(&po.UpdatedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM purchase_order_items WHERE purchase_order_id = $1`, po.ID); err != nil {
+		return err
+	}
+	if err := insertPurchaseOrderItems(tx, po); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const purchaseOrderQuery = `
+	SELECT po.id, po.provider_id, pr.name, COALESCE(pr.email, ''), po.state, po.expected_date, po.notes,
+	       po.user_id, COALESCE(u.username, ''),
+	       COALESCE((SELECT SUM(i.quantity * i.expected_price) FROM purchase_order_items i WHERE i.purchase_order_id = po.id), 0),
+	       po.sent_at, po.created_at, po.updated_at
+	FROM purchase_orders po
+	JOIN providers pr ON pr.id = po.provider_id
+	LEFT JOIN users u ON u.id = po.user_id`
+
+func scanPurchaseOrder(row interface{ Scan(dest ...any) error }) (*PurchaseOrder, error) {
+	var po PurchaseOrder
+	err := row.Scan(&po.ID, &po.ProviderID, &po.ProviderName, &po.ProviderEmail, &po.State, &po.ExpectedDate, &po.Notes,
+		&po.UserID, &po.Username, &po.Total, &po.SentAt, &po.CreatedAt, &po.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &po, nil
+}
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func purchaseOrderItems(q querier, id int64) ([]*PurchaseOrderItem, error) {
+	query := `
+	SELECT i.id, i.purchase_order_id, i.ingredient_id, ing.name, i.quantity, i.expected_price, i.received_quantity
+	FROM purchase_order_items i
+	JOIN ingredients ing ON ing.id = i.ingredient_id
+	WHERE i.purchase_order_id = $1
+	ORDER BY ing.name`
+
+	rows, err := q.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*PurchaseOrderItem
+	for rows.Next() {
+		var item PurchaseOrderItem
+		if err := rows.Scan(&item.ID, &item.PurchaseOrderID, &item.IngredientID, &item.IngredientName,
+			&item.Quantity, &item.ExpectedPrice, &item.ReceivedQuantity); err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresPurchaseOrderStore) GetByID(id int64) (*PurchaseOrder, error) {
+	po, err := scanPurchaseOrder(s.db.QueryRow(purchaseOrderQuery+` WHERE po.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if po.Items, err = purchaseOrderItems(s.db, id); err != nil {
+		return nil, err
+	}
+	if po.Receipts, err = s.receipts(id); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+func (s *PostgresPurchaseOrderStore) receipts(orderID int64) ([]*PurchaseReceipt, error) {
+	query := `
+	SELECT r.id, r.purchase_order_id, r.expense_id, r.amount, r.notes, r.user_id, COALESCE(u.username, ''), r.created_at
+	FROM purchase_receipts r
+	LEFT JOIN users u ON u.id = r.user_id
+	WHERE r.purchase_order_id = $1
+	ORDER BY r.created_at, r.id`
+
+	rows, err := s.db.Query(query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var receipts []*PurchaseReceipt
+	byID := make(map[int64]*PurchaseReceipt)
+	for rows.Next() {
+		var r PurchaseReceipt
+		if err := rows.Scan(&r.ID, &r.PurchaseOrderID, &r.ExpenseID, &r.Amount, &r.Notes, &r.UserID, &r.Username, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, &r)
+		byID[r.ID] = &r
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(receipts) == 0 {
+		return nil, nil
+	}
+
+	itemQuery := `
+	SELECT ri.id, ri.receipt_id, ri.item_id, i.ingredient_id, ing.name, ri.quantity, ri.unit_price
+	FROM purchase_receipt_items ri
+	JOIN purchase_receipts r ON r.id = ri.receipt_id
+	JOIN purchase_order_items i ON i.id = ri.item_id
+	JOIN ingredients ing ON ing.id = i.ingredient_id
+	WHERE r.purchase_order_id = $1
+	ORDER BY ri.id`
+
+	itemRows, err := s.db.Query(itemQuery, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var item PurchaseReceiptItem
+		if err := itemRows.Scan(&item.ID, &item.ReceiptID, &item.ItemID, &item.IngredientID, &item.IngredientName,
+			&item.Quantity, &item.UnitPrice); err != nil {
+			return nil, err
+		}
+		if r, ok := byID[item.ReceiptID]; ok {
+			r.Items = append(r.Items, &item)
+		}
+	}
+	return receipts, itemRows.Err()
+}
+
+func (s *PostgresPurchaseOrderStore) List(f PurchaseOrderFilter) ([]*PurchaseOrder, error) {
+	query := purchaseOrderQuery + `
+	WHERE ($1::BIGINT IS NULL OR po.provider_id = $1)
+	  AND ($2::TEXT IS NULL OR po.state = $2)
+	ORDER BY po.created_at DESC, po.id DESC
+	LIMIT NULLIF($3, 0) OFFSET $4`
+
+	var state *string
+	if f.State != nil {
+		v := string(*f.State)
+		state = &v
+	}
+	rows, err := s.db.Query(query, f.ProviderID, state, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []*PurchaseOrder
+	for rows.Next() {
+		po, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, po)
+	}
+	return orders, rows.Err()
+}
+
+const setPurchaseOrderStateQuery = `
+	UPDATE purchase_orders
+	SET state = $1,
+	    sent_at = CASE WHEN $1 = 'sent' THEN NOW() ELSE sent_at END,
+	    updated_at = NOW()
+	WHERE id = $2`
+
+func (s *PostgresPurchaseOrderStore) SetState(id int64, state PurchaseOrderState) error {
+	return expectOneRow(s.db.Exec(setPurchaseOrderStateQuery, state, id))
+}
+
+func (s *PostgresPurchaseOrderStore) SetStateTx(tx *sql.Tx, id int64, state PurchaseOrderState) error {
+	return expectOneRow(tx.Exec(setPurchaseOrderStateQuery, state, id))
+}
+
+func expectOneRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *PostgresPurchaseOrderStore) LockTx(tx *sql.Tx, id int64) (*PurchaseOrder, error) {
+	var exists int64
+	err := tx.QueryRow(`SELECT id FROM purchase_orders WHERE id = $1 FOR UPDATE`, id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	po, err := scanPurchaseOrder(tx.QueryRow(purchaseOrderQuery+` WHERE po.id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+	if po.Items, err = purchaseOrderItems(tx, id); err != nil {
+		return nil, err
+	}
+	return po, nil
+}
+
+func (s *PostgresPurchaseOrderStore) CreateReceiptTx(tx *sql.Tx, receipt *PurchaseReceipt) error {
+	query := `
+	INSERT INTO purchase_receipts (purchase_order_id, expense_id, amount, notes, user_id)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at`
+
+	var userID int64
+	if receipt.UserID != nil {
+		userID = *receipt.UserID
+	}
+	err := tx.QueryRow(query, receipt.PurchaseOrderID, receipt.ExpenseID, receipt.Amount, receipt.Notes, nullInt64(userID)).
+		Scan(&receipt.ID, &receipt.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	itemQuery := `
+	INSERT INTO purchase_receipt_items (receipt_id, item_id, quantity, unit_price)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id`
+	receivedQuery := `
+	UPDATE purchase_order_items
+	SET received_quantity = received_quantity + $1
+	WHERE id = $2 AND purchase_order_id = $3`
+	for _, item := range receipt.Items {
+		item.ReceiptID = receipt.ID
+		if err := tx.QueryRow(itemQuery, item.ReceiptID, item.ItemID, item.Quantity, item.UnitPrice).Scan(&item.ID); err != nil {
+			return err
+		}
+		if err := expectOneRow(tx.Exec(receivedQuery, item.Quantity, item.ItemID, receipt.PurchaseOrderID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurchaseOrderStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	db.Exec("INSERT INTO provider_categories (id, name) VALUES (1, 'Sin Categoría') ON CONFLICT (id) DO NOTHING")
+
+	s := NewPostgresPurchaseOrderStore(db)
+	ingredientStore := NewPostgresIngredientStore(db)
+
+	provider := &Provider{Name: "Molino", Reference: "ref-po", CUIT: "cuit-po", Email: "ventas@molino.test"}
+	require.NoError(t, NewPostgresProviderStore(db).CreateProvider(provider))
+	flour := &Ingredient{Name: "Harina", Cost: 900}
+	require.NoError(t, ingredientStore.CreateIngredient(flour))
+	sugar := &Ingredient{Name: "Azúcar", Cost: 1200}
+	require.NoError(t, ingredientStore.CreateIngredient(sugar))
+
+	po := &PurchaseOrder{ProviderID: provider.ID, Items: []*PurchaseOrderItem{
+		{IngredientID: flour.ID, Quantity: 10, ExpectedPrice: 900},
+	}}
+	require.NoError(t, s.Create(po))
+	assert.NotZero(t, po.ID)
+	assert.Equal(t, PurchaseOrderDraft, po.State)
+
+	po.Items = []*PurchaseOrderItem{
+		{IngredientID: flour.ID, Quantity: 20, ExpectedPrice: 900},
+		{IngredientID: sugar.ID, Quantity: 5, ExpectedPrice: 1200},
+	}
+	require.NoError(t, s.UpdateDraft(po))
+
+	got, err := s.GetByID(po.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "Molino", got.ProviderName)
+	assert.Equal(t, "ventas@molino.test", got.ProviderEmail)
+	assert.Equal(t, 24000.0, got.Total)
+	require.Len(t, got.Items, 2)
+
+	require.NoError(t, s.SetState(po.ID, PurchaseOrderSent))
+	assert.ErrorIs(t, s.UpdateDraft(po), sql.ErrNoRows, "only drafts can be edited")
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	locked, err := s.LockTx(tx, po.ID)
+	require.NoError(t, err)
+	require.Len(t, locked.Items, 2)
+	require.NoError(t, s.CreateReceiptTx(tx, &PurchaseReceipt{PurchaseOrderID: po.ID, Amount: 9000, Items: []*PurchaseReceiptItem{
+		{ItemID: locked.Items[0].ID, Quantity: 10, UnitPrice: 900},
+	}}))
+	require.NoError(t, ingredientStore.AdjustStockTx(tx, flour.ID, 10))
+	require.NoError(t, s.SetStateTx(tx, po.ID, PurchaseOrderPartiallyReceived))
+	require.NoError(t, tx.Commit())
+
+	got, err = s.GetByID(po.ID)
+	require.NoError(t, err)
+	assert.Equal(t, PurchaseOrderPartiallyReceived, got.State)
+	assert.NotNil(t, got.SentAt)
+	require.Len(t, got.Receipts, 1)
+	require.Len(t, got.Receipts[0].Items, 1)
+	for _, item := range got.Items {
+		if item.ID == locked.Items[0].ID {
+			assert.Equal(t, 10.0, item.ReceivedQuantity)
+			assert.Equal(t, 10.0, item.Pending())
+		}
+	}
+
+	updated, err := ingredientStore.GetIngredientByID(flour.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, updated.Stock)
+
+	state := PurchaseOrderPartiallyReceived
+	orders, err := s.List(PurchaseOrderFilter{State: &state})
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Empty(t, orders[0].Items)
+
+	draft := PurchaseOrderDraft
+	orders, err = s.List(PurchaseOrderFilter{State: &draft})
+	require.NoError(t, err)
+	assert.Empty(t, orders)
+
+	missing, err := s.GetByID(po.ID + 100)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
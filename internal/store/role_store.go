@@ -0,0 +1,193 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// AdminRole is the system role holding every permission.
+const AdminRole = "administrator"
+
+var (
+	// ErrRoleNameTaken is returned when another role already has the name.
+	ErrRoleNameTaken = errors.New("ya existe un rol con ese nombre")
+	// ErrRoleInUse is returned when deleting a role assigned to users.
+	ErrRoleInUse = errors.New("el rol está asignado a usuarios")
+)
+
+// Role is a named set of permissions assigned to users.
+type Role struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsSystem    bool      `json:"is_system"`
+	Permissions []string  `json:"permissions"`
+	UserCount   int       `json:"user_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Has reports whether the role grants permission.
+func (r *Role) Has(permission string) bool {
+	for _, p := range r.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+type RoleStore interface {
+	Create(role *Role) error
+	// Update saves the name, description and permissions. Renaming a role
+	// renames it on its users too.
+	Update(role *Role) error
+	Delete(id int64) error
+	GetByID(id int64) (*Role, error)
+	GetByName(name string) (*Role, error)
+	List() ([]*Role, error)
+}
+
+type PostgresRoleStore struct {
+	db *sql.DB
+}
+
+func NewPostgresRoleStore(db *sql.DB) *PostgresRoleStore {
+	return &PostgresRoleStore{db: db}
+}
+
+func roleWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.ConstraintName {
+		case "roles_name_key":
+			return ErrRoleNameTaken
+		case "users_role_fkey":
+			return ErrRoleInUse
+		}
+	}
+	return err
+}
+
+func (s *PostgresRoleStore) Create(role *Role) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+	INSERT INTO roles (name, description)
+	VALUES ($1, $2)
+	RETURNING id, is_system, created_at`, role.Name, role.Description).
+		Scan(&role.ID, &role.IsSystem, &role.CreatedAt)
+	if err != nil {
+		return roleWriteError(err)
+	}
+	if err := setRolePermissionsTx(tx, role.ID, role.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresRoleStore) Update(role *Role) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = expectOneRow(tx.Exec(`
+	UPDATE roles SET name = $1, description = $2
+	WHERE id = $3`, role.Name, role.Description, role.ID))
+	if err != nil {
+		return roleWriteError(err)
+	}
+	if err := setRolePermissionsTx(tx, role.ID, role.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func setRolePermissionsTx(tx *sql.Tx, roleID int64, permissions []string) error {
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role_id = $1`, roleID); err != nil {
+		return err
+	}
+	for _, p := range permissions {
+		_, err := tx.Exec(`
+		INSERT INTO role_permissions (role_id, permission) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, roleID, p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes a role that isn't a system role nor assigned to users.
+func (s *PostgresRoleStore) Delete(id int64) error {
+	return roleWriteError(expectOneRow(s.db.Exec(`DELETE FROM roles WHERE id = $1 AND NOT is_system`, id)))
+}
+
+const roleQuery = `
+	SELECT r.id, r.name, r.description, r.is_system, r.created_at,
+	       COALESCE((SELECT string_agg(rp.permission, ',' ORDER BY rp.permission)
+	                 FROM role_permissions rp WHERE rp.role_id = r.id), ''),
+	       (SELECT COUNT(*) FROM users u WHERE u.role = r.name AND u.deleted_at IS NULL)
+	FROM roles r`
+
+func scanRole(row interface{ Scan(...any) error }) (*Role, error) {
+	r := &Role{}
+	var permissions string
+	if err := row.Scan(&r.ID, &r.Name, &r.Description, &r.IsSystem, &r.CreatedAt, &permissions, &r.UserCount); err != nil {
+		return nil, err
+	}
+	r.Permissions = splitPermissions(permissions)
+	return r, nil
+}
+
+// splitPermissions parses the comma separated list built with string_agg.
+func splitPermissions(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func (s *PostgresRoleStore) GetByID(id int64) (*Role, error) {
+	r, err := scanRole(s.db.QueryRow(roleQuery+` WHERE r.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+func (s *PostgresRoleStore) GetByName(name string) (*Role, error) {
+	r, err := scanRole(s.db.QueryRow(roleQuery+` WHERE r.name = $1`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return r, err
+}
+
+// List returns the system roles first, then the rest by name.
+func (s *PostgresRoleStore) List() ([]*Role, error) {
+	rows, err := s.db.Query(roleQuery + ` ORDER BY r.is_system DESC, r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*Role
+	for rows.Next() {
+		r, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
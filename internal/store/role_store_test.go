@@ -0,0 +1,87 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleStore_CRUD(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	rs := NewPostgresRoleStore(db)
+
+	admin, err := rs.GetByName(AdminRole)
+	require.NoError(t, err)
+	require.NotNil(t, admin)
+	assert.True(t, admin.IsSystem)
+	assert.ElementsMatch(t, AllPermissions(), admin.Permissions)
+
+	name := fmt.Sprintf("cajero-%d", time.Now().UnixNano())
+	role := &Role{Name: name, Description: "Caja", Permissions: []string{PermSalesCreate, PermSalesView}}
+	require.NoError(t, rs.Create(role))
+	assert.NotZero(t, role.ID)
+	assert.False(t, role.IsSystem)
+
+	err = rs.Create(&Role{Name: name})
+	assert.ErrorIs(t, err, ErrRoleNameTaken)
+
+	role.Name = name + "-x"
+	role.Permissions = []string{PermWasteRegister}
+	require.NoError(t, rs.Update(role))
+
+	got, err := rs.GetByID(role.ID)
+	require.NoError(t, err)
+	assert.Equal(t, name+"-x", got.Name)
+	assert.Equal(t, []string{PermWasteRegister}, got.Permissions)
+	assert.True(t, got.Has(PermWasteRegister))
+	assert.False(t, got.Has(PermSalesCreate))
+
+	require.NoError(t, rs.Delete(role.ID))
+	got, err = rs.GetByID(role.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	// System roles aren't deleted.
+	assert.Error(t, rs.Delete(admin.ID))
+}
+
+func TestRoleStore_UserPermissions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	rs := NewPostgresRoleStore(db)
+	us := NewPostgresUserStore(db)
+	ts := NewPostgresTokenStore(db)
+
+	name := fmt.Sprintf("stock-%d", time.Now().UnixNano())
+	role := &Role{Name: name, Permissions: []string{PermStockView, PermStockAdjust}}
+	require.NoError(t, rs.Create(role))
+
+	user := &User{Username: "stocker", Email: "stocker@example.com", Role: name}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, us.CreateUser(user))
+
+	token, err := ts.CreateNewToken(int(user.ID), time.Hour, tokens.ScopeAuth)
+	require.NoError(t, err)
+
+	got, err := us.GetUserToken(tokens.ScopeAuth, token.Plaintext)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.True(t, got.Can(PermStockAdjust))
+	assert.False(t, got.Can(PermSalesCreate))
+
+	// Roles assigned to users can't be deleted.
+	assert.ErrorIs(t, rs.Delete(role.ID), ErrRoleInUse)
+
+	// Renaming the role keeps it on the user.
+	role.Name = name + "-renamed"
+	require.NoError(t, rs.Update(role))
+	got, err = us.GetUserToken(tokens.ScopeAuth, token.Plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, name+"-renamed", got.Role)
+	assert.True(t, got.Can(PermStockView))
+}
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Security events.
+const (
+	SecurityLoginFailed            = "login_failed"
+	SecurityTwoFactorFailed        = "two_factor_failed"
+	SecurityPINFailed              = "pin_failed"
+	SecurityPINLockout             = "pin_lockout"
+	SecurityLockout                = "lockout"
+	SecurityUnlock                 = "unlock"
+	SecurityPasswordResetRequested = "password_reset_requested"
+	SecurityPasswordReset          = "password_reset"
+)
+
+// SecurityEvent is a login failure, lockout or password reset, kept for
+// administrators to review.
+type SecurityEvent struct {
+	ID        int64     `json:"id"`
+	UserID    *int64    `json:"user_id"`
+	Username  string    `json:"username"`
+	Event     string    `json:"event"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SecurityEventFilter struct {
+	UserID *int64
+	Event  string
+	Limit  int
+	Offset int
+}
+
+// LoginState is a user's failed logins, or wrong PINs, since the last
+// success.
+type LoginState struct {
+	FailedLogins      int
+	LastFailedLoginAt *time.Time
+	LockedUntil       *time.Time
+}
+
+type SecurityStore interface {
+	// GetLoginState returns nil when the user doesn't exist.
+	GetLoginState(userID int64) (*LoginState, error)
+	// RecordLoginFailure counts a failed login. Reaching threshold locks the
+	// account for lockFor and restarts the count; locked reports whether
+	// this failure did it.
+	RecordLoginFailure(userID int64, threshold int, lockFor time.Duration) (state *LoginState, locked bool, err error)
+	// ResetLoginFailures clears the count and any lock. sql.ErrNoRows when
+	// the user doesn't exist.
+	ResetLoginFailures(userID int64) error
+	InsertEvent(e *SecurityEvent) error
+	// ListEvents returns the events matching the filter newest first, with
+	// the total number of matches.
+	ListEvents(f SecurityEventFilter) ([]*SecurityEvent, int, error)
+	// SetPIN stores the hash of the user's POS PIN, removing it when nil.
+	// sql.ErrNoRows when the user doesn't exist.
+	SetPIN(userID int64, pinHash []byte) error
+	// GetPINHash returns nil when the user has no PIN.
+	GetPINHash(userID int64) ([]byte, error)
+	// GetPINState, RecordPINFailure and ResetPINFailures are the login
+	// state ones for wrong PINs, which are counted and locked apart.
+	GetPINState(userID int64) (*LoginState, error)
+	RecordPINFailure(userID int64, threshold int, lockFor time.Duration) (state *LoginState, locked bool, err error)
+	ResetPINFailures(userID int64) error
+	// ListPINUsers returns the active users with a PIN, by username.
+	ListPINUsers() ([]*User, error)
+}
+
+type PostgresSecurityStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSecurityStore(db *sql.DB) *PostgresSecurityStore {
+	return &PostgresSecurityStore{db: db}
+}
+
+// attemptColumns are the users columns that count failed logins or PINs.
+type attemptColumns struct {
+	failed, lastFailed, lockedUntil string
+}
+
+var (
+	loginColumns = attemptColumns{"failed_logins", "last_failed_login_at", "locked_until"}
+	pinColumns   = attemptColumns{"failed_pins", "last_failed_pin_at", "pin_locked_until"}
+)
+
+func (s *PostgresSecurityStore) GetLoginState(userID int64) (*LoginState, error) {
+	return s.getAttemptState(loginColumns, userID)
+}
+
+func (s *PostgresSecurityStore) RecordLoginFailure(userID int64, threshold int, lockFor time.Duration) (*LoginState, bool, error) {
+	return s.recordFailure(loginColumns, userID, threshold, lockFor)
+}
+
+func (s *PostgresSecurityStore) ResetLoginFailures(userID int64) error {
+	return s.resetFailures(loginColumns, userID)
+}
+
+func (s *PostgresSecurityStore) GetPINState(userID int64) (*LoginState, error) {
+	return s.getAttemptState(pinColumns, userID)
+}
+
+func (s *PostgresSecurityStore) RecordPINFailure(userID int64, threshold int, lockFor time.Duration) (*LoginState, bool, error) {
+	return s.recordFailure(pinColumns, userID, threshold, lockFor)
+}
+
+func (s *PostgresSecurityStore) ResetPINFailures(userID int64) error {
+	return s.resetFailures(pinColumns, userID)
+}
+
+func (s *PostgresSecurityStore) getAttemptState(c attemptColumns, userID int64) (*LoginState, error) {
+	st := &LoginState{}
+	err := s.db.QueryRow(fmt.Sprintf(`
+	SELECT %s, %s, %s
+	FROM users WHERE id = $1 AND deleted_at IS NULL`, c.failed, c.lastFailed, c.lockedUntil), userID).
+		Scan(&st.FailedLogins, &st.LastFailedLoginAt, &st.LockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func (s *PostgresSecurityStore) recordFailure(c attemptColumns, userID int64, threshold int, lockFor time.Duration) (*LoginState, bool, error) {
+	st := &LoginState{}
+	var locked bool
+	err := s.db.QueryRow(fmt.Sprintf(`
+	UPDATE users SET
+	    %[1]s = CASE WHEN %[1]s + 1 >= $2 THEN 0 ELSE %[1]s + 1 END,
+	    %[2]s = NOW(),
+	    %[3]s = CASE WHEN %[1]s + 1 >= $2 THEN NOW() + $3::int * INTERVAL '1 second' ELSE %[3]s END
+	WHERE id = $1
+	RETURNING %[1]s, %[2]s, %[3]s, %[1]s = 0`, c.failed, c.lastFailed, c.lockedUntil),
+		userID, threshold, int64(lockFor/time.Second)).
+		Scan(&st.FailedLogins, &st.LastFailedLoginAt, &st.LockedUntil, &locked)
+	if err != nil {
+		return nil, false, err
+	}
+	return st, locked, nil
+}
+
+func (s *PostgresSecurityStore) resetFailures(c attemptColumns, userID int64) error {
+	return expectOneRow(s.db.Exec(fmt.Sprintf(`
+	UPDATE users SET %s = 0, %s = NULL, %s = NULL
+	WHERE id = $1 AND deleted_at IS NULL`, c.failed, c.lastFailed, c.lockedUntil), userID))
+}
+
+func (s *PostgresSecurityStore) InsertEvent(e *SecurityEvent) error {
+	var userID int64
+	if e.UserID != nil {
+		userID = *e.UserID
+	}
+	return s.db.QueryRow(`
+	INSERT INTO security_events (user_id, username, event, ip, user_agent, details)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`,
+		nullInt64(userID), e.Username, e.Event, e.IP, e.UserAgent, e.Details).Scan(&e.ID, &e.CreatedAt)
+}
+
+func (s *PostgresSecurityStore) ListEvents(f SecurityEventFilter) ([]*SecurityEvent, int, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	where := "WHERE TRUE"
+	args := []any{}
+	if f.UserID != nil {
+		where += fmt.Sprintf(" AND user_id=$%d", len(args)+1)
+		args = append(args, *f.UserID)
+	}
+	if f.Event != "" {
+		where += fmt.Sprintf(" AND event=$%d", len(args)+1)
+		args = append(args, f.Event)
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM security_events `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	q := `
+	SELECT id, user_id, username, event, ip, user_agent, details, created_at
+	FROM security_events ` + where + fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var out []*SecurityEvent
+	for rows.Next() {
+		e := &SecurityEvent{}
+		var userID sql.NullInt64
+		if err := rows.Scan(&e.ID, &userID, &e.Username, &e.Event, &e.IP, &e.UserAgent, &e.Details, &e.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if userID.Valid {
+			e.UserID = &userID.Int64
+		}
+		out = append(out, e)
+	}
+	return out, total, rows.Err()
+}
+
+func (s *PostgresSecurityStore) SetPIN(userID int64, pinHash []byte) error {
+	return expectOneRow(s.db.Exec(`UPDATE users SET pin_hash = $2 WHERE id = $1 AND deleted_at IS NULL`, userID, pinHash))
+}
+
+func (s *PostgresSecurityStore) GetPINHash(userID int64) ([]byte, error) {
+	var hash []byte
+	err := s.db.QueryRow(`SELECT pin_hash FROM users WHERE id = $1 AND deleted_at IS NULL`, userID).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return hash, err
+}
+
+func (s *PostgresSecurityStore) ListPINUsers() ([]*User, error) {
+	rows, err := s.db.Query(`
+	SELECT id, username, email, role, is_active, created_at
+	FROM users
+	WHERE pin_hash IS NOT NULL AND is_active AND deleted_at IS NULL
+	ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u := &User{}
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.IsActive, &u.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
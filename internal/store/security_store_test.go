@@ -0,0 +1,128 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	us := NewPostgresUserStore(db)
+	s := NewPostgresSecurityStore(db)
+
+	user := &User{Username: "cajero", Email: "cajero@example.com", Role: "employee"}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, us.CreateUser(user))
+
+	st, err := s.GetLoginState(user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, st)
+	assert.Zero(t, st.FailedLogins)
+	assert.Nil(t, st.LockedUntil)
+
+	st, locked, err := s.RecordLoginFailure(user.ID, 3, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, locked)
+	assert.Equal(t, 1, st.FailedLogins)
+	require.NotNil(t, st.LastFailedLoginAt)
+
+	_, _, err = s.RecordLoginFailure(user.ID, 3, time.Minute)
+	require.NoError(t, err)
+	st, locked, err = s.RecordLoginFailure(user.ID, 3, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked, "the third failure locks")
+	assert.Zero(t, st.FailedLogins, "the count restarts after a lockout")
+	require.NotNil(t, st.LockedUntil)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *st.LockedUntil, 5*time.Second)
+
+	got, err := us.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Locked())
+
+	require.NoError(t, s.ResetLoginFailures(user.ID))
+	got, err = us.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.False(t, got.Locked())
+	assert.ErrorIs(t, s.ResetLoginFailures(999999), sql.ErrNoRows)
+
+	st, err = s.GetLoginState(999999)
+	require.NoError(t, err)
+	assert.Nil(t, st)
+
+	require.NoError(t, s.InsertEvent(&SecurityEvent{UserID: &user.ID, Username: "cajero", Event: SecurityLockout, IP: "10.0.0.1"}))
+	unknown := &SecurityEvent{Username: "nadie", Event: SecurityLoginFailed, IP: "10.0.0.2"}
+	require.NoError(t, s.InsertEvent(unknown))
+	assert.NotZero(t, unknown.ID)
+
+	events, total, err := s.ListEvents(SecurityEventFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, events, 2)
+	assert.Equal(t, unknown.ID, events[0].ID, "newest first")
+	assert.Nil(t, events[0].UserID)
+
+	events, total, err = s.ListEvents(SecurityEventFilter{UserID: &user.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, SecurityLockout, events[0].Event)
+
+	_, total, err = s.ListEvents(SecurityEventFilter{Event: SecurityPasswordReset})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestSecurityStorePIN(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	us := NewPostgresUserStore(db)
+	s := NewPostgresSecurityStore(db)
+
+	user := &User{Username: "cajero", Email: "cajero@example.com", Role: "employee"}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, us.CreateUser(user))
+
+	hash, err := s.GetPINHash(user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, hash)
+	users, err := s.ListPINUsers()
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	require.NoError(t, s.SetPIN(user.ID, []byte("hash")))
+	hash, err = s.GetPINHash(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hash"), hash)
+	users, err = s.ListPINUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "cajero", users[0].Username)
+
+	require.NoError(t, s.SetPIN(user.ID, nil))
+	hash, err = s.GetPINHash(user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, hash)
+	assert.ErrorIs(t, s.SetPIN(999999, []byte("hash")), sql.ErrNoRows)
+
+	_, locked, err := s.RecordPINFailure(user.ID, 2, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, locked)
+	st, locked, err := s.RecordPINFailure(user.ID, 2, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	require.NotNil(t, st.LockedUntil)
+
+	login, err := s.GetLoginState(user.ID)
+	require.NoError(t, err)
+	assert.Zero(t, login.FailedLogins)
+	assert.Nil(t, login.LockedUntil, "wrong pins don't lock the account")
+
+	require.NoError(t, s.ResetPINFailures(user.ID))
+	st, err = s.GetPINState(user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, st.LockedUntil)
+}
@@ -5,9 +5,14 @@ import (
 	"time"
 )
 
+// Shift is a cash register session: it is opened against a drawer and held by
+// whoever is currently on duty, so it survives employee handovers.
 type Shift struct {
 	ID              int64      `json:"id"`
-	UserID          int64      `json:"user_id"`
+	RegisterID      int64      `json:"register_id"`
+	UserID          int64      `json:"user_id"` // opened by
+	CurrentUserID   int64      `json:"current_user_id"`
+	ClosedBy        *int64     `json:"closed_by"`
 	StartTime       time.Time  `json:"start_time"`
 	EndTime         *time.Time `json:"end_time"`
 	StartCash       float64    `json:"start_cash"`
@@ -16,14 +21,29 @@ type Shift struct {
 	Difference      *float64   `json:"difference"`
 	Status          string     `json:"status"` // 'open', 'closed'
 	Notes           string     `json:"notes"`
+	LocationID      int64      `json:"location_id"` // stock location of the register
+}
+
+type ShiftHandover struct {
+	ID          int64     `json:"id"`
+	ShiftID     int64     `json:"shift_id"`
+	FromUserID  int64     `json:"from_user_id"`
+	ToUserID    int64     `json:"to_user_id"`
+	CountedCash *float64  `json:"counted_cash"`
+	Notes       string    `json:"notes"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type ShiftStore interface {
 	Create(shift *Shift) error
 	Update(shift *Shift) error
 	GetByID(id int64) (*Shift, error)
+	GetOpenShiftByRegisterID(registerID int64) (*Shift, error)
 	GetOpenShiftByUserID(userID int64) (*Shift, error)
+	ListByRegisterID(registerID int64, limit, offset int) ([]*Shift, error)
 	ListByUserID(userID int64, limit, offset int) ([]*Shift, error)
+	Handover(h *ShiftHandover) error
+	ListHandovers(shiftID int64) ([]*ShiftHandover, error)
 }
 
 type PostgresShiftStore struct {
@@ -34,78 +54,107 @@ func NewPostgresShiftStore(db *sql.DB) *PostgresShiftStore {
 	return &PostgresShiftStore{db: db}
 }
 
+const shiftColumns = `id, register_id, user_id, current_user_id, closed_by, start_time, end_time, start_cash,
+		end_cash_expected, end_cash_declared, difference, status, COALESCE(notes, ''),
+		(SELECT location_id FROM cash_registers cr WHERE cr.id = shifts.register_id)`
+
+func scanShift(row interface{ Scan(dest ...any) error }) (*Shift, error) {
+	var shift Shift
+	err := row.Scan(
+		&shift.ID, &shift.RegisterID, &shift.UserID, &shift.CurrentUserID, &shift.ClosedBy,
+		&shift.StartTime, &shift.EndTime, &shift.StartCash,
+		&shift.EndCashExpected, &shift.EndCashDeclared, &shift.Difference, &shift.Status, &shift.Notes,
+		&shift.LocationID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &shift, nil
+}
+
 func (s *PostgresShiftStore) Create(shift *Shift) error {
 	query := `
-		INSERT INTO shifts (user_id, start_time, start_cash, status, notes)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO shifts (register_id, user_id, current_user_id, start_time, start_cash, status, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id`
-	
+
 	if shift.StartTime.IsZero() {
 		shift.StartTime = time.Now()
 	}
 	if shift.Status == "" {
 		shift.Status = "open"
 	}
+	if shift.CurrentUserID == 0 {
+		shift.CurrentUserID = shift.UserID
+	}
 
-	return s.db.QueryRow(query, shift.UserID, shift.StartTime, shift.StartCash, shift.Status, shift.Notes).Scan(&shift.ID)
+	return s.db.QueryRow(query, shift.RegisterID, shift.UserID, shift.CurrentUserID, shift.StartTime, shift.StartCash, shift.Status, shift.Notes).Scan(&shift.ID)
 }
 
 func (s *PostgresShiftStore) Update(shift *Shift) error {
 	query := `
 		UPDATE shifts
-		SET end_time=$1, end_cash_expected=$2, end_cash_declared=$3, difference=$4, status=$5, notes=$6
-		WHERE id=$7`
-	
-	_, err := s.db.Exec(query, shift.EndTime, shift.EndCashExpected, shift.EndCashDeclared, shift.Difference, shift.Status, shift.Notes, shift.ID)
+		SET end_time=$1, end_cash_expected=$2, end_cash_declared=$3, difference=$4, status=$5, notes=$6,
+			current_user_id=$7, closed_by=$8
+		WHERE id=$9`
+
+	_, err := s.db.Exec(query, shift.EndTime, shift.EndCashExpected, shift.EndCashDeclared, shift.Difference, shift.Status, shift.Notes,
+		shift.CurrentUserID, shift.ClosedBy, shift.ID)
 	return err
 }
 
 func (s *PostgresShiftStore) GetByID(id int64) (*Shift, error) {
-	query := `
-		SELECT id, user_id, start_time, end_time, start_cash, end_cash_expected, end_cash_declared, difference, status, COALESCE(notes, '')
-		FROM shifts WHERE id = $1`
-	
-	var shift Shift
-	err := s.db.QueryRow(query, id).Scan(
-		&shift.ID, &shift.UserID, &shift.StartTime, &shift.EndTime, &shift.StartCash, 
-		&shift.EndCashExpected, &shift.EndCashDeclared, &shift.Difference, &shift.Status, &shift.Notes,
-	)
+	query := `SELECT ` + shiftColumns + ` FROM shifts WHERE id = $1`
+
+	shift, err := scanShift(s.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	if err != nil {
-		return nil, err
+	return shift, err
+}
+
+func (s *PostgresShiftStore) GetOpenShiftByRegisterID(registerID int64) (*Shift, error) {
+	query := `SELECT ` + shiftColumns + `
+		FROM shifts WHERE register_id = $1 AND status = 'open'`
+
+	shift, err := scanShift(s.db.QueryRow(query, registerID))
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	return &shift, nil
+	return shift, err
 }
 
+// GetOpenShiftByUserID returns the open session currently held by the user, if any.
 func (s *PostgresShiftStore) GetOpenShiftByUserID(userID int64) (*Shift, error) {
-	query := `
-		SELECT id, user_id, start_time, end_time, start_cash, end_cash_expected, end_cash_declared, difference, status, COALESCE(notes, '')
-		FROM shifts WHERE user_id = $1 AND status = 'open'
+	query := `SELECT ` + shiftColumns + `
+		FROM shifts WHERE current_user_id = $1 AND status = 'open'
 		ORDER BY start_time DESC LIMIT 1`
-	
-	var shift Shift
-	err := s.db.QueryRow(query, userID).Scan(
-		&shift.ID, &shift.UserID, &shift.StartTime, &shift.EndTime, &shift.StartCash, 
-		&shift.EndCashExpected, &shift.EndCashDeclared, &shift.Difference, &shift.Status, &shift.Notes,
-	)
+
+	shift, err := scanShift(s.db.QueryRow(query, userID))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	if err != nil {
-		return nil, err
-	}
-	return &shift, nil
+	return shift, err
+}
+
+func (s *PostgresShiftStore) ListByRegisterID(registerID int64, limit, offset int) ([]*Shift, error) {
+	query := `SELECT ` + shiftColumns + `
+		FROM shifts WHERE register_id = $1
+		ORDER BY start_time DESC LIMIT $2 OFFSET $3`
+
+	return s.list(query, registerID, limit, offset)
 }
 
 func (s *PostgresShiftStore) ListByUserID(userID int64, limit, offset int) ([]*Shift, error) {
-	query := `
-		SELECT id, user_id, start_time, end_time, start_cash, end_cash_expected, end_cash_declared, difference, status, COALESCE(notes, '')
+	query := `SELECT ` + shiftColumns + `
 		FROM shifts WHERE user_id = $1
 		ORDER BY start_time DESC LIMIT $2 OFFSET $3`
-	
-	rows, err := s.db.Query(query, userID, limit, offset)
+
+	return s.list(query, userID, limit, offset)
+}
+
+func (s *PostgresShiftStore) list(query string, args ...any) ([]*Shift, error) {
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -113,14 +162,67 @@ func (s *PostgresShiftStore) ListByUserID(userID int64, limit, offset int) ([]*S
 
 	var shifts []*Shift
 	for rows.Next() {
-		var s Shift
-		if err := rows.Scan(
-			&s.ID, &s.UserID, &s.StartTime, &s.EndTime, &s.StartCash, 
-			&s.EndCashExpected, &s.EndCashDeclared, &s.Difference, &s.Status, &s.Notes,
-		); err != nil {
+		shift, err := scanShift(rows)
+		if err != nil {
 			return nil, err
 		}
-		shifts = append(shifts, &s)
+		shifts = append(shifts, shift)
 	}
 	return shifts, rows.Err()
 }
+
+// Handover records a change of the person responsible for an open session
+// and moves the session to the incoming user in the same transaction.
+func (s *PostgresShiftStore) Handover(h *ShiftHandover) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE shifts SET current_user_id = $1 WHERE id = $2 AND status = 'open'`, h.ToUserID, h.ShiftID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	const q = `
+		INSERT INTO shift_handovers (shift_id, from_user_id, to_user_id, counted_cash, notes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+	if err := tx.QueryRow(q, h.ShiftID, h.FromUserID, h.ToUserID, h.CountedCash, h.Notes).Scan(&h.ID, &h.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *PostgresShiftStore) ListHandovers(shiftID int64) ([]*ShiftHandover, error) {
+	const q = `
+		SELECT id, shift_id, from_user_id, to_user_id, counted_cash, COALESCE(notes, ''), created_at
+		FROM shift_handovers
+		WHERE shift_id = $1
+		ORDER BY created_at`
+
+	rows, err := s.db.Query(q, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*ShiftHandover
+	for rows.Next() {
+		h := &ShiftHandover{}
+		if err := rows.Scan(&h.ID, &h.ShiftID, &h.FromUserID, &h.ToUserID, &h.CountedCash, &h.Notes, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
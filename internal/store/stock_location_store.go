@@ -0,0 +1,126 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrLocationNameTaken is returned when another location already has the name.
+var ErrLocationNameTaken = errors.New("ya existe una ubicación con ese nombre")
+
+// StockLocation is a place that holds stock: the shop, the production kitchen,
+// a second branch. Stock written without a location goes to the default one.
+type StockLocation struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	IsDefault bool      `json:"is_default"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type StockLocationStore interface {
+	Create(location *StockLocation) error
+	Update(location *StockLocation) error
+	GetByID(id int64) (*StockLocation, error)
+	GetDefault() (*StockLocation, error)
+	List(activeOnly bool) ([]*StockLocation, error)
+}
+
+type PostgresStockLocationStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStockLocationStore(db *sql.DB) *PostgresStockLocationStore {
+	return &PostgresStockLocationStore{db: db}
+}
+
+// defaultLocationSQL resolves to the id of the default location.
+const defaultLocationSQL = `(SELECT id FROM stock_locations WHERE is_default)`
+
+// locationSQL resolves a location parameter, falling back to the default
+// location when it is NULL (see nullInt64).
+func locationSQL(param string) string {
+	return `COALESCE(` + param + `::INT, ` + defaultLocationSQL + `)`
+}
+
+func locationWriteError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.ConstraintName == "stock_locations_name_key" {
+		return ErrLocationNameTaken
+	}
+	return err
+}
+
+func (s *PostgresStockLocationStore) Create(location *StockLocation) error {
+	const q = `
+	INSERT INTO stock_locations (name, is_active)
+	VALUES ($1, $2)
+	RETURNING id, is_default, created_at, updated_at`
+	err := s.db.QueryRow(q, location.Name, location.IsActive).
+		Scan(&location.ID, &location.IsDefault, &location.CreatedAt, &location.UpdatedAt)
+	return locationWriteError(err)
+}
+
+func (s *PostgresStockLocationStore) Update(location *StockLocation) error {
+	const q = `
+	UPDATE stock_locations
+	SET name = $1, is_active = $2, updated_at = NOW()
+	WHERE id = $3
+	RETURNING is_default, updated_at`
+	err := s.db.QueryRow(q, location.Name, location.IsActive, location.ID).Scan(&location.IsDefault, &location.UpdatedAt)
+	return locationWriteError(err)
+}
+
+const stockLocationColumns = `id, name, is_default, is_active, created_at, updated_at`
+
+func scanStockLocation(row interface{ Scan(dest ...any) error }) (*StockLocation, error) {
+	var l StockLocation
+	if err := row.Scan(&l.ID, &l.Name, &l.IsDefault, &l.IsActive, &l.CreatedAt, &l.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (s *PostgresStockLocationStore) GetByID(id int64) (*StockLocation, error) {
+	l, err := scanStockLocation(s.db.QueryRow(`SELECT `+stockLocationColumns+` FROM stock_locations WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return l, err
+}
+
+func (s *PostgresStockLocationStore) GetDefault() (*StockLocation, error) {
+	l, err := scanStockLocation(s.db.QueryRow(`SELECT ` + stockLocationColumns + ` FROM stock_locations WHERE is_default`))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return l, err
+}
+
+func (s *PostgresStockLocationStore) List(activeOnly bool) ([]*StockLocation, error) {
+	const q = `
+	SELECT ` + stockLocationColumns + `
+	FROM stock_locations
+	WHERE ($1 = FALSE OR is_active = TRUE)
+	ORDER BY is_default DESC, name`
+
+	rows, err := s.db.Query(q, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []*StockLocation
+	for rows.Next() {
+		l, err := scanStockLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockLocationStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	s := NewPostgresStockLocationStore(db)
+
+	def, err := s.GetDefault()
+	require.NoError(t, err)
+	require.NotNil(t, def)
+	assert.True(t, def.IsDefault)
+
+	name := fmt.Sprintf("Depósito %d", time.Now().UnixNano())
+	location := &StockLocation{Name: name, IsActive: true}
+	require.NoError(t, s.Create(location))
+	assert.NotZero(t, location.ID)
+	assert.False(t, location.IsDefault)
+
+	t.Run("duplicate name", func(t *testing.T) {
+		err := s.Create(&StockLocation{Name: name, IsActive: true})
+		assert.ErrorIs(t, err, ErrLocationNameTaken)
+	})
+
+	t.Run("inactive locations are left out", func(t *testing.T) {
+		location.IsActive = false
+		require.NoError(t, s.Update(location))
+
+		active, err := s.List(true)
+		require.NoError(t, err)
+		require.NotEmpty(t, active)
+		assert.True(t, active[0].IsDefault, "the default location comes first")
+		for _, l := range active {
+			assert.NotEqual(t, location.ID, l.ID)
+		}
+
+		all, err := s.List(false)
+		require.NoError(t, err)
+		assert.Greater(t, len(all), len(active))
+	})
+}
+
+func TestStockPerLocation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	stock := NewPostgresLocalStockStore(db)
+	lots := NewPostgresLotStore(db)
+	transfers := NewPostgresStockTransferStore(db)
+	prod := setupProductForStockTest(t, db)
+
+	kitchen := &StockLocation{Name: fmt.Sprintf("Cocina %d", time.Now().UnixNano()), IsActive: true}
+	require.NoError(t, NewPostgresStockLocationStore(db).Create(kitchen))
+
+	_, err := stock.Create(prod.ID, 2, StockChange{Reason: MovementInitial})
+	require.NoError(t, err)
+	_, err = stock.Create(prod.ID, 6, StockChange{LocationID: kitchen.ID, Reason: MovementInitial})
+	require.NoError(t, err)
+
+	shop, err := stock.GetByProductID(prod.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, shop.Quantity)
+
+	perLocation, err := stock.ListByProductID(prod.ID)
+	require.NoError(t, err)
+	require.Len(t, perLocation, 2)
+
+	_, err = stock.AdjustQuantity(prod.ID, 4, StockChange{LocationID: kitchen.ID, Reason: MovementProduction, Lot: &NewLot{Number: "K-1"}})
+	require.NoError(t, err)
+
+	t.Run("a transfer moves the lots along", func(t *testing.T) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		transfer := &StockTransfer{
+			FromLocationID: kitchen.ID,
+			ToLocationID:   shop.LocationID,
+			Notes:          "reparto",
+			Items:          []*StockTransferItem{{ProductID: prod.ID, Quantity: 3}},
+		}
+		require.NoError(t, transfers.CreateTx(tx, transfer))
+
+		change := StockChange{LocationID: kitchen.ID, Reason: MovementTransfer, ReferenceType: ReferenceStockTransfer, ReferenceID: transfer.ID}
+		_, err = stock.AdjustQuantityTx(tx, prod.ID, -3, change)
+		require.NoError(t, err)
+		change.LocationID = shop.LocationID
+		_, err = stock.AdjustQuantityTx(tx, prod.ID, 3, change)
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		saved, err := transfers.GetByID(transfer.ID)
+		require.NoError(t, err)
+		require.Len(t, saved.Items, 1)
+		assert.Equal(t, kitchen.Name, saved.FromLocationName)
+		assert.Equal(t, 3.0, saved.Items[0].Quantity)
+
+		list, err := transfers.List(time.Now().Add(-time.Hour), time.Now().Add(time.Hour), kitchen.ID)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+
+		lotList, err := lots.ListByProduct(prod.ID, false)
+		require.NoError(t, err)
+		require.Len(t, lotList, 2)
+		for _, lot := range lotList {
+			assert.Equal(t, "K-1", lot.LotNumber)
+			switch lot.LocationID {
+			case kitchen.ID:
+				assert.Equal(t, 1.0, lot.Quantity)
+			case shop.LocationID:
+				assert.Equal(t, 3.0, lot.Quantity)
+			default:
+				t.Fatalf("unexpected location %d", lot.LocationID)
+			}
+		}
+
+		moved, err := stock.GetByProductID(prod.ID, kitchen.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 7.0, moved.Quantity)
+		shop, err = stock.GetByProductID(prod.ID, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 5.0, shop.Quantity)
+	})
+}
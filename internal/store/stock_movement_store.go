@@ -0,0 +1,151 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Reasons a product's local stock changes.
+const (
+	MovementInitial    = "initial"
+	MovementSale       = "sale"
+	MovementVoid       = "void"
+	MovementAdjustment = "adjustment"
+	MovementProduction = "production"
+	MovementWaste      = "waste"
+	MovementTransfer   = "transfer"
+	MovementCount      = "count"
+)
+
+// Reference documents a movement can point to.
+const (
+	ReferenceLocalSale      = "local_sale"
+	ReferenceInventoryCount = "inventory_count"
+	ReferenceWaste          = "waste"
+	ReferenceStockTransfer  = "stock_transfer"
+)
+
+// StockChange describes why stock is being written. Every write to
+// local_stock takes one and records it in the movement ledger.
+type StockChange struct {
+	// LocationID is the location whose stock is written; zero is the
+	// default location.
+	LocationID    int64
+	Reason        string
+	UserID        int64
+	ReferenceType string
+	ReferenceID   int64
+	Note          string
+	// Lot, when adding stock, opens a lot with the added quantity.
+	Lot *NewLot
+}
+
+type StockMovement struct {
+	ID            int64     `json:"id"`
+	ProductID     int64     `json:"product_id"`
+	LocationID    int64     `json:"location_id"`
+	Delta         float64   `json:"delta"`
+	Balance       float64   `json:"balance"`
+	Reason        string    `json:"reason"`
+	UserID        *int64    `json:"user_id"`
+	Username      string    `json:"username,omitempty"`
+	ReferenceType *string   `json:"reference_type"`
+	ReferenceID   *int64    `json:"reference_id"`
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type StockMovementStore interface {
+	// ListByProduct returns the movements of a product at a location in
+	// [from, to), oldest first. A zero locationID is the default location.
+	ListByProduct(productID, locationID int64, from, to time.Time) ([]*StockMovement, error)
+	// BalanceAt reconstructs the stock of a product at a location at the
+	// given instant.
+	BalanceAt(productID, locationID int64, at time.Time) (float64, error)
+}
+
+type PostgresStockMovementStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStockMovementStore(db *sql.DB) *PostgresStockMovementStore {
+	return &PostgresStockMovementStore{db: db}
+}
+
+func (s *PostgresStockMovementStore) ListByProduct(productID, locationID int64, from, to time.Time) ([]*StockMovement, error) {
+	query := `
+	SELECT sm.id, sm.product_id, sm.location_id, sm.delta, sm.balance, sm.reason, sm.user_id, COALESCE(u.username, ''),
+	       sm.reference_type, sm.reference_id, sm.note, sm.created_at
+	FROM stock_movements sm
+	LEFT JOIN users u ON u.id = sm.user_id
+	WHERE sm.product_id = $1 AND sm.location_id = ` + locationSQL("$4") + `
+	  AND sm.created_at >= $2 AND sm.created_at < $3
+	ORDER BY sm.created_at, sm.id`
+
+	rows, err := s.db.Query(query, productID, from, to, nullInt64(locationID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []*StockMovement
+	for rows.Next() {
+		var m StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.LocationID, &m.Delta, &m.Balance, &m.Reason, &m.UserID, &m.Username,
+			&m.ReferenceType, &m.ReferenceID, &m.Note, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, &m)
+	}
+	return movements, rows.Err()
+}
+
+func (s *PostgresStockMovementStore) BalanceAt(productID, locationID int64, at time.Time) (float64, error) {
+	query := `
+	SELECT COALESCE(SUM(delta), 0)
+	FROM stock_movements
+	WHERE product_id = $1 AND location_id = ` + locationSQL("$3") + ` AND created_at < $2`
+
+	var balance float64
+	err := s.db.QueryRow(query, productID, at, nullInt64(locationID)).Scan(&balance)
+	return balance, err
+}
+
+// insertStockMovement records a change already applied to a stock row,
+// within the same transaction, and applies it to the lots at its location:
+// stock taken out consumes lots FIFO, a void puts back what its document took,
+// stock transferred in brings the lots the transfer took out elsewhere, and
+// stock added with a Lot opens one.
+func insertStockMovement(tx *sql.Tx, stock *LocalStock, delta float64, change StockChange) error {
+	query := `
+	INSERT INTO stock_movements (product_id, location_id, delta, balance, reason, user_id, reference_type, reference_id, note)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	RETURNING id`
+
+	var movementID int64
+	err := tx.QueryRow(query, stock.ProductID, stock.LocationID, delta, stock.Quantity, change.Reason,
+		nullInt64(change.UserID), nullString(change.ReferenceType), nullInt64(change.ReferenceID), change.Note).Scan(&movementID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case delta < 0:
+		return consumeLotsTx(tx, stock.ProductID, stock.LocationID, -delta, movementID)
+	case delta > 0 && change.Lot != nil:
+		return createLotTx(tx, stock.ProductID, stock.LocationID, delta, movementID, change.Lot)
+	case delta > 0 && change.Reason == MovementVoid && change.ReferenceType != "":
+		return restoreLotsTx(tx, stock.ProductID, delta, movementID, change.ReferenceType, change.ReferenceID)
+	case delta > 0 && change.Reason == MovementTransfer && change.ReferenceType != "":
+		return transferLotsTx(tx, stock.ProductID, stock.LocationID, delta, movementID, change.ReferenceType, change.ReferenceID)
+	}
+	return nil
+}
+
+func nullInt64(v int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: v, Valid: v != 0}
+}
+
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStockMovementStore_Ledger(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	stockStore := NewPostgresLocalStockStore(db)
+	s := NewPostgresStockMovementStore(db)
+	prod := setupProductForStockTest(t, db)
+
+	before := time.Now().Add(-time.Second)
+	_, err := stockStore.Create(prod.ID, 10, StockChange{Reason: MovementInitial})
+	require.NoError(t, err)
+	_, err = stockStore.AdjustQuantity(prod.ID, 5, StockChange{Reason: MovementProduction, Note: "horneada"})
+	require.NoError(t, err)
+	_, err = stockStore.AdjustQuantity(prod.ID, -3, StockChange{Reason: MovementSale, ReferenceType: ReferenceLocalSale, ReferenceID: 42})
+	require.NoError(t, err)
+	after := time.Now().Add(time.Second)
+
+	movements, err := s.ListByProduct(prod.ID, 0, before, after)
+	require.NoError(t, err)
+	require.Len(t, movements, 3)
+
+	assert.Equal(t, MovementInitial, movements[0].Reason)
+	assert.Equal(t, 10.0, movements[0].Balance)
+	assert.Nil(t, movements[0].UserID)
+
+	assert.Equal(t, MovementProduction, movements[1].Reason)
+	assert.Equal(t, 5.0, movements[1].Delta)
+	assert.Equal(t, 15.0, movements[1].Balance)
+	assert.Equal(t, "horneada", movements[1].Note)
+
+	assert.Equal(t, -3.0, movements[2].Delta)
+	assert.Equal(t, 12.0, movements[2].Balance)
+	require.NotNil(t, movements[2].ReferenceType)
+	assert.Equal(t, ReferenceLocalSale, *movements[2].ReferenceType)
+	assert.Equal(t, int64(42), *movements[2].ReferenceID)
+
+	balance, err := s.BalanceAt(prod.ID, 0, before)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, balance)
+
+	balance, err = s.BalanceAt(prod.ID, 0, movements[2].CreatedAt)
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, balance)
+
+	balance, err = s.BalanceAt(prod.ID, 0, after)
+	require.NoError(t, err)
+	assert.Equal(t, 12.0, balance)
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// StockTransfer is an internal delivery note (remito interno): goods sent
+// from one location to another. The stock moves when it is created.
+type StockTransfer struct {
+	ID               int64                `json:"id"`
+	FromLocationID   int64                `json:"from_location_id"`
+	FromLocationName string               `json:"from_location_name"`
+	ToLocationID     int64                `json:"to_location_id"`
+	ToLocationName   string               `json:"to_location_name"`
+	Notes            string               `json:"notes"`
+	UserID           *int64               `json:"user_id"`
+	Username         string               `json:"username,omitempty"`
+	CreatedAt        time.Time            `json:"created_at"`
+	Items            []*StockTransferItem `json:"items,omitempty"`
+}
+
+type StockTransferItem struct {
+	ID          int64   `json:"id"`
+	TransferID  int64   `json:"transfer_id"`
+	ProductID   int64   `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	SaleUnit    string  `json:"sale_unit"`
+	Quantity    float64 `json:"quantity"`
+}
+
+type StockTransferStore interface {
+	// CreateTx inserts the transfer and its items. Moving the stock is up to
+	// the caller, in the same transaction.
+	CreateTx(tx *sql.Tx, transfer *StockTransfer) error
+	GetByID(id int64) (*StockTransfer, error)
+	// List returns the transfers in [from, to), newest first. locationID 0
+	// means every location, otherwise transfers leaving or entering it.
+	List(from, to time.Time, locationID int64) ([]*StockTransfer, error)
+}
+
+type PostgresStockTransferStore struct {
+	db *sql.DB
+}
+
+func NewPostgresStockTransferStore(db *sql.DB) *PostgresStockTransferStore {
+	return &PostgresStockTransferStore{db: db}
+}
+
+func (s *PostgresStockTransferStore) CreateTx(tx *sql.Tx, transfer *StockTransfer) error {
+	query := `
+	INSERT INTO stock_transfers (from_location_id, to_location_id, notes, user_id)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+
+	var userID int64
+	if transfer.UserID != nil {
+		userID = *transfer.UserID
+	}
+	err := tx.QueryRow(query, transfer.FromLocationID, transfer.ToLocationID, transfer.Notes, nullInt64(userID)).
+		Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	itemQuery := `
+	INSERT INTO stock_transfer_items (transfer_id, product_id, quantity)
+	VALUES ($1, $2, $3)
+	RETURNING id`
+	for _, item := range transfer.Items {
+		item.TransferID = transfer.ID
+		if err := tx.QueryRow(itemQuery, item.TransferID, item.ProductID, item.Quantity).Scan(&item.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const stockTransferQuery = `
+	SELECT t.id, t.from_location_id, fl.name, t.to_location_id, tl.name, t.notes, t.user_id,
+	       COALESCE(u.username, ''), t.created_at
+	FROM stock_transfers t
+	JOIN stock_locations fl ON fl.id = t.from_location_id
+	JOIN stock_locations tl ON tl.id = t.to_location_id
+	LEFT JOIN users u ON u.id = t.user_id`
+
+func scanStockTransfer(row interface{ Scan(dest ...any) error }) (*StockTransfer, error) {
+	var t StockTransfer
+	err := row.Scan(&t.ID, &t.FromLocationID, &t.FromLocationName, &t.ToLocationID, &t.ToLocationName, &t.Notes,
+		&t.UserID, &t.Username, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *PostgresStockTransferStore) GetByID(id int64) (*StockTransfer, error) {
+	transfer, err := scanStockTransfer(s.db.QueryRow(stockTransferQuery+` WHERE t.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT i.id, i.transfer_id, i.product_id, p.name, p.sale_unit, i.quantity
+	FROM stock_transfer_items i
+	JOIN products p ON p.id = i.product_id
+	WHERE i.transfer_id = $1
+	ORDER BY p.name`
+
+	rows, err := s.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item StockTransferItem
+		if err := rows.Scan(&item.ID, &item.TransferID, &item.ProductID, &item.ProductName, &item.SaleUnit, &item.Quantity); err != nil {
+			return nil, err
+		}
+		transfer.Items = append(transfer.Items, &item)
+	}
+	return transfer, rows.Err()
+}
+
+func (s *PostgresStockTransferStore) List(from, to time.Time, locationID int64) ([]*StockTransfer, error) {
+	query := stockTransferQuery + `
+	WHERE t.created_at >= $1 AND t.created_at < $2
+	  AND ($3 = 0 OR t.from_location_id = $3 OR t.to_location_id = $3)
+	ORDER BY t.created_at DESC, t.id DESC`
+
+	rows, err := s.db.Query(query, from, to, locationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []*StockTransfer
+	for rows.Next() {
+		transfer, err := scanStockTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	return transfers, rows.Err()
+}
@@ -7,6 +7,30 @@ import (
 	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
 )
 
+// Session lifetimes. API tokens expire SessionTTL after login; web sessions
+// slide: each use pushes the expiry SessionTTL ahead, up to SessionMaxAge
+// after login.
+const (
+	SessionTTL    = 24 * time.Hour
+	SessionMaxAge = 7 * 24 * time.Hour
+	// sessionTouchInterval throttles the last seen updates, so a session is
+	// written at most once a minute.
+	sessionTouchInterval = time.Minute
+)
+
+// Session is an active authentication token.
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Expiry     time.Time `json:"expiry"`
+	// Current marks the session the request was made with.
+	Current bool `json:"current"`
+}
+
 type PostgresTokenStore struct {
 	db *sql.DB
 }
@@ -21,6 +45,19 @@ type TokenStore interface {
 	Insert(token *tokens.Token) error
 	CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error)
 	DeleteAllTokensForUser(userID int, scope string) error
+	// ListSessions returns the user's unexpired authentication tokens, most
+	// recently used first.
+	ListSessions(userID int64) ([]*Session, error)
+	// RevokeSession deletes one of the user's sessions. sql.ErrNoRows when
+	// the user has no such session.
+	RevokeSession(userID, sessionID int64) error
+	// RevokeOtherSessions deletes all of the user's sessions but keepID.
+	RevokeOtherSessions(userID, keepID int64) error
+	// TouchSession records a use of the session from ip, at most once every
+	// minute. With slide, it also moves the expiry SessionTTL ahead, never
+	// past SessionMaxAge after login. It returns the new expiry and whether
+	// the session was updated.
+	TouchSession(sessionID int64, ip string, slide bool) (time.Time, bool, error)
 }
 
 func (t *PostgresTokenStore) CreateNewToken(userID int, ttl time.Duration, scope string) (*tokens.Token, error) {
@@ -35,11 +72,11 @@ func (t *PostgresTokenStore) CreateNewToken(userID int, ttl time.Duration, scope
 
 func (t *PostgresTokenStore) Insert(token *tokens.Token) error {
 	query := `
-  INSERT INTO tokens (hash, user_id, expiry, scope)
-  VALUES ($1, $2, $3, $4)
+  INSERT INTO tokens (hash, user_id, expiry, scope, user_agent, ip)
+  VALUES ($1, $2, $3, $4, $5, $6)
   `
 
-	_, err := t.db.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope)
+	_, err := t.db.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope, token.UserAgent, token.IP)
 	return err
 }
 
@@ -52,3 +89,81 @@ func (t *PostgresTokenStore) DeleteAllTokensForUser(userID int, scope string) er
 	_, err := t.db.Exec(query, scope, userID)
 	return err
 }
+
+func (t *PostgresTokenStore) ListSessions(userID int64) ([]*Session, error) {
+	query := `
+  SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expiry
+  FROM tokens
+  WHERE user_id = $1 AND scope = $2 AND expiry > $3
+  ORDER BY last_seen_at DESC, id DESC
+  `
+
+	rows, err := t.db.Query(query, userID, tokens.ScopeAuth, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		s := &Session{}
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.Expiry); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+func (t *PostgresTokenStore) RevokeSession(userID, sessionID int64) error {
+	query := `
+  DELETE FROM tokens
+  WHERE id = $1 AND user_id = $2 AND scope = $3
+  `
+
+	result, err := t.db.Exec(query, sessionID, userID, tokens.ScopeAuth)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (t *PostgresTokenStore) RevokeOtherSessions(userID, keepID int64) error {
+	query := `
+  DELETE FROM tokens
+  WHERE user_id = $1 AND scope = $2 AND id <> $3
+  `
+
+	_, err := t.db.Exec(query, userID, tokens.ScopeAuth, keepID)
+	return err
+}
+
+func (t *PostgresTokenStore) TouchSession(sessionID int64, ip string, slide bool) (time.Time, bool, error) {
+	now := time.Now()
+	query := `
+  UPDATE tokens
+  SET last_seen_at = $2,
+      ip = $3,
+      expiry = CASE WHEN $4 THEN LEAST($5::timestamptz, created_at + $6::int * INTERVAL '1 second') ELSE expiry END
+  WHERE id = $1 AND last_seen_at <= $7
+  RETURNING expiry
+  `
+
+	var expiry time.Time
+	err := t.db.QueryRow(query, sessionID, now, ip, slide, now.Add(SessionTTL),
+		int64(SessionMaxAge/time.Second), now.Add(-sessionTouchInterval)).Scan(&expiry)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return expiry, true, nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSession(t *testing.T, ts *PostgresTokenStore, userID int64, ua string) *tokens.Token {
+	t.Helper()
+	token, err := tokens.GenerateToken(int(userID), SessionTTL, tokens.ScopeAuth)
+	require.NoError(t, err)
+	token.UserAgent = ua
+	token.IP = "10.0.0.1"
+	require.NoError(t, ts.Insert(token))
+	return token
+}
+
+func TestTokenStore_Sessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	us := NewPostgresUserStore(db)
+	ts := NewPostgresTokenStore(db)
+
+	user := &User{Username: "cajero", Email: "cajero@example.com", Role: "employee"}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, us.CreateUser(user))
+
+	laptop := newSession(t, ts, user.ID, "Firefox")
+	phone := newSession(t, ts, user.ID, "Chrome")
+	tablet := newSession(t, ts, user.ID, "Safari")
+	_, err := ts.CreateNewToken(int(user.ID), time.Hour, tokens.ScopePasswordReset)
+	require.NoError(t, err)
+
+	sessions, err := ts.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3, "only authentication tokens are sessions")
+	assert.Equal(t, "10.0.0.1", sessions[0].IP)
+
+	got, err := us.GetUserToken(tokens.ScopeAuth, laptop.Plaintext)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.NotZero(t, got.SessionID)
+
+	// Touches are throttled: a fresh session isn't written again.
+	_, touched, err := ts.TouchSession(got.SessionID, "10.0.0.2", true)
+	require.NoError(t, err)
+	assert.False(t, touched)
+
+	_, err = db.Exec(`UPDATE tokens SET last_seen_at = NOW() - INTERVAL '5 minutes', expiry = NOW() + INTERVAL '1 hour' WHERE id = $1`, got.SessionID)
+	require.NoError(t, err)
+	expiry, touched, err := ts.TouchSession(got.SessionID, "10.0.0.2", true)
+	require.NoError(t, err)
+	assert.True(t, touched)
+	assert.WithinDuration(t, time.Now().Add(SessionTTL), expiry, time.Minute, "web sessions slide")
+
+	// Sliding never goes past the maximum age.
+	_, err = db.Exec(`UPDATE tokens SET created_at = NOW() - INTERVAL '7 days' + INTERVAL '1 hour', last_seen_at = NOW() - INTERVAL '5 minutes' WHERE id = $1`, got.SessionID)
+	require.NoError(t, err)
+	expiry, _, err = ts.TouchSession(got.SessionID, "10.0.0.2", true)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiry, time.Minute)
+
+	// Revoking takes the session out and it stops authenticating.
+	phoneUser, err := us.GetUserToken(tokens.ScopeAuth, phone.Plaintext)
+	require.NoError(t, err)
+	require.NoError(t, ts.RevokeSession(user.ID, phoneUser.SessionID))
+	assert.ErrorIs(t, ts.RevokeSession(user.ID, phoneUser.SessionID), sql.ErrNoRows)
+	revoked, err := us.GetUserToken(tokens.ScopeAuth, phone.Plaintext)
+	require.NoError(t, err)
+	assert.Nil(t, revoked)
+
+	require.NoError(t, ts.RevokeOtherSessions(user.ID, got.SessionID))
+	sessions, err = ts.ListSessions(user.ID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, got.SessionID, sessions[0].ID)
+	revoked, err = us.GetUserToken(tokens.ScopeAuth, tablet.Plaintext)
+	require.NoError(t, err)
+	assert.Nil(t, revoked)
+}
+
+func TestUserStore_ToggleUserStatusRevokesSessions(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	us := NewPostgresUserStore(db)
+	ts := NewPostgresTokenStore(db)
+
+	user := &User{Username: "repartidor", Email: "repartidor@example.com", Role: "employee"}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, us.CreateUser(user))
+	newSession(t, ts, user.ID, "Chrome")
+
+	require.NoError(t, us.ToggleUserStatus(user.ID))
+	sessions, err := ts.ListSessions(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions, "deactivating revokes the sessions")
+
+	// Activating again doesn't bring anything back, nor fails.
+	require.NoError(t, us.ToggleUserStatus(user.ID))
+	got, err := us.GetUserByID(user.ID)
+	require.NoError(t, err)
+	assert.True(t, got.IsActive)
+
+	assert.ErrorIs(t, us.ToggleUserStatus(999999), sql.ErrNoRows)
+}
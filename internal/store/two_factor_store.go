@@ -0,0 +1,156 @@
+package store
+
+import (
+	"database/sql"
+)
+
+// TwoFactor is a user's TOTP enrollment. Secret is set from the start of the
+// enrollment; codes are only asked for once Enabled.
+type TwoFactor struct {
+	UserID      int64
+	Secret      string
+	Enabled     bool
+	LastCounter int64
+	// RecoveryCodesLeft counts the unused recovery codes.
+	RecoveryCodesLeft int
+}
+
+type TwoFactorStore interface {
+	// Get returns the user's enrollment, with an empty Secret when the user
+	// never started one. nil when the user doesn't exist.
+	Get(userID int64) (*TwoFactor, error)
+	// SetPending starts an enrollment with secret, replacing any unconfirmed
+	// one. It fails with sql.ErrNoRows when two-factor is already enabled.
+	SetPending(userID int64, secret string) error
+	// Enable confirms the pending secret, used at counter, and replaces the
+	// recovery codes.
+	Enable(userID, counter int64, codeHashes [][]byte) error
+	// Disable removes the secret and the recovery codes.
+	Disable(userID int64) error
+	// UseCounter records a code's time step as used. It reports false when
+	// that step or a later one was used already, i.e. a replay.
+	UseCounter(userID, counter int64) (bool, error)
+	// UseRecoveryCode spends an unused recovery code, reporting false when
+	// there is none with that hash.
+	UseRecoveryCode(userID int64, codeHash []byte) (bool, error)
+	ReplaceRecoveryCodes(userID int64, codeHashes [][]byte) error
+}
+
+type PostgresTwoFactorStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTwoFactorStore(db *sql.DB) *PostgresTwoFactorStore {
+	return &PostgresTwoFactorStore{db: db}
+}
+
+func (s *PostgresTwoFactorStore) Get(userID int64) (*TwoFactor, error) {
+	query := `
+	SELECT u.id, COALESCE(u.totp_secret, ''), u.totp_enabled, u.totp_last_counter,
+	       (SELECT COUNT(*) FROM user_recovery_codes rc WHERE rc.user_id = u.id AND rc.used_at IS NULL)
+	FROM users u
+	WHERE u.id = $1 AND u.deleted_at IS NULL
+	`
+
+	tf := &TwoFactor{}
+	err := s.db.QueryRow(query, userID).Scan(&tf.UserID, &tf.Secret, &tf.Enabled, &tf.LastCounter, &tf.RecoveryCodesLeft)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tf, nil
+}
+
+func (s *PostgresTwoFactorStore) SetPending(userID int64, secret string) error {
+	return expectOneRow(s.db.Exec(`
+	UPDATE users SET totp_secret = $2, totp_last_counter = 0
+	WHERE id = $1 AND NOT totp_enabled`, userID, secret))
+}
+
+func (s *PostgresTwoFactorStore) Enable(userID, counter int64, codeHashes [][]byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = expectOneRow(tx.Exec(`
+	UPDATE users SET totp_enabled = TRUE, totp_last_counter = $2
+	WHERE id = $1 AND totp_secret IS NOT NULL AND NOT totp_enabled`, userID, counter))
+	if err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodesTx(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresTwoFactorStore) Disable(userID int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = expectOneRow(tx.Exec(`
+	UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_counter = 0
+	WHERE id = $1`, userID))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresTwoFactorStore) UseCounter(userID, counter int64) (bool, error) {
+	err := expectOneRow(s.db.Exec(`
+	UPDATE users SET totp_last_counter = $2
+	WHERE id = $1 AND totp_last_counter < $2`, userID, counter))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *PostgresTwoFactorStore) UseRecoveryCode(userID int64, codeHash []byte) (bool, error) {
+	err := expectOneRow(s.db.Exec(`
+	UPDATE user_recovery_codes SET used_at = NOW()
+	WHERE id = (SELECT id FROM user_recovery_codes
+	            WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	            LIMIT 1)
+	  AND used_at IS NULL`, userID, codeHash))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *PostgresTwoFactorStore) ReplaceRecoveryCodes(userID int64, codeHashes [][]byte) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodesTx(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodesTx(tx *sql.Tx, userID int64, codeHashes [][]byte) error {
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
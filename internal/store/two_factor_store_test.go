@@ -0,0 +1,70 @@
+package store
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	us := NewPostgresUserStore(db)
+	s := NewPostgresTwoFactorStore(db)
+
+	user := &User{Username: "admin", Email: "admin@example.com", Role: AdminRole}
+	require.NoError(t, user.PasswordHash.Set("password"))
+	require.NoError(t, us.CreateUser(user))
+
+	tf, err := s.Get(user.ID)
+	require.NoError(t, err)
+	assert.Empty(t, tf.Secret)
+	assert.False(t, tf.Enabled)
+
+	missing, err := s.Get(9999)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	require.NoError(t, s.SetPending(user.ID, "SECRET"))
+	require.NoError(t, s.Enable(user.ID, 100, [][]byte{[]byte("a"), []byte("b")}))
+
+	tf, err = s.Get(user.ID)
+	require.NoError(t, err)
+	assert.True(t, tf.Enabled)
+	assert.Equal(t, "SECRET", tf.Secret)
+	assert.Equal(t, int64(100), tf.LastCounter)
+	assert.Equal(t, 2, tf.RecoveryCodesLeft)
+
+	assert.ErrorIs(t, s.SetPending(user.ID, "OTHER"), sql.ErrNoRows, "an enabled secret isn't replaced")
+
+	// Codes from the app are only good once.
+	ok, err := s.UseCounter(user.ID, 100)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = s.UseCounter(user.ID, 101)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.UseRecoveryCode(user.ID, []byte("a"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.UseRecoveryCode(user.ID, []byte("a"))
+	require.NoError(t, err)
+	assert.False(t, ok, "recovery codes are spent")
+
+	require.NoError(t, s.ReplaceRecoveryCodes(user.ID, [][]byte{[]byte("c")}))
+	ok, err = s.UseRecoveryCode(user.ID, []byte("b"))
+	require.NoError(t, err)
+	assert.False(t, ok, "old codes are gone")
+
+	require.NoError(t, s.Disable(user.ID))
+	tf, err = s.Get(user.ID)
+	require.NoError(t, err)
+	assert.False(t, tf.Enabled)
+	assert.Empty(t, tf.Secret)
+	assert.Zero(t, tf.RecoveryCodesLeft)
+
+	assert.ErrorIs(t, s.Disable(9999), sql.ErrNoRows)
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/RamunnoAJ/aesovoy-server/internal/tokens"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -48,6 +49,20 @@ type User struct {
 	PasswordHash Password  `json:"-"`
 	CreatedAt    time.Time `json:"created_at"`
 	DeletedAt    *time.Time `json:"deleted_at"`
+	// LockedUntil is set while the account is locked for failed logins.
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	// Permissions are the ones granted by the role. They are only loaded for
+	// the authenticated user (see GetUserToken).
+	Permissions []string `json:"permissions,omitempty"`
+	// SessionID is the authentication token the user was loaded with (see
+	// GetUserToken).
+	SessionID int64 `json:"-"`
+	// APIKey is the key the request was authenticated with, nil for user
+	// tokens (see APIKeyStore.GetUserAPIKey).
+	APIKey *APIKey `json:"-"`
+	// DeviceUser is who is logged in on the device when this user is
+	// operating its POS with their PIN, nil otherwise.
+	DeviceUser *User `json:"-"`
 }
 
 var AnonymousUser = &User{}
@@ -56,6 +71,21 @@ func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
 
+// Locked reports whether the account is locked for failed logins.
+func (u *User) Locked() bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(time.Now())
+}
+
+// Can reports whether the user's role grants permission.
+func (u *User) Can(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
 type PostgresUserStore struct {
 	db *sql.DB
 }
@@ -75,6 +105,8 @@ type UserStore interface {
 	DeleteUser(id int64) error
 	GetUserToken(scope, tokenPlainText string) (*User, error)
 	GetAllUsers() ([]*User, error)
+	// ToggleUserStatus activates or deactivates the user. Deactivating also
+	// revokes all of the user's sessions.
 	ToggleUserStatus(id int64) error
 }
 
@@ -107,7 +139,7 @@ func (s *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 	}
 
 	query := `
-	SELECT id, username, email, password_hash, role, is_active, created_at, deleted_at
+	SELECT id, username, email, password_hash, role, is_active, created_at, deleted_at, locked_until
 	FROM users
 	WHERE username = $1 AND deleted_at IS NULL
 	`
@@ -121,6 +153,7 @@ func (s *PostgresUserStore) GetUserByUsername(username string) (*User, error) {
 		&user.IsActive,
 		&user.CreatedAt,
 		&user.DeletedAt,
+		&user.LockedUntil,
 	)
 
 	if err == sql.ErrNoRows {
@@ -140,7 +173,7 @@ func (s *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
 	}
 
 	query := `
-	SELECT id, username, email, password_hash, role, is_active, created_at, deleted_at
+	SELECT id, username, email, password_hash, role, is_active, created_at, deleted_at, locked_until
 	FROM users
 	WHERE email = $1 AND deleted_at IS NULL
 	`
@@ -154,6 +187,7 @@ func (s *PostgresUserStore) GetUserByEmail(email string) (*User, error) {
 		&user.IsActive,
 		&user.CreatedAt,
 		&user.DeletedAt,
+		&user.LockedUntil,
 	)
 
 	if err == sql.ErrNoRows {
@@ -173,7 +207,7 @@ func (s *PostgresUserStore) GetUserByID(id int64) (*User, error) {
 	}
 
 	query := `
-	SELECT id, username, email, password_hash, role, is_active, created_at, deleted_at
+	SELECT id, username, email, password_hash, role, is_active, created_at, deleted_at, locked_until
 	FROM users
 	WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -187,6 +221,7 @@ func (s *PostgresUserStore) GetUserByID(id int64) (*User, error) {
 		&user.IsActive,
 		&user.CreatedAt,
 		&user.DeletedAt,
+		&user.LockedUntil,
 	)
 
 	if err == sql.ErrNoRows {
@@ -244,7 +279,12 @@ func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User
 	tokenHash := sha256.Sum256([]byte(plaintextPassword))
 
 	query := `
-	SELECT u.id, u.username, u.email, u.password_hash, u.role, u.is_active, u.created_at, u.deleted_at
+	SELECT u.id, u.username, u.email, u.password_hash, u.role, u.is_active, u.created_at, u.deleted_at,
+	       COALESCE((SELECT string_agg(rp.permission, ',')
+	                 FROM role_permissions rp
+	                 INNER JOIN roles r ON r.id = rp.role_id
+	                 WHERE r.name = u.role), ''),
+	       t.id
 	FROM users u
 	INNER JOIN tokens t ON t.user_id = u.id
 	WHERE t.hash = $1 AND t.scope = $2 and t.expiry > $3 AND u.deleted_at IS NULL
@@ -254,6 +294,7 @@ func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User
 		PasswordHash: Password{},
 	}
 
+	var permissions string
 	err := s.db.QueryRow(query, tokenHash[:], scope, time.Now()).Scan(
 		&user.ID,
 		&user.Username,
@@ -263,6 +304,8 @@ func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User
 		&user.IsActive,
 		&user.CreatedAt,
 		&user.DeletedAt,
+		&permissions,
+		&user.SessionID,
 	)
 
 	if err == sql.ErrNoRows {
@@ -273,12 +316,13 @@ func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User
 		return nil, err
 	}
 
+	user.Permissions = splitPermissions(permissions)
 	return user, nil
 }
 
 func (s *PostgresUserStore) GetAllUsers() ([]*User, error) {
 	query := `
-	SELECT id, username, email, role, is_active, created_at, deleted_at
+	SELECT id, username, email, role, is_active, created_at, deleted_at, locked_until
 	FROM users
 	WHERE deleted_at IS NULL
 	ORDER BY username
@@ -293,7 +337,7 @@ func (s *PostgresUserStore) GetAllUsers() ([]*User, error) {
 	var users []*User
 	for rows.Next() {
 		u := &User{}
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.IsActive, &u.CreatedAt, &u.DeletedAt); err != nil {
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.Role, &u.IsActive, &u.CreatedAt, &u.DeletedAt, &u.LockedUntil); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -308,20 +352,17 @@ func (s *PostgresUserStore) GetAllUsers() ([]*User, error) {
 
 func (s *PostgresUserStore) ToggleUserStatus(id int64) error {
 	query := `
-	UPDATE users
-	SET is_active = NOT is_active
-	WHERE id = $1 AND deleted_at IS NULL
+	WITH toggled AS (
+		UPDATE users
+		SET is_active = NOT is_active
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, is_active
+	), revoked AS (
+		DELETE FROM tokens
+		WHERE scope = $2 AND user_id IN (SELECT id FROM toggled WHERE NOT is_active)
+	)
+	SELECT id FROM toggled
 	`
-	result, err := s.db.Exec(query, id)
-	if err != nil {
-		return err
-	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-	return nil
+	var toggled int64
+	return s.db.QueryRow(query, id, tokens.ScopeAuth).Scan(&toggled)
 }
\ No newline at end of file
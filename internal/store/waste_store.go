@@ -0,0 +1,166 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Reasons goods are wasted.
+const (
+	WasteExpired = "expired"
+	WasteBroken  = "broken"
+	WasteTasting = "tasting"
+	WasteOther   = "other"
+)
+
+// How a waste record was valued.
+const (
+	CostBasisRecipe = "recipe"
+	CostBasisPrice  = "price"
+)
+
+type WasteRecord struct {
+	ID          int64     `json:"id"`
+	ProductID   int64     `json:"product_id"`
+	ProductName string    `json:"product_name"`
+	SaleUnit    string    `json:"sale_unit"`
+	Quantity    float64   `json:"quantity"`
+	Reason      string    `json:"reason"`
+	Note        string    `json:"note"`
+	UnitCost    float64   `json:"unit_cost"`
+	CostBasis   string    `json:"cost_basis"`
+	Value       float64   `json:"value"`
+	UserID      *int64    `json:"user_id"`
+	Username    string    `json:"username,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// WasteByProduct totals the waste of one product over a period.
+type WasteByProduct struct {
+	ProductID   int64   `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	SaleUnit    string  `json:"sale_unit"`
+	Quantity    float64 `json:"quantity"`
+	Value       float64 `json:"value"`
+	Records     int     `json:"records"`
+}
+
+// WasteByWeek totals the waste of a week, starting on Monday.
+type WasteByWeek struct {
+	WeekStart time.Time `json:"week_start"`
+	Quantity  float64   `json:"quantity"`
+	Value     float64   `json:"value"`
+	Records   int       `json:"records"`
+}
+
+type WasteStore interface {
+	CreateTx(tx *sql.Tx, record *WasteRecord) error
+	// List returns the records in [from, to), newest first. productID 0
+	// means every product.
+	List(from, to time.Time, productID int64) ([]*WasteRecord, error)
+	ReportByProduct(from, to time.Time) ([]*WasteByProduct, error)
+	// ReportByWeek groups by week; quantities only add up when productID is
+	// set, since units differ between products.
+	ReportByWeek(from, to time.Time, productID int64) ([]*WasteByWeek, error)
+}
+
+type PostgresWasteStore struct {
+	db *sql.DB
+}
+
+func NewPostgresWasteStore(db *sql.DB) *PostgresWasteStore {
+	return &PostgresWasteStore{db: db}
+}
+
+func (s *PostgresWasteStore) CreateTx(tx *sql.Tx, record *WasteRecord) error {
+	query := `
+	INSERT INTO waste_records (product_id, quantity, reason, note, unit_cost, cost_basis, value, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id, created_at`
+
+	var userID int64
+	if record.UserID != nil {
+		userID = *record.UserID
+	}
+	return tx.QueryRow(query, record.ProductID, record.Quantity, record.Reason, record.Note,
+		record.UnitCost, record.CostBasis, record.Value, nullInt64(userID)).Scan(&record.ID, &record.CreatedAt)
+}
+
+func (s *PostgresWasteStore) List(from, to time.Time, productID int64) ([]*WasteRecord, error) {
+	query := `
+	SELECT w.id, w.product_id, p.name, p.sale_unit, w.quantity, w.reason, w.note, w.unit_cost, w.cost_basis,
+	       w.value, w.user_id, COALESCE(u.username, ''), w.created_at
+	FROM waste_records w
+	JOIN products p ON p.id = w.product_id
+	LEFT JOIN users u ON u.id = w.user_id
+	WHERE w.created_at >= $1 AND w.created_at < $2 AND ($3::BIGINT = 0 OR w.product_id = $3)
+	ORDER BY w.created_at DESC, w.id DESC`
+
+	rows, err := s.db.Query(query, from, to, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*WasteRecord
+	for rows.Next() {
+		var w WasteRecord
+		if err := rows.Scan(&w.ID, &w.ProductID, &w.ProductName, &w.SaleUnit, &w.Quantity, &w.Reason, &w.Note,
+			&w.UnitCost, &w.CostBasis, &w.Value, &w.UserID, &w.Username, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, &w)
+	}
+	return records, rows.Err()
+}
+
+func (s *PostgresWasteStore) ReportByProduct(from, to time.Time) ([]*WasteByProduct, error) {
+	query := `
+	SELECT w.product_id, p.name, p.sale_unit, SUM(w.quantity), SUM(w.value), COUNT(*)
+	FROM waste_records w
+	JOIN products p ON p.id = w.product_id
+	WHERE w.created_at >= $1 AND w.created_at < $2
+	GROUP BY w.product_id, p.name, p.sale_unit
+	ORDER BY SUM(w.value) DESC, p.name`
+
+	rows, err := s.db.Query(query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []*WasteByProduct
+	for rows.Next() {
+		var r WasteByProduct
+		if err := rows.Scan(&r.ProductID, &r.ProductName, &r.SaleUnit, &r.Quantity, &r.Value, &r.Records); err != nil {
+			return nil, err
+		}
+		report = append(report, &r)
+	}
+	return report, rows.Err()
+}
+
+func (s *PostgresWasteStore) ReportByWeek(from, to time.Time, productID int64) ([]*WasteByWeek, error) {
+	query := `
+	SELECT date_trunc('week', w.created_at), SUM(w.quantity), SUM(w.value), COUNT(*)
+	FROM waste_records w
+	WHERE w.created_at >= $1 AND w.created_at < $2 AND ($3::BIGINT = 0 OR w.product_id = $3)
+	GROUP BY 1
+	ORDER BY 1`
+
+	rows, err := s.db.Query(query, from, to, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []*WasteByWeek
+	for rows.Next() {
+		var r WasteByWeek
+		if err := rows.Scan(&r.WeekStart, &r.Quantity, &r.Value, &r.Records); err != nil {
+			return nil, err
+		}
+		report = append(report, &r)
+	}
+	return report, rows.Err()
+}
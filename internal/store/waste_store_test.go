@@ -0,0 +1,67 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWasteStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	s := NewPostgresWasteStore(db)
+	prod := setupProductForStockTest(t, db)
+
+	create := func(qty, value float64, reason string) *WasteRecord {
+		t.Helper()
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		record := &WasteRecord{ProductID: prod.ID, Quantity: qty, Reason: reason, UnitCost: 10, CostBasis: CostBasisPrice, Value: value}
+		require.NoError(t, s.CreateTx(tx, record))
+		require.NoError(t, tx.Commit())
+		return record
+	}
+
+	first := create(2, 20, WasteExpired)
+	second := create(1, 10, WasteBroken)
+	assert.NotZero(t, first.ID)
+	assert.False(t, first.CreatedAt.IsZero())
+
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	records, err := s.List(from, to, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, second.ID, records[0].ID)
+	assert.Equal(t, prod.Name, records[0].ProductName)
+
+	records, err = s.List(from, to, prod.ID+1)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	byProduct, err := s.ReportByProduct(from, to)
+	require.NoError(t, err)
+	require.Len(t, byProduct, 1)
+	assert.Equal(t, 3.0, byProduct[0].Quantity)
+	assert.Equal(t, 30.0, byProduct[0].Value)
+	assert.Equal(t, 2, byProduct[0].Records)
+
+	byWeek, err := s.ReportByWeek(from, to, prod.ID)
+	require.NoError(t, err)
+	require.Len(t, byWeek, 1)
+	assert.Equal(t, 3.0, byWeek[0].Quantity)
+	assert.Equal(t, time.Monday, byWeek[0].WeekStart.Weekday())
+
+	t.Run("invalid reason", func(t *testing.T) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+		err = s.CreateTx(tx, &WasteRecord{ProductID: prod.ID, Quantity: 1, Reason: "lost", CostBasis: CostBasisPrice})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,423 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Webhook events.
+const (
+	WebhookOrderCreated      = "order.created"
+	WebhookOrderStateChanged = "order.state_changed"
+	WebhookLocalSaleCreated  = "local_sale.created"
+	// WebhookPing is only sent from the test button, whatever the events of
+	// the webhook.
+	WebhookPing = "ping"
+)
+
+// WebhookEventInfo describes an event for the webhook screens.
+type WebhookEventInfo struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// WebhookEventCatalog lists the events a webhook can get.
+var WebhookEventCatalog = []WebhookEventInfo{
+	{WebhookOrderCreated, "Pedido creado"},
+	{WebhookOrderStateChanged, "Pedido cambió de estado"},
+	{WebhookLocalSaleCreated, "Venta del local registrada"},
+}
+
+// IsWebhookEvent reports whether key is in WebhookEventCatalog.
+func IsWebhookEvent(key string) bool {
+	for _, e := range WebhookEventCatalog {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook delivery statuses.
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	// WebhookFailed deliveries ran out of attempts.
+	WebhookFailed = "failed"
+)
+
+// Webhook is a URL business events are POSTed to, signed with its secret.
+type Webhook struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Subscribed reports whether the webhook gets event.
+func (w *Webhook) Subscribed(event string) bool {
+	return slices.Contains(w.Events, event)
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a webhook, with the
+// result of its last attempt.
+type WebhookDelivery struct {
+	ID        int64  `json:"id"`
+	WebhookID int64  `json:"webhook_id"`
+	EventID   string `json:"event_id"`
+	Event     string `json:"event"`
+	// Payload is the JSON body sent.
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	ResponseBody   string          `json:"response_body"`
+	LastError      string          `json:"last_error"`
+	DurationMS     *int            `json:"duration_ms"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+
+	// URL and Secret of the webhook, only set on claimed deliveries.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type WebhookDeliveryFilter struct {
+	WebhookID *int64
+	Status    string
+	Limit     int
+	Offset    int
+}
+
+type WebhookStore interface {
+	Create(w *Webhook) error
+	// Update saves everything but the creation date. sql.ErrNoRows when
+	// there is no such webhook.
+	Update(w *Webhook) error
+	// Delete removes the webhook with its deliveries. sql.ErrNoRows when
+	// there is no such webhook.
+	Delete(id int64) error
+	// GetByID returns nil when there is no such webhook.
+	GetByID(id int64) (*Webhook, error)
+	List() ([]*Webhook, error)
+
+	// Enqueue queues a delivery of a new event with data to every active
+	// webhook that gets it, returning how many.
+	Enqueue(event string, data any) (int, error)
+	// InsertDelivery queues d as is, whatever the webhook's events.
+	InsertDelivery(d *WebhookDelivery) error
+	// ClaimDeliveries takes up to limit pending deliveries of active webhooks
+	// that are due, counting an attempt and leaving them alone for lease so
+	// other workers skip them. When the worker dies they are retried after
+	// lease.
+	ClaimDeliveries(limit int, lease time.Duration) ([]*WebhookDelivery, error)
+	// FinishDelivery saves the result of an attempt: status, next attempt and
+	// response.
+	FinishDelivery(d *WebhookDelivery) error
+	// ReleaseDeliveries gives claimed deliveries back without counting their
+	// attempt, due right away; used when an attempt was cut short.
+	ReleaseDeliveries(ids []int64) error
+	// Redeliver queues a new delivery of the same event and payload. nil when
+	// there is no such delivery.
+	Redeliver(id int64) (*WebhookDelivery, error)
+	// GetDelivery returns nil when there is no such delivery.
+	GetDelivery(id int64) (*WebhookDelivery, error)
+	// ListDeliveries returns the deliveries matching the filter newest first,
+	// with the total number of matches.
+	ListDeliveries(f WebhookDeliveryFilter) ([]*WebhookDelivery, int, error)
+}
+
+type PostgresWebhookStore struct {
+	db *sql.DB
+}
+
+func NewPostgresWebhookStore(db *sql.DB) *PostgresWebhookStore {
+	return &PostgresWebhookStore{db: db}
+}
+
+func (s *PostgresWebhookStore) Create(w *Webhook) error {
+	return s.db.QueryRow(`
+	INSERT INTO webhooks (name, url, secret, events, is_active)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, updated_at`,
+		w.Name, w.URL, w.Secret, strings.Join(w.Events, ","), w.IsActive).
+		Scan(&w.ID, &w.CreatedAt, &w.UpdatedAt)
+}
+
+func (s *PostgresWebhookStore) Update(w *Webhook) error {
+	err := s.db.QueryRow(`
+	UPDATE webhooks SET name = $2, url = $3, secret = $4, events = $5, is_active = $6, updated_at = NOW()
+	WHERE id = $1
+	RETURNING updated_at`,
+		w.ID, w.Name, w.URL, w.Secret, strings.Join(w.Events, ","), w.IsActive).
+		Scan(&w.UpdatedAt)
+	return err
+}
+
+func (s *PostgresWebhookStore) Delete(id int64) error {
+	return expectOneRow(s.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id))
+}
+
+const webhookQuery = `
+	SELECT id, name, url, secret, events, is_active, created_at, updated_at
+	FROM webhooks`
+
+func scanWebhook(row interface{ Scan(...any) error }) (*Webhook, error) {
+	w := &Webhook{}
+	var events string
+	if err := row.Scan(&w.ID, &w.Name, &w.URL, &w.Secret, &events, &w.IsActive, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	w.Events = splitPermissions(events)
+	return w, nil
+}
+
+func (s *PostgresWebhookStore) GetByID(id int64) (*Webhook, error) {
+	w, err := scanWebhook(s.db.QueryRow(webhookQuery+` WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return w, err
+}
+
+func (s *PostgresWebhookStore) List() ([]*Webhook, error) {
+	rows, err := s.db.Query(webhookQuery + ` ORDER BY name, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, w)
+	}
+	return hooks, rows.Err()
+}
+
+func (s *PostgresWebhookStore) Enqueue(event string, data any) (int, error) {
+	return enqueueWebhookEvent(s.db, event, data)
+}
+
+// WebhookPayload is the body of every webhook request.
+type WebhookPayload struct {
+	// ID is the event's, the same on every delivery and retry of it.
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// NewWebhookPayload returns the ID of a new event and its body.
+func NewWebhookPayload(event string, data any) (string, []byte, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	id := "evt_" + hex.EncodeToString(b)
+
+	body, err := json.Marshal(WebhookPayload{ID: id, Event: event, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		return "", nil, fmt.Errorf("error marshaling webhook payload: %w", err)
+	}
+	return id, body, nil
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// enqueueWebhookEvent queues a new event for the webhooks with q, the
+// transaction of the change it tells about, so it is sent if and only if
+// the change commits.
+func enqueueWebhookEvent(q execer, event string, data any) (int, error) {
+	id, payload, err := NewWebhookPayload(event, data)
+	if err != nil {
+		return 0, err
+	}
+	res, err := q.Exec(`
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+	SELECT id, $1, $2, $3 FROM webhooks
+	WHERE is_active AND $2 = ANY(string_to_array(events, ','))`,
+		id, event, payload)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *PostgresWebhookStore) InsertDelivery(d *WebhookDelivery) error {
+	return s.db.QueryRow(`
+	INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, attempts, next_attempt_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at`,
+		d.WebhookID, d.EventID, d.Event, []byte(d.Payload), d.Status, d.Attempts, d.NextAttemptAt).
+		Scan(&d.ID, &d.CreatedAt)
+}
+
+const deliveryColumns = `
+	d.id, d.webhook_id, d.event_id, d.event, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.response_status, d.response_body, d.last_error,
+	d.duration_ms, d.created_at, d.delivered_at`
+
+func scanDelivery(row interface{ Scan(...any) error }, extra ...any) (*WebhookDelivery, error) {
+	d := &WebhookDelivery{}
+	var payload []byte
+	var status, duration sql.NullInt64
+	dest := []any{&d.ID, &d.WebhookID, &d.EventID, &d.Event, &payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &status, &d.ResponseBody, &d.LastError,
+		&duration, &d.CreatedAt, &d.DeliveredAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	d.Payload = payload
+	if status.Valid {
+		v := int(status.Int64)
+		d.ResponseStatus = &v
+	}
+	if duration.Valid {
+		v := int(duration.Int64)
+		d.DurationMS = &v
+	}
+	return d, nil
+}
+
+func (s *PostgresWebhookStore) ClaimDeliveries(limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	rows, err := s.db.Query(`
+	UPDATE webhook_deliveries d
+	SET attempts = d.attempts + 1, next_attempt_at = NOW() + $2::int * INTERVAL '1 second'
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT q.id FROM webhook_deliveries q
+		INNER JOIN webhooks qw ON qw.id = q.webhook_id
+		WHERE q.status = 'pending' AND q.next_attempt_at <= NOW() AND qw.is_active
+		ORDER BY q.next_attempt_at, q.id
+		LIMIT $1
+		FOR UPDATE OF q SKIP LOCKED
+	)
+	RETURNING `+deliveryColumns+`, w.url, w.secret`,
+		limit, int(lease.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*WebhookDelivery
+	for rows.Next() {
+		var url, secret string
+		d, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		d.URL, d.Secret = url, secret
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+func (s *PostgresWebhookStore) FinishDelivery(d *WebhookDelivery) error {
+	return expectOneRow(s.db.Exec(`
+	UPDATE webhook_deliveries
+	SET status = $2, next_attempt_at = $3, response_status = $4, response_body = $5,
+	    last_error = $6, duration_ms = $7, delivered_at = $8
+	WHERE id = $1`,
+		d.ID, d.Status, d.NextAttemptAt, nullInt(d.ResponseStatus), d.ResponseBody,
+		d.LastError, nullInt(d.DurationMS), d.DeliveredAt))
+}
+
+func (s *PostgresWebhookStore) ReleaseDeliveries(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := s.db.Exec(`
+	UPDATE webhook_deliveries
+	SET attempts = GREATEST(attempts - 1, 0), next_attempt_at = NOW()
+	WHERE id = ANY($1) AND status = 'pending'`, ids)
+	return err
+}
+
+func nullInt(v *int) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*v), Valid: true}
+}
+
+func (s *PostgresWebhookStore) Redeliver(id int64) (*WebhookDelivery, error) {
+	d, err := scanDelivery(s.db.QueryRow(`
+	INSERT INTO webhook_deliveries AS d (webhook_id, event_id, event, payload)
+	SELECT webhook_id, event_id, event, payload FROM webhook_deliveries WHERE id = $1
+	RETURNING `+deliveryColumns, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+func (s *PostgresWebhookStore) GetDelivery(id int64) (*WebhookDelivery, error) {
+	d, err := scanDelivery(s.db.QueryRow(`SELECT `+deliveryColumns+` FROM webhook_deliveries d WHERE d.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+func (s *PostgresWebhookStore) ListDeliveries(f WebhookDeliveryFilter) ([]*WebhookDelivery, int, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+
+	where := "WHERE TRUE"
+	args := []any{}
+	if f.WebhookID != nil {
+		where += fmt.Sprintf(" AND d.webhook_id=$%d", len(args)+1)
+		args = append(args, *f.WebhookID)
+	}
+	if f.Status != "" {
+		where += fmt.Sprintf(" AND d.status=$%d", len(args)+1)
+		args = append(args, f.Status)
+	}
+
+	var total int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries d `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	q := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d ` + where +
+		fmt.Sprintf(" ORDER BY d.created_at DESC, d.id DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	args = append(args, f.Limit, f.Offset)
+
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var out []*WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, d)
+	}
+	return out, total, rows.Err()
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookStore(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	s := NewPostgresWebhookStore(db)
+
+	orders := &Webhook{Name: "pedidos", URL: "http://localhost/orders", Secret: "s1",
+		Events: []string{WebhookOrderCreated, WebhookOrderStateChanged}, IsActive: true}
+	sales := &Webhook{Name: "ventas", URL: "http://localhost/sales", Secret: "s2",
+		Events: []string{WebhookLocalSaleCreated}, IsActive: true}
+	paused := &Webhook{Name: "pausado", URL: "http://localhost/paused", Secret: "s3",
+		Events: []string{WebhookOrderCreated}, IsActive: false}
+	for _, w := range []*Webhook{orders, sales, paused} {
+		require.NoError(t, s.Create(w))
+	}
+
+	got, err := s.GetByID(orders.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, []string{WebhookOrderCreated, WebhookOrderStateChanged}, got.Events)
+	assert.Equal(t, "s1", got.Secret)
+	assert.True(t, got.Subscribed(WebhookOrderCreated))
+	assert.False(t, got.Subscribed(WebhookLocalSaleCreated))
+
+	// Only active webhooks with the event get a delivery.
+	n, err := s.Enqueue(WebhookOrderCreated, map[string]any{"id": 7})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	claimed, err := s.ClaimDeliveries(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	d := claimed[0]
+	assert.Equal(t, orders.ID, d.WebhookID)
+	assert.True(t, strings.HasPrefix(d.EventID, "evt_"))
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, "http://localhost/orders", d.URL)
+	assert.Equal(t, "s1", d.Secret)
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(d.Payload, &payload))
+	assert.Equal(t, d.EventID, payload.ID)
+	assert.Equal(t, WebhookOrderCreated, payload.Event)
+	assert.Equal(t, map[string]any{"id": 7.0}, payload.Data)
+
+	// Leased: nobody else takes it until the attempt is over.
+	again, err := s.ClaimDeliveries(10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+
+	status := 500
+	d.Status = WebhookPending
+	d.ResponseStatus = &status
+	d.LastError = "respuesta 500"
+	d.NextAttemptAt = time.Now().Add(-time.Second)
+	require.NoError(t, s.FinishDelivery(d))
+
+	again, err = s.ClaimDeliveries(10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	assert.Equal(t, 2, again[0].Attempts)
+
+	status = 200
+	now := time.Now()
+	d = again[0]
+	d.Status = WebhookDelivered
+	d.ResponseStatus = &status
+	d.LastError = ""
+	d.DeliveredAt = &now
+	require.NoError(t, s.FinishDelivery(d))
+
+	stored, err := s.GetDelivery(d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, WebhookDelivered, stored.Status)
+	require.NotNil(t, stored.ResponseStatus)
+	assert.Equal(t, 200, *stored.ResponseStatus)
+	assert.NotNil(t, stored.DeliveredAt)
+
+	// A redelivery is a new pending delivery of the same event.
+	re, err := s.Redeliver(d.ID)
+	require.NoError(t, err)
+	require.NotNil(t, re)
+	assert.NotEqual(t, d.ID, re.ID)
+	assert.Equal(t, d.EventID, re.EventID)
+	assert.Equal(t, WebhookPending, re.Status)
+	assert.Zero(t, re.Attempts)
+
+	missing, err := s.Redeliver(999999)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	list, total, err := s.ListDeliveries(WebhookDeliveryFilter{WebhookID: &orders.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, re.ID, list[0].ID)
+
+	_, total, err = s.ListDeliveries(WebhookDeliveryFilter{WebhookID: &orders.ID, Status: WebhookDelivered})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+
+	// Deleting the webhook takes its deliveries with it.
+	require.NoError(t, s.Delete(orders.ID))
+	assert.ErrorIs(t, s.Delete(orders.ID), sql.ErrNoRows)
+	_, total, err = s.ListDeliveries(WebhookDeliveryFilter{})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestOrderWebhooksQueuedWithTheChange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	s := NewPostgresWebhookStore(db)
+	orderStore := NewPostgresOrderStore(db)
+
+	hook := &Webhook{Name: "pedidos", URL: "http://localhost/orders", Secret: "s1", IsActive: true,
+		Events: []string{WebhookOrderCreated, WebhookOrderStateChanged}}
+	require.NoError(t, s.Create(hook))
+
+	client := &Client{Name: "Cliente", Type: ClientTypeIndividual, Reference: "ref-hook", CUIT: "cuit-hook"}
+	require.NoError(t, NewPostgresClientStore(db).CreateClient(client))
+	category := &Category{Name: "Categoria"}
+	require.NoError(t, NewPostgresCategoryStore(db).CreateCategory(category))
+	product := &Product{CategoryID: category.ID, Name: "Producto", UnitPrice: 10.0, DistributionPrice: 8.0}
+	require.NoError(t, NewPostgresProductStore(db).CreateProduct(product))
+
+	order := &Order{ClientID: client.ID, State: OrderTodo}
+	require.NoError(t, orderStore.CreateOrder(order, []OrderItem{{ProductID: product.ID, Quantity: 1, Price: "10"}}))
+	require.NoError(t, orderStore.UpdateOrderState(order.ID, OrderDone, nil))
+	assert.ErrorIs(t, orderStore.UpdateOrderState(999999, OrderDone, nil), sql.ErrNoRows)
+
+	deliveries, total, err := s.ListDeliveries(WebhookDeliveryFilter{WebhookID: &hook.ID})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+
+	var changed WebhookPayload
+	require.NoError(t, json.Unmarshal(deliveries[0].Payload, &changed))
+	assert.Equal(t, WebhookOrderStateChanged, changed.Event)
+	data := changed.Data.(map[string]any)
+	assert.Equal(t, string(OrderTodo), data["previous_state"])
+	assert.Equal(t, string(OrderDone), data["order"].(map[string]any)["state"])
+	assert.Equal(t, WebhookOrderCreated, deliveries[1].Event)
+}
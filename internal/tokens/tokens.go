@@ -4,12 +4,20 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base32"
+	"encoding/hex"
+	"strings"
 	"time"
 )
 
 const (
 	ScopeAuth          = "authentication"
 	ScopePasswordReset = "password-reset"
+	// ScopeTwoFactor is held between a correct password and the second
+	// factor; it only lets the user finish logging in.
+	ScopeTwoFactor = "two-factor"
+	// ScopePOSOperator is held by a device whose POS is being used by an
+	// employee who entered their PIN.
+	ScopePOSOperator = "pos-operator"
 )
 
 type Token struct {
@@ -18,6 +26,41 @@ type Token struct {
 	UserID    int       `json:"-"`
 	Expiry    time.Time `json:"expiry"`
 	Scope     string    `json:"-"`
+	// UserAgent and IP identify the device an authentication token was
+	// issued to.
+	UserAgent string `json:"-"`
+	IP        string `json:"-"`
+}
+
+// APIKeyPrefix starts every API key, telling them apart from user tokens.
+const APIKeyPrefix = "aesk_"
+
+// GenerateAPIKey returns a new API key and its hash.
+func GenerateAPIKey() (string, []byte, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	key := APIKeyPrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return key, Hash(key), nil
+}
+
+// WebhookSecretPrefix starts the generated webhook secrets.
+const WebhookSecretPrefix = "whsec_"
+
+// GenerateWebhookSecret returns a new secret to sign webhook payloads with.
+func GenerateWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return WebhookSecretPrefix + hex.EncodeToString(b), nil
+}
+
+// Hash is how tokens and API keys are stored.
+func Hash(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
 }
 
 func GenerateToken(userID int, ttl time.Duration, scope string) (*Token, error) {
@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAt_RFC6238(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := CodeAt(rfcSecret, Counter(time.Unix(tt.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "at %d", tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	counter := Counter(now)
+
+	c, ok := Validate(rfcSecret, "081804", now)
+	assert.True(t, ok)
+	assert.Equal(t, counter, c)
+
+	// The previous and next steps are accepted for clock drift.
+	c, ok = Validate(rfcSecret, "081804", now.Add(Period))
+	assert.True(t, ok)
+	assert.Equal(t, counter, c)
+	_, ok = Validate(rfcSecret, "081804", now.Add(3*Period))
+	assert.False(t, ok)
+
+	_, ok = Validate(rfcSecret, " 081 804 ", now)
+	assert.True(t, ok)
+	_, ok = Validate(rfcSecret, "000000", now)
+	assert.False(t, ok)
+	_, ok = Validate(rfcSecret, "0818", now)
+	assert.False(t, ok)
+	_, ok = Validate("not base32!", "081804", now)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecretAndURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	code, err := CodeAt(secret, Counter(time.Now()))
+	require.NoError(t, err)
+	_, ok := Validate(secret, code, time.Now())
+	assert.True(t, ok)
+
+	uri := URI("A Eso Voy", "admin", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/A%20Eso%20Voy:admin?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=A+Eso+Voy")
+}
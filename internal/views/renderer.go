@@ -125,6 +125,12 @@ func NewRenderer() *Renderer {
 				}
 				return *f
 			},
+			"derefInt64": func(i *int64) int64 {
+				if i == nil {
+					return 0
+				}
+				return *i
+			},
 			"defaultNA": func(s string) string {
 				if strings.TrimSpace(s) == "" {
 					return "N/A"
@@ -137,7 +143,7 @@ func NewRenderer() *Renderer {
 
 func (r *Renderer) Render(w io.Writer, page string, data any) error {
 	// We need to name the template to use ParseFS with Funcs properly
-	tmpl, err := template.New("base.html").Funcs(r.funcMap).ParseFS(fs, "templates/base.html", "templates/user_row.html", "templates/quick_category_modal.html", "templates/"+page)
+	tmpl, err := template.New("base.html").Funcs(r.funcMap).ParseFS(fs, "templates/base.html", "templates/user_row.html", "templates/quick_category_modal.html", "templates/pos_operator.html", "templates/"+page)
 	if err != nil {
 		return err
 	}
@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/RamunnoAJ/aesovoy-server/internal/app"
 	"github.com/RamunnoAJ/aesovoy-server/internal/routes"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -21,14 +26,15 @@ import (
 // @description "Type 'Bearer' followed by a space and then your token."
 func main() {
 	var port int
+	var shutdownTimeout time.Duration
 	flag.IntVar(&port, "port", 8080, "go backend server port")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 30*time.Second, "time to drain requests and jobs on shutdown")
 	flag.Parse()
 
 	app, err := app.NewApplication()
 	if err != nil {
 		panic(err)
 	}
-	defer app.DB.Close()
 
 	r := routes.SetupRoutes(app)
 	server := &http.Server{
@@ -39,10 +45,33 @@ func main() {
 		WriteTimeout: 30 * time.Second,
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
 	app.Logger.Info(fmt.Sprintf("we are running on port %d", port))
 
-	err = server.ListenAndServe()
-	if err != nil {
+	select {
+	case err := <-serverErr:
 		app.Logger.Error(fmt.Sprintf("%v", err))
+	case <-ctx.Done():
+		app.Logger.Info("shutting down", "timeout", shutdownTimeout.String())
+	}
+	// A second signal kills the process right away.
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		app.Logger.Error("shutting down server", "error", err)
+	}
+	if err := app.Shutdown(shutdownCtx); err != nil {
+		app.Logger.Error("shutting down application", "error", err)
 	}
+	app.Logger.Info("server stopped")
 }
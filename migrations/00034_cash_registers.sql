@@ -0,0 +1,72 @@
+-- +goose Up
+CREATE TABLE cash_registers (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO cash_registers (name) VALUES ('Caja Principal');
+
+-- Shifts become register sessions: opened against a drawer, held by whoever is on duty.
+ALTER TABLE shifts
+    ADD COLUMN register_id INT REFERENCES cash_registers(id),
+    ADD COLUMN current_user_id INT REFERENCES users(id),
+    ADD COLUMN closed_by INT REFERENCES users(id);
+
+UPDATE shifts
+SET register_id = (SELECT id FROM cash_registers WHERE name = 'Caja Principal'),
+    current_user_id = user_id,
+    closed_by = CASE WHEN status = 'closed' THEN user_id END;
+
+-- Only one open session per drawer; older open shifts from other users are closed.
+UPDATE shifts
+SET status = 'closed',
+    end_time = NOW(),
+    closed_by = user_id,
+    notes = COALESCE(notes, '') || E'\nCerrado automáticamente al migrar a cajas'
+WHERE status = 'open'
+  AND id <> (SELECT id FROM shifts WHERE status = 'open' ORDER BY start_time DESC LIMIT 1);
+
+ALTER TABLE shifts
+    ALTER COLUMN register_id SET NOT NULL,
+    ALTER COLUMN current_user_id SET NOT NULL;
+
+CREATE INDEX idx_shifts_register_id ON shifts(register_id);
+CREATE UNIQUE INDEX idx_shifts_one_open_per_register ON shifts(register_id) WHERE status = 'open';
+
+CREATE TABLE shift_handovers (
+    id SERIAL PRIMARY KEY,
+    shift_id INT NOT NULL REFERENCES shifts(id),
+    from_user_id INT NOT NULL REFERENCES users(id),
+    to_user_id INT NOT NULL REFERENCES users(id),
+    counted_cash NUMERIC(15, 2),
+    notes TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_shift_handovers_shift_id ON shift_handovers(shift_id);
+
+ALTER TABLE cash_movements ADD COLUMN user_id INT REFERENCES users(id);
+
+UPDATE cash_movements cm
+SET user_id = s.user_id
+FROM shifts s
+WHERE cm.shift_id = s.id;
+
+ALTER TABLE local_sales ADD COLUMN shift_id INT REFERENCES shifts(id);
+CREATE INDEX idx_local_sales_shift_id ON local_sales(shift_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_local_sales_shift_id;
+ALTER TABLE local_sales DROP COLUMN IF EXISTS shift_id;
+ALTER TABLE cash_movements DROP COLUMN IF EXISTS user_id;
+DROP TABLE IF EXISTS shift_handovers;
+DROP INDEX IF EXISTS idx_shifts_one_open_per_register;
+DROP INDEX IF EXISTS idx_shifts_register_id;
+ALTER TABLE shifts
+    DROP COLUMN IF EXISTS closed_by,
+    DROP COLUMN IF EXISTS current_user_id,
+    DROP COLUMN IF EXISTS register_id;
+DROP TABLE IF EXISTS cash_registers;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- A product can be identified at the counter by any number of codes: the
+-- manufacturer's EAN/UPC, an internal PLU typed by hand, or the item code a
+-- label scale embeds in weighed EAN-13 labels.
+CREATE TABLE product_barcodes (
+    id SERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL,
+    code VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_product
+        FOREIGN KEY(product_id)
+        REFERENCES products(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_barcodes_product_id ON product_barcodes(product_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS product_barcodes;
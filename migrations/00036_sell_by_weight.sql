@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Products sold by weight keep their price per kilogram in unit_price and
+-- their counter stock and sale quantities in the sale unit.
+ALTER TABLE products ADD COLUMN sale_unit VARCHAR(10) NOT NULL DEFAULT 'unit'
+    CHECK (sale_unit IN ('unit', 'kg', 'g'));
+
+ALTER TABLE local_stock ALTER COLUMN quantity TYPE NUMERIC(12, 3);
+ALTER TABLE local_sale_items ALTER COLUMN quantity TYPE NUMERIC(12, 3);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE local_sale_items ALTER COLUMN quantity TYPE INT USING ROUND(quantity);
+ALTER TABLE local_stock ALTER COLUMN quantity TYPE INT USING ROUND(quantity);
+
+ALTER TABLE products DROP COLUMN IF EXISTS sale_unit;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Counter stock can't go below zero unless the product explicitly allows it
+-- (e.g. items produced to order). Enforced in the database so concurrent
+-- sales can't oversell even if a caller skips the application checks.
+ALTER TABLE products ADD COLUMN allow_negative_stock BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Existing negative balances are kept by allowing them on those products.
+UPDATE products SET allow_negative_stock = TRUE
+WHERE id IN (SELECT product_id FROM local_stock WHERE quantity < 0);
+
+CREATE OR REPLACE FUNCTION local_stock_check_non_negative() RETURNS trigger AS $$
+BEGIN
+  IF NEW.quantity < 0 AND NOT EXISTS (
+    SELECT 1 FROM products WHERE id = NEW.product_id AND allow_negative_stock
+  ) THEN
+    RAISE EXCEPTION 'stock of product % would be negative (%)', NEW.product_id, NEW.quantity
+      USING ERRCODE = 'check_violation', CONSTRAINT = 'local_stock_non_negative';
+  END IF;
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_local_stock_non_negative
+  BEFORE INSERT OR UPDATE OF quantity
+  ON local_stock
+  FOR EACH ROW
+  EXECUTE FUNCTION local_stock_check_non_negative();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS trg_local_stock_non_negative ON local_stock;
+DROP FUNCTION IF EXISTS local_stock_check_non_negative();
+ALTER TABLE products DROP COLUMN IF EXISTS allow_negative_stock;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Ledger of every change to local_stock. balance is the product's stock right
+-- after the movement, so per-product history reads as a running balance.
+CREATE TABLE stock_movements (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    delta NUMERIC(12, 3) NOT NULL,
+    balance NUMERIC(12, 3) NOT NULL,
+    reason VARCHAR(20) NOT NULL
+        CHECK (reason IN ('initial', 'sale', 'void', 'adjustment', 'production', 'waste', 'transfer')),
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    reference_type VARCHAR(30),
+    reference_id BIGINT,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_stock_movements_product_created ON stock_movements(product_id, created_at, id);
+CREATE INDEX idx_stock_movements_reference ON stock_movements(reference_type, reference_id);
+
+-- Stock that existed before the ledger is recorded as an opening balance.
+INSERT INTO stock_movements (product_id, delta, balance, reason, note)
+SELECT product_id, quantity, quantity, 'initial', 'Saldo al habilitar el registro de movimientos'
+FROM local_stock;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_movements;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE stock_movements DROP CONSTRAINT stock_movements_reason_check;
+ALTER TABLE stock_movements ADD CONSTRAINT stock_movements_reason_check
+    CHECK (reason IN ('initial', 'sale', 'void', 'adjustment', 'production', 'waste', 'transfer', 'count'));
+
+-- A physical stock count. Items snapshot the expected quantity of every
+-- product when the count starts; counted quantities are filled in as they
+-- are entered and posted to the ledger on approval.
+CREATE TABLE inventory_counts (
+    id BIGSERIAL PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'approved', 'cancelled')),
+    notes TEXT NOT NULL DEFAULT '',
+    created_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    closed_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Only one count can be in progress at a time.
+CREATE UNIQUE INDEX idx_inventory_counts_one_open ON inventory_counts(status) WHERE status = 'open';
+
+CREATE TABLE inventory_count_items (
+    id BIGSERIAL PRIMARY KEY,
+    count_id BIGINT NOT NULL REFERENCES inventory_counts(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    expected NUMERIC(12, 3) NOT NULL,
+    counted NUMERIC(12, 3) CHECK (counted >= 0),
+    counted_by BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    counted_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (count_id, product_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS inventory_count_items;
+DROP TABLE IF EXISTS inventory_counts;
+DELETE FROM stock_movements WHERE reason = 'count';
+ALTER TABLE stock_movements DROP CONSTRAINT stock_movements_reason_check;
+ALTER TABLE stock_movements ADD CONSTRAINT stock_movements_reason_check
+    CHECK (reason IN ('initial', 'sale', 'void', 'adjustment', 'production', 'waste', 'transfer'));
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Ingredient cost per kg, liter or unit, used to value products at recipe cost.
+ALTER TABLE ingredients ADD COLUMN cost NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (cost >= 0);
+
+-- Goods thrown away or given out. unit_cost is frozen when the waste is
+-- registered so later price or recipe changes don't rewrite past losses.
+CREATE TABLE waste_records (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity NUMERIC(12, 3) NOT NULL CHECK (quantity > 0),
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('expired', 'broken', 'tasting', 'other')),
+    note TEXT NOT NULL DEFAULT '',
+    unit_cost NUMERIC(12, 2) NOT NULL,
+    cost_basis VARCHAR(10) NOT NULL CHECK (cost_basis IN ('recipe', 'price')),
+    value NUMERIC(12, 2) NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_waste_records_created ON waste_records(created_at);
+CREATE INDEX idx_waste_records_product ON waste_records(product_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS waste_records;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS cost;
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Production batches of a product. quantity is what is left of the lot; stock
+-- produced before lots existed stays untracked in local_stock only, so the
+-- lots of a product never add up to more than its stock.
+CREATE TABLE stock_lots (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    lot_number VARCHAR(50) NOT NULL,
+    produced_on DATE NOT NULL DEFAULT CURRENT_DATE,
+    best_before DATE,
+    initial_quantity NUMERIC(12, 3) NOT NULL CHECK (initial_quantity > 0),
+    quantity NUMERIC(12, 3) NOT NULL CHECK (quantity >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, lot_number),
+    CHECK (best_before IS NULL OR best_before >= produced_on)
+);
+
+-- Lots still in stock, in the order they are consumed (FIFO).
+CREATE INDEX idx_stock_lots_fifo ON stock_lots(product_id, best_before, produced_on, id) WHERE quantity > 0;
+CREATE INDEX idx_stock_lots_best_before ON stock_lots(best_before) WHERE quantity > 0;
+
+-- How each stock movement was split across lots, for traceability.
+CREATE TABLE stock_lot_movements (
+    id BIGSERIAL PRIMARY KEY,
+    lot_id BIGINT NOT NULL REFERENCES stock_lots(id) ON DELETE CASCADE,
+    movement_id BIGINT NOT NULL REFERENCES stock_movements(id) ON DELETE CASCADE,
+    quantity NUMERIC(12, 3) NOT NULL
+);
+
+CREATE INDEX idx_stock_lot_movements_lot ON stock_lot_movements(lot_id);
+CREATE INDEX idx_stock_lot_movements_movement ON stock_lot_movements(movement_id);
+
+-- Lots an order was dispatched from, printed on its remito.
+ALTER TABLE order_products ADD COLUMN lot_numbers TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE order_products DROP COLUMN IF EXISTS lot_numbers;
+DROP TABLE IF EXISTS stock_lot_movements;
+DROP TABLE IF EXISTS stock_lots;
+-- +goose StatementEnd
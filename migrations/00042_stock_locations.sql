@@ -0,0 +1,106 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Places that hold stock (shop, production kitchen, second branch). Stock
+-- that existed before locations belongs to the default one.
+CREATE TABLE stock_locations (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (is_active OR NOT is_default)
+);
+
+CREATE UNIQUE INDEX idx_stock_locations_one_default ON stock_locations(is_default) WHERE is_default;
+
+INSERT INTO stock_locations (name, is_default) VALUES ('Local', TRUE);
+
+-- Stock is kept per product per location.
+ALTER TABLE local_stock ADD COLUMN location_id INT REFERENCES stock_locations(id);
+UPDATE local_stock SET location_id = (SELECT id FROM stock_locations WHERE is_default);
+ALTER TABLE local_stock ALTER COLUMN location_id SET NOT NULL;
+ALTER TABLE local_stock DROP CONSTRAINT local_stock_product_id_key;
+ALTER TABLE local_stock ADD CONSTRAINT local_stock_product_location_key UNIQUE (product_id, location_id);
+
+-- Movements and their running balance are per location too.
+ALTER TABLE stock_movements ADD COLUMN location_id INT REFERENCES stock_locations(id);
+UPDATE stock_movements SET location_id = (SELECT id FROM stock_locations WHERE is_default);
+ALTER TABLE stock_movements ALTER COLUMN location_id SET NOT NULL;
+DROP INDEX idx_stock_movements_product_created;
+CREATE INDEX idx_stock_movements_product_created ON stock_movements(product_id, location_id, created_at, id);
+
+-- A lot moved to another location keeps its number there.
+ALTER TABLE stock_lots ADD COLUMN location_id INT REFERENCES stock_locations(id);
+UPDATE stock_lots SET location_id = (SELECT id FROM stock_locations WHERE is_default);
+ALTER TABLE stock_lots ALTER COLUMN location_id SET NOT NULL;
+ALTER TABLE stock_lots DROP CONSTRAINT stock_lots_product_id_lot_number_key;
+ALTER TABLE stock_lots ADD CONSTRAINT stock_lots_lot_number_key UNIQUE (product_id, location_id, lot_number);
+DROP INDEX idx_stock_lots_fifo;
+CREATE INDEX idx_stock_lots_fifo ON stock_lots(product_id, location_id, best_before, produced_on, id) WHERE quantity > 0;
+
+-- Registers sell from the stock of their location; sales remember it so a
+-- void puts the goods back where they came from.
+ALTER TABLE cash_registers ADD COLUMN location_id INT REFERENCES stock_locations(id);
+UPDATE cash_registers SET location_id = (SELECT id FROM stock_locations WHERE is_default);
+ALTER TABLE cash_registers ALTER COLUMN location_id SET NOT NULL;
+
+ALTER TABLE local_sales ADD COLUMN location_id INT REFERENCES stock_locations(id);
+UPDATE local_sales SET location_id = (SELECT id FROM stock_locations WHERE is_default);
+ALTER TABLE local_sales ALTER COLUMN location_id SET NOT NULL;
+
+ALTER TABLE inventory_counts ADD COLUMN location_id INT REFERENCES stock_locations(id);
+UPDATE inventory_counts SET location_id = (SELECT id FROM stock_locations WHERE is_default);
+ALTER TABLE inventory_counts ALTER COLUMN location_id SET NOT NULL;
+
+-- Internal delivery notes (remitos internos): stock moved from one location
+-- to another in a single transaction.
+CREATE TABLE stock_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    from_location_id INT NOT NULL REFERENCES stock_locations(id),
+    to_location_id INT NOT NULL REFERENCES stock_locations(id),
+    notes TEXT NOT NULL DEFAULT '',
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (from_location_id <> to_location_id)
+);
+
+CREATE INDEX idx_stock_transfers_created ON stock_transfers(created_at);
+
+CREATE TABLE stock_transfer_items (
+    id BIGSERIAL PRIMARY KEY,
+    transfer_id BIGINT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    quantity NUMERIC(12, 3) NOT NULL CHECK (quantity > 0),
+    UNIQUE (transfer_id, product_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS stock_transfer_items;
+DROP TABLE IF EXISTS stock_transfers;
+
+ALTER TABLE inventory_counts DROP COLUMN IF EXISTS location_id;
+ALTER TABLE local_sales DROP COLUMN IF EXISTS location_id;
+ALTER TABLE cash_registers DROP COLUMN IF EXISTS location_id;
+
+DELETE FROM stock_lots WHERE location_id <> (SELECT id FROM stock_locations WHERE is_default);
+DROP INDEX IF EXISTS idx_stock_lots_fifo;
+ALTER TABLE stock_lots DROP CONSTRAINT IF EXISTS stock_lots_lot_number_key;
+ALTER TABLE stock_lots DROP COLUMN IF EXISTS location_id;
+ALTER TABLE stock_lots ADD CONSTRAINT stock_lots_product_id_lot_number_key UNIQUE (product_id, lot_number);
+CREATE INDEX idx_stock_lots_fifo ON stock_lots(product_id, best_before, produced_on, id) WHERE quantity > 0;
+
+DELETE FROM stock_movements WHERE location_id <> (SELECT id FROM stock_locations WHERE is_default);
+DROP INDEX IF EXISTS idx_stock_movements_product_created;
+ALTER TABLE stock_movements DROP COLUMN IF EXISTS location_id;
+CREATE INDEX idx_stock_movements_product_created ON stock_movements(product_id, created_at, id);
+
+DELETE FROM local_stock WHERE location_id <> (SELECT id FROM stock_locations WHERE is_default);
+ALTER TABLE local_stock DROP CONSTRAINT IF EXISTS local_stock_product_location_key;
+ALTER TABLE local_stock DROP COLUMN IF EXISTS location_id;
+ALTER TABLE local_stock ADD CONSTRAINT local_stock_product_id_key UNIQUE (product_id);
+
+DROP TABLE IF EXISTS stock_locations;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Per-product stock levels at the default location. Below min_stock the
+-- product raises a low-stock alert; production suggestions top it up to
+-- target_stock (or min_stock when no target is set).
+ALTER TABLE products ADD COLUMN min_stock NUMERIC(12, 3) CHECK (min_stock >= 0);
+ALTER TABLE products ADD COLUMN target_stock NUMERIC(12, 3) CHECK (target_stock >= 0);
+ALTER TABLE products ADD CONSTRAINT products_target_above_min
+    CHECK (target_stock IS NULL OR min_stock IS NULL OR target_stock >= min_stock);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_target_above_min;
+ALTER TABLE products DROP COLUMN IF EXISTS target_stock;
+ALTER TABLE products DROP COLUMN IF EXISTS min_stock;
+-- +goose StatementEnd
@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Ingredients on hand, in the unit their cost is expressed in (kg, liter or
+-- unit). Purchases add to it.
+ALTER TABLE ingredients ADD COLUMN stock NUMERIC(12, 3) NOT NULL DEFAULT 0;
+
+-- Goods ordered from a provider. Items keep the price agreed when ordering
+-- and how much has arrived so far.
+CREATE TABLE purchase_orders (
+    id BIGSERIAL PRIMARY KEY,
+    provider_id BIGINT NOT NULL REFERENCES providers(id),
+    state TEXT NOT NULL DEFAULT 'draft'
+        CHECK (state IN ('draft', 'sent', 'partially_received', 'received', 'cancelled')),
+    expected_date DATE,
+    notes TEXT NOT NULL DEFAULT '',
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_purchase_orders_provider ON purchase_orders(provider_id);
+CREATE INDEX idx_purchase_orders_state ON purchase_orders(state);
+
+CREATE TABLE purchase_order_items (
+    id BIGSERIAL PRIMARY KEY,
+    purchase_order_id BIGINT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    ingredient_id BIGINT NOT NULL REFERENCES ingredients(id),
+    quantity NUMERIC(12, 3) NOT NULL CHECK (quantity > 0),
+    expected_price NUMERIC(12, 2) NOT NULL CHECK (expected_price >= 0),
+    received_quantity NUMERIC(12, 3) NOT NULL DEFAULT 0 CHECK (received_quantity >= 0),
+    UNIQUE (purchase_order_id, ingredient_id)
+);
+
+-- Each delivery received against an order, with the expense it created.
+CREATE TABLE purchase_receipts (
+    id BIGSERIAL PRIMARY KEY,
+    purchase_order_id BIGINT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    expense_id INT REFERENCES expenses(id) ON DELETE SET NULL,
+    amount NUMERIC(15, 2) NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE purchase_receipt_items (
+    id BIGSERIAL PRIMARY KEY,
+    receipt_id BIGINT NOT NULL REFERENCES purchase_receipts(id) ON DELETE CASCADE,
+    item_id BIGINT NOT NULL REFERENCES purchase_order_items(id) ON DELETE CASCADE,
+    quantity NUMERIC(12, 3) NOT NULL CHECK (quantity > 0),
+    unit_price NUMERIC(12, 2) NOT NULL CHECK (unit_price >= 0)
+);
+
+-- Expenses created by receiving go here unless another category is chosen.
+INSERT INTO expense_categories (name) VALUES ('Compras') ON CONFLICT (name) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS purchase_receipt_items;
+DROP TABLE IF EXISTS purchase_receipts;
+DROP TABLE IF EXISTS purchase_order_items;
+DROP TABLE IF EXISTS purchase_orders;
+ALTER TABLE ingredients DROP COLUMN IF EXISTS stock;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Expenses become provider invoices that can be left pending until their due
+-- date. Expenses recorded so far were paid on the spot.
+ALTER TABLE expenses ADD COLUMN due_date DATE;
+ALTER TABLE expenses ADD COLUMN paid_amount NUMERIC(15, 2) NOT NULL DEFAULT 0 CHECK (paid_amount >= 0);
+UPDATE expenses SET paid_amount = amount;
+
+CREATE INDEX idx_expenses_unpaid ON expenses (COALESCE(due_date, date::date))
+    WHERE deleted_at IS NULL AND paid_amount < amount;
+
+-- A payment made to a provider with one payment method, applied to one or
+-- more of its invoices.
+CREATE TABLE provider_payments (
+    id BIGSERIAL PRIMARY KEY,
+    provider_id BIGINT NOT NULL REFERENCES providers(id),
+    payment_method_id BIGINT NOT NULL REFERENCES payment_methods(id),
+    amount NUMERIC(15, 2) NOT NULL CHECK (amount > 0),
+    date DATE NOT NULL DEFAULT CURRENT_DATE,
+    notes TEXT NOT NULL DEFAULT '',
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_provider_payments_provider ON provider_payments(provider_id, date);
+
+CREATE TABLE provider_payment_allocations (
+    id BIGSERIAL PRIMARY KEY,
+    payment_id BIGINT NOT NULL REFERENCES provider_payments(id) ON DELETE CASCADE,
+    expense_id INT NOT NULL REFERENCES expenses(id),
+    amount NUMERIC(15, 2) NOT NULL CHECK (amount > 0),
+    UNIQUE (payment_id, expense_id)
+);
+
+CREATE INDEX idx_provider_payment_allocations_expense ON provider_payment_allocations(expense_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS provider_payment_allocations;
+DROP TABLE IF EXISTS provider_payments;
+DROP INDEX IF EXISTS idx_expenses_unpaid;
+ALTER TABLE expenses DROP COLUMN IF EXISTS paid_amount;
+ALTER TABLE expenses DROP COLUMN IF EXISTS due_date;
+-- +goose StatementEnd
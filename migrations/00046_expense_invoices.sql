@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The provider invoice behind an expense: its number and type (A, B, C, M or
+-- X for non-fiscal tickets). A provider never bills the same number twice.
+ALTER TABLE expenses ADD COLUMN invoice_number TEXT NOT NULL DEFAULT '';
+ALTER TABLE expenses ADD COLUMN invoice_type TEXT NOT NULL DEFAULT ''
+    CHECK (invoice_type IN ('', 'A', 'B', 'C', 'M', 'X'));
+
+CREATE UNIQUE INDEX idx_expenses_provider_invoice ON expenses (provider_id, invoice_number)
+    WHERE invoice_number <> '' AND deleted_at IS NULL;
+
+-- What was bought: an ingredient or a free-text description.
+CREATE TABLE expense_items (
+    id BIGSERIAL PRIMARY KEY,
+    expense_id INT NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    ingredient_id BIGINT REFERENCES ingredients(id),
+    description TEXT NOT NULL DEFAULT '',
+    quantity NUMERIC(12, 3) NOT NULL CHECK (quantity > 0),
+    unit_price NUMERIC(15, 2) NOT NULL CHECK (unit_price >= 0),
+    CHECK (ingredient_id IS NOT NULL OR description <> '')
+);
+
+CREATE INDEX idx_expense_items_expense ON expense_items(expense_id);
+
+-- Net taxed amount and VAT per rate, as printed on the invoice.
+CREATE TABLE expense_vat (
+    id BIGSERIAL PRIMARY KEY,
+    expense_id INT NOT NULL REFERENCES expenses(id) ON DELETE CASCADE,
+    rate NUMERIC(5, 2) NOT NULL CHECK (rate >= 0),
+    net_amount NUMERIC(15, 2) NOT NULL CHECK (net_amount >= 0),
+    vat_amount NUMERIC(15, 2) NOT NULL CHECK (vat_amount >= 0),
+    UNIQUE (expense_id, rate)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS expense_vat;
+DROP TABLE IF EXISTS expense_items;
+DROP INDEX IF EXISTS idx_expenses_provider_invoice;
+ALTER TABLE expenses DROP COLUMN IF EXISTS invoice_type;
+ALTER TABLE expenses DROP COLUMN IF EXISTS invoice_number;
+-- +goose StatementEnd
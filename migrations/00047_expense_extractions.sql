@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- What OCR read from an uploaded receipt and the expense fields it proposed,
+-- kept to review against the expense finally loaded from it.
+CREATE TABLE expense_extractions (
+    id BIGSERIAL PRIMARY KEY,
+    image_path TEXT NOT NULL,
+    engine TEXT NOT NULL,
+    raw_text TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    amount NUMERIC(15, 2),
+    date DATE,
+    provider_cuit TEXT NOT NULL DEFAULT '',
+    provider_id BIGINT REFERENCES providers(id) ON DELETE SET NULL,
+    invoice_number TEXT NOT NULL DEFAULT '',
+    invoice_type TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'confirmed', 'discarded')),
+    expense_id INT REFERENCES expenses(id) ON DELETE SET NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    reviewed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_expense_extractions_status ON expense_extractions(status, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS expense_extractions;
+-- +goose StatementEnd
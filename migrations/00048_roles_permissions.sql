@@ -0,0 +1,70 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Roles are sets of permissions. users.role keeps the role name, now
+-- referencing a configurable role instead of one of two hard-coded values.
+CREATE TABLE roles (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    -- System roles can't be renamed or deleted.
+    is_system BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE role_permissions (
+    role_id BIGINT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+INSERT INTO roles (name, description, is_system) VALUES
+    ('administrator', 'Acceso completo al sistema', TRUE),
+    ('employee', 'Atención del local: ventas, turnos y stock', TRUE);
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (VALUES
+    ('sales.view'), ('sales.create'), ('sales.void'), ('shifts.operate'),
+    ('stock.view'), ('stock.adjust'), ('stock.transfer'), ('waste.register'), ('waste.report'),
+    ('inventory.count'), ('inventory.manage'),
+    ('products.edit'), ('prices.edit'),
+    ('clients.manage'), ('providers.manage'), ('orders.manage'), ('invoices.view'),
+    ('purchases.manage'), ('payables.manage'), ('expenses.view'), ('expenses.create'), ('production.plan'),
+    ('reports.view'), ('settings.manage'), ('users.manage')
+) AS p(permission)
+WHERE r.name = 'administrator';
+
+-- Everything employees could reach on the web before roles: the POS, shifts
+-- and local stock, the catalog (products, categories, ingredients and
+-- recipes), clients, providers and orders. Payment methods, expenses, users,
+-- invoices and pending production were already administrator only.
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, p.permission
+FROM roles r
+CROSS JOIN (VALUES
+    ('sales.view'), ('sales.create'), ('shifts.operate'),
+    ('stock.view'), ('stock.adjust'), ('stock.transfer'), ('waste.register'),
+    ('inventory.count'),
+    ('products.edit'), ('clients.manage'), ('providers.manage'), ('orders.manage')
+) AS p(permission)
+WHERE r.name = 'employee';
+
+-- Keep any role name already assigned, without permissions.
+INSERT INTO roles (name)
+SELECT DISTINCT role FROM users
+ON CONFLICT (name) DO NOTHING;
+
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(50);
+ALTER TABLE users ADD CONSTRAINT users_role_fkey
+    FOREIGN KEY (role) REFERENCES roles(name) ON UPDATE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_fkey;
+UPDATE users SET role = 'employee' WHERE role NOT IN ('administrator', 'employee');
+ALTER TABLE users ALTER COLUMN role TYPE VARCHAR(20);
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Authentication tokens double as sessions: the id lets users revoke one
+-- without knowing its hash, and the rest is shown in the sessions list.
+ALTER TABLE tokens
+    ADD COLUMN id BIGSERIAL UNIQUE,
+    ADD COLUMN user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN ip VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+CREATE INDEX idx_tokens_user_scope ON tokens (user_id, scope);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tokens_user_scope;
+ALTER TABLE tokens
+    DROP COLUMN last_seen_at,
+    DROP COLUMN created_at,
+    DROP COLUMN ip,
+    DROP COLUMN user_agent,
+    DROP COLUMN id;
+-- +goose StatementEnd
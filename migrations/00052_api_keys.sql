@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Long-lived keys for integrations. A key acts as the user who created it,
+-- limited to its scopes. Only a hash is stored; prefix is the start of the
+-- key, shown so keys can be told apart.
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash BYTEA NOT NULL UNIQUE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- IPs and CIDR ranges the key can be used from, comma separated. Empty
+    -- allows any.
+    allowed_ips TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE api_key_scopes (
+    api_key_id BIGINT NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL,
+    PRIMARY KEY (api_key_id, permission)
+);
+
+-- No foreign key, like user_id: entries outlive the keys.
+ALTER TABLE audit_log ADD COLUMN api_key_id BIGINT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE audit_log DROP COLUMN IF EXISTS api_key_id;
+DROP TABLE IF EXISTS api_key_scopes;
+DROP TABLE IF EXISTS api_keys;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Failed logins since the last success. The count restarts when the account
+-- gets locked until locked_until.
+ALTER TABLE users
+    ADD COLUMN failed_logins INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_failed_login_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN locked_until TIMESTAMP WITH TIME ZONE;
+
+-- Security relevant events: failed logins, lockouts, password resets.
+-- username is the one typed, which may not exist.
+CREATE TABLE security_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    username VARCHAR(100) NOT NULL DEFAULT '',
+    event VARCHAR(50) NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_security_events_user ON security_events (user_id);
+CREATE INDEX idx_security_events_created_at ON security_events (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS security_events;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS failed_logins,
+    DROP COLUMN IF EXISTS last_failed_login_at,
+    DROP COLUMN IF EXISTS locked_until;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Numeric PIN employees identify with on the POS, bcrypt hashed. NULL when
+-- the user has none.
+ALTER TABLE users ADD COLUMN pin_hash BYTEA;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS pin_hash;
+-- +goose StatementEnd
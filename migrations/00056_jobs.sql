@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Background jobs: side effects of requests (invoices, emails) and scheduled
+-- tasks. Jobs queued in the same transaction as the change they follow up
+-- run only if it commits. Workers claim due jobs with SKIP LOCKED, marking
+-- them running until locked_until; a job whose worker died is taken again
+-- after that. Failed jobs are retried with backoff until max_attempts, then
+-- left dead for an administrator to retry.
+CREATE TABLE jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 5,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMP WITH TIME ZONE,
+    -- At most one pending or running job per unique_key, so scheduled tasks
+    -- don't pile up when several servers run.
+    unique_key VARCHAR(100),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_jobs_due ON jobs (run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_status ON jobs (status, created_at);
+CREATE UNIQUE INDEX idx_jobs_unique_key ON jobs (unique_key) WHERE status IN ('pending', 'running');
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'jobs.manage' FROM roles WHERE name = 'administrator'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM role_permissions WHERE permission = 'jobs.manage';
+DROP TABLE IF EXISTS jobs;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Orders don't take stock out of any lot, so the lots guessed for their
+-- remitos couldn't be backed up.
+ALTER TABLE order_products DROP COLUMN IF EXISTS lot_numbers;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE order_products ADD COLUMN lot_numbers TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
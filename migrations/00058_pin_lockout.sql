@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Wrong POS PINs since the last right one, counted apart from failed logins:
+-- too many lock only the PIN quick-switch, until pin_locked_until.
+ALTER TABLE users
+    ADD COLUMN failed_pins INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_failed_pin_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN pin_locked_until TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN IF EXISTS failed_pins,
+    DROP COLUMN IF EXISTS last_failed_pin_at,
+    DROP COLUMN IF EXISTS pin_locked_until;
+-- +goose StatementEnd
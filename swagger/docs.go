@@ -532,6 +532,18 @@ const docTemplate = `{
                         "name": "category_id",
                         "in": "query"
                     },
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "provider_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payment status (paid/partial/pending)",
+                        "name": "status",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
                         "description": "Start Date (YYYY-MM-DD)",
@@ -581,9 +593,10 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Creates a new expense with optional receipt image",
+                "description": "Creates a new expense with optional receipt image, line items, provider invoice and VAT breakdown. Accepts multipart/form-data, where items and vat are JSON arrays, or a JSON body without image. The amount is derived from the VAT breakdown or the items when omitted. Responds 409 when the provider invoice number is already loaded.",
                 "consumes": [
-                    "multipart/form-data"
+                    "multipart/form-data",
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
@@ -595,10 +608,9 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Amount",
+                        "description": "Amount; derived from vat or items when empty",
                         "name": "amount",
-                        "in": "formData",
-                        "required": true
+                        "in": "formData"
                     },
                     {
                         "type": "integer",
@@ -627,11 +639,55 @@ const docTemplate = `{
                         "name": "provider_id",
                         "in": "formData"
                     },
+                    {
+                        "type": "string",
+                        "description": "Due date (YYYY-MM-DD); leaves the expense pending payment",
+                        "name": "due_date",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Provider invoice number",
+                        "name": "invoice_number",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Invoice type (A/B/C/M/X)",
+                        "name": "invoice_type",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON array of services.ExpenseItemRequest",
+                        "name": "items",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JSON array of services.ExpenseVATRequest",
+                        "name": "vat",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "OCR extraction the expense is loaded from; its image is used when none is uploaded",
+                        "name": "extraction_id",
+                        "in": "formData"
+                    },
                     {
                         "type": "file",
                         "description": "Receipt Image",
                         "name": "image",
                         "in": "formData"
+                    },
+                    {
+                        "description": "Expense (JSON body)",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/services.ExpenseRequest"
+                        }
                     }
                 ],
                 "responses": {
@@ -647,6 +703,205 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/expenses/extractions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with OCR extractions for review, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expenses"
+                ],
+                "summary": "Lists receipt extractions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Status (pending/confirmed/discarded)",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/store.ExpenseExtraction"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stores the receipt image and reads it with OCR, proposing amount, date, provider CUIT (matched with a provider), invoice number and type. The extraction stays pending until an expense is created with its extraction_id or it is discarded. If the engine fails, the extraction is stored with the error and no proposals.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expenses"
+                ],
+                "summary": "Reads a receipt",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Receipt Image",
+                        "name": "image",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/store.ExpenseExtraction"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/expenses/extractions/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the OCR text and proposed fields of a receipt",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "expenses"
+                ],
+                "summary": "Gets a receipt extraction",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Extraction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/store.ExpenseExtraction"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/expenses/extractions/{id}/discard": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Marks a pending extraction as reviewed without loading an expense",
+                "tags": [
+                    "expenses"
+                ],
+                "summary": "Discards a receipt extraction",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Extraction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -743,6 +998,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -788,7 +1049,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Creates a new ingredient with a name",
+                "description": "Creates a new ingredient with a name and an optional cost per kg, liter or unit",
                 "consumes": [
                     "application/json"
                 ],
@@ -933,7 +1194,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Updates an ingredient's name",
+                "description": "Updates an ingredient's name and cost",
                 "consumes": [
                     "application/json"
                 ],
@@ -1118,6 +1379,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
+                    "409": {
+                        "description": "The given cash register has no open session",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -1127,27 +1394,27 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/local_sales/{id}": {
+        "/api/v1/local_sales/lookup": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Retrieves the details of a single local sale by its ID.",
+                "description": "Resolves a barcode or PLU to a product. Weighed-item EAN-13 labels (prefix 20-29) also return the price of the line, embedded in the label or computed from the embedded weight.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "local_sales"
                 ],
-                "summary": "Get a single local sale",
+                "summary": "Look up a scanned code",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Sale ID",
-                        "name": "id",
-                        "in": "path",
+                        "type": "string",
+                        "description": "Scanned barcode or PLU",
+                        "name": "code",
+                        "in": "query",
                         "required": true
                     }
                 ],
@@ -1155,7 +1422,13 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.LocalSaleResponse"
+                            "$ref": "#/definitions/api.ScanResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
                     "404": {
@@ -1173,85 +1446,39 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/local_stock": {
+        "/api/v1/local_sales/{id}": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a list of all local stock records",
+                "description": "Retrieves the details of a single local sale by its ID.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "local_stock"
+                    "local_sales"
                 ],
-                "summary": "List all local stock",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/api.LocalStocksResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
-                        }
-                    }
-                }
-            },
-            "post": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Creates the first stock record for a product. Fails if a record already exists.",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "local_stock"
-                ],
-                "summary": "Create initial stock for a product",
+                "summary": "Get a single local sale",
                 "parameters": [
                     {
-                        "description": "Initial stock data",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.CreateInitialStockRequest"
-                        }
+                        "type": "integer",
+                        "description": "Sale ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
-                        "schema": {
-                            "$ref": "#/definitions/api.LocalStockResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Invalid input",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
+                            "$ref": "#/definitions/api.LocalSaleResponse"
                         }
                     },
                     "404": {
-                        "description": "Product not found",
-                        "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
-                        }
-                    },
-                    "409": {
-                        "description": "Stock record already exists",
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
@@ -1265,39 +1492,52 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/local_stock/{product_id}": {
+        "/api/v1/local_sales/{id}/receipt": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with the local stock quantity for a given product ID",
+                "description": "Renders the customer ticket of a sale. format=escpos returns the raw ESC/POS byte stream for a thermal printer; the default, format=html, returns a printable page.",
                 "produces": [
-                    "application/json"
+                    "text/html",
+                    "application/octet-stream"
                 ],
                 "tags": [
-                    "local_stock"
+                    "local_sales"
                 ],
-                "summary": "Get stock for a single product",
+                "summary": "Get the ticket of a local sale",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "product_id",
+                        "description": "Sale ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "html (default) or escpos",
+                        "name": "format",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.LocalStockResponse"
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
                     "404": {
-                        "description": "Stock record not found",
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
@@ -1311,57 +1551,54 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/local_stock/{product_id}/adjust": {
-            "patch": {
+        "/api/v1/local_sales/{id}/receipt/print": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Adjusts a product's stock quantity by a delta (can be positive or negative).",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Sends the ESC/POS ticket of a sale to the configured network thermal printer.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "local_stock"
+                    "local_sales"
                 ],
-                "summary": "Adjust stock for a product",
+                "summary": "Print the ticket of a local sale",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "product_id",
+                        "description": "Sale ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Adjustment data",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.AdjustStockRequest"
-                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "$ref": "#/definitions/api.LocalStockResponse"
+                            "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
-                    "400": {
-                        "description": "Invalid input or insufficient stock",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "502": {
+                        "description": "The printer could not be reached",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "503": {
+                        "description": "No printer is configured",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
@@ -1369,44 +1606,26 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/orders": {
+        "/api/v1/local_stock": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a list of orders, with optional filters",
+                "description": "Responds with the stock of every product at a location (the default location unless location_id is given)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "orders"
+                    "local_stock"
                 ],
-                "summary": "Lists orders",
+                "summary": "List all local stock",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Filter by client ID",
-                        "name": "client_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Filter by order state",
-                        "name": "state",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Results-per-page limit",
-                        "name": "limit",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page offset for pagination",
-                        "name": "offset",
+                        "description": "Stock location ID",
+                        "name": "location_id",
                         "in": "query"
                     }
                 ],
@@ -1414,7 +1633,19 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.OrdersResponse"
+                            "$ref": "#/definitions/api.LocalStocksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Location not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
                     "500": {
@@ -1431,7 +1662,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Creates a new order for a client with a list of items",
+                "description": "Creates the first stock record for a product at the default location. Fails if a record already exists.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1439,17 +1670,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "orders"
+                    "local_stock"
                 ],
-                "summary": "Creates an order",
+                "summary": "Create initial stock for a product",
                 "parameters": [
                     {
-                        "description": "Order data",
+                        "description": "Initial stock data",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.RegisterOrderRequest"
+                            "$ref": "#/definitions/api.CreateInitialStockRequest"
                         }
                     }
                 ],
@@ -1457,11 +1688,23 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/api.OrderResponse"
+                            "$ref": "#/definitions/api.LocalStockResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid input",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Stock record already exists",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
@@ -1475,35 +1718,34 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/orders/{id}": {
+        "/api/v1/local_stock/lots/expiring": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a single order with a given ID",
+                "description": "Responds with the lots still in stock whose best-before date is within the given number of days (default 1: today and tomorrow), including lots already expired.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "orders"
+                    "local_stock"
                 ],
-                "summary": "Gets an order",
+                "summary": "List lots about to expire",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Order ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Days ahead (default 1)",
+                        "name": "days",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.OrderResponse"
+                            "$ref": "#/definitions/api.LotsResponse"
                         }
                     },
                     "400": {
@@ -1512,12 +1754,6 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -1527,52 +1763,35 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/orders/{id}/state": {
-            "patch": {
+        "/api/v1/local_stock/lots/{id}": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Updates the state of an order (e.g., \"todo\", \"done\", \"delivered\")",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Responds with a lot and every stock movement that touched it (production, sales, voids, waste), to trace where its goods went.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "orders"
+                    "local_stock"
                 ],
-                "summary": "Updates an order's state",
+                "summary": "Get a lot with its movements",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Order ID",
+                        "description": "Lot ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "New state",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object",
-                            "properties": {
-                                "state": {
-                                    "$ref": "#/definitions/store.OrderState"
-                                }
-                            }
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.UpdateOrderStateResponse"
+                            "$ref": "#/definitions/api.LotResponse"
                         }
                     },
                     "400": {
@@ -1596,43 +1815,72 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/payment_methods": {
+        "/api/v1/local_stock/{product_id}": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a list of all payment methods",
+                "description": "Responds with the stock quantity of a product at a location (the default location unless location_id is given)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment_methods"
+                    "local_stock"
+                ],
+                "summary": "Get stock for a single product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "product_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stock location ID",
+                        "name": "location_id",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Gets all payment methods",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.PaymentMethodsResponse"
+                            "$ref": "#/definitions/api.LocalStockResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Stock record not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     }
                 }
-            },
-            "post": {
+            }
+        },
+        "/api/v1/local_stock/{product_id}/adjust": {
+            "patch": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Creates a new payment method",
+                "description": "Adjusts a product's stock quantity at a location (default location unless location_id is given) by a delta (can be positive or negative). The change is recorded in the stock movement ledger as a manual adjustment or as production. Production can open a lot with production and best-before dates; stock taken out consumes lots first to expire first. Stock at a location other than the default one starts with its first entry.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1640,29 +1888,48 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "payment_methods"
+                    "local_stock"
                 ],
-                "summary": "Creates a payment method",
+                "summary": "Adjust stock for a product",
                 "parameters": [
                     {
-                        "description": "Payment Method data",
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "product_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Adjustment data",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.registerPaymentMethodRequest"
+                            "$ref": "#/definitions/api.AdjustStockRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.PaymentMethodResponse"
+                            "$ref": "#/definitions/api.LocalStockResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "Invalid input or insufficient stock",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Product, location or stock record not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Lot number already used for the product",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
@@ -1676,35 +1943,48 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/payment_methods/{id}": {
+        "/api/v1/local_stock/{product_id}/at": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a single payment method with a given ID",
+                "description": "Reconstructs a product's stock at a location from the movement ledger. A plain date (YYYY-MM-DD) means the end of that day; an RFC 3339 timestamp is used as is.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "payment_methods"
+                    "local_stock"
                 ],
-                "summary": "Gets a payment method",
+                "summary": "Get a product's stock at a past date",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Payment Method ID",
-                        "name": "id",
+                        "description": "Product ID",
+                        "name": "product_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stock location ID (default location when omitted)",
+                        "name": "location_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date (YYYY-MM-DD) or timestamp (RFC 3339)",
+                        "name": "at",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.PaymentMethodResponse"
+                            "$ref": "#/definitions/api.StockAtResponse"
                         }
                     },
                     "400": {
@@ -1726,39 +2006,41 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/api/v1/local_stock/{product_id}/locations": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Deletes a payment method with a given ID",
+                "description": "Responds with the stock records a product has, one per location.",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "payment_methods"
+                    "local_stock"
                 ],
-                "summary": "Deletes a payment method",
+                "summary": "Get a product's stock at every location",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Payment Method ID",
-                        "name": "id",
+                        "description": "Product ID",
+                        "name": "product_id",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
+                            "$ref": "#/definitions/api.LocalStocksResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
@@ -1772,69 +2054,41 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/products": {
+        "/api/v1/local_stock/{product_id}/lots": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a list of all products",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "products"
-                ],
-                "summary": "Gets all products",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/api.ProductsResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
-                        }
-                    }
-                }
-            },
-            "post": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Creates a new product with a name, a description, a category, and prices",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Responds with a product's lots in the order sales consume them (first to expire first). Lots already used up are only listed with all=true.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "products"
+                    "local_stock"
                 ],
-                "summary": "Creates a product",
+                "summary": "List the lots of a product",
                 "parameters": [
                     {
-                        "description": "Product data",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.registerProductRequest"
-                        }
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "product_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include used up lots",
+                        "name": "all",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ProductResponse"
+                            "$ref": "#/definitions/api.LotsResponse"
                         }
                     },
                     "400": {
@@ -1843,6 +2097,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -1852,35 +2112,53 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/products/{id}": {
+        "/api/v1/local_stock/{product_id}/movements": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a single product with a given ID",
+                "description": "Responds with every change to a product's stock at a location (sales, voids, adjustments, production, waste, transfers) in a date range, oldest first. Each movement carries the balance at the location after it; opening_balance is the stock there at the start of the range.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "products"
+                    "local_stock"
                 ],
-                "summary": "Gets a product",
+                "summary": "List stock movements of a product",
                 "parameters": [
                     {
                         "type": "integer",
                         "description": "Product ID",
-                        "name": "id",
+                        "name": "product_id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stock location ID (default location when omitted)",
+                        "name": "location_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "First day, YYYY-MM-DD (default: 30 days ago)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day, YYYY-MM-DD (default: today)",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ProductResponse"
+                            "$ref": "#/definitions/api.StockMovementsResponse"
                         }
                     },
                     "400": {
@@ -1902,41 +2180,54 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/api/v1/orders": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Deletes a product with a given ID",
+                "description": "Responds with a list of orders, with optional filters",
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "products"
+                    "orders"
                 ],
-                "summary": "Deletes a product",
+                "summary": "Lists orders",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Filter by client ID",
+                        "name": "client_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by order state",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Results-per-page limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page offset for pagination",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
-                        }
-                    },
-                    "404": {
-                        "description": "Not Found",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
+                            "$ref": "#/definitions/api.OrdersResponse"
                         }
                     },
                     "500": {
@@ -1947,13 +2238,13 @@ const docTemplate = `{
                     }
                 }
             },
-            "patch": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Updates a product's details",
+                "description": "Creates a new order for a client with a list of items",
                 "consumes": [
                     "application/json"
                 ],
@@ -1961,32 +2252,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "products"
+                    "orders"
                 ],
-                "summary": "Updates a product",
+                "summary": "Creates an order",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Product ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Product data",
+                        "description": "Order data",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.registerProductRequest"
+                            "$ref": "#/definitions/api.RegisterOrderRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/api.ProductResponse"
+                            "$ref": "#/definitions/api.OrderResponse"
                         }
                     },
                     "400": {
@@ -1995,12 +2279,6 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -2010,47 +2288,35 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/products/{productID}/ingredients": {
-            "post": {
+        "/api/v1/orders/{id}": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Adds a new ingredient to a product's recipe",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Responds with a single order with a given ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "products"
+                    "orders"
                 ],
-                "summary": "Adds an ingredient to a product",
+                "summary": "Gets an order",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "productID",
+                        "description": "Order ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Ingredient data",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.productIngredientRequest"
-                        }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ProductIngredientResponse"
+                            "$ref": "#/definitions/api.OrderResponse"
                         }
                     },
                     "400": {
@@ -2059,6 +2325,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -2068,37 +2340,53 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/products/{productID}/ingredients/{ingredientID}": {
-            "delete": {
+        "/api/v1/orders/{id}/state": {
+            "patch": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Removes an ingredient from a product's recipe",
+                "description": "Updates the state of an order (e.g., \"todo\", \"done\", \"delivered\")",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
-                    "products"
+                    "orders"
                 ],
-                "summary": "Removes an ingredient from a product",
+                "summary": "Updates an order's state",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "productID",
+                        "description": "Order ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Ingredient ID",
-                        "name": "ingredientID",
-                        "in": "path",
-                        "required": true
+                        "description": "New state",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "state": {
+                                    "$ref": "#/definitions/store.OrderState"
+                                }
+                            }
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.UpdateOrderStateResponse"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -2119,63 +2407,42 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
+            }
+        },
+        "/api/v1/payables": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Updates an ingredient's quantity or unit in a product's recipe",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Responds with unpaid and partially paid expenses, soonest due first, with the total owed and how much of it is overdue.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "products"
+                    "accounts_payable"
                 ],
-                "summary": "Updates a product's ingredient",
+                "summary": "List expenses left to pay",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
-                        "name": "productID",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Ingredient ID",
-                        "name": "ingredientID",
-                        "in": "path",
-                        "required": true
+                        "description": "Only this provider",
+                        "name": "provider_id",
+                        "in": "query"
                     },
                     {
-                        "description": "Ingredient data",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "type": "object",
-                            "properties": {
-                                "quantity": {
-                                    "type": "number",
-                                    "format": "float64"
-                                },
-                                "unit": {
-                                    "type": "string"
-                                }
-                            }
-                        }
+                        "type": "string",
+                        "description": "Only due on or before this day (YYYY-MM-DD)",
+                        "name": "until",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ProductIngredientResponse"
+                            "$ref": "#/definitions/api.PayablesResponse"
                         }
                     },
                     "400": {
@@ -2184,12 +2451,6 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/utils.HTTPError"
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -2199,55 +2460,57 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/providers": {
+        "/api/v1/payables/balances": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a list of all providers. Can be filtered using a\nfull-text search query, and paginated using limit and offset.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Responds with what is owed to each provider, largest first, with the overdue part and the next due date.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "providers"
+                    "accounts_payable"
                 ],
-                "summary": "Gets all providers, or searches them",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Full-text search query",
-                        "name": "q",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Category ID to filter by",
-                        "name": "category_id",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Results-per-page limit",
-                        "name": "limit",
-                        "in": "query"
+                "summary": "List provider balances",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProviderBalancesResponse"
+                        }
                     },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/payment_methods": {
+            "get": {
+                "security": [
                     {
-                        "type": "integer",
-                        "description": "Page offset for pagination",
-                        "name": "offset",
-                        "in": "query"
+                        "BearerAuth": []
                     }
                 ],
+                "description": "Responds with a list of all payment methods",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payment_methods"
+                ],
+                "summary": "Gets all payment methods",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ProvidersResponse"
+                            "$ref": "#/definitions/api.PaymentMethodsResponse"
                         }
                     },
                     "500": {
@@ -2264,7 +2527,7 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Creates a new provider",
+                "description": "Creates a new payment method",
                 "consumes": [
                     "application/json"
                 ],
@@ -2272,17 +2535,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "providers"
+                    "payment_methods"
                 ],
-                "summary": "Creates a provider",
+                "summary": "Creates a payment method",
                 "parameters": [
                     {
-                        "description": "Provider data",
+                        "description": "Payment Method data",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.registerProviderRequest"
+                            "$ref": "#/definitions/api.registerPaymentMethodRequest"
                         }
                     }
                 ],
@@ -2290,7 +2553,7 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/api.ProviderResponse"
+                            "$ref": "#/definitions/api.PaymentMethodResponse"
                         }
                     },
                     "400": {
@@ -2308,25 +2571,25 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/providers/{id}": {
+        "/api/v1/payment_methods/{id}": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Responds with a single provider with a given ID",
+                "description": "Responds with a single payment method with a given ID",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "providers"
+                    "payment_methods"
                 ],
-                "summary": "Gets a provider",
+                "summary": "Gets a payment method",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Provider ID",
+                        "description": "Payment Method ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -2336,7 +2599,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ProviderResponse"
+                            "$ref": "#/definitions/api.PaymentMethodResponse"
                         }
                     },
                     "400": {
@@ -2359,48 +2622,30 @@ const docTemplate = `{
                     }
                 }
             },
-            "patch": {
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Updates a provider's details",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
+                "description": "Deletes a payment method with a given ID",
                 "tags": [
-                    "providers"
+                    "payment_methods"
                 ],
-                "summary": "Updates a provider",
+                "summary": "Deletes a payment method",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Provider ID",
+                        "description": "Payment Method ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Provider data",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.registerProviderRequest"
-                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/api.ProviderResponse"
-                        }
-                    },
+                    "204": {
+                        "description": "No Content"
+                    },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
@@ -2422,9 +2667,94 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/tokens/authentication": {
+        "/api/v1/production/suggestions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Proposes how much of each product to make for a day. Stock at the default location is projected by taking off pending \"todo\" orders and the average sales of the same weekday over the previous weeks; products whose projection falls below their minimum are topped up to their target. Only products with something to make are listed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "production"
+                ],
+                "summary": "Suggest production quantities",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Day to plan, YYYY-MM-DD (default: tomorrow)",
+                        "name": "date",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Past weeks to average sales over, 1 to 12 (default: 4)",
+                        "name": "weeks",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProductionPlanResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/products": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with a list of all products",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Gets all products",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProductsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Creates a new authentication token for a user",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a new product with a name, a description, a category, and prices",
                 "consumes": [
                     "application/json"
                 ],
@@ -2432,17 +2762,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "tokens"
+                    "products"
                 ],
-                "summary": "Creates an authentication token",
+                "summary": "Creates a product",
                 "parameters": [
                     {
-                        "description": "User credentials",
+                        "description": "Product data",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.createTokenRequest"
+                            "$ref": "#/definitions/api.registerProductRequest"
                         }
                     }
                 ],
@@ -2450,7 +2780,7 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/api.TokenResponse"
+                            "$ref": "#/definitions/api.ProductResponse"
                         }
                     },
                     "400": {
@@ -2459,8 +2789,8 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "409": {
+                        "description": "A barcode already identifies another product",
                         "schema": {
                             "$ref": "#/definitions/utils.HTTPError"
                         }
@@ -2474,9 +2804,108 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/users": {
-            "post": {
-                "description": "Creates a new user with a username, email, and password",
+        "/api/v1/products/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with a single product with a given ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Gets a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProductResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes a product with a given ID",
+                "tags": [
+                    "products"
+                ],
+                "summary": "Deletes a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Updates a product's details. Fields left out are kept; min_stock and target_stock are cleared with null.",
                 "consumes": [
                     "application/json"
                 ],
@@ -2484,25 +2913,32 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "users"
+                    "products"
                 ],
-                "summary": "Creates a user",
+                "summary": "Updates a product",
                 "parameters": [
                     {
-                        "description": "User data",
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Product data",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.registerUserRequest"
+                            "$ref": "#/definitions/api.registerProductRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.UserResponse"
+                            "$ref": "#/definitions/api.ProductResponse"
                         }
                     },
                     "400": {
@@ -2511,6 +2947,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.HTTPError"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "A barcode already identifies another product",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -2519,438 +2967,3578 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.AdjustStockRequest": {
-            "type": "object",
-            "properties": {
-                "delta": {
-                    "type": "integer"
-                }
-            }
         },
-        "api.CategoriesResponse": {
-            "type": "object",
-            "properties": {
-                "categories": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/store.Category"
+        "/api/v1/products/{productID}/ingredients": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
                     }
-                }
+                ],
+                "description": "Adds a new ingredient to a product's recipe",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Adds an ingredient to a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Ingredient data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.productIngredientRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProductIngredientResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/products/{productID}/ingredients/{ingredientID}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Removes an ingredient from a product's recipe",
+                "tags": [
+                    "products"
+                ],
+                "summary": "Removes an ingredient from a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Ingredient ID",
+                        "name": "ingredientID",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Updates an ingredient's quantity or unit in a product's recipe",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Updates a product's ingredient",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "productID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Ingredient ID",
+                        "name": "ingredientID",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Ingredient data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "quantity": {
+                                    "type": "number",
+                                    "format": "float64"
+                                },
+                                "unit": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProductIngredientResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/provider_payments": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with payments made between two days, newest first, without their allocations.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "accounts_payable"
+                ],
+                "summary": "List provider payments",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only this provider",
+                        "name": "provider_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "First day (YYYY-MM-DD, default: 30 days before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day (YYYY-MM-DD, default: today)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProviderPaymentsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Records a payment to a provider with one payment method and applies it to one or more of its expenses. The payment amount is the sum of the allocations, each of which may not exceed what is left to pay of its expense.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "accounts_payable"
+                ],
+                "summary": "Register a provider payment",
+                "parameters": [
+                    {
+                        "description": "Payment data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ProviderPaymentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProviderPaymentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Provider, payment method or expense not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Amount exceeds the expense balance",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/provider_payments/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the payment and the expenses it was applied to.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "accounts_payable"
+                ],
+                "summary": "Get a provider payment",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Payment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProviderPaymentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/providers": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with a list of all providers. Can be filtered using a\nfull-text search query, and paginated using limit and offset.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "providers"
+                ],
+                "summary": "Gets all providers, or searches them",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Full-text search query",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Category ID to filter by",
+                        "name": "category_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Results-per-page limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page offset for pagination",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProvidersResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a new provider",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "providers"
+                ],
+                "summary": "Creates a provider",
+                "parameters": [
+                    {
+                        "description": "Provider data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.registerProviderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProviderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/providers/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with a single provider with a given ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "providers"
+                ],
+                "summary": "Gets a provider",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProviderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Updates a provider's details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "providers"
+                ],
+                "summary": "Updates a provider",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Provider data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.registerProviderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProviderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/providers/{id}/statement": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the balance owed to the provider before from, every expense (debit) and payment (credit) between from and to with the running balance, and the closing balance. Expenses recorded as paid show a settling credit on the same day.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "accounts_payable"
+                ],
+                "summary": "Get a provider account statement",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Provider ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "First day (YYYY-MM-DD, default: 30 days before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day (YYYY-MM-DD, default: today)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.ProviderStatementResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/purchase_orders": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with purchase orders, newest first, without their lines.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchase_orders"
+                ],
+                "summary": "List purchase orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Only this provider",
+                        "name": "provider_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "draft, sent, partially_received, received or cancelled",
+                        "name": "state",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default: all)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurchaseOrdersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a draft order to a provider with the ingredients, quantities (in the unit the ingredient cost is expressed in) and expected unit prices.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchase_orders"
+                ],
+                "summary": "Create a purchase order",
+                "parameters": [
+                    {
+                        "description": "Order data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.PurchaseOrderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurchaseOrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Provider or ingredient not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/purchase_orders/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the order, its lines with received quantities and the receptions recorded so far.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchase_orders"
+                ],
+                "summary": "Get a purchase order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Purchase order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurchaseOrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replaces the provider, date, notes and lines of an order that has not been sent yet.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchase_orders"
+                ],
+                "summary": "Update a draft purchase order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Purchase order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Order data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.PurchaseOrderRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurchaseOrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order is no longer a draft",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/purchase_orders/{id}/cancel": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Cancels an order that has not been fully received. Goods already received stay in stock.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchase_orders"
+                ],
+                "summary": "Cancel a purchase order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Purchase order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurchaseOrderResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order already received or cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/purchase_orders/{id}/mark_sent": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Moves a draft to sent without emailing it, for orders placed by phone or in person.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchase_orders"
+                ],
+                "summary": "Mark a purchase order as sent",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Purchase order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurchaseOrderResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order is not a draft",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/purchase_orders/{id}/receive": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Records a delivery: quantities are added to ingredient stock and an expense for the provider is created at the invoiced prices (the expected ones when omitted). The order becomes partially_received or received.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchase_orders"
+                ],
+                "summary": "Receive goods of a purchase order",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Purchase order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Received quantities",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.ReceivePurchaseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurchaseOrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order is not sent",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/purchase_orders/{id}/send": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Emails the order to the provider and marks a draft as sent. Sent orders can be emailed again.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "purchase_orders"
+                ],
+                "summary": "Email a purchase order to the provider",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Purchase order ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.PurchaseOrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Provider has no email",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Order already received or cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "502": {
+                        "description": "Email could not be sent",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stock_locations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the places that hold stock, the default one first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock_locations"
+                ],
+                "summary": "List stock locations",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only active locations",
+                        "name": "active",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.StockLocationsResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Adds a place that holds stock. It starts empty; stock arrives through transfers or adjustments.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock_locations"
+                ],
+                "summary": "Create a stock location",
+                "parameters": [
+                    {
+                        "description": "Location data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.StockLocationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.StockLocationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Name already taken",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stock_locations/{id}": {
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Renames a location or (de)activates it. The default location cannot be deactivated.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock_locations"
+                ],
+                "summary": "Update a stock location",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Location ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Location data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.StockLocationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.StockLocationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "409": {
+                        "description": "Name already taken",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stock_transfers": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the remitos internos in a date range, newest first, without their items.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock_transfers"
+                ],
+                "summary": "List internal transfers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day, YYYY-MM-DD (default: 30 days ago)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day, YYYY-MM-DD (default: today)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only transfers leaving or entering this location",
+                        "name": "location_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.StockTransfersResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Records a remito interno and moves its goods from one location to the other in the same transaction, lots included.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock_transfers"
+                ],
+                "summary": "Create an internal transfer",
+                "parameters": [
+                    {
+                        "description": "Transfer data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.CreateTransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.StockTransferResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or insufficient stock",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Location or product not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/stock_transfers/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with a remito interno and its items.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "stock_transfers"
+                ],
+                "summary": "Get an internal transfer",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Transfer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.StockTransferResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tokens/authentication": {
+            "post": {
+                "description": "Creates a new authentication token for a user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tokens"
+                ],
+                "summary": "Creates an authentication token",
+                "parameters": [
+                    {
+                        "description": "User credentials",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.createTokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users": {
+            "post": {
+                "description": "Creates a new user with a username, email, and password",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Creates a user",
+                "parameters": [
+                    {
+                        "description": "User data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.registerUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/waste": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Responds with the waste registered in a date range, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "waste"
+                ],
+                "summary": "List waste records",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day, YYYY-MM-DD (default: 30 days ago)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day, YYYY-MM-DD (default: today)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Only this product",
+                        "name": "product_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.WasteListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Records goods thrown away (reason: expired, broken, tasting or other) and deducts them from local stock. The loss is valued at recipe cost, or at the retail price when the recipe has no ingredient costs.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "waste"
+                ],
+                "summary": "Register waste",
+                "parameters": [
+                    {
+                        "description": "Waste data",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/services.RegisterWasteRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/api.WasteRecordResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input or insufficient stock",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Product not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/waste/report": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Totals waste in a date range by product and by week (weeks start on Monday). With product_id the weekly series covers that product only; otherwise weekly quantities mix units and only the value is meaningful.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "waste"
+                ],
+                "summary": "Waste report",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "First day, YYYY-MM-DD (default: 30 days ago)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day, YYYY-MM-DD (default: today)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Weekly series for this product",
+                        "name": "product_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/api.WasteReportResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.HTTPError"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.AdjustStockRequest": {
+            "type": "object",
+            "properties": {
+                "delta": {
+                    "type": "number"
+                },
+                "location_id": {
+                    "description": "LocationID is the stock location to adjust; the default location\nwhen omitted.",
+                    "type": "integer"
+                },
+                "lot": {
+                    "description": "Lot opens a lot with the produced quantity. Only with reason\n\"production\".",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/api.LotRequest"
+                        }
+                    ]
+                },
+                "note": {
+                    "type": "string"
+                },
+                "reason": {
+                    "description": "Reason is \"adjustment\" (default) or \"production\".",
+                    "type": "string",
+                    "example": "production"
+                }
+            }
+        },
+        "api.CategoriesResponse": {
+            "type": "object",
+            "properties": {
+                "categories": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.Category"
+                    }
+                }
+            }
+        },
+        "api.CategoryResponse": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "$ref": "#/definitions/store.Category"
+                }
+            }
+        },
+        "api.ClientResponse": {
+            "type": "object",
+            "properties": {
+                "client": {
+                    "$ref": "#/definitions/store.Client"
+                }
+            }
+        },
+        "api.ClientsResponse": {
+            "type": "object",
+            "properties": {
+                "clients": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.Client"
+                    }
+                },
+                "meta": {
+                    "$ref": "#/definitions/utils.Meta"
+                }
+            }
+        },
+        "api.CreateInitialStockRequest": {
+            "type": "object",
+            "properties": {
+                "initial_quantity": {
+                    "type": "number"
+                },
+                "product_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.CreateLocalSaleRequest": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.CreateLocalSaleItem"
+                    }
+                },
+                "payment_method_id": {
+                    "type": "integer"
+                },
+                "register_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.IngredientResponse": {
+            "type": "object",
+            "properties": {
+                "ingredient": {
+                    "$ref": "#/definitions/store.Ingredient"
+                }
+            }
+        },
+        "api.IngredientsResponse": {
+            "type": "object",
+            "properties": {
+                "ingredients": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.Ingredient"
+                    }
+                }
+            }
+        },
+        "api.InvoicesMeta": {
+            "type": "object",
+            "properties": {
+                "current_page": {
+                    "type": "integer"
+                },
+                "date_filter": {
+                    "type": "string"
+                },
+                "items_per_page": {
+                    "type": "integer"
+                },
+                "total_items": {
+                    "type": "integer"
+                },
+                "total_pages": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.InvoicesResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/billing.InvoiceFile"
+                    }
+                },
+                "meta": {
+                    "$ref": "#/definitions/api.InvoicesMeta"
+                }
+            }
+        },
+        "api.LocalSaleResponse": {
+            "type": "object",
+            "properties": {
+                "local_sale": {
+                    "$ref": "#/definitions/store.LocalSale"
+                }
+            }
+        },
+        "api.LocalSalesResponse": {
+            "type": "object",
+            "properties": {
+                "local_sales": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.LocalSale"
+                    }
+                }
+            }
+        },
+        "api.LocalStockResponse": {
+            "type": "object",
+            "properties": {
+                "local_stock": {
+                    "$ref": "#/definitions/store.LocalStock"
+                }
+            }
+        },
+        "api.LocalStocksResponse": {
+            "type": "object",
+            "properties": {
+                "local_stock": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.LocalStock"
+                    }
+                }
+            }
+        },
+        "api.LotRequest": {
+            "type": "object",
+            "properties": {
+                "best_before": {
+                    "type": "string",
+                    "example": "2025-03-04"
+                },
+                "number": {
+                    "description": "Number is generated from the production date when empty.",
+                    "type": "string",
+                    "example": "L250301-1"
+                },
+                "produced_on": {
+                    "type": "string",
+                    "example": "2025-03-01"
+                }
+            }
+        },
+        "api.LotResponse": {
+            "type": "object",
+            "properties": {
+                "lot": {
+                    "$ref": "#/definitions/store.StockLot"
+                },
+                "movements": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.LotMovement"
+                    }
+                }
+            }
+        },
+        "api.LotsResponse": {
+            "type": "object",
+            "properties": {
+                "lots": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.StockLot"
+                    }
+                }
+            }
+        },
+        "api.OrderItemReq": {
+            "type": "object",
+            "properties": {
+                "price": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.OrderResponse": {
+            "type": "object",
+            "properties": {
+                "order": {
+                    "$ref": "#/definitions/store.Order"
+                }
+            }
+        },
+        "api.OrdersResponse": {
+            "type": "object",
+            "properties": {
+                "meta": {
+                    "$ref": "#/definitions/utils.Meta"
+                },
+                "orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.Order"
+                    }
+                }
+            }
+        },
+        "api.PayablesResponse": {
+            "type": "object",
+            "properties": {
+                "payables": {
+                    "$ref": "#/definitions/services.Payables"
+                }
+            }
+        },
+        "api.PaymentMethodResponse": {
+            "type": "object",
+            "properties": {
+                "payment_method": {
+                    "$ref": "#/definitions/store.PaymentMethod"
+                }
+            }
+        },
+        "api.PaymentMethodsResponse": {
+            "type": "object",
+            "properties": {
+                "payment_methods": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.PaymentMethod"
+                    }
+                }
+            }
+        },
+        "api.ProductIngredientResponse": {
+            "type": "object",
+            "properties": {
+                "product_ingredient": {
+                    "$ref": "#/definitions/store.ProductIngredient"
+                }
+            }
+        },
+        "api.ProductResponse": {
+            "type": "object",
+            "properties": {
+                "product": {
+                    "$ref": "#/definitions/store.Product"
+                }
+            }
+        },
+        "api.ProductionPlanResponse": {
+            "type": "object",
+            "properties": {
+                "production_plan": {
+                    "$ref": "#/definitions/services.ProductionPlan"
+                }
+            }
+        },
+        "api.ProductsResponse": {
+            "type": "object",
+            "properties": {
+                "products": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.Product"
+                    }
+                }
+            }
+        },
+        "api.ProviderBalancesResponse": {
+            "type": "object",
+            "properties": {
+                "balances": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.ProviderBalance"
+                    }
+                }
+            }
+        },
+        "api.ProviderPaymentResponse": {
+            "type": "object",
+            "properties": {
+                "payment": {
+                    "$ref": "#/definitions/store.ProviderPayment"
+                }
+            }
+        },
+        "api.ProviderPaymentsResponse": {
+            "type": "object",
+            "properties": {
+                "payments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.ProviderPayment"
+                    }
+                }
+            }
+        },
+        "api.ProviderResponse": {
+            "type": "object",
+            "properties": {
+                "provider": {
+                    "$ref": "#/definitions/store.Provider"
+                }
+            }
+        },
+        "api.ProviderStatementResponse": {
+            "type": "object",
+            "properties": {
+                "statement": {
+                    "$ref": "#/definitions/services.ProviderStatement"
+                }
+            }
+        },
+        "api.ProvidersResponse": {
+            "type": "object",
+            "properties": {
+                "meta": {
+                    "$ref": "#/definitions/utils.Meta"
+                },
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.Provider"
+                    }
+                }
+            }
+        },
+        "api.PurchaseOrderResponse": {
+            "type": "object",
+            "properties": {
+                "purchase_order": {
+                    "$ref": "#/definitions/store.PurchaseOrder"
+                }
+            }
+        },
+        "api.PurchaseOrdersResponse": {
+            "type": "object",
+            "properties": {
+                "purchase_orders": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.PurchaseOrder"
+                    }
+                }
+            }
+        },
+        "api.RegisterOrderRequest": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.OrderItemReq"
+                    }
+                },
+                "state": {
+                    "$ref": "#/definitions/store.OrderState"
+                }
+            }
+        },
+        "api.ScanResponse": {
+            "type": "object",
+            "properties": {
+                "scan": {
+                    "$ref": "#/definitions/services.ScanResult"
+                }
+            }
+        },
+        "api.StockAtResponse": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.StockLocationRequest": {
+            "type": "object",
+            "properties": {
+                "is_active": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.StockLocationResponse": {
+            "type": "object",
+            "properties": {
+                "stock_location": {
+                    "$ref": "#/definitions/store.StockLocation"
+                }
+            }
+        },
+        "api.StockLocationsResponse": {
+            "type": "object",
+            "properties": {
+                "stock_locations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.StockLocation"
+                    }
+                }
+            }
+        },
+        "api.StockMovementsResponse": {
+            "type": "object",
+            "properties": {
+                "movements": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.StockMovement"
+                    }
+                },
+                "opening_balance": {
+                    "type": "number"
+                }
+            }
+        },
+        "api.StockTransferResponse": {
+            "type": "object",
+            "properties": {
+                "stock_transfer": {
+                    "$ref": "#/definitions/store.StockTransfer"
+                }
+            }
+        },
+        "api.StockTransfersResponse": {
+            "type": "object",
+            "properties": {
+                "stock_transfers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.StockTransfer"
+                    }
+                }
+            }
+        },
+        "api.TokenResponse": {
+            "type": "object",
+            "properties": {
+                "auth_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.UpdateOrderStateResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "state": {
+                    "$ref": "#/definitions/store.OrderState"
+                }
+            }
+        },
+        "api.UserResponse": {
+            "type": "object",
+            "properties": {
+                "user": {
+                    "$ref": "#/definitions/store.User"
+                }
+            }
+        },
+        "api.WasteListResponse": {
+            "type": "object",
+            "properties": {
+                "waste": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.WasteRecord"
+                    }
+                }
+            }
+        },
+        "api.WasteRecordResponse": {
+            "type": "object",
+            "properties": {
+                "waste": {
+                    "$ref": "#/definitions/store.WasteRecord"
+                }
+            }
+        },
+        "api.WasteReportResponse": {
+            "type": "object",
+            "properties": {
+                "report": {
+                    "$ref": "#/definitions/services.WasteReport"
+                }
+            }
+        },
+        "api.createTokenRequest": {
+            "type": "object",
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ingredientRequest": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "description": "Cost is per kg, liter or unit. Left unchanged on update when omitted.",
+                    "type": "number",
+                    "example": 1500
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.productIngredientRequest": {
+            "type": "object",
+            "properties": {
+                "ingredient_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "unit": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.registerCategoryRequest": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.registerClientRequest": {
+            "type": "object",
+            "properties": {
+                "address": {
+                    "type": "string"
+                },
+                "cuit": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/store.ClientType"
+                }
+            }
+        },
+        "api.registerPaymentMethodRequest": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.registerProductRequest": {
+            "type": "object",
+            "properties": {
+                "allow_negative_stock": {
+                    "type": "boolean"
+                },
+                "barcodes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "distribution_price": {
+                    "type": "number"
+                },
+                "min_stock": {
+                    "description": "low-stock alert level",
+                    "type": "number"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "sale_unit": {
+                    "type": "string",
+                    "example": "kg"
+                },
+                "target_stock": {
+                    "description": "level production tops up to",
+                    "type": "number"
+                },
+                "unit_price": {
+                    "type": "number"
+                }
             }
         },
-        "api.CategoryResponse": {
+        "api.registerProviderRequest": {
             "type": "object",
             "properties": {
-                "category": {
-                    "$ref": "#/definitions/store.Category"
+                "address": {
+                    "type": "string"
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "cuit": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
                 }
             }
         },
-        "api.ClientResponse": {
+        "api.registerUserRequest": {
             "type": "object",
             "properties": {
-                "client": {
-                    "$ref": "#/definitions/store.Client"
+                "email": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
                 }
             }
         },
-        "api.ClientsResponse": {
+        "billing.InvoiceFile": {
             "type": "object",
             "properties": {
-                "clients": {
+                "modTime": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "rawTime": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer",
+                    "format": "int64"
+                }
+            }
+        },
+        "services.CreateLocalSaleItem": {
+            "type": "object",
+            "properties": {
+                "barcode": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.CreateTransferItem": {
+            "type": "object",
+            "properties": {
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.CreateTransferRequest": {
+            "type": "object",
+            "properties": {
+                "from_location_id": {
+                    "type": "integer"
+                },
+                "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/store.Client"
+                        "$ref": "#/definitions/services.CreateTransferItem"
                     }
                 },
-                "meta": {
-                    "$ref": "#/definitions/utils.Meta"
+                "notes": {
+                    "type": "string"
+                },
+                "to_location_id": {
+                    "type": "integer"
                 }
             }
         },
-        "api.CreateInitialStockRequest": {
+        "services.ExpenseItemRequest": {
             "type": "object",
             "properties": {
-                "initial_quantity": {
-                    "type": "integer"
+                "description": {
+                    "type": "string"
                 },
-                "product_id": {
+                "ingredient_id": {
                     "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "unit_price": {
+                    "type": "number"
                 }
             }
         },
-        "api.CreateLocalSaleRequest": {
+        "services.ExpenseRequest": {
             "type": "object",
             "properties": {
+                "amount": {
+                    "description": "Amount is derived from the VAT breakdown, or else the items, when empty.",
+                    "type": "string"
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2026-05-20"
+                },
+                "due_date": {
+                    "type": "string",
+                    "example": "2026-06-20"
+                },
+                "extraction_id": {
+                    "description": "ExtractionID is the OCR reading the expense was loaded from. Its\nimage is used when no other is uploaded.",
+                    "type": "integer"
+                },
+                "invoice_number": {
+                    "type": "string",
+                    "example": "0001-00001234"
+                },
+                "invoice_type": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/store.InvoiceType"
+                        }
+                    ],
+                    "example": "A"
+                },
                 "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/services.CreateLocalSaleItem"
+                        "$ref": "#/definitions/services.ExpenseItemRequest"
                     }
                 },
-                "payment_method_id": {
+                "provider_id": {
                     "type": "integer"
+                },
+                "type": {
+                    "$ref": "#/definitions/store.ExpenseType"
+                },
+                "vat": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ExpenseVATRequest"
+                    }
                 }
             }
         },
-        "api.IngredientResponse": {
+        "services.ExpenseVATRequest": {
             "type": "object",
             "properties": {
-                "ingredient": {
-                    "$ref": "#/definitions/store.Ingredient"
+                "net_amount": {
+                    "type": "number"
+                },
+                "rate": {
+                    "type": "number"
+                },
+                "vat_amount": {
+                    "description": "VATAmount is computed from the rate when zero.",
+                    "type": "number"
                 }
             }
         },
-        "api.IngredientsResponse": {
+        "services.Payables": {
             "type": "object",
             "properties": {
-                "ingredients": {
+                "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/store.Ingredient"
+                        "$ref": "#/definitions/store.Expense"
                     }
+                },
+                "overdue": {
+                    "type": "number"
+                },
+                "total": {
+                    "type": "number"
                 }
             }
         },
-        "api.InvoicesMeta": {
+        "services.PaymentAllocationRequest": {
             "type": "object",
             "properties": {
-                "current_page": {
-                    "type": "integer"
+                "amount": {
+                    "type": "number"
                 },
-                "date_filter": {
+                "expense_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ProductionPlan": {
+            "type": "object",
+            "properties": {
+                "date": {
                     "type": "string"
                 },
-                "items_per_page": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.ProductionSuggestion"
+                    }
+                },
+                "weeks": {
+                    "type": "integer"
+                }
+            }
+        },
+        "services.ProductionSuggestion": {
+            "type": "object",
+            "properties": {
+                "average_sales": {
+                    "type": "number"
+                },
+                "min_stock": {
+                    "type": "number"
+                },
+                "pending_orders": {
+                    "type": "number"
+                },
+                "product_id": {
                     "type": "integer"
                 },
-                "total_items": {
+                "product_name": {
+                    "type": "string"
+                },
+                "projected": {
+                    "type": "number"
+                },
+                "sale_unit": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "number"
+                },
+                "suggested": {
+                    "type": "number"
+                },
+                "target_stock": {
+                    "type": "number"
+                }
+            }
+        },
+        "services.ProviderPaymentRequest": {
+            "type": "object",
+            "properties": {
+                "allocations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/services.PaymentAllocationRequest"
+                    }
+                },
+                "date": {
+                    "description": "today when empty",
+                    "type": "string",
+                    "example": "2026-05-20"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "payment_method_id": {
                     "type": "integer"
                 },
-                "total_pages": {
+                "provider_id": {
                     "type": "integer"
                 }
             }
         },
-        "api.InvoicesResponse": {
+        "services.ProviderStatement": {
             "type": "object",
             "properties": {
-                "data": {
+                "closing_balance": {
+                    "type": "number"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "lines": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/billing.InvoiceFile"
+                        "$ref": "#/definitions/store.StatementLine"
                     }
                 },
-                "meta": {
-                    "$ref": "#/definitions/api.InvoicesMeta"
+                "opening_balance": {
+                    "type": "number"
+                },
+                "provider_id": {
+                    "type": "integer"
+                },
+                "provider_name": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
                 }
             }
         },
-        "api.LocalSaleResponse": {
+        "services.PurchaseOrderItemRequest": {
             "type": "object",
             "properties": {
-                "local_sale": {
-                    "$ref": "#/definitions/store.LocalSale"
+                "expected_price": {
+                    "type": "number"
+                },
+                "ingredient_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
                 }
             }
         },
-        "api.LocalSalesResponse": {
+        "services.PurchaseOrderRequest": {
             "type": "object",
             "properties": {
-                "local_sales": {
+                "expected_date": {
+                    "type": "string",
+                    "example": "2026-05-20"
+                },
+                "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/store.LocalSale"
+                        "$ref": "#/definitions/services.PurchaseOrderItemRequest"
                     }
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "provider_id": {
+                    "type": "integer"
                 }
             }
         },
-        "api.LocalStockResponse": {
+        "services.ReceivePurchaseItem": {
             "type": "object",
             "properties": {
-                "local_stock": {
-                    "$ref": "#/definitions/store.LocalStock"
+                "item_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "unit_price": {
+                    "description": "UnitPrice is the invoiced price; the expected one when omitted.",
+                    "type": "number"
                 }
             }
         },
-        "api.LocalStocksResponse": {
+        "services.ReceivePurchaseRequest": {
             "type": "object",
             "properties": {
-                "local_stock": {
+                "category_id": {
+                    "description": "CategoryID is the expense category; \"Compras\" when zero.",
+                    "type": "integer"
+                },
+                "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/store.LocalStock"
+                        "$ref": "#/definitions/services.ReceivePurchaseItem"
                     }
+                },
+                "notes": {
+                    "type": "string"
                 }
             }
         },
-        "api.OrderItemReq": {
+        "services.RegisterWasteRequest": {
             "type": "object",
             "properties": {
-                "price": {
+                "location_id": {
+                    "description": "LocationID is where the goods are taken from; zero is the default\nlocation.",
+                    "type": "integer"
+                },
+                "note": {
                     "type": "string"
                 },
                 "product_id": {
                     "type": "integer"
                 },
                 "quantity": {
-                    "type": "integer"
+                    "type": "number"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "expired"
                 }
             }
         },
-        "api.OrderResponse": {
+        "services.ScanResult": {
             "type": "object",
             "properties": {
-                "order": {
-                    "$ref": "#/definitions/store.Order"
+                "code": {
+                    "type": "string"
+                },
+                "fixed_price": {
+                    "description": "FixedPrice is set for labels whose price is printed on them; the line\nthen takes quantity and subtotal from the label.",
+                    "type": "boolean"
+                },
+                "product": {
+                    "$ref": "#/definitions/store.Product"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "subtotal": {
+                    "type": "number"
+                },
+                "unit_price": {
+                    "type": "number"
+                },
+                "weight": {
+                    "type": "number"
                 }
             }
         },
-        "api.OrdersResponse": {
+        "services.WasteReport": {
             "type": "object",
             "properties": {
-                "meta": {
-                    "$ref": "#/definitions/utils.Meta"
+                "by_product": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.WasteByProduct"
+                    }
                 },
-                "orders": {
+                "by_week": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/store.Order"
+                        "$ref": "#/definitions/store.WasteByWeek"
                     }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                },
+                "total_value": {
+                    "type": "number"
                 }
             }
         },
-        "api.PaymentMethodResponse": {
+        "store.Category": {
             "type": "object",
             "properties": {
-                "payment_method": {
-                    "$ref": "#/definitions/store.PaymentMethod"
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
                 }
             }
         },
-        "api.PaymentMethodsResponse": {
+        "store.Client": {
             "type": "object",
             "properties": {
-                "payment_methods": {
+                "address": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "cuit": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "phone": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/store.ClientType"
+                }
+            }
+        },
+        "store.ClientType": {
+            "type": "string",
+            "enum": [
+                "distributer",
+                "individual"
+            ],
+            "x-enum-varnames": [
+                "ClientTypeDistributer",
+                "ClientTypeIndividual"
+            ]
+        },
+        "store.Expense": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "stored as NUMERIC, transferred as string",
+                    "type": "string"
+                },
+                "category_id": {
+                    "type": "integer"
+                },
+                "category_name": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "due_date": {
+                    "description": "DueDate is when a pending expense has to be paid; nil means on Date.",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "image_path": {
+                    "type": "string"
+                },
+                "invoice_number": {
+                    "type": "string"
+                },
+                "invoice_type": {
+                    "$ref": "#/definitions/store.InvoiceType"
+                },
+                "items": {
+                    "description": "Items and VAT are only loaded by GetExpenseByID.",
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/store.PaymentMethod"
+                        "$ref": "#/definitions/store.ExpenseItem"
+                    }
+                },
+                "overdue": {
+                    "description": "Overdue is set on unpaid expenses past their due date.",
+                    "type": "boolean"
+                },
+                "paid_amount": {
+                    "description": "PaidAmount is how much has been paid so far. Expenses paid on the spot\nare created with the full amount.",
+                    "type": "string"
+                },
+                "payment_status": {
+                    "$ref": "#/definitions/store.ExpensePaymentStatus"
+                },
+                "provider_id": {
+                    "type": "integer"
+                },
+                "provider_name": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/store.ExpenseType"
+                },
+                "vat": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.ExpenseVAT"
                     }
                 }
             }
         },
-        "api.ProductIngredientResponse": {
+        "store.ExpenseExtraction": {
             "type": "object",
             "properties": {
-                "product_ingredient": {
-                    "$ref": "#/definitions/store.ProductIngredient"
+                "amount": {
+                    "type": "number"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "engine": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expense_amount": {
+                    "description": "ExpenseAmount is the amount of the expense finally loaded, to compare\nwith the proposed one.",
+                    "type": "string"
+                },
+                "expense_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "image_path": {
+                    "type": "string"
+                },
+                "invoice_number": {
+                    "type": "string"
+                },
+                "invoice_type": {
+                    "$ref": "#/definitions/store.InvoiceType"
+                },
+                "provider_cuit": {
+                    "type": "string"
+                },
+                "provider_id": {
+                    "type": "integer"
+                },
+                "provider_name": {
+                    "type": "string"
+                },
+                "raw_text": {
+                    "type": "string"
+                },
+                "reviewed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/store.ExtractionStatus"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
                 }
             }
         },
-        "api.ProductResponse": {
+        "store.ExpenseItem": {
             "type": "object",
             "properties": {
-                "product": {
-                    "$ref": "#/definitions/store.Product"
+                "description": {
+                    "type": "string"
+                },
+                "expense_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ingredient_id": {
+                    "type": "integer"
+                },
+                "ingredient_name": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "unit_price": {
+                    "type": "number"
                 }
             }
         },
-        "api.ProductsResponse": {
-            "type": "object",
-            "properties": {
-                "products": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/store.Product"
-                    }
-                }
-            }
+        "store.ExpensePaymentStatus": {
+            "type": "string",
+            "enum": [
+                "paid",
+                "partial",
+                "pending"
+            ],
+            "x-enum-varnames": [
+                "ExpensePaid",
+                "ExpensePartialPaid",
+                "ExpensePending"
+            ]
         },
-        "api.ProviderResponse": {
+        "store.ExpenseType": {
+            "type": "string",
+            "enum": [
+                "local",
+                "production"
+            ],
+            "x-enum-varnames": [
+                "ExpenseTypeLocal",
+                "ExpenseTypeProduction"
+            ]
+        },
+        "store.ExpenseVAT": {
             "type": "object",
             "properties": {
-                "provider": {
-                    "$ref": "#/definitions/store.Provider"
+                "net_amount": {
+                    "type": "number"
+                },
+                "rate": {
+                    "type": "number"
+                },
+                "vat_amount": {
+                    "type": "number"
                 }
             }
         },
-        "api.ProvidersResponse": {
+        "store.ExtractionStatus": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "confirmed",
+                "discarded"
+            ],
+            "x-enum-varnames": [
+                "ExtractionPending",
+                "ExtractionConfirmed",
+                "ExtractionDiscarded"
+            ]
+        },
+        "store.Ingredient": {
             "type": "object",
             "properties": {
-                "meta": {
-                    "$ref": "#/definitions/utils.Meta"
+                "cost": {
+                    "description": "Cost is per kg, liter or unit, matching the g, ml or u used in recipes.",
+                    "type": "number"
                 },
-                "providers": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/store.Provider"
-                    }
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "stock": {
+                    "description": "Stock on hand, in the same unit as Cost. Purchases add to it.",
+                    "type": "number"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "api.RegisterOrderRequest": {
+        "store.InvoiceType": {
+            "type": "string",
+            "enum": [
+                "A",
+                "B",
+                "C",
+                "M",
+                "X"
+            ],
+            "x-enum-varnames": [
+                "InvoiceTypeA",
+                "InvoiceTypeB",
+                "InvoiceTypeC",
+                "InvoiceTypeM",
+                "InvoiceTypeX"
+            ]
+        },
+        "store.LocalSale": {
             "type": "object",
             "properties": {
-                "client_id": {
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "id": {
                     "type": "integer"
                 },
                 "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/api.OrderItemReq"
+                        "$ref": "#/definitions/store.LocalSaleItem"
                     }
                 },
-                "state": {
-                    "$ref": "#/definitions/store.OrderState"
+                "location_id": {
+                    "description": "LocationID is the stock location the sale was taken from; zero on\ncreate means the default location.",
+                    "type": "integer"
+                },
+                "payment_method_id": {
+                    "type": "integer"
+                },
+                "shift_id": {
+                    "type": "integer"
+                },
+                "subtotal": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "api.TokenResponse": {
+        "store.LocalSaleItem": {
             "type": "object",
             "properties": {
-                "auth_token": {
+                "id": {
+                    "type": "integer"
+                },
+                "line_subtotal": {
+                    "type": "string"
+                },
+                "local_sale_id": {
+                    "type": "integer"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "unit_price": {
                     "type": "string"
                 }
             }
         },
-        "api.UpdateOrderStateResponse": {
+        "store.LocalStock": {
             "type": "object",
             "properties": {
+                "created_at": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "state": {
-                    "$ref": "#/definitions/store.OrderState"
+                "location_id": {
+                    "type": "integer"
+                },
+                "location_name": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "api.UserResponse": {
+        "store.LotMovement": {
             "type": "object",
             "properties": {
-                "user": {
-                    "$ref": "#/definitions/store.User"
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lot_id": {
+                    "type": "integer"
+                },
+                "movement_id": {
+                    "type": "integer"
+                },
+                "quantity": {
+                    "type": "number"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "reference_id": {
+                    "type": "integer"
+                },
+                "reference_type": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
                 }
             }
         },
-        "api.createTokenRequest": {
+        "store.Order": {
             "type": "object",
             "properties": {
-                "password": {
+                "client_id": {
+                    "type": "integer"
+                },
+                "client_name": {
                     "type": "string"
                 },
-                "username": {
+                "created_at": {
+                    "type": "string"
+                },
+                "date": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.OrderItem"
+                    }
+                },
+                "payment_method_id": {
+                    "type": "integer"
+                },
+                "payment_method_name": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/store.OrderState"
+                },
+                "total": {
                     "type": "string"
                 }
             }
         },
-        "api.ingredientRequest": {
+        "store.OrderItem": {
             "type": "object",
             "properties": {
-                "name": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "order_id": {
+                    "type": "integer"
+                },
+                "price": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "integer"
+                },
+                "product_name": {
                     "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
                 }
             }
         },
-        "api.productIngredientRequest": {
+        "store.OrderState": {
+            "type": "string",
+            "enum": [
+                "todo",
+                "done",
+                "cancelled",
+                "delivered",
+                "paid"
+            ],
+            "x-enum-varnames": [
+                "OrderTodo",
+                "OrderDone",
+                "OrderCancelled",
+                "OrderDelivered",
+                "OrderPaid"
+            ]
+        },
+        "store.PaymentAllocation": {
             "type": "object",
             "properties": {
-                "ingredient_id": {
-                    "type": "integer"
-                },
-                "quantity": {
+                "amount": {
                     "type": "number"
                 },
-                "unit": {
+                "expense_date": {
                     "type": "string"
+                },
+                "expense_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "payment_id": {
+                    "type": "integer"
                 }
             }
         },
-        "api.registerCategoryRequest": {
+        "store.PaymentMethod": {
             "type": "object",
             "properties": {
-                "description": {
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "api.registerClientRequest": {
+        "store.Product": {
             "type": "object",
             "properties": {
-                "address": {
-                    "type": "string"
+                "allow_negative_stock": {
+                    "type": "boolean"
                 },
-                "cuit": {
-                    "type": "string"
+                "barcodes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "email": {
+                "category_id": {
+                    "type": "integer"
+                },
+                "category_name": {
                     "type": "string"
                 },
-                "name": {
+                "created_at": {
                     "type": "string"
                 },
-                "phone": {
+                "current_stock": {
+                    "description": "at the default location",
+                    "type": "number"
+                },
+                "deleted_at": {
                     "type": "string"
                 },
-                "reference": {
+                "description": {
                     "type": "string"
                 },
-                "type": {
-                    "$ref": "#/definitions/store.ClientType"
-                }
-            }
-        },
-        "api.registerPaymentMethodRequest": {
-            "type": "object",
-            "properties": {
+                "distribution_price": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "min_stock": {
+                    "description": "low-stock alert level, nil when unset",
+                    "type": "number"
+                },
                 "name": {
                     "type": "string"
                 },
-                "reference": {
+                "recipe": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.ProductIngredient"
+                    }
+                },
+                "sale_unit": {
                     "type": "string"
+                },
+                "target_stock": {
+                    "description": "level production tops up to",
+                    "type": "number"
+                },
+                "unit_price": {
+                    "type": "number"
                 }
             }
         },
-        "api.registerProductRequest": {
+        "store.ProductIngredient": {
             "type": "object",
             "properties": {
-                "category_id": {
-                    "type": "integer"
+                "cost": {
+                    "type": "number"
                 },
-                "description": {
+                "created_at": {
                     "type": "string"
                 },
-                "distribution_price": {
-                    "type": "number"
+                "id": {
+                    "type": "integer"
+                },
+                "ingredient_id": {
+                    "type": "integer"
                 },
                 "name": {
                     "type": "string"
                 },
-                "unit_price": {
+                "quantity": {
                     "type": "number"
+                },
+                "unit": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "api.registerProviderRequest": {
+        "store.Provider": {
             "type": "object",
             "properties": {
                 "address": {
                     "type": "string"
                 },
+                "category": {
+                    "type": "string"
+                },
                 "category_id": {
                     "type": "integer"
                 },
+                "created_at": {
+                    "type": "string"
+                },
                 "cuit": {
                     "type": "string"
                 },
+                "deleted_at": {
+                    "type": "string"
+                },
                 "email": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "integer"
+                },
                 "name": {
                     "type": "string"
                 },
@@ -2962,213 +6550,274 @@ const docTemplate = `{
                 }
             }
         },
-        "api.registerUserRequest": {
+        "store.ProviderBalance": {
             "type": "object",
             "properties": {
-                "email": {
-                    "type": "string"
+                "balance": {
+                    "type": "number"
                 },
-                "password": {
+                "next_due": {
                     "type": "string"
                 },
-                "role": {
-                    "type": "string"
+                "overdue": {
+                    "type": "number"
                 },
-                "username": {
+                "provider_id": {
+                    "type": "integer"
+                },
+                "provider_name": {
                     "type": "string"
                 }
             }
         },
-        "billing.InvoiceFile": {
+        "store.ProviderPayment": {
             "type": "object",
             "properties": {
-                "modTime": {
+                "allocations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.PaymentAllocation"
+                    }
+                },
+                "amount": {
+                    "type": "number"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "name": {
+                "date": {
                     "type": "string"
                 },
-                "rawTime": {
+                "id": {
+                    "type": "integer"
+                },
+                "notes": {
                     "type": "string"
                 },
-                "size": {
-                    "type": "integer",
-                    "format": "int64"
-                }
-            }
-        },
-        "services.CreateLocalSaleItem": {
-            "type": "object",
-            "properties": {
-                "product_id": {
+                "payment_method_id": {
                     "type": "integer"
                 },
-                "quantity": {
+                "payment_method_name": {
+                    "type": "string"
+                },
+                "provider_id": {
+                    "type": "integer"
+                },
+                "provider_name": {
+                    "type": "string"
+                },
+                "user_id": {
                     "type": "integer"
+                },
+                "username": {
+                    "type": "string"
                 }
             }
         },
-        "store.Category": {
+        "store.PurchaseOrder": {
             "type": "object",
             "properties": {
                 "created_at": {
                     "type": "string"
                 },
-                "deleted_at": {
-                    "type": "string"
-                },
-                "description": {
+                "expected_date": {
                     "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "name": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.PurchaseOrderItem"
+                    }
+                },
+                "notes": {
                     "type": "string"
-                }
-            }
-        },
-        "store.Client": {
-            "type": "object",
-            "properties": {
-                "address": {
+                },
+                "provider_email": {
                     "type": "string"
                 },
-                "created_at": {
+                "provider_id": {
+                    "type": "integer"
+                },
+                "provider_name": {
                     "type": "string"
                 },
-                "cuit": {
+                "receipts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.PurchaseReceipt"
+                    }
+                },
+                "sent_at": {
                     "type": "string"
                 },
-                "deleted_at": {
+                "state": {
+                    "$ref": "#/definitions/store.PurchaseOrderState"
+                },
+                "total": {
+                    "description": "at expected prices",
+                    "type": "number"
+                },
+                "updated_at": {
                     "type": "string"
                 },
-                "email": {
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
                     "type": "string"
+                }
+            }
+        },
+        "store.PurchaseOrderItem": {
+            "type": "object",
+            "properties": {
+                "expected_price": {
+                    "type": "number"
                 },
                 "id": {
                     "type": "integer"
                 },
-                "name": {
-                    "type": "string"
+                "ingredient_id": {
+                    "type": "integer"
                 },
-                "phone": {
+                "ingredient_name": {
                     "type": "string"
                 },
-                "reference": {
-                    "type": "string"
+                "purchase_order_id": {
+                    "type": "integer"
                 },
-                "type": {
-                    "$ref": "#/definitions/store.ClientType"
+                "quantity": {
+                    "type": "number"
+                },
+                "received_quantity": {
+                    "type": "number"
                 }
             }
         },
-        "store.ClientType": {
+        "store.PurchaseOrderState": {
             "type": "string",
             "enum": [
-                "distributer",
-                "individual"
+                "draft",
+                "sent",
+                "partially_received",
+                "received",
+                "cancelled"
             ],
             "x-enum-varnames": [
-                "ClientTypeDistributer",
-                "ClientTypeIndividual"
+                "PurchaseOrderDraft",
+                "PurchaseOrderSent",
+                "PurchaseOrderPartiallyReceived",
+                "PurchaseOrderReceived",
+                "PurchaseOrderCancelled"
             ]
         },
-        "store.Expense": {
+        "store.PurchaseReceipt": {
             "type": "object",
             "properties": {
                 "amount": {
-                    "description": "stored as NUMERIC, transferred as string",
+                    "type": "number"
+                },
+                "created_at": {
                     "type": "string"
                 },
-                "category_id": {
+                "expense_id": {
                     "type": "integer"
                 },
-                "category_name": {
-                    "type": "string"
+                "id": {
+                    "type": "integer"
                 },
-                "created_at": {
-                    "type": "string"
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/store.PurchaseReceiptItem"
+                    }
                 },
-                "date": {
+                "notes": {
                     "type": "string"
                 },
-                "deleted_at": {
-                    "type": "string"
+                "purchase_order_id": {
+                    "type": "integer"
                 },
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "store.PurchaseReceiptItem": {
+            "type": "object",
+            "properties": {
                 "id": {
                     "type": "integer"
                 },
-                "image_path": {
+                "ingredient_id": {
+                    "type": "integer"
+                },
+                "ingredient_name": {
                     "type": "string"
                 },
-                "provider_id": {
+                "item_id": {
                     "type": "integer"
                 },
-                "provider_name": {
-                    "type": "string"
+                "quantity": {
+                    "type": "number"
                 },
-                "type": {
-                    "$ref": "#/definitions/store.ExpenseType"
+                "receipt_id": {
+                    "type": "integer"
+                },
+                "unit_price": {
+                    "type": "number"
                 }
             }
         },
-        "store.ExpenseType": {
-            "type": "string",
-            "enum": [
-                "local",
-                "production"
-            ],
-            "x-enum-varnames": [
-                "ExpenseTypeLocal",
-                "ExpenseTypeProduction"
-            ]
-        },
-        "store.Ingredient": {
+        "store.StatementLine": {
             "type": "object",
             "properties": {
-                "created_at": {
-                    "type": "string"
+                "balance": {
+                    "type": "number"
                 },
-                "deleted_at": {
+                "credit": {
+                    "type": "number"
+                },
+                "date": {
                     "type": "string"
                 },
-                "id": {
-                    "type": "integer"
+                "debit": {
+                    "type": "number"
                 },
-                "name": {
+                "description": {
                     "type": "string"
                 },
-                "updated_at": {
+                "kind": {
+                    "description": "expense, payment or paid_on_entry",
                     "type": "string"
+                },
+                "reference_id": {
+                    "type": "integer"
                 }
             }
         },
-        "store.LocalSale": {
+        "store.StockLocation": {
             "type": "object",
             "properties": {
                 "created_at": {
                     "type": "string"
                 },
-                "deleted_at": {
-                    "type": "string"
-                },
                 "id": {
                     "type": "integer"
                 },
-                "items": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/store.LocalSaleItem"
-                    }
-                },
-                "payment_method_id": {
-                    "type": "integer"
+                "is_active": {
+                    "type": "boolean"
                 },
-                "subtotal": {
-                    "type": "string"
+                "is_default": {
+                    "type": "boolean"
                 },
-                "total": {
+                "name": {
                     "type": "string"
                 },
                 "updated_at": {
@@ -3176,65 +6825,98 @@ const docTemplate = `{
                 }
             }
         },
-        "store.LocalSaleItem": {
+        "store.StockLot": {
             "type": "object",
             "properties": {
+                "best_before": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "line_subtotal": {
-                    "type": "string"
+                "initial_quantity": {
+                    "type": "number"
                 },
-                "local_sale_id": {
+                "location_id": {
                     "type": "integer"
                 },
+                "location_name": {
+                    "type": "string"
+                },
+                "lot_number": {
+                    "type": "string"
+                },
+                "produced_on": {
+                    "type": "string"
+                },
                 "product_id": {
                     "type": "integer"
                 },
+                "product_name": {
+                    "type": "string"
+                },
                 "quantity": {
-                    "type": "integer"
+                    "type": "number"
                 },
-                "unit_price": {
+                "sale_unit": {
                     "type": "string"
                 }
             }
         },
-        "store.LocalStock": {
+        "store.StockMovement": {
             "type": "object",
             "properties": {
+                "balance": {
+                    "type": "number"
+                },
                 "created_at": {
                     "type": "string"
                 },
+                "delta": {
+                    "type": "number"
+                },
                 "id": {
                     "type": "integer"
                 },
+                "location_id": {
+                    "type": "integer"
+                },
+                "note": {
+                    "type": "string"
+                },
                 "product_id": {
                     "type": "integer"
                 },
-                "quantity": {
+                "reason": {
+                    "type": "string"
+                },
+                "reference_id": {
                     "type": "integer"
                 },
-                "updated_at": {
+                "reference_type": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                },
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "store.Order": {
+        "store.StockTransfer": {
             "type": "object",
             "properties": {
-                "client_id": {
-                    "type": "integer"
-                },
-                "client_name": {
-                    "type": "string"
-                },
                 "created_at": {
                     "type": "string"
                 },
-                "date": {
-                    "type": "string"
+                "from_location_id": {
+                    "type": "integer"
                 },
-                "deleted_at": {
+                "from_location_name": {
                     "type": "string"
                 },
                 "id": {
@@ -3243,38 +6925,32 @@ const docTemplate = `{
                 "items": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/store.OrderItem"
+                        "$ref": "#/definitions/store.StockTransferItem"
                     }
                 },
-                "payment_method_id": {
+                "notes": {
+                    "type": "string"
+                },
+                "to_location_id": {
                     "type": "integer"
                 },
-                "payment_method_name": {
+                "to_location_name": {
                     "type": "string"
                 },
-                "state": {
-                    "$ref": "#/definitions/store.OrderState"
+                "user_id": {
+                    "type": "integer"
                 },
-                "total": {
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "store.OrderItem": {
+        "store.StockTransferItem": {
             "type": "object",
             "properties": {
-                "created_at": {
-                    "type": "string"
-                },
                 "id": {
                     "type": "integer"
                 },
-                "order_id": {
-                    "type": "integer"
-                },
-                "price": {
-                    "type": "string"
-                },
                 "product_id": {
                     "type": "integer"
                 },
@@ -3282,28 +6958,17 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "quantity": {
+                    "type": "number"
+                },
+                "sale_unit": {
+                    "type": "string"
+                },
+                "transfer_id": {
                     "type": "integer"
                 }
             }
         },
-        "store.OrderState": {
-            "type": "string",
-            "enum": [
-                "todo",
-                "done",
-                "cancelled",
-                "delivered",
-                "paid"
-            ],
-            "x-enum-varnames": [
-                "OrderTodo",
-                "OrderDone",
-                "OrderCancelled",
-                "OrderDelivered",
-                "OrderPaid"
-            ]
-        },
-        "store.PaymentMethod": {
+        "store.User": {
             "type": "object",
             "properties": {
                 "created_at": {
@@ -3312,148 +6977,104 @@ const docTemplate = `{
                 "deleted_at": {
                     "type": "string"
                 },
+                "email": {
+                    "type": "string"
+                },
                 "id": {
                     "type": "integer"
                 },
-                "name": {
-                    "type": "string"
+                "is_active": {
+                    "type": "boolean"
                 },
-                "reference": {
+                "role": {
                     "type": "string"
                 },
-                "updated_at": {
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "store.Product": {
+        "store.WasteByProduct": {
             "type": "object",
             "properties": {
-                "category_id": {
+                "product_id": {
                     "type": "integer"
                 },
-                "category_name": {
-                    "type": "string"
-                },
-                "created_at": {
-                    "type": "string"
-                },
-                "current_stock": {
-                    "type": "number"
-                },
-                "deleted_at": {
-                    "type": "string"
-                },
-                "description": {
+                "product_name": {
                     "type": "string"
                 },
-                "distribution_price": {
+                "quantity": {
                     "type": "number"
                 },
-                "id": {
+                "records": {
                     "type": "integer"
                 },
-                "name": {
+                "sale_unit": {
                     "type": "string"
                 },
-                "recipe": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/store.ProductIngredient"
-                    }
-                },
-                "unit_price": {
+                "value": {
                     "type": "number"
                 }
             }
         },
-        "store.ProductIngredient": {
+        "store.WasteByWeek": {
             "type": "object",
             "properties": {
-                "created_at": {
-                    "type": "string"
-                },
-                "id": {
-                    "type": "integer"
+                "quantity": {
+                    "type": "number"
                 },
-                "ingredient_id": {
+                "records": {
                     "type": "integer"
                 },
-                "name": {
-                    "type": "string"
-                },
-                "quantity": {
+                "value": {
                     "type": "number"
                 },
-                "unit": {
-                    "type": "string"
-                },
-                "updated_at": {
+                "week_start": {
                     "type": "string"
                 }
             }
         },
-        "store.Provider": {
+        "store.WasteRecord": {
             "type": "object",
             "properties": {
-                "address": {
+                "cost_basis": {
                     "type": "string"
                 },
-                "category": {
-                    "type": "string"
-                },
-                "category_id": {
-                    "type": "integer"
-                },
                 "created_at": {
                     "type": "string"
                 },
-                "cuit": {
-                    "type": "string"
-                },
-                "deleted_at": {
-                    "type": "string"
+                "id": {
+                    "type": "integer"
                 },
-                "email": {
+                "note": {
                     "type": "string"
                 },
-                "id": {
+                "product_id": {
                     "type": "integer"
                 },
-                "name": {
+                "product_name": {
                     "type": "string"
                 },
-                "phone": {
-                    "type": "string"
+                "quantity": {
+                    "type": "number"
                 },
-                "reference": {
-                    "type": "string"
-                }
-            }
-        },
-        "store.User": {
-            "type": "object",
-            "properties": {
-                "created_at": {
+                "reason": {
                     "type": "string"
                 },
-                "deleted_at": {
+                "sale_unit": {
                     "type": "string"
                 },
-                "email": {
-                    "type": "string"
+                "unit_cost": {
+                    "type": "number"
                 },
-                "id": {
+                "user_id": {
                     "type": "integer"
                 },
-                "is_active": {
-                    "type": "boolean"
-                },
-                "role": {
-                    "type": "string"
-                },
                 "username": {
                     "type": "string"
+                },
+                "value": {
+                    "type": "number"
                 }
             }
         },